@@ -1,6 +1,9 @@
 package odata
 
-import "github.com/nlstn/go-odata/internal/auth"
+import (
+	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/rowsecurity"
+)
 
 // AuthContext contains authentication and request metadata for authorization decisions.
 type AuthContext = auth.AuthContext
@@ -16,14 +19,16 @@ type Operation = auth.Operation
 
 // Operation values for authorization checks.
 const (
-	OperationRead     = auth.OperationRead
-	OperationCreate   = auth.OperationCreate
-	OperationUpdate   = auth.OperationUpdate
-	OperationDelete   = auth.OperationDelete
-	OperationQuery    = auth.OperationQuery
-	OperationMetadata = auth.OperationMetadata
-	OperationAction   = auth.OperationAction
-	OperationFunction = auth.OperationFunction
+	OperationRead        = auth.OperationRead
+	OperationCreate      = auth.OperationCreate
+	OperationUpdate      = auth.OperationUpdate
+	OperationDelete      = auth.OperationDelete
+	OperationQuery       = auth.OperationQuery
+	OperationMetadata    = auth.OperationMetadata
+	OperationAction      = auth.OperationAction
+	OperationFunction    = auth.OperationFunction
+	OperationReadDeleted = auth.OperationReadDeleted
+	OperationRestore     = auth.OperationRestore
 )
 
 // Decision represents the result of an authorization check.
@@ -74,6 +79,11 @@ const (
 // SetPolicy registers an authorization policy for the service.
 // Pass nil to clear the policy (all requests will be allowed).
 //
+// When the policy implements QueryFilterProvider, the filter it returns for
+// OperationQuery restricts every read of the entity set, including $expand,
+// navigation, $count, $apply, $ref, batch and bound operation paths, and writes
+// that would move an entity outside the filter are refused.
+//
 // # Example
 //
 //	err := service.SetPolicy(myAuthPolicy)
@@ -98,5 +108,13 @@ func (s *Service) SetPolicy(policy Policy) error {
 	if s.operationsHandler != nil {
 		s.operationsHandler.SetPolicy(policy)
 	}
+	if s.batchHandler != nil {
+		s.batchHandler.SetPolicy(policy)
+	}
+	if _, ok := policy.(auth.QueryFilterProvider); ok {
+		if err := rowsecurity.Register(s.db); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,69 @@
+package odata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/cache"
+)
+
+// CacheInvalidator propagates invalidations of CacheLevelFull entity caches
+// between the replicas of a service. A replica publishes the entity set name
+// after a write to it commits, and every replica subscribed to that entity set
+// drops its snapshot. Set it as EntityCacheConfig.Invalidator.
+type CacheInvalidator = cache.Invalidator
+
+// DatabaseCacheInvalidator is a CacheInvalidator that keeps a version counter
+// per entity set in the _odata_cache_versions table of the primary database.
+// Writes increment the counter and every replica polls it, so no
+// infrastructure beyond the shared database is needed.
+type DatabaseCacheInvalidator = cache.DatabaseInvalidator
+
+// PubSubCacheInvalidator is a CacheInvalidator backed by an external message
+// bus. Invalidations are handed to a publish hook, and the application passes
+// the entity set names it receives from the bus to Receive.
+type PubSubCacheInvalidator = cache.PubSubInvalidator
+
+// NewDatabaseCacheInvalidator creates the _odata_cache_versions table if needed
+// and returns an invalidator that polls it every pollInterval. Writes on other
+// replicas are therefore seen after at most one interval; writes on this
+// replica are seen immediately. A zero pollInterval defaults to one second.
+//
+// Example:
+//
+//	invalidator, err := service.NewDatabaseCacheInvalidator(2 * time.Second)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	service.RegisterEntity(&Category{}, odata.EntityCacheConfig{
+//	    Level:       odata.CacheLevelFull,
+//	    TTL:         time.Hour,
+//	    Invalidator: invalidator,
+//	})
+func (s *Service) NewDatabaseCacheInvalidator(pollInterval time.Duration) (*DatabaseCacheInvalidator, error) {
+	invalidator, err := cache.NewDatabaseInvalidator(s.db, pollInterval, s.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure cache invalidator: %w", err)
+	}
+	return invalidator, nil
+}
+
+// NewPubSubCacheInvalidator returns an invalidator that publishes entity set
+// names through publish, for example to a Redis channel or NATS subject. The
+// application subscribes to the same channel and calls Receive for every
+// message, including the ones this replica sent.
+//
+// Example:
+//
+//	invalidator := odata.NewPubSubCacheInvalidator(func(ctx context.Context, entitySet string) error {
+//	    return rdb.Publish(ctx, "odata-cache", entitySet).Err()
+//	})
+//	go func() {
+//	    for msg := range rdb.Subscribe(ctx, "odata-cache").Channel() {
+//	        invalidator.Receive(msg.Payload)
+//	    }
+//	}()
+func NewPubSubCacheInvalidator(publish func(ctx context.Context, entitySet string) error) *PubSubCacheInvalidator {
+	return cache.NewPubSubInvalidator(publish)
+}
@@ -0,0 +1,81 @@
+package odata
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Collection is a collection-valued property of primitive or complex values,
+// such as tags or addresses, stored as a JSON array in a single column: jsonb
+// on PostgreSQL, json on MySQL and text on SQLite and other databases.
+//
+// It is advertised as Collection(Edm.String), Collection(Namespace.Address),
+// etc. in $metadata and can be filtered with any/all lambda operators and
+// $count:
+//
+//	type Product struct {
+//	    ID   uint                      `json:"ID" gorm:"primaryKey" odata:"key"`
+//	    Tags odata.Collection[string]  `json:"Tags"`
+//	}
+//
+//	GET /Products?$filter=Tags/any(t: t eq 'sale')
+//
+// Plain slices tagged with gorm:"serializer:json" are supported as well.
+type Collection[T any] []T
+
+// Value implements driver.Valuer.
+func (c Collection[T]) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal([]T(c))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner.
+func (c *Collection[T]) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("odata: cannot scan %T into a collection", value)
+	}
+	var values []T
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("odata: invalid collection value: %w", err)
+	}
+	*c = values
+	return nil
+}
+
+// GormDataType implements schema.GormDataTypeInterface.
+func (Collection[T]) GormDataType() string {
+	return "json"
+}
+
+// GormDBDataType chooses the column type for the database when migrating.
+func (Collection[T]) GormDBDataType(db *gorm.DB, _ *schema.Field) string {
+	switch db.Name() {
+	case "postgres":
+		return "jsonb"
+	case "mysql":
+		return "json"
+	case "sqlserver":
+		return "nvarchar(max)"
+	default:
+		return "text"
+	}
+}
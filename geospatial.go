@@ -31,6 +31,12 @@ func (s *Service) EnableGeospatial() error {
 
 	// Check if database supports geospatial features
 	if err := checkGeospatialSupport(s.db, s.logger); err != nil {
+		// SQLite without SpatiaLite can still evaluate the geo functions in Go
+		if fallbackErr := registerSQLiteGeospatialFunctions(s.db); fallbackErr == nil {
+			s.logger.Info("No spatial extension found, using the pure-Go geospatial fallback")
+			atomic.StoreInt32(&s.geospatialEnabled, 1)
+			return nil
+		}
 		s.logger.Error("Failed to enable geospatial features", "error", err)
 		return fmt.Errorf("geospatial features cannot be enabled: %w", err)
 	}
@@ -0,0 +1,90 @@
+package odata
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/nlstn/go-odata/internal/geo"
+)
+
+// EnableGeospatialFallback enables geospatial features backed by pure-Go
+// implementations of the spatial SQL functions instead of a database spatial
+// extension. The geo.distance, geo.length, geo.intersects, geo.contains and
+// geo.within filter functions then work on plain SQLite, for example in tests.
+//
+// Distances and lengths of values in SRID 4326 are geodesic, in meters on the
+// WGS 84 ellipsoid; other values are measured in the plane. EnableGeospatial
+// falls back to these functions automatically when SQLite lacks SpatiaLite.
+// The fallback is only available for SQLite.
+func (s *Service) EnableGeospatialFallback() error {
+	if err := registerSQLiteGeospatialFunctions(s.db); err != nil {
+		return fmt.Errorf("geospatial fallback cannot be enabled: %w", err)
+	}
+	atomic.StoreInt32(&s.geospatialEnabled, 1)
+	s.logger.Info("Geospatial features enabled with the pure-Go fallback")
+	return nil
+}
+
+// geospatialSQLFunctions returns the pure-Go spatial SQL functions, keyed by
+// the names the geo filter functions compile to. Spatial arguments are EWKB
+// blobs, as written by the spatial property types, or WKT text; WKT without an
+// SRID is taken to be in SRID 4326 like the literals of the geo functions. A
+// NULL argument yields NULL.
+func geospatialSQLFunctions() map[string]interface{} {
+	const defaultSRID = 4326
+	decode := func(values ...interface{}) ([]geo.Shape, bool, error) {
+		shapes := make([]geo.Shape, len(values))
+		for i, value := range values {
+			// SQLite hands NULL to interface{} arguments as a nil []byte.
+			if b, isBytes := value.([]byte); value == nil || isBytes && b == nil {
+				return nil, false, nil
+			}
+			shape, err := geo.Decode(value, defaultSRID)
+			if err != nil {
+				return nil, false, err
+			}
+			shapes[i] = shape
+		}
+		return shapes, true, nil
+	}
+	predicate := func(test func(a, b geo.Shape) bool) func(a, b interface{}) (interface{}, error) {
+		return func(a, b interface{}) (interface{}, error) {
+			shapes, ok, err := decode(a, b)
+			if !ok {
+				return nil, err
+			}
+			return test(shapes[0], shapes[1]), nil
+		}
+	}
+
+	return map[string]interface{}{
+		"ST_GeomFromText": func(text string, srid ...int64) ([]byte, error) {
+			defaultSRID := 0
+			if len(srid) > 0 {
+				defaultSRID = int(srid[0])
+			}
+			shape, err := geo.Decode(text, defaultSRID)
+			if err != nil {
+				return nil, err
+			}
+			return geo.EncodeEWKB(shape), nil
+		},
+		"ST_Distance": func(a, b interface{}) (interface{}, error) {
+			shapes, ok, err := decode(a, b)
+			if !ok {
+				return nil, err
+			}
+			return geo.Distance(shapes[0], shapes[1])
+		},
+		"ST_Length": func(a interface{}) (interface{}, error) {
+			shapes, ok, err := decode(a)
+			if !ok {
+				return nil, err
+			}
+			return geo.Length(shapes[0]), nil
+		},
+		"ST_Intersects": predicate(geo.Intersects),
+		"ST_Contains":   predicate(geo.Contains),
+		"ST_Within":     predicate(geo.Within),
+	}
+}
@@ -113,10 +113,20 @@ func TestGeospatialEnabledWithSQLiteNoSpatialite(t *testing.T) {
 		t.Fatalf("NewService() error: %v", err)
 	}
 
-	// Try to enable geospatial - should fail because SQLite doesn't have SpatiaLite
-	err = service.EnableGeospatial()
-	if err == nil {
-		t.Errorf("Expected error when enabling geospatial without SpatiaLite, but no error occurred")
+	// Without SpatiaLite, EnableGeospatial falls back to the pure-Go functions
+	if err := service.EnableGeospatial(); err != nil {
+		t.Fatalf("Expected EnableGeospatial to fall back to the pure-Go functions, got: %v", err)
+	}
+	if !service.IsGeospatialEnabled() {
+		t.Error("Expected geospatial to be enabled")
+	}
+
+	var distance float64
+	if err := db.Raw("SELECT ST_Distance(ST_GeomFromText('POINT(0 0)', 0), ST_GeomFromText('POINT(3 4)', 0))").Scan(&distance).Error; err != nil {
+		t.Fatalf("Expected the fallback ST_Distance to be registered: %v", err)
+	}
+	if distance != 5 {
+		t.Errorf("Expected planar distance 5, got %v", distance)
 	}
 }
 
@@ -0,0 +1,59 @@
+package odata
+
+import "github.com/nlstn/go-odata/internal/geo"
+
+// Spatial property types. Fields of these types are advertised in $metadata
+// with the matching EDM type (Edm.GeographyPoint, Edm.GeometryPolygon, ...),
+// serialized as GeoJSON, accepted as GeoJSON or WKT on write and persisted as
+// EWKB through database/sql. Geography values default to SRID 4326 and
+// geometry values to SRID 0; the odata:"srid=N" tag advertises another SRID.
+//
+// Example:
+//
+//	type Store struct {
+//	    ID       int                   `json:"ID" gorm:"primaryKey" odata:"key"`
+//	    Location odata.GeographyPoint  `json:"Location"`
+//	    Area     *odata.GeometryPolygon `json:"Area" odata:"srid=3857"`
+//	}
+type (
+	GeographyPoint           = geo.GeographyPoint
+	GeographyLineString      = geo.GeographyLineString
+	GeographyPolygon         = geo.GeographyPolygon
+	GeographyMultiPoint      = geo.GeographyMultiPoint
+	GeographyMultiLineString = geo.GeographyMultiLineString
+	GeographyMultiPolygon    = geo.GeographyMultiPolygon
+	GeographyCollection      = geo.GeographyCollection
+
+	GeometryPoint           = geo.GeometryPoint
+	GeometryLineString      = geo.GeometryLineString
+	GeometryPolygon         = geo.GeometryPolygon
+	GeometryMultiPoint      = geo.GeometryMultiPoint
+	GeometryMultiLineString = geo.GeometryMultiLineString
+	GeometryMultiPolygon    = geo.GeometryMultiPolygon
+	GeometryCollection      = geo.GeometryCollection
+)
+
+// Shapes embedded by the spatial property types. Their X coordinates hold
+// longitudes and their Y coordinates latitudes for geography values.
+type (
+	Position        = geo.Position
+	Point           = geo.Point
+	LineString      = geo.LineString
+	Polygon         = geo.Polygon
+	MultiPoint      = geo.MultiPoint
+	MultiLineString = geo.MultiLineString
+	MultiPolygon    = geo.MultiPolygon
+	ShapeCollection = geo.ShapeCollection
+	Shape           = geo.Shape
+)
+
+// NewGeographyPoint returns a geography point at the given longitude and
+// latitude in degrees.
+func NewGeographyPoint(longitude, latitude float64) GeographyPoint {
+	return GeographyPoint{Point: Point{X: longitude, Y: latitude}}
+}
+
+// NewGeometryPoint returns a geometry point at the given coordinates.
+func NewGeometryPoint(x, y float64) GeometryPoint {
+	return GeometryPoint{Point: Point{X: x, Y: y}}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/handlers"
 	"github.com/nlstn/go-odata/internal/response"
 )
 
@@ -72,5 +73,8 @@ func (s *Service) serveHTTP(w http.ResponseWriter, r *http.Request, allowAsync b
 		}
 	}
 
+	r = handlers.WithRowSecurity(r, s.policy, s.handlers)
+	r = handlers.WithVirtualExpand(r, s.virtualExpand)
+
 	s.runtime.ServeHTTP(w, r, allowAsync)
 }
@@ -132,11 +132,11 @@ func (h *mockEntityHandler) NavigationTargetSet(_ string) (string, bool) {
 	return "", false
 }
 
-func (h *mockEntityHandler) FetchEntity(_ string) (interface{}, error) {
+func (h *mockEntityHandler) FetchEntity(_ context.Context, _ string) (interface{}, error) {
 	return nil, nil
 }
 
-func (h *mockEntityHandler) FetchNavEntityKey(_ string, _ string) (string, error) {
+func (h *mockEntityHandler) FetchNavEntityKey(_ context.Context, _ string, _ string) (string, error) {
 	return "", nil
 }
 
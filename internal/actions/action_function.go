@@ -10,6 +10,9 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/odataerrors"
 )
 
 // ParameterDefinition defines a parameter for an action or function.
@@ -30,6 +33,8 @@ import (
 //   - reflect.TypeOf(map[string]interface{}{}) for maps
 //   - Required: If true, the parameter must be present in the request. If false, the parameter
 //     is optional and may be omitted.
+//   - Annotations: Optional vocabulary annotations. Validation vocabulary terms (Pattern, Minimum,
+//     Maximum, AllowedValues, MaxItems, MinItems) are enforced before the handler is invoked.
 //
 // Example:
 //
@@ -38,9 +43,10 @@ import (
 //	    {Name: "reason", Type: reflect.TypeOf(""), Required: false},
 //	}
 type ParameterDefinition struct {
-	Name     string
-	Type     reflect.Type
-	Required bool
+	Name        string
+	Type        reflect.Type
+	Required    bool
+	Annotations *metadata.AnnotationCollection
 }
 
 // ActionDefinition defines an OData action that can modify data.
@@ -64,6 +70,9 @@ type ParameterDefinition struct {
 //   - ReturnType: The Go type of the return value, or nil if the action returns no value.
 //     Use reflect.TypeOf(MyType{}) to specify a return type.
 //     Actions with nil ReturnType should return HTTP 204 No Content.
+//   - IncludeDeleted: Optional. For actions bound to an entity set with a soft delete
+//     property, also binds the action to deleted entities. By default invoking a bound
+//     action on a deleted entity returns 404 Not Found.
 //
 // Example - Bound action with parameters:
 //
@@ -110,6 +119,7 @@ type ActionDefinition struct {
 	Parameters          []ParameterDefinition
 	ParameterStructType reflect.Type
 	ReturnType          reflect.Type // nil if no return value
+	IncludeDeleted      bool         // Bind to soft-deleted entities as well
 }
 
 // FunctionDefinition defines an OData function that computes and returns values.
@@ -270,6 +280,44 @@ type ActionHandler func(w http.ResponseWriter, r *http.Request, ctx interface{},
 //	}
 type FunctionHandler func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) (interface{}, error)
 
+// ValidateParameterConstraints checks parsed parameter values against the Validation
+// vocabulary annotations declared on the parameter definitions. All violations are
+// reported in a single OData error with one detail per offending parameter.
+func ValidateParameterConstraints(paramDefs []ParameterDefinition, params map[string]interface{}) error {
+	var details []odataerrors.ErrorDetail
+	for _, paramDef := range paramDefs {
+		if paramDef.Annotations == nil {
+			continue
+		}
+		value, exists := params[paramDef.Name]
+		if !exists {
+			continue
+		}
+
+		violations := metadata.ValidationViolations(value, paramDef.Annotations)
+		if len(violations) == 0 {
+			continue
+		}
+
+		details = append(details, odataerrors.ErrorDetail{
+			Code:    "Validation failed",
+			Target:  paramDef.Name,
+			Message: strings.Join(violations, "; "),
+		})
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	return &odataerrors.ODataError{
+		StatusCode: http.StatusBadRequest,
+		Code:       odataerrors.ErrorCodeBadRequest,
+		Message:    "One or more parameter values violate declared validation constraints",
+		Details:    details,
+	}
+}
+
 // ParseActionParameters parses action parameters from request body
 func ParseActionParameters(r *http.Request, paramDefs []ParameterDefinition, structType reflect.Type) (map[string]interface{}, error) {
 	params := make(map[string]interface{})
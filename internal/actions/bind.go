@@ -3,8 +3,10 @@ package actions
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	publicactions "github.com/nlstn/go-odata/actions"
+	"github.com/nlstn/go-odata/internal/metadata"
 )
 
 // ParameterDefinitionsFromStruct derives parameter definitions for the provided struct type.
@@ -17,16 +19,49 @@ func ParameterDefinitionsFromStruct(t reflect.Type) ([]ParameterDefinition, erro
 
 	defs := make([]ParameterDefinition, 0, len(bindings))
 	for _, binding := range bindings {
+		annotations, err := parameterAnnotationsFromTag(binding.Field)
+		if err != nil {
+			return nil, err
+		}
 		defs = append(defs, ParameterDefinition{
-			Name:     binding.Name,
-			Type:     binding.Field.Type,
-			Required: binding.Required,
+			Name:        binding.Name,
+			Type:        binding.Field.Type,
+			Required:    binding.Required,
+			Annotations: annotations,
 		})
 	}
 
 	return defs, nil
 }
 
+// parameterAnnotationsFromTag collects annotation:... parts of a field's odata tag,
+// using the same syntax as entity properties (e.g. odata:"annotation:Validation.Minimum=0").
+func parameterAnnotationsFromTag(field reflect.StructField) (*metadata.AnnotationCollection, error) {
+	odataTag := field.Tag.Get("odata")
+	if odataTag == "" {
+		return nil, nil
+	}
+
+	var annotations *metadata.AnnotationCollection
+	for _, part := range strings.Split(odataTag, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.HasPrefix(part, "annotation:") {
+			continue
+		}
+		annotationValue := strings.TrimPrefix(part, "annotation:")
+		annotation, err := metadata.ParseAnnotationTag(annotationValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation tag %q on parameter field %s: %w", annotationValue, field.Name, err)
+		}
+		if annotations == nil {
+			annotations = metadata.NewAnnotationCollection()
+		}
+		annotations.Add(annotation)
+	}
+
+	return annotations, nil
+}
+
 func bindStructToParams(params map[string]interface{}, structType reflect.Type) error {
 	if structType == nil {
 		return nil
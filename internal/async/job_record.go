@@ -15,6 +15,19 @@ type JobRecord struct {
 	ResponseHeaders   []byte
 	ResponseBody      []byte
 	ErrorText         string
+	PercentComplete   *int
+	ProgressMessage   string
+	RecoveryPolicy    RecoveryPolicy `gorm:"size:16"`
+	RequestMethod     string         `gorm:"size:16"`
+	RequestURL        string
+	RequestHeaders    []byte
+	RequestBody       []byte
+	RequestIdentity   string
+	// Owner is the instance ID of the manager running the job. It renews
+	// LeaseExpiresAt while the job runs; other instances recover the job only
+	// once the lease has expired.
+	Owner          string `gorm:"size:64;index"`
+	LeaseExpiresAt *time.Time
 }
 
 // TableName isolates async job persistence from application tables.
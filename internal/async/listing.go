@@ -0,0 +1,123 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JobInfo is the monitoring view of a job, used by the job listing and by
+// monitor responses for unfinished jobs that have reported progress.
+type JobInfo struct {
+	ID              string     `json:"id"`
+	Status          JobStatus  `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	CompletedAt     *time.Time `json:"completedAt,omitempty"`
+	AgeSeconds      int64      `json:"ageSeconds"`
+	MonitorURL      string     `json:"monitorUrl,omitempty"`
+	PercentComplete *int       `json:"percentComplete,omitempty"`
+	ProgressMessage string     `json:"progressMessage,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// WithJobListAuthorizer enables the job listing served by ServeJobList.
+// authorize is called for every listing request; the listing is disabled when
+// no authorizer is configured.
+func WithJobListAuthorizer(authorize func(r *http.Request) bool) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.authorizeList = authorize
+	}
+}
+
+// ListJobs returns the persisted jobs, newest first. When statuses are given,
+// only jobs in one of them are returned.
+func (m *Manager) ListJobs(ctx context.Context, statuses ...JobStatus) ([]JobInfo, error) {
+	db := m.db
+	if ctx != nil {
+		db = db.WithContext(ctx)
+	}
+	if len(statuses) > 0 {
+		db = db.Where("status IN ?", statuses)
+	}
+
+	var records []JobRecord
+	if err := db.Omit("response_headers", "response_body", "request_headers", "request_body").
+		Order("created_at DESC").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	jobs := make([]JobInfo, len(records))
+	for i := range records {
+		jobs[i] = recordToInfo(&records[i], now)
+	}
+	return jobs, nil
+}
+
+// ServeJobList handles requests for the job listing. The optional status query
+// parameter takes a comma-separated list of statuses to filter by.
+func (m *Manager) ServeJobList(w http.ResponseWriter, r *http.Request) {
+	if m.authorizeList == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.authorizeList(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var statuses []JobStatus
+	if filter := r.URL.Query().Get("status"); filter != "" {
+		for _, status := range strings.Split(filter, ",") {
+			statuses = append(statuses, JobStatus(strings.TrimSpace(status)))
+		}
+	}
+
+	jobs, err := m.ListJobs(r.Context(), statuses...)
+	if err != nil {
+		log.Printf("async: failed to list jobs: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]JobInfo{"value": jobs}, r.Method != http.MethodHead)
+}
+
+func recordToInfo(record *JobRecord, now time.Time) JobInfo {
+	return JobInfo{
+		ID:              record.ID,
+		Status:          record.Status,
+		CreatedAt:       record.CreatedAt,
+		UpdatedAt:       record.UpdatedAt,
+		CompletedAt:     record.CompletedAt,
+		AgeSeconds:      int64(now.Sub(record.CreatedAt) / time.Second),
+		MonitorURL:      record.MonitorURL,
+		PercentComplete: record.PercentComplete,
+		ProgressMessage: record.ProgressMessage,
+		Error:           record.ErrorText,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}, includeBody bool) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if includeBody {
+		writeBytes(w, body)
+	}
+}
@@ -0,0 +1,130 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeJobListDisabledWithoutAuthorizer(t *testing.T) {
+	mgr, _ := newTestManager(t, 0)
+
+	rec := httptest.NewRecorder()
+	mgr.ServeJobList(rec, httptest.NewRequest(http.MethodGet, "/async/jobs/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestServeJobList(t *testing.T) {
+	db := newTestDB(t)
+	mgr, err := NewManager(db, 0, WithJobListAuthorizer(func(r *http.Request) bool {
+		return r.Header.Get("X-Role") == "admin"
+	}))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(mgr.Close)
+
+	created := time.Now().Add(-90 * time.Second)
+	if err := db.Create(&JobRecord{ID: "old", Status: JobCompleted, CreatedAt: created, UpdatedAt: created, MonitorURL: "/async/jobs/old"}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	job, err := mgr.StartJob(context.Background(), func(ctx context.Context) (*StoredResponse, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+
+	denied := httptest.NewRecorder()
+	mgr.ServeJobList(denied, httptest.NewRequest(http.MethodGet, "/async/jobs/", nil))
+	if denied.Code != http.StatusForbidden {
+		t.Errorf("unauthorized status = %d, want 403", denied.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/async/jobs/", nil)
+	req.Header.Set("X-Role", "admin")
+	rec := httptest.NewRecorder()
+	mgr.ServeJobList(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var listing struct {
+		Value []JobInfo `json:"value"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(listing.Value) != 2 || listing.Value[0].ID != job.ID || listing.Value[1].ID != "old" {
+		t.Fatalf("listing = %+v, want the new job before the old one", listing.Value)
+	}
+	if age := listing.Value[1].AgeSeconds; age < 89 || age > 120 {
+		t.Errorf("ageSeconds = %d, want about 90", age)
+	}
+
+	filtered := httptest.NewRequest(http.MethodGet, "/async/jobs/?status=completed,failed", nil)
+	filtered.Header.Set("X-Role", "admin")
+	rec = httptest.NewRecorder()
+	mgr.ServeJobList(rec, filtered)
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(listing.Value) != 1 || listing.Value[0].Status != JobCompleted {
+		t.Errorf("filtered listing = %+v, want only the completed job", listing.Value)
+	}
+
+	post := httptest.NewRequest(http.MethodPost, "/async/jobs/", nil)
+	post.Header.Set("X-Role", "admin")
+	rec = httptest.NewRecorder()
+	mgr.ServeJobList(rec, post)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestReportProgressInMonitorResponse(t *testing.T) {
+	mgr, _ := newTestManager(t, 0)
+
+	if err := ReportProgress(context.Background(), 10, "ignored"); err != nil {
+		t.Errorf("ReportProgress without a job: %v", err)
+	}
+
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	job, err := mgr.StartJob(context.Background(), func(ctx context.Context) (*StoredResponse, error) {
+		if err := ReportProgress(ctx, 140, "copying rows"); err != nil {
+			t.Errorf("ReportProgress: %v", err)
+		}
+		close(reported)
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+	<-reported
+
+	rec := httptest.NewRecorder()
+	mgr.ServeMonitor(rec, httptest.NewRequest(http.MethodGet, "/async/jobs/"+job.ID, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", rec.Code)
+	}
+	var info JobInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON: %v; body %q", err, rec.Body.String())
+	}
+	if info.Status != JobRunning || info.PercentComplete == nil || *info.PercentComplete != 100 || info.ProgressMessage != "copying rows" {
+		t.Errorf("monitor body = %+v, want running at 100%% with message", info)
+	}
+
+	close(release)
+	job.Wait()
+}
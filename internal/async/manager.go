@@ -20,6 +20,9 @@ import (
 const (
 	// DefaultJobRetention is the default amount of time completed jobs are retained.
 	DefaultJobRetention = 24 * time.Hour
+	// DefaultJobLease is the default time an unfinished job stays owned by its
+	// instance without a heartbeat.
+	DefaultJobLease = 30 * time.Second
 )
 
 var defaultJobRetention = DefaultJobRetention
@@ -56,13 +59,17 @@ type Job struct {
 	CompletedAt *time.Time
 	Response    *StoredResponse
 	Error       string
+	Progress    *JobProgress
 
 	monitorURL string
 	retryAfter *time.Duration
+	recovery   RecoveryPolicy
+	request    *StoredRequest
 
-	cancel  context.CancelFunc
-	done    chan struct{}
-	manager *Manager
+	cancel          context.CancelFunc
+	cancelRequested bool
+	done            chan struct{}
+	manager         *Manager
 }
 
 // WithMonitorURL sets the URL clients should poll for job status.
@@ -93,10 +100,22 @@ type Manager struct {
 	cleanupTicker *time.Ticker
 	stopCleanup   chan struct{}
 	db            *gorm.DB
+	authorizeList func(r *http.Request) bool
+	instanceID    string
+	lease         time.Duration
+	// recoverMu serializes recovery passes.
+	recoverMu sync.Mutex
+	// recovering is set by Recover; from then on the heartbeat also recovers
+	// jobs whose lease has expired, replaying them with replay.
+	recovering bool
+	replay     HandlerFactory
 }
 
 type managerConfig struct {
 	disableRetention bool
+	authorizeList    func(r *http.Request) bool
+	instanceID       string
+	lease            time.Duration
 }
 
 // ManagerOption configures behaviour of NewManager.
@@ -109,6 +128,25 @@ func WithRetentionDisabled() ManagerOption {
 	}
 }
 
+// WithInstanceID sets the ID under which the manager owns its jobs. Give each
+// instance sharing the jobs table a stable ID of its own, such as the pod name,
+// so that a restarted instance recovers its jobs right away. By default every
+// manager gets a random ID and its jobs are recovered once their lease expires.
+func WithInstanceID(id string) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.instanceID = id
+	}
+}
+
+// WithJobLease sets how long an unfinished job stays owned by its instance
+// without a heartbeat. The manager renews the leases of its jobs every third of
+// the duration. A non-positive duration applies DefaultJobLease.
+func WithJobLease(d time.Duration) ManagerOption {
+	return func(cfg *managerConfig) {
+		cfg.lease = d
+	}
+}
+
 // NewManager constructs a Manager with the supplied TTL for completed jobs.
 // A zero TTL applies DefaultJobRetention unless WithRetentionDisabled is provided.
 func NewManager(db *gorm.DB, ttl time.Duration, opts ...ManagerOption) (*Manager, error) {
@@ -134,12 +172,27 @@ func NewManager(db *gorm.DB, ttl time.Duration, opts ...ManagerOption) (*Manager
 		effectiveTTL = defaultJobRetention
 	}
 
+	if cfg.instanceID == "" {
+		id, err := generateID()
+		if err != nil {
+			return nil, err
+		}
+		cfg.instanceID = id
+	}
+	if cfg.lease <= 0 {
+		cfg.lease = DefaultJobLease
+	}
+
 	m := &Manager{
-		jobs:        make(map[string]*Job),
-		ttl:         effectiveTTL,
-		stopCleanup: make(chan struct{}),
-		db:          db,
+		jobs:          make(map[string]*Job),
+		ttl:           effectiveTTL,
+		stopCleanup:   make(chan struct{}),
+		db:            db,
+		authorizeList: cfg.authorizeList,
+		instanceID:    cfg.instanceID,
+		lease:         cfg.lease,
 	}
+	go m.heartbeat()
 
 	if effectiveTTL > 0 {
 		interval := effectiveTTL / 2
@@ -163,11 +216,8 @@ func NewManager(db *gorm.DB, ttl time.Duration, opts ...ManagerOption) (*Manager
 	return m, nil
 }
 
-// Close stops the manager's background cleanup.
+// Close stops the manager's background cleanup and lease renewal.
 func (m *Manager) Close() {
-	if m.cleanupTicker == nil {
-		return
-	}
 	select {
 	case <-m.stopCleanup:
 		// already closed
@@ -211,16 +261,29 @@ func (m *Manager) StartJob(ctx context.Context, handler Handler, opts ...JobOpti
 		UpdatedAt:         job.UpdatedAt,
 		MonitorURL:        job.monitorURL,
 		RetryAfterSeconds: durationToSeconds(job.retryAfter),
+		RecoveryPolicy:    job.recovery,
+		Owner:             m.instanceID,
+		LeaseExpiresAt:    m.leaseExpiry(now),
 	}
-
-	if err := m.db.Create(record).Error; err != nil {
+	if err := storedRequestToRecord(job.request, record); err != nil {
+		cancel()
 		return nil, err
 	}
 
+	// The job is registered before it is stored so that recovery never takes
+	// it for the job of a stopped process.
 	m.mu.Lock()
 	m.jobs[job.ID] = job
 	m.mu.Unlock()
 
+	if err := m.db.Create(record).Error; err != nil {
+		m.mu.Lock()
+		delete(m.jobs, job.ID)
+		m.mu.Unlock()
+		cancel()
+		return nil, err
+	}
+
 	go m.run(job, jobCtx, handler)
 
 	return job, nil
@@ -234,23 +297,29 @@ func (m *Manager) GetJob(id string) (*Job, bool) {
 	return job, ok
 }
 
-// CancelJob requests cancellation of the specified job.
+// CancelJob requests cancellation of the specified job. The job is recorded as
+// canceled right away, even if its handler keeps running until it notices the
+// canceled context; whatever it returns afterwards is discarded. Jobs that are
+// only known from the database, such as those of another process sharing the
+// table, are marked canceled without being interrupted.
 func (m *Manager) CancelJob(id string) bool {
 	m.mu.Lock()
 	job, ok := m.jobs[id]
 	if !ok {
 		m.mu.Unlock()
-		return false
+		return m.markCanceled(id)
 	}
 
-	if job.Status == JobCompleted || job.Status == JobFailed || job.Status == JobCanceled {
+	if job.Status == JobCompleted || job.Status == JobFailed || job.Status == JobCanceled || job.cancelRequested {
 		m.mu.Unlock()
 		return false
 	}
 
+	job.cancelRequested = true
 	cancel := job.cancel
 	m.mu.Unlock()
 
+	m.markCanceled(id)
 	if cancel != nil {
 		cancel()
 	}
@@ -258,6 +327,23 @@ func (m *Manager) CancelJob(id string) bool {
 	return true
 }
 
+// markCanceled records an unfinished job as canceled and reports whether it did.
+func (m *Manager) markCanceled(id string) bool {
+	now := time.Now()
+	result := m.db.Model(&JobRecord{}).
+		Where("id = ? AND status IN ?", id, []JobStatus{JobPending, JobRunning}).
+		Updates(map[string]interface{}{
+			"status":       JobCanceled,
+			"updated_at":   now,
+			"completed_at": &now,
+		})
+	if result.Error != nil {
+		log.Printf("async: failed to persist cancellation for job %s: %v", id, result.Error)
+		return false
+	}
+	return result.RowsAffected > 0
+}
+
 // Wait blocks until the job reaches a terminal state.
 func (j *Job) Wait() {
 	<-j.done
@@ -268,7 +354,16 @@ func (m *Manager) run(job *Job, ctx context.Context, handler Handler) {
 
 	m.updateStatus(job, JobRunning)
 
-	resp, err := handler(ctx)
+	resp, err := handler(ContextWithJob(ctx, job))
+
+	m.mu.Lock()
+	canceled := job.cancelRequested
+	m.mu.Unlock()
+	if canceled {
+		m.finish(job, JobCanceled, nil, nil)
+		return
+	}
+
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.Canceled) {
 			m.finish(job, JobCanceled, resp, nil)
@@ -285,12 +380,17 @@ func (m *Manager) updateStatus(job *Job, status JobStatus) {
 	now := time.Now()
 
 	m.mu.Lock()
+	if job.cancelRequested {
+		m.mu.Unlock()
+		return
+	}
 	job.Status = status
 	job.UpdatedAt = now
 	m.mu.Unlock()
 
+	// A job canceled through another manager keeps its canceled status.
 	if err := m.db.Model(&JobRecord{}).
-		Where("id = ?", job.ID).
+		Where("id = ? AND status <> ?", job.ID, JobCanceled).
 		Updates(map[string]interface{}{
 			"status":     status,
 			"updated_at": now,
@@ -319,8 +419,13 @@ func (m *Manager) finish(job *Job, status JobStatus, resp *StoredResponse, err e
 	job.CompletedAt = &now
 	job.Response = cloned
 	job.Error = errText
-	delete(m.jobs, job.ID)
 	m.mu.Unlock()
+	// The job is forgotten once its final state is stored, see StartJob.
+	defer func() {
+		m.mu.Lock()
+		delete(m.jobs, job.ID)
+		m.mu.Unlock()
+	}()
 
 	updates := map[string]interface{}{
 		"status":           status,
@@ -336,9 +441,11 @@ func (m *Manager) finish(job *Job, status JobStatus, resp *StoredResponse, err e
 		updates["response_status"] = nil
 	}
 
-	if err := m.db.Model(&JobRecord{}).
-		Where("id = ?", job.ID).
-		Updates(updates).Error; err != nil {
+	db := m.db.Model(&JobRecord{}).Where("id = ?", job.ID)
+	if status != JobCanceled {
+		db = db.Where("status <> ?", JobCanceled)
+	}
+	if err := db.Updates(updates).Error; err != nil {
 		log.Printf("async: failed to persist completion for job %s: %v", job.ID, err)
 	}
 }
@@ -502,7 +609,12 @@ func (m *Manager) ServeMonitor(w http.ResponseWriter, r *http.Request) {
 		if snapshot.retryAfter != nil {
 			w.Header().Set("Retry-After", formatRetryAfter(*snapshot.retryAfter))
 		}
-		w.WriteHeader(http.StatusAccepted)
+		if record.PercentComplete == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		// Jobs that report progress describe it in the body.
+		writeJSON(w, http.StatusAccepted, recordToInfo(&record, time.Now()), r.Method != http.MethodHead)
 	case JobCompleted:
 		writeStoredResponse(w, snapshot.response, r.Method != http.MethodHead)
 	case JobFailed:
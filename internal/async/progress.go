@@ -0,0 +1,76 @@
+package async
+
+import (
+	"context"
+	"time"
+)
+
+// JobProgress describes how far a running job has got.
+type JobProgress struct {
+	// Percent is the completed share of the work, from 0 to 100.
+	Percent int
+	// Message is an optional human-readable description of the current step.
+	Message string
+}
+
+type jobContextKey struct{}
+
+// ContextWithJob returns a copy of ctx that carries job.
+func ContextWithJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, job)
+}
+
+// JobFromContext returns the job whose handler is running with ctx.
+func JobFromContext(ctx context.Context) (*Job, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	job, ok := ctx.Value(jobContextKey{}).(*Job)
+	return job, ok && job != nil
+}
+
+// ReportProgress records progress for the job running with ctx. It does
+// nothing when ctx does not belong to an asynchronous job, so handlers can
+// report progress whether or not the client asked for async processing.
+func ReportProgress(ctx context.Context, percent int, message string) error {
+	job, ok := JobFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return job.ReportProgress(percent, message)
+}
+
+// ReportProgress records the job's progress. percent is clamped to 0..100.
+// Monitor responses for the pending job include the latest report.
+func (j *Job) ReportProgress(percent int, message string) error {
+	if j == nil {
+		return nil
+	}
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+
+	now := time.Now()
+	if j.manager != nil {
+		j.manager.mu.Lock()
+	}
+	j.Progress = &JobProgress{Percent: percent, Message: message}
+	j.UpdatedAt = now
+	if j.manager != nil {
+		j.manager.mu.Unlock()
+	}
+
+	if j.manager == nil || j.manager.db == nil {
+		return nil
+	}
+
+	return j.manager.db.Model(&JobRecord{}).
+		Where("id = ? AND status IN ?", j.ID, []JobStatus{JobPending, JobRunning}).
+		Updates(map[string]interface{}{
+			"percent_complete": percent,
+			"progress_message": message,
+			"updated_at":       now,
+		}).Error
+}
@@ -0,0 +1,337 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrJobInterrupted is recorded for jobs that were still pending or running
+// when the process stopped and were not re-enqueued.
+var ErrJobInterrupted = errors.New("async: job was interrupted by a service restart")
+
+// RecoveryPolicy decides what happens to a job that is still pending or running
+// when the service restarts.
+type RecoveryPolicy string
+
+const (
+	// RecoverFail marks interrupted jobs as failed. It is the default.
+	RecoverFail RecoveryPolicy = "fail"
+	// RecoverRequeue runs interrupted jobs again from their stored request.
+	RecoverRequeue RecoveryPolicy = "requeue"
+)
+
+// StoredRequest is the persisted form of the request an asynchronous job
+// executes, kept so the job can be replayed after a restart.
+type StoredRequest struct {
+	Method string
+	// URL is absolute so the replayed request keeps the scheme and host that
+	// responses use to build their links.
+	URL string
+	// Header holds the headers that shape the response (see storedHeader).
+	// Credentials are never stored.
+	Header http.Header
+	Body   []byte
+	// Identity names the caller in a form that is safe to store, such as a user
+	// ID, so that the replay can re-establish who issued the request.
+	Identity string
+}
+
+// NewRequest rebuilds the HTTP request bound to ctx.
+func (s *StoredRequest) NewRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, s.Method, s.URL, bytes.NewReader(s.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = cloneHeader(s.Header)
+	req.RequestURI = req.URL.RequestURI()
+	if req.URL.Scheme == "https" {
+		req.TLS = &tls.ConnectionState{}
+	}
+	req.URL.Scheme = ""
+	req.URL.Host = ""
+	return req, nil
+}
+
+// StoredRequestFrom captures r, whose body has already been read into body.
+// Only the headers that shape the response are kept: Accept, Accept-Language,
+// Content-Type, If-Match, If-None-Match, Prefer and the OData-* headers.
+// Credentials such as Authorization and Cookie are dropped; set Identity to
+// replay the request on behalf of its caller.
+func StoredRequestFrom(r *http.Request, body []byte) *StoredRequest {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	return &StoredRequest{
+		Method: r.Method,
+		URL:    u.String(),
+		Header: storedHeader(r.Header),
+		Body:   append([]byte(nil), body...),
+	}
+}
+
+// storedHeaders are the headers StoredRequestFrom keeps besides OData-*.
+var storedHeaders = map[string]bool{
+	"Accept":          true,
+	"Accept-Language": true,
+	"Content-Type":    true,
+	"If-Match":        true,
+	"If-None-Match":   true,
+	"Prefer":          true,
+}
+
+func storedHeader(h http.Header) http.Header {
+	stored := make(http.Header)
+	for k, vv := range h {
+		name := http.CanonicalHeaderKey(k)
+		if storedHeaders[name] || strings.HasPrefix(name, "Odata-") {
+			stored[k] = append([]string(nil), vv...)
+		}
+	}
+	return stored
+}
+
+// WithRecovery sets the job's recovery policy. req is stored alongside the job
+// and is required for RecoverRequeue; without it the job is marked failed.
+func WithRecovery(policy RecoveryPolicy, req *StoredRequest) JobOption {
+	return func(j *Job) {
+		j.recovery = policy
+		j.request = req
+	}
+}
+
+// HandlerFactory builds the handler that replays a stored request.
+type HandlerFactory func(req *StoredRequest) Handler
+
+// Recover resolves the jobs that were still pending or running when their
+// instance stopped. Jobs started with RecoverRequeue and a stored request run
+// again under their original ID and monitor URL, using the handler built by
+// factory; all other interrupted jobs are marked failed with ErrJobInterrupted.
+//
+// Only jobs owned by this manager's instance ID and jobs whose lease has
+// expired are recovered, so the live jobs of other instances sharing the table
+// are left alone. Each job is claimed before it is resolved, so an interrupted
+// job is recovered by one instance only. After Recover, the manager keeps
+// recovering jobs whose lease expires, such as those of an instance that
+// stopped for good. It should be called once at startup, before new jobs are
+// accepted.
+func (m *Manager) Recover(ctx context.Context, factory HandlerFactory) (requeued, failed int, err error) {
+	m.mu.Lock()
+	m.recovering = true
+	m.replay = factory
+	m.mu.Unlock()
+	return m.recoverJobs(ctx, factory)
+}
+
+func (m *Manager) recoverJobs(ctx context.Context, factory HandlerFactory) (requeued, failed int, err error) {
+	m.recoverMu.Lock()
+	defer m.recoverMu.Unlock()
+
+	now := time.Now()
+	var records []JobRecord
+	if err := m.recoverable(m.db.WithContext(ctx), now).
+		Order("created_at").
+		Find(&records).Error; err != nil {
+		return 0, 0, err
+	}
+
+	for i := range records {
+		record := &records[i]
+		if _, live := m.GetJob(record.ID); live {
+			continue
+		}
+		claimed, err := m.claim(ctx, record.ID, now)
+		if err != nil {
+			return requeued, failed, err
+		}
+		if !claimed {
+			// Another instance recovered the job first.
+			continue
+		}
+
+		if record.RecoveryPolicy == RecoverRequeue && factory != nil {
+			req, reqErr := storedRequestFromRecord(record)
+			if reqErr == nil && req != nil {
+				if reqErr = m.requeue(ctx, record, req, factory(req)); reqErr == nil {
+					requeued++
+					continue
+				}
+			}
+			if reqErr != nil {
+				log.Printf("async: failed to requeue job %s: %v", record.ID, reqErr)
+			}
+		}
+
+		now := time.Now()
+		if err := m.db.Model(&JobRecord{}).
+			Where("id = ? AND status IN ?", record.ID, []JobStatus{JobPending, JobRunning}).
+			Updates(map[string]interface{}{
+				"status":       JobFailed,
+				"updated_at":   now,
+				"completed_at": &now,
+				"error_text":   ErrJobInterrupted.Error(),
+			}).Error; err != nil {
+			return requeued, failed, err
+		}
+		failed++
+	}
+	return requeued, failed, nil
+}
+
+// recoverable restricts db to the unfinished jobs this manager may recover:
+// its own and those whose lease expired before now. Jobs without a lease were
+// written before leases existed.
+func (m *Manager) recoverable(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Model(&JobRecord{}).
+		Where("status IN ?", []JobStatus{JobPending, JobRunning}).
+		Where("owner = ? OR lease_expires_at IS NULL OR lease_expires_at < ?", m.instanceID, now)
+}
+
+// claim takes over the job with id if it is still recoverable and reports
+// whether it did.
+func (m *Manager) claim(ctx context.Context, id string, now time.Time) (bool, error) {
+	result := m.recoverable(m.db.WithContext(ctx), now).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"owner":            m.instanceID,
+			"lease_expires_at": m.leaseExpiry(time.Now()),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (m *Manager) leaseExpiry(now time.Time) *time.Time {
+	expiry := now.Add(m.lease)
+	return &expiry
+}
+
+// heartbeat renews the leases of the manager's running jobs and, once Recover
+// has been called, recovers the jobs whose lease expired, until Close.
+func (m *Manager) heartbeat() {
+	ticker := time.NewTicker(m.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.renewLeases()
+			m.mu.Lock()
+			recovering, factory := m.recovering, m.replay
+			m.mu.Unlock()
+			if recovering {
+				if _, _, err := m.recoverJobs(context.Background(), factory); err != nil {
+					log.Printf("async: failed to recover jobs with expired leases: %v", err)
+				}
+			}
+		case <-m.stopCleanup:
+			return
+		}
+	}
+}
+
+func (m *Manager) renewLeases() {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.jobs))
+	for id := range m.jobs {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+	if err := m.db.Model(&JobRecord{}).
+		Where("id IN ? AND owner = ?", ids, m.instanceID).
+		Update("lease_expires_at", m.leaseExpiry(time.Now())).Error; err != nil {
+		log.Printf("async: failed to renew job leases: %v", err)
+	}
+}
+
+func (m *Manager) requeue(ctx context.Context, record *JobRecord, req *StoredRequest, handler Handler) error {
+	if handler == nil {
+		return errors.New("async: no handler for stored request")
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:         record.ID,
+		Status:     JobPending,
+		CreatedAt:  record.CreatedAt,
+		UpdatedAt:  now,
+		monitorURL: record.MonitorURL,
+		retryAfter: secondsToDuration(record.RetryAfterSeconds),
+		recovery:   record.RecoveryPolicy,
+		request:    req,
+		done:       make(chan struct{}),
+		manager:    m,
+	}
+
+	if err := m.db.Model(&JobRecord{}).
+		Where("id = ?", job.ID).
+		Updates(map[string]interface{}{
+			"status":           JobPending,
+			"updated_at":       now,
+			"percent_complete": nil,
+			"progress_message": "",
+		}).Error; err != nil {
+		return err
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	job.cancel = cancel
+
+	m.mu.Lock()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	go m.run(job, jobCtx, handler)
+	return nil
+}
+
+func storedRequestToRecord(req *StoredRequest, record *JobRecord) error {
+	if req == nil {
+		return nil
+	}
+	headers, err := serializeHeaders(req.Header)
+	if err != nil {
+		return err
+	}
+	record.RequestMethod = req.Method
+	record.RequestURL = req.URL
+	record.RequestHeaders = headers
+	record.RequestBody = append([]byte(nil), req.Body...)
+	record.RequestIdentity = req.Identity
+	return nil
+}
+
+func storedRequestFromRecord(record *JobRecord) (*StoredRequest, error) {
+	if record.RequestMethod == "" || record.RequestURL == "" {
+		return nil, nil
+	}
+	header, err := deserializeHeaders(record.RequestHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &StoredRequest{
+		Method:   record.RequestMethod,
+		URL:      record.RequestURL,
+		Header:   header,
+		Body:     append([]byte(nil), record.RequestBody...),
+		Identity: record.RequestIdentity,
+	}, nil
+}
+
+func cloneHeader(h http.Header) http.Header {
+	cloned := make(http.Header, len(h))
+	for k, vv := range h {
+		cloned[k] = append([]string(nil), vv...)
+	}
+	return cloned
+}
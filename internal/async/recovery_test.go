@@ -0,0 +1,337 @@
+package async
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoredRequestRoundTrip(t *testing.T) {
+	original := httptest.NewRequest(http.MethodPost, "https://example.com/odata/Products?$select=Name", nil)
+	original.Header.Set("Authorization", "Bearer token")
+	original.Header.Set("Cookie", "session=secret")
+	original.Header.Set("Accept", "application/json")
+	original.Header.Set("OData-MaxVersion", "4.01")
+
+	stored := StoredRequestFrom(original, []byte(`{"Name":"Laptop"}`))
+	stored.Identity = "alice"
+	record := &JobRecord{}
+	if err := storedRequestToRecord(stored, record); err != nil {
+		t.Fatalf("storedRequestToRecord: %v", err)
+	}
+	if strings.Contains(string(record.RequestHeaders), "token") || strings.Contains(string(record.RequestHeaders), "secret") {
+		t.Fatalf("stored headers contain credentials: %s", record.RequestHeaders)
+	}
+	stored, err := storedRequestFromRecord(record)
+	if err != nil {
+		t.Fatalf("storedRequestFromRecord: %v", err)
+	}
+	if stored.URL != "https://example.com/odata/Products?$select=Name" {
+		t.Fatalf("stored URL = %q", stored.URL)
+	}
+
+	req, err := stored.NewRequest(context.Background())
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if req.Method != http.MethodPost || req.Host != "example.com" || req.TLS == nil {
+		t.Errorf("request = %s host %q TLS %v, want POST to example.com over TLS", req.Method, req.Host, req.TLS != nil)
+	}
+	if req.URL.Path != "/odata/Products" || req.URL.RawQuery != "$select=Name" || req.URL.Host != "" {
+		t.Errorf("URL = %#v, want a server-side request URL", req.URL)
+	}
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		t.Errorf("credentials replayed: %v", req.Header)
+	}
+	if req.Header.Get("Accept") != "application/json" || req.Header.Get("OData-MaxVersion") != "4.01" {
+		t.Errorf("headers = %v, want Accept and OData-MaxVersion kept", req.Header)
+	}
+	if stored.Identity != "alice" {
+		t.Errorf("identity = %q, want alice", stored.Identity)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `{"Name":"Laptop"}` {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestRecoverFailsInterruptedJobs(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&JobRecord{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	now := time.Now()
+	completedAt := now
+	records := []JobRecord{
+		{ID: "pending", Status: JobPending, CreatedAt: now, UpdatedAt: now},
+		{ID: "running", Status: JobRunning, CreatedAt: now, UpdatedAt: now, RecoveryPolicy: RecoverFail},
+		// Requeue without a stored request cannot be replayed.
+		{ID: "requeue", Status: JobRunning, CreatedAt: now, UpdatedAt: now, RecoveryPolicy: RecoverRequeue},
+		{ID: "done", Status: JobCompleted, CreatedAt: now, UpdatedAt: now, CompletedAt: &completedAt},
+	}
+	if err := db.Create(&records).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	mgr, err := NewManager(db, 0)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(mgr.Close)
+
+	requeued, failed, err := mgr.Recover(context.Background(), func(*StoredRequest) Handler {
+		t.Error("factory called for a job without stored request")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if requeued != 0 || failed != 3 {
+		t.Errorf("Recover = %d requeued, %d failed; want 0, 3", requeued, failed)
+	}
+
+	rec := httptest.NewRecorder()
+	mgr.ServeMonitor(rec, httptest.NewRequest(http.MethodGet, "/async/jobs/running", nil))
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "interrupted") {
+		t.Errorf("monitor = %d %q, want 500 with interruption error", rec.Code, rec.Body.String())
+	}
+
+	var done JobRecord
+	if err := db.First(&done, "id = ?", "done").Error; err != nil || done.Status != JobCompleted {
+		t.Errorf("completed job changed to %s (%v)", done.Status, err)
+	}
+}
+
+func TestRecoverRequeuesStoredRequest(t *testing.T) {
+	db := newTestDB(t)
+	crashed, err := NewManager(db, 0, WithInstanceID("node-1"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(crashed.Close)
+
+	// The first process never finishes the job; its handler stands in for a
+	// process that stopped mid-request.
+	stuck, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	original := httptest.NewRequest(http.MethodPost, "http://example.com/Export", nil)
+	job, err := crashed.StartJob(stuck, func(ctx context.Context) (*StoredResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithRecovery(RecoverRequeue, StoredRequestFrom(original, []byte("payload"))))
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+	if err := job.SetMonitorURL("/async/jobs/" + job.ID); err != nil {
+		t.Fatalf("SetMonitorURL: %v", err)
+	}
+
+	// The restarted instance keeps its ID and recovers its jobs right away.
+	restarted, err := NewManager(db, 0, WithInstanceID("node-1"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(restarted.Close)
+
+	requeued, failed, err := restarted.Recover(context.Background(), func(req *StoredRequest) Handler {
+		return func(ctx context.Context) (*StoredResponse, error) {
+			if req.Method != http.MethodPost || req.URL != "http://example.com/Export" || string(req.Body) != "payload" {
+				t.Errorf("replayed request = %s %s %q", req.Method, req.URL, req.Body)
+			}
+			return &StoredResponse{StatusCode: http.StatusOK, Body: []byte("exported")}, nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if requeued != 1 || failed != 0 {
+		t.Fatalf("Recover = %d requeued, %d failed; want 1, 0", requeued, failed)
+	}
+
+	replayed, ok := restarted.GetJob(job.ID)
+	if ok {
+		replayed.Wait()
+	}
+
+	rec := httptest.NewRecorder()
+	restarted.ServeMonitor(rec, httptest.NewRequest(http.MethodGet, job.MonitorURL(), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "exported" {
+		t.Errorf("monitor = %d %q, want the replayed response", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecoverLeavesJobsOfOtherInstances(t *testing.T) {
+	db := newTestDB(t)
+	other, err := NewManager(db, 0, WithInstanceID("node-1"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(other.Close)
+
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	live, err := other.StartJob(context.Background(), func(ctx context.Context) (*StoredResponse, error) {
+		<-block
+		return &StoredResponse{StatusCode: http.StatusOK}, nil
+	})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	if err := db.Create(&JobRecord{ID: "abandoned", Status: JobRunning, CreatedAt: expired, UpdatedAt: expired,
+		Owner: "node-2", LeaseExpiresAt: &expired}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	starting, err := NewManager(db, 0, WithInstanceID("node-3"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(starting.Close)
+
+	requeued, failed, err := starting.Recover(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if requeued != 0 || failed != 1 {
+		t.Errorf("Recover = %d requeued, %d failed; want 0, 1", requeued, failed)
+	}
+
+	var records []JobRecord
+	if err := db.Order("id").Find(&records).Error; err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, record := range records {
+		switch record.ID {
+		case live.ID:
+			if record.Status != JobRunning && record.Status != JobPending {
+				t.Errorf("live job of another instance = %s, want it untouched", record.Status)
+			}
+		case "abandoned":
+			if record.Status != JobFailed || record.Owner != "node-3" {
+				t.Errorf("abandoned job = %s owned by %q, want failed by node-3", record.Status, record.Owner)
+			}
+		}
+	}
+}
+
+func TestHeartbeatRecoversExpiredLeases(t *testing.T) {
+	db := newTestDB(t)
+	mgr, err := NewManager(db, 0, WithInstanceID("node-1"), WithJobLease(30*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(mgr.Close)
+	if _, _, err := mgr.Recover(context.Background(), nil); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+
+	// Another instance stops after Recover ran; its lease runs out later.
+	lease := time.Now().Add(50 * time.Millisecond)
+	if err := db.Create(&JobRecord{ID: "stopped", Status: JobRunning, CreatedAt: time.Now(), UpdatedAt: time.Now(),
+		Owner: "node-2", LeaseExpiresAt: &lease}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var record JobRecord
+		if err := db.First(&record, "id = ?", "stopped").Error; err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if record.Status == JobFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status = %s, want failed after its lease expired", record.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHeartbeatRenewsLeases(t *testing.T) {
+	db := newTestDB(t)
+	mgr, err := NewManager(db, 0, WithJobLease(30*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(mgr.Close)
+
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	job, err := mgr.StartJob(context.Background(), func(ctx context.Context) (*StoredResponse, error) {
+		<-block
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	var record JobRecord
+	if err := db.First(&record, "id = ?", job.ID).Error; err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if record.LeaseExpiresAt == nil || !record.LeaseExpiresAt.After(time.Now()) {
+		t.Errorf("lease = %v, want it renewed past now", record.LeaseExpiresAt)
+	}
+}
+
+func TestCancelJobDiscardsLateResult(t *testing.T) {
+	mgr, _ := newTestManager(t, 0)
+
+	canceled := make(chan struct{})
+	job, err := mgr.StartJob(context.Background(), func(ctx context.Context) (*StoredResponse, error) {
+		<-canceled
+		// The handler ignores cancellation and reports success.
+		return &StoredResponse{StatusCode: http.StatusOK}, nil
+	})
+	if err != nil {
+		t.Fatalf("StartJob: %v", err)
+	}
+
+	if !mgr.CancelJob(job.ID) {
+		t.Fatal("CancelJob returned false for a running job")
+	}
+	if mgr.CancelJob(job.ID) {
+		t.Error("CancelJob returned true for an already canceled job")
+	}
+
+	rec := httptest.NewRecorder()
+	mgr.ServeMonitor(rec, httptest.NewRequest(http.MethodGet, "/async/jobs/"+job.ID, nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("monitor after cancel = %d, want 204 before the handler returns", rec.Code)
+	}
+
+	close(canceled)
+	job.Wait()
+	if job.Status != JobCanceled {
+		t.Errorf("job status = %s, want canceled", job.Status)
+	}
+}
+
+func TestCancelJobMarksStoredJob(t *testing.T) {
+	mgr, db := newTestManager(t, 0)
+	now := time.Now()
+	if err := db.Create(&JobRecord{ID: "elsewhere", Status: JobRunning, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	if !mgr.CancelJob("elsewhere") {
+		t.Fatal("CancelJob returned false for a stored running job")
+	}
+	var record JobRecord
+	if err := db.First(&record, "id = ?", "elsewhere").Error; err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if record.Status != JobCanceled || record.CompletedAt == nil {
+		t.Errorf("record = %s completed %v, want canceled", record.Status, record.CompletedAt)
+	}
+	if mgr.CancelJob("missing") {
+		t.Error("CancelJob returned true for an unknown job")
+	}
+}
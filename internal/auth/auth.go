@@ -47,6 +47,10 @@ const (
 	OperationMetadata
 	OperationAction
 	OperationFunction
+	// OperationReadDeleted authorizes reading soft-deleted entities via $deleted.
+	OperationReadDeleted
+	// OperationRestore authorizes restoring a soft-deleted entity.
+	OperationRestore
 )
 
 // Decision represents the result of an authorization check.
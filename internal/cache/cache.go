@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
@@ -28,6 +29,8 @@ import (
 //   - Automatic: a snapshot expires after the configured TTL.
 //   - Manual: call Invalidate() after any write operation to force a refresh on
 //     the next read.
+//   - Distributed: with an Invalidator attached through Distribute, Publish
+//     tells the caches of other replicas to drop their snapshot as well.
 type EntityCache struct {
 	refreshMu   sync.Mutex // serializes refreshes to prevent a thundering herd
 	snap        atomic.Pointer[Snapshot]
@@ -35,6 +38,8 @@ type EntityCache struct {
 	entityType  reflect.Type
 	keyFn       KeyFunc
 	normalizeFn NormalizeFunc
+	invalidator Invalidator
+	entitySet   string
 }
 
 // KeyFunc derives the canonical string key of an entity. The argument is a
@@ -107,6 +112,32 @@ func (c *EntityCache) Invalidate() {
 	c.snap.Store(nil)
 }
 
+// Distribute subscribes the cache to invalidations of the entity set published
+// through inv, on this or any other replica, and makes Publish announce local
+// writes through it. It must be called before the cache serves reads. The
+// returned function cancels the subscription.
+func (c *EntityCache) Distribute(entitySet string, inv Invalidator) (func(), error) {
+	if inv == nil {
+		return nil, fmt.Errorf("invalidator must not be nil")
+	}
+	cancel, err := inv.Subscribe(entitySet, c.Invalidate)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidator = inv
+	c.entitySet = entitySet
+	return cancel, nil
+}
+
+// Publish announces a committed write to the other replicas through the
+// Invalidator passed to Distribute. It does nothing if there is none.
+func (c *EntityCache) Publish(ctx context.Context) error {
+	if c.invalidator == nil {
+		return nil
+	}
+	return c.invalidator.Publish(ctx, c.entitySet)
+}
+
 // Refresh reloads the entire dataset from the primary database into a fresh
 // snapshot and swaps it in atomically. It serialises concurrent refresh attempts
 // so that only one fetch runs at a time; callers that arrive while a refresh is
@@ -0,0 +1,305 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invalidator propagates cache invalidations between service replicas. A
+// replica publishes the name of an entity set after a write to it commits;
+// every subscriber of that entity set, on any replica, is then called so that
+// it drops its snapshot.
+type Invalidator interface {
+	// Publish announces that the entity set changed.
+	Publish(ctx context.Context, entitySet string) error
+	// Subscribe registers invalidate to be called whenever the entity set is
+	// published. The returned function cancels the subscription.
+	Subscribe(entitySet string, invalidate func()) (cancel func(), err error)
+}
+
+// subscribers is the set of invalidation callbacks registered per entity set.
+type subscribers struct {
+	mu    sync.Mutex
+	byKey map[string]map[*func()]struct{}
+}
+
+// add registers fn and returns the handle that removes it again.
+func (s *subscribers) add(entitySet string, fn func()) *func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byKey == nil {
+		s.byKey = make(map[string]map[*func()]struct{})
+	}
+	set := s.byKey[entitySet]
+	if set == nil {
+		set = make(map[*func()]struct{})
+		s.byKey[entitySet] = set
+	}
+	handle := &fn
+	set[handle] = struct{}{}
+	return handle
+}
+
+// remove unregisters a handle and reports whether no subscribers are left for
+// any entity set.
+func (s *subscribers) remove(entitySet string, handle *func()) (empty bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if set, ok := s.byKey[entitySet]; ok {
+		delete(set, handle)
+		if len(set) == 0 {
+			delete(s.byKey, entitySet)
+		}
+	}
+	return len(s.byKey) == 0
+}
+
+// entitySets returns the entity sets that have subscribers.
+func (s *subscribers) entitySets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sets := make([]string, 0, len(s.byKey))
+	for entitySet := range s.byKey {
+		sets = append(sets, entitySet)
+	}
+	return sets
+}
+
+// notify calls the subscribers of the entity set. The callbacks run without
+// the lock held, so they may subscribe or cancel themselves.
+func (s *subscribers) notify(entitySet string) {
+	s.mu.Lock()
+	callbacks := make([]func(), 0, len(s.byKey[entitySet]))
+	for handle := range s.byKey[entitySet] {
+		callbacks = append(callbacks, *handle)
+	}
+	s.mu.Unlock()
+	for _, invalidate := range callbacks {
+		invalidate()
+	}
+}
+
+// PubSubInvalidator connects entity caches to an external message bus such as
+// Redis pub/sub, NATS or Postgres LISTEN/NOTIFY. Publish hands the entity set
+// name to the publish hook; the application forwards every message it receives
+// from the bus, including its own, to Receive.
+type PubSubInvalidator struct {
+	publish func(ctx context.Context, entitySet string) error
+	subs    subscribers
+}
+
+// NewPubSubInvalidator creates an invalidator that publishes through the given
+// hook. Local subscribers are invalidated when Publish is called, so messages
+// that return to the publishing replica only cause an extra refresh.
+func NewPubSubInvalidator(publish func(ctx context.Context, entitySet string) error) *PubSubInvalidator {
+	return &PubSubInvalidator{publish: publish}
+}
+
+// Publish invalidates the local subscribers of the entity set and passes the
+// entity set to the publish hook.
+func (p *PubSubInvalidator) Publish(ctx context.Context, entitySet string) error {
+	p.subs.notify(entitySet)
+	if p.publish == nil {
+		return nil
+	}
+	return p.publish(ctx, entitySet)
+}
+
+// Subscribe registers invalidate for the entity set.
+func (p *PubSubInvalidator) Subscribe(entitySet string, invalidate func()) (func(), error) {
+	handle := p.subs.add(entitySet, invalidate)
+	var once sync.Once
+	return func() {
+		once.Do(func() { p.subs.remove(entitySet, handle) })
+	}, nil
+}
+
+// Receive delivers an invalidation received from the message bus to the
+// subscribers of the entity set.
+func (p *PubSubInvalidator) Receive(entitySet string) {
+	p.subs.notify(entitySet)
+}
+
+// DatabaseInvalidator shares invalidations through a version counter per
+// entity set in the _odata_cache_versions table of the primary database, so
+// replicas need no infrastructure beyond the database they already use.
+// Publish increments the counter; every replica polls the counters of the
+// entity sets it subscribes to and invalidates those that changed. Remote
+// writes therefore become visible within one poll interval.
+type DatabaseInvalidator struct {
+	db       *gorm.DB
+	interval time.Duration
+	logger   *slog.Logger
+	subs     subscribers
+
+	mu   sync.Mutex
+	seen map[string]int64 // last observed version per entity set
+	stop chan struct{}
+	done chan struct{}
+}
+
+type versionRecord struct {
+	EntitySet string `gorm:"column:entity_set;primaryKey;size:255"`
+	Version   int64  `gorm:"column:version;not null"`
+}
+
+func (versionRecord) TableName() string {
+	return "_odata_cache_versions"
+}
+
+// NewDatabaseInvalidator creates the version table if needed and returns an
+// invalidator that polls it every interval. A zero interval defaults to one
+// second. The polling loop runs while there are subscribers.
+func NewDatabaseInvalidator(db *gorm.DB, interval time.Duration, logger *slog.Logger) (*DatabaseInvalidator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("db must not be nil")
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("poll interval must not be negative")
+	}
+	if interval == 0 {
+		interval = time.Second
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if err := db.AutoMigrate(&versionRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to create cache version table: %w", err)
+	}
+	return &DatabaseInvalidator{
+		db:       db,
+		interval: interval,
+		logger:   logger,
+		seen:     make(map[string]int64),
+	}, nil
+}
+
+// Publish increments the version of the entity set and invalidates the local
+// subscribers at once.
+func (d *DatabaseInvalidator) Publish(ctx context.Context, entitySet string) error {
+	var version int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := versionRecord{EntitySet: entitySet, Version: 1}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_set"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"version": gorm.Expr(versionRecord{}.TableName() + ".version + 1")}),
+		}).Create(&record).Error; err != nil {
+			return err
+		}
+		return tx.Model(&versionRecord{}).Where("entity_set = ?", entitySet).Pluck("version", &version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish cache invalidation for %s: %w", entitySet, err)
+	}
+
+	// Earlier versions need no separate notification: the invalidation below
+	// happens after they committed.
+	d.mu.Lock()
+	if _, ok := d.seen[entitySet]; ok && version > d.seen[entitySet] {
+		d.seen[entitySet] = version
+	}
+	d.mu.Unlock()
+	d.subs.notify(entitySet)
+	return nil
+}
+
+// Subscribe registers invalidate for the entity set and starts the polling
+// loop if it is not running.
+func (d *DatabaseInvalidator) Subscribe(entitySet string, invalidate func()) (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[entitySet]; !ok {
+		versions, err := d.versions(context.Background(), []string{entitySet})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache version of %s: %w", entitySet, err)
+		}
+		d.seen[entitySet] = versions[entitySet]
+	}
+	handle := d.subs.add(entitySet, invalidate)
+	if d.stop == nil {
+		d.stop, d.done = make(chan struct{}), make(chan struct{})
+		go d.run(d.stop, d.done)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			// The last subscriber stops the loop under d.mu, the lock Subscribe
+			// holds while it adds a subscriber and starts the loop, so that a
+			// concurrent Subscribe either keeps the loop or starts a new one.
+			d.mu.Lock()
+			var stop, done chan struct{}
+			if d.subs.remove(entitySet, handle) {
+				stop, done = d.stop, d.done
+				d.stop, d.done = nil, nil
+			}
+			d.mu.Unlock()
+			if stop != nil {
+				close(stop)
+				<-done
+			}
+		})
+	}, nil
+}
+
+// Poll compares the versions of the subscribed entity sets with the ones seen
+// last and invalidates the subscribers of those that changed. The polling loop
+// calls it every interval.
+func (d *DatabaseInvalidator) Poll(ctx context.Context) error {
+	entitySets := d.subs.entitySets()
+	if len(entitySets) == 0 {
+		return nil
+	}
+	versions, err := d.versions(ctx, entitySets)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	d.mu.Lock()
+	for entitySet, version := range versions {
+		if version > d.seen[entitySet] {
+			d.seen[entitySet] = version
+			changed = append(changed, entitySet)
+		}
+	}
+	d.mu.Unlock()
+	for _, entitySet := range changed {
+		d.subs.notify(entitySet)
+	}
+	return nil
+}
+
+func (d *DatabaseInvalidator) versions(ctx context.Context, entitySets []string) (map[string]int64, error) {
+	var records []versionRecord
+	if err := d.db.WithContext(ctx).Where("entity_set IN ?", entitySets).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[string]int64, len(records))
+	for _, record := range records {
+		versions[record.EntitySet] = record.Version
+	}
+	return versions, nil
+}
+
+func (d *DatabaseInvalidator) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := d.Poll(context.Background()); err != nil {
+				d.logger.Warn("Failed to poll cache versions", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestPubSubInvalidator(t *testing.T) {
+	var published []string
+	inv := NewPubSubInvalidator(func(_ context.Context, entitySet string) error {
+		published = append(published, entitySet)
+		return nil
+	})
+
+	var widgets, gadgets int32
+	cancel, err := inv.Subscribe("Widgets", func() { atomic.AddInt32(&widgets, 1) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if _, err := inv.Subscribe("Gadgets", func() { atomic.AddInt32(&gadgets, 1) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := inv.Publish(context.Background(), "Widgets"); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if !reflect.DeepEqual(published, []string{"Widgets"}) {
+		t.Errorf("publish hook received %v, want [Widgets]", published)
+	}
+	inv.Receive("Gadgets")
+	if widgets != 1 || gadgets != 1 {
+		t.Errorf("got %d widget and %d gadget invalidations, want 1 each", widgets, gadgets)
+	}
+
+	cancel()
+	cancel()
+	inv.Receive("Widgets")
+	if widgets != 1 {
+		t.Errorf("cancelled subscriber was invalidated")
+	}
+}
+
+func TestDatabaseInvalidatorAcrossReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "versions.db")
+	open := func() *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatalf("open db: %v", err)
+		}
+		return db
+	}
+
+	// Long intervals keep the polling loops out of the way; the test polls
+	// explicitly.
+	replicaA, err := NewDatabaseInvalidator(open(), time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewDatabaseInvalidator: %v", err)
+	}
+	replicaB, err := NewDatabaseInvalidator(open(), time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewDatabaseInvalidator: %v", err)
+	}
+
+	var a, b int32
+	cancelA, err := replicaA.Subscribe("Widgets", func() { atomic.AddInt32(&a, 1) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer cancelA()
+	cancelB, err := replicaB.Subscribe("Widgets", func() { atomic.AddInt32(&b, 1) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer cancelB()
+
+	ctx := context.Background()
+	if err := replicaB.Poll(ctx); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if b != 0 {
+		t.Fatalf("replica B invalidated before any write")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := replicaA.Publish(ctx, "Widgets"); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+	if a != 2 {
+		t.Errorf("replica A invalidated %d times, want 2", a)
+	}
+	if b != 0 {
+		t.Errorf("replica B invalidated before polling")
+	}
+
+	if err := replicaB.Poll(ctx); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if err := replicaA.Poll(ctx); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if b != 1 {
+		t.Errorf("replica B invalidated %d times after polling, want 1", b)
+	}
+	if a != 2 {
+		t.Errorf("replica A was invalidated again by its own writes")
+	}
+
+	if err := replicaB.Poll(ctx); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+	if b != 1 {
+		t.Errorf("replica B invalidated again without a new write")
+	}
+}
+
+func TestDatabaseInvalidatorPollingLoop(t *testing.T) {
+	db := newSourceDB(t)
+	inv, err := NewDatabaseInvalidator(db, 10*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("NewDatabaseInvalidator: %v", err)
+	}
+	invalidated := make(chan struct{}, 1)
+	cancel, err := inv.Subscribe("Widgets", func() {
+		select {
+		case invalidated <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer cancel()
+
+	// A write by another replica only shows up in the version table.
+	if err := db.Create(&versionRecord{EntitySet: "Widgets", Version: 1}).Error; err != nil {
+		t.Fatalf("insert version: %v", err)
+	}
+	select {
+	case <-invalidated:
+	case <-time.After(2 * time.Second):
+		t.Fatal("polling loop did not pick up the new version")
+	}
+}
+
+func TestDistributeInvalidatesSnapshot(t *testing.T) {
+	db := newSourceDB(t, widget{ID: 1, Name: "a"})
+	c := newWidgetCache(t, time.Hour)
+	inv := NewPubSubInvalidator(nil)
+	cancel, err := c.Distribute("Widgets", inv)
+	if err != nil {
+		t.Fatalf("Distribute: %v", err)
+	}
+	defer cancel()
+
+	if err := c.Refresh(db); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	inv.Receive("Widgets")
+	if c.IsValid() {
+		t.Fatal("expected remote invalidation to drop the snapshot")
+	}
+
+	if err := c.Refresh(db); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if err := c.Publish(context.Background()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if c.IsValid() {
+		t.Fatal("expected Publish to invalidate local subscribers")
+	}
+}
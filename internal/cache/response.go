@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a cached HTTP response. It is immutable once stored.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	ETag       string
+}
+
+// ResponseStore holds cached responses of all entity sets of a service. Every
+// entry lists the entity sets its content was read from, so that a write to
+// any of them drops the entry, including responses of other entity sets that
+// expanded the written one.
+//
+// A response is read before it is stored, so a write may commit and invalidate
+// in between. Readers take the Generation before reading, and Put discards a
+// response when one of its entity sets was invalidated since.
+type ResponseStore struct {
+	mu          sync.Mutex
+	entries     map[string]*responseEntry
+	counts      map[string]int    // entries per owning entity set
+	generation  uint64            // incremented by every invalidation
+	invalidated map[string]uint64 // generation of the last invalidation per entity set
+}
+
+type responseEntry struct {
+	response  *Response
+	owner     string
+	dependsOn []string
+	expiresAt time.Time
+}
+
+// NewResponseStore creates an empty response store.
+func NewResponseStore() *ResponseStore {
+	return &ResponseStore{
+		entries:     make(map[string]*responseEntry),
+		counts:      make(map[string]int),
+		invalidated: make(map[string]uint64),
+	}
+}
+
+// Generation returns the current invalidation generation. Take it before
+// reading the response passed to Put.
+func (s *ResponseStore) Generation() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// Get returns the unexpired response stored under key.
+func (s *ResponseStore) Get(key string) (*Response, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(entry.expiresAt) {
+		s.remove(key, entry)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// Put stores a response of the owner entity set under key for ttl. dependsOn
+// lists the entity sets whose invalidation drops the entry; the owner is
+// always included. generation is the Generation taken before the response was
+// read; the response is discarded when the owner or one of dependsOn has been
+// invalidated since. When the owner already has maxEntries entries, expired
+// ones are evicted first and then the one closest to expiry.
+func (s *ResponseStore) Put(key, owner string, dependsOn []string, resp *Response, ttl time.Duration, maxEntries int, generation uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.invalidated[owner] > generation {
+		return
+	}
+	for _, dependency := range dependsOn {
+		if s.invalidated[dependency] > generation {
+			return
+		}
+	}
+	if existing, ok := s.entries[key]; ok {
+		s.remove(key, existing)
+	}
+	if maxEntries > 0 && s.counts[owner] >= maxEntries {
+		s.evict(owner, maxEntries-1)
+	}
+	s.entries[key] = &responseEntry{
+		response:  resp,
+		owner:     owner,
+		dependsOn: dependsOn,
+		expiresAt: time.Now().Add(ttl),
+	}
+	s.counts[owner]++
+}
+
+// Invalidate drops every entry that depends on the entity set.
+func (s *ResponseStore) Invalidate(entitySet string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	s.invalidated[entitySet] = s.generation
+	for key, entry := range s.entries {
+		if entry.owner == entitySet {
+			s.remove(key, entry)
+			continue
+		}
+		for _, dependency := range entry.dependsOn {
+			if dependency == entitySet {
+				s.remove(key, entry)
+				break
+			}
+		}
+	}
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// evicted.
+func (s *ResponseStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// evict removes entries of the owner until at most limit remain.
+func (s *ResponseStore) evict(owner string, limit int) {
+	now := time.Now()
+	for key, entry := range s.entries {
+		if entry.owner == owner && !now.Before(entry.expiresAt) {
+			s.remove(key, entry)
+		}
+	}
+	for s.counts[owner] > limit {
+		var oldestKey string
+		var oldest *responseEntry
+		for key, entry := range s.entries {
+			if entry.owner == owner && (oldest == nil || entry.expiresAt.Before(oldest.expiresAt)) {
+				oldestKey, oldest = key, entry
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		s.remove(oldestKey, oldest)
+	}
+}
+
+func (s *ResponseStore) remove(key string, entry *responseEntry) {
+	delete(s.entries, key)
+	if s.counts[entry.owner]--; s.counts[entry.owner] <= 0 {
+		delete(s.counts, entry.owner)
+	}
+}
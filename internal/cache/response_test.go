@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseStoreGetAndExpiry(t *testing.T) {
+	s := NewResponseStore()
+	resp := &Response{StatusCode: 200, Body: []byte("{}"), ETag: `W/"1"`}
+	s.Put("a", "Widgets", nil, resp, time.Hour, 0, s.Generation())
+	s.Put("b", "Widgets", nil, resp, time.Nanosecond, 0, s.Generation())
+	time.Sleep(time.Millisecond)
+
+	if got, ok := s.Get("a"); !ok || got != resp {
+		t.Fatalf("Get(a) = %v, %v; want the stored response", got, ok)
+	}
+	if _, ok := s.Get("b"); ok {
+		t.Fatal("expected expired entry to be missing")
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1 after the expired entry was dropped", s.Len())
+	}
+}
+
+func TestResponseStoreInvalidateDependencies(t *testing.T) {
+	s := NewResponseStore()
+	resp := &Response{StatusCode: 200}
+	s.Put("authors", "Authors", []string{"Books"}, resp, time.Hour, 0, s.Generation())
+	s.Put("books", "Books", []string{"Authors"}, resp, time.Hour, 0, s.Generation())
+	s.Put("tags", "Tags", nil, resp, time.Hour, 0, s.Generation())
+
+	s.Invalidate("Books")
+	if _, ok := s.Get("authors"); ok {
+		t.Error("expected the entry depending on Books to be dropped")
+	}
+	if _, ok := s.Get("books"); ok {
+		t.Error("expected the entry owned by Books to be dropped")
+	}
+	if _, ok := s.Get("tags"); !ok {
+		t.Error("expected the unrelated entry to remain")
+	}
+}
+
+func TestResponseStoreDiscardsResponsesReadBeforeInvalidation(t *testing.T) {
+	s := NewResponseStore()
+	resp := &Response{StatusCode: 200}
+
+	generation := s.Generation()
+	s.Invalidate("Books")
+	s.Put("authors", "Authors", []string{"Books"}, resp, time.Hour, 0, generation)
+	s.Put("books", "Books", nil, resp, time.Hour, 0, generation)
+	s.Put("tags", "Tags", nil, resp, time.Hour, 0, generation)
+
+	if _, ok := s.Get("authors"); ok {
+		t.Error("expected a response depending on the invalidated entity set to be discarded")
+	}
+	if _, ok := s.Get("books"); ok {
+		t.Error("expected a response of the invalidated entity set to be discarded")
+	}
+	if _, ok := s.Get("tags"); !ok {
+		t.Error("expected an unrelated response to be stored")
+	}
+
+	s.Put("books", "Books", nil, resp, time.Hour, 0, s.Generation())
+	if _, ok := s.Get("books"); !ok {
+		t.Error("expected a response read after the invalidation to be stored")
+	}
+}
+
+func TestResponseStoreMaxEntriesPerOwner(t *testing.T) {
+	s := NewResponseStore()
+	resp := &Response{StatusCode: 200}
+	s.Put("w1", "Widgets", nil, resp, time.Minute, 2, s.Generation())
+	s.Put("w2", "Widgets", nil, resp, time.Hour, 2, s.Generation())
+	s.Put("g1", "Gadgets", nil, resp, time.Hour, 2, s.Generation())
+	s.Put("w3", "Widgets", nil, resp, time.Hour, 2, s.Generation())
+
+	if _, ok := s.Get("w1"); ok {
+		t.Error("expected the entry closest to expiry to be evicted")
+	}
+	for _, key := range []string{"w2", "w3", "g1"} {
+		if _, ok := s.Get(key); !ok {
+			t.Errorf("expected %s to remain", key)
+		}
+	}
+
+	// Replacing an entry does not count against the bound.
+	s.Put("w3", "Widgets", nil, resp, time.Hour, 2, s.Generation())
+	if _, ok := s.Get("w2"); !ok {
+		t.Error("replacing w3 evicted w2")
+	}
+}
@@ -116,6 +116,33 @@ func Generate(entity interface{}, meta *metadata.EntityMetadata) string {
 	return result
 }
 
+// GenerateForContent creates a strong ETag for binary content such as media
+// entity or stream property values. Strong ETags are required for If-Range, so
+// the value changes with every byte of content rather than with an ETag property.
+func GenerateForContent(content []byte) string {
+	buf := make([]byte, 0, 18)
+	buf = append(buf, '"')
+	buf = appendHex16(buf, xxhash.Sum64(content))
+	buf = append(buf, '"')
+	return string(buf)
+}
+
+// GenerateStrong creates a strong ETag for binary content such as media entity
+// or stream property values from the entity's ETag property, without reading the
+// content. The ETag property must change whenever the content does. Returns an
+// empty string if no ETag property is defined.
+func GenerateStrong(entity interface{}, meta *metadata.EntityMetadata) string {
+	hash, ok := etagHash(entity, meta)
+	if !ok {
+		return ""
+	}
+	buf := make([]byte, 0, 18)
+	buf = append(buf, '"')
+	buf = appendHex16(buf, hash)
+	buf = append(buf, '"')
+	return string(buf)
+}
+
 // AppendJSON appends the JSON-encoded ETag string (e.g. `"W/\"0123456789abcdef\""`)
 // to dst and returns the extended slice with true. It returns (dst, false),
 // writing nothing, when the entity has no resolvable ETag — matching Generate
@@ -124,6 +124,36 @@ func TestGenerate_DifferentValues(t *testing.T) {
 	}
 }
 
+func TestGenerateForContent(t *testing.T) {
+	first := GenerateForContent([]byte("video bytes"))
+	if first != GenerateForContent([]byte("video bytes")) {
+		t.Errorf("GenerateForContent() is not stable for identical content")
+	}
+	if first == GenerateForContent([]byte("video byteS")) {
+		t.Errorf("GenerateForContent() produced the same ETag for different content: %v", first)
+	}
+	if len(first) != 18 || first[0] != '"' || first[len(first)-1] != '"' {
+		t.Errorf("GenerateForContent() = %v, want a quoted strong ETag", first)
+	}
+}
+
+func TestGenerateStrong(t *testing.T) {
+	meta := &metadata.EntityMetadata{ETagProperty: &metadata.PropertyMetadata{FieldName: "Version", IsETag: true}}
+	first := GenerateStrong(&TestEntity{ID: 1, Version: 5}, meta)
+	if len(first) != 18 || first[0] != '"' || first[len(first)-1] != '"' {
+		t.Errorf("GenerateStrong() = %v, want a quoted strong ETag", first)
+	}
+	if weak := Generate(&TestEntity{ID: 1, Version: 5}, meta); weak != "W/"+first {
+		t.Errorf("GenerateStrong() = %v, want the strong form of %v", first, weak)
+	}
+	if first == GenerateStrong(&TestEntity{ID: 1, Version: 6}, meta) {
+		t.Errorf("GenerateStrong() produced the same ETag for different versions: %v", first)
+	}
+	if got := GenerateStrong(&TestEntity{ID: 1}, &metadata.EntityMetadata{}); got != "" {
+		t.Errorf("GenerateStrong() = %v without an ETag property, want empty", got)
+	}
+}
+
 func TestGenerate_MapEntity(t *testing.T) {
 	tests := []struct {
 		name         string
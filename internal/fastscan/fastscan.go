@@ -13,6 +13,7 @@ package fastscan
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -341,6 +342,99 @@ func First(db *gorm.DB, dest interface{}) error {
 	return nil
 }
 
+// ErrNotStreamable is returned by Each for statements whose results GORM would
+// post-process after reading every row, such as preloads.
+var ErrNotStreamable = errors.New("fastscan: statement cannot be streamed")
+
+// Each executes the SELECT described by db and calls fn once per row with the
+// row scanned into a struct of type elemType. Only one row is held in memory:
+// the value passed to fn is reused for the next row, so fn must copy anything
+// it keeps. Returning an error from fn stops the iteration and Each returns that
+// error. Rows the compiled plan cannot scan are scanned through GORM instead;
+// AfterFind hooks run for every row. Like Find, it consumes db's statement.
+func Each(db *gorm.DB, elemType reflect.Type, fn func(elem reflect.Value) error) error {
+	if elemType.Kind() != reflect.Struct || db.DryRun {
+		return ErrNotStreamable
+	}
+	dest := reflect.New(elemType)
+
+	tx := db
+	if tx.Statement.Model == nil {
+		tx = tx.Model(dest.Interface())
+	}
+	if err := tx.Statement.Parse(tx.Statement.Model); err != nil {
+		return err
+	}
+	sch := tx.Statement.Schema
+	if sch == nil || sch.ModelType != elemType || !eligibleStatement(tx.Statement, sch) {
+		return ErrNotStreamable
+	}
+	p := planFor(sch)
+	if p != nil && p.likelyTouchesPoisoned(tx.Statement, sch) {
+		p = nil
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close() //nolint:errcheck // the iteration error, if any, is more useful
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	ctx := tx.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var bindings *bindingSet
+	if p != nil && !p.anyPoisoned(columns) {
+		bindings = p.acquireBindingSet(columns)
+		defer p.releaseBindingSet(bindings)
+	}
+
+	elem := dest.Elem()
+	zero := reflect.Zero(elemType)
+	hook, hasAfterFind := dest.Interface().(interface{ AfterFind(*gorm.DB) error })
+	fast := bindings != nil
+	for rows.Next() {
+		elem.Set(zero)
+		if fast {
+			if err := scanRowInto(ctx, rows, bindings, elem); err != nil {
+				scanErr, ok := err.(*rowScanError)
+				if !ok {
+					return err
+				}
+				if ctxErr := contextErr(ctx); ctxErr != nil {
+					return scanErr.err
+				}
+				// Rows already handed to fn cannot be re-read, so switch to
+				// GORM's scanner for this and every following row.
+				p.recordScanFailure(scanErr.err, columns)
+				fast = false
+				elem.Set(zero)
+			}
+		}
+		if !fast {
+			if err := tx.ScanRows(rows, dest.Interface()); err != nil {
+				return err
+			}
+		}
+		if hasAfterFind && sch.AfterFind {
+			if err := hook.AfterFind(tx); err != nil {
+				return err
+			}
+		}
+		if err := fn(elem); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // planFor returns the cached scan plan for sch, or nil when the schema is
 // ineligible for fast scanning.
 func planFor(sch *schema.Schema) *plan {
@@ -734,3 +734,91 @@ func TestFieldScanModes(t *testing.T) {
 		}
 	}
 }
+
+// eachWidgets streams the rows of build through Each and returns copies of them.
+func eachWidgets(t *testing.T, build func() *gorm.DB) []Widget {
+	t.Helper()
+	var streamed []Widget
+	err := Each(build(), reflect.TypeOf(Widget{}), func(elem reflect.Value) error {
+		streamed = append(streamed, elem.Interface().(Widget))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	return streamed
+}
+
+func TestEachMatchesFind(t *testing.T) {
+	db := openDB(t)
+	seedWidgets(t, db)
+
+	var found []Widget
+	if err := Find(db.Order("id"), &found); err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	streamed := eachWidgets(t, func() *gorm.DB { return db.Order("id") })
+	if !reflect.DeepEqual(streamed, found) {
+		t.Fatalf("Each result differs from Find:\neach: %#v\nfind: %#v", streamed, found)
+	}
+	// The second row has NULL columns; the reused value must not keep the
+	// first row's pointers.
+	if streamed[1].Description != nil || streamed[1].Quantity != nil {
+		t.Errorf("row values leaked into the next row: %+v", streamed[1])
+	}
+}
+
+func TestEachStopsOnCallbackError(t *testing.T) {
+	db := openDB(t)
+	seedWidgets(t, db)
+
+	stop := errors.New("stop")
+	calls := 0
+	err := Each(db.Order("id"), reflect.TypeOf(Widget{}), func(reflect.Value) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Each error = %v, want %v", err, stop)
+	}
+	if calls != 1 {
+		t.Errorf("callback ran %d times, want 1", calls)
+	}
+}
+
+func TestEachRunsAfterFindHook(t *testing.T) {
+	db := openDB(t)
+	if err := db.AutoMigrate(&Hooked{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&[]Hooked{{Name: "a"}, {Name: "b"}}).Error; err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	var counts []int
+	err := Each(db.Order("id"), reflect.TypeOf(Hooked{}), func(elem reflect.Value) error {
+		counts = append(counts, elem.Interface().(Hooked).Count)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	if !reflect.DeepEqual(counts, []int{42, 42}) {
+		t.Fatalf("AfterFind hook counts = %v, want [42 42]", counts)
+	}
+}
+
+func TestEachRejectsPreload(t *testing.T) {
+	db := openDB(t)
+	if err := db.AutoMigrate(&Parent{}, &Child{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	err := Each(db.Preload("Children"), reflect.TypeOf(Parent{}), func(reflect.Value) error {
+		t.Fatal("callback must not run for a preloading statement")
+		return nil
+	})
+	if !errors.Is(err, ErrNotStreamable) {
+		t.Fatalf("Each error = %v, want %v", err, ErrNotStreamable)
+	}
+}
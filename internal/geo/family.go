@@ -0,0 +1,180 @@
+package geo
+
+import "database/sql/driver"
+
+// The family types give the shapes an EDM type. Geography values default to
+// SRID 4326 and geometry values to SRID 0 when their SRID field is 0.
+
+// GeographyPoint is an Edm.GeographyPoint value.
+type GeographyPoint struct{ Point }
+
+func (GeographyPoint) EdmType() string { return "Edm.GeographyPoint" }
+
+func (p GeographyPoint) MarshalJSON() ([]byte, error) { return encodeGeoJSON(p.node(), Geography) }
+
+func (p GeographyPoint) Value() (driver.Value, error) { return encodeEWKB(p.node(), Geography), nil }
+
+func (p GeographyPoint) String() string { return formatWKT(p.node(), Geography) }
+
+// GeographyLineString is an Edm.GeographyLineString value.
+type GeographyLineString struct{ LineString }
+
+func (GeographyLineString) EdmType() string { return "Edm.GeographyLineString" }
+
+func (l GeographyLineString) MarshalJSON() ([]byte, error) { return encodeGeoJSON(l.node(), Geography) }
+
+func (l GeographyLineString) Value() (driver.Value, error) {
+	return encodeEWKB(l.node(), Geography), nil
+}
+
+func (l GeographyLineString) String() string { return formatWKT(l.node(), Geography) }
+
+// GeographyPolygon is an Edm.GeographyPolygon value.
+type GeographyPolygon struct{ Polygon }
+
+func (GeographyPolygon) EdmType() string { return "Edm.GeographyPolygon" }
+
+func (p GeographyPolygon) MarshalJSON() ([]byte, error) { return encodeGeoJSON(p.node(), Geography) }
+
+func (p GeographyPolygon) Value() (driver.Value, error) { return encodeEWKB(p.node(), Geography), nil }
+
+func (p GeographyPolygon) String() string { return formatWKT(p.node(), Geography) }
+
+// GeographyMultiPoint is an Edm.GeographyMultiPoint value.
+type GeographyMultiPoint struct{ MultiPoint }
+
+func (GeographyMultiPoint) EdmType() string { return "Edm.GeographyMultiPoint" }
+
+func (m GeographyMultiPoint) MarshalJSON() ([]byte, error) { return encodeGeoJSON(m.node(), Geography) }
+
+func (m GeographyMultiPoint) Value() (driver.Value, error) {
+	return encodeEWKB(m.node(), Geography), nil
+}
+
+func (m GeographyMultiPoint) String() string { return formatWKT(m.node(), Geography) }
+
+// GeographyMultiLineString is an Edm.GeographyMultiLineString value.
+type GeographyMultiLineString struct{ MultiLineString }
+
+func (GeographyMultiLineString) EdmType() string { return "Edm.GeographyMultiLineString" }
+
+func (m GeographyMultiLineString) MarshalJSON() ([]byte, error) {
+	return encodeGeoJSON(m.node(), Geography)
+}
+
+func (m GeographyMultiLineString) Value() (driver.Value, error) {
+	return encodeEWKB(m.node(), Geography), nil
+}
+
+func (m GeographyMultiLineString) String() string { return formatWKT(m.node(), Geography) }
+
+// GeographyMultiPolygon is an Edm.GeographyMultiPolygon value.
+type GeographyMultiPolygon struct{ MultiPolygon }
+
+func (GeographyMultiPolygon) EdmType() string { return "Edm.GeographyMultiPolygon" }
+
+func (m GeographyMultiPolygon) MarshalJSON() ([]byte, error) {
+	return encodeGeoJSON(m.node(), Geography)
+}
+
+func (m GeographyMultiPolygon) Value() (driver.Value, error) {
+	return encodeEWKB(m.node(), Geography), nil
+}
+
+func (m GeographyMultiPolygon) String() string { return formatWKT(m.node(), Geography) }
+
+// GeographyCollection is an Edm.GeographyCollection value.
+type GeographyCollection struct{ ShapeCollection }
+
+func (GeographyCollection) EdmType() string { return "Edm.GeographyCollection" }
+
+func (c GeographyCollection) MarshalJSON() ([]byte, error) { return encodeGeoJSON(c.node(), Geography) }
+
+func (c GeographyCollection) Value() (driver.Value, error) {
+	return encodeEWKB(c.node(), Geography), nil
+}
+
+func (c GeographyCollection) String() string { return formatWKT(c.node(), Geography) }
+
+// GeometryPoint is an Edm.GeometryPoint value.
+type GeometryPoint struct{ Point }
+
+func (GeometryPoint) EdmType() string { return "Edm.GeometryPoint" }
+
+func (p GeometryPoint) MarshalJSON() ([]byte, error) { return encodeGeoJSON(p.node(), Geometry) }
+
+func (p GeometryPoint) Value() (driver.Value, error) { return encodeEWKB(p.node(), Geometry), nil }
+
+func (p GeometryPoint) String() string { return formatWKT(p.node(), Geometry) }
+
+// GeometryLineString is an Edm.GeometryLineString value.
+type GeometryLineString struct{ LineString }
+
+func (GeometryLineString) EdmType() string { return "Edm.GeometryLineString" }
+
+func (l GeometryLineString) MarshalJSON() ([]byte, error) { return encodeGeoJSON(l.node(), Geometry) }
+
+func (l GeometryLineString) Value() (driver.Value, error) { return encodeEWKB(l.node(), Geometry), nil }
+
+func (l GeometryLineString) String() string { return formatWKT(l.node(), Geometry) }
+
+// GeometryPolygon is an Edm.GeometryPolygon value.
+type GeometryPolygon struct{ Polygon }
+
+func (GeometryPolygon) EdmType() string { return "Edm.GeometryPolygon" }
+
+func (p GeometryPolygon) MarshalJSON() ([]byte, error) { return encodeGeoJSON(p.node(), Geometry) }
+
+func (p GeometryPolygon) Value() (driver.Value, error) { return encodeEWKB(p.node(), Geometry), nil }
+
+func (p GeometryPolygon) String() string { return formatWKT(p.node(), Geometry) }
+
+// GeometryMultiPoint is an Edm.GeometryMultiPoint value.
+type GeometryMultiPoint struct{ MultiPoint }
+
+func (GeometryMultiPoint) EdmType() string { return "Edm.GeometryMultiPoint" }
+
+func (m GeometryMultiPoint) MarshalJSON() ([]byte, error) { return encodeGeoJSON(m.node(), Geometry) }
+
+func (m GeometryMultiPoint) Value() (driver.Value, error) { return encodeEWKB(m.node(), Geometry), nil }
+
+func (m GeometryMultiPoint) String() string { return formatWKT(m.node(), Geometry) }
+
+// GeometryMultiLineString is an Edm.GeometryMultiLineString value.
+type GeometryMultiLineString struct{ MultiLineString }
+
+func (GeometryMultiLineString) EdmType() string { return "Edm.GeometryMultiLineString" }
+
+func (m GeometryMultiLineString) MarshalJSON() ([]byte, error) {
+	return encodeGeoJSON(m.node(), Geometry)
+}
+
+func (m GeometryMultiLineString) Value() (driver.Value, error) {
+	return encodeEWKB(m.node(), Geometry), nil
+}
+
+func (m GeometryMultiLineString) String() string { return formatWKT(m.node(), Geometry) }
+
+// GeometryMultiPolygon is an Edm.GeometryMultiPolygon value.
+type GeometryMultiPolygon struct{ MultiPolygon }
+
+func (GeometryMultiPolygon) EdmType() string { return "Edm.GeometryMultiPolygon" }
+
+func (m GeometryMultiPolygon) MarshalJSON() ([]byte, error) { return encodeGeoJSON(m.node(), Geometry) }
+
+func (m GeometryMultiPolygon) Value() (driver.Value, error) {
+	return encodeEWKB(m.node(), Geometry), nil
+}
+
+func (m GeometryMultiPolygon) String() string { return formatWKT(m.node(), Geometry) }
+
+// GeometryCollection is an Edm.GeometryCollection value.
+type GeometryCollection struct{ ShapeCollection }
+
+func (GeometryCollection) EdmType() string { return "Edm.GeometryCollection" }
+
+func (c GeometryCollection) MarshalJSON() ([]byte, error) { return encodeGeoJSON(c.node(), Geometry) }
+
+func (c GeometryCollection) Value() (driver.Value, error) { return encodeEWKB(c.node(), Geometry), nil }
+
+func (c GeometryCollection) String() string { return formatWKT(c.node(), Geometry) }
@@ -0,0 +1,302 @@
+// Package geo implements the OData spatial primitive types. Values serialize as
+// GeoJSON, parse from GeoJSON and well-known text (WKT), and persist as
+// extended well-known binary (EWKB) through database/sql.
+package geo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Family distinguishes round-earth geography values from flat-earth geometry
+// values.
+type Family uint8
+
+const (
+	Geography Family = iota
+	Geometry
+)
+
+// String returns the family name as used in EDM type names.
+func (f Family) String() string {
+	if f == Geography {
+		return "Geography"
+	}
+	return "Geometry"
+}
+
+// DefaultSRID returns the spatial reference system of values that name none:
+// WGS 84 (4326) for geography and 0 for geometry, as CSDL defines.
+func (f Family) DefaultSRID() int {
+	if f == Geography {
+		return 4326
+	}
+	return 0
+}
+
+// Kind identifies the shape of a spatial value.
+type Kind uint8
+
+const (
+	KindPoint Kind = iota + 1
+	KindLineString
+	KindPolygon
+	KindMultiPoint
+	KindMultiLineString
+	KindMultiPolygon
+	KindCollection
+)
+
+var kindNames = [...]string{
+	KindPoint:           "Point",
+	KindLineString:      "LineString",
+	KindPolygon:         "Polygon",
+	KindMultiPoint:      "MultiPoint",
+	KindMultiLineString: "MultiLineString",
+	KindMultiPolygon:    "MultiPolygon",
+	KindCollection:      "GeometryCollection",
+}
+
+// String returns the GeoJSON and WKT name of the kind.
+func (k Kind) String() string {
+	if int(k) < len(kindNames) && kindNames[k] != "" {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", uint8(k))
+}
+
+// edmName returns the kind's suffix in EDM type names.
+func (k Kind) edmName() string {
+	if k == KindCollection {
+		return "Collection"
+	}
+	return k.String()
+}
+
+// EdmType returns the EDM type name of spatial values of a family and kind,
+// e.g. Edm.GeographyPoint.
+func EdmType(family Family, kind Kind) string {
+	return "Edm." + family.String() + kind.edmName()
+}
+
+// ParseEdmType splits a spatial EDM type name into its family and kind.
+func ParseEdmType(name string) (Family, Kind, bool) {
+	rest, ok := strings.CutPrefix(name, "Edm.")
+	if !ok {
+		return 0, 0, false
+	}
+	var family Family
+	switch {
+	case strings.HasPrefix(rest, "Geography"):
+		family, rest = Geography, strings.TrimPrefix(rest, "Geography")
+	case strings.HasPrefix(rest, "Geometry"):
+		family, rest = Geometry, strings.TrimPrefix(rest, "Geometry")
+	default:
+		return 0, 0, false
+	}
+	for kind := KindPoint; kind <= KindCollection; kind++ {
+		if kind.edmName() == rest {
+			return family, kind, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Position is a coordinate pair. Geography positions hold the longitude in X
+// and the latitude in Y, both in degrees.
+type Position struct {
+	X, Y float64
+}
+
+// Shape is a spatial value of any kind.
+type Shape interface {
+	Kind() Kind
+	node() node
+}
+
+// node is the kind-independent form of a shape the codecs work on.
+type node struct {
+	kind     Kind
+	srid     int
+	points   []Position     // Point (exactly one), LineString, MultiPoint
+	lines    [][]Position   // Polygon rings, MultiLineString
+	polygons [][][]Position // MultiPolygon
+	members  []node         // ShapeCollection
+}
+
+// decoder is implemented by pointers to shapes, which take their value from a
+// decoded node of their kind.
+type decoder interface {
+	Shape
+	assign(n node)
+}
+
+// Point is a single position.
+type Point struct {
+	X, Y float64
+	SRID int
+}
+
+// LineString is a sequence of positions.
+type LineString struct {
+	Points []Position
+	SRID   int
+}
+
+// Polygon is an area bounded by closed rings. The first ring is the exterior
+// boundary; further rings are holes.
+type Polygon struct {
+	Rings [][]Position
+	SRID  int
+}
+
+// MultiPoint is a set of positions.
+type MultiPoint struct {
+	Points []Position
+	SRID   int
+}
+
+// MultiLineString is a set of line strings.
+type MultiLineString struct {
+	LineStrings [][]Position
+	SRID        int
+}
+
+// MultiPolygon is a set of polygons, each given by its rings.
+type MultiPolygon struct {
+	Polygons [][][]Position
+	SRID     int
+}
+
+// ShapeCollection is a heterogeneous set of shapes. The SRID of its members is
+// that of the collection.
+type ShapeCollection struct {
+	Shapes []Shape
+	SRID   int
+}
+
+func (Point) Kind() Kind           { return KindPoint }
+func (LineString) Kind() Kind      { return KindLineString }
+func (Polygon) Kind() Kind         { return KindPolygon }
+func (MultiPoint) Kind() Kind      { return KindMultiPoint }
+func (MultiLineString) Kind() Kind { return KindMultiLineString }
+func (MultiPolygon) Kind() Kind    { return KindMultiPolygon }
+func (ShapeCollection) Kind() Kind { return KindCollection }
+
+func (p Point) node() node {
+	return node{kind: KindPoint, srid: p.SRID, points: []Position{{X: p.X, Y: p.Y}}}
+}
+
+func (l LineString) node() node {
+	return node{kind: KindLineString, srid: l.SRID, points: l.Points}
+}
+
+func (p Polygon) node() node {
+	return node{kind: KindPolygon, srid: p.SRID, lines: p.Rings}
+}
+
+func (m MultiPoint) node() node {
+	return node{kind: KindMultiPoint, srid: m.SRID, points: m.Points}
+}
+
+func (m MultiLineString) node() node {
+	return node{kind: KindMultiLineString, srid: m.SRID, lines: m.LineStrings}
+}
+
+func (m MultiPolygon) node() node {
+	return node{kind: KindMultiPolygon, srid: m.SRID, polygons: m.Polygons}
+}
+
+func (c ShapeCollection) node() node {
+	members := make([]node, 0, len(c.Shapes))
+	for _, shape := range c.Shapes {
+		if shape != nil {
+			members = append(members, shape.node())
+		}
+	}
+	return node{kind: KindCollection, srid: c.SRID, members: members}
+}
+
+func (p *Point) assign(n node) {
+	*p = Point{SRID: n.srid}
+	if len(n.points) > 0 {
+		p.X, p.Y = n.points[0].X, n.points[0].Y
+	}
+}
+
+func (l *LineString) assign(n node)      { *l = LineString{Points: n.points, SRID: n.srid} }
+func (p *Polygon) assign(n node)         { *p = Polygon{Rings: n.lines, SRID: n.srid} }
+func (m *MultiPoint) assign(n node)      { *m = MultiPoint{Points: n.points, SRID: n.srid} }
+func (m *MultiLineString) assign(n node) { *m = MultiLineString{LineStrings: n.lines, SRID: n.srid} }
+func (m *MultiPolygon) assign(n node)    { *m = MultiPolygon{Polygons: n.polygons, SRID: n.srid} }
+
+func (c *ShapeCollection) assign(n node) {
+	*c = ShapeCollection{SRID: n.srid, Shapes: make([]Shape, 0, len(n.members))}
+	for _, member := range n.members {
+		c.Shapes = append(c.Shapes, shapeOf(member))
+	}
+}
+
+// shapeOf returns the shape a node describes.
+func shapeOf(n node) Shape {
+	var target decoder
+	switch n.kind {
+	case KindPoint:
+		target = &Point{}
+	case KindLineString:
+		target = &LineString{}
+	case KindPolygon:
+		target = &Polygon{}
+	case KindMultiPoint:
+		target = &MultiPoint{}
+	case KindMultiLineString:
+		target = &MultiLineString{}
+	case KindMultiPolygon:
+		target = &MultiPolygon{}
+	default:
+		target = &ShapeCollection{}
+	}
+	target.assign(n)
+	return reflect.ValueOf(target).Elem().Interface().(Shape)
+}
+
+// decodeInto assigns n to target after checking that the kinds match.
+func decodeInto(target decoder, n node) error {
+	if n.kind != target.Kind() {
+		return fmt.Errorf("expected a %s, got a %s", target.Kind(), n.kind)
+	}
+	target.assign(n)
+	return nil
+}
+
+// Typed is implemented by the family types, which carry an EDM type name.
+type Typed interface {
+	Shape
+	EdmType() string
+}
+
+var typedType = reflect.TypeOf((*Typed)(nil)).Elem()
+
+// EdmTypeOf returns the EDM type of a Go type that holds spatial values, such
+// as GeographyPoint or *GeometryPolygon.
+func EdmTypeOf(t reflect.Type) (string, bool) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || !t.Implements(typedType) {
+		return "", false
+	}
+	return reflect.Zero(t).Interface().(Typed).EdmType(), true
+}
+
+// Parse parses a spatial value from WKT, optionally prefixed with an SRID
+// ("SRID=4326;POINT(1 2)") or wrapped as an OData literal
+// ("geography'SRID=4326;Point(1 2)'").
+func Parse(text string) (Shape, error) {
+	n, err := parseWKT(text)
+	if err != nil {
+		return nil, err
+	}
+	return shapeOf(n), nil
+}
@@ -0,0 +1,226 @@
+package geo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeoJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		json  string
+	}{
+		{
+			name:  "geography point",
+			value: GeographyPoint{Point{X: -122.1, Y: 47.6}},
+			json:  `{"type":"Point","coordinates":[-122.1,47.6]}`,
+		},
+		{
+			name:  "geography point with SRID",
+			value: GeographyPoint{Point{X: 1, Y: 2, SRID: 4269}},
+			json:  `{"type":"Point","coordinates":[1,2],"crs":{"type":"name","properties":{"name":"EPSG:4269"}}}`,
+		},
+		{
+			name:  "geometry line string",
+			value: GeometryLineString{LineString{Points: []Position{{0, 0}, {1, 1}}}},
+			json:  `{"type":"LineString","coordinates":[[0,0],[1,1]]}`,
+		},
+		{
+			name:  "geometry polygon",
+			value: GeometryPolygon{Polygon{Rings: [][]Position{{{0, 0}, {4, 0}, {4, 4}, {0, 0}}}}},
+			json:  `{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,0]]]}`,
+		},
+		{
+			name:  "geography multi polygon",
+			value: GeographyMultiPolygon{MultiPolygon{Polygons: [][][]Position{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}}},
+			json:  `{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}`,
+		},
+		{
+			name: "geometry collection",
+			value: GeometryCollection{ShapeCollection{Shapes: []Shape{
+				Point{X: 1, Y: 2},
+				LineString{Points: []Position{{0, 0}, {1, 1}}},
+			}}},
+			json: `{"type":"GeometryCollection","geometries":[{"type":"Point","coordinates":[1,2]},{"type":"LineString","coordinates":[[0,0],[1,1]]}]}`,
+		},
+		{
+			name:  "empty geography collection",
+			value: GeographyCollection{ShapeCollection{Shapes: []Shape{}}},
+			json:  `{"type":"GeometryCollection","geometries":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.json {
+				t.Fatalf("Marshal = %s, want %s", data, tt.json)
+			}
+			decoded := reflect.New(reflect.TypeOf(tt.value))
+			if err := json.Unmarshal(data, decoded.Interface()); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			again, err := json.Marshal(decoded.Elem().Interface())
+			if err != nil {
+				t.Fatalf("Marshal decoded: %v", err)
+			}
+			if string(again) != tt.json {
+				t.Errorf("round trip = %s, want %s", again, tt.json)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAcceptsWKT(t *testing.T) {
+	var point GeographyPoint
+	if err := json.Unmarshal([]byte(`"SRID=4326;POINT(-122.1 47.6)"`), &point); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if point.X != -122.1 || point.Y != 47.6 || point.SRID != 4326 {
+		t.Errorf("point = %+v", point.Point)
+	}
+
+	var polygon GeometryPolygon
+	if err := json.Unmarshal([]byte(`"geometry'Polygon((0 0,4 0,4 4,0 0))'"`), &polygon); err != nil {
+		t.Fatalf("Unmarshal literal: %v", err)
+	}
+	if len(polygon.Rings) != 1 || len(polygon.Rings[0]) != 4 {
+		t.Errorf("polygon = %+v", polygon.Polygon)
+	}
+}
+
+func TestUnmarshalRejectsWrongKind(t *testing.T) {
+	var point GeographyPoint
+	err := json.Unmarshal([]byte(`{"type":"LineString","coordinates":[[0,0],[1,1]]}`), &point)
+	if err == nil || !strings.Contains(err.Error(), "expected a Point") {
+		t.Errorf("expected kind mismatch error, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(`{"type":"Point","coordinates":[1,2,3]}`), &point); err == nil {
+		t.Error("expected error for three-dimensional position")
+	}
+}
+
+func TestParseWKT(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"POINT(1 2)", "POINT(1 2)"},
+		{"point ( 1.5  -2 )", "POINT(1.5 -2)"},
+		{"SRID=3857;LINESTRING(0 0, 1 1, 2 0)", "SRID=3857;LINESTRING(0 0,1 1,2 0)"},
+		{"MULTIPOINT((1 2),(3 4))", "MULTIPOINT(1 2,3 4)"},
+		{"MULTIPOINT(1 2, 3 4)", "MULTIPOINT(1 2,3 4)"},
+		{"POLYGON((0 0,4 0,4 4,0 0),(1 1,2 1,2 2,1 1))", "POLYGON((0 0,4 0,4 4,0 0),(1 1,2 1,2 2,1 1))"},
+		{"MULTIPOLYGON(((0 0,1 0,1 1,0 0)))", "MULTIPOLYGON(((0 0,1 0,1 1,0 0)))"},
+		{"Collection(Point(1 2),LineString(0 0,1 1))", "GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1 1))"},
+		{"LINESTRING EMPTY", "LINESTRING EMPTY"},
+	}
+	for _, tt := range tests {
+		shape, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if got := formatWKT(shape.node(), Geometry); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "POINT", "POINT(1)", "POINT Z(1 2 3)", "POINT(1 2 3)", "CIRCLE(1 2)", "POINT(1 2) x", "SRID=x;POINT(1 2)"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestWKBRoundTrip(t *testing.T) {
+	values := []Typed{
+		GeographyPoint{Point{X: -122.1, Y: 47.6}},
+		GeometryLineString{LineString{Points: []Position{{0, 0}, {1, 1}}, SRID: 3857}},
+		GeometryPolygon{Polygon{Rings: [][]Position{{{0, 0}, {4, 0}, {4, 4}, {0, 0}}}}},
+		GeographyMultiPoint{MultiPoint{Points: []Position{{1, 2}, {3, 4}}}},
+		GeometryMultiLineString{MultiLineString{LineStrings: [][]Position{{{0, 0}, {1, 1}}, {{2, 2}, {3, 3}}}}},
+		GeographyMultiPolygon{MultiPolygon{Polygons: [][][]Position{{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}}},
+		GeometryCollection{ShapeCollection{Shapes: []Shape{Point{X: 1, Y: 2}, Polygon{Rings: [][]Position{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}}}}},
+	}
+	for _, value := range values {
+		t.Run(value.EdmType(), func(t *testing.T) {
+			encoded, err := value.(driver.Valuer).Value()
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+			data := encoded.([]byte)
+
+			for _, src := range []interface{}{data, hex.EncodeToString(data), value.(interface{ String() string }).String()} {
+				target := reflect.New(reflect.TypeOf(value))
+				if err := target.Interface().(sql.Scanner).Scan(src); err != nil {
+					t.Fatalf("Scan(%T): %v", src, err)
+				}
+				if got, want := target.Elem().Interface().(interface{ String() string }).String(), value.(interface{ String() string }).String(); got != want {
+					t.Errorf("Scan(%T) = %s, want %s", src, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWKBGeographyCarriesDefaultSRID(t *testing.T) {
+	value, _ := GeographyPoint{Point{X: 1, Y: 2}}.Value()
+	var point Point
+	if err := point.Scan(value); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if point.SRID != 4326 {
+		t.Errorf("SRID = %d, want 4326", point.SRID)
+	}
+}
+
+func TestDecodeBigEndianWKB(t *testing.T) {
+	data, _ := hex.DecodeString("00000000013ff00000000000004000000000000000")
+	var point GeometryPoint
+	if err := point.Scan(data); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if point.X != 1 || point.Y != 2 {
+		t.Errorf("point = %+v", point.Point)
+	}
+}
+
+func TestScanRejectsCorruptWKB(t *testing.T) {
+	var line LineString
+	if err := line.Scan([]byte{1, 2, 0, 0, 0, 0xff, 0xff, 0xff, 0x7f}); err == nil {
+		t.Error("expected error for truncated line string")
+	}
+	var point Point
+	if err := point.Scan([]byte{1, 2, 0, 0, 0}); err == nil {
+		t.Error("expected error for line string scanned into point")
+	}
+}
+
+func TestEdmTypes(t *testing.T) {
+	if got, ok := EdmTypeOf(reflect.TypeOf(&GeographyCollection{})); !ok || got != "Edm.GeographyCollection" {
+		t.Errorf("EdmTypeOf(*GeographyCollection) = %q, %v", got, ok)
+	}
+	if _, ok := EdmTypeOf(reflect.TypeOf(Point{})); ok {
+		t.Error("family-neutral shapes have no EDM type")
+	}
+	family, kind, ok := ParseEdmType("Edm.GeometryMultiLineString")
+	if !ok || family != Geometry || kind != KindMultiLineString {
+		t.Errorf("ParseEdmType = %v, %v, %v", family, kind, ok)
+	}
+	if _, _, ok := ParseEdmType("Edm.String"); ok {
+		t.Error("ParseEdmType(Edm.String) succeeded")
+	}
+	if got := EdmType(Geography, KindCollection); got != "Edm.GeographyCollection" {
+		t.Errorf("EdmType = %s", got)
+	}
+}
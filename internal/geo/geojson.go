@@ -0,0 +1,188 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// geoJSONObject is the GeoJSON form of a shape. The crs member is the
+// named-CRS form of the 2008 GeoJSON specification, which OData JSON uses to
+// carry an SRID.
+type geoJSONObject struct {
+	Type        string           `json:"type"`
+	Coordinates interface{}      `json:"coordinates,omitempty"`
+	Geometries  *[]geoJSONObject `json:"geometries,omitempty"`
+	CRS         *geoJSONCRS      `json:"crs,omitempty"`
+}
+
+type geoJSONCRS struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+}
+
+// geoJSONInput mirrors geoJSONObject for decoding, deferring the coordinates
+// until the type is known.
+type geoJSONInput struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates"`
+	Geometries  []json.RawMessage `json:"geometries"`
+	CRS         *geoJSONCRS       `json:"crs"`
+}
+
+// MarshalJSON encodes a position as a GeoJSON [x, y] array.
+func (p Position) MarshalJSON() ([]byte, error) {
+	b := make([]byte, 0, 32)
+	b = append(b, '[')
+	b = strconv.AppendFloat(b, p.X, 'g', -1, 64)
+	b = append(b, ',')
+	b = strconv.AppendFloat(b, p.Y, 'g', -1, 64)
+	return append(b, ']'), nil
+}
+
+// UnmarshalJSON decodes a GeoJSON [x, y] array.
+func (p *Position) UnmarshalJSON(data []byte) error {
+	var coords []float64
+	if err := json.Unmarshal(data, &coords); err != nil {
+		return fmt.Errorf("invalid GeoJSON position %s", data)
+	}
+	if len(coords) != 2 {
+		return fmt.Errorf("GeoJSON positions must have two coordinates, got %d", len(coords))
+	}
+	p.X, p.Y = coords[0], coords[1]
+	return nil
+}
+
+// encodeGeoJSON encodes n as GeoJSON. The SRID is emitted as a named CRS when
+// it differs from the family's default.
+func encodeGeoJSON(n node, family Family) ([]byte, error) {
+	object := geoJSONOf(n)
+	if srid := effectiveSRID(n.srid, family); srid != family.DefaultSRID() {
+		object.CRS = &geoJSONCRS{Type: "name"}
+		object.CRS.Properties.Name = "EPSG:" + strconv.Itoa(srid)
+	}
+	return json.Marshal(object)
+}
+
+func geoJSONOf(n node) geoJSONObject {
+	object := geoJSONObject{Type: n.kind.String()}
+	switch n.kind {
+	case KindPoint:
+		if len(n.points) > 0 {
+			object.Coordinates = n.points[0]
+		}
+	case KindLineString, KindMultiPoint:
+		object.Coordinates = nonNil(n.points)
+	case KindPolygon, KindMultiLineString:
+		object.Coordinates = nonNil(n.lines)
+	case KindMultiPolygon:
+		object.Coordinates = nonNil(n.polygons)
+	case KindCollection:
+		members := make([]geoJSONObject, 0, len(n.members))
+		for _, member := range n.members {
+			members = append(members, geoJSONOf(member))
+		}
+		object.Geometries = &members
+	}
+	return object
+}
+
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// decodeGeoJSON decodes a GeoJSON object. A JSON string is parsed as WKT, so
+// clients may send either form.
+func decodeGeoJSON(data []byte) (node, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return node{}, err
+		}
+		return parseWKT(text)
+	}
+
+	var input geoJSONInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return node{}, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	n, err := decodeGeoJSONObject(input)
+	if err != nil {
+		return node{}, err
+	}
+	if input.CRS != nil {
+		if n.srid, err = parseCRSName(input.CRS.Properties.Name); err != nil {
+			return node{}, err
+		}
+	}
+	return n, nil
+}
+
+func decodeGeoJSONObject(input geoJSONInput) (node, error) {
+	n := node{}
+	for kind := KindPoint; kind <= KindCollection; kind++ {
+		if kind.String() == input.Type {
+			n.kind = kind
+		}
+	}
+	if n.kind == 0 {
+		return node{}, fmt.Errorf("unsupported GeoJSON type %q", input.Type)
+	}
+
+	if n.kind == KindCollection {
+		for _, raw := range input.Geometries {
+			var member geoJSONInput
+			if err := json.Unmarshal(raw, &member); err != nil {
+				return node{}, fmt.Errorf("invalid GeoJSON: %w", err)
+			}
+			decoded, err := decodeGeoJSONObject(member)
+			if err != nil {
+				return node{}, err
+			}
+			n.members = append(n.members, decoded)
+		}
+		return n, nil
+	}
+
+	if len(input.Coordinates) == 0 {
+		return node{}, fmt.Errorf("GeoJSON %s has no coordinates", input.Type)
+	}
+	var err error
+	switch n.kind {
+	case KindPoint:
+		var pos Position
+		err = json.Unmarshal(input.Coordinates, &pos)
+		n.points = []Position{pos}
+	case KindLineString, KindMultiPoint:
+		err = json.Unmarshal(input.Coordinates, &n.points)
+	case KindPolygon, KindMultiLineString:
+		err = json.Unmarshal(input.Coordinates, &n.lines)
+	case KindMultiPolygon:
+		err = json.Unmarshal(input.Coordinates, &n.polygons)
+	}
+	if err != nil {
+		return node{}, fmt.Errorf("invalid GeoJSON %s coordinates: %w", input.Type, err)
+	}
+	return n, nil
+}
+
+// parseCRSName extracts the SRID from a CRS name such as "EPSG:4326" or
+// "urn:ogc:def:crs:EPSG::4326".
+func parseCRSName(name string) (int, error) {
+	if !strings.Contains(strings.ToUpper(name), "EPSG:") {
+		return 0, fmt.Errorf("unsupported GeoJSON CRS %q", name)
+	}
+	srid, err := strconv.Atoi(name[strings.LastIndex(name, ":")+1:])
+	if err != nil || srid < 0 {
+		return 0, fmt.Errorf("unsupported GeoJSON CRS %q", name)
+	}
+	return srid, nil
+}
@@ -0,0 +1,402 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// WGS 84 ellipsoid parameters used for geodesic measurements of values in
+// SRID 4326.
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+
+	// meanEarthRadius is the IUGG mean radius used by the haversine
+	// fallback for nearly antipodal points, where Vincenty does not converge.
+	meanEarthRadius = 6371008.8
+
+	// geodeticSRID identifies the geographic reference system measured on the
+	// ellipsoid; all other SRIDs are measured in the plane.
+	geodeticSRID = 4326
+)
+
+// Distance returns the distance between two points. Points in SRID 4326 are
+// measured along the WGS 84 ellipsoid in meters; other points are measured in
+// the plane in coordinate units. The SRID of a decides, or that of b when a
+// has none.
+func Distance(a, b Shape) (float64, error) {
+	na, nb := a.node(), b.node()
+	if na.kind != KindPoint || nb.kind != KindPoint || len(na.points) == 0 || len(nb.points) == 0 {
+		return 0, fmt.Errorf("distance is only defined between points, got %s and %s", na.kind, nb.kind)
+	}
+	p, q := na.points[0], nb.points[0]
+	if sridOf(na, nb) == geodeticSRID {
+		return geodesicDistance(p, q), nil
+	}
+	return math.Hypot(q.X-p.X, q.Y-p.Y), nil
+}
+
+// Length returns the length of a line string or multi line string, measured
+// like Distance. Other shapes have no length and yield 0.
+func Length(s Shape) float64 {
+	n := s.node()
+	geodesic := n.srid == geodeticSRID
+	var lines [][]Position
+	switch n.kind {
+	case KindLineString:
+		lines = [][]Position{n.points}
+	case KindMultiLineString:
+		lines = n.lines
+	}
+	total := 0.0
+	for _, line := range lines {
+		for i := 1; i < len(line); i++ {
+			if geodesic {
+				total += geodesicDistance(line[i-1], line[i])
+			} else {
+				total += math.Hypot(line[i].X-line[i-1].X, line[i].Y-line[i-1].Y)
+			}
+		}
+	}
+	return total
+}
+
+func sridOf(a, b node) int {
+	if a.srid != 0 {
+		return a.srid
+	}
+	return b.srid
+}
+
+func geodesicDistance(p, q Position) float64 {
+	if d, ok := vincenty(p, q); ok {
+		return d
+	}
+	return haversine(p, q)
+}
+
+// vincenty solves the inverse geodesic problem on the WGS 84 ellipsoid. It
+// reports false when the iteration does not converge.
+func vincenty(p, q Position) (float64, bool) {
+	const f = wgs84F
+	l := radians(q.X - p.X)
+	u1 := math.Atan((1 - f) * math.Tan(radians(p.Y)))
+	u2 := math.Atan((1 - f) * math.Tan(radians(q.Y)))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma := math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, true
+		}
+		cosSigma := sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma := math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha := 1 - sinAlpha*sinAlpha
+		cos2SigmaM := 0.0
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+		previous := lambda
+		lambda = l + (1-c)*f*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) > 1e-12 {
+			continue
+		}
+
+		uSq := cos2Alpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+		a := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+		b := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+		deltaSigma := b * sinSigma * (cos2SigmaM + b/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			b/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		return wgs84B * a * (sigma - deltaSigma), true
+	}
+	return 0, false
+}
+
+// haversine returns the great-circle distance on a sphere of the mean earth
+// radius.
+func haversine(p, q Position) float64 {
+	lat1, lat2 := radians(p.Y), radians(q.Y)
+	dLat, dLon := lat2-lat1, radians(q.X-p.X)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * meanEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Intersects reports whether two shapes share at least one point. The test is
+// planar in both families; for geography values edges are straight lines in
+// longitude and latitude.
+func Intersects(a, b Shape) bool {
+	for _, pa := range primitives(a.node()) {
+		for _, pb := range primitives(b.node()) {
+			if intersects(pa, pb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Contains reports whether b lies inside a: every part of b is contained by a
+// part of a, with at least one point in a's interior. Like Intersects, the
+// test is planar.
+func Contains(a, b Shape) bool {
+	parts := primitives(b.node())
+	if len(parts) == 0 {
+		return false
+	}
+	containers := primitives(a.node())
+	for _, part := range parts {
+		contained := false
+		for _, container := range containers {
+			if contains(container, part) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			return false
+		}
+	}
+	return true
+}
+
+// Within reports whether a lies inside b.
+func Within(a, b Shape) bool {
+	return Contains(b, a)
+}
+
+// primitives flattens a shape into points, line strings and polygons.
+func primitives(n node) []node {
+	switch n.kind {
+	case KindPoint, KindLineString, KindPolygon:
+		return []node{n}
+	case KindMultiPoint:
+		parts := make([]node, 0, len(n.points))
+		for _, p := range n.points {
+			parts = append(parts, node{kind: KindPoint, points: []Position{p}})
+		}
+		return parts
+	case KindMultiLineString:
+		parts := make([]node, 0, len(n.lines))
+		for _, line := range n.lines {
+			parts = append(parts, node{kind: KindLineString, points: line})
+		}
+		return parts
+	case KindMultiPolygon:
+		parts := make([]node, 0, len(n.polygons))
+		for _, rings := range n.polygons {
+			parts = append(parts, node{kind: KindPolygon, lines: rings})
+		}
+		return parts
+	default:
+		var parts []node
+		for _, member := range n.members {
+			parts = append(parts, primitives(member)...)
+		}
+		return parts
+	}
+}
+
+func intersects(a, b node) bool {
+	if a.kind > b.kind {
+		a, b = b, a
+	}
+	switch {
+	case a.kind == KindPoint && b.kind == KindPoint:
+		return len(a.points) > 0 && len(b.points) > 0 && a.points[0] == b.points[0]
+	case a.kind == KindPoint && b.kind == KindLineString:
+		return len(a.points) > 0 && onLine(a.points[0], b.points)
+	case a.kind == KindPoint && b.kind == KindPolygon:
+		return len(a.points) > 0 && locate(a.points[0], b.lines) >= 0
+	case a.kind == KindLineString && b.kind == KindLineString:
+		return linesIntersect(a.points, b.points)
+	case a.kind == KindLineString && b.kind == KindPolygon:
+		for _, p := range a.points {
+			if locate(p, b.lines) >= 0 {
+				return true
+			}
+		}
+		for _, ring := range b.lines {
+			if linesIntersect(a.points, ring) {
+				return true
+			}
+		}
+		return false
+	default:
+		// Two polygons intersect when the exterior of either meets the other.
+		return intersects(node{kind: KindLineString, points: exterior(a)}, b) ||
+			intersects(node{kind: KindLineString, points: exterior(b)}, a)
+	}
+}
+
+func contains(a, b node) bool {
+	switch {
+	case a.kind == KindPoint && b.kind == KindPoint:
+		return intersects(a, b)
+	case a.kind == KindLineString && b.kind == KindPoint:
+		return len(b.points) > 0 && onLine(b.points[0], a.points)
+	case a.kind == KindLineString && b.kind == KindLineString:
+		for i, p := range b.points {
+			if !onLine(p, a.points) || (i > 0 && !onLine(midpoint(b.points[i-1], p), a.points)) {
+				return false
+			}
+		}
+		return len(b.points) > 0
+	case a.kind == KindPolygon && b.kind == KindPoint:
+		return len(b.points) > 0 && locate(b.points[0], a.lines) > 0
+	case a.kind == KindPolygon && b.kind == KindLineString:
+		return polygonCovers(a.lines, b.points)
+	case a.kind == KindPolygon && b.kind == KindPolygon:
+		return polygonCovers(a.lines, exterior(b))
+	default:
+		return false
+	}
+}
+
+// polygonCovers reports whether a path lies inside a polygon, touching its
+// boundary at most, with at least one point in its interior.
+func polygonCovers(rings [][]Position, path []Position) bool {
+	interior := false
+	for i, p := range path {
+		candidates := []Position{p}
+		if i > 0 {
+			candidates = append(candidates, midpoint(path[i-1], p))
+			for _, ring := range rings {
+				for j := 1; j < len(ring); j++ {
+					if segmentsCross(path[i-1], p, ring[j-1], ring[j]) {
+						return false
+					}
+				}
+			}
+		}
+		for _, c := range candidates {
+			switch locate(c, rings) {
+			case -1:
+				return false
+			case 1:
+				interior = true
+			}
+		}
+	}
+	return interior
+}
+
+func exterior(polygon node) []Position {
+	if len(polygon.lines) == 0 {
+		return nil
+	}
+	return polygon.lines[0]
+}
+
+func midpoint(p, q Position) Position {
+	return Position{X: (p.X + q.X) / 2, Y: (p.Y + q.Y) / 2}
+}
+
+// locate returns 1 when p lies inside the polygon, 0 when it lies on its
+// boundary and -1 when it lies outside.
+func locate(p Position, rings [][]Position) int {
+	if len(rings) == 0 {
+		return -1
+	}
+	for _, ring := range rings {
+		if onLine(p, ring) {
+			return 0
+		}
+	}
+	if !insideRing(p, rings[0]) {
+		return -1
+	}
+	for _, hole := range rings[1:] {
+		if insideRing(p, hole) {
+			return -1
+		}
+	}
+	return 1
+}
+
+// insideRing is the even-odd ray casting test.
+func insideRing(p Position, ring []Position) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func onLine(p Position, line []Position) bool {
+	if len(line) == 1 {
+		return line[0] == p
+	}
+	for i := 1; i < len(line); i++ {
+		if onSegment(p, line[i-1], line[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func linesIntersect(a, b []Position) bool {
+	if len(a) == 1 {
+		return onLine(a[0], b)
+	}
+	if len(b) == 1 {
+		return onLine(b[0], a)
+	}
+	for i := 1; i < len(a); i++ {
+		for j := 1; j < len(b); j++ {
+			if segmentsIntersect(a[i-1], a[i], b[j-1], b[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// orientation returns the sign of the cross product (b-a)×(c-a).
+func orientation(a, b, c Position) int {
+	cross := (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+	const epsilon = 1e-12
+	switch {
+	case cross > epsilon:
+		return 1
+	case cross < -epsilon:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func onSegment(p, a, b Position) bool {
+	return orientation(a, b, p) == 0 &&
+		p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X) &&
+		p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y)
+}
+
+func segmentsIntersect(p1, p2, q1, q2 Position) bool {
+	o1, o2 := orientation(p1, p2, q1), orientation(p1, p2, q2)
+	o3, o4 := orientation(q1, q2, p1), orientation(q1, q2, p2)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+	return onSegment(q1, p1, p2) || onSegment(q2, p1, p2) || onSegment(p1, q1, q2) || onSegment(p2, q1, q2)
+}
+
+// segmentsCross reports whether two segments cross at a single point interior
+// to both.
+func segmentsCross(p1, p2, q1, q2 Position) bool {
+	o1, o2 := orientation(p1, p2, q1), orientation(p1, p2, q2)
+	o3, o4 := orientation(q1, q2, p1), orientation(q1, q2, p2)
+	return o1*o2 < 0 && o3*o4 < 0
+}
@@ -0,0 +1,145 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func mustParse(t *testing.T, text string) Shape {
+	t.Helper()
+	shape, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", text, err)
+	}
+	return shape
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		want      float64
+		tolerance float64
+	}{
+		{"one degree along the equator", "SRID=4326;POINT(0 0)", "SRID=4326;POINT(1 0)", 111319.491, 0.01},
+		{"Berlin to Paris", "SRID=4326;POINT(13.405 52.52)", "SRID=4326;POINT(2.3522 48.8566)", 878000, 2000},
+		{"coincident points", "SRID=4326;POINT(10 10)", "SRID=4326;POINT(10 10)", 0, 0},
+		{"nearly antipodal points", "SRID=4326;POINT(0 0)", "SRID=4326;POINT(179.7 0.5)", 19970000, 50000},
+		{"planar", "POINT(0 0)", "POINT(3 4)", 5, 0},
+		{"SRID taken from the second point", "POINT(0 0)", "SRID=4326;POINT(0 1)", 110574, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Distance(mustParse(t, tt.a), mustParse(t, tt.b))
+			if err != nil {
+				t.Fatalf("Distance: %v", err)
+			}
+			if math.Abs(got-tt.want) > tt.tolerance {
+				t.Errorf("Distance = %f, want %f ± %f", got, tt.want, tt.tolerance)
+			}
+		})
+	}
+
+	if _, err := Distance(mustParse(t, "POINT(0 0)"), mustParse(t, "LINESTRING(0 0,1 1)")); err == nil {
+		t.Error("expected error for distance to a line string")
+	}
+}
+
+func TestLength(t *testing.T) {
+	if got := Length(mustParse(t, "LINESTRING(0 0,3 4,3 10)")); got != 11 {
+		t.Errorf("planar Length = %f, want 11", got)
+	}
+	got := Length(mustParse(t, "SRID=4326;LINESTRING(0 0,1 0,2 0)"))
+	if math.Abs(got-2*111319.491) > 0.1 {
+		t.Errorf("geodesic Length = %f, want %f", got, 2*111319.491)
+	}
+	if got := Length(mustParse(t, "MULTILINESTRING((0 0,1 0),(0 0,0 2))")); got != 3 {
+		t.Errorf("MultiLineString Length = %f, want 3", got)
+	}
+	if got := Length(mustParse(t, "POINT(1 1)")); got != 0 {
+		t.Errorf("Point Length = %f, want 0", got)
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	square := "POLYGON((0 0,10 0,10 10,0 10,0 0),(4 4,6 4,6 6,4 6,4 4))"
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"POINT(1 1)", square, true},
+		{"POINT(10 5)", square, true},
+		{"POINT(5 5)", square, false},
+		{"POINT(11 5)", square, false},
+		{"LINESTRING(-5 5,15 5)", square, true},
+		{"LINESTRING(-5 -5,-1 20)", square, false},
+		{"LINESTRING(0 0,2 2)", "LINESTRING(0 2,2 0)", true},
+		{"LINESTRING(0 0,1 0)", "LINESTRING(0 1,1 1)", false},
+		{"POLYGON((1 1,2 1,2 2,1 1))", square, true},
+		{"POLYGON((-5 -5,20 -5,20 20,-5 -5))", square, true},
+		{"POLYGON((20 20,30 20,30 30,20 20))", square, false},
+		{"MULTIPOINT(50 50,1 1)", square, true},
+		{"GEOMETRYCOLLECTION(POINT(50 50),LINESTRING(60 60,70 70))", square, false},
+	}
+	for _, tt := range tests {
+		if got := Intersects(mustParse(t, tt.a), mustParse(t, tt.b)); got != tt.want {
+			t.Errorf("Intersects(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Intersects(mustParse(t, tt.b), mustParse(t, tt.a)); got != tt.want {
+			t.Errorf("Intersects(%s, %s) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestContainsAndWithin(t *testing.T) {
+	square := "POLYGON((0 0,10 0,10 10,0 10,0 0),(4 4,6 4,6 6,4 6,4 4))"
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{square, "POINT(1 1)", true},
+		{square, "POINT(10 5)", false},
+		{square, "POINT(5 5)", false},
+		{square, "LINESTRING(1 1,3 1)", true},
+		{square, "LINESTRING(1 1,5 5)", false},
+		{square, "LINESTRING(0 0,10 0)", false},
+		{square, "POLYGON((1 1,3 1,3 3,1 1))", true},
+		{square, "POLYGON((1 1,12 1,3 3,1 1))", false},
+		{square, "MULTIPOINT(1 1,2 2)", true},
+		{square, "MULTIPOINT(1 1,20 20)", false},
+		{"LINESTRING(0 0,10 0)", "POINT(5 0)", true},
+		{"LINESTRING(0 0,10 0)", "LINESTRING(2 0,4 0)", true},
+		{"POINT(1 1)", "POINT(1 1)", true},
+		{"POINT(1 1)", square, false},
+	}
+	for _, tt := range tests {
+		if got := Contains(mustParse(t, tt.a), mustParse(t, tt.b)); got != tt.want {
+			t.Errorf("Contains(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := Within(mustParse(t, tt.b), mustParse(t, tt.a)); got != tt.want {
+			t.Errorf("Within(%s, %s) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	shape, err := Decode("POINT(1 2)", 4326)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if p := shape.(Point); p.SRID != 4326 {
+		t.Errorf("SRID = %d, want the default 4326", p.SRID)
+	}
+
+	shape, err = Decode(EncodeEWKB(Point{X: 1, Y: 2}), 4326)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if p := shape.(Point); p.SRID != 0 {
+		t.Errorf("SRID = %d, binary values keep their own SRID", p.SRID)
+	}
+
+	if _, err := Decode(42, 0); err == nil {
+		t.Error("expected error for numeric value")
+	}
+}
@@ -0,0 +1,147 @@
+package geo
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+)
+
+// unmarshalShape decodes GeoJSON, or WKT in a JSON string, into target.
+func unmarshalShape(target decoder, data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	n, err := decodeGeoJSON(data)
+	if err != nil {
+		return err
+	}
+	return decodeInto(target, n)
+}
+
+// scanShape decodes a database value into target. Binary values are WKB or
+// EWKB; text values are hex-encoded EWKB or WKT. NULL resets target.
+func scanShape(target decoder, src interface{}) error {
+	var (
+		n   node
+		err error
+	)
+	switch v := src.(type) {
+	case nil:
+		target.assign(node{kind: target.Kind()})
+		return nil
+	case []byte:
+		if len(v) > 0 && (v[0] == 0 || v[0] == 1) {
+			n, err = decodeWKB(v)
+		} else {
+			n, err = parseText(string(v))
+		}
+	case string:
+		n, err = parseText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a %s", src, target.Kind())
+	}
+	if err != nil {
+		return err
+	}
+	return decodeInto(target, n)
+}
+
+// Decode decodes a value as stored in or computed by a database: WKB or EWKB
+// bytes, or text holding hex-encoded EWKB or WKT. WKT that names no SRID is
+// given defaultSRID.
+func Decode(src interface{}, defaultSRID int) (Shape, error) {
+	var (
+		n   node
+		err error
+	)
+	switch v := src.(type) {
+	case []byte:
+		if len(v) > 0 && (v[0] == 0 || v[0] == 1) {
+			n, err = decodeWKB(v)
+			break
+		}
+		return Decode(string(v), defaultSRID)
+	case string:
+		n, err = parseText(v)
+		if err == nil && n.srid == 0 && !isHexWKB(v) {
+			n.srid = defaultSRID
+		}
+	default:
+		return nil, fmt.Errorf("cannot decode %T as a spatial value", src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return shapeOf(n), nil
+}
+
+// EncodeEWKB encodes a shape as little-endian EWKB with the shape's own SRID.
+func EncodeEWKB(s Shape) []byte {
+	return encodeEWKB(s.node(), Geometry)
+}
+
+func parseText(text string) (node, error) {
+	if isHexWKB(text) {
+		data, err := hex.DecodeString(text)
+		if err != nil {
+			return node{}, err
+		}
+		return decodeWKB(data)
+	}
+	return parseWKT(text)
+}
+
+// The family-neutral shapes encode without a default SRID, like geometry
+// values. Their pointers decode GeoJSON or WKT and scan WKB, EWKB or WKT.
+
+func (p Point) MarshalJSON() ([]byte, error)           { return encodeGeoJSON(p.node(), Geometry) }
+func (l LineString) MarshalJSON() ([]byte, error)      { return encodeGeoJSON(l.node(), Geometry) }
+func (p Polygon) MarshalJSON() ([]byte, error)         { return encodeGeoJSON(p.node(), Geometry) }
+func (m MultiPoint) MarshalJSON() ([]byte, error)      { return encodeGeoJSON(m.node(), Geometry) }
+func (m MultiLineString) MarshalJSON() ([]byte, error) { return encodeGeoJSON(m.node(), Geometry) }
+func (m MultiPolygon) MarshalJSON() ([]byte, error)    { return encodeGeoJSON(m.node(), Geometry) }
+func (c ShapeCollection) MarshalJSON() ([]byte, error) { return encodeGeoJSON(c.node(), Geometry) }
+
+func (p *Point) UnmarshalJSON(data []byte) error           { return unmarshalShape(p, data) }
+func (l *LineString) UnmarshalJSON(data []byte) error      { return unmarshalShape(l, data) }
+func (p *Polygon) UnmarshalJSON(data []byte) error         { return unmarshalShape(p, data) }
+func (m *MultiPoint) UnmarshalJSON(data []byte) error      { return unmarshalShape(m, data) }
+func (m *MultiLineString) UnmarshalJSON(data []byte) error { return unmarshalShape(m, data) }
+func (m *MultiPolygon) UnmarshalJSON(data []byte) error    { return unmarshalShape(m, data) }
+func (c *ShapeCollection) UnmarshalJSON(data []byte) error { return unmarshalShape(c, data) }
+
+func (p *Point) Scan(src interface{}) error           { return scanShape(p, src) }
+func (l *LineString) Scan(src interface{}) error      { return scanShape(l, src) }
+func (p *Polygon) Scan(src interface{}) error         { return scanShape(p, src) }
+func (m *MultiPoint) Scan(src interface{}) error      { return scanShape(m, src) }
+func (m *MultiLineString) Scan(src interface{}) error { return scanShape(m, src) }
+func (m *MultiPolygon) Scan(src interface{}) error    { return scanShape(m, src) }
+func (c *ShapeCollection) Scan(src interface{}) error { return scanShape(c, src) }
+
+func (p Point) Value() (driver.Value, error)           { return encodeEWKB(p.node(), Geometry), nil }
+func (l LineString) Value() (driver.Value, error)      { return encodeEWKB(l.node(), Geometry), nil }
+func (p Polygon) Value() (driver.Value, error)         { return encodeEWKB(p.node(), Geometry), nil }
+func (m MultiPoint) Value() (driver.Value, error)      { return encodeEWKB(m.node(), Geometry), nil }
+func (m MultiLineString) Value() (driver.Value, error) { return encodeEWKB(m.node(), Geometry), nil }
+func (m MultiPolygon) Value() (driver.Value, error)    { return encodeEWKB(m.node(), Geometry), nil }
+func (c ShapeCollection) Value() (driver.Value, error) { return encodeEWKB(c.node(), Geometry), nil }
+
+func (p Point) String() string           { return formatWKT(p.node(), Geometry) }
+func (l LineString) String() string      { return formatWKT(l.node(), Geometry) }
+func (p Polygon) String() string         { return formatWKT(p.node(), Geometry) }
+func (m MultiPoint) String() string      { return formatWKT(m.node(), Geometry) }
+func (m MultiLineString) String() string { return formatWKT(m.node(), Geometry) }
+func (m MultiPolygon) String() string    { return formatWKT(m.node(), Geometry) }
+func (c ShapeCollection) String() string { return formatWKT(c.node(), Geometry) }
+
+// GormDataType makes GORM migrate spatial fields to binary columns. Fields
+// can name a spatial column type instead, e.g. gorm:"type:geography(Point,4326)"
+// on PostGIS.
+func (Point) GormDataType() string           { return "bytes" }
+func (LineString) GormDataType() string      { return "bytes" }
+func (Polygon) GormDataType() string         { return "bytes" }
+func (MultiPoint) GormDataType() string      { return "bytes" }
+func (MultiLineString) GormDataType() string { return "bytes" }
+func (MultiPolygon) GormDataType() string    { return "bytes" }
+func (ShapeCollection) GormDataType() string { return "bytes" }
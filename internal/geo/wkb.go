@@ -0,0 +1,263 @@
+package geo
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+const (
+	// ewkbSRIDFlag marks EWKB geometries whose type code is followed by an
+	// SRID, as written by PostGIS.
+	ewkbSRIDFlag = 0x20000000
+	ewkbZFlag    = 0x80000000
+	ewkbMFlag    = 0x40000000
+)
+
+// encodeEWKB encodes n as little-endian EWKB. The SRID is included unless it
+// is 0 after applying the family's default.
+func encodeEWKB(n node, family Family) []byte {
+	return appendWKB(nil, n, effectiveSRID(n.srid, family))
+}
+
+func appendWKB(b []byte, n node, srid int) []byte {
+	code := uint32(n.kind)
+	if srid != 0 {
+		code |= ewkbSRIDFlag
+	}
+	b = append(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, code)
+	if srid != 0 {
+		b = binary.LittleEndian.AppendUint32(b, uint32(srid))
+	}
+
+	switch n.kind {
+	case KindPoint:
+		var pos Position
+		if len(n.points) > 0 {
+			pos = n.points[0]
+		}
+		b = appendPosition(b, pos)
+	case KindLineString:
+		b = appendPositions(b, n.points)
+	case KindPolygon:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(n.lines)))
+		for _, ring := range n.lines {
+			b = appendPositions(b, ring)
+		}
+	case KindMultiPoint:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(n.points)))
+		for _, pos := range n.points {
+			b = appendWKB(b, node{kind: KindPoint, points: []Position{pos}}, 0)
+		}
+	case KindMultiLineString:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(n.lines)))
+		for _, line := range n.lines {
+			b = appendWKB(b, node{kind: KindLineString, points: line}, 0)
+		}
+	case KindMultiPolygon:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(n.polygons)))
+		for _, polygon := range n.polygons {
+			b = appendWKB(b, node{kind: KindPolygon, lines: polygon}, 0)
+		}
+	case KindCollection:
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(n.members)))
+		for _, member := range n.members {
+			b = appendWKB(b, member, 0)
+		}
+	}
+	return b
+}
+
+func appendPosition(b []byte, pos Position) []byte {
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(pos.X))
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(pos.Y))
+}
+
+func appendPositions(b []byte, positions []Position) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(positions)))
+	for _, pos := range positions {
+		b = appendPosition(b, pos)
+	}
+	return b
+}
+
+// decodeWKB decodes WKB or EWKB in either byte order.
+func decodeWKB(data []byte) (node, error) {
+	r := &wkbReader{data: data}
+	n, err := r.shape()
+	if err != nil {
+		return node{}, err
+	}
+	if r.pos != len(r.data) {
+		return node{}, fmt.Errorf("unexpected trailing bytes in well-known binary")
+	}
+	return n, nil
+}
+
+// isHexWKB reports whether text is hex-encoded WKB, the form PostGIS returns
+// spatial columns in when results are transferred as text.
+func isHexWKB(text string) bool {
+	if len(text) < 10 || len(text)%2 != 0 || (text[:2] != "00" && text[:2] != "01") {
+		return false
+	}
+	_, err := hex.DecodeString(text)
+	return err == nil
+}
+
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+var errWKBTruncated = fmt.Errorf("truncated well-known binary")
+
+func (r *wkbReader) uint32() (uint32, error) {
+	if len(r.data)-r.pos < 4 {
+		return 0, errWKBTruncated
+	}
+	v := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// count reads a length prefix, bounding it by the bytes left so corrupt input
+// cannot cause huge allocations.
+func (r *wkbReader) count(minSize int) (int, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return 0, err
+	}
+	if int64(n)*int64(minSize) > int64(len(r.data)-r.pos) {
+		return 0, errWKBTruncated
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) position() (Position, error) {
+	if len(r.data)-r.pos < 16 {
+		return Position{}, errWKBTruncated
+	}
+	x := math.Float64frombits(r.order.Uint64(r.data[r.pos:]))
+	y := math.Float64frombits(r.order.Uint64(r.data[r.pos+8:]))
+	r.pos += 16
+	return Position{X: x, Y: y}, nil
+}
+
+func (r *wkbReader) positions() ([]Position, error) {
+	n, err := r.count(16)
+	if err != nil {
+		return nil, err
+	}
+	positions := make([]Position, 0, n)
+	for i := 0; i < n; i++ {
+		pos, err := r.position()
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
+
+func (r *wkbReader) shape() (node, error) {
+	if r.pos >= len(r.data) {
+		return node{}, errWKBTruncated
+	}
+	switch r.data[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return node{}, fmt.Errorf("invalid well-known binary byte order %d", r.data[r.pos])
+	}
+	r.pos++
+
+	code, err := r.uint32()
+	if err != nil {
+		return node{}, err
+	}
+	if code&(ewkbZFlag|ewkbMFlag) != 0 || code&0x0fffffff > 1000 {
+		return node{}, fmt.Errorf("only two-dimensional coordinates are supported")
+	}
+	n := node{kind: Kind(code & 0xff)}
+	if n.kind < KindPoint || n.kind > KindCollection || code&0x0fffff00 != 0 {
+		return node{}, fmt.Errorf("unsupported well-known binary type %d", code&0x0fffffff)
+	}
+	if code&ewkbSRIDFlag != 0 {
+		srid, err := r.uint32()
+		if err != nil {
+			return node{}, err
+		}
+		n.srid = int(srid)
+	}
+
+	switch n.kind {
+	case KindPoint:
+		pos, err := r.position()
+		if err != nil {
+			return node{}, err
+		}
+		if math.IsNaN(pos.X) && math.IsNaN(pos.Y) {
+			return node{}, fmt.Errorf("empty points are not supported")
+		}
+		n.points = []Position{pos}
+	case KindLineString:
+		if n.points, err = r.positions(); err != nil {
+			return node{}, err
+		}
+	case KindPolygon:
+		rings, err := r.count(4)
+		if err != nil {
+			return node{}, err
+		}
+		for i := 0; i < rings; i++ {
+			ring, err := r.positions()
+			if err != nil {
+				return node{}, err
+			}
+			n.lines = append(n.lines, ring)
+		}
+	default:
+		members, err := r.count(5)
+		if err != nil {
+			return node{}, err
+		}
+		for i := 0; i < members; i++ {
+			member, err := r.shape()
+			if err != nil {
+				return node{}, err
+			}
+			if err := n.add(member); err != nil {
+				return node{}, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// add appends a decoded member to a multi shape or collection.
+func (n *node) add(member node) error {
+	expected := map[Kind]Kind{
+		KindMultiPoint:      KindPoint,
+		KindMultiLineString: KindLineString,
+		KindMultiPolygon:    KindPolygon,
+	}[n.kind]
+	if expected != 0 && member.kind != expected {
+		return fmt.Errorf("a %s cannot contain a %s", n.kind, member.kind)
+	}
+	switch n.kind {
+	case KindMultiPoint:
+		n.points = append(n.points, member.points...)
+	case KindMultiLineString:
+		n.lines = append(n.lines, member.points)
+	case KindMultiPolygon:
+		n.polygons = append(n.polygons, member.lines)
+	default:
+		n.members = append(n.members, member)
+	}
+	return nil
+}
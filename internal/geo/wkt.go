@@ -0,0 +1,347 @@
+package geo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// parseWKT parses well-known text with an optional SRID prefix. The OData
+// literal forms geography'...' and geometry'...' are accepted as well, as are
+// the OData names Collection and the WKT name GeometryCollection.
+func parseWKT(text string) (node, error) {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+	for _, prefix := range []string{"geography'", "geometry'"} {
+		if strings.HasPrefix(lower, prefix) && strings.HasSuffix(text, "'") && len(text) > len(prefix) {
+			text = text[len(prefix) : len(text)-1]
+			break
+		}
+	}
+
+	srid := 0
+	if head, rest, ok := strings.Cut(text, ";"); ok && strings.HasPrefix(strings.ToUpper(strings.TrimSpace(head)), "SRID=") {
+		value, err := strconv.Atoi(strings.TrimSpace(head)[len("SRID="):])
+		if err != nil || value < 0 {
+			return node{}, fmt.Errorf("invalid SRID in %q", head)
+		}
+		srid, text = value, rest
+	}
+
+	p := &wktParser{input: text}
+	n, err := p.shape()
+	if err != nil {
+		return node{}, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.input) {
+		return node{}, fmt.Errorf("unexpected %q after well-known text", p.input[p.pos:])
+	}
+	n.srid = srid
+	return n, nil
+}
+
+type wktParser struct {
+	input string
+	pos   int
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *wktParser) peek(c byte) bool {
+	p.skipSpace()
+	return p.pos < len(p.input) && p.input[p.pos] == c
+}
+
+func (p *wktParser) expect(c byte) error {
+	if !p.peek(c) {
+		if p.pos >= len(p.input) {
+			return fmt.Errorf("expected %q at end of well-known text", c)
+		}
+		return fmt.Errorf("expected %q at %q", c, p.input[p.pos:])
+	}
+	p.pos++
+	return nil
+}
+
+// empty consumes the EMPTY keyword if it comes next.
+func (p *wktParser) empty() bool {
+	p.skipSpace()
+	if len(p.input)-p.pos >= 5 && strings.EqualFold(p.input[p.pos:p.pos+5], "EMPTY") {
+		p.pos += 5
+		return true
+	}
+	return false
+}
+
+func (p *wktParser) shape() (node, error) {
+	name := strings.ToUpper(p.word())
+	var kind Kind
+	switch name {
+	case "POINT":
+		kind = KindPoint
+	case "LINESTRING":
+		kind = KindLineString
+	case "POLYGON":
+		kind = KindPolygon
+	case "MULTIPOINT":
+		kind = KindMultiPoint
+	case "MULTILINESTRING":
+		kind = KindMultiLineString
+	case "MULTIPOLYGON":
+		kind = KindMultiPolygon
+	case "GEOMETRYCOLLECTION", "COLLECTION":
+		kind = KindCollection
+	case "":
+		return node{}, fmt.Errorf("expected a shape name in well-known text")
+	default:
+		return node{}, fmt.Errorf("unsupported shape %q in well-known text", name)
+	}
+	n := node{kind: kind}
+
+	if p.empty() {
+		if kind == KindPoint {
+			return node{}, fmt.Errorf("empty points are not supported")
+		}
+		return n, nil
+	}
+	if p.pos < len(p.input) && unicode.IsLetter(rune(p.input[p.pos])) {
+		return node{}, fmt.Errorf("%s: only two-dimensional coordinates are supported", kind)
+	}
+
+	var err error
+	switch kind {
+	case KindPoint:
+		var pos []Position
+		pos, err = p.positions()
+		if err == nil && len(pos) != 1 {
+			err = fmt.Errorf("a point has exactly one position")
+		}
+		n.points = pos
+	case KindLineString:
+		n.points, err = p.positions()
+	case KindMultiPoint:
+		n.points, err = p.multiPoint()
+	case KindPolygon, KindMultiLineString:
+		n.lines, err = p.rings()
+	case KindMultiPolygon:
+		n.polygons, err = p.polygons()
+	case KindCollection:
+		err = p.list(func() error {
+			member, err := p.shape()
+			n.members = append(n.members, member)
+			return err
+		})
+	}
+	return n, err
+}
+
+// list parses a parenthesized, comma separated list of items.
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		if !p.peek(',') {
+			break
+		}
+		p.pos++
+	}
+	return p.expect(')')
+}
+
+func (p *wktParser) position() (Position, error) {
+	x, err := p.number()
+	if err != nil {
+		return Position{}, err
+	}
+	y, err := p.number()
+	if err != nil {
+		return Position{}, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) && p.input[p.pos] != ',' && p.input[p.pos] != ')' {
+		return Position{}, fmt.Errorf("only two-dimensional coordinates are supported")
+	}
+	return Position{X: x, Y: y}, nil
+}
+
+func (p *wktParser) number() (float64, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("+-.0123456789eE", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		if start < len(p.input) {
+			return 0, fmt.Errorf("expected a coordinate at %q", p.input[start:])
+		}
+		return 0, fmt.Errorf("expected a coordinate at end of well-known text")
+	}
+	return value, nil
+}
+
+func (p *wktParser) positions() ([]Position, error) {
+	var positions []Position
+	err := p.list(func() error {
+		pos, err := p.position()
+		positions = append(positions, pos)
+		return err
+	})
+	return positions, err
+}
+
+// multiPoint accepts both MULTIPOINT(1 2, 3 4) and MULTIPOINT((1 2), (3 4)).
+func (p *wktParser) multiPoint() ([]Position, error) {
+	var positions []Position
+	err := p.list(func() error {
+		if p.peek('(') {
+			p.pos++
+			pos, err := p.position()
+			positions = append(positions, pos)
+			if err != nil {
+				return err
+			}
+			return p.expect(')')
+		}
+		pos, err := p.position()
+		positions = append(positions, pos)
+		return err
+	})
+	return positions, err
+}
+
+func (p *wktParser) rings() ([][]Position, error) {
+	var rings [][]Position
+	err := p.list(func() error {
+		ring, err := p.positions()
+		rings = append(rings, ring)
+		return err
+	})
+	return rings, err
+}
+
+func (p *wktParser) polygons() ([][][]Position, error) {
+	var polygons [][][]Position
+	err := p.list(func() error {
+		rings, err := p.rings()
+		polygons = append(polygons, rings)
+		return err
+	})
+	return polygons, err
+}
+
+// formatWKT formats n as well-known text, prefixed with its SRID unless the
+// SRID is 0.
+func formatWKT(n node, family Family) string {
+	var b strings.Builder
+	if srid := effectiveSRID(n.srid, family); srid != 0 {
+		fmt.Fprintf(&b, "SRID=%d;", srid)
+	}
+	writeWKT(&b, n)
+	return b.String()
+}
+
+func writeWKT(b *strings.Builder, n node) {
+	b.WriteString(strings.ToUpper(n.kind.String()))
+	switch n.kind {
+	case KindPoint:
+		b.WriteByte('(')
+		if len(n.points) > 0 {
+			writePosition(b, n.points[0])
+		}
+		b.WriteByte(')')
+	case KindLineString, KindMultiPoint:
+		if len(n.points) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		writePositions(b, n.points)
+	case KindPolygon, KindMultiLineString:
+		if len(n.lines) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		writeRings(b, n.lines)
+	case KindMultiPolygon:
+		if len(n.polygons) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteByte('(')
+		for i, polygon := range n.polygons {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeRings(b, polygon)
+		}
+		b.WriteByte(')')
+	case KindCollection:
+		if len(n.members) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteByte('(')
+		for i, member := range n.members {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeWKT(b, member)
+		}
+		b.WriteByte(')')
+	}
+}
+
+func writePosition(b *strings.Builder, pos Position) {
+	b.WriteString(strconv.FormatFloat(pos.X, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(pos.Y, 'f', -1, 64))
+}
+
+func writePositions(b *strings.Builder, positions []Position) {
+	b.WriteByte('(')
+	for i, pos := range positions {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writePosition(b, pos)
+	}
+	b.WriteByte(')')
+}
+
+func writeRings(b *strings.Builder, rings [][]Position) {
+	b.WriteByte('(')
+	for i, ring := range rings {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		writePositions(b, ring)
+	}
+	b.WriteByte(')')
+}
+
+// effectiveSRID returns srid, or the family's default when srid is 0.
+func effectiveSRID(srid int, family Family) int {
+	if srid == 0 {
+		return family.DefaultSRID()
+	}
+	return srid
+}
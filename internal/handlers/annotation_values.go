@@ -5,6 +5,10 @@ import (
 	"sort"
 )
 
+// annotationRecordTypeKey names the record member holding the qualified name of
+// the record's type, for terms whose type is abstract or has derived types.
+const annotationRecordTypeKey = "@type"
+
 func annotationCollectionValues(value interface{}) ([]interface{}, bool) {
 	if value == nil {
 		return nil, false
@@ -46,13 +46,7 @@ func authorizeRequest(w http.ResponseWriter, r *http.Request, policy auth.Policy
 		return true
 	}
 
-	statusCode := http.StatusForbidden
-	message := "Forbidden"
-	if r != nil && r.Header.Get("Authorization") == "" {
-		statusCode = http.StatusUnauthorized
-		message = "Unauthorized"
-	}
-
+	statusCode, message := authorizationDenial(r)
 	if err := response.WriteError(w, r, statusCode, message, decision.Reason); err != nil {
 		if logger == nil {
 			logger = slog.Default()
@@ -62,6 +56,15 @@ func authorizeRequest(w http.ResponseWriter, r *http.Request, policy auth.Policy
 	return false
 }
 
+// authorizationDenial returns the status and message of the response to a request the policy
+// refused: 401 when the request carries no credentials and 403 otherwise.
+func authorizationDenial(r *http.Request) (int, string) {
+	if r != nil && r.Header.Get("Authorization") == "" {
+		return http.StatusUnauthorized, "Unauthorized"
+	}
+	return http.StatusForbidden, "Forbidden"
+}
+
 func policyQueryFilter(r *http.Request, policy auth.Policy, resource auth.ResourceDescriptor, operation auth.Operation) (*query.FilterExpression, error) {
 	if policy == nil {
 		return nil, nil
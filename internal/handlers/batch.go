@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -20,6 +21,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nlstn/go-odata/internal/auth"
 	"github.com/nlstn/go-odata/internal/observability"
 	"github.com/nlstn/go-odata/internal/response"
 	"github.com/nlstn/go-odata/internal/storage"
@@ -32,15 +34,23 @@ import (
 // BatchHandler handles $batch requests for OData v4
 type BatchHandler struct {
 	db            *gorm.DB
-	store         storage.Store
+	store         storage.GORMStore
 	handlers      map[string]*EntityHandler
 	service       http.Handler
 	logger        *slog.Logger
 	observability *observability.Config
 	// preRequestHook is called before each sub-request is processed.
 	preRequestHook func(r *http.Request) (context.Context, error)
+	// policy supplies the row filters applied to changeset sub-requests.
+	policy auth.Policy
+	// virtualExpand serves the $expand of virtual entity sets in changeset sub-requests.
+	virtualExpand *VirtualExpand
 	// maxBatchSize limits the maximum number of sub-requests allowed in a batch
 	maxBatchSize int
+	// maxParallelism bounds how many independent GET sub-requests run concurrently.
+	maxParallelism int
+	// maxBodySize limits the size of a batch request body in bytes; 0 means unlimited.
+	maxBodySize int64
 }
 
 // NewBatchHandler creates a new batch handler
@@ -49,7 +59,7 @@ func NewBatchHandler(db *gorm.DB, handlers map[string]*EntityHandler, service ht
 }
 
 // NewBatchHandlerWithStore creates a new batch handler with a storage backend.
-func NewBatchHandlerWithStore(store storage.Store, handlers map[string]*EntityHandler, service http.Handler, maxBatchSize int) *BatchHandler {
+func NewBatchHandlerWithStore(store storage.GORMStore, handlers map[string]*EntityHandler, service http.Handler, maxBatchSize int) *BatchHandler {
 	if store == nil {
 		store = gormstore.New(nil)
 	}
@@ -82,6 +92,18 @@ func (h *BatchHandler) SetPreRequestHook(hook func(r *http.Request) (context.Con
 	h.preRequestHook = hook
 }
 
+// SetPolicy sets the authorization policy whose row filters restrict the reads
+// of changeset sub-requests.
+func (h *BatchHandler) SetPolicy(policy auth.Policy) {
+	h.policy = policy
+}
+
+// SetVirtualExpand sets the virtual entity sets whose GetCollection overwrites
+// serve the $expand of changeset sub-requests.
+func (h *BatchHandler) SetVirtualExpand(v *VirtualExpand) {
+	h.virtualExpand = v
+}
+
 // batchRequest represents a single request within a batch
 type batchRequest struct {
 	Method    string
@@ -199,21 +221,57 @@ func (h *BatchHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart request
-	reader := multipart.NewReader(r.Body, boundary)
+	// Validate the whole body before executing anything, so malformed and
+	// oversized batches are rejected without side effects.
+	spool, err := h.spoolBatchBody(r, func(body io.Reader) error {
+		return scanMultipartBatch(body, boundary, h.maxBatchSize)
+	})
+	if err != nil {
+		h.writeBatchBodyError(w, r, err, "Invalid batch request", "Failed to read batch part: %v")
+		return
+	}
+	defer func() {
+		if err := spool.Close(); err != nil {
+			h.logger.Error("Error removing batch spool", "error", err)
+		}
+	}()
+
+	// Parse sub-requests one at a time and stream each response once it is final.
+	reader := multipart.NewReader(spool.reader(), boundary)
+	out := h.newMultipartBatchWriter(w)
 	responses := []batchResponse{}
+	flushed := 0
+	reads := h.newBatchReadPool(r)
+	if reads != nil {
+		defer reads.settle()
+	}
+	flush := func() {
+		limit := len(responses)
+		if reads != nil {
+			if first, ok := reads.firstPending(); ok {
+				limit = first
+			}
+		}
+		for ; flushed < limit; flushed++ {
+			out.writePart(responses[flushed])
+			responses[flushed] = batchResponse{}
+		}
+	}
 
 	for {
+		flush()
+
 		part, err := reader.NextPart()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			if err := response.WriteError(w, r, http.StatusBadRequest, "Invalid batch request",
-				fmt.Sprintf("Failed to read batch part: %v", err)); err != nil {
-				h.logger.Error("Error writing error response", "error", err)
+			if reads != nil {
+				reads.settleInto(responses)
 			}
-			return
+			responses = append(responses, h.createErrorResponse(http.StatusBadRequest,
+				fmt.Sprintf("Failed to read batch part: %v", err)))
+			break
 		}
 
 		partContentType := part.Header.Get("Content-Type")
@@ -231,29 +289,24 @@ func (h *BatchHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
+			// Reads submitted earlier must not observe the changeset's writes.
+			if reads != nil {
+				reads.settleInto(responses)
+			}
+
 			// Process changeset (atomic operations)
 			// Pass remaining capacity to ensure changeset doesn't exceed batch limit
 			remainingCapacity := h.maxBatchSize - len(responses)
 			changesetResponses, exceeded := h.processChangeset(part, changesetBoundary, remainingCapacity, r)
-			// Check if batch size limit was exceeded
+			// The body was counted before processing, so this only guards against
+			// a changeset that parses differently the second time.
 			if exceeded {
-				if err := response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch size limit exceeded",
-					fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize)); err != nil {
-					h.logger.Error("Error writing error response", "error", err)
-				}
-				return
+				responses = append(responses, h.createErrorResponse(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize)))
+				break
 			}
 			responses = append(responses, changesetResponses...)
 		} else if partMediaType == "application/http" {
-			// Check batch size limit before processing single request
-			if len(responses)+1 > h.maxBatchSize {
-				if err := response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch size limit exceeded",
-					fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize)); err != nil {
-					h.logger.Error("Error writing error response", "error", err)
-				}
-				return
-			}
-
 			// Process single request
 			// Capture Content-ID from MIME part envelope headers (per OData v4 spec, must be echoed in response)
 			contentID := part.Header.Get("Content-ID")
@@ -267,24 +320,27 @@ func (h *BatchHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
 			}
 
 			req.ContentID = contentID
+			if reads != nil {
+				if isParallelBatchRead(req.Method, req.URL) {
+					responses = append(responses, batchResponse{ContentID: contentID})
+					reads.submit(len(responses)-1, "", req)
+					continue
+				}
+				reads.settleInto(responses)
+			}
 			resp := h.executeRequest(req, r)
 			resp.ContentID = req.ContentID // Echo Content-ID in response
 			responses = append(responses, resp)
 		} else {
-			// Check batch size limit before appending error response
-			if len(responses)+1 > h.maxBatchSize {
-				if err := response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch size limit exceeded",
-					fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize)); err != nil {
-					h.logger.Error("Error writing error response", "error", err)
-				}
-				return
-			}
 			responses = append(responses, h.createErrorResponse(http.StatusBadRequest, "Invalid part Content-Type"))
 		}
 	}
 
-	// Write batch response
-	h.writeBatchResponse(w, responses)
+	if reads != nil {
+		reads.settleInto(responses)
+	}
+	flush()
+	out.close()
 
 	// Update batch span with actual size and record batch metrics
 	if h.observability != nil {
@@ -300,11 +356,10 @@ func (h *BatchHandler) processChangeset(r io.Reader, boundary string, remainingC
 	responses := []batchResponse{}
 
 	// Start a transaction for the changeset
-	txAdapter, err := h.store.Begin(parentReq.Context())
+	tx, err := beginGORMTransaction(parentReq.Context(), h.store)
 	if err != nil {
 		return []batchResponse{h.createErrorResponse(http.StatusInternalServerError, "Failed to start transaction")}, false
 	}
-	tx := txAdapter.DB()
 
 	pendingEvents := make([]pendingChangeEvent, 0)
 
@@ -535,9 +590,12 @@ func (h *BatchHandler) executeRequestInTransaction(req *batchRequest, tx *gorm.D
 		txHandler.SetNamespace(handler.namespace)
 		txHandler.SetDeltaTracker(handler.tracker)
 		txHandler.SetPolicy(handler.policy)
+		txHandler.SetEntityCache(handler.entityCache)
+		txHandler.SetResponseStore(handler.responseStore)
 		if handler.entitiesMetadata != nil {
 			txHandler.SetEntitiesMetadata(handler.entitiesMetadata)
 		}
+		txHandler.SetEntityHandlers(h.handlers)
 		if handler.keyGeneratorResolver != nil {
 			txHandler.SetKeyGeneratorResolver(handler.keyGeneratorResolver)
 		}
@@ -603,7 +661,7 @@ func (h *BatchHandler) executeRequestInTransaction(req *batchRequest, tx *gorm.D
 			if hasTarget {
 				targetHandler, targetExists := txHandlers[targetEntitySet]
 				if targetExists {
-					intermediateKey, err := handler.FetchNavEntityKey(key, propertySegments[0])
+					intermediateKey, err := handler.FetchNavEntityKey(r.Context(), key, propertySegments[0])
 					if err != nil {
 						statusCode := http.StatusInternalServerError
 						if IsNotFoundError(err) {
@@ -827,6 +885,8 @@ func (h *BatchHandler) executeRequestInTransaction(req *batchRequest, tx *gorm.D
 	}
 
 	httpReq = httpReq.WithContext(withTransactionAndEvents(ctx, tx, pendingEvents))
+	httpReq = WithRowSecurity(httpReq, h.policy, h.handlers)
+	httpReq = WithVirtualExpand(httpReq, h.virtualExpand)
 
 	// Execute request
 	recorder := httptest.NewRecorder()
@@ -866,75 +926,71 @@ func (h *BatchHandler) createErrorResponse(statusCode int, message string) batch
 
 // writeBatchResponse writes the batch response
 func (h *BatchHandler) writeBatchResponse(w http.ResponseWriter, responses []batchResponse) {
-	// Generate a boundary for the response
-	boundary := fmt.Sprintf("batchresponse_%s", generateBoundary())
-
-	w.Header().Set("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%s", boundary))
-	w.WriteHeader(http.StatusOK)
-
-	// Write each response as a multipart part
+	out := h.newMultipartBatchWriter(w)
 	for _, resp := range responses {
-		if _, err := fmt.Fprintf(w, "--%s\r\n", boundary); err != nil {
-			h.logger.Error("Error writing boundary", "error", err)
-			return
-		}
-		if _, err := fmt.Fprintf(w, "Content-Type: application/http\r\n"); err != nil {
-			h.logger.Error("Error writing content type", "error", err)
-			return
-		}
-		if _, err := fmt.Fprintf(w, "Content-Transfer-Encoding: binary\r\n"); err != nil {
-			h.logger.Error("Error writing encoding", "error", err)
-			return
-		}
-		// Echo Content-ID in the response MIME part envelope if it was present in the request
-		// Per OData v4 spec section 11.7.4, the Content-ID MUST be echoed back
-		if resp.ContentID != "" {
-			if _, err := fmt.Fprintf(w, "Content-ID: %s\r\n", resp.ContentID); err != nil {
-				h.logger.Error("Error writing Content-ID", "error", err)
-				return
-			}
-		}
-		if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
-			h.logger.Error("Error writing newline", "error", err)
-			return
-		}
+		out.writePart(resp)
+	}
+	out.close()
+}
 
-		// Write status line
-		if _, err := fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode)); err != nil {
-			h.logger.Error("Error writing status line", "error", err)
-			return
+// writeBatchPart writes a single response as a multipart part
+func (h *BatchHandler) writeBatchPart(w io.Writer, boundary string, resp batchResponse) error {
+	if _, err := fmt.Fprintf(w, "--%s\r\n", boundary); err != nil {
+		h.logger.Error("Error writing boundary", "error", err)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Type: application/http\r\n"); err != nil {
+		h.logger.Error("Error writing content type", "error", err)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Transfer-Encoding: binary\r\n"); err != nil {
+		h.logger.Error("Error writing encoding", "error", err)
+		return err
+	}
+	// Echo Content-ID in the response MIME part envelope if it was present in the request
+	// Per OData v4 spec section 11.7.4, the Content-ID MUST be echoed back
+	if resp.ContentID != "" {
+		if _, err := fmt.Fprintf(w, "Content-ID: %s\r\n", resp.ContentID); err != nil {
+			h.logger.Error("Error writing Content-ID", "error", err)
+			return err
 		}
+	}
+	if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
+		h.logger.Error("Error writing newline", "error", err)
+		return err
+	}
 
-		// Write headers
-		for key, values := range resp.Headers {
-			for _, value := range values {
-				if _, err := fmt.Fprintf(w, "%s: %s\r\n", key, value); err != nil {
-					h.logger.Error("Error writing header", "error", err)
-					return
-				}
-			}
-		}
+	// Write status line
+	if _, err := fmt.Fprintf(w, "HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode)); err != nil {
+		h.logger.Error("Error writing status line", "error", err)
+		return err
+	}
 
-		if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
-			h.logger.Error("Error writing newline", "error", err)
-			return
+	// Write headers
+	for key, values := range resp.Headers {
+		for _, value := range values {
+			if _, err := fmt.Fprintf(w, "%s: %s\r\n", key, value); err != nil {
+				h.logger.Error("Error writing header", "error", err)
+				return err
+			}
 		}
+	}
 
-		// Write body
-		if _, err := w.Write(resp.Body); err != nil {
-			h.logger.Error("Error writing body", "error", err)
-			return
-		}
-		if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
-			h.logger.Error("Error writing newline", "error", err)
-			return
-		}
+	if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
+		h.logger.Error("Error writing newline", "error", err)
+		return err
 	}
 
-	// Write final boundary
-	if _, err := fmt.Fprintf(w, "--%s--\r\n", boundary); err != nil {
-		h.logger.Error("Error writing final boundary", "error", err)
+	// Write body
+	if _, err := w.Write(resp.Body); err != nil {
+		h.logger.Error("Error writing body", "error", err)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "\r\n"); err != nil {
+		h.logger.Error("Error writing newline", "error", err)
+		return err
 	}
+	return nil
 }
 
 // handleJSONBatch processes a JSON-encoded batch request per OData JSON Format v4.01 §19.
@@ -960,40 +1016,30 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid batch request",
-			fmt.Sprintf("Failed to read request body: %v", err)); writeErr != nil {
-			h.logger.Error("Error writing error response", "error", writeErr)
-		}
-		return
-	}
-
-	var rawEnvelope map[string]json.RawMessage
-	if err := json.Unmarshal(body, &rawEnvelope); err != nil {
-		if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid JSON batch request",
-			fmt.Sprintf("Failed to parse JSON batch envelope: %v", err)); writeErr != nil {
-			h.logger.Error("Error writing error response", "error", writeErr)
-		}
-		return
-	}
-	requestsJSON, requestsPresent := rawEnvelope["requests"]
-	if !requestsPresent || bytes.Equal(bytes.TrimSpace(requestsJSON), []byte("null")) {
+	// Read the envelope one request at a time, keeping only the request metadata
+	// for validation; bodies are decoded again from the spool as requests run.
+	var envelope jsonBatchEnvelope
+	spool, err := h.spoolBatchBody(r, func(body io.Reader) error {
+		requests, scanErr := scanJSONBatchEnvelope(body, h.maxBatchSize)
+		envelope.Requests = requests
+		return scanErr
+	})
+	if errors.Is(err, errMissingJSONBatchRequests) {
 		if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid JSON batch request",
 			"JSON batch requests must contain a top-level 'requests' array"); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
 		}
 		return
 	}
-
-	var envelope jsonBatchEnvelope
-	if err := json.Unmarshal(body, &envelope); err != nil {
-		if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid JSON batch request",
-			fmt.Sprintf("Failed to parse JSON batch envelope: %v", err)); writeErr != nil {
-			h.logger.Error("Error writing error response", "error", writeErr)
-		}
+	if err != nil {
+		h.writeBatchBodyError(w, r, err, "Invalid JSON batch request", "Failed to parse JSON batch envelope: %v")
 		return
 	}
+	defer func() {
+		if err := spool.Close(); err != nil {
+			h.logger.Error("Error removing batch spool", "error", err)
+		}
+	}()
 
 	// Validate identifiers and structural ordering constraints before executing anything.
 	allIDs := make(map[string]bool, len(envelope.Requests))
@@ -1064,12 +1110,9 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Enforce batch size limit
-	if len(envelope.Requests) > h.maxBatchSize {
-		if writeErr := response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch size limit exceeded",
-			fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize)); writeErr != nil {
-			h.logger.Error("Error writing error response", "error", writeErr)
-		}
+	requests, err := newJSONBatchRequestReader(spool.reader())
+	if err != nil {
+		h.writeBatchBodyError(w, r, err, "Invalid JSON batch request", "Failed to parse JSON batch envelope: %v")
 		return
 	}
 
@@ -1080,7 +1123,99 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 	groups := make(map[string]*jsonGroupState)
 	responses := make([]jsonBatchResponseItem, 0, len(envelope.Requests))
 
-	for i, item := range envelope.Requests {
+	// failRemaining answers every request from index from onwards with 424
+	// Failed Dependency after a standalone failure stopped processing.
+	failRemaining := func(from int) {
+		for j := from; j < len(envelope.Requests); j++ {
+			rem := envelope.Requests[j]
+			failedIDs[rem.ID] = true
+			if rem.AtomicityGroup != "" {
+				remGS := h.getOrCreateJSONGroupState(groups, rem.AtomicityGroup)
+				remGS.responseIndices = append(remGS.responseIndices, len(responses))
+			}
+			responses = append(responses, h.makeJSONFailedDependencyResponse(rem.ID, rem.AtomicityGroup))
+		}
+	}
+
+	// settleReads waits for reads running in parallel and records their results.
+	// When a read failed and continue-on-error is off, every response after it
+	// is replaced with 424, as if processing had stopped at that read; only
+	// side-effect-free reads can follow it, so nothing else needs undoing. It
+	// reports whether processing must stop.
+	reads := h.newBatchReadPool(r)
+	if reads != nil {
+		defer reads.settle()
+	}
+	settleReads := func() bool {
+		if reads == nil {
+			return false
+		}
+		stopAt := -1
+		for _, read := range reads.settle() {
+			if stopAt >= 0 {
+				break
+			}
+			responses[read.index] = h.batchResponseToJSONItem(read.id, "", read.response)
+			if read.response.StatusCode >= 400 {
+				failedIDs[read.id] = true
+				if !continueOnError {
+					stopAt = read.index
+				}
+			}
+		}
+		if stopAt < 0 {
+			return false
+		}
+		for j := stopAt + 1; j < len(responses); j++ {
+			failedIDs[responses[j].ID] = true
+			responses[j] = h.makeJSONFailedDependencyResponse(responses[j].ID, "")
+		}
+		return true
+	}
+
+	// Stream each response once it is final. Responses of parallel reads that
+	// are still running and of atomicity groups that may still roll back are held.
+	out := h.newJSONBatchWriter(w)
+	flushed := 0
+	flush := func(next int) {
+		limit := len(responses)
+		if reads != nil {
+			if first, ok := reads.firstPending(); ok {
+				limit = first
+			}
+		}
+		for name, gs := range groups {
+			if !gs.failed && !gs.committed && groupLastIdx[name] >= next &&
+				len(gs.responseIndices) > 0 && gs.responseIndices[0] < limit {
+				limit = gs.responseIndices[0]
+			}
+		}
+		for ; flushed < limit; flushed++ {
+			out.write(responses[flushed])
+			responses[flushed] = jsonBatchResponseItem{}
+		}
+	}
+
+	for i := range envelope.Requests {
+		item, err := requests.next()
+		if err != nil {
+			h.logger.Error("Error reading JSON batch request", "error", err)
+			failRemaining(i)
+			break
+		}
+
+		// --- Settle parallel reads before anything that depends on them ---
+		if reads != nil && reads.pending() {
+			parallel := item.AtomicityGroup == "" && isParallelBatchRead(item.Method, item.URL)
+			if !parallel || reads.inFlight(item.DependsOn) {
+				if settleReads() {
+					failRemaining(i)
+					break
+				}
+			}
+		}
+		flush(i)
+
 		// --- Check dependsOn ---
 		depFailed := false
 		for _, dep := range item.DependsOn {
@@ -1150,7 +1285,7 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 
 			// Start a transaction for the group if this is the first request.
 			if gs.tx == nil {
-				txAdapter, beginErr := h.store.Begin(r.Context())
+				tx, beginErr := beginGORMTransaction(r.Context(), h.store)
 				if beginErr != nil {
 					gs.failed = true
 					failedIDs[item.ID] = true
@@ -1168,7 +1303,7 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 					}
 					continue
 				}
-				gs.tx = txAdapter.DB()
+				gs.tx = tx
 			}
 
 			// Resolve $<id> content-ID URL references within the group.
@@ -1219,6 +1354,10 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 			}
+		} else if reads != nil && isParallelBatchRead(req.Method, req.URL) {
+			// Independent read: run it in parallel and fill in its response when settled.
+			responses = append(responses, jsonBatchResponseItem{ID: item.ID})
+			reads.submit(len(responses)-1, item.ID, req)
 		} else {
 			// Standalone (non-group) request.
 			resp = h.executeRequest(req, r)
@@ -1230,20 +1369,13 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 					// Fatal error: per OData JSON Format v4.01 §19.5, the service MUST
 					// return a response for every request.  Fill remaining unprocessed
 					// requests with 424 Failed Dependency and stop.
-					for j := i + 1; j < len(envelope.Requests); j++ {
-						rem := envelope.Requests[j]
-						failedIDs[rem.ID] = true
-						if rem.AtomicityGroup != "" {
-							remGS := h.getOrCreateJSONGroupState(groups, rem.AtomicityGroup)
-							remGS.responseIndices = append(remGS.responseIndices, len(responses))
-						}
-						responses = append(responses, h.makeJSONFailedDependencyResponse(rem.ID, rem.AtomicityGroup))
-					}
+					failRemaining(i + 1)
 					break
 				}
 			}
 		}
 	}
+	settleReads()
 
 	// Roll back any group transaction that was never committed (early exit).
 	for _, gs := range groups {
@@ -1252,7 +1384,8 @@ func (h *BatchHandler) handleJSONBatch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	h.writeJSONBatchResponse(w, responses)
+	flush(len(envelope.Requests))
+	out.close()
 
 	// Update batch span and metrics (reuse observability infrastructure).
 	if h.observability != nil {
@@ -1358,23 +1491,6 @@ func jsonBatchContinueOnError(preferHeaders []string) bool {
 	return true
 }
 
-// writeJSONBatchResponse serialises the responses slice as a JSON batch response envelope.
-func (h *BatchHandler) writeJSONBatchResponse(w http.ResponseWriter, responses []jsonBatchResponseItem) {
-	envelope := jsonBatchResponseEnvelope{Responses: responses}
-	out, err := json.Marshal(envelope)
-	if err != nil {
-		h.logger.Error("Error marshalling JSON batch response", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write(out); err != nil {
-		h.logger.Error("Error writing JSON batch response", "error", err)
-	}
-}
-
 // jsonRawError returns a json.RawMessage containing an OData-format error object.
 func jsonRawError(code int, message string) json.RawMessage {
 	// Defensive: marshal can theoretically fail with exotic string values, so
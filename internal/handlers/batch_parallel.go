@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SetMaxParallelism sets how many independent GET sub-requests of a batch may
+// run concurrently. Values below 2 process every sub-request sequentially.
+func (h *BatchHandler) SetMaxParallelism(n int) {
+	h.maxParallelism = n
+}
+
+// isParallelBatchRead reports whether a sub-request outside any changeset or
+// atomicity group may run concurrently with its neighbours. Only reads qualify,
+// and reads addressing a $<Content-ID> reference depend on an earlier request.
+func isParallelBatchRead(method, rawURL string) bool {
+	if !strings.EqualFold(method, http.MethodGet) {
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimPrefix(rawURL, "/"), "$")
+}
+
+// batchReadPool runs independent GET sub-requests of one batch on a bounded
+// number of goroutines. The batch loop submits reads in request order and
+// settles the pool before anything that must observe their effects or
+// results: writes, changesets, atomicity groups and dependent requests.
+type batchReadPool struct {
+	handler *BatchHandler
+	parent  *http.Request
+	slots   chan struct{}
+	wg      sync.WaitGroup
+	reads   []*pendingBatchRead
+}
+
+// pendingBatchRead is a submitted read and, once settled, its response.
+type pendingBatchRead struct {
+	index    int
+	id       string
+	response batchResponse
+}
+
+// newBatchReadPool returns nil when parallel reads are disabled.
+func (h *BatchHandler) newBatchReadPool(parent *http.Request) *batchReadPool {
+	if h.maxParallelism < 2 {
+		return nil
+	}
+	return &batchReadPool{
+		handler: h,
+		parent:  parent,
+		slots:   make(chan struct{}, h.maxParallelism),
+	}
+}
+
+// submit starts req once a worker slot is free. index is the position of the
+// request's response; id is the JSON batch request id, if any.
+func (p *batchReadPool) submit(index int, id string, req *batchRequest) {
+	read := &pendingBatchRead{index: index, id: id}
+	p.reads = append(p.reads, read)
+
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.slots
+			p.wg.Done()
+		}()
+		read.response = p.handler.executeRequest(req, p.parent)
+	}()
+}
+
+// pending reports whether reads were submitted since the last settle.
+func (p *batchReadPool) pending() bool {
+	return len(p.reads) > 0
+}
+
+// firstPending returns the response index of the earliest read submitted
+// since the last settle.
+func (p *batchReadPool) firstPending() (int, bool) {
+	if len(p.reads) == 0 {
+		return 0, false
+	}
+	return p.reads[0].index, true
+}
+
+// inFlight reports whether any of ids names a read submitted since the last settle.
+func (p *batchReadPool) inFlight(ids []string) bool {
+	for _, id := range ids {
+		for _, read := range p.reads {
+			if read.id != "" && read.id == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// settle waits for all submitted reads and returns them in request order.
+func (p *batchReadPool) settle() []*pendingBatchRead {
+	p.wg.Wait()
+	reads := p.reads
+	p.reads = nil
+	return reads
+}
+
+// settleInto waits for all submitted reads and stores their responses at
+// their positions in responses, keeping the Content-ID echoed for each part.
+func (p *batchReadPool) settleInto(responses []batchResponse) {
+	for _, read := range p.settle() {
+		read.response.ContentID = responses[read.index].ContentID
+		responses[read.index] = read.response
+	}
+}
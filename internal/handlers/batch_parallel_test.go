@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchProbe is a service handler that records how sub-requests overlap.
+type batchProbe struct {
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+	log     []string
+}
+
+func (p *batchProbe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.active++
+	if p.active > p.maxSeen {
+		p.maxSeen = p.active
+	}
+	p.log = append(p.log, "start "+r.Method+" "+r.URL.Path)
+	p.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	p.mu.Lock()
+	p.active--
+	p.log = append(p.log, "end "+r.Method+" "+r.URL.Path)
+	p.mu.Unlock()
+
+	if strings.Contains(r.URL.Path, "Missing") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error":{"code":"404","message":"not found"}}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	_, _ = fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+}
+
+// logIndex returns the position of entry in the probe log, or -1.
+func (p *batchProbe) logIndex(entry string) int {
+	for i, logged := range p.log {
+		if logged == entry {
+			return i
+		}
+	}
+	return -1
+}
+
+func newParallelBatchHandler(probe *batchProbe, parallelism int) *BatchHandler {
+	handler := NewBatchHandler(nil, map[string]*EntityHandler{}, probe, 100)
+	handler.SetMaxParallelism(parallelism)
+	return handler
+}
+
+func multipartBatchBody(boundary string, requestLines ...string) string {
+	var body strings.Builder
+	for _, line := range requestLines {
+		fmt.Fprintf(&body, "--%s\r\nContent-Type: application/http\r\nContent-Transfer-Encoding: binary\r\n\r\n%s HTTP/1.1\r\nAccept: application/json\r\n\r\n\r\n", boundary, line)
+	}
+	fmt.Fprintf(&body, "--%s--\r\n", boundary)
+	return body.String()
+}
+
+func readMultipartBatchBodies(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse response Content-Type: %v", err)
+	}
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return bodies
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		raw, _ := io.ReadAll(part)
+		bodies = append(bodies, string(raw))
+	}
+}
+
+func TestBatchHandler_ParallelReadsKeepOrder(t *testing.T) {
+	probe := &batchProbe{}
+	handler := newParallelBatchHandler(probe, 3)
+
+	lines := make([]string, 6)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("GET /Items%d", i)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(multipartBatchBody("batch_p", lines...)))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=batch_p")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %v, want %v", w.Code, http.StatusOK)
+	}
+	if probe.maxSeen < 2 || probe.maxSeen > 3 {
+		t.Errorf("max concurrent sub-requests = %d, want between 2 and 3", probe.maxSeen)
+	}
+	bodies := readMultipartBatchBodies(t, w)
+	if len(bodies) != len(lines) {
+		t.Fatalf("got %d responses, want %d", len(bodies), len(lines))
+	}
+	for i, body := range bodies {
+		if want := fmt.Sprintf(`{"path":"/Items%d"}`, i); !strings.Contains(body, want) {
+			t.Errorf("response %d = %q, want it to contain %s", i, body, want)
+		}
+	}
+}
+
+func TestBatchHandler_ParallelReadsWaitForWrites(t *testing.T) {
+	probe := &batchProbe{}
+	handler := newParallelBatchHandler(probe, 4)
+
+	body := multipartBatchBody("batch_w", "GET /Before", "POST /Items", "GET /After")
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=batch_w")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %v, want %v", w.Code, http.StatusOK)
+	}
+	if probe.logIndex("end GET /Before") > probe.logIndex("start POST /Items") {
+		t.Errorf("write started before the preceding read finished: %v", probe.log)
+	}
+	if probe.logIndex("end POST /Items") > probe.logIndex("start GET /After") {
+		t.Errorf("read started before the preceding write finished: %v", probe.log)
+	}
+}
+
+func TestBatchHandler_SequentialByDefault(t *testing.T) {
+	probe := &batchProbe{}
+	handler := NewBatchHandler(nil, map[string]*EntityHandler{}, probe, 100)
+
+	body := multipartBatchBody("batch_s", "GET /A", "GET /B", "GET /C")
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=batch_s")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	if probe.maxSeen != 1 {
+		t.Errorf("max concurrent sub-requests = %d, want 1", probe.maxSeen)
+	}
+}
+
+func decodeJSONBatchResponses(t *testing.T, w *httptest.ResponseRecorder) []jsonBatchResponseItem {
+	t.Helper()
+	var envelope jsonBatchResponseEnvelope
+	if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode JSON batch response: %v; body: %s", err, w.Body.String())
+	}
+	return envelope.Responses
+}
+
+func TestBatchHandler_JSONParallelReadsHonourDependsOn(t *testing.T) {
+	probe := &batchProbe{}
+	handler := newParallelBatchHandler(probe, 4)
+
+	body := `{"requests":[
+		{"id":"r1","method":"GET","url":"/Missing"},
+		{"id":"r2","method":"GET","url":"/Other"},
+		{"id":"r3","method":"GET","url":"/Dependent","dependsOn":["r1"]},
+		{"id":"r4","method":"GET","url":"/Independent"}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	responses := decodeJSONBatchResponses(t, w)
+	want := []struct {
+		id     string
+		status int
+	}{{"r1", 404}, {"r2", 200}, {"r3", 424}, {"r4", 200}}
+	if len(responses) != len(want) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(want))
+	}
+	for i, expected := range want {
+		if responses[i].ID != expected.id || responses[i].Status != expected.status {
+			t.Errorf("response %d = %s/%d, want %s/%d", i, responses[i].ID, responses[i].Status, expected.id, expected.status)
+		}
+	}
+	if probe.logIndex("start GET /Dependent") != -1 {
+		t.Errorf("request depending on a failed read was executed: %v", probe.log)
+	}
+	if probe.maxSeen < 2 {
+		t.Errorf("max concurrent sub-requests = %d, want at least 2", probe.maxSeen)
+	}
+}
+
+func TestBatchHandler_JSONParallelReadsStopOnError(t *testing.T) {
+	probe := &batchProbe{}
+	handler := newParallelBatchHandler(probe, 4)
+
+	body := `{"requests":[
+		{"id":"r1","method":"GET","url":"/Missing"},
+		{"id":"r2","method":"GET","url":"/Other"},
+		{"id":"r3","method":"POST","url":"/Items","body":{}}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Prefer", "continue-on-error=false")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	responses := decodeJSONBatchResponses(t, w)
+	statuses := make([]int, 0, len(responses))
+	for _, resp := range responses {
+		statuses = append(statuses, resp.Status)
+	}
+	if len(statuses) != 3 || statuses[0] != 404 || statuses[1] != 424 || statuses[2] != 424 {
+		t.Fatalf("statuses = %v, want [404 424 424]", statuses)
+	}
+	if probe.logIndex("start POST /Items") != -1 {
+		t.Errorf("write after a failed read was executed: %v", probe.log)
+	}
+}
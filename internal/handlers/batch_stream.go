@@ -0,0 +1,437 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/response"
+)
+
+// batchSpoolMemoryLimit is how many bytes of a batch body are kept in memory
+// before the spool moves to a temporary file.
+const batchSpoolMemoryLimit = 1 << 20
+
+var (
+	// errBatchBodyTooLarge reports a batch body above the configured maximum size.
+	errBatchBodyTooLarge = errors.New("batch request body is too large")
+	// errBatchSizeExceeded reports a batch with more sub-requests than allowed.
+	errBatchSizeExceeded = errors.New("batch request contains too many sub-requests")
+	// errBatchSpoolFailed reports that the batch body could not be buffered.
+	errBatchSpoolFailed = errors.New("failed to buffer batch request")
+	// errMissingJSONBatchRequests reports a JSON batch envelope without a requests array.
+	errMissingJSONBatchRequests = errors.New("JSON batch requests must contain a top-level 'requests' array")
+)
+
+// SetMaxBodySize sets the maximum size in bytes of a $batch request body.
+// Values of 0 or below leave the body size unlimited.
+func (h *BatchHandler) SetMaxBodySize(n int64) {
+	h.maxBodySize = n
+}
+
+// batchSpool buffers a batch body while it is validated so that sub-requests
+// can afterwards be parsed from it one at a time. Bodies above memoryLimit are
+// moved to a temporary file.
+type batchSpool struct {
+	memoryLimit int
+	buf         bytes.Buffer
+	file        *os.File
+	size        int64
+	err         error
+}
+
+func newBatchSpool() *batchSpool {
+	return &batchSpool{memoryLimit: batchSpoolMemoryLimit}
+}
+
+func (s *batchSpool) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.file == nil && s.buf.Len()+len(p) > s.memoryLimit {
+		file, err := os.CreateTemp("", "odata-batch-*")
+		if err != nil {
+			s.err = err
+			return 0, err
+		}
+		s.file = file
+		if _, err := s.file.Write(s.buf.Bytes()); err != nil {
+			s.err = err
+			return 0, err
+		}
+		s.buf = bytes.Buffer{}
+	}
+
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.size += int64(n)
+	if err != nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// reader returns a reader over everything written to the spool.
+func (s *batchSpool) reader() io.Reader {
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, s.size)
+	}
+	return bytes.NewReader(s.buf.Bytes())
+}
+
+// Close releases the spool and removes its temporary file, if any.
+func (s *batchSpool) Close() error {
+	s.buf = bytes.Buffer{}
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	closeErr := s.file.Close()
+	s.file = nil
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// batchBodyLimitReader fails with errBatchBodyTooLarge once more than limit
+// bytes were read. A limit of 0 or below reads without a limit.
+type batchBodyLimitReader struct {
+	r        io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (l *batchBodyLimitReader) Read(p []byte) (int, error) {
+	if l.limit <= 0 {
+		return l.r.Read(p)
+	}
+	if l.read >= l.limit {
+		// Only fail when the body really continues past the limit.
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			l.exceeded = true
+			return 0, errBatchBodyTooLarge
+		}
+		return 0, err
+	}
+	if remaining := l.limit - l.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// spoolBatchBody copies the request body into a spool while scan validates
+// it, so nothing is executed for batches that are malformed, too large or
+// contain too many sub-requests. The caller must close the returned spool.
+func (h *BatchHandler) spoolBatchBody(r *http.Request, scan func(io.Reader) error) (*batchSpool, error) {
+	spool := newBatchSpool()
+	body := &batchBodyLimitReader{r: r.Body, limit: h.maxBodySize}
+	err := scan(io.TeeReader(body, spool))
+	if err == nil {
+		return spool, nil
+	}
+	if closeErr := spool.Close(); closeErr != nil {
+		h.logger.Error("Error removing batch spool", "error", closeErr)
+	}
+	switch {
+	case body.exceeded:
+		return nil, errBatchBodyTooLarge
+	case spool.err != nil:
+		return nil, fmt.Errorf("%w: %v", errBatchSpoolFailed, spool.err)
+	}
+	return nil, err
+}
+
+// writeBatchBodyError answers a batch whose body was rejected by spoolBatchBody.
+// Errors other than size and buffering failures are reported as 400 Bad Request
+// with the given title and detail format.
+func (h *BatchHandler) writeBatchBodyError(w http.ResponseWriter, r *http.Request, err error, title, detailFormat string) {
+	var writeErr error
+	switch {
+	case errors.Is(err, errBatchBodyTooLarge):
+		writeErr = response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch body too large",
+			fmt.Sprintf("Batch request body exceeds the maximum allowed size of %d bytes", h.maxBodySize))
+	case errors.Is(err, errBatchSizeExceeded):
+		writeErr = response.WriteError(w, r, http.StatusRequestEntityTooLarge, "Batch size limit exceeded",
+			fmt.Sprintf("Batch request contains too many sub-requests. Maximum allowed: %d", h.maxBatchSize))
+	case errors.Is(err, errBatchSpoolFailed):
+		h.logger.Error("Error buffering batch request", "error", err)
+		writeErr = response.WriteError(w, r, http.StatusInternalServerError, "Internal server error",
+			"Failed to buffer batch request")
+	default:
+		writeErr = response.WriteError(w, r, http.StatusBadRequest, title, fmt.Sprintf(detailFormat, err))
+	}
+	if writeErr != nil {
+		h.logger.Error("Error writing error response", "error", writeErr)
+	}
+}
+
+// scanMultipartBatch reads a multipart batch body and verifies that it is
+// well-formed and contains at most maxRequests sub-requests, counting every
+// request inside a changeset. Malformed changesets are left to processChangeset,
+// which reports them in the changeset's response.
+func scanMultipartBatch(r io.Reader, boundary string, maxRequests int) error {
+	reader := multipart.NewReader(r, boundary)
+	count := 0
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err == nil && strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+			changeset := multipart.NewReader(part, params["boundary"])
+			for {
+				if _, err := changeset.NextPart(); err != nil {
+					break
+				}
+				count++
+			}
+		} else {
+			count++
+		}
+		if count > maxRequests {
+			return errBatchSizeExceeded
+		}
+	}
+}
+
+// seekJSONBatchRequests advances dec past the opening bracket of the
+// top-level "requests" array of a JSON batch envelope.
+func seekJSONBatchRequests(dec *json.Decoder) error {
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key, _ := token.(string); key != "requests" {
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+
+		token, err = dec.Token()
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			return errMissingJSONBatchRequests
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return fmt.Errorf("'requests' must be an array")
+		}
+		return nil
+	}
+	return errMissingJSONBatchRequests
+}
+
+func expectJSONDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expected %q, got %v", want, token)
+	}
+	return nil
+}
+
+// scanJSONBatchEnvelope reads a JSON batch envelope one request at a time and
+// returns the requests without their bodies, which are decoded again from the
+// spool when each request runs.
+func scanJSONBatchEnvelope(r io.Reader, maxRequests int) ([]jsonBatchRequestItem, error) {
+	dec := json.NewDecoder(r)
+	if err := seekJSONBatchRequests(dec); err != nil {
+		return nil, err
+	}
+
+	items := make([]jsonBatchRequestItem, 0)
+	for dec.More() {
+		var item jsonBatchRequestItem
+		if err := dec.Decode(&item); err != nil {
+			return nil, err
+		}
+		item.Body = nil
+		items = append(items, item)
+		if len(items) > maxRequests {
+			return nil, errBatchSizeExceeded
+		}
+	}
+	if err := expectJSONDelim(dec, ']'); err != nil {
+		return nil, err
+	}
+
+	// Validate the remainder of the envelope.
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key, _ := token.(string); key == "requests" {
+			return nil, fmt.Errorf("duplicate 'requests' member")
+		}
+		var skipped json.RawMessage
+		if err := dec.Decode(&skipped); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectJSONDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after top-level value")
+	}
+	return items, nil
+}
+
+// jsonBatchRequestReader decodes the requests of a validated JSON batch
+// envelope one at a time.
+type jsonBatchRequestReader struct {
+	dec *json.Decoder
+}
+
+func newJSONBatchRequestReader(r io.Reader) (*jsonBatchRequestReader, error) {
+	dec := json.NewDecoder(r)
+	if err := seekJSONBatchRequests(dec); err != nil {
+		return nil, err
+	}
+	return &jsonBatchRequestReader{dec: dec}, nil
+}
+
+func (rr *jsonBatchRequestReader) next() (jsonBatchRequestItem, error) {
+	var item jsonBatchRequestItem
+	if !rr.dec.More() {
+		return item, io.ErrUnexpectedEOF
+	}
+	err := rr.dec.Decode(&item)
+	return item, err
+}
+
+// flushBatchResponse pushes a written sub-response to the client.
+func flushBatchResponse(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).Flush()
+}
+
+// multipartBatchWriter writes the parts of a multipart batch response as
+// they become available.
+type multipartBatchWriter struct {
+	h        *BatchHandler
+	w        http.ResponseWriter
+	boundary string
+	failed   bool
+}
+
+// newMultipartBatchWriter writes the status and headers of a multipart batch response.
+func (h *BatchHandler) newMultipartBatchWriter(w http.ResponseWriter) *multipartBatchWriter {
+	boundary := fmt.Sprintf("batchresponse_%s", generateBoundary())
+	w.Header().Set("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%s", boundary))
+	w.WriteHeader(http.StatusOK)
+	return &multipartBatchWriter{h: h, w: w, boundary: boundary}
+}
+
+// writePart writes resp as the next part and flushes it. After a write error
+// the remaining parts are dropped.
+func (mw *multipartBatchWriter) writePart(resp batchResponse) {
+	if mw.failed {
+		return
+	}
+	if err := mw.h.writeBatchPart(mw.w, mw.boundary, resp); err != nil {
+		mw.failed = true
+		return
+	}
+	flushBatchResponse(mw.w)
+}
+
+// close writes the final boundary.
+func (mw *multipartBatchWriter) close() {
+	if mw.failed {
+		return
+	}
+	if _, err := fmt.Fprintf(mw.w, "--%s--\r\n", mw.boundary); err != nil {
+		mw.h.logger.Error("Error writing final boundary", "error", err)
+	}
+}
+
+// jsonBatchWriter writes the responses array of a JSON batch response as
+// responses become available.
+type jsonBatchWriter struct {
+	h       *BatchHandler
+	w       http.ResponseWriter
+	written int
+	failed  bool
+}
+
+// newJSONBatchWriter writes the status, headers and opening of a JSON batch response.
+func (h *BatchHandler) newJSONBatchWriter(w http.ResponseWriter) *jsonBatchWriter {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	jw := &jsonBatchWriter{h: h, w: w}
+	if _, err := io.WriteString(w, `{"responses":[`); err != nil {
+		h.logger.Error("Error writing JSON batch response", "error", err)
+		jw.failed = true
+	}
+	return jw
+}
+
+// write appends item to the responses array and flushes it.
+func (jw *jsonBatchWriter) write(item jsonBatchResponseItem) {
+	if jw.failed {
+		return
+	}
+	out, err := json.Marshal(item)
+	if err != nil {
+		jw.h.logger.Error("Error marshalling JSON batch response", "error", err)
+		out, _ = json.Marshal(jsonBatchResponseItem{
+			ID:             item.ID,
+			Status:         http.StatusInternalServerError,
+			Body:           jsonRawError(http.StatusInternalServerError, "Internal server error"),
+			AtomicityGroup: item.AtomicityGroup,
+		})
+	}
+	if jw.written > 0 {
+		out = append([]byte{','}, out...)
+	}
+	if _, err := jw.w.Write(out); err != nil {
+		jw.h.logger.Error("Error writing JSON batch response", "error", err)
+		jw.failed = true
+		return
+	}
+	jw.written++
+	flushBatchResponse(jw.w)
+}
+
+// close terminates the responses array and the envelope.
+func (jw *jsonBatchWriter) close() {
+	if jw.failed {
+		return
+	}
+	if _, err := io.WriteString(jw.w, "]}"); err != nil {
+		jw.h.logger.Error("Error writing JSON batch response", "error", err)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchSpool_MovesLargeBodiesToTempFile(t *testing.T) {
+	spool := newBatchSpool()
+	spool.memoryLimit = 8
+
+	if _, err := spool.Write([]byte("0123")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if spool.file != nil {
+		t.Fatal("spool moved to a file below the memory limit")
+	}
+	if _, err := spool.Write([]byte("456789")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if spool.file == nil {
+		t.Fatal("spool stayed in memory above the memory limit")
+	}
+	name := spool.file.Name()
+
+	content, err := io.ReadAll(spool.reader())
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(content) != "0123456789" {
+		t.Errorf("spool content = %q, want %q", content, "0123456789")
+	}
+
+	if err := spool.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("temporary file %s was not removed", name)
+	}
+}
+
+func TestBatchBodyLimitReader(t *testing.T) {
+	exact := &batchBodyLimitReader{r: strings.NewReader("12345"), limit: 5}
+	if content, err := io.ReadAll(exact); err != nil || string(content) != "12345" {
+		t.Errorf("ReadAll at the limit = %q, %v; want full body", content, err)
+	}
+
+	over := &batchBodyLimitReader{r: strings.NewReader("123456"), limit: 5}
+	if _, err := io.ReadAll(over); !errors.Is(err, errBatchBodyTooLarge) {
+		t.Errorf("ReadAll above the limit error = %v, want %v", err, errBatchBodyTooLarge)
+	}
+	if !over.exceeded {
+		t.Error("exceeded = false, want true")
+	}
+
+	unlimited := &batchBodyLimitReader{r: strings.NewReader("123456")}
+	if content, err := io.ReadAll(unlimited); err != nil || string(content) != "123456" {
+		t.Errorf("ReadAll without limit = %q, %v; want full body", content, err)
+	}
+}
+
+func TestBatchHandler_BodyTooLarge(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "multipart",
+			contentType: "multipart/mixed; boundary=batch_l",
+			body:        multipartBatchBody("batch_l", "GET /A", "GET /B", "GET /C"),
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"requests":[{"id":"r1","method":"GET","url":"/A"},{"id":"r2","method":"GET","url":"/B"},{"id":"r3","method":"GET","url":"/C"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probe := &batchProbe{}
+			handler := NewBatchHandler(nil, map[string]*EntityHandler{}, probe, 100)
+			handler.SetMaxBodySize(64)
+
+			req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			handler.HandleBatch(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Status = %v, want %v. Body: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+			}
+			if len(probe.log) != 0 {
+				t.Errorf("sub-requests ran for a rejected batch: %v", probe.log)
+			}
+		})
+	}
+}
+
+// streamingProbe records how much of the batch response had been written
+// when each sub-request started.
+type streamingProbe struct {
+	out     *httptest.ResponseRecorder
+	written map[string]string
+}
+
+func (p *streamingProbe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.written[r.URL.Path] = p.out.Body.String()
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, `{"path":"`+r.URL.Path+`"}`)
+}
+
+func TestBatchHandler_StreamsResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "multipart",
+			contentType: "multipart/mixed; boundary=batch_st",
+			body:        multipartBatchBody("batch_st", "GET /First", "GET /Second"),
+		},
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"requests":[{"id":"r1","method":"GET","url":"/First"},{"id":"r2","method":"GET","url":"/Second"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			probe := &streamingProbe{out: w, written: map[string]string{}}
+			handler := NewBatchHandler(nil, map[string]*EntityHandler{}, probe, 100)
+
+			req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			handler.HandleBatch(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Status = %v, want %v. Body: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			if !strings.Contains(probe.written["/Second"], `{"path":"/First"}`) {
+				t.Errorf("first response was not written before the second request ran; written so far: %q", probe.written["/Second"])
+			}
+			if !w.Flushed {
+				t.Error("batch response was not flushed")
+			}
+		})
+	}
+}
+
+func TestJSONBatch_EnvelopeWithOtherMembers(t *testing.T) {
+	probe := &batchProbe{}
+	handler := NewBatchHandler(nil, map[string]*EntityHandler{}, probe, 100)
+
+	body := `{"comment":{"nested":[1,2]},"requests":[{"id":"r1","method":"GET","url":"/A"}],"trailer":true}`
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	handler.HandleBatch(w, req)
+
+	responses := decodeJSONBatchResponses(t, w)
+	if len(responses) != 1 || responses[0].ID != "r1" || responses[0].Status != http.StatusOK {
+		t.Fatalf("responses = %+v, want r1 with status 200", responses)
+	}
+	if !bytes.Contains(responses[0].Body, []byte(`"/A"`)) {
+		t.Errorf("response body = %s, want the result of /A", responses[0].Body)
+	}
+}
+
+func TestJSONBatch_InvalidEnvelopeTail(t *testing.T) {
+	handler := NewBatchHandler(nil, map[string]*EntityHandler{}, &batchProbe{}, 100)
+
+	for _, body := range []string{
+		`{"requests":[{"id":"r1","method":"GET","url":"/A"}]} trailing`,
+		`{"requests":[{"id":"r1","method":"GET","url":"/A"}],"requests":[]}`,
+		`{"requests":[{"id":"r1","method":"GET","url":"/A"}]`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		handler.HandleBatch(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: Status = %v, want %v", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/response"
 )
 
@@ -36,7 +37,7 @@ func (h *EntityHandler) HandleCollection(w http.ResponseWriter, r *http.Request)
 		if !authorizeRequest(w, r, h.policy, buildEntityResourceDescriptor(h.metadata, "", nil), auth.OperationQuery, h.logger) {
 			return
 		}
-		h.handleGetCollection(w, r)
+		h.serveWithResponseCache(w, r, h.handleGetCollection)
 	case http.MethodPost:
 		h.handlePostEntity(w, r)
 	case http.MethodOptions:
@@ -81,7 +82,7 @@ func (h *EntityHandler) HandleCount(w http.ResponseWriter, r *http.Request) {
 		if !authorizeRequest(w, r, h.policy, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), auth.OperationQuery, h.logger) {
 			return
 		}
-		h.handleGetCount(w, r)
+		h.serveWithResponseCache(w, r, h.handleGetCount)
 	case http.MethodOptions:
 		if !authorizeRequest(w, r, h.policy, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), auth.OperationRead, h.logger) {
 			return
@@ -107,7 +108,21 @@ func (h *EntityHandler) handleGetCount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := applyPolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), queryOptions); err != nil {
+	if err := h.validateCountRestriction(); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+	if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+
+	if queryOptions.Deleted != query.DeletedExclude &&
+		!h.authorizeDeletedAccess(w, r, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), auth.OperationReadDeleted) {
+		return
+	}
+
+	if err := applyDatabasePolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), queryOptions); err != nil {
 		WriteError(w, r, http.StatusForbidden, "Authorization failed", err.Error())
 		return
 	}
@@ -144,6 +159,15 @@ func (h *EntityHandler) handleGetCountOverwrite(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	if err := h.validateCountRestriction(); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+	if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+
 	if err := applyPolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", []string{"$count"}), queryOptions); err != nil {
 		WriteError(w, r, http.StatusForbidden, "Authorization failed", err.Error())
 		return
@@ -158,7 +182,8 @@ func (h *EntityHandler) handleGetCountOverwrite(w http.ResponseWriter, r *http.R
 	// Call the overwrite handler
 	count, err := h.overwrite.getCount(ctx)
 	if err != nil {
-		WriteError(w, r, http.StatusInternalServerError, "Error getting count", err.Error())
+		_, status, message, details := extractHookErrorDetails(err, http.StatusInternalServerError, "Error getting count")
+		WriteError(w, r, status, message, details)
 		return
 	}
 
@@ -16,12 +16,14 @@ func (h *EntityHandler) countEntities(ctx context.Context, queryOptions *query.Q
 	// Fast path: count directly from the in-memory snapshot cache when it is warm
 	// and the query is within the supported subset.
 	if len(scopes) == 0 && h.entityCache != nil && h.snapshotSupportsCount(queryOptions) {
-		if snap, ok := h.cacheSnapshot(ctx); ok {
-			var filter *query.FilterExpression
-			if queryOptions != nil {
-				filter = queryOptions.Filter
+		var filter *query.FilterExpression
+		if queryOptions != nil {
+			filter = queryOptions.Filter
+		}
+		if filter, ok := h.snapshotFilter(ctx, filter); ok {
+			if snap, ok := h.cacheSnapshot(ctx); ok {
+				return h.countSnapshot(snap, filter), nil
 			}
-			return h.countSnapshot(snap, filter), nil
 		}
 	}
 
@@ -31,6 +33,12 @@ func (h *EntityHandler) countEntities(ctx context.Context, queryOptions *query.Q
 	if len(scopes) > 0 {
 		baseDB = baseDB.Scopes(scopes...)
 	}
+	if queryOptions != nil && queryOptions.Temporal != nil {
+		baseDB = baseDB.Scopes(h.temporalScope(queryOptions.Temporal))
+	}
+	if queryOptions != nil && queryOptions.Deleted != query.DeletedExclude {
+		baseDB = baseDB.Scopes(h.deletedScope(queryOptions.Deleted))
+	}
 
 	if queryOptions == nil {
 		var count int64
@@ -51,6 +59,28 @@ func (h *EntityHandler) countEntities(ctx context.Context, queryOptions *query.Q
 	filter := queryOptions.Filter
 	search := queryOptions.Search
 
+	ranking, err := h.rankSearch(ctx, queryOptions)
+	if err != nil {
+		return 0, err
+	}
+	if ranking != nil {
+		var total int64
+		session := baseDB.Session(&gorm.Session{})
+		for _, hits := range ranking.pages() {
+			countDB := h.restrictToSearchHits(session, hits)
+			if filter != nil {
+				countDB = query.ApplyFilterOnly(countDB, filter, h.metadata, h.logger)
+			}
+			var count int64
+			if err := countDB.Count(&count).Error; err != nil {
+				return 0, err
+			}
+			total += count
+		}
+
+		return total, nil
+	}
+
 	if search != "" && h.ftsManager != nil {
 		countOptions := &query.QueryOptions{Filter: filter, Search: search}
 		countDB := query.ApplyQueryOptionsWithFTS(baseDB, countOptions, h.metadata, h.ftsManager, h.metadata.TableName, h.logger)
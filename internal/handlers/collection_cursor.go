@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/nlstn/go-odata/internal/fastscan"
+	"github.com/nlstn/go-odata/internal/preference"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/response"
+	"gorm.io/gorm"
+)
+
+// errCursorPageFull stops the row iteration once a streamed page is complete.
+var errCursorPageFull = errors.New("streamed page is full")
+
+// EnableCollectionStreaming makes collection reads of the entity set stream
+// their results from a database cursor instead of materializing the page. Clients
+// can also ask for this per request with Prefer: odata.streaming.
+func (h *EntityHandler) EnableCollectionStreaming() {
+	h.streamCollections = true
+}
+
+// streamCollectionFunc returns the StreamResponse phase of the collection
+// pipeline. It streams when the entity set or the request opted in and the
+// query only needs what the direct struct writer can produce row by row.
+func (h *EntityHandler) streamCollectionFunc(w http.ResponseWriter, r *http.Request, pref *preference.Preference) func(*query.QueryOptions, []func(*gorm.DB) *gorm.DB, *int64) (bool, error) {
+	return func(queryOptions *query.QueryOptions, scopes []func(*gorm.DB) *gorm.DB, totalCount *int64) (bool, error) {
+		if !h.streamCollections && !pref.StreamingRequested {
+			return false, nil
+		}
+		if r.Method != http.MethodGet || !response.CanStreamCollection(r) || !h.canStreamCollection(r, queryOptions, pref) {
+			return false, nil
+		}
+
+		db := h.cursorQuery(r, queryOptions, scopes)
+		if db == nil {
+			return false, nil
+		}
+		return h.streamCollection(w, r, pref, db, queryOptions, totalCount)
+	}
+}
+
+// canStreamCollection reports whether the query reads plain entity rows that
+// need no post-processing of the complete result: no $expand, $apply or
+// $compute, a $select the writer can project, no type cast filtered in memory,
+// no change tracking link and no after-read collection hook.
+func (h *EntityHandler) canStreamCollection(r *http.Request, queryOptions *query.QueryOptions, pref *preference.Preference) bool {
+	if len(queryOptions.Expand) > 0 || query.ShouldUseMapResults(queryOptions) {
+		return false
+	}
+	// Relevance ordering needs every match before the first row is written.
+	if query.HasSearchScoreOrder(queryOptions.OrderBy) {
+		return false
+	}
+	// Version periods are read alongside the complete result.
+	if queryOptions.Temporal.IsPeriod() {
+		return false
+	}
+	if queryOptions.Top != nil && *queryOptions.Top <= 0 {
+		return false
+	}
+	if len(queryOptions.Select) > 0 && !query.CanDeferSelectProjection(queryOptions.Select, queryOptions.Expand, h.metadata) {
+		return false
+	}
+	if pref.TrackChangesRequested || GetTypeCast(r.Context()) != "" {
+		return false
+	}
+	if h.metadata.Hooks.HasODataAfterReadCollection {
+		return false
+	}
+	_, hasGenericHook := reflect.PointerTo(h.metadata.EntityType).MethodByName("ODataAfterReadCollectionGeneric")
+	return !hasGenericHook
+}
+
+// cursorQuery builds the SELECT for a streamed page, mirroring fetchResults for
+// entity rows. It returns nil when $search could not be pushed to the database,
+// because the in-memory search needs the complete result, or when the search
+// provider failed, so that fetchResults reports the error.
+func (h *EntityHandler) cursorQuery(r *http.Request, queryOptions *query.QueryOptions, scopes []func(*gorm.DB) *gorm.DB) *gorm.DB {
+	modifiedOptions := *queryOptions
+	if queryOptions.Top != nil {
+		// One extra row tells whether a next page exists.
+		topPlusOne := *queryOptions.Top + 1
+		modifiedOptions.Top = &topPlusOne
+	}
+
+	db := h.db.WithContext(r.Context())
+	if len(scopes) > 0 {
+		db = db.Scopes(scopes...)
+	}
+	if queryOptions.Temporal != nil {
+		db = db.Scopes(h.temporalScope(queryOptions.Temporal))
+	}
+	if queryOptions.Deleted != query.DeletedExclude {
+		db = db.Scopes(h.deletedScope(queryOptions.Deleted))
+	}
+	if queryOptions.SkipToken != nil {
+		db = h.applySkipTokenFilter(db, queryOptions)
+	}
+	ranking, err := h.rankSearch(r.Context(), queryOptions)
+	if err != nil {
+		return nil
+	}
+	if ranking != nil {
+		if ranking.paged() {
+			// The matches are read page by page and ordered in memory.
+			return nil
+		}
+		db = h.restrictToSearchHits(db, ranking.hits)
+		modifiedOptions.Search = ""
+	}
+	if len(modifiedOptions.OrderBy) == 0 && len(h.metadata.KeyProperties) > 0 {
+		db = h.orderByKeys(db)
+	}
+	db = query.ApplyQueryOptionsWithFTS(db, &modifiedOptions, h.metadata, h.ftsManager, h.metadata.TableName, h.logger)
+
+	if modifiedOptions.Search != "" {
+		if applied, ok := db.Get("_fts_search_applied"); !ok || applied != true {
+			return nil
+		}
+	}
+	return db
+}
+
+// streamCollection writes the response while the rows are read. Errors before
+// the first row are returned so the pipeline can report them; once the response
+// has started, a failure truncates the payload and is only logged. It reports
+// false when the statement turned out not to be streamable (for example because
+// a read scope added preloads) and nothing was written.
+func (h *EntityHandler) streamCollection(w http.ResponseWriter, r *http.Request, pref *preference.Preference, db *gorm.DB, queryOptions *query.QueryOptions, totalCount *int64) (bool, error) {
+	var (
+		stream   *response.CollectionStream
+		lastRow  reflect.Value
+		nextLink *string
+	)
+	start := func() error {
+		if stream != nil {
+			return nil
+		}
+		pref.ApplyStreaming()
+		applyCollectionPreferences(w, pref)
+		var err error
+		stream, err = response.NewCollectionStream(w, r, h.metadata.EntitySetName, h.getMetadataAdapter(), h.metadata,
+			selectedNavigationProps(queryOptions.Select, h.metadata), queryOptions.Select, totalCount)
+		return err
+	}
+
+	err := fastscan.Each(db, h.metadata.EntityType, func(entity reflect.Value) error {
+		if queryOptions.Top != nil && stream != nil && stream.Count() == *queryOptions.Top {
+			return errCursorPageFull
+		}
+		if err := start(); err != nil {
+			return err
+		}
+		if err := stream.WriteEntity(entity); err != nil {
+			return err
+		}
+		if queryOptions.Top != nil && stream.Count() == *queryOptions.Top {
+			// Keep a copy of the page's last entity to derive the $skiptoken from.
+			lastRow = reflect.New(entity.Type()).Elem()
+			lastRow.Set(entity)
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errCursorPageFull):
+		if nextLink = buildNextLinkForLastEntity(h.metadata, queryOptions, lastRow.Interface(), r, h.tokenSigner); nextLink == nil {
+			nextLink = h.skipNextLink(queryOptions, r)
+		}
+	case errors.Is(err, fastscan.ErrNotStreamable) && stream == nil:
+		return false, nil
+	case err != nil && stream == nil:
+		return true, err
+	case err != nil:
+		h.logger.Error("Error streaming collection", "entitySet", h.metadata.EntitySetName, "error", err)
+		stream.Abort()
+		return true, errRequestHandled
+	}
+
+	if err := start(); err != nil {
+		return true, err
+	}
+	if h.observability != nil {
+		h.observability.Metrics().RecordResultCount(r.Context(), h.metadata.EntitySetName, int64(stream.Count()))
+	}
+	if err := stream.Close(nextLink); err != nil {
+		h.logger.Error("Error writing streamed collection", "entitySet", h.metadata.EntitySetName, "error", err)
+	}
+	return true, nil
+}
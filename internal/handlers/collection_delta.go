@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/nlstn/go-odata/internal/etag"
@@ -15,6 +16,24 @@ func (h *EntityHandler) handleDeltaCollection(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	token, err := h.tokenSigner.Verify(token, continuationTokenScope(deltaTokenScopeKind, h.metadata.EntitySetName, r))
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
+			"Invalid $deltatoken value: the token was not issued for this entity set and query, or it has been modified")
+		return
+	}
+
+	rowFilter, err := h.rowFilter(r.Context())
+	if err != nil {
+		WriteError(w, r, http.StatusForbidden, "Authorization failed", err.Error())
+		return
+	}
+	if !h.filterSupported(rowFilter) {
+		WriteError(w, r, http.StatusForbidden, "Authorization failed",
+			"The row filter of the authorization policy cannot be applied to delta responses")
+		return
+	}
+
 	entitySet, err := h.tracker.EntitySetFromToken(token)
 	if err != nil {
 		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
@@ -29,12 +48,20 @@ func (h *EntityHandler) handleDeltaCollection(w http.ResponseWriter, r *http.Req
 	}
 
 	events, newToken, err := h.tracker.ChangesSince(token)
+	if errors.Is(err, trackchanges.ErrTokenExpired) {
+		WriteError(w, r, http.StatusGone, ErrMsgDeltaTokenExpired, ErrDetailDeltaTokenExpired)
+		return
+	}
 	if err != nil {
 		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions, err.Error())
 		return
 	}
 
+	if rowFilter != nil {
+		events = h.visibleChangeEvents(events, h.prepareFilter(rowFilter))
+	}
 	entries := h.buildDeltaEntries(r, events)
+	newToken = h.tokenSigner.Sign(newToken, continuationTokenScope(deltaTokenScopeKind, h.metadata.EntitySetName, r))
 	deltaLink := response.BuildDeltaLink(r, newToken)
 
 	if err := response.WriteODataDeltaResponse(w, r, h.metadata.EntitySetName, entries, &deltaLink); err != nil {
@@ -93,3 +120,17 @@ func (h *EntityHandler) buildDeltaEntries(r *http.Request, events []trackchanges
 
 	return entries
 }
+
+// visibleChangeEvents returns the events of entities within the policy's row
+// filter. Changes to other entities are omitted entirely, removals included,
+// so that a delta response never reveals the keys of rows the caller may not
+// read.
+func (h *EntityHandler) visibleChangeEvents(events []trackchanges.ChangeEvent, rowFilter *preparedFilterNode) []trackchanges.ChangeEvent {
+	visible := make([]trackchanges.ChangeEvent, 0, len(events))
+	for _, event := range events {
+		if event.Data != nil && h.changeEventMatches(event, rowFilter) {
+			visible = append(visible, event)
+		}
+	}
+	return visible
+}
@@ -30,12 +30,17 @@ func (e *collectionRequestError) Error() string {
 // query pipeline. Implementations can customize individual phases such as parsing
 // query options, running hooks, fetching data, computing next links, and writing
 // the final response while sharing the common orchestration logic.
+//
+// StreamResponse is optional and may write the whole response straight from a
+// database cursor. It reports false when the query cannot be streamed, in which
+// case the regular fetch and write phases run.
 type collectionExecutionContext struct {
 	Metadata *metadata.EntityMetadata
 
 	ParseQueryOptions func() (*query.QueryOptions, error)
 	BeforeRead        func(*query.QueryOptions) ([]func(*gorm.DB) *gorm.DB, error)
 	CountFunc         func(*query.QueryOptions, []func(*gorm.DB) *gorm.DB) (*int64, error)
+	StreamResponse    func(*query.QueryOptions, []func(*gorm.DB) *gorm.DB, *int64) (bool, error)
 	FetchFunc         func(*query.QueryOptions, []func(*gorm.DB) *gorm.DB) (interface{}, error)
 	NextLinkFunc      func(*query.QueryOptions, interface{}) (*string, interface{}, error)
 	AfterRead         func(*query.QueryOptions, interface{}) (interface{}, bool, error)
@@ -72,6 +77,13 @@ func (h *EntityHandler) executeCollectionQuery(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	if ctx.StreamResponse != nil {
+		streamed, streamErr := ctx.StreamResponse(queryOptions, scopes, totalCount)
+		if !h.handleCollectionError(w, r, streamErr, http.StatusInternalServerError, ErrMsgDatabaseError) || streamed {
+			return
+		}
+	}
+
 	results, err := ctx.FetchFunc(queryOptions, scopes)
 	if !h.handleCollectionError(w, r, err, http.StatusInternalServerError, ErrMsgDatabaseError) {
 		return
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/auth"
 	"github.com/nlstn/go-odata/internal/fastscan"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/preference"
@@ -32,6 +33,11 @@ func (h *EntityHandler) handleGetCollection(w http.ResponseWriter, r *http.Reque
 		r = r.WithContext(ctx)
 	}
 
+	if h.isChangeStreamRequest(r) {
+		h.handleChangeStream(w, r)
+		return
+	}
+
 	// Check if there's an overwrite handler
 	if h.overwrite.hasGetCollection() {
 		h.handleGetCollectionOverwrite(w, r)
@@ -48,12 +54,15 @@ func (h *EntityHandler) handleGetCollection(w http.ResponseWriter, r *http.Reque
 	}
 
 	pref := preference.ParsePrefer(r)
+	r = h.withTemporalPeriods(withSearchRanking(r))
+	ctx = r.Context()
 
 	h.executeCollectionQuery(w, r, &collectionExecutionContext{
 		Metadata:          h.metadata,
 		ParseQueryOptions: h.parseCollectionQueryOptions(w, r, pref),
 		BeforeRead:        h.beforeReadCollection(r),
 		CountFunc:         h.collectionCountFunc(ctx),
+		StreamResponse:    h.streamCollectionFunc(w, r, pref),
 		FetchFunc:         h.fetchResultsWithTypeCast(r),
 		NextLinkFunc:      h.collectionNextLinkFunc(r),
 		AfterRead:         h.afterReadCollection(r),
@@ -82,6 +91,11 @@ func (h *EntityHandler) handleGetCollectionOverwrite(w http.ResponseWriter, r *h
 		}
 	}
 
+	if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+
 	// Handle delta token requests - these are not supported with overwrite handlers
 	// because delta tokens require change tracking at the data layer
 	if queryOptions.DeltaToken != nil {
@@ -123,6 +137,13 @@ func (h *EntityHandler) handleGetCollectionOverwrite(w http.ResponseWriter, r *h
 		result = &CollectionResult{Items: []interface{}{}}
 	}
 
+	items, err := h.expandVirtualResults(r, result.Items, queryOptions.Expand)
+	if err != nil {
+		h.writeHookError(w, r, err, http.StatusInternalServerError, "Error expanding collection")
+		return
+	}
+	result.Items = items
+
 	// Build the response
 	if err := h.collectionResponseWriter(w, r, pref)(queryOptions, result.Items, result.Count, nil); err != nil {
 		h.logger.Error("Error writing collection response", "error", err)
@@ -143,12 +164,25 @@ func (h *EntityHandler) parseCollectionQueryOptions(w http.ResponseWriter, r *ht
 			}
 		}
 
+		if err := validateTemporalOptions(queryOptions); err != nil {
+			return nil, &collectionRequestError{
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  ErrMsgInvalidQueryOptions,
+				Message:    err.Error(),
+			}
+		}
+
+		if queryOptions.Deleted != query.DeletedExclude &&
+			!h.authorizeDeletedAccess(w, r, buildEntityResourceDescriptor(h.metadata, "", nil), auth.OperationReadDeleted) {
+			return nil, errRequestHandled
+		}
+
 		if queryOptions.DeltaToken != nil {
 			h.handleDeltaCollection(w, r, *queryOptions.DeltaToken)
 			return nil, errRequestHandled
 		}
 
-		if err := h.validateSkipToken(queryOptions); err != nil {
+		if err := h.validateSkipToken(r, queryOptions); err != nil {
 			return nil, &collectionRequestError{
 				StatusCode: http.StatusBadRequest,
 				ErrorCode:  "Invalid $skiptoken",
@@ -164,6 +198,14 @@ func (h *EntityHandler) parseCollectionQueryOptions(w http.ResponseWriter, r *ht
 			}
 		}
 
+		if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+			return nil, &collectionRequestError{
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  ErrMsgQueryOptionRestricted,
+				Message:    err.Error(),
+			}
+		}
+
 		if pref.MaxPageSize != nil {
 			queryOptions = h.applyMaxPageSize(queryOptions, *pref.MaxPageSize)
 		}
@@ -171,14 +213,14 @@ func (h *EntityHandler) parseCollectionQueryOptions(w http.ResponseWriter, r *ht
 		// Apply default max top if no explicit $top is set
 		queryOptions = h.applyDefaultMaxTop(queryOptions)
 
-		if err := applyPolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", nil), queryOptions); err != nil {
+		if err := applyDatabasePolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", nil), queryOptions); err != nil {
 			return nil, &collectionRequestError{
 				StatusCode: http.StatusForbidden,
 				ErrorCode:  "Authorization failed",
 				Message:    err.Error(),
 			}
 		}
-		if err := applyPolicyFiltersToExpand(r, h.policy, h.metadata, queryOptions.Expand); err != nil {
+		if err := applyDatabasePolicyFiltersToExpand(r, h.policy, h.metadata, queryOptions.Expand); err != nil {
 			return nil, &collectionRequestError{
 				StatusCode: http.StatusForbidden,
 				ErrorCode:  "Authorization failed",
@@ -250,9 +292,13 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	// (before-read hooks, type-cast filters) cannot be reproduced in memory, so
 	// their presence forces the SQL path.
 	if len(scopes) == 0 && h.entityCache != nil && h.snapshotSupportsCollection(queryOptions) {
-		if snap, ok := h.cacheSnapshot(ctx); ok {
-			resultsPtr := h.queryCollectionSnapshot(snap, queryOptions, &modifiedOptions)
-			return h.postProcessCachedCollection(ctx, resultsPtr, queryOptions)
+		if filter, ok := h.snapshotFilter(ctx, queryOptions.Filter); ok {
+			if snap, ok := h.cacheSnapshot(ctx); ok {
+				snapshotOptions := *queryOptions
+				snapshotOptions.Filter = filter
+				resultsPtr := h.queryCollectionSnapshot(snap, &snapshotOptions, &modifiedOptions)
+				return h.postProcessCachedCollection(ctx, resultsPtr, queryOptions)
+			}
 		}
 	}
 
@@ -266,6 +312,17 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	if len(scopes) > 0 {
 		baseDB = baseDB.Scopes(scopes...)
 	}
+	// Temporal reads select versions from the history table; the expand lookups
+	// above keep reading the current related entities.
+	if queryOptions.Temporal != nil {
+		db = db.Scopes(h.temporalScope(queryOptions.Temporal))
+		if queryOptions.Temporal.IsPeriod() {
+			db = db.Scopes(h.temporalVersionOrder)
+		}
+	}
+	if queryOptions.Deleted != query.DeletedExclude {
+		db = db.Scopes(h.deletedScope(queryOptions.Deleted))
+	}
 
 	if queryOptions.SkipToken != nil {
 		db = h.applySkipTokenFilter(db, queryOptions)
@@ -349,17 +406,41 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	// NamingStrategy) to guarantee it matches the FROM clause that GORM will generate.
 	// h.metadata.TableName uses a simpler pluralization that can diverge from GORM's
 	// jinzhu/inflection for types without an explicit TableName() method.
+	// A search provider ranks the matches of $search; the query is restricted to
+	// their keys. Sorting by $search.score is done in memory, so $skip and $top
+	// are applied after the sort. More matches than one key restriction can
+	// carry are read one page of keys at a time and always sorted in memory.
+	ranking, err := h.rankSearch(ctx, queryOptions)
+	if err != nil {
+		return nil, err
+	}
+	pagedSearch := ranking != nil && ranking.paged()
+	scoreOrder := ranking != nil && (pagedSearch || query.HasSearchScoreOrder(queryOptions.OrderBy))
+	scoreTop := modifiedOptions.Top
+	if ranking != nil {
+		if !pagedSearch {
+			db = h.restrictToSearchHits(db, ranking.hits)
+		}
+		modifiedOptions.Search = ""
+	}
+	if scoreOrder {
+		if err := h.validateSearchScoreOrder(queryOptions.OrderBy); err != nil {
+			return nil, &collectionRequestError{
+				StatusCode: http.StatusBadRequest,
+				ErrorCode:  ErrMsgInvalidQueryOptions,
+				Message:    err.Error(),
+			}
+		}
+		modifiedOptions.OrderBy = nil
+		modifiedOptions.Skip = nil
+		modifiedOptions.Top = nil
+	} else {
+		modifiedOptions.OrderBy = withoutSearchScore(modifiedOptions.OrderBy)
+	}
+
 	if len(modifiedOptions.OrderBy) == 0 &&
 		!query.ShouldUseMapResults(queryOptions) && len(h.metadata.KeyProperties) > 0 {
-		gormTableName := gormCanonicalTableName(db, h.metadata)
-		for _, kp := range h.metadata.KeyProperties {
-			db = db.Order(clause.OrderByColumn{
-				Column: clause.Column{
-					Table: gormTableName,
-					Name:  kp.ColumnName,
-				},
-			})
-		}
+		db = h.orderByKeys(db)
 	}
 
 	// Apply query options with FTS support
@@ -440,8 +521,43 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	}
 	results := resultsPtr.Interface()
 
-	if err := fastscan.Find(db, results); err != nil {
-		return nil, err
+	// The validity periods of $from/$to versions are read by a copy of the
+	// final statement, which returns the versions in the same order.
+	var periods []temporalPeriod
+	if pagedSearch {
+		limit := searchReadLimit(queryOptions.OrderBy, queryOptions.Skip, scoreTop)
+		periods, err = h.findSearchHitPages(ctx, db, ranking, resultsPtr.Elem(), queryOptions.Temporal.IsPeriod(), limit)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var periodDB *gorm.DB
+		if queryOptions.Temporal.IsPeriod() {
+			periodDB = db.WithContext(ctx)
+		}
+		if err := fastscan.Find(db, results); err != nil {
+			return nil, err
+		}
+		if periodDB != nil {
+			periods, err = h.loadTemporalPeriods(periodDB, resultsPtr.Elem().Len())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if scoreOrder {
+		order := h.orderBySearchScore(resultsPtr.Elem(), h.searchOrder(queryOptions.OrderBy), ranking, queryOptions.Skip, scoreTop)
+		if periods != nil {
+			sorted := make([]temporalPeriod, len(order))
+			for i, index := range order {
+				sorted[i] = periods[index]
+			}
+			periods = sorted
+		}
+	}
+	if queryOptions.Temporal.IsPeriod() {
+		supplyTemporalPeriods(ctx, resultsPtr.Elem(), periods)
 	}
 
 	if len(queryOptions.Expand) > 0 {
@@ -453,7 +569,7 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	sliceValue := reflect.ValueOf(results).Elem().Interface()
 
 	// Only apply in-memory search if it wasn't already applied at database level
-	if queryOptions.Search != "" && !searchAppliedAtDB {
+	if queryOptions.Search != "" && ranking == nil && !searchAppliedAtDB {
 		sliceValue = query.ApplySearch(sliceValue, queryOptions.Search, h.metadata)
 	}
 
@@ -472,6 +588,21 @@ func (h *EntityHandler) fetchResults(ctx context.Context, queryOptions *query.Qu
 	return sliceValue, nil
 }
 
+// orderByKeys orders db by the entity's key columns, qualified with the
+// GORM-canonical table name.
+func (h *EntityHandler) orderByKeys(db *gorm.DB) *gorm.DB {
+	gormTableName := gormCanonicalTableName(db, h.metadata)
+	for _, kp := range h.metadata.KeyProperties {
+		db = db.Order(clause.OrderByColumn{
+			Column: clause.Column{
+				Table: gormTableName,
+				Name:  kp.ColumnName,
+			},
+		})
+	}
+	return db
+}
+
 func hasLeadingStructuralApplyTransformation(apply []query.ApplyTransformation) bool {
 	if len(apply) == 0 {
 		return false
@@ -1416,22 +1547,30 @@ func (h *EntityHandler) calculateNextLink(queryOptions *query.QueryOptions, slic
 	resultCount := reflect.ValueOf(sliceValue).Len()
 
 	if resultCount > *queryOptions.Top {
-		nextURL := buildNextLinkWithSkipToken(h.metadata, queryOptions, sliceValue, r)
-		if nextURL != nil {
-			return nextURL, true
+		// A $skiptoken addresses an entity by key, which does not identify one of
+		// several versions returned by $from/$to.
+		if !queryOptions.Temporal.IsPeriod() {
+			if nextURL := buildNextLinkWithSkipToken(h.metadata, queryOptions, sliceValue, r, h.tokenSigner); nextURL != nil {
+				return nextURL, true
+			}
 		}
+		return h.skipNextLink(queryOptions, r), true
+	}
 
-		currentSkip := 0
-		if queryOptions.Skip != nil {
-			currentSkip = *queryOptions.Skip
-		}
-		nextSkip := currentSkip + *queryOptions.Top
+	return nil, false
+}
 
-		fallbackURL := response.BuildNextLink(r, nextSkip)
-		return &fallbackURL, true
+// skipNextLink builds the $skip based next link used when no $skiptoken can be
+// derived from the page.
+func (h *EntityHandler) skipNextLink(queryOptions *query.QueryOptions, r *http.Request) *string {
+	currentSkip := 0
+	if queryOptions.Skip != nil {
+		currentSkip = *queryOptions.Skip
 	}
+	nextSkip := currentSkip + *queryOptions.Top
 
-	return nil, false
+	fallbackURL := response.BuildNextLink(r, nextSkip)
+	return &fallbackURL
 }
 
 func (h *EntityHandler) trimResults(sliceValue interface{}, maxLen int) interface{} {
@@ -1614,16 +1753,24 @@ func (h *EntityHandler) applySkipTokenFilter(db *gorm.DB, queryOptions *query.Qu
 	return db
 }
 
-func (h *EntityHandler) validateSkipToken(queryOptions *query.QueryOptions) error {
+// validateSkipToken checks that the $skiptoken can be decoded. When token signing is enabled
+// the signature is verified first and queryOptions.SkipToken is replaced with the verified
+// payload, so later stages decode it like an unsigned token.
+func (h *EntityHandler) validateSkipToken(r *http.Request, queryOptions *query.QueryOptions) error {
 	if queryOptions.SkipToken == nil {
 		return nil
 	}
 
-	_, err := skiptoken.Decode(*queryOptions.SkipToken)
+	payload, err := h.tokenSigner.Verify(*queryOptions.SkipToken, continuationTokenScope(skipTokenScopeKind, h.metadata.EntitySetName, r))
 	if err != nil {
+		return fmt.Errorf("invalid skiptoken: the token was not issued for this entity set and query, or it has been modified")
+	}
+
+	if _, err := skiptoken.Decode(payload); err != nil {
 		return fmt.Errorf("invalid skiptoken: %w", err)
 	}
 
+	queryOptions.SkipToken = &payload
 	return nil
 }
 
@@ -1678,7 +1825,7 @@ func (h *EntityHandler) validateComplexTypeUsage(queryOptions *query.QueryOption
 	}
 
 	for _, orderBy := range queryOptions.OrderBy {
-		if computedAliases[orderBy.Property] {
+		if computedAliases[orderBy.Property] || orderBy.Property == query.SearchScoreProperty {
 			continue
 		}
 
@@ -1699,6 +1846,9 @@ func (h *EntityHandler) validateComplexTypeUsage(queryOptions *query.QueryOption
 		if prop.IsComplexType {
 			return fmt.Errorf("ordering by complex type property '%s' is not supported", orderBy.Property)
 		}
+		if prop.IsCollection {
+			return fmt.Errorf("ordering by collection property '%s' is not supported", orderBy.Property)
+		}
 	}
 
 	return nil
@@ -1733,6 +1883,13 @@ func (h *EntityHandler) validateFilterForComplexTypes(filter *query.FilterExpres
 
 		// Allow lambda operators (any/all) on navigation properties - OData v4 spec 5.1.1.10
 		if filter.Operator == query.OpAny || filter.Operator == query.OpAll {
+			// Collections of primitive or complex values are expanded from their JSON column
+			if collProp := h.metadata.FindCollectionProperty(filter.Property); collProp != nil {
+				if err := validateCollectionLambdaPredicate(filter.Left, collProp); err != nil {
+					return err
+				}
+				goto validateChildren
+			}
 			// For lambda operators, the property is the navigation property
 			// The predicate is stored in filter.Left
 			prop, _, err := h.metadata.ResolvePropertyPath(filter.Property)
@@ -1757,6 +1914,9 @@ func (h *EntityHandler) validateFilterForComplexTypes(filter *query.FilterExpres
 		if strings.HasSuffix(filter.Property, "/$count") {
 			segments := strings.Split(filter.Property, "/")
 			if len(segments) == 2 {
+				if h.metadata.FindCollectionProperty(strings.TrimSpace(segments[0])) != nil {
+					goto validateChildren
+				}
 				navProp := h.metadata.FindNavigationProperty(strings.TrimSpace(segments[0]))
 				if navProp != nil && navProp.NavigationIsArray {
 					goto validateChildren
@@ -1808,6 +1968,9 @@ func (h *EntityHandler) validateFilterForComplexTypes(filter *query.FilterExpres
 			}
 			// Allow null comparison - SQL generation expands to per-field null checks
 		}
+		if prop.IsCollection {
+			return fmt.Errorf("collection property '%s' can only be filtered with any/all operators or $count", filter.Property)
+		}
 	}
 
 validateChildren:
@@ -1828,6 +1991,33 @@ validateChildren:
 	return nil
 }
 
+// validateCollectionLambdaPredicate validates a lambda predicate over a collection of primitive or
+// complex values. The range variable may be compared directly for primitive elements, while complex
+// elements are accessed through their properties (e.g., "a/City").
+func validateCollectionLambdaPredicate(filter *query.FilterExpression, collProp *metadata.PropertyMetadata) error {
+	if filter == nil {
+		return nil
+	}
+	if filter.Operator == query.OpAny || filter.Operator == query.OpAll {
+		return fmt.Errorf("nested lambda operators are not supported on collection property '%s'", collProp.JsonName)
+	}
+	if filter.Property != "" && !strings.HasPrefix(filter.Property, "_") {
+		name := strings.TrimPrefix(filter.Property, "$it/")
+		isComplex := len(collProp.ComplexTypeFields) > 0
+		if name == "$it" {
+			if isComplex {
+				return fmt.Errorf("elements of collection property '%s' must be accessed through their properties", collProp.JsonName)
+			}
+		} else if field, ok := collProp.ComplexTypeFields[name]; !ok || field.IsComplexType || field.IsNavigationProp {
+			return fmt.Errorf("property '%s' is not supported on elements of collection property '%s'", name, collProp.JsonName)
+		}
+	}
+	if err := validateCollectionLambdaPredicate(filter.Left, collProp); err != nil {
+		return err
+	}
+	return validateCollectionLambdaPredicate(filter.Right, collProp)
+}
+
 func (h *EntityHandler) getTotalCount(ctx context.Context, queryOptions *query.QueryOptions, w http.ResponseWriter, r *http.Request, scopes []func(*gorm.DB) *gorm.DB) *int64 {
 	if !queryOptions.Count {
 		return nil
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// encodeCollectionPropertiesInPlace replaces the JSON-decoded values of
+// collection properties of primitive or complex values in data with their
+// JSON array encoding.
+//
+// These properties are stored as a JSON column. POST and PUT re-marshal the
+// request into the entity struct, where the field's serializer or
+// driver.Valuer does the encoding, but GORM's Updates(map) used for PATCH
+// writes map values as they are. Each value is decoded into the Go field type
+// first so that elements of the wrong type are rejected.
+func (h *EntityHandler) encodeCollectionPropertiesInPlace(data map[string]interface{}) error {
+	for i := range h.metadata.Properties {
+		prop := &h.metadata.Properties[i]
+		if !prop.IsCollection {
+			continue
+		}
+
+		keys := []string{prop.JsonName}
+		if prop.Name != prop.JsonName {
+			keys = append(keys, prop.Name)
+		}
+		for _, key := range keys {
+			raw, exists := data[key]
+			if !exists || raw == nil {
+				continue
+			}
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return fmt.Errorf("property '%s': %w", prop.JsonName, err)
+			}
+			target := reflect.New(prop.Type)
+			if err := json.Unmarshal(encoded, target.Interface()); err != nil {
+				return fmt.Errorf("property '%s' has an invalid collection value: %w", prop.JsonName, err)
+			}
+			// Re-encode the typed value so the column holds the same JSON as
+			// an entity written through POST or PUT.
+			encoded, err = json.Marshal(target.Elem().Interface())
+			if err != nil {
+				return fmt.Errorf("property '%s': %w", prop.JsonName, err)
+			}
+			data[key] = string(encoded)
+		}
+	}
+	return nil
+}
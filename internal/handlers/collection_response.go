@@ -45,30 +45,13 @@ func (h *EntityHandler) collectionResponseWriter(w http.ResponseWriter, r *http.
 				}
 			}
 
+			token = h.tokenSigner.Sign(token, continuationTokenScope(deltaTokenScopeKind, h.metadata.EntitySetName, r))
 			link := response.BuildDeltaLink(r, token)
 			deltaLink = &link
 			pref.ApplyTrackChanges()
 		}
 
-		// Honor odata.allow-entityreferences: mark as applied when the preference is present.
-		// The service may return @odata.id references for repeated entities (per OData v4 §8.2.8.1).
-		if pref.AllowEntityReferences {
-			pref.ApplyAllowEntityReferences()
-		}
-
-		// Honor odata.include-annotations: mark as applied when a filter pattern was specified.
-		// The annotation filtering is applied during response serialization in the response package.
-		if pref.IncludeAnnotations != nil {
-			pref.ApplyIncludeAnnotations()
-		}
-
-		if pref.OmitValues != nil {
-			pref.ApplyOmitValues(true)
-		}
-
-		if applied := pref.GetPreferenceApplied(); applied != "" {
-			w.Header().Set(HeaderPreferenceApplied, applied)
-		}
+		applyCollectionPreferences(w, pref)
 
 		selectedNavProps := selectedNavigationProps(queryOptions.Select, h.metadata)
 
@@ -95,6 +78,30 @@ func (h *EntityHandler) collectionResponseWriter(w http.ResponseWriter, r *http.
 	}
 }
 
+// applyCollectionPreferences marks the read preferences honored by every
+// collection response as applied and sets the Preference-Applied header.
+func applyCollectionPreferences(w http.ResponseWriter, pref *preference.Preference) {
+	// Honor odata.allow-entityreferences: mark as applied when the preference is present.
+	// The service may return @odata.id references for repeated entities (per OData v4 §8.2.8.1).
+	if pref.AllowEntityReferences {
+		pref.ApplyAllowEntityReferences()
+	}
+
+	// Honor odata.include-annotations: mark as applied when a filter pattern was specified.
+	// The annotation filtering is applied during response serialization in the response package.
+	if pref.IncludeAnnotations != nil {
+		pref.ApplyIncludeAnnotations()
+	}
+
+	if pref.OmitValues != nil {
+		pref.ApplyOmitValues(true)
+	}
+
+	if applied := pref.GetPreferenceApplied(); applied != "" {
+		w.Header().Set(HeaderPreferenceApplied, applied)
+	}
+}
+
 func selectedNavigationProps(selectedProps []string, entityMetadata *metadata.EntityMetadata) []string {
 	if len(selectedProps) == 0 || entityMetadata == nil {
 		return nil
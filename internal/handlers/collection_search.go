@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/cache"
+	"github.com/nlstn/go-odata/internal/fastscan"
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/response"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxSearchHits is the number of ranked matches whose keys restrict one query.
+// The keys are sent as bind parameters and must stay within the parameter
+// limits of the databases. A search with more matches is read one page of keys
+// at a time and ordered in memory.
+const maxSearchHits = 5000
+
+// searchRankingState holds the memoized ranking of a request.
+type searchRankingState struct {
+	search  string
+	ranking *searchRanking
+	err     error
+	done    bool
+}
+
+// searchRanking is the result of a ranked $search. Its hits are unique and
+// ordered by descending score.
+type searchRanking struct {
+	hits   []query.SearchHit
+	scores map[string]float64
+	keyFn  cache.KeyFunc
+}
+
+// SetSearchProvider sets the provider that answers $search for the entity set.
+// Without one, the FTS manager ranks matches when the database supports
+// full-text search, and $search is otherwise evaluated in memory.
+func (h *EntityHandler) SetSearchProvider(provider query.SearchProvider) {
+	h.searchProvider = provider
+}
+
+// withSearchRanking prepares r's context to carry the ranking of its $search
+// and the @search.score annotations of the response.
+func withSearchRanking(r *http.Request) *http.Request {
+	if !strings.Contains(r.URL.RawQuery, "search=") {
+		return r
+	}
+	ctx := context.WithValue(r.Context(), searchRankingKey, &searchRankingState{})
+	return r.WithContext(response.WithSearchScores(ctx))
+}
+
+// activeSearchProvider returns the provider answering $search, or nil when it
+// is evaluated in memory.
+func (h *EntityHandler) activeSearchProvider() query.SearchProvider {
+	if h.searchProvider != nil {
+		return h.searchProvider
+	}
+	if h.ftsSearchProvider != nil && h.ftsManager.IsFTSAvailable() {
+		return h.ftsSearchProvider
+	}
+	return nil
+}
+
+// rankSearch runs the entity set's search provider for queryOptions.Search. It
+// returns nil when there is no $search or the expression has to be evaluated in
+// memory. The ranking is computed once per request and, when the response
+// writer was prepared with withSearchRanking, supplies its @search.score values.
+func (h *EntityHandler) rankSearch(ctx context.Context, queryOptions *query.QueryOptions) (*searchRanking, error) {
+	if queryOptions.Search == "" || h.db == nil || query.ShouldUseMapResults(queryOptions) {
+		return nil, nil
+	}
+	provider := h.activeSearchProvider()
+	if provider == nil {
+		return nil, nil
+	}
+
+	state, _ := ctx.Value(searchRankingKey).(*searchRankingState)
+	if state != nil && state.done && state.search == queryOptions.Search {
+		return state.ranking, state.err
+	}
+
+	req := query.NewSearchRequest(h.db.WithContext(ctx), h.metadata, queryOptions.Search, 0)
+	hits, err := provider.Search(ctx, req)
+	var ranking *searchRanking
+	switch {
+	case errors.Is(err, query.ErrSearchNotSupported):
+		err = nil
+	case err == nil:
+		ranking = h.newSearchRanking(hits)
+	}
+
+	if state != nil {
+		state.search, state.ranking, state.err, state.done = queryOptions.Search, ranking, err, true
+	}
+	if ranking != nil {
+		response.SetSearchScores(ctx, ranking.score)
+	}
+	return ranking, err
+}
+
+func (h *EntityHandler) newSearchRanking(hits []query.SearchHit) *searchRanking {
+	ranking := &searchRanking{
+		hits:   make([]query.SearchHit, 0, len(hits)),
+		scores: make(map[string]float64, len(hits)),
+		keyFn:  EntityCacheKeyFunc(h.metadata),
+	}
+	parts := make([]string, len(h.metadata.KeyProperties))
+	for _, hit := range hits {
+		if len(hit.Key) != len(parts) {
+			continue
+		}
+		for i, v := range hit.Key {
+			parts[i] = canonicalKeyComponent(v)
+		}
+		key := strings.Join(parts, keyComponentSeparator)
+		if _, seen := ranking.scores[key]; !seen {
+			ranking.scores[key] = hit.Score
+			ranking.hits = append(ranking.hits, hit)
+		}
+	}
+	sort.SliceStable(ranking.hits, func(i, j int) bool {
+		return ranking.hits[i].Score > ranking.hits[j].Score
+	})
+	return ranking
+}
+
+// paged reports whether the hits exceed what one key restriction can carry.
+func (rk *searchRanking) paged() bool {
+	return len(rk.hits) > maxSearchHits
+}
+
+// pages splits the hits into pages of at most maxSearchHits, best first.
+func (rk *searchRanking) pages() [][]query.SearchHit {
+	if len(rk.hits) == 0 {
+		return [][]query.SearchHit{nil}
+	}
+	pages := make([][]query.SearchHit, 0, (len(rk.hits)+maxSearchHits-1)/maxSearchHits)
+	for start := 0; start < len(rk.hits); start += maxSearchHits {
+		pages = append(pages, rk.hits[start:min(start+maxSearchHits, len(rk.hits))])
+	}
+	return pages
+}
+
+// score returns the relevance score of entity.
+func (rk *searchRanking) score(entity reflect.Value) (float64, bool) {
+	score, ok := rk.scores[rk.keyFn(entity)]
+	return score, ok
+}
+
+// restrictToSearchHits limits db to the matches with the keys of hits.
+func (h *EntityHandler) restrictToSearchHits(db *gorm.DB, hits []query.SearchHit) *gorm.DB {
+	if len(hits) == 0 {
+		return db.Where("1 = 0")
+	}
+	table := gormCanonicalTableName(db, h.metadata)
+	keyProps := h.metadata.KeyProperties
+	if len(keyProps) == 1 {
+		values := make([]interface{}, len(hits))
+		for i, hit := range hits {
+			values[i] = hit.Key[0]
+		}
+		return db.Where(clause.IN{Column: clause.Column{Table: table, Name: keyProps[0].ColumnName}, Values: values})
+	}
+
+	matches := make([]clause.Expression, 0, len(hits))
+	for _, hit := range hits {
+		if len(hit.Key) != len(keyProps) {
+			continue
+		}
+		conds := make([]clause.Expression, len(keyProps))
+		for i, kp := range keyProps {
+			conds[i] = clause.Eq{Column: clause.Column{Table: table, Name: kp.ColumnName}, Value: hit.Key[i]}
+		}
+		matches = append(matches, clause.And(conds...))
+	}
+	return db.Where(clause.Or(matches...))
+}
+
+// withoutSearchScore returns orderBy without its $search.score items.
+func withoutSearchScore(orderBy []query.OrderByItem) []query.OrderByItem {
+	if !query.HasSearchScoreOrder(orderBy) {
+		return orderBy
+	}
+	items := make([]query.OrderByItem, 0, len(orderBy))
+	for _, item := range orderBy {
+		if item.Property != query.SearchScoreProperty {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateSearchScoreOrder checks that the $orderby of a ranked $search can be
+// evaluated in memory: every item other than $search.score must be a scalar
+// structural property.
+func (h *EntityHandler) validateSearchScoreOrder(orderBy []query.OrderByItem) error {
+	for _, item := range orderBy {
+		if item.Property == query.SearchScoreProperty {
+			continue
+		}
+		if _, ok := h.resolveScalarProperty(item.Property); !ok {
+			if query.HasSearchScoreOrder(orderBy) {
+				return fmt.Errorf("property '%s' cannot be combined with $search.score in $orderby", item.Property)
+			}
+			return fmt.Errorf("property '%s' cannot be used in $orderby when $search matches more than %d entities", item.Property, maxSearchHits)
+		}
+	}
+	return nil
+}
+
+// searchOrder returns the in-memory ordering of a ranked $search: orderBy, or
+// the key properties when the request has no $orderby, as the database would
+// order it.
+func (h *EntityHandler) searchOrder(orderBy []query.OrderByItem) []query.OrderByItem {
+	if len(orderBy) > 0 {
+		return orderBy
+	}
+	items := make([]query.OrderByItem, 0, len(h.metadata.KeyProperties))
+	for _, kp := range h.metadata.KeyProperties {
+		items = append(items, query.OrderByItem{Property: kp.Name})
+	}
+	return items
+}
+
+// searchReadLimit returns how many matches a paged search has to read: $skip
+// plus top when the matches are ordered by descending score alone, because the
+// pages are read best first, and zero for all of them otherwise.
+func searchReadLimit(orderBy []query.OrderByItem, skip, top *int) int {
+	if top == nil || len(orderBy) != 1 || orderBy[0].Property != query.SearchScoreProperty || !orderBy[0].Descending {
+		return 0
+	}
+	limit := *top
+	if skip != nil && *skip > 0 {
+		limit += *skip
+	}
+	return limit
+}
+
+// findSearchHitPages reads the matches of a paged ranking into results, one
+// page of keys at a time. It stops once limit matches were read unless limit
+// is zero, and returns the validity periods of the versions when withPeriods
+// is set.
+func (h *EntityHandler) findSearchHitPages(ctx context.Context, db *gorm.DB, ranking *searchRanking, results reflect.Value, withPeriods bool, limit int) ([]temporalPeriod, error) {
+	var periods []temporalPeriod
+	session := db.Session(&gorm.Session{})
+	for _, hits := range ranking.pages() {
+		pageDB := h.restrictToSearchHits(session, hits)
+		var periodDB *gorm.DB
+		if withPeriods {
+			periodDB = pageDB.WithContext(ctx)
+		}
+		page := reflect.New(results.Type())
+		if err := fastscan.Find(pageDB, page.Interface()); err != nil {
+			return nil, err
+		}
+		if periodDB != nil {
+			pagePeriods, err := h.loadTemporalPeriods(periodDB, page.Elem().Len())
+			if err != nil {
+				return nil, err
+			}
+			periods = append(periods, pagePeriods...)
+		}
+		results.Set(reflect.AppendSlice(results, page.Elem()))
+		if limit > 0 && results.Len() >= limit {
+			break
+		}
+	}
+	return periods, nil
+}
+
+// orderBySearchScore sorts the matches of a ranked $search by orderBy and
+// applies $skip and top, which the query could not apply because the database
+// does not know the scores. It returns the original index of every entity in
+// the sorted page.
+func (h *EntityHandler) orderBySearchScore(results reflect.Value, orderBy []query.OrderByItem, ranking *searchRanking, skip, top *int) []int {
+	type sortKey struct {
+		prop       *metadata.PropertyMetadata
+		descending bool
+	}
+	keys := make([]sortKey, 0, len(orderBy))
+	for _, item := range orderBy {
+		if item.Property == query.SearchScoreProperty {
+			keys = append(keys, sortKey{descending: item.Descending})
+			continue
+		}
+		if prop, ok := h.resolveScalarProperty(item.Property); ok {
+			keys = append(keys, sortKey{prop: prop, descending: item.Descending})
+		}
+	}
+
+	order := make([]int, results.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		left, right := results.Index(order[i]), results.Index(order[j])
+		for _, key := range keys {
+			var cmp int
+			if key.prop == nil {
+				leftScore, _ := ranking.score(left)
+				rightScore, _ := ranking.score(right)
+				cmp = compareMapValues(leftScore, true, rightScore, true)
+			} else {
+				left := normalizeCacheScalar(entityFieldValue(left, key.prop))
+				right := normalizeCacheScalar(entityFieldValue(right, key.prop))
+				cmp = compareMapValues(left, left != nil, right, right != nil)
+			}
+			if cmp == 0 {
+				continue
+			}
+			if key.descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	start, end := 0, len(order)
+	if skip != nil && *skip > 0 {
+		start = min(*skip, end)
+	}
+	if top != nil && *top >= 0 && start+*top < end {
+		end = start + *top
+	}
+	order = order[start:end]
+	page := reflect.MakeSlice(results.Type(), len(order), len(order))
+	for i, index := range order {
+		page.Index(i).Set(results.Index(index))
+	}
+	results.Set(page)
+	return order
+}
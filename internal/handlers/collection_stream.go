@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/trackchanges"
+)
+
+// DefaultChangeStreamHeartbeat is the interval between keep-alive comments on
+// an idle change stream.
+const DefaultChangeStreamHeartbeat = 15 * time.Second
+
+// EnableChangeStream lets clients subscribe to live changes of the entity set
+// by requesting the collection with Accept: text/event-stream. Change tracking
+// must already be enabled. A non-positive heartbeat uses DefaultChangeStreamHeartbeat.
+func (h *EntityHandler) EnableChangeStream(heartbeat time.Duration) error {
+	if !h.supportsTrackChanges() {
+		return fmt.Errorf("change tracking must be enabled for entity set '%s' before enabling change streams", h.metadata.EntitySetName)
+	}
+	if heartbeat <= 0 {
+		heartbeat = DefaultChangeStreamHeartbeat
+	}
+	h.changeStreamHeartbeat = heartbeat
+	return nil
+}
+
+// isChangeStreamRequest reports whether r subscribes to the entity set's change stream.
+func (h *EntityHandler) isChangeStreamRequest(r *http.Request) bool {
+	return h.changeStreamHeartbeat > 0 && r.Method == http.MethodGet && response.AcceptsEventStream(r)
+}
+
+// handleChangeStream streams change tracking entries for the entity set as
+// Server-Sent Events until the client disconnects. Each event carries one delta
+// entry and the delta token that resumes the stream after it as the event ID,
+// so clients can reconnect with Last-Event-ID (or $deltatoken) without losing
+// changes. $filter restricts added and updated entries; updated entities that no
+// longer match are reported as removed with reason "changed".
+func (h *EntityHandler) handleChangeStream(w http.ResponseWriter, r *http.Request) {
+	if !h.supportsTrackChanges() {
+		WriteError(w, r, http.StatusNotImplemented, ErrMsgNotImplemented,
+			"Change tracking is not enabled for this entity set")
+		return
+	}
+
+	queryOptions, err := h.parseQueryOptionsByNegotiatedVersion(r, h.metadata, h.getParserConfig())
+	if err != nil {
+		h.writeRequestError(w, r, err, http.StatusBadRequest, ErrMsgInvalidQueryOptions)
+		return
+	}
+	if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+	if !changeStreamOptionsSupported(queryOptions) {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
+			"Change streams support only the $filter, $select and $deltatoken query options")
+		return
+	}
+	if err := applyPolicyFilter(r, h.policy, buildEntityResourceDescriptor(h.metadata, "", nil), queryOptions); err != nil {
+		WriteError(w, r, http.StatusForbidden, "Authorization failed", err.Error())
+		return
+	}
+	if !h.filterSupported(queryOptions.Filter) {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
+			"The $filter expression is not supported for change streams; use comparisons, in, contains, startswith or endswith on scalar properties")
+		return
+	}
+	rowFilter, err := h.rowFilter(r.Context())
+	if err != nil || !h.filterSupported(rowFilter) {
+		WriteError(w, r, http.StatusForbidden, "Authorization failed",
+			"The row filter of the authorization policy cannot be applied to change streams")
+		return
+	}
+
+	scope := continuationTokenScope(deltaTokenScopeKind, h.metadata.EntitySetName, r)
+	token, ok := h.changeStreamStartToken(w, r, queryOptions, scope)
+	if !ok {
+		return
+	}
+
+	// Subscribe before reading history so no change recorded in between is missed.
+	notify, unsubscribe := h.tracker.Subscribe(h.metadata.EntitySetName)
+	defer unsubscribe()
+
+	stream := &changeStream{
+		handler:   h,
+		request:   r,
+		writer:    w,
+		filter:    h.prepareFilter(queryOptions.Filter),
+		hasFilter: queryOptions.Filter != nil,
+		rowFilter: h.prepareFilter(rowFilter),
+		selected:  h.changeStreamSelection(queryOptions.Select),
+		scope:     scope,
+	}
+
+	response.WriteEventStreamHeaders(w)
+
+	heartbeat := time.NewTicker(h.changeStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		next, err := stream.emitSince(token)
+		if err != nil {
+			h.logger.Debug("Change stream closed", "entitySet", h.metadata.EntitySetName, "error", err)
+			return
+		}
+		token = next
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-notify:
+		case <-heartbeat.C:
+			if err := response.WriteEventStreamComment(w, "keep-alive"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// changeStreamStartToken resolves the position the stream starts from:
+// Last-Event-ID when reconnecting, otherwise $deltatoken, otherwise the
+// current end of the change history. It writes an error response and returns
+// false when the supplied token is invalid or has expired.
+func (h *EntityHandler) changeStreamStartToken(w http.ResponseWriter, r *http.Request, queryOptions *query.QueryOptions, scope string) (string, bool) {
+	supplied := r.Header.Get("Last-Event-ID")
+	if supplied == "" && queryOptions.DeltaToken != nil {
+		supplied = *queryOptions.DeltaToken
+	}
+	if supplied == "" {
+		token, err := h.tracker.CurrentToken(h.metadata.EntitySetName)
+		if err != nil {
+			WriteError(w, r, http.StatusInternalServerError, ErrMsgInternalError, err.Error())
+			return "", false
+		}
+		return token, true
+	}
+
+	token, err := h.tokenSigner.Verify(supplied, scope)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
+			"Invalid resume token: the token was not issued for this entity set and query, or it has been modified")
+		return "", false
+	}
+	entitySet, err := h.tracker.EntitySetFromToken(token)
+	if err != nil || entitySet != h.metadata.EntitySetName {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions,
+			"Resume token does not match the requested entity set")
+		return "", false
+	}
+	if err := h.tracker.CheckToken(token); err != nil {
+		if errors.Is(err, trackchanges.ErrTokenExpired) {
+			WriteError(w, r, http.StatusGone, ErrMsgDeltaTokenExpired, ErrDetailDeltaTokenExpired)
+		} else {
+			WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidQueryOptions, err.Error())
+		}
+		return "", false
+	}
+	return token, true
+}
+
+// changeStreamSelection maps $select to the JSON property names kept in
+// streamed entries. A nil result keeps every property.
+func (h *EntityHandler) changeStreamSelection(selectItems []string) map[string]bool {
+	if len(selectItems) == 0 {
+		return nil
+	}
+	selected := make(map[string]bool, len(selectItems)+len(h.metadata.KeyProperties))
+	for _, item := range selectItems {
+		if item == "*" {
+			return nil
+		}
+		if prop := h.metadata.FindProperty(item); prop != nil {
+			selected[prop.JsonName] = true
+		}
+	}
+	for _, key := range h.metadata.KeyProperties {
+		selected[key.JsonName] = true
+	}
+	return selected
+}
+
+func changeStreamOptionsSupported(queryOptions *query.QueryOptions) bool {
+	return len(queryOptions.Expand) == 0 &&
+		len(queryOptions.OrderBy) == 0 &&
+		queryOptions.Top == nil &&
+		queryOptions.Skip == nil &&
+		queryOptions.SkipToken == nil &&
+		!queryOptions.Count &&
+		len(queryOptions.Apply) == 0 &&
+		queryOptions.Search == "" &&
+		queryOptions.Compute == nil
+}
+
+// changeStream writes tracked changes of one entity set to an event stream.
+type changeStream struct {
+	handler   *EntityHandler
+	request   *http.Request
+	writer    http.ResponseWriter
+	filter    *preparedFilterNode
+	hasFilter bool
+	// rowFilter hides every change to entities outside the policy's row filter.
+	rowFilter *preparedFilterNode
+	selected  map[string]bool
+	scope     string
+}
+
+// emitSince writes one event per change recorded after token and returns the
+// token to continue from.
+func (s *changeStream) emitSince(token string) (string, error) {
+	h := s.handler
+	events, next, err := h.tracker.ChangesSince(token)
+	if err != nil {
+		return "", err
+	}
+
+	for _, event := range events {
+		entry, ok := s.entryFor(event)
+		if !ok {
+			continue
+		}
+		eventToken, err := h.tracker.TokenForVersion(h.metadata.EntitySetName, event.Version)
+		if err != nil {
+			return "", err
+		}
+		id := h.tokenSigner.Sign(eventToken, s.scope)
+		if err := response.WriteDeltaEvent(s.writer, s.request, h.metadata.EntitySetName, id, []map[string]interface{}{entry}); err != nil {
+			return "", err
+		}
+	}
+	return next, nil
+}
+
+// entryFor builds the delta entry for event, applying $filter and $select. It
+// returns false when the change is not visible to the subscriber.
+func (s *changeStream) entryFor(event trackchanges.ChangeEvent) (map[string]interface{}, bool) {
+	h := s.handler
+	if s.rowFilter != nil && (event.Data == nil || !h.changeEventMatches(event, s.rowFilter)) {
+		return nil, false
+	}
+	if event.Type == trackchanges.ChangeTypeDeleted || !s.hasFilter {
+		return s.project(h.buildDeltaEntries(s.request, []trackchanges.ChangeEvent{event})[0]), true
+	}
+
+	if h.changeEventMatches(event, s.filter) {
+		return s.project(h.buildDeltaEntries(s.request, []trackchanges.ChangeEvent{event})[0]), true
+	}
+	if event.Type == trackchanges.ChangeTypeAdded {
+		return nil, false
+	}
+
+	// The entity was updated out of the filtered set.
+	removed := event
+	removed.Type = trackchanges.ChangeTypeDeleted
+	entry := h.buildDeltaEntries(s.request, []trackchanges.ChangeEvent{removed})[0]
+	entry["@odata.removed"] = map[string]string{"reason": "changed"}
+	return entry, true
+}
+
+func (s *changeStream) project(entry map[string]interface{}) map[string]interface{} {
+	if s.selected == nil {
+		return entry
+	}
+	for name := range entry {
+		if len(name) > 0 && name[0] == '@' {
+			continue
+		}
+		if !s.selected[name] {
+			delete(entry, name)
+		}
+	}
+	return entry
+}
+
+// changeEventMatches evaluates a prepared filter against the entity state
+// recorded in event, using the same evaluator as the entity snapshot cache.
+func (h *EntityHandler) changeEventMatches(event trackchanges.ChangeEvent, filter *preparedFilterNode) bool {
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return false
+	}
+	entity := reflect.New(h.metadata.EntityType)
+	if err := json.Unmarshal(raw, entity.Interface()); err != nil {
+		return false
+	}
+	return evalPreparedFilter(EntityCacheNormalizeFunc(h.metadata)(entity), filter)
+}
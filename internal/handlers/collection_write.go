@@ -124,6 +124,11 @@ func (h *EntityHandler) handlePostEntity(w http.ResponseWriter, r *http.Request)
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.validateVocabularyConstraints(requestData); err != nil {
+			h.writeRequestError(w, r, err, http.StatusBadRequest, ErrMsgValidationFailed)
+			return newTransactionHandledError(err)
+		}
+
 		if err := h.validateReferentialConstraints(ctx, tx, requestData); err != nil {
 			WriteError(w, r, http.StatusBadRequest, "Invalid reference", err.Error())
 			return newTransactionHandledError(err)
@@ -145,12 +150,19 @@ func (h *EntityHandler) handlePostEntity(w http.ResponseWriter, r *http.Request)
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.ensureRowVisible(w, r, tx, entity); err != nil {
+			return err
+		}
+
 		if err := h.callAfterCreate(entity, hookReq); err != nil {
 			h.logger.Error("AfterCreate hook failed", "error", err)
 		}
 
 		changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeAdded})
-		return nil
+		if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, changeEvents)
 	}); err != nil {
 		if isTransactionHandled(err) {
 			return
@@ -162,7 +174,7 @@ func (h *EntityHandler) handlePostEntity(w http.ResponseWriter, r *http.Request)
 	h.finalizeChangeEvents(ctx, changeEvents)
 
 	// Invalidate the entity cache so that subsequent reads reflect the new entity.
-	h.invalidateCache()
+	h.invalidateCache(ctx)
 
 	location := h.buildEntityLocation(r, entity)
 	w.Header().Set("Location", location)
@@ -238,12 +250,19 @@ func (h *EntityHandler) handlePostMediaEntity(w http.ResponseWriter, r *http.Req
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.ensureRowVisible(w, r, tx, entity); err != nil {
+			return err
+		}
+
 		if err := h.callAfterCreate(entity, hookReq); err != nil {
 			h.logger.Error("AfterCreate hook failed", "error", err)
 		}
 
 		changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeAdded})
-		return nil
+		if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, changeEvents)
 	}); err != nil {
 		if isTransactionHandled(err) {
 			return
@@ -255,7 +274,7 @@ func (h *EntityHandler) handlePostMediaEntity(w http.ResponseWriter, r *http.Req
 	h.finalizeChangeEvents(ctx, changeEvents)
 
 	// Invalidate the entity cache so that subsequent reads reflect the new entity.
-	h.invalidateCache()
+	h.invalidateCache(ctx)
 
 	location := h.buildEntityLocation(r, entity)
 	w.Header().Set("Location", location)
@@ -605,9 +624,11 @@ func (h *EntityHandler) handlePostEntityOverwrite(w http.ResponseWriter, r *http
 	// Call the overwrite handler
 	result, err := h.overwrite.create(ctx, entity)
 	if err != nil {
-		WriteError(w, r, http.StatusInternalServerError, "Error creating entity", err.Error())
+		_, status, message, details := extractHookErrorDetails(err, http.StatusInternalServerError, "Error creating entity")
+		WriteError(w, r, status, message, details)
 		return
 	}
+	h.invalidateCache(r.Context())
 
 	if result == nil {
 		WriteError(w, r, http.StatusInternalServerError, "Error creating entity", "handler returned nil entity")
@@ -127,7 +127,7 @@ func (h *EntityHandler) fetchComplexPropertyValue(w http.ResponseWriter, r *http
 		db = h.db
 	} else {
 		// For regular entities, build the key query
-		db, err = h.buildKeyQuery(h.db, entityKey)
+		db, err = h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 		if err != nil {
 			if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 				h.logger.Error("Error writing error response", "error", writeErr)
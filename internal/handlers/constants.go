@@ -22,6 +22,7 @@ const (
 const (
 	ErrMsgMethodNotAllowed       = "Method not allowed"
 	ErrMsgInvalidQueryOptions    = "Invalid query options"
+	ErrMsgQueryOptionRestricted  = "Query option restricted"
 	ErrMsgDatabaseError          = "Database error"
 	ErrMsgInvalidRequestBody     = "Invalid request body"
 	ErrMsgInvalidKey             = "Invalid key"
@@ -36,6 +37,8 @@ const (
 	ErrMsgConflict               = "Conflict"
 	ErrDetailDuplicateKey        = "An entity with the same key already exists."
 	ErrDetailForeignKeyViolation = "The entity cannot be deleted because it is still referenced by related entities."
+	ErrMsgDeltaTokenExpired      = "Delta token expired"
+	ErrDetailDeltaTokenExpired   = "The changes since this delta token are no longer retained. Request the entity set again to obtain a new delta link."
 )
 
 // Error detail format constants
@@ -14,6 +14,7 @@ const (
 	typeCastKey          contextKey = "odata_type_cast"
 	transactionDBKey     contextKey = "odata_transaction_db"
 	transactionEventsKey contextKey = "odata_transaction_events"
+	searchRankingKey     contextKey = "odata_search_ranking"
 )
 
 // WithTypeCast adds a type cast filter to the request context
@@ -2,44 +2,334 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/etag"
 	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/rowsecurity"
+	"github.com/nlstn/go-odata/internal/trackchanges"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
+// deltaAnnotationSuffix marks a collection-valued navigation property sent as a delta payload
+// ("Lines@delta": [...]), where only the listed changes are applied.
+const deltaAnnotationSuffix = "@delta"
+
+// deepUpdateError is a deep update failure that calls for a status other than 400 Bad Request,
+// such as a refused authorization or a failed ETag precondition of a related entity.
+type deepUpdateError struct {
+	status  int
+	message string
+	err     error
+}
+
+func (e *deepUpdateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deepUpdateError) Unwrap() error {
+	return e.err
+}
+
+// writeDeepUpdateError writes the response for a failed deep update.
+func (h *EntityHandler) writeDeepUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	status, message := http.StatusBadRequest, "Failed to deep update related entity"
+	var deepErr *deepUpdateError
+	if errors.As(err, &deepErr) {
+		status, message = deepErr.status, deepErr.message
+	}
+	h.writeHookError(w, r, err, status, message)
+}
+
+// deepUpdateWriter writes the related entities of a deep update inside the transaction of the
+// update of their parent. Every related entity is written through the handler of its entity set,
+// so that its authorization policy, hooks, ETag, temporal history and outbox apply as they do for
+// a direct request. The change events are collected and finalized once the transaction commits.
+type deepUpdateWriter struct {
+	ctx    context.Context
+	tx     *gorm.DB
+	r      *http.Request
+	events []pendingChangeEvent
+}
+
+// newDeepUpdateWriter returns a writer for the nested writes of a deep update running in tx.
+// hookReq is the request passed to the entity hooks.
+func newDeepUpdateWriter(ctx context.Context, tx *gorm.DB, hookReq *http.Request) *deepUpdateWriter {
+	return &deepUpdateWriter{ctx: ctx, tx: tx, r: hookReq}
+}
+
+// finalize records the change events of the related entities and invalidates the caches of
+// their entity sets. It is called after the transaction committed.
+func (d *deepUpdateWriter) finalize(ctx context.Context) {
+	if d == nil || len(d.events) == 0 {
+		return
+	}
+	handlers := make([]*EntityHandler, 0, 1)
+	events := make(map[*EntityHandler][]changeEvent)
+	for _, evt := range d.events {
+		if _, seen := events[evt.handler]; !seen {
+			handlers = append(handlers, evt.handler)
+		}
+		events[evt.handler] = append(events[evt.handler], evt.event)
+	}
+	for _, handler := range handlers {
+		handler.finalizeChangeEvents(ctx, events[handler])
+		handler.invalidateCache(ctx)
+	}
+}
+
+// record writes the temporal history and outbox events of a nested write and keeps its change
+// event for finalize.
+func (d *deepUpdateWriter) record(handler *EntityHandler, entity interface{}, changeType trackchanges.ChangeType) error {
+	events := []changeEvent{{entity: entity, changeType: changeType}}
+	if err := handler.appendTemporalHistory(d.tx, events); err != nil {
+		return err
+	}
+	if err := handler.appendOutboxEvents(d.tx, events); err != nil {
+		return err
+	}
+	d.events = append(d.events, pendingChangeEvent{handler: handler, event: events[0]})
+	return nil
+}
+
+// create inserts a related entity through handler.
+func (d *deepUpdateWriter) create(handler *EntityHandler, entity interface{}) error {
+	if err := handler.authorizeDeepUpdate(d.r, buildEntityResourceDescriptor(handler.metadata, "", nil), auth.OperationCreate); err != nil {
+		return err
+	}
+	if err := handler.initializeEntityKeys(d.ctx, entity); err != nil {
+		return err
+	}
+	if err := handler.callBeforeCreate(entity, d.r); err != nil {
+		return &deepUpdateError{status: http.StatusForbidden, message: "Authorization failed", err: err}
+	}
+	if err := d.tx.Create(entity).Error; err != nil {
+		return fmt.Errorf("failed to create related entity: %w", err)
+	}
+	if err := d.ensureRowVisible(handler, entity); err != nil {
+		return err
+	}
+	if err := handler.callAfterCreate(entity, d.r); err != nil {
+		handler.logger.Error("AfterCreate hook failed", "error", err)
+	}
+	return d.record(handler, entity, trackchanges.ChangeTypeAdded)
+}
+
+// update applies data to a related entity through handler. ifMatch is the ETag the client sent
+// for the entity, if any.
+func (d *deepUpdateWriter) update(handler *EntityHandler, entity interface{}, data map[string]interface{}, ifMatch string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := handler.authorizeDeepUpdate(d.r, buildEntityResourceDescriptorWithEntity(handler.metadata, "", entity, nil), auth.OperationUpdate); err != nil {
+		return err
+	}
+	if handler.metadata.ETagProperty != nil && !etag.Match(ifMatch, etag.Generate(entity, handler.metadata)) {
+		return &deepUpdateError{status: http.StatusPreconditionFailed, message: ErrMsgPreconditionFailed, err: errors.New(ErrDetailPreconditionFailed)}
+	}
+	if err := handler.callBeforeUpdate(entity, d.r); err != nil {
+		return &deepUpdateError{status: http.StatusForbidden, message: "Authorization failed", err: err}
+	}
+	if handler.metadata.ETagProperty != nil {
+		handler.incrementETagProperty(entity)
+		if etagField := reflect.Indirect(reflect.ValueOf(entity)).FieldByName(handler.metadata.ETagProperty.FieldName); etagField.IsValid() {
+			data[handler.metadata.ETagProperty.ColumnName] = etagField.Interface()
+		}
+	}
+	if err := d.tx.Model(entity).Updates(data).Error; err != nil {
+		return fmt.Errorf("failed to update related entity: %w", err)
+	}
+	if err := d.ensureRowVisible(handler, entity); err != nil {
+		return err
+	}
+	if err := handler.callAfterUpdate(entity, d.r); err != nil {
+		handler.logger.Error("AfterUpdate hook failed", "error", err)
+	}
+	if err := d.tx.First(entity).Error; err != nil {
+		return fmt.Errorf("failed to refresh related entity: %w", err)
+	}
+	return d.record(handler, entity, trackchanges.ChangeTypeUpdated)
+}
+
+// delete removes a related entity through handler.
+func (d *deepUpdateWriter) delete(handler *EntityHandler, entity interface{}) error {
+	if err := handler.authorizeDeepUpdate(d.r, buildEntityResourceDescriptorWithEntity(handler.metadata, "", entity, nil), auth.OperationDelete); err != nil {
+		return err
+	}
+	if err := handler.callBeforeDelete(entity, d.r); err != nil {
+		return &deepUpdateError{status: http.StatusForbidden, message: "Authorization failed", err: err}
+	}
+	if err := handler.deleteEntity(d.tx, entity); err != nil {
+		return fmt.Errorf("failed to delete related entity: %w", err)
+	}
+	if err := handler.callAfterDelete(entity, d.r); err != nil {
+		handler.logger.Error("AfterDelete hook failed", "error", err)
+	}
+	return d.record(handler, entity, trackchanges.ChangeTypeDeleted)
+}
+
+// ensureRowVisible refuses a nested write that leaves the related entity outside the rows the
+// caller may read, like EntityHandler.ensureRowVisible does for a direct write. The refusal is
+// returned as a deepUpdateError.
+func (d *deepUpdateWriter) ensureRowVisible(handler *EntityHandler, entity interface{}) error {
+	filter, err := handler.rowFilter(d.ctx)
+	if err != nil {
+		return &deepUpdateError{status: http.StatusForbidden, message: "Authorization failed", err: err}
+	}
+	if filter == nil {
+		return nil
+	}
+	visible, err := handler.rowExists(d.tx.Session(&gorm.Session{NewDB: true, Context: d.ctx}), entity)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return &deepUpdateError{status: http.StatusForbidden, message: "Forbidden",
+			err: fmt.Errorf("related entity '%s' would not be accessible under the authorization policy", handler.metadata.EntityName)}
+	}
+	return nil
+}
+
+// authorizeDeepUpdate checks a nested write against the handler's policy. Unlike
+// authorizeRequest it writes no response; the refusal is returned as a deepUpdateError.
+func (h *EntityHandler) authorizeDeepUpdate(r *http.Request, resource auth.ResourceDescriptor, operation auth.Operation) error {
+	if h.policy == nil {
+		return nil
+	}
+	decision := h.policy.Authorize(buildAuthContext(r), resource, operation)
+	if decision.Allowed {
+		return nil
+	}
+	status, message := authorizationDenial(r)
+	reason := decision.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("access to entity set '%s' denied", h.metadata.EntitySetName)
+	}
+	return &deepUpdateError{status: status, message: message, err: errors.New(reason)}
+}
+
+// relatedHandler returns the handler that writes entities of the entity set described by
+// targetMeta. Entity sets without a registered handler are not exposed and cannot be
+// written.
+func (h *EntityHandler) relatedHandler(targetMeta *metadata.EntityMetadata) (*EntityHandler, error) {
+	if targetMeta.IsVirtual {
+		return nil, fmt.Errorf("entity set '%s' is virtual and cannot be written by a deep update", targetMeta.EntitySetName)
+	}
+	handler := h.entityHandlers[targetMeta.EntitySetName]
+	if handler == nil {
+		return nil, fmt.Errorf("entity set '%s' is not exposed by the service and cannot be written by a deep update", targetMeta.EntitySetName)
+	}
+	return handler, nil
+}
+
+// prepareDeepUpdateData validates the properties of a nested entry the way a direct request to
+// the entity set validates them, and converts them for storage.
+func (h *EntityHandler) prepareDeepUpdateData(data map[string]interface{}, create bool) error {
+	if err := h.decodeBinaryPropertiesInPlace(data); err != nil {
+		return err
+	}
+	if create {
+		if err := h.validateRequiredProperties(data); err != nil {
+			return err
+		}
+	} else if err := h.validateDataTypes(data); err != nil {
+		return err
+	}
+	if err := h.validateRequiredFieldsNotNull(data); err != nil {
+		return err
+	}
+	if err := h.validateMaxLength(data); err != nil {
+		return err
+	}
+	if err := h.validateVocabularyConstraints(data); err != nil {
+		return err
+	}
+	if create {
+		return nil
+	}
+	if err := h.encodeCollectionPropertiesInPlace(data); err != nil {
+		return err
+	}
+	return h.decodeSpatialPropertiesInPlace(data)
+}
+
 // processDeepUpdateNavigationProperties processes navigation properties with inline entity data
 // for deep update in PATCH operations. Related single-valued navigation property entities are
 // updated with the provided inline data map (PATCH semantics applied to the related entity).
 //
+// Collection-valued navigation properties follow the OData 4.01 deep update rules
+// (Part 1: Protocol, Section 11.4.3.1). Nested entities with key values are updated, nested
+// entities without keys are created and entity references (@id) are linked. Entries annotated
+// with @removed are unlinked, or deleted when the removal reason is "deleted". A plain array
+// ("Lines": [...]) represents the complete set of related entities, so related entities that are
+// not listed are unlinked (deleted for containment navigation properties). A delta array
+// ("Lines@delta": [...]) only applies the listed changes. References from a "Lines@odata.bind"
+// annotation in the same payload are linked as part of the deep update, and the corresponding
+// pending binding is dropped from the returned slice.
+//
+// Keyed entries and @removed entries must identify an entity related to entity; keys of
+// entities related to another parent are rejected. Related entities are written through the
+// handler of their entity set by writer.
+//
 // Returns the list of navigation property keys that were processed and should be removed from
-// updateData before the main entity is saved. Collection-valued navigation properties are also
-// added to the removal list to prevent GORM from trying to save them as columns.
-func (h *EntityHandler) processDeepUpdateNavigationProperties(ctx context.Context, entity interface{}, updateData map[string]interface{}, tx *gorm.DB) ([]string, error) {
+// updateData before the main entity is saved, along with the pending collection bindings that
+// still have to be applied.
+func (h *EntityHandler) processDeepUpdateNavigationProperties(writer *deepUpdateWriter, entity interface{}, updateData map[string]interface{}, pendingBindings []PendingCollectionBinding) ([]string, []PendingCollectionBinding, error) {
 	entityValue := reflect.ValueOf(entity).Elem()
 	keysToRemove := make([]string, 0, len(updateData))
 
 	for key, value := range updateData {
-		// Skip annotations (both entity-level "@odata.type" and property-level "prop@odata.bind")
-		if strings.HasPrefix(key, "@") || strings.Contains(key, "@") {
+		isDelta := false
+		if strings.HasSuffix(key, deltaAnnotationSuffix) && !strings.HasPrefix(key, "@") {
+			isDelta = true
+		} else if strings.Contains(key, "@") {
+			// Skip annotations (both entity-level "@odata.type" and property-level "prop@odata.bind")
 			continue
 		}
 
 		// Only process navigation properties
-		navProp := h.findNavigationProperty(key)
+		navProp := h.findNavigationProperty(strings.TrimSuffix(key, deltaAnnotationSuffix))
 		if navProp == nil {
+			if isDelta {
+				return nil, nil, fmt.Errorf("delta annotation '%s' does not refer to a navigation property", key)
+			}
 			continue
 		}
 
 		// Always remove navigation property keys from updateData to prevent GORM column errors
 		keysToRemove = append(keysToRemove, key)
 
-		// Collection-valued navigation properties require collection graph merge semantics.
-		// Silently dropping them would make a non-applied deep update look successful.
+		// Find the target entity metadata
+		targetMeta := h.findTargetEntityMetadataByType(navProp.NavigationTarget)
+		if targetMeta == nil {
+			return nil, nil, fmt.Errorf("entity type '%s' for navigation property '%s' is not registered", navProp.NavigationTarget, navProp.Name)
+		}
+
 		if navProp.NavigationIsArray {
-			return nil, fmt.Errorf("deep update for collection-valued navigation property '%s' is not supported", key)
+			entries, ok := value.([]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("collection-valued navigation property '%s' must be an array, got %T", key, value)
+			}
+
+			var boundTargets []interface{}
+			boundTargets, pendingBindings = takePendingCollectionBinding(pendingBindings, navProp)
+
+			if err := h.performDeepUpdateCollectionNavProp(writer, entity, navProp, targetMeta, entries, boundTargets, isDelta); err != nil {
+				return nil, nil, fmt.Errorf("failed to deep update navigation property '%s': %w", navProp.Name, err)
+			}
+			continue
+		}
+
+		if isDelta {
+			return nil, nil, fmt.Errorf("delta annotation '%s' is only allowed on collection-valued navigation properties", key)
 		}
 
 		// Only proceed if the value is a map (inline entity data)
@@ -48,19 +338,480 @@ func (h *EntityHandler) processDeepUpdateNavigationProperties(ctx context.Contex
 			continue
 		}
 
-		// Find the target entity metadata
-		targetMeta := h.findTargetEntityMetadataByType(navProp.NavigationTarget)
-		if targetMeta == nil {
-			return nil, fmt.Errorf("entity type '%s' for navigation property '%s' is not registered", navProp.NavigationTarget, key)
+		// Perform the deep update on the related entity
+		if err := h.performDeepUpdateSingleNavProp(writer, entityValue, navProp, targetMeta, inlineData); err != nil {
+			return nil, nil, fmt.Errorf("failed to deep update navigation property '%s': %w", key, err)
 		}
+	}
 
-		// Perform the deep update on the related entity
-		if err := h.performDeepUpdateSingleNavProp(ctx, entityValue, navProp, targetMeta, inlineData, tx); err != nil {
-			return nil, fmt.Errorf("failed to deep update navigation property '%s': %w", key, err)
+	return keysToRemove, pendingBindings, nil
+}
+
+// takePendingCollectionBinding removes the pending binding for navProp (if any) and returns its
+// target entities together with the remaining bindings.
+func takePendingCollectionBinding(pendingBindings []PendingCollectionBinding, navProp *metadata.PropertyMetadata) ([]interface{}, []PendingCollectionBinding) {
+	for i, binding := range pendingBindings {
+		if binding.NavigationProperty.Name != navProp.Name {
+			continue
+		}
+		remaining := make([]PendingCollectionBinding, 0, len(pendingBindings)-1)
+		remaining = append(remaining, pendingBindings[:i]...)
+		remaining = append(remaining, pendingBindings[i+1:]...)
+		return binding.TargetEntities, remaining
+	}
+	return nil, pendingBindings
+}
+
+// deepUpdateCollection applies the entries of one collection-valued navigation property.
+type deepUpdateCollection struct {
+	writer      *deepUpdateWriter
+	parent      interface{}
+	navProp     *metadata.PropertyMetadata
+	targetMeta  *metadata.EntityMetadata
+	handler     *EntityHandler
+	foreignKeys []deepUpdateForeignKey
+	many2many   bool
+}
+
+// deepUpdateForeignKey is a foreign key column of a has-many relationship together with the value
+// that relates an entity to the parent.
+type deepUpdateForeignKey struct {
+	field *schema.Field
+	value interface{}
+}
+
+// association returns a fresh GORM association of the parent; GORM associations keep
+// per-operation state, so each operation uses a new one.
+func (c *deepUpdateCollection) association() *gorm.Association {
+	return c.writer.tx.Model(c.parent).Association(c.navProp.Name)
+}
+
+// performDeepUpdateCollectionNavProp applies the nested entries of a collection-valued
+// navigation property to the related entities of entity. See processDeepUpdateNavigationProperties
+// for the semantics of the individual entries.
+func (h *EntityHandler) performDeepUpdateCollectionNavProp(
+	writer *deepUpdateWriter,
+	entity interface{},
+	navProp *metadata.PropertyMetadata,
+	targetMeta *metadata.EntityMetadata,
+	entries []interface{},
+	boundTargets []interface{},
+	isDelta bool,
+) error {
+	handler, err := h.relatedHandler(targetMeta)
+	if err != nil {
+		return err
+	}
+	c := &deepUpdateCollection{writer: writer, parent: entity, navProp: navProp, targetMeta: targetMeta, handler: handler}
+
+	association := c.association()
+	if association.Error != nil {
+		return association.Error
+	}
+	switch association.Relationship.Type {
+	case schema.HasMany:
+		for _, ref := range association.Relationship.References {
+			if !ref.OwnPrimaryKey {
+				continue
+			}
+			var value interface{} = ref.PrimaryValue
+			if ref.PrimaryKey != nil {
+				value, _ = ref.PrimaryKey.ValueOf(writer.ctx, reflect.ValueOf(entity))
+			}
+			c.foreignKeys = append(c.foreignKeys, deepUpdateForeignKey{field: ref.ForeignKey, value: value})
+		}
+	case schema.Many2Many:
+		c.many2many = true
+	default:
+		return fmt.Errorf("relationship of type '%s' is not supported in deep update", association.Relationship.Type)
+	}
+
+	listed := make(map[string]bool, len(entries)+len(boundTargets))
+	for _, target := range boundTargets {
+		if err := c.link(target, map[string]interface{}{}, ""); err != nil {
+			return err
+		}
+		listed[deepUpdateKeyString(targetMeta, target)] = true
+	}
+
+	for i, rawEntry := range entries {
+		entry, ok := rawEntry.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("entry %d must be an object, got %T", i, rawEntry)
+		}
+		target, err := c.applyEntry(entry)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		if target != nil {
+			listed[deepUpdateKeyString(targetMeta, target)] = true
+		}
+	}
+
+	if isDelta {
+		return nil
+	}
+
+	// A full collection replaces the set of related entities: remove everything not listed.
+	current, err := c.findRelated(nil)
+	if err != nil {
+		return fmt.Errorf("failed to load related entities: %w", err)
+	}
+	for i := 0; i < current.Len(); i++ {
+		target := current.Index(i).Interface()
+		if listed[deepUpdateKeyString(targetMeta, target)] {
+			continue
+		}
+		if navProp.NavigationContainsTarget {
+			if err := writer.delete(handler, target); err != nil {
+				return fmt.Errorf("failed to delete contained entity: %w", err)
+			}
+		} else if err := c.unlink(target); err != nil {
+			return fmt.Errorf("failed to unlink related entity: %w", err)
 		}
 	}
 
-	return keysToRemove, nil
+	return nil
+}
+
+// applyEntry applies one nested entry and returns the related entity it leaves in the
+// collection, or nil for a removed entry.
+func (c *deepUpdateCollection) applyEntry(entry map[string]interface{}) (interface{}, error) {
+	ifMatch, _ := entry["@odata.etag"].(string)
+
+	if removed, isRemoved := deepUpdateRemovedAnnotation(entry); isRemoved {
+		target, err := c.findEntryTarget(entry)
+		if err != nil {
+			return nil, err
+		}
+		if target == nil {
+			return nil, fmt.Errorf("removed entries must identify a related entity by @id or key")
+		}
+		if deepUpdateRemovalDeletes(removed) {
+			return nil, c.writer.delete(c.handler, target)
+		}
+		return nil, c.unlink(target)
+	}
+
+	data, err := deepUpdateEntryProperties(c.targetMeta, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref, hasRef := deepUpdateEntryReference(entry); hasRef {
+		// An entity reference links the referenced entity, whichever parent it had.
+		target, err := c.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		related, err := c.findRelated(deepUpdateEntityKeyConditions(c.targetMeta, target))
+		if err != nil {
+			return nil, err
+		}
+		removeDeepUpdateKeys(c.targetMeta, data)
+		if err := c.handler.prepareDeepUpdateData(data, false); err != nil {
+			return nil, err
+		}
+		if related.Len() > 0 {
+			return target, c.writer.update(c.handler, target, data, ifMatch)
+		}
+		return target, c.link(target, data, ifMatch)
+	}
+
+	target, err := c.findEntryTarget(entry)
+	if err != nil {
+		return nil, err
+	}
+	if target != nil {
+		removeDeepUpdateKeys(c.targetMeta, data)
+		if err := c.handler.prepareDeepUpdateData(data, false); err != nil {
+			return nil, err
+		}
+		return target, c.writer.update(c.handler, target, data, ifMatch)
+	}
+
+	// No matching entity: create it related to the parent.
+	for _, fk := range c.foreignKeys {
+		if prop := c.targetMeta.FindProperty(fk.field.Name); prop != nil {
+			data[prop.JsonName] = fk.value
+		}
+	}
+	if err := c.handler.prepareDeepUpdateData(data, true); err != nil {
+		return nil, err
+	}
+	target = reflect.New(c.targetMeta.EntityType).Interface()
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(jsonData, target); err != nil {
+		return nil, fmt.Errorf("invalid entity data: %w", err)
+	}
+	for _, fk := range c.foreignKeys {
+		if err := fk.field.Set(c.writer.ctx, reflect.ValueOf(target), fk.value); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.writer.create(c.handler, target); err != nil {
+		return nil, err
+	}
+	if c.many2many {
+		if err := c.association().Append(target); err != nil {
+			return nil, fmt.Errorf("failed to link related entity: %w", err)
+		}
+	}
+	return target, nil
+}
+
+// link relates target to the parent and applies data to it.
+func (c *deepUpdateCollection) link(target interface{}, data map[string]interface{}, ifMatch string) error {
+	if c.many2many {
+		if err := c.writer.update(c.handler, target, data, ifMatch); err != nil {
+			return err
+		}
+		if err := c.association().Append(target); err != nil {
+			return fmt.Errorf("failed to link related entity: %w", err)
+		}
+		return nil
+	}
+	for _, fk := range c.foreignKeys {
+		data[fk.field.DBName] = fk.value
+	}
+	return c.writer.update(c.handler, target, data, ifMatch)
+}
+
+// unlink removes the relationship between target and the parent, keeping target.
+func (c *deepUpdateCollection) unlink(target interface{}) error {
+	if c.many2many {
+		if err := c.association().Delete(target); err != nil {
+			return fmt.Errorf("failed to unlink related entity: %w", err)
+		}
+		return nil
+	}
+	data := make(map[string]interface{}, len(c.foreignKeys))
+	for _, fk := range c.foreignKeys {
+		data[fk.field.DBName] = nil
+	}
+	return c.writer.update(c.handler, target, data, "")
+}
+
+// findRelated returns the entities related to the parent that match conds, as a slice of
+// pointers to the target entity type.
+func (c *deepUpdateCollection) findRelated(conds []interface{}) (reflect.Value, error) {
+	related := reflect.New(reflect.SliceOf(reflect.PointerTo(c.targetMeta.EntityType)))
+	if err := c.association().Find(related.Interface(), conds...); err != nil {
+		return reflect.Value{}, err
+	}
+	return related.Elem(), nil
+}
+
+// findEntryTarget resolves the related entity a nested entry identifies through an entity
+// reference (@id or @odata.id) or through its key values. It returns nil when the entry carries
+// no identification or when no entity with the given key exists, and an error when the entity
+// exists but is not related to the parent.
+func (c *deepUpdateCollection) findEntryTarget(entry map[string]interface{}) (interface{}, error) {
+	var conds []interface{}
+	if ref, hasRef := deepUpdateEntryReference(entry); hasRef {
+		target, err := c.resolveReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		conds = deepUpdateEntityKeyConditions(c.targetMeta, target)
+	} else {
+		var identified bool
+		if conds, identified = deepUpdateEntryKeyConditions(c.targetMeta, entry); !identified {
+			return nil, nil
+		}
+	}
+
+	related, err := c.findRelated(conds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch related entity '%s': %w", c.targetMeta.EntityName, err)
+	}
+	if related.Len() > 0 {
+		return related.Index(0).Interface(), nil
+	}
+
+	existing := c.writer.tx.Model(reflect.New(c.targetMeta.EntityType).Interface())
+	for _, cond := range conds {
+		existing = existing.Where(cond)
+	}
+	var count int64
+	if err := existing.Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch related entity '%s': %w", c.targetMeta.EntityName, err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("entity '%s' with key (%s) is not related to this entity", c.targetMeta.EntityName, deepUpdateConditionsString(conds))
+	}
+
+	// An entity the row filters hide from the caller is refused as not found rather than taken
+	// for a new entity with the same key.
+	if rowsecurity.FromContext(c.writer.ctx) != nil {
+		unrestricted := c.writer.tx.Session(&gorm.Session{NewDB: true, Context: rowsecurity.Unrestricted(c.writer.ctx)}).
+			Model(reflect.New(c.targetMeta.EntityType).Interface())
+		for _, cond := range conds {
+			unrestricted = unrestricted.Where(cond)
+		}
+		if err := unrestricted.Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch related entity '%s': %w", c.targetMeta.EntityName, err)
+		}
+		if count > 0 {
+			return nil, &deepUpdateError{status: http.StatusNotFound, message: ErrMsgEntityNotFound,
+				err: fmt.Errorf("entity '%s' with key (%s) not found", c.targetMeta.EntityName, deepUpdateConditionsString(conds))}
+		}
+	}
+	return nil, nil
+}
+
+// resolveReference fetches the entity an entity reference of a nested entry points to.
+func (c *deepUpdateCollection) resolveReference(ref interface{}) (interface{}, error) {
+	refURL, ok := ref.(string)
+	if !ok {
+		return nil, fmt.Errorf("entity reference must be a string, got %T", ref)
+	}
+	entitySetName, entityKey, err := parseEntityReference(refURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entity reference '%s': %w", refURL, err)
+	}
+	refMeta, exists := c.handler.entitiesMetadata[entitySetName]
+	if !exists {
+		return nil, fmt.Errorf("entity set '%s' not found", entitySetName)
+	}
+	if refMeta.EntityName != c.targetMeta.EntityName {
+		return nil, fmt.Errorf("entity set '%s' does not match navigation target '%s'", entitySetName, c.targetMeta.EntityName)
+	}
+
+	refHandler, err := c.handler.relatedHandler(refMeta)
+	if err != nil {
+		return nil, err
+	}
+	targetDB, err := refHandler.buildKeyQuery(c.writer.tx, entityKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entity key '%s': %w", entityKey, err)
+	}
+	target := reflect.New(c.targetMeta.EntityType).Interface()
+	if err := targetDB.First(target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("referenced entity '%s(%s)' not found", entitySetName, entityKey)
+		}
+		return nil, fmt.Errorf("failed to fetch referenced entity: %w", err)
+	}
+	return target, nil
+}
+
+// deepUpdateEntryReference returns the entity reference (@id or @odata.id) of a nested entry.
+func deepUpdateEntryReference(entry map[string]interface{}) (interface{}, bool) {
+	if ref, ok := entry["@id"]; ok {
+		return ref, true
+	}
+	ref, ok := entry["@odata.id"]
+	return ref, ok
+}
+
+// deepUpdateEntryKeyConditions returns conditions matching the key values of a nested entry, and
+// false when the entry does not carry all key values.
+func deepUpdateEntryKeyConditions(targetMeta *metadata.EntityMetadata, entry map[string]interface{}) ([]interface{}, bool) {
+	if len(targetMeta.KeyProperties) == 0 {
+		return nil, false
+	}
+	conds := make([]interface{}, 0, len(targetMeta.KeyProperties))
+	for _, keyProp := range targetMeta.KeyProperties {
+		keyValue, ok := entry[keyProp.JsonName]
+		if !ok {
+			keyValue, ok = entry[keyProp.Name]
+		}
+		if !ok || keyValue == nil {
+			return nil, false
+		}
+		conds = append(conds, deepUpdateKeyCondition(targetMeta, keyProp, keyValue))
+	}
+	return conds, true
+}
+
+// deepUpdateEntityKeyConditions returns conditions matching the key values of entity.
+func deepUpdateEntityKeyConditions(targetMeta *metadata.EntityMetadata, entity interface{}) []interface{} {
+	entityValue := reflect.Indirect(reflect.ValueOf(entity))
+	conds := make([]interface{}, 0, len(targetMeta.KeyProperties))
+	for _, keyProp := range targetMeta.KeyProperties {
+		conds = append(conds, deepUpdateKeyCondition(targetMeta, keyProp, extractFieldValue(entityValue.FieldByName(keyProp.Name))))
+	}
+	return conds
+}
+
+func deepUpdateKeyCondition(targetMeta *metadata.EntityMetadata, keyProp metadata.PropertyMetadata, value interface{}) clause.Expression {
+	column := clause.Column{Table: clause.CurrentTable, Name: findEntityPropertyColumnName(targetMeta, keyProp.Name)}
+	return clause.Eq{Column: column, Value: value}
+}
+
+// deepUpdateConditionsString renders key conditions for error messages.
+func deepUpdateConditionsString(conds []interface{}) string {
+	parts := make([]string, 0, len(conds))
+	for _, cond := range conds {
+		if eq, ok := cond.(clause.Eq); ok {
+			if column, ok := eq.Column.(clause.Column); ok {
+				parts = append(parts, fmt.Sprintf("%s=%v", column.Name, eq.Value))
+			}
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// removeDeepUpdateKeys drops the key properties from the data of an existing related entity.
+func removeDeepUpdateKeys(targetMeta *metadata.EntityMetadata, data map[string]interface{}) {
+	for _, keyProp := range targetMeta.KeyProperties {
+		delete(data, keyProp.JsonName)
+		delete(data, keyProp.Name)
+	}
+}
+
+// deepUpdateRemovedAnnotation returns the @removed annotation value of a delta entry.
+func deepUpdateRemovedAnnotation(entry map[string]interface{}) (interface{}, bool) {
+	if removed, ok := entry["@removed"]; ok {
+		return removed, true
+	}
+	removed, ok := entry["@odata.removed"]
+	return removed, ok
+}
+
+// deepUpdateRemovalDeletes reports whether a @removed annotation asks for the related entity
+// to be deleted rather than only unlinked.
+func deepUpdateRemovalDeletes(removed interface{}) bool {
+	record, ok := removed.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	reason, ok := record["reason"].(string)
+	return ok && reason == "deleted"
+}
+
+// deepUpdateEntryProperties returns the structural properties of a nested entry, dropping
+// instance annotations. Nested navigation properties are rejected because deep update is
+// applied one level deep.
+func deepUpdateEntryProperties(targetMeta *metadata.EntityMetadata, entry map[string]interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{}, len(entry))
+	for key, value := range entry {
+		if strings.Contains(key, "@") {
+			continue
+		}
+		if targetMeta.FindNavigationProperty(key) != nil {
+			return nil, fmt.Errorf("nested navigation property '%s' is not supported in deep update", key)
+		}
+		data[key] = value
+	}
+	return data, nil
+}
+
+// deepUpdateKeyString builds a comparable representation of an entity's key values.
+func deepUpdateKeyString(targetMeta *metadata.EntityMetadata, entity interface{}) string {
+	entityValue := reflect.ValueOf(entity)
+	for entityValue.Kind() == reflect.Ptr {
+		entityValue = entityValue.Elem()
+	}
+	parts := make([]string, 0, len(targetMeta.KeyProperties))
+	for _, keyProp := range targetMeta.KeyProperties {
+		parts = append(parts, fmt.Sprintf("%v", extractFieldValue(entityValue.FieldByName(keyProp.Name))))
+	}
+	return strings.Join(parts, ",")
 }
 
 // findTargetEntityMetadataByType finds the metadata for an entity by its type name (EntityName).
@@ -77,21 +828,47 @@ func (h *EntityHandler) findTargetEntityMetadataByType(entityTypeName string) *m
 }
 
 // performDeepUpdateSingleNavProp applies a partial update (PATCH semantics) to the entity
-// referenced by a single-valued navigation property.
+// referenced by a single-valued navigation property, through the handler of its entity set.
 //
 // Two relationship patterns are supported:
 //   - BelongsTo (FK on current entity): e.g. Product.CategoryID references Category.ID
 //   - HasOne (FK on related entity):    e.g. Order has one Address where Address.OrderID = Order.ID
 func (h *EntityHandler) performDeepUpdateSingleNavProp(
-	ctx context.Context,
+	writer *deepUpdateWriter,
 	entityValue reflect.Value,
 	navProp *metadata.PropertyMetadata,
 	targetMeta *metadata.EntityMetadata,
 	inlineData map[string]interface{},
-	tx *gorm.DB,
 ) error {
-	ctxDB := tx.WithContext(ctx)
+	targetEntity, err := h.findDeepUpdateSingleTarget(writer.tx, entityValue, navProp, targetMeta)
+	if err != nil {
+		return err
+	}
+
+	handler, err := h.relatedHandler(targetMeta)
+	if err != nil {
+		return err
+	}
+	data, err := deepUpdateEntryProperties(targetMeta, inlineData)
+	if err != nil {
+		return err
+	}
+	removeDeepUpdateKeys(targetMeta, data)
+	if err := handler.prepareDeepUpdateData(data, false); err != nil {
+		return err
+	}
+	ifMatch, _ := inlineData["@odata.etag"].(string)
+	return writer.update(handler, targetEntity, data, ifMatch)
+}
 
+// findDeepUpdateSingleTarget fetches the entity referenced by a single-valued navigation property
+// of the entity held by entityValue.
+func (h *EntityHandler) findDeepUpdateSingleTarget(
+	db *gorm.DB,
+	entityValue reflect.Value,
+	navProp *metadata.PropertyMetadata,
+	targetMeta *metadata.EntityMetadata,
+) (interface{}, error) {
 	if len(navProp.ReferentialConstraints) > 0 {
 		for dependentProp, principalProp := range navProp.ReferentialConstraints {
 			// Check if the FK field exists on the current entity (BelongsTo relationship)
@@ -100,25 +877,24 @@ func (h *EntityHandler) performDeepUpdateSingleNavProp(
 				// BelongsTo: FK is on the current entity; find the related entity by the FK value
 				fkValue := extractFieldValue(fkField)
 				if fkValue == nil {
-					return fmt.Errorf("foreign key '%s' is nil, cannot deep update related entity '%s'", dependentProp, targetMeta.EntityName)
+					return nil, fmt.Errorf("foreign key '%s' is nil, cannot deep update related entity '%s'", dependentProp, targetMeta.EntityName)
 				}
 
 				// Find the principal property's column name in the target entity
 				principalColumnName := findEntityPropertyColumnName(targetMeta, principalProp)
 				if principalColumnName == "" {
-					return fmt.Errorf("referenced property '%s' not found in target entity '%s'", principalProp, targetMeta.EntityName)
+					return nil, fmt.Errorf("referenced property '%s' not found in target entity '%s'", principalProp, targetMeta.EntityName)
 				}
 
 				// Fetch the related entity
 				targetEntity := reflect.New(targetMeta.EntityType).Interface()
-				if err := ctxDB.Where(fmt.Sprintf("%s = ?", principalColumnName), fkValue).First(targetEntity).Error; err != nil {
+				if err := db.Where(fmt.Sprintf("%s = ?", principalColumnName), fkValue).First(targetEntity).Error; err != nil {
 					if err == gorm.ErrRecordNotFound {
-						return fmt.Errorf("related entity '%s' with %s=%v not found", targetMeta.EntityName, principalColumnName, fkValue)
+						return nil, fmt.Errorf("related entity '%s' with %s=%v not found", targetMeta.EntityName, principalColumnName, fkValue)
 					}
-					return fmt.Errorf("failed to fetch related entity '%s': %w", targetMeta.EntityName, err)
+					return nil, fmt.Errorf("failed to fetch related entity '%s': %w", targetMeta.EntityName, err)
 				}
-
-				return ctxDB.Model(targetEntity).Updates(inlineData).Error
+				return targetEntity, nil
 			}
 		}
 	}
@@ -127,23 +903,22 @@ func (h *EntityHandler) performDeepUpdateSingleNavProp(
 	// to find the related entity via the FK column stored in navProp.ForeignKeyColumnName.
 	currentKeyValue := h.getNavPropPrincipalValue(entityValue, navProp)
 	if currentKeyValue == nil {
-		return fmt.Errorf("cannot determine key value for navigation property '%s'", navProp.Name)
+		return nil, fmt.Errorf("cannot determine key value for navigation property '%s'", navProp.Name)
 	}
 
 	fkColumnName := navProp.ForeignKeyColumnName
 	if fkColumnName == "" {
-		return fmt.Errorf("cannot determine foreign key column for navigation property '%s'", navProp.Name)
+		return nil, fmt.Errorf("cannot determine foreign key column for navigation property '%s'", navProp.Name)
 	}
 
 	targetEntity := reflect.New(targetMeta.EntityType).Interface()
-	if err := ctxDB.Where(fmt.Sprintf("%s = ?", fkColumnName), currentKeyValue).First(targetEntity).Error; err != nil {
+	if err := db.Where(fmt.Sprintf("%s = ?", fkColumnName), currentKeyValue).First(targetEntity).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return fmt.Errorf("related entity '%s' with %s=%v not found", targetMeta.EntityName, fkColumnName, currentKeyValue)
+			return nil, fmt.Errorf("related entity '%s' with %s=%v not found", targetMeta.EntityName, fkColumnName, currentKeyValue)
 		}
-		return fmt.Errorf("failed to fetch related entity '%s': %w", targetMeta.EntityName, err)
+		return nil, fmt.Errorf("failed to fetch related entity '%s': %w", targetMeta.EntityName, err)
 	}
-
-	return ctxDB.Model(targetEntity).Updates(inlineData).Error
+	return targetEntity, nil
 }
 
 // extractFieldValue returns the underlying Go value for a reflect.Value, dereferencing pointers.
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+type deepUpdateTestLine struct {
+	ID int `json:"ID" odata:"key"`
+}
+
+func TestRelatedHandlerRequiresRegisteredEntitySet(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&deepUpdateTestLine{})
+	if err != nil {
+		t.Fatalf("AnalyzeEntity() error: %v", err)
+	}
+	h := NewEntityHandler(nil, meta, nil)
+
+	if _, err := h.relatedHandler(meta); err == nil {
+		t.Fatal("expected an error for an entity set without a registered handler")
+	}
+
+	h.SetEntityHandlers(map[string]*EntityHandler{meta.EntitySetName: h})
+	related, err := h.relatedHandler(meta)
+	if err != nil {
+		t.Fatalf("relatedHandler() error: %v", err)
+	}
+	if related != h {
+		t.Error("expected the registered handler")
+	}
+}
@@ -10,14 +10,17 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/nlstn/go-odata/internal/auth"
 	"github.com/nlstn/go-odata/internal/cache"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/observability"
+	"github.com/nlstn/go-odata/internal/outbox"
 	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/storage"
 	"github.com/nlstn/go-odata/internal/storage/gormstore"
+	"github.com/nlstn/go-odata/internal/tokensign"
 	"github.com/nlstn/go-odata/internal/trackchanges"
 	"gorm.io/gorm"
 )
@@ -25,14 +28,17 @@ import (
 // EntityHandler handles HTTP requests for entity collections
 type EntityHandler struct {
 	db                   *gorm.DB
-	store                storage.Store
+	store                storage.GORMStore
 	metadata             *metadata.EntityMetadata
 	entitiesMetadata     map[string]*metadata.EntityMetadata
 	namespace            string
 	tracker              *trackchanges.Tracker
+	outbox               *outbox.Outbox
 	logger               *slog.Logger
 	policy               auth.Policy
 	ftsManager           *query.FTSManager
+	ftsSearchProvider    *query.FTSSearchProvider
+	searchProvider       query.SearchProvider
 	keyGeneratorResolver func(string) (func(context.Context) (interface{}, error), bool)
 	overwrite            *entityOverwriteHandlers
 	defaultMaxTop        *int
@@ -59,6 +65,26 @@ type EntityHandler struct {
 	// When non-nil and warm, reads within the supported query subset are served
 	// from the snapshot instead of querying the primary database.
 	entityCache *cache.EntityCache
+	// responseStore holds the cached HTTP responses of the service's entity
+	// sets. Writes drop the entries that depend on this entity set.
+	responseStore *cache.ResponseStore
+	// responseCache enables HTTP response caching for the entity set. Nil
+	// means responses are not cached.
+	responseCache *ResponseCachePolicy
+	// tokenSigner signs $skiptoken and $deltatoken values. Nil disables signing.
+	tokenSigner *tokensign.Signer
+	// changeStreamHeartbeat is the keep-alive interval of Server-Sent Events change
+	// streams. Zero means change streams are disabled for the entity set.
+	changeStreamHeartbeat time.Duration
+	// streamCollections makes collection reads stream from a database cursor
+	// even when the client did not send Prefer: odata.streaming.
+	streamCollections bool
+	// temporal describes the history table of a temporal entity set. Nil means
+	// the entity set keeps no history.
+	temporal *temporalHistory
+	// entityHandlers holds the handlers of the service's entity sets, keyed by
+	// entity set name. Deep updates write related entities through them.
+	entityHandlers map[string]*EntityHandler
 }
 
 // NewEntityHandler creates a new entity handler
@@ -79,7 +105,7 @@ func NewEntityHandler(db *gorm.DB, entityMetadata *metadata.EntityMetadata, logg
 }
 
 // NewEntityHandlerWithStore creates a new entity handler with a storage backend.
-func NewEntityHandlerWithStore(store storage.Store, entityMetadata *metadata.EntityMetadata, logger *slog.Logger) *EntityHandler {
+func NewEntityHandlerWithStore(store storage.GORMStore, entityMetadata *metadata.EntityMetadata, logger *slog.Logger) *EntityHandler {
 	if store == nil {
 		store = gormstore.New(nil)
 	}
@@ -121,6 +147,10 @@ func (h *EntityHandler) initPropertyMap() {
 // SetFTSManager sets the FTS manager for the handler
 func (h *EntityHandler) SetFTSManager(ftsManager *query.FTSManager) {
 	h.ftsManager = ftsManager
+	h.ftsSearchProvider = nil
+	if ftsManager != nil {
+		h.ftsSearchProvider = query.NewFTSSearchProvider(ftsManager)
+	}
 }
 
 // SetLogger sets the logger for the handler.
@@ -151,6 +181,14 @@ func (h *EntityHandler) SetEntitiesMetadata(entitiesMetadata map[string]*metadat
 	}
 }
 
+// SetEntityHandlers sets the handlers of the service's entity sets, keyed by
+// entity set name. Related entities written by a deep update go through the
+// handler of their entity set, so that its hooks, authorization and
+// bookkeeping apply.
+func (h *EntityHandler) SetEntityHandlers(handlers map[string]*EntityHandler) {
+	h.entityHandlers = handlers
+}
+
 // SetKeyGeneratorResolver injects a resolver used to look up key generator functions by name.
 func (h *EntityHandler) SetKeyGeneratorResolver(resolver func(string) (func(context.Context) (interface{}, error), bool)) {
 	h.keyGeneratorResolver = resolver
@@ -191,6 +229,18 @@ func (h *EntityHandler) SetDeltaTracker(tracker *trackchanges.Tracker) {
 	h.tracker = tracker
 }
 
+// SetOutbox configures the transactional outbox that receives a change event
+// for every committed create, update and delete. Pass nil to disable it.
+func (h *EntityHandler) SetOutbox(o *outbox.Outbox) {
+	h.outbox = o
+}
+
+// SetTokenSigner configures signing of $skiptoken and $deltatoken values.
+// Pass nil to issue and accept unsigned tokens.
+func (h *EntityHandler) SetTokenSigner(signer *tokensign.Signer) {
+	h.tokenSigner = signer
+}
+
 // SetDefaultMaxTop sets the default maximum number of results for this entity handler.
 func (h *EntityHandler) SetDefaultMaxTop(maxTop *int) {
 	h.defaultMaxTop = maxTop
@@ -228,11 +278,70 @@ func (h *EntityHandler) SetEntityCache(c *cache.EntityCache) {
 }
 
 // invalidateCache marks the entity cache as stale so that the next read
-// triggers a refresh from the primary database. It is a no-op when no cache
-// is configured.
-func (h *EntityHandler) invalidateCache() {
+// triggers a refresh from the primary database. Search providers keeping their
+// own index of the entity set are invalidated as well, and so are the cached
+// responses that depend on the entity set. Other replicas are told through the
+// cache's invalidator; inside a $batch change set that waits until the change
+// set commits.
+func (h *EntityHandler) invalidateCache(ctx context.Context) {
+	_, inTransaction := TransactionFromContext(ctx)
 	if h.entityCache != nil {
 		h.entityCache.Invalidate()
+		if !inTransaction {
+			if err := h.entityCache.Publish(ctx); err != nil {
+				h.logger.Warn("Failed to publish cache invalidation",
+					"entitySet", h.metadata.EntitySetName,
+					"error", err)
+			}
+		}
+	}
+	if h.responseStore != nil {
+		h.responseStore.Invalidate(h.metadata.EntitySetName)
+		if h.responseCache != nil && h.responseCache.Invalidator != nil && !inTransaction {
+			if err := h.responseCache.Invalidator.Publish(ctx, h.metadata.EntitySetName); err != nil {
+				h.logger.Warn("Failed to publish response cache invalidation",
+					"entitySet", h.metadata.EntitySetName,
+					"error", err)
+			}
+		}
+	}
+	// Search providers that update single entities get the written entities
+	// from updateSearch.
+	if _, ok := h.searchUpdater(); ok {
+		return
+	}
+	if invalidator, ok := h.searchProvider.(query.SearchInvalidator); ok {
+		invalidator.InvalidateSearch(h.metadata.EntitySetName)
+	}
+}
+
+// searchUpdater returns the search provider when it updates single entities.
+func (h *EntityHandler) searchUpdater() (query.SearchUpdater, bool) {
+	updater, ok := h.searchProvider.(query.SearchUpdater)
+	return updater, ok && h.db != nil
+}
+
+// updateSearch refreshes the entities of committed change events in a search
+// provider that updates single entities.
+func (h *EntityHandler) updateSearch(ctx context.Context, events []changeEvent) {
+	updater, ok := h.searchUpdater()
+	if !ok || len(events) == 0 {
+		return
+	}
+	keys := make([][]interface{}, 0, len(events))
+	for _, event := range events {
+		keyValues := h.extractKeyValues(event.entity)
+		key := make([]interface{}, len(h.metadata.KeyProperties))
+		for i, kp := range h.metadata.KeyProperties {
+			key[i] = keyValues[kp.JsonName]
+		}
+		keys = append(keys, key)
+	}
+	req := query.NewSearchRequest(h.db.WithContext(ctx), h.metadata, "", 0)
+	if err := updater.UpdateSearch(ctx, req, keys); err != nil {
+		h.logger.Warn("Failed to update search index",
+			"entitySet", h.metadata.EntitySetName,
+			"error", err)
 	}
 }
 
@@ -367,10 +476,10 @@ func (h *EntityHandler) ensureOverwrite() {
 
 // FetchEntity fetches an entity by its key string
 // This is a public method that can be used by action/function handlers
-func (h *EntityHandler) FetchEntity(entityKey string) (interface{}, error) {
+func (h *EntityHandler) FetchEntity(ctx context.Context, entityKey string) (interface{}, error) {
 	// Use empty query options since we just need to verify entity exists
 	queryOptions := &query.QueryOptions{}
-	return h.fetchEntityByKey(context.Background(), entityKey, queryOptions, nil)
+	return h.fetchEntityByKey(ctx, entityKey, queryOptions, nil)
 }
 
 // IsNotFoundError checks if an error is a "not found" error
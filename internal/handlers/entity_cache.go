@@ -10,6 +10,7 @@ import (
 	"github.com/nlstn/go-odata/internal/cache"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/rowsecurity"
 	"gorm.io/gorm"
 )
 
@@ -125,7 +126,8 @@ func canonicalKeyComponent(v interface{}) string {
 // cacheSnapshot returns the current entity snapshot, refreshing it from the
 // primary database when it is missing or expired. It returns false when caching
 // is disabled or a refresh fails, so callers transparently fall back to the
-// primary database.
+// primary database. The snapshot is shared between requests, so it is loaded
+// without row security; readers apply the policy's row filter themselves.
 func (h *EntityHandler) cacheSnapshot(ctx context.Context) (*cache.Snapshot, bool) {
 	if h.entityCache == nil {
 		return nil, false
@@ -133,7 +135,7 @@ func (h *EntityHandler) cacheSnapshot(ctx context.Context) (*cache.Snapshot, boo
 	if snap, ok := h.entityCache.Current(); ok {
 		return snap, true
 	}
-	if err := h.entityCache.Refresh(h.db.WithContext(ctx)); err != nil {
+	if err := h.entityCache.Refresh(h.db.WithContext(rowsecurity.Unrestricted(ctx))); err != nil {
 		h.logger.Warn("Failed to refresh entity cache, falling back to primary database",
 			"entitySet", h.metadata.EntitySetName,
 			"error", err)
@@ -410,7 +412,7 @@ func (h *EntityHandler) snapshotSupportsCollection(queryOptions *query.QueryOpti
 	if len(queryOptions.Apply) > 0 || queryOptions.Compute != nil {
 		return false
 	}
-	if queryOptions.Search != "" || queryOptions.SkipToken != nil || queryOptions.DeltaToken != nil {
+	if queryOptions.Search != "" || queryOptions.SkipToken != nil || queryOptions.DeltaToken != nil || queryOptions.Temporal != nil || queryOptions.Deleted != query.DeletedExclude {
 		return false
 	}
 	if query.ShouldUseMapResults(queryOptions) {
@@ -434,7 +436,7 @@ func (h *EntityHandler) snapshotSupportsCount(queryOptions *query.QueryOptions)
 	if queryOptions == nil {
 		return true
 	}
-	if len(queryOptions.Apply) > 0 || queryOptions.Search != "" {
+	if len(queryOptions.Apply) > 0 || queryOptions.Search != "" || queryOptions.Temporal != nil || queryOptions.Deleted != query.DeletedExclude {
 		return false
 	}
 	return h.filterSupported(queryOptions.Filter)
@@ -591,6 +593,18 @@ func (h *EntityHandler) postProcessCachedCollection(ctx context.Context, results
 	return sliceValue, nil
 }
 
+// snapshotFilter returns filter restricted to the policy's row filter, for
+// queries evaluated against the snapshot. ok is false when the row filter
+// cannot be evaluated in memory; the caller then reads from the database, where
+// row security applies it.
+func (h *EntityHandler) snapshotFilter(ctx context.Context, filter *query.FilterExpression) (*query.FilterExpression, bool) {
+	rowFilter, err := h.rowFilter(ctx)
+	if err != nil || !h.filterSupported(rowFilter) {
+		return nil, false
+	}
+	return query.MergeFilterExpressions(filter, rowFilter), true
+}
+
 // countSnapshot counts entities in the snapshot matching filter.
 func (h *EntityHandler) countSnapshot(snap *cache.Snapshot, filter *query.FilterExpression) int64 {
 	if filter == nil {
@@ -609,8 +623,13 @@ func (h *EntityHandler) countSnapshot(snap *cache.Snapshot, filter *query.Filter
 // fetchEntityByKeyFromSnapshot serves a key read from the snapshot. The returned
 // served flag is true when the snapshot is authoritative for this key (found or
 // not); callers only fall back to the primary database when served is false.
+// Entities outside the policy's row filter are reported as not found.
 func (h *EntityHandler) fetchEntityByKeyFromSnapshot(ctx context.Context, entityKey string, queryOptions *query.QueryOptions, scopes []func(*gorm.DB) *gorm.DB) (interface{}, bool, bool, error) {
-	if len(scopes) > 0 {
+	if len(scopes) > 0 || queryOptions.Temporal != nil || queryOptions.Deleted != query.DeletedExclude {
+		return nil, false, false, nil
+	}
+	rowFilter, err := h.rowFilter(ctx)
+	if err != nil || !h.filterSupported(rowFilter) {
 		return nil, false, false, nil
 	}
 	snap, ok := h.cacheSnapshot(ctx)
@@ -629,6 +648,9 @@ func (h *EntityHandler) fetchEntityByKeyFromSnapshot(ctx context.Context, entity
 		// The snapshot is authoritative (no scopes): the entity does not exist.
 		return nil, false, true, nil
 	}
+	if rowFilter != nil && !evalPreparedFilter(EntityCacheNormalizeFunc(h.metadata)(entity), h.prepareFilter(rowFilter)) {
+		return nil, false, true, nil
+	}
 
 	result := reflect.New(h.metadata.EntityType)
 	result.Elem().Set(entity)
@@ -113,6 +113,14 @@ func (h *EntityHandler) parseSingleEntityQueryOptions(r *http.Request) (*query.Q
 		}
 	}
 
+	if queryOptions.Temporal.IsPeriod() {
+		return nil, &requestError{
+			StatusCode: http.StatusBadRequest,
+			ErrorCode:  ErrMsgInvalidQueryOptions,
+			Message:    "$from and $to query options are not applicable to individual entities",
+		}
+	}
+
 	if queryOptions.Index {
 		return nil, &requestError{
 			StatusCode: http.StatusBadRequest,
@@ -121,7 +129,15 @@ func (h *EntityHandler) parseSingleEntityQueryOptions(r *http.Request) (*query.Q
 		}
 	}
 
-	if err := applyPolicyFiltersToExpand(r, h.policy, h.metadata, queryOptions.Expand); err != nil {
+	if err := h.validateQueryRestrictions(queryOptions, false); err != nil {
+		return nil, &requestError{
+			StatusCode: http.StatusBadRequest,
+			ErrorCode:  ErrMsgQueryOptionRestricted,
+			Message:    err.Error(),
+		}
+	}
+
+	if err := applyDatabasePolicyFiltersToExpand(r, h.policy, h.metadata, queryOptions.Expand); err != nil {
 		return nil, &requestError{
 			StatusCode: http.StatusForbidden,
 			ErrorCode:  "Authorization failed",
@@ -158,6 +174,12 @@ func (h *EntityHandler) fetchEntityByKey(ctx context.Context, entityKey string,
 	// statement used by First (including its key predicate), so sharing it would
 	// incorrectly apply the parent key filter to child lookups.
 	baseDB := db.Session(&gorm.Session{NewDB: true})
+	if queryOptions.Temporal != nil {
+		db = db.Scopes(h.temporalScope(queryOptions.Temporal))
+	}
+	if queryOptions.Deleted != query.DeletedExclude {
+		db = db.Scopes(h.deletedScope(queryOptions.Deleted))
+	}
 
 	db, err := h.buildKeyQuery(db, entityKey)
 	if err != nil {
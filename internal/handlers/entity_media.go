@@ -7,7 +7,9 @@ import (
 	"strings"
 
 	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/etag"
 	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/trackchanges"
 	"gorm.io/gorm"
 )
 
@@ -58,7 +60,7 @@ func (h *EntityHandler) HandleMediaEntityValue(w http.ResponseWriter, r *http.Re
 func (h *EntityHandler) handleGetMediaEntityValue(w http.ResponseWriter, r *http.Request, entityKey string) {
 	// Fetch the entity
 	entity := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
@@ -100,18 +102,7 @@ func (h *EntityHandler) handleGetMediaEntityValue(w http.ResponseWriter, r *http
 		}
 	}
 
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
-
-	// Write binary response
-	w.Header().Set("Content-Type", contentType)
-	w.WriteHeader(http.StatusOK)
-	if r.Method != http.MethodHead {
-		if _, err := w.Write(content); err != nil {
-			h.logger.Error("Error writing media content", "error", err)
-		}
-	}
+	writeMediaContent(w, r, content, contentType, etag.GenerateStrong(entity, h.metadata))
 }
 
 // handlePutMediaEntityValue handles PUT requests to update media entity binary content
@@ -137,7 +128,7 @@ func (h *EntityHandler) handlePutMediaEntityValue(w http.ResponseWriter, r *http
 
 	// Fetch the entity
 	entity := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
@@ -213,27 +204,37 @@ func (h *EntityHandler) handlePutMediaEntityValue(w http.ResponseWriter, r *http
 		return
 	}
 
-	// Build a fresh key query for the update to avoid any state from previous queries.
-	// We use h.db.Model(entity) to specify the table and then build the WHERE clause.
-	// Note: We cannot use h.db.Session(&gorm.Session{NewDB: true}) here because that would
-	// lose the Model context and cause "WHERE conditions required" errors.
-	updateDB, err := h.buildKeyQuery(h.db.Model(entity), entityKey)
-	if err != nil {
-		if writeErr := response.WriteError(w, r, http.StatusInternalServerError, ErrMsgInternalError,
-			fmt.Sprintf("Failed to build update query: %v", err)); writeErr != nil {
-			h.logger.Error("Error writing error response", "error", writeErr)
+	// The content, the version it produces in the history of a temporal entity
+	// set and its outbox event are written in one transaction.
+	events := []changeEvent{{entity: entity, changeType: trackchanges.ChangeTypeUpdated}}
+	err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+		// Build a fresh key query for the update to avoid any state from previous queries.
+		// We use tx.Model(entity) to specify the table and then build the WHERE clause.
+		// Note: We cannot use a session with NewDB: true here because that would
+		// lose the Model context and cause "WHERE conditions required" errors.
+		updateDB, err := h.buildKeyQuery(tx.Model(entity), entityKey)
+		if err != nil {
+			return fmt.Errorf("failed to build update query: %w", err)
 		}
-		return
-	}
 
-	// Use Session with FullSaveAssociations=false to avoid association issues
-	if err := updateDB.Session(&gorm.Session{FullSaveAssociations: false}).Updates(updates).Error; err != nil {
+		// Use Session with FullSaveAssociations=false to avoid association issues
+		if err := updateDB.Session(&gorm.Session{FullSaveAssociations: false}).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := h.appendTemporalHistory(tx, events); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, events)
+	})
+	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusInternalServerError, ErrMsgInternalError,
 			fmt.Sprintf("Failed to update media entity: %v", err)); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
 		}
 		return
 	}
+	h.finalizeChangeEvents(r.Context(), events)
+	h.invalidateCache(r.Context())
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -180,3 +180,59 @@ func TestHandleMediaEntityValue_InvalidKey(t *testing.T) {
 		t.Errorf("Status = %v, want 404 or 400", w.Code)
 	}
 }
+
+// RangeMediaEntity is a media entity used to exercise partial content responses.
+type RangeMediaEntity struct {
+	ID          uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	ContentType string `json:"ContentType"`
+	Content     []byte `json:"-"`
+}
+
+func (RangeMediaEntity) HasStream() bool { return true }
+
+func (m *RangeMediaEntity) GetMediaContent() []byte { return m.Content }
+
+func (m *RangeMediaEntity) GetMediaContentType() string { return m.ContentType }
+
+func TestHandleMediaEntityValue_GetRange(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&RangeMediaEntity{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	if err := db.Create(&RangeMediaEntity{ID: 1, ContentType: "application/pdf", Content: []byte("%PDF-1.7 body")}).Error; err != nil {
+		t.Fatalf("Failed to create test data: %v", err)
+	}
+
+	entityMeta, err := metadata.AnalyzeEntity(RangeMediaEntity{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	handler := NewEntityHandler(db, entityMeta, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/RangeMediaEntities(1)/$value", nil)
+	req.Header.Set("Range", "bytes=0-7")
+	w := httptest.NewRecorder()
+
+	handler.HandleMediaEntityValue(w, req, "1")
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %v, want %v. Body: %s", w.Code, http.StatusPartialContent, w.Body.String())
+	}
+	if w.Body.String() != "%PDF-1.7" {
+		t.Errorf("body = %q, want %%PDF-1.7", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", got)
+	}
+
+	notModified := httptest.NewRequest(http.MethodGet, "/RangeMediaEntities(1)/$value", nil)
+	notModified.Header.Set("If-None-Match", w.Header().Get(HeaderETag))
+	w = httptest.NewRecorder()
+	handler.HandleMediaEntityValue(w, notModified, "1")
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Status = %v, want %v", w.Code, http.StatusNotModified)
+	}
+}
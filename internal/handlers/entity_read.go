@@ -43,7 +43,9 @@ func (h *EntityHandler) HandleEntity(w http.ResponseWriter, r *http.Request, ent
 		if !authorizeRequest(w, r, h.policy, buildEntityResourceDescriptor(h.metadata, entityKey, nil), auth.OperationRead, h.logger) {
 			return
 		}
-		h.handleGetEntity(w, r, entityKey)
+		h.serveWithResponseCache(w, r, func(w http.ResponseWriter, r *http.Request) {
+			h.handleGetEntity(w, r, entityKey)
+		})
 	case http.MethodDelete:
 		h.handleDeleteEntity(w, r, entityKey)
 	case http.MethodPatch:
@@ -97,6 +99,11 @@ func (h *EntityHandler) handleGetEntity(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	if queryOptions.Deleted != query.DeletedExclude &&
+		!h.authorizeDeletedAccess(w, r, buildEntityResourceDescriptor(h.metadata, entityKey, nil), auth.OperationReadDeleted) {
+		return
+	}
+
 	// Invoke BeforeReadEntity hooks to obtain scopes
 	scopes, hookErr := callBeforeReadEntity(h.metadata, r, queryOptions)
 	if hookErr != nil {
@@ -182,7 +189,8 @@ func (h *EntityHandler) handleGetEntityOverwrite(w http.ResponseWriter, r *http.
 				fmt.Sprintf("Entity with key '%s' not found", entityKey))
 			return
 		}
-		WriteError(w, r, http.StatusInternalServerError, "Error fetching entity", err.Error())
+		_, status, message, details := extractHookErrorDetails(err, http.StatusInternalServerError, "Error fetching entity")
+		WriteError(w, r, status, message, details)
 		return
 	}
 
@@ -192,6 +200,12 @@ func (h *EntityHandler) handleGetEntityOverwrite(w http.ResponseWriter, r *http.
 		return
 	}
 
+	result, err = h.expandVirtualResults(r, result, queryOptions.Expand)
+	if err != nil {
+		h.writeHookError(w, r, err, http.StatusInternalServerError, "Error expanding entity")
+		return
+	}
+
 	// Build and write response
 	h.writeEntityResponseWithETag(w, r, result, "", http.StatusOK, queryOptions.Expand, queryOptions.Select)
 }
@@ -334,6 +348,11 @@ func (h *EntityHandler) HandleCollectionRef(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := h.validateQueryRestrictions(queryOptions, true); err != nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgQueryOptionRestricted, err.Error())
+		return
+	}
+
 	// Invoke BeforeReadCollection hooks to obtain scopes
 	scopes, hookErr := callBeforeReadCollection(h.metadata, r, queryOptions)
 	if hookErr != nil {
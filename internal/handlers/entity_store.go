@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/nlstn/go-odata/internal/hookerrors"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/storage"
+)
+
+// SetEntityStore serves the entity set from a record-level store: collection
+// reads, $count, single-entity reads and all writes are delegated to store with
+// the parsed query options, replacing any previously configured overwrite
+// handlers. Passing nil removes the store.
+func (h *EntityHandler) SetEntityStore(store storage.EntityStore) {
+	if store == nil {
+		h.SetOverwrite(nil)
+		return
+	}
+
+	h.SetOverwrite(&EntityOverwrite{
+		GetCollection: func(ctx *OverwriteContext) (*CollectionResult, error) {
+			q := storage.Query{Options: ctx.QueryOptions}
+			result, err := store.Query(ctx.Request.Context(), q)
+			if err != nil {
+				return nil, mapEntityStoreError(err)
+			}
+			if result == nil {
+				result = &storage.Result{}
+			}
+			count := result.Count
+			if ctx.QueryOptions.Count && count == nil {
+				total, err := store.Count(ctx.Request.Context(), q)
+				if err != nil {
+					return nil, mapEntityStoreError(err)
+				}
+				count = &total
+			}
+			return &CollectionResult{Items: h.projectStoreItems(result.Items, ctx.QueryOptions), Count: count}, nil
+		},
+		GetCount: func(ctx *OverwriteContext) (int64, error) {
+			count, err := store.Count(ctx.Request.Context(), storage.Query{Options: ctx.QueryOptions})
+			return count, mapEntityStoreError(err)
+		},
+		GetEntity: func(ctx *OverwriteContext) (interface{}, error) {
+			entity, err := store.Get(ctx.Request.Context(), ctx.EntityKeyValues, storage.Query{Options: ctx.QueryOptions})
+			if err != nil || entity == nil {
+				return nil, mapEntityStoreError(err)
+			}
+			if len(ctx.QueryOptions.Select) > 0 {
+				entity = query.ApplySelectToEntity(entity, ctx.QueryOptions.Select, h.metadata, ctx.QueryOptions.Expand)
+			}
+			return entity, nil
+		},
+		Create: func(ctx *OverwriteContext, entity interface{}) (interface{}, error) {
+			created, err := store.Create(ctx.Request.Context(), entity)
+			return created, mapEntityStoreError(err)
+		},
+		Update: func(ctx *OverwriteContext, updateData map[string]interface{}, isFullReplace bool) (interface{}, error) {
+			updated, err := store.Update(ctx.Request.Context(), ctx.EntityKeyValues, updateData, isFullReplace)
+			return updated, mapEntityStoreError(err)
+		},
+		Delete: func(ctx *OverwriteContext) error {
+			return mapEntityStoreError(store.Delete(ctx.Request.Context(), ctx.EntityKeyValues))
+		},
+	})
+}
+
+// projectStoreItems applies $select to store results the same way the SQL
+// collection path does: flat selections are projected by the serializer,
+// anything else is materialized through query.ApplySelect.
+func (h *EntityHandler) projectStoreItems(items interface{}, queryOptions *query.QueryOptions) interface{} {
+	if items == nil {
+		return []interface{}{}
+	}
+	if len(queryOptions.Select) > 0 && !query.CanDeferSelectProjection(queryOptions.Select, queryOptions.Expand, h.metadata) {
+		return query.ApplySelect(items, queryOptions.Select, h.metadata, queryOptions.Expand)
+	}
+	return items
+}
+
+// mapEntityStoreError converts storage sentinel errors into HookErrors carrying
+// the matching HTTP status. storage.ErrNotFound is passed through unchanged
+// because the overwrite paths already answer it with 404.
+func mapEntityStoreError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrConflict):
+		return &hookerrors.HookError{StatusCode: http.StatusConflict, Message: ErrMsgConflict, Err: err}
+	case errors.Is(err, storage.ErrUnsupportedQuery):
+		return &hookerrors.HookError{StatusCode: http.StatusNotImplemented, Message: ErrMsgNotImplemented, Err: err}
+	default:
+		return err
+	}
+}
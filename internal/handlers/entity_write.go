@@ -113,7 +113,7 @@ func (h *EntityHandler) handleDeleteEntity(w http.ResponseWriter, r *http.Reques
 			return newTransactionHandledError(err)
 		}
 
-		if err := tx.Delete(entity).Error; err != nil {
+		if err := h.deleteEntity(tx, entity); err != nil {
 			h.writeDeleteDatabaseError(w, r, err)
 			return newTransactionHandledError(err)
 		}
@@ -123,7 +123,10 @@ func (h *EntityHandler) handleDeleteEntity(w http.ResponseWriter, r *http.Reques
 		}
 
 		changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeDeleted})
-		return nil
+		if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, changeEvents)
 	}); err != nil {
 		if isTransactionHandled(err) {
 			return
@@ -135,7 +138,7 @@ func (h *EntityHandler) handleDeleteEntity(w http.ResponseWriter, r *http.Reques
 	h.finalizeChangeEvents(ctx, changeEvents)
 
 	// Invalidate the entity cache so that subsequent reads reflect the deletion.
-	h.invalidateCache()
+	h.invalidateCache(ctx)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -182,10 +185,12 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 	var (
 		entity       interface{}
 		changeEvents []changeEvent
+		nested       *deepUpdateWriter
 	)
 
 	if err := h.runInTransaction(ctx, r, func(tx *gorm.DB, hookReq *http.Request) error {
 		entity = reflect.New(h.metadata.EntityType).Interface()
+		nested = newDeepUpdateWriter(ctx, tx, hookReq)
 
 		db, err := h.buildKeyQuery(tx, entityKey)
 		if err != nil {
@@ -250,11 +255,9 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 		}
 
 		// Process deep update: update related entities with inline navigation property data
-		navPropsToRemove, err := h.processDeepUpdateNavigationProperties(ctx, entity, updateData, tx)
+		navPropsToRemove, pendingBindings, err := h.processDeepUpdateNavigationProperties(nested, entity, updateData, pendingBindings)
 		if err != nil {
-			if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Failed to deep update related entity", err.Error()); writeErr != nil {
-				h.logger.Error("Error writing error response", "error", writeErr)
-			}
+			h.writeDeepUpdateError(w, r, err)
 			return newTransactionHandledError(err)
 		}
 
@@ -286,6 +289,25 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.validateVocabularyConstraints(updateData); err != nil {
+			h.writeRequestError(w, r, err, http.StatusBadRequest, ErrMsgValidationFailed)
+			return newTransactionHandledError(err)
+		}
+
+		if err := h.encodeCollectionPropertiesInPlace(updateData); err != nil {
+			if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid property value", err.Error()); writeErr != nil {
+				h.logger.Error("Error writing error response", "error", writeErr)
+			}
+			return newTransactionHandledError(err)
+		}
+
+		if err := h.decodeSpatialPropertiesInPlace(updateData); err != nil {
+			if writeErr := response.WriteError(w, r, http.StatusBadRequest, "Invalid property value", err.Error()); writeErr != nil {
+				h.logger.Error("Error writing error response", "error", writeErr)
+			}
+			return newTransactionHandledError(err)
+		}
+
 		if err := h.callBeforeUpdate(entity, hookReq); err != nil {
 			h.writeHookError(w, r, err, http.StatusForbidden, "Authorization failed")
 			return newTransactionHandledError(err)
@@ -316,6 +338,10 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.ensureRowVisible(w, r, tx, entity); err != nil {
+			return err
+		}
+
 		if err := h.callAfterUpdate(entity, hookReq); err != nil {
 			h.logger.Error("AfterUpdate hook failed", "error", err)
 		}
@@ -326,7 +352,10 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 			changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeUpdated})
 		}
 
-		return nil
+		if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, changeEvents)
 	}); err != nil {
 		if isTransactionHandled(err) {
 			return
@@ -336,9 +365,10 @@ func (h *EntityHandler) handlePatchEntity(w http.ResponseWriter, r *http.Request
 	}
 
 	h.finalizeChangeEvents(ctx, changeEvents)
+	nested.finalize(ctx)
 
 	// Invalidate the entity cache so that subsequent reads reflect the update.
-	h.invalidateCache()
+	h.invalidateCache(ctx)
 
 	db, err := h.buildKeyQuery(h.db.WithContext(ctx), entityKey)
 	if err != nil {
@@ -463,6 +493,11 @@ func (h *EntityHandler) handlePutEntity(w http.ResponseWriter, r *http.Request,
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.validateVocabularyConstraints(replacementData); err != nil {
+			h.writeRequestError(w, r, err, http.StatusBadRequest, ErrMsgValidationFailed)
+			return newTransactionHandledError(err)
+		}
+
 		replacementEntity := reflect.New(h.metadata.EntityType).Interface()
 		replacementJSON, err := json.Marshal(replacementData)
 		if err != nil {
@@ -532,6 +567,10 @@ func (h *EntityHandler) handlePutEntity(w http.ResponseWriter, r *http.Request,
 			return newTransactionHandledError(err)
 		}
 
+		if err := h.ensureRowVisible(w, r, tx, entity); err != nil {
+			return err
+		}
+
 		if err := h.callAfterUpdate(entity, hookReq); err != nil {
 			h.logger.Error("AfterUpdate hook failed", "error", err)
 		}
@@ -542,7 +581,10 @@ func (h *EntityHandler) handlePutEntity(w http.ResponseWriter, r *http.Request,
 			changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeUpdated})
 		}
 
-		return nil
+		if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, changeEvents)
 	}); err != nil {
 		if isTransactionHandled(err) {
 			return
@@ -554,7 +596,7 @@ func (h *EntityHandler) handlePutEntity(w http.ResponseWriter, r *http.Request,
 	h.finalizeChangeEvents(ctx, changeEvents)
 
 	// Invalidate the entity cache so that subsequent reads reflect the change.
-	h.invalidateCache()
+	h.invalidateCache(ctx)
 
 	// 204 No Content (or 200 OK) response for the update
 	db, err := h.buildKeyQuery(h.db.WithContext(ctx), entityKey)
@@ -803,9 +845,11 @@ func (h *EntityHandler) handleDeleteEntityOverwrite(w http.ResponseWriter, r *ht
 				fmt.Sprintf("Entity with key '%s' not found", entityKey))
 			return
 		}
-		WriteError(w, r, http.StatusInternalServerError, "Error deleting entity", err.Error())
+		_, status, message, details := extractHookErrorDetails(err, http.StatusInternalServerError, "Error deleting entity")
+		WriteError(w, r, status, message, details)
 		return
 	}
+	h.invalidateCache(r.Context())
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -847,9 +891,11 @@ func (h *EntityHandler) handleUpdateEntityOverwrite(w http.ResponseWriter, r *ht
 				fmt.Sprintf("Entity with key '%s' not found", entityKey))
 			return
 		}
-		WriteError(w, r, http.StatusInternalServerError, "Error updating entity", err.Error())
+		_, status, message, details := extractHookErrorDetails(err, http.StatusInternalServerError, "Error updating entity")
+		WriteError(w, r, status, message, details)
 		return
 	}
+	h.invalidateCache(r.Context())
 
 	// Build response
 	if applied := pref.GetPreferenceApplied(); applied != "" {
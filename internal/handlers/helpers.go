@@ -8,11 +8,13 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/odataerrors"
+	"github.com/nlstn/go-odata/internal/outbox"
 	"github.com/nlstn/go-odata/internal/preference"
 	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/response"
@@ -325,11 +327,10 @@ func (h *EntityHandler) recordChange(entity interface{}, changeType trackchanges
 		return
 	}
 
+	// Deletions keep the entity state too, so that delta responses can tell
+	// whether the deleted entity was within a policy's row filter.
 	keyValues := h.extractKeyValues(entity)
-	var data map[string]interface{}
-	if changeType != trackchanges.ChangeTypeDeleted {
-		data = h.entityToMap(entity)
-	}
+	data := h.entityToMap(entity)
 	if _, err := h.tracker.RecordChange(h.metadata.EntitySetName, keyValues, data, changeType); err != nil {
 		if h.logger != nil {
 			h.logger.Error("failed to record change event", "entitySet", h.metadata.EntitySetName, "err", err)
@@ -346,7 +347,41 @@ func (h *EntityHandler) finalizeChangeEvents(ctx context.Context, events []chang
 		return
 	}
 	for _, event := range events {
-		h.recordChange(event.entity, event.changeType)
+		if !event.untracked {
+			h.recordChange(event.entity, event.changeType)
+		}
+	}
+	h.notifyOutbox()
+	h.updateSearch(ctx, events)
+}
+
+// appendOutboxEvents writes events to the transactional outbox using tx so they
+// commit or roll back together with the entity write.
+func (h *EntityHandler) appendOutboxEvents(tx *gorm.DB, events []changeEvent) error {
+	if h.outbox == nil || len(events) == 0 || h.metadata.IsSingleton {
+		return nil
+	}
+	now := time.Now()
+	records := make([]outbox.Event, 0, len(events))
+	for _, event := range events {
+		record := outbox.Event{
+			EntitySet:  h.metadata.EntitySetName,
+			Type:       event.changeType,
+			KeyValues:  h.extractKeyValues(event.entity),
+			OccurredAt: now,
+		}
+		if event.changeType != trackchanges.ChangeTypeDeleted {
+			record.Data = h.entityToMap(event.entity)
+		}
+		records = append(records, record)
+	}
+	return h.outbox.Append(tx, records)
+}
+
+// notifyOutbox wakes the outbox delivery loop after a write committed.
+func (h *EntityHandler) notifyOutbox() {
+	if h.outbox != nil {
+		h.outbox.Notify()
 	}
 }
 
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/etag"
+)
+
+// writeMediaContent writes the raw value of a media entity or stream property.
+// It sends the strong ETag tag, or one derived from content when tag is empty,
+// and advertises byte ranges, so If-None-Match is answered with 304 Not
+// Modified, and Range (optionally guarded by If-Range) with 206 Partial
+// Content. Several ranges produce a multipart/byteranges body. HEAD requests
+// receive the headers only.
+//
+// The content is loaded in full even when a range of it is requested.
+func writeMediaContent(w http.ResponseWriter, r *http.Request, content []byte, contentType, tag string) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if tag == "" {
+		tag = etag.GenerateForContent(content)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set(HeaderETag, tag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+}
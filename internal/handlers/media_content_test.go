@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const mediaContentTestBody = "0123456789abcdefghij"
+
+func serveMediaContent(t *testing.T, method string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, "/Media(1)/$value", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	writeMediaContent(w, req, []byte(mediaContentTestBody), "video/mp4", "")
+	return w
+}
+
+func TestWriteMediaContent_FullResponse(t *testing.T) {
+	w := serveMediaContent(t, http.MethodGet, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "video/mp4" {
+		t.Errorf("Content-Type = %q, want video/mp4", got)
+	}
+	if etag := w.Header().Get(HeaderETag); etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Errorf("ETag = %q, want a strong ETag", etag)
+	}
+	if w.Body.String() != mediaContentTestBody {
+		t.Errorf("body = %q, want %q", w.Body.String(), mediaContentTestBody)
+	}
+}
+
+func TestWriteMediaContent_GivenETag(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Media(1)/$value", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	req.Header.Set("If-Range", `"v7"`)
+	w := httptest.NewRecorder()
+	writeMediaContent(w, req, []byte(mediaContentTestBody), "video/mp4", `"v7"`)
+
+	if got := w.Header().Get(HeaderETag); got != `"v7"` {
+		t.Errorf("ETag = %q, want the given ETag", got)
+	}
+	if w.Code != http.StatusPartialContent || w.Body.String() != "0123" {
+		t.Errorf("If-Range with the given ETag: status %d body %q, want 206 0123", w.Code, w.Body.String())
+	}
+}
+
+func TestWriteMediaContent_SingleRange(t *testing.T) {
+	w := serveMediaContent(t, http.MethodGet, map[string]string{"Range": "bytes=5-9"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusPartialContent)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Errorf("Content-Range = %q, want bytes 5-9/20", got)
+	}
+	if w.Body.String() != "56789" {
+		t.Errorf("body = %q, want 56789", w.Body.String())
+	}
+}
+
+func TestWriteMediaContent_MultipleRanges(t *testing.T) {
+	w := serveMediaContent(t, http.MethodGet, map[string]string{"Range": "bytes=0-1,-3"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusPartialContent)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("Content-Type = %q, want multipart/byteranges", w.Header().Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		if got := part.Header.Get("Content-Type"); got != "video/mp4" {
+			t.Errorf("part Content-Type = %q, want video/mp4", got)
+		}
+		body, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Range")+"="+string(body))
+	}
+	if len(parts) != 2 || parts[0] != "bytes 0-1/20=01" || parts[1] != "bytes 17-19/20=hij" {
+		t.Errorf("parts = %v", parts)
+	}
+}
+
+func TestWriteMediaContent_IfRange(t *testing.T) {
+	current := serveMediaContent(t, http.MethodHead, nil).Header().Get(HeaderETag)
+
+	w := serveMediaContent(t, http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": current})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "0123" {
+		t.Errorf("matching If-Range: status %d body %q, want 206 0123", w.Code, w.Body.String())
+	}
+
+	w = serveMediaContent(t, http.MethodGet, map[string]string{"Range": "bytes=0-3", "If-Range": `"stale"`})
+	if w.Code != http.StatusOK || w.Body.String() != mediaContentTestBody {
+		t.Errorf("stale If-Range: status %d body %q, want the full content", w.Code, w.Body.String())
+	}
+}
+
+func TestWriteMediaContent_IfNoneMatch(t *testing.T) {
+	current := serveMediaContent(t, http.MethodHead, nil).Header().Get(HeaderETag)
+
+	w := serveMediaContent(t, http.MethodGet, map[string]string{"If-None-Match": current})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusNotModified)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 response has body %q", w.Body.String())
+	}
+
+	w = serveMediaContent(t, http.MethodGet, map[string]string{"If-None-Match": `"other"`})
+	if w.Code != http.StatusOK {
+		t.Errorf("Status = %d, want %d for a non-matching ETag", w.Code, http.StatusOK)
+	}
+}
+
+func TestWriteMediaContent_UnsatisfiableRange(t *testing.T) {
+	w := serveMediaContent(t, http.MethodGet, map[string]string{"Range": "bytes=50-60"})
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("Status = %d, want %d", w.Code, http.StatusRequestedRangeNotSatisfiable)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */20" {
+		t.Errorf("Content-Range = %q, want bytes */20", got)
+	}
+}
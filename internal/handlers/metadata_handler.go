@@ -12,6 +12,7 @@ import (
 
 	"github.com/nlstn/go-odata/internal/actions"
 	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/response"
 )
@@ -537,6 +538,10 @@ func getEdmType(goType reflect.Type) string {
 		return "Edm.Untyped"
 	}
 
+	if edmType, ok := geo.EdmTypeOf(goType); ok {
+		return edmType
+	}
+
 	// Check for specific types by name
 	typeName := goType.String()
 	switch typeName {
@@ -590,7 +595,7 @@ func (m metadataModel) collectComplexTypes() map[string]*complexTypeInfo {
 	for _, entityMeta := range m.entities {
 		for i := range entityMeta.Properties {
 			prop := &entityMeta.Properties[i]
-			if !prop.IsComplexType || len(prop.ComplexTypeFields) == 0 {
+			if (!prop.IsComplexType && !prop.IsCollection) || len(prop.ComplexTypeFields) == 0 {
 				continue
 			}
 
@@ -614,8 +619,12 @@ func (m metadataModel) collectComplexTypes() map[string]*complexTypeInfo {
 }
 
 // complexTypeGoName returns the Go struct name used as the OData complex type name.
+// For complex collections it is the name of the element type.
 func complexTypeGoName(prop *metadata.PropertyMetadata) string {
 	t := prop.Type
+	if prop.IsCollection {
+		t = prop.CollectionElementType()
+	}
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/nlstn/go-odata/internal/actions"
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/version"
 )
@@ -364,8 +365,7 @@ func (h *MetadataHandler) buildJSONEntityType(model metadataModel, entityMeta *m
 	// Add entity-level annotations
 	if entityMeta.Annotations != nil {
 		for _, annotation := range entityMeta.Annotations.Get() {
-			annotationKey := "@" + annotation.QualifiedTerm()
-			entityType[annotationKey] = h.annotationJSONValue(annotation.Value)
+			h.addJSONAnnotation(entityType, annotation)
 		}
 	}
 
@@ -389,7 +389,13 @@ func (h *MetadataHandler) addJSONRegularProperties(model metadataModel, entityTy
 func (h *MetadataHandler) buildJSONPropertyDefinition(model metadataModel, prop *metadata.PropertyMetadata) map[string]interface{} {
 	propDef := make(map[string]interface{})
 
-	propDef["$Type"] = h.propertyEdmType(model, prop)
+	edmType := h.propertyEdmType(model, prop)
+	// CSDL JSON marks collection-valued properties with $Collection and the element type
+	if elemType, ok := strings.CutPrefix(edmType, "Collection("); ok && prop.IsCollection {
+		propDef["$Collection"] = true
+		edmType = strings.TrimSuffix(elemType, ")")
+	}
+	propDef["$Type"] = edmType
 
 	if value, include := h.propertyNullable(prop); include {
 		propDef["$Nullable"] = value
@@ -400,8 +406,7 @@ func (h *MetadataHandler) buildJSONPropertyDefinition(model metadataModel, prop
 	// Add property-level annotations
 	if prop.Annotations != nil {
 		for _, annotation := range prop.Annotations.Get() {
-			annotationKey := "@" + annotation.QualifiedTerm()
-			propDef[annotationKey] = h.annotationJSONValue(annotation.Value)
+			h.addJSONAnnotation(propDef, annotation)
 		}
 	}
 
@@ -427,6 +432,9 @@ func (h *MetadataHandler) addJSONPropertyFacets(propDef map[string]interface{},
 			propDef["$Scale"] = prop.Scale
 		}
 	}
+	if _, _, spatial := geo.ParseEdmType(edmType); spatial && prop.SRID > 0 {
+		propDef["$SRID"] = prop.SRID
+	}
 	if prop.DefaultValue != "" {
 		propDef["$DefaultValue"] = prop.DefaultValue
 	}
@@ -472,6 +480,15 @@ func (h *MetadataHandler) buildJSONNavigationProperty(model metadataModel, prop
 	return navProp
 }
 
+// addJSONAnnotation writes an annotation, and any annotations applied to it, onto the target object.
+func (h *MetadataHandler) addJSONAnnotation(target map[string]interface{}, annotation metadata.Annotation) {
+	annotationKey := "@" + annotation.QualifiedTerm()
+	target[annotationKey] = h.annotationJSONValue(annotation.Value)
+	for _, nested := range annotation.Annotations {
+		target[annotationKey+"@"+nested.QualifiedTerm()] = h.annotationJSONValue(nested.Value)
+	}
+}
+
 func (h *MetadataHandler) annotationJSONValue(value interface{}) interface{} {
 	if collectionValues, ok := annotationCollectionValues(value); ok {
 		collection := make([]interface{}, 0, len(collectionValues))
@@ -503,8 +520,7 @@ func (h *MetadataHandler) buildJSONEntityContainer(model metadataModel) map[stri
 
 	if model.containerAnnotations != nil {
 		for _, annotation := range model.containerAnnotations.Get() {
-			annotationKey := "@" + annotation.QualifiedTerm()
-			container[annotationKey] = h.annotationJSONValue(annotation.Value)
+			h.addJSONAnnotation(container, annotation)
 		}
 	}
 
@@ -525,8 +541,7 @@ func (h *MetadataHandler) buildJSONEntityContainer(model metadataModel) map[stri
 
 			if entityMeta.SingletonAnnotations != nil {
 				for _, annotation := range entityMeta.SingletonAnnotations.Get() {
-					annotationKey := "@" + annotation.QualifiedTerm()
-					singleton[annotationKey] = h.annotationJSONValue(annotation.Value)
+					h.addJSONAnnotation(singleton, annotation)
 				}
 			}
 
@@ -544,8 +559,7 @@ func (h *MetadataHandler) buildJSONEntityContainer(model metadataModel) map[stri
 
 			if entityMeta.EntitySetAnnotations != nil {
 				for _, annotation := range entityMeta.EntitySetAnnotations.Get() {
-					annotationKey := "@" + annotation.QualifiedTerm()
-					entitySet[annotationKey] = h.annotationJSONValue(annotation.Value)
+					h.addJSONAnnotation(entitySet, annotation)
 				}
 			}
 
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"sort"
 
 	"github.com/nlstn/go-odata/internal/metadata"
@@ -118,6 +119,12 @@ func (h *MetadataHandler) propertyEdmType(model metadataModel, prop *metadata.Pr
 			return model.qualifiedTypeName(name)
 		}
 	}
+	if prop.IsCollection {
+		if name := complexTypeGoName(prop); name != "" {
+			return fmt.Sprintf("Collection(%s)", model.qualifiedTypeName(name))
+		}
+		return fmt.Sprintf("Collection(%s)", getEdmType(prop.CollectionElementType()))
+	}
 	return getEdmType(prop.Type)
 }
 
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/version"
 )
@@ -141,6 +142,10 @@ func (h *MetadataHandler) getEdmTypeName(t reflect.Type) string {
 		return "Edm.Untyped"
 	}
 
+	if edmType, ok := geo.EdmTypeOf(t); ok {
+		return edmType
+	}
+
 	// json.RawMessage → Edm.Untyped (must be checked before the slice/array branch)
 	if t.PkgPath() == "encoding/json" && t.Name() == "RawMessage" {
 		return "Edm.Untyped"
@@ -311,6 +316,9 @@ func (h *MetadataHandler) buildComplexType(model metadataModel, info *complexTyp
 				attrs += fmt.Sprintf(` Scale="%d"`, prop.Scale)
 			}
 		}
+		if _, _, spatial := geo.ParseEdmType(edmType); spatial && prop.SRID > 0 {
+			attrs += fmt.Sprintf(` SRID="%d"`, prop.SRID)
+		}
 		if prop.DefaultValue != "" {
 			attrs += fmt.Sprintf(` DefaultValue="%s"`, prop.DefaultValue)
 		}
@@ -520,6 +528,9 @@ func (h *MetadataHandler) buildRegularProperties(model metadataModel, entityMeta
 				attrs += fmt.Sprintf(` Scale="%d"`, prop.Scale)
 			}
 		}
+		if _, _, spatial := geo.ParseEdmType(edmType); spatial && prop.SRID > 0 {
+			attrs += fmt.Sprintf(` SRID="%d"`, prop.SRID)
+		}
 		if prop.DefaultValue != "" {
 			attrs += fmt.Sprintf(` DefaultValue="%s"`, prop.DefaultValue)
 		}
@@ -727,6 +738,10 @@ func (h *MetadataHandler) buildAnnotations(model metadataModel) string {
 
 // buildAnnotationXML builds the XML representation of a single annotation
 func (h *MetadataHandler) buildAnnotationXML(annotation metadata.Annotation) string {
+	if len(annotation.Annotations) > 0 {
+		return h.buildAnnotatedAnnotationXML(annotation)
+	}
+
 	indentStr := strings.Repeat(" ", 8)
 
 	escapedTerm := escapeXML(annotation.Term)
@@ -817,6 +832,32 @@ func (h *MetadataHandler) buildAnnotationXML(annotation metadata.Annotation) str
 `, indentStr, escapedTerm, qualifierAttr, escapedValue)
 }
 
+// buildAnnotatedAnnotationXML builds an annotation element that carries annotations of its own,
+// such as Validation.Exclusive on a Validation.Minimum bound. The nested annotations are
+// emitted as child elements after the annotation value.
+func (h *MetadataHandler) buildAnnotatedAnnotationXML(annotation metadata.Annotation) string {
+	nested := annotation.Annotations
+	annotation.Annotations = nil
+	element := h.buildAnnotationXML(annotation)
+
+	var children strings.Builder
+	for _, child := range nested {
+		childXML := h.buildAnnotationXML(child)
+		for _, line := range strings.SplitAfter(childXML, "\n") {
+			if line != "" {
+				children.WriteString("  " + line)
+			}
+		}
+	}
+
+	if strings.HasSuffix(element, " />\n") {
+		return strings.TrimSuffix(element, " />\n") + ">\n" + children.String() + strings.Repeat(" ", 8) + "</Annotation>\n"
+	}
+
+	closing := strings.Repeat(" ", 8) + "</Annotation>\n"
+	return strings.TrimSuffix(element, closing) + children.String() + closing
+}
+
 func (h *MetadataHandler) buildAnnotationCollectionXML(values []interface{}, indent int) string {
 	indentStr := strings.Repeat(" ", indent)
 	childIndent := indent + 2
@@ -837,9 +878,17 @@ func (h *MetadataHandler) buildAnnotationRecordXML(values map[string]interface{}
 	childIndent := indent + 2
 
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf(`%s<Record>
+	if recordType, ok := values[annotationRecordTypeKey].(string); ok {
+		builder.WriteString(fmt.Sprintf(`%s<Record Type="%s">
+`, indentStr, escapeXML(recordType)))
+	} else {
+		builder.WriteString(fmt.Sprintf(`%s<Record>
 `, indentStr))
+	}
 	for _, key := range sortedAnnotationKeys(values) {
+		if key == annotationRecordTypeKey {
+			continue
+		}
 		builder.WriteString(h.buildAnnotationPropertyValueXML(key, values[key], childIndent))
 	}
 	builder.WriteString(fmt.Sprintf(`%s</Record>
@@ -956,6 +1005,7 @@ func vocabularyURI(namespace string) string {
 		"Org.OData.Validation.V1":    "https://oasis-tcs.github.io/odata-vocabularies/vocabularies/Org.OData.Validation.V1.xml",
 		"Org.OData.Measures.V1":      "https://oasis-tcs.github.io/odata-vocabularies/vocabularies/Org.OData.Measures.V1.xml",
 		"Org.OData.Authorization.V1": "https://oasis-tcs.github.io/odata-vocabularies/vocabularies/Org.OData.Authorization.V1.xml",
+		"Org.OData.Temporal.V1":      "https://oasis-tcs.github.io/odata-vocabularies/vocabularies/Org.OData.Temporal.V1.xml",
 	}
 
 	if uri, ok := standardVocabularyURIs[namespace]; ok {
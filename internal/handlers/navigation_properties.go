@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/trackchanges"
 	"gorm.io/gorm"
 )
 
@@ -142,7 +144,7 @@ func (h *EntityHandler) handleGetNavigationProperty(w http.ResponseWriter, r *ht
 	}
 
 	// Fetch the parent entity with the navigation property preloaded
-	parent, err := h.fetchParentEntityWithNav(entityKey, navProp.Name)
+	parent, err := h.fetchParentEntityWithNav(r.Context(), entityKey, navProp.Name)
 	if err != nil {
 		h.handleFetchError(w, r, err, entityKey)
 		return
@@ -190,7 +192,7 @@ func (h *EntityHandler) handleNavigationCollectionWithQueryOptions(w http.Respon
 		return
 	}
 
-	relatedDB := h.buildNavigationRelatedQuery(parent, navProp, targetMetadata)
+	relatedDB := h.buildNavigationRelatedQuery(r.Context(), parent, navProp, targetMetadata)
 	navigationPath := fmt.Sprintf("%s(%s)/%s", h.metadata.EntitySetName, entityKey, navProp.JsonName)
 
 	h.executeCollectionQuery(w, r, &collectionExecutionContext{
@@ -215,7 +217,7 @@ func (h *EntityHandler) verifyAndFetchParentEntity(w http.ResponseWriter, r *htt
 	}
 
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error())
 		return nil, err
@@ -239,8 +241,8 @@ func (h *EntityHandler) verifyAndFetchParentEntity(w http.ResponseWriter, r *htt
 // buildNavigationRelatedQuery builds a GORM query for the related collection with foreign key constraints.
 // It honours explicit GORM foreignKey/references tags on the navigation property before falling back
 // to the convention-based <EntityName><KeyName> approach.
-func (h *EntityHandler) buildNavigationRelatedQuery(parent interface{}, navProp *metadata.PropertyMetadata, targetMetadata *metadata.EntityMetadata) *gorm.DB {
-	relatedDB := h.db.Model(reflect.New(targetMetadata.EntityType).Interface())
+func (h *EntityHandler) buildNavigationRelatedQuery(ctx context.Context, parent interface{}, navProp *metadata.PropertyMetadata, targetMetadata *metadata.EntityMetadata) *gorm.DB {
+	relatedDB := h.db.WithContext(ctx).Model(reflect.New(targetMetadata.EntityType).Interface())
 	parentValue := reflect.ValueOf(parent).Elem()
 
 	// Prefer explicit referential constraints from GORM foreignKey/references tags.
@@ -297,14 +299,14 @@ func (h *EntityHandler) createNavParseQueryOptions(r *http.Request, targetMetada
 		if err != nil {
 			return nil, err
 		}
-		if err := applyPolicyFilter(r, h.policy, buildEntityResourceDescriptor(targetMetadata, "", nil), queryOptions); err != nil {
+		if err := applyDatabasePolicyFilter(r, h.policy, buildEntityResourceDescriptor(targetMetadata, "", nil), queryOptions); err != nil {
 			return nil, &collectionRequestError{
 				StatusCode: http.StatusForbidden,
 				ErrorCode:  "Authorization failed",
 				Message:    err.Error(),
 			}
 		}
-		if err := applyPolicyFiltersToExpand(r, h.policy, targetMetadata, queryOptions.Expand); err != nil {
+		if err := applyDatabasePolicyFiltersToExpand(r, h.policy, targetMetadata, queryOptions.Expand); err != nil {
 			return nil, &collectionRequestError{
 				StatusCode: http.StatusForbidden,
 				ErrorCode:  "Authorization failed",
@@ -461,7 +463,7 @@ func (h *EntityHandler) createNavNextLinkFunc(r *http.Request, targetMetadata *m
 		if value.Kind() == reflect.Slice && value.Len() > *queryOptions.Top {
 			trimmed := h.trimResults(results, *queryOptions.Top)
 
-			if nextURL := buildNextLinkWithSkipToken(targetMetadata, queryOptions, results, r); nextURL != nil {
+			if nextURL := buildNextLinkWithSkipToken(targetMetadata, queryOptions, results, r, h.tokenSigner); nextURL != nil {
 				return nextURL, trimmed, nil
 			}
 
@@ -514,7 +516,7 @@ func (h *EntityHandler) handleNavigationCollectionItem(w http.ResponseWriter, r
 
 	// First verify that the parent entity exists
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	parentDB, err := h.buildKeyQuery(h.db, entityKey)
+	parentDB, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error())
 		return
@@ -632,6 +634,12 @@ func (h *EntityHandler) handleNavigationCollectionItem(w http.ResponseWriter, r
 
 // handleGetNavigationPropertyCount handles GET requests for navigation property count
 func (h *EntityHandler) handleGetNavigationPropertyCount(w http.ResponseWriter, r *http.Request, entityKey string, navigationProperty string) {
+	// Collections of primitive or complex values are counted from the stored array
+	if collProp := h.metadata.FindCollectionProperty(navigationProperty); collProp != nil {
+		h.handleGetCollectionPropertyCount(w, r, entityKey, collProp)
+		return
+	}
+
 	// Find and validate the navigation property
 	navProp := h.findNavigationProperty(navigationProperty)
 	if navProp == nil {
@@ -660,7 +668,7 @@ func (h *EntityHandler) handleGetNavigationPropertyCount(w http.ResponseWriter,
 	}
 
 	// Fetch the parent entity with the navigation property preloaded
-	parent, err := h.fetchParentEntityWithNav(entityKey, navProp.Name)
+	parent, err := h.fetchParentEntityWithNav(r.Context(), entityKey, navProp.Name)
 	if err != nil {
 		h.handleFetchError(w, r, err, entityKey)
 		return
@@ -701,7 +709,7 @@ func (h *EntityHandler) handleOptionsNavigationPropertyCount(w http.ResponseWrit
 }
 
 // fetchParentEntityWithNav fetches the parent entity and preloads the specified navigation property
-func (h *EntityHandler) fetchParentEntityWithNav(entityKey, navPropertyName string) (interface{}, error) {
+func (h *EntityHandler) fetchParentEntityWithNav(ctx context.Context, entityKey, navPropertyName string) (interface{}, error) {
 	parent := reflect.New(h.metadata.EntityType).Interface()
 
 	var db *gorm.DB
@@ -710,10 +718,10 @@ func (h *EntityHandler) fetchParentEntityWithNav(entityKey, navPropertyName stri
 	// Handle singleton case where entityKey is empty
 	if h.metadata.IsSingleton && entityKey == "" {
 		// For singletons, we don't use a key query, just fetch the first (and only) record
-		db = h.db
+		db = h.db.WithContext(ctx)
 	} else {
 		// For regular entities, build the key query
-		db, err = h.buildKeyQuery(h.db, entityKey)
+		db, err = h.buildKeyQuery(h.db.WithContext(ctx), entityKey)
 		if err != nil {
 			return nil, err
 		}
@@ -726,7 +734,7 @@ func (h *EntityHandler) fetchParentEntityWithNav(entityKey, navPropertyName stri
 // FetchNavEntityKey fetches the key string of the entity pointed to by a single-valued
 // navigation property. Used by the router to resolve chained navigation paths like
 // Products(1)/Category/Products.
-func (h *EntityHandler) FetchNavEntityKey(entityKey, navPropName string) (string, error) {
+func (h *EntityHandler) FetchNavEntityKey(ctx context.Context, entityKey, navPropName string) (string, error) {
 	navProp := h.findNavigationProperty(navPropName)
 	if navProp == nil {
 		return "", fmt.Errorf("navigation property '%s' not found", navPropName)
@@ -738,7 +746,7 @@ func (h *EntityHandler) FetchNavEntityKey(entityKey, navPropName string) (string
 	if err != nil {
 		return "", fmt.Errorf("failed to get target metadata: %w", err)
 	}
-	parent, err := h.fetchParentEntityWithNav(entityKey, navProp.Name)
+	parent, err := h.fetchParentEntityWithNav(ctx, entityKey, navProp.Name)
 	if err != nil {
 		return "", err
 	}
@@ -1040,12 +1048,19 @@ func (h *EntityHandler) handlePutNavigationPropertyRef(w http.ResponseWriter, r
 	}
 
 	// Update the navigation property reference
-	if err := h.updateNavigationPropertyReference(entityKey, navProp, targetKey); err != nil {
+	var change referenceChange
+	err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+		var err error
+		change, err = h.updateNavigationPropertyReference(tx, entityKey, navProp, targetKey)
+		return err
+	})
+	if err != nil {
 		h.logger.Error("Failed to update navigation property reference", "error", err, "entityKey", entityKey, "navProp", navProp.Name, "targetKey", targetKey)
-		WriteError(w, r, http.StatusInternalServerError, ErrMsgDatabaseError,
-			fmt.Sprintf("Failed to update navigation property: %v", err))
+		h.writeReferenceError(w, r, err, "Failed to update navigation property")
 		return
 	}
+	change.finalize(r.Context())
+	h.invalidateReferenceCaches(r.Context(), targetMetadata)
 
 	// Success - return 204 No Content
 	w.WriteHeader(http.StatusNoContent)
@@ -1121,12 +1136,19 @@ func (h *EntityHandler) handlePostNavigationPropertyRef(w http.ResponseWriter, r
 	}
 
 	// Add the reference to the collection navigation property
-	if err := h.addNavigationPropertyReference(entityKey, navProp, targetKey); err != nil {
+	var change referenceChange
+	err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+		var err error
+		change, err = h.addNavigationPropertyReference(tx, entityKey, navProp, targetKey)
+		return err
+	})
+	if err != nil {
 		h.logger.Error("Failed to add navigation property reference", "error", err, "entityKey", entityKey, "navProp", navProp.Name, "targetKey", targetKey)
-		WriteError(w, r, http.StatusInternalServerError, ErrMsgDatabaseError,
-			fmt.Sprintf("Failed to add navigation property reference: %v", err))
+		h.writeReferenceError(w, r, err, "Failed to add navigation property reference")
 		return
 	}
+	change.finalize(r.Context())
+	h.invalidateReferenceCaches(r.Context(), targetMetadata)
 
 	// Success - return 204 No Content
 	w.WriteHeader(http.StatusNoContent)
@@ -1159,12 +1181,19 @@ func (h *EntityHandler) handleDeleteNavigationPropertyRef(w http.ResponseWriter,
 			return
 		}
 		// DELETE specific reference from collection: EntitySet(key)/NavProp(targetKey)/$ref
-		if err := h.deleteCollectionNavigationPropertyReference(entityKey, navProp, targetKey); err != nil {
+		var change referenceChange
+		err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+			var err error
+			change, err = h.deleteCollectionNavigationPropertyReference(tx, entityKey, navProp, targetKey)
+			return err
+		})
+		if err != nil {
 			h.logger.Error("Failed to delete collection navigation property reference", "error", err, "entityKey", entityKey, "navProp", navProp.Name, "targetKey", targetKey)
-			WriteError(w, r, http.StatusInternalServerError, ErrMsgDatabaseError,
-				fmt.Sprintf("Failed to delete navigation property reference: %v", err))
+			h.writeReferenceError(w, r, err, "Failed to delete navigation property reference")
 			return
 		}
+		change.finalize(r.Context())
+		h.invalidateReferenceCaches(r.Context(), targetMetadata)
 	} else if navProp.NavigationIsArray && targetKey == "" {
 		// Collection navigation property without target key specified
 		WriteError(w, r, http.StatusBadRequest, "Invalid request",
@@ -1182,18 +1211,87 @@ func (h *EntityHandler) handleDeleteNavigationPropertyRef(w http.ResponseWriter,
 		}
 		// Single-valued navigation property
 		// Remove the reference by setting the navigation property to null
-		if err := h.deleteNavigationPropertyReference(entityKey, navProp); err != nil {
+		var change referenceChange
+		err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+			var err error
+			change, err = h.deleteNavigationPropertyReference(tx, entityKey, navProp)
+			return err
+		})
+		if err != nil {
 			h.logger.Error("Failed to delete single navigation property reference", "error", err, "entityKey", entityKey, "navProp", navProp.Name)
-			WriteError(w, r, http.StatusInternalServerError, ErrMsgDatabaseError,
-				fmt.Sprintf("Failed to delete navigation property reference: %v", err))
+			h.writeReferenceError(w, r, err, "Failed to delete navigation property reference")
 			return
 		}
+		change.finalize(r.Context())
+		h.invalidateReferenceCaches(r.Context(), targetMetadata)
 	}
 
 	// Success - return 204 No Content
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// referenceChange is the change event a $ref write produces for the entity
+// set whose row holds the reference. It is empty when no row changed.
+type referenceChange struct {
+	handler *EntityHandler
+	events  []changeEvent
+}
+
+// finalize wakes the outbox once the transaction of the $ref write commits.
+func (c referenceChange) finalize(ctx context.Context) {
+	if c.handler != nil {
+		c.handler.finalizeChangeEvents(ctx, c.events)
+	}
+}
+
+// recordReferenceChange appends the temporal history and outbox event of a
+// $ref change in tx. They belong to the entity set whose row holds the
+// reference: the parent for a single-valued navigation property, and the
+// target for a one-to-many collection. Many-to-many references live in a join
+// table and change neither.
+func (h *EntityHandler) recordReferenceChange(tx *gorm.DB, navProp *metadata.PropertyMetadata, targetMetadata *metadata.EntityMetadata, parent, target interface{}) (referenceChange, error) {
+	owner, entity := h, parent
+	if navProp.NavigationIsArray {
+		if navProp.IsManyToMany || target == nil {
+			return referenceChange{}, nil
+		}
+		owner, entity = h.entityHandlers[targetMetadata.EntitySetName], target
+		if owner == nil {
+			return referenceChange{}, nil
+		}
+	}
+	// Change tracking does not record reference changes, so that a delta
+	// response reports an entity added together with its references once.
+	events := []changeEvent{{entity: entity, changeType: trackchanges.ChangeTypeUpdated, untracked: true}}
+	if err := owner.appendTemporalHistory(tx, events); err != nil {
+		return referenceChange{}, err
+	}
+	if err := owner.appendOutboxEvents(tx, events); err != nil {
+		return referenceChange{}, err
+	}
+	return referenceChange{handler: owner, events: events}, nil
+}
+
+// invalidateReferenceCaches drops the cached entities and responses of both
+// ends of a modified reference: the foreign key lives in either entity set.
+func (h *EntityHandler) invalidateReferenceCaches(ctx context.Context, targetMetadata *metadata.EntityMetadata) {
+	h.invalidateCache(ctx)
+	if target := h.entityHandlers[targetMetadata.EntitySetName]; target != nil && target != h {
+		target.invalidateCache(ctx)
+	}
+}
+
+// writeReferenceError reports a failed reference modification. A parent or
+// target entity that does not exist, or that the policy's row filter hides, is
+// reported as not found.
+func (h *EntityHandler) writeReferenceError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if IsNotFoundError(err) {
+		WriteError(w, r, http.StatusNotFound, ErrMsgEntityNotFound, err.Error())
+		return
+	}
+	WriteError(w, r, http.StatusInternalServerError, ErrMsgDatabaseError, fmt.Sprintf("%s: %v", message, err))
+}
+
 // parseNavigationPropertyWithKey parses a navigation property that may contain a key
 // Example: "RelatedProducts(2)" returns ("RelatedProducts", "2")
 // Example: "Category" returns ("Category", "")
@@ -1245,31 +1343,31 @@ func (h *EntityHandler) validateAndExtractEntityKey(odataID string, targetEntity
 }
 
 // updateNavigationPropertyReference updates a single-valued navigation property reference
-func (h *EntityHandler) updateNavigationPropertyReference(entityKey string, navProp *metadata.PropertyMetadata, targetKey string) error {
+func (h *EntityHandler) updateNavigationPropertyReference(tx *gorm.DB, entityKey string, navProp *metadata.PropertyMetadata, targetKey string) (referenceChange, error) {
 	// Get the target entity metadata to find the foreign key fields
 	targetMetadata, err := h.getTargetMetadata(navProp.NavigationTarget)
 	if err != nil {
-		return fmt.Errorf("failed to get target metadata: %w", err)
+		return referenceChange{}, fmt.Errorf("failed to get target metadata: %w", err)
 	}
 
 	// Fetch the parent entity
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(tx, entityKey)
 	if err != nil {
-		return fmt.Errorf("invalid entity key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid entity key: %w", err)
 	}
 	if err := db.First(parent).Error; err != nil {
-		return fmt.Errorf("parent entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("parent entity not found: %w", err)
 	}
 
 	// Fetch the target entity to verify it exists and get its key value
 	target := reflect.New(targetMetadata.EntityType).Interface()
-	targetDB, err := h.buildTargetKeyQuery(targetKey, targetMetadata)
+	targetDB, err := h.buildTargetKeyQuery(tx, targetKey, targetMetadata)
 	if err != nil {
-		return fmt.Errorf("invalid target key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid target key: %w", err)
 	}
 	if err := targetDB.First(target).Error; err != nil {
-		return fmt.Errorf("target entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("target entity not found: %w", err)
 	}
 
 	// Extract the target entity's key value(s)
@@ -1310,39 +1408,39 @@ func (h *EntityHandler) updateNavigationPropertyReference(entityKey string, navP
 	}
 
 	// Save the updated parent entity
-	if err := h.db.Save(parent).Error; err != nil {
-		return fmt.Errorf("failed to save entity: %w", err)
+	if err := tx.Save(parent).Error; err != nil {
+		return referenceChange{}, fmt.Errorf("failed to save entity: %w", err)
 	}
 
-	return nil
+	return h.recordReferenceChange(tx, navProp, targetMetadata, parent, nil)
 }
 
 // addNavigationPropertyReference adds a reference to a collection navigation property
-func (h *EntityHandler) addNavigationPropertyReference(entityKey string, navProp *metadata.PropertyMetadata, targetKey string) error {
+func (h *EntityHandler) addNavigationPropertyReference(tx *gorm.DB, entityKey string, navProp *metadata.PropertyMetadata, targetKey string) (referenceChange, error) {
 	// Get the target entity metadata
 	targetMetadata, err := h.getTargetMetadata(navProp.NavigationTarget)
 	if err != nil {
-		return fmt.Errorf("failed to get target metadata: %w", err)
+		return referenceChange{}, fmt.Errorf("failed to get target metadata: %w", err)
 	}
 
 	// Fetch the parent entity
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(tx, entityKey)
 	if err != nil {
-		return fmt.Errorf("invalid entity key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid entity key: %w", err)
 	}
 	if err := db.First(parent).Error; err != nil {
-		return fmt.Errorf("parent entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("parent entity not found: %w", err)
 	}
 
 	// Fetch the target entity to verify it exists
 	target := reflect.New(targetMetadata.EntityType).Interface()
-	targetDB, err := h.buildTargetKeyQuery(targetKey, targetMetadata)
+	targetDB, err := h.buildTargetKeyQuery(tx, targetKey, targetMetadata)
 	if err != nil {
-		return fmt.Errorf("invalid target key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid target key: %w", err)
 	}
 	if err := targetDB.First(target).Error; err != nil {
-		return fmt.Errorf("target entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("target entity not found: %w", err)
 	}
 
 	// Use GORM's association API to add the relationship
@@ -1350,33 +1448,33 @@ func (h *EntityHandler) addNavigationPropertyReference(entityKey string, navProp
 	navField := parentValue.FieldByName(navProp.Name)
 
 	if !navField.IsValid() {
-		return fmt.Errorf("navigation property field not found")
+		return referenceChange{}, fmt.Errorf("navigation property field not found")
 	}
 
 	// Use GORM Model().Association() to append the target entity
-	if err := h.db.Model(parent).Association(navProp.Name).Append(target); err != nil {
-		return fmt.Errorf("failed to add association: %w", err)
+	if err := tx.Model(parent).Association(navProp.Name).Append(target); err != nil {
+		return referenceChange{}, fmt.Errorf("failed to add association: %w", err)
 	}
 
-	return nil
+	return h.recordReferenceChange(tx, navProp, targetMetadata, parent, target)
 }
 
 // deleteNavigationPropertyReference removes a single-valued navigation property reference
-func (h *EntityHandler) deleteNavigationPropertyReference(entityKey string, navProp *metadata.PropertyMetadata) error {
+func (h *EntityHandler) deleteNavigationPropertyReference(tx *gorm.DB, entityKey string, navProp *metadata.PropertyMetadata) (referenceChange, error) {
 	// Fetch the parent entity
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(tx, entityKey)
 	if err != nil {
-		return fmt.Errorf("invalid entity key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid entity key: %w", err)
 	}
 	if err := db.First(parent).Error; err != nil {
-		return fmt.Errorf("parent entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("parent entity not found: %w", err)
 	}
 
 	// Get the target entity metadata to find the foreign key fields
 	targetMetadata, err := h.getTargetMetadata(navProp.NavigationTarget)
 	if err != nil {
-		return fmt.Errorf("failed to get target metadata: %w", err)
+		return referenceChange{}, fmt.Errorf("failed to get target metadata: %w", err)
 	}
 
 	// Set the foreign key field(s) to null/zero value
@@ -1394,55 +1492,55 @@ func (h *EntityHandler) deleteNavigationPropertyReference(entityKey string, navP
 	}
 
 	// Save the updated parent entity
-	if err := h.db.Save(parent).Error; err != nil {
-		return fmt.Errorf("failed to save entity: %w", err)
+	if err := tx.Save(parent).Error; err != nil {
+		return referenceChange{}, fmt.Errorf("failed to save entity: %w", err)
 	}
 
-	return nil
+	return h.recordReferenceChange(tx, navProp, targetMetadata, parent, nil)
 }
 
 // deleteCollectionNavigationPropertyReference removes a specific reference from a collection navigation property
-func (h *EntityHandler) deleteCollectionNavigationPropertyReference(entityKey string, navProp *metadata.PropertyMetadata, targetKey string) error {
+func (h *EntityHandler) deleteCollectionNavigationPropertyReference(tx *gorm.DB, entityKey string, navProp *metadata.PropertyMetadata, targetKey string) (referenceChange, error) {
 	// Get the target entity metadata
 	targetMetadata, err := h.getTargetMetadata(navProp.NavigationTarget)
 	if err != nil {
-		return fmt.Errorf("failed to get target metadata: %w", err)
+		return referenceChange{}, fmt.Errorf("failed to get target metadata: %w", err)
 	}
 
 	// Fetch the parent entity
 	parent := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(tx, entityKey)
 	if err != nil {
-		return fmt.Errorf("invalid entity key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid entity key: %w", err)
 	}
 	if err := db.First(parent).Error; err != nil {
-		return fmt.Errorf("parent entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("parent entity not found: %w", err)
 	}
 
 	// Fetch the target entity to verify it exists
 	target := reflect.New(targetMetadata.EntityType).Interface()
-	targetDB, err := h.buildTargetKeyQuery(targetKey, targetMetadata)
+	targetDB, err := h.buildTargetKeyQuery(tx, targetKey, targetMetadata)
 	if err != nil {
-		return fmt.Errorf("invalid target key: %w", err)
+		return referenceChange{}, fmt.Errorf("invalid target key: %w", err)
 	}
 	if err := targetDB.First(target).Error; err != nil {
-		return fmt.Errorf("target entity not found: %w", err)
+		return referenceChange{}, fmt.Errorf("target entity not found: %w", err)
 	}
 
 	// Use GORM's association API to delete the relationship
-	if err := h.db.Model(parent).Association(navProp.Name).Delete(target); err != nil {
-		return fmt.Errorf("failed to delete association: %w", err)
+	if err := tx.Model(parent).Association(navProp.Name).Delete(target); err != nil {
+		return referenceChange{}, fmt.Errorf("failed to delete association: %w", err)
 	}
 
-	return nil
+	return h.recordReferenceChange(tx, navProp, targetMetadata, parent, target)
 }
 
 // buildTargetKeyQuery builds a database query to find an entity by key in a different entity set
-func (h *EntityHandler) buildTargetKeyQuery(keyString string, targetMetadata *metadata.EntityMetadata) (*gorm.DB, error) {
+func (h *EntityHandler) buildTargetKeyQuery(tx *gorm.DB, keyString string, targetMetadata *metadata.EntityMetadata) (*gorm.DB, error) {
 	// Parse the key string and build query conditions
 	// This reuses the logic from buildKeyQuery but with target metadata
 
-	db := h.db.Model(reflect.New(targetMetadata.EntityType).Interface())
+	db := tx.Model(reflect.New(targetMetadata.EntityType).Interface())
 
 	// Check if this is a composite key (contains '=' or ',')
 	if strings.Contains(keyString, "=") || strings.Contains(keyString, ",") {
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/cache"
+	"github.com/nlstn/go-odata/internal/etag"
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/preference"
+	"github.com/nlstn/go-odata/internal/response"
+)
+
+// responseCacheVary lists the request headers every cached response varies on.
+var responseCacheVary = []string{"Accept", "Prefer", "OData-MaxVersion"}
+
+// ResponseCachePolicy configures the HTTP response cache of an entity set.
+type ResponseCachePolicy struct {
+	// TTL bounds how long a response is served from the cache.
+	TTL time.Duration
+	// MaxEntries bounds the number of cached responses of the entity set. Zero
+	// means no bound.
+	MaxEntries int
+	// MaxBodySize bounds the size in bytes of a cached response body. Larger
+	// responses, such as streamed collections and exports, are sent as they
+	// are produced and not cached. Zero means no bound.
+	MaxBodySize int
+	// CacheControl is sent as the Cache-Control header when not empty.
+	CacheControl string
+	// Vary lists request headers that select different responses, in addition
+	// to Accept, Prefer and OData-MaxVersion.
+	Vary []string
+	// PartitionKey separates the responses of different principals or tenants.
+	PartitionKey func(r *http.Request) string
+	// Invalidator publishes writes to the entity set to the other replicas.
+	// Nil when the entity cache publishes them or they are not shared.
+	Invalidator cache.Invalidator
+}
+
+// SetResponseStore sets the response store shared by the service's entity
+// sets. Writes invalidate the entries that depend on the entity set even when
+// the entity set itself does not cache responses.
+func (h *EntityHandler) SetResponseStore(store *cache.ResponseStore) {
+	h.responseStore = store
+}
+
+// EnableResponseCache caches the responses of GET requests for the entity
+// set's collection, entities and $count in the response store.
+func (h *EntityHandler) EnableResponseCache(policy ResponseCachePolicy) error {
+	if h.responseStore == nil {
+		return fmt.Errorf("response store is not configured for '%s'", h.metadata.EntitySetName)
+	}
+	if policy.TTL <= 0 {
+		return fmt.Errorf("response cache TTL must be positive")
+	}
+	vary := append([]string(nil), responseCacheVary...)
+	for _, name := range policy.Vary {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name != "" && !containsFold(vary, name) {
+			vary = append(vary, name)
+		}
+	}
+	policy.Vary = vary
+	h.responseCache = &policy
+	return nil
+}
+
+// serveWithResponseCache answers the request from the response cache, or runs
+// serve and caches a successful response. Conditional requests whose
+// If-None-Match names the response's ETag are answered with 304 Not Modified.
+func (h *EntityHandler) serveWithResponseCache(w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	if h.responseCache == nil || h.responseStore == nil || !h.isResponseCacheable(r) {
+		serve(w, r)
+		return
+	}
+
+	key := h.responseCacheKey(r)
+	if cached, ok := h.responseStore.Get(key); ok {
+		h.writeCachedResponse(w, r, cached)
+		return
+	}
+	if r.Method != http.MethodGet {
+		serve(w, r)
+		return
+	}
+
+	// Taken before the read, so that a write committing while the response is
+	// produced keeps it out of the store.
+	generation := h.responseStore.Generation()
+	// Headers set before serve, by middleware wrapping the service, belong to
+	// this request only and are not cached.
+	before := w.Header().Clone()
+	buffer := &bufferedResponseWriter{w: w, limit: h.responseCache.MaxBodySize}
+	serve(buffer, r)
+	if buffer.spilled {
+		return
+	}
+	if buffer.statusCode() != http.StatusOK {
+		w.WriteHeader(buffer.statusCode())
+		if _, err := w.Write(buffer.body.Bytes()); err != nil {
+			h.logger.Error("Error writing response", "error", err)
+		}
+		return
+	}
+
+	body := buffer.body.Bytes()
+	tag := w.Header().Get(HeaderETag)
+	if tag == "" {
+		tag = responseETag(body)
+		w.Header().Set(HeaderETag, tag)
+	}
+	if h.responseCache.CacheControl != "" {
+		w.Header().Set("Cache-Control", h.responseCache.CacheControl)
+	}
+	w.Header().Set("Vary", strings.Join(h.responseCache.Vary, ", "))
+
+	cached := &cache.Response{
+		StatusCode: http.StatusOK,
+		Header:     producedHeader(before, w.Header()),
+		Body:       body,
+		ETag:       tag,
+	}
+	h.responseStore.Put(key, h.metadata.EntitySetName, h.responseCacheDependencies(), cached, h.responseCache.TTL, h.responseCache.MaxEntries, generation)
+	h.writeCachedResponse(w, r, cached)
+}
+
+// isResponseCacheable excludes requests whose response must not be shared:
+// reads inside a $batch change set see uncommitted writes, and change streams
+// and asynchronous requests do not produce a plain response.
+func (h *EntityHandler) isResponseCacheable(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if _, inTransaction := TransactionFromContext(r.Context()); inTransaction {
+		return false
+	}
+	if h.isChangeStreamRequest(r) {
+		return false
+	}
+	return !preference.ParsePrefer(r).RespondAsyncRequested
+}
+
+// responseCacheKey normalizes the request into the key of its cached response:
+// the service URL, the resource path, the query options in canonical order,
+// the Vary headers and the partition key.
+func (h *EntityHandler) responseCacheKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(response.BuildBaseURL(r))
+	b.WriteString(r.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(r.URL.Query().Encode())
+	for _, name := range h.responseCache.Vary {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	b.WriteString("\npartition:")
+	if h.responseCache.PartitionKey != nil {
+		b.WriteString(h.responseCache.PartitionKey(r))
+	} else {
+		b.WriteString(defaultResponsePartition(r))
+	}
+	return b.String()
+}
+
+// defaultResponsePartition partitions responses by the authenticated
+// principal, roles, claims and scopes, since policies may filter rows by any
+// of them.
+func defaultResponsePartition(r *http.Request) string {
+	principal, roles, claims, scopes := auth.ExtractFromContext(r.Context())
+	if principal == nil && roles == nil && claims == nil && scopes == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v|%v|%v|%v", principal, roles, claims, scopes)
+}
+
+// responseCacheDependencies returns the entity sets reachable from this one
+// through navigation properties. Responses may expand, filter or order by any
+// of them, so writes to them invalidate the cached responses.
+func (h *EntityHandler) responseCacheDependencies() []string {
+	seen := map[*metadata.EntityMetadata]bool{h.metadata: true}
+	queue := []*metadata.EntityMetadata{h.metadata}
+	var dependencies []string
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for i := range current.Properties {
+			if !current.Properties[i].IsNavigationProp {
+				continue
+			}
+			target, err := current.ResolveNavigationTarget(current.Properties[i].Name)
+			if err != nil || seen[target] {
+				continue
+			}
+			seen[target] = true
+			dependencies = append(dependencies, target.EntitySetName)
+			queue = append(queue, target)
+		}
+	}
+	return dependencies
+}
+
+// writeCachedResponse writes a cached response, or 304 Not Modified when the
+// request's If-None-Match matches its ETag.
+func (h *EntityHandler) writeCachedResponse(w http.ResponseWriter, r *http.Request, cached *cache.Response) {
+	header := w.Header()
+	for name, values := range cached.Header {
+		header[name] = values
+	}
+	if !etag.NoneMatch(r.Header.Get(HeaderIfNoneMatch), cached.ETag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+	w.WriteHeader(cached.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(cached.Body); err != nil {
+		h.logger.Error("Error writing response", "error", err)
+	}
+}
+
+// producedHeader returns the headers of after that are not in before with the
+// same values.
+func producedHeader(before, after http.Header) http.Header {
+	produced := make(http.Header, len(after))
+	for name, values := range after {
+		if previous, ok := before[name]; ok && slices.Equal(previous, values) {
+			continue
+		}
+		produced[name] = slices.Clone(values)
+	}
+	return produced
+}
+
+// responseETag returns a weak ETag derived from the response body.
+func responseETag(body []byte) string {
+	return `W/"` + strconv.FormatUint(xxhash.Sum64(body), 16) + `"`
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponseWriter collects a response so that it can be cached before
+// it is sent. Headers are written to the wrapped writer's header map directly.
+// Once the body exceeds limit, the collected part is sent and the rest of the
+// response passes through to the wrapped writer.
+type bufferedResponseWriter struct {
+	w       http.ResponseWriter
+	limit   int
+	status  int
+	body    bytes.Buffer
+	spilled bool
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.w.Header()
+}
+
+func (b *bufferedResponseWriter) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	if b.spilled {
+		return b.w.Write(p)
+	}
+	if b.limit > 0 && b.body.Len()+len(p) > b.limit {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+		return b.w.Write(p)
+	}
+	return b.body.Write(p)
+}
+
+// Flush flushes the wrapped writer once the response passes through to it.
+// Until then the response is still collected and there is nothing to flush.
+func (b *bufferedResponseWriter) Flush() {
+	if b.spilled {
+		_ = http.NewResponseController(b.w).Flush()
+	}
+}
+
+// spill sends the status and the collected body to the wrapped writer.
+func (b *bufferedResponseWriter) spill() error {
+	b.spilled = true
+	b.w.WriteHeader(b.statusCode())
+	_, err := b.w.Write(b.body.Bytes())
+	b.body = bytes.Buffer{}
+	return err
+}
+
+func (b *bufferedResponseWriter) statusCode() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
 )
 
 func isOperationProhibited(annotations *metadata.AnnotationCollection, term, field string) bool {
@@ -69,3 +70,292 @@ func (h *EntityHandler) enforceDeleteRestrictions(w http.ResponseWriter, r *http
 
 	return true
 }
+
+// capabilityRecords returns the record values of a Capabilities restriction term.
+// Entity set annotations take precedence over annotations on the entity type, which
+// are consulted as a fallback since both are accepted for registering restrictions.
+func (h *EntityHandler) capabilityRecords(term string) []map[string]interface{} {
+	if h.metadata == nil {
+		return nil
+	}
+
+	var records []map[string]interface{}
+	for _, annotations := range []*metadata.AnnotationCollection{h.metadata.EntitySetAnnotations, h.metadata.Annotations} {
+		for _, annotation := range annotations.GetByTerm(term) {
+			if record, ok := annotation.Value.(map[string]interface{}); ok {
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// capabilityFlag reports the value of a boolean restriction field. The second return
+// value is false when no annotation defines the field.
+func (h *EntityHandler) capabilityFlag(term, field string) (bool, bool) {
+	for _, record := range h.capabilityRecords(term) {
+		if rawValue, ok := record[field]; ok {
+			if boolValue, ok := boolFromAnnotationValue(rawValue); ok {
+				return boolValue, true
+			}
+		}
+	}
+	return false, false
+}
+
+// capabilityPaths collects the property or navigation property paths listed in a
+// collection-valued restriction field such as NonFilterableProperties.
+func (h *EntityHandler) capabilityPaths(term, field string) []string {
+	var paths []string
+	for _, record := range h.capabilityRecords(term) {
+		paths = append(paths, pathsFromAnnotationValue(record[field])...)
+	}
+	return paths
+}
+
+// capabilityInt reports the value of an integer restriction field such as MaxLevels.
+func (h *EntityHandler) capabilityInt(term, field string) (int, bool) {
+	for _, record := range h.capabilityRecords(term) {
+		switch typed := record[field].(type) {
+		case int:
+			return typed, true
+		case int32:
+			return int(typed), true
+		case int64:
+			return int(typed), true
+		case float64:
+			return int(typed), true
+		}
+	}
+	return 0, false
+}
+
+// pathsFromAnnotationValue accepts path collections expressed as plain strings or as
+// records carrying a PropertyPath / NavigationPropertyPath member.
+func pathsFromAnnotationValue(value interface{}) []string {
+	switch typed := value.(type) {
+	case string:
+		if typed == "" {
+			return nil
+		}
+		return []string{typed}
+	case []string:
+		return typed
+	case []interface{}:
+		var paths []string
+		for _, item := range typed {
+			paths = append(paths, pathsFromAnnotationValue(item)...)
+		}
+		return paths
+	case []map[string]interface{}:
+		var paths []string
+		for _, item := range typed {
+			paths = append(paths, pathsFromAnnotationValue(item)...)
+		}
+		return paths
+	case map[string]interface{}:
+		for _, key := range []string{"PropertyPath", "NavigationPropertyPath", "$PropertyPath", "$NavigationPropertyPath"} {
+			if path, ok := typed[key].(string); ok && path != "" {
+				return []string{path}
+			}
+		}
+	}
+	return nil
+}
+
+// restrictedPath returns the restricted path that covers the given property path. A
+// restriction on a complex or navigation property also covers the paths below it.
+func restrictedPath(restricted []string, path string) (string, bool) {
+	for _, candidate := range restricted {
+		if strings.EqualFold(candidate, path) || strings.HasPrefix(strings.ToLower(path), strings.ToLower(candidate)+"/") {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// validateQueryRestrictions enforces the Capabilities FilterRestrictions, SortRestrictions,
+// ExpandRestrictions, SearchRestrictions and CountRestrictions advertised for the entity set.
+// isCollection distinguishes collection reads, where RequiresFilter and RequiredProperties
+// apply, from single entity reads. It must run before policy filters are merged into
+// $filter so that only client-supplied expressions are checked.
+func (h *EntityHandler) validateQueryRestrictions(queryOptions *query.QueryOptions, isCollection bool) error {
+	if queryOptions == nil {
+		return nil
+	}
+
+	if err := h.validateFilterRestrictions(queryOptions, isCollection); err != nil {
+		return err
+	}
+	if err := h.validateSortRestrictions(queryOptions.OrderBy); err != nil {
+		return err
+	}
+	if err := h.validateExpandRestrictions(queryOptions.Expand); err != nil {
+		return err
+	}
+
+	if queryOptions.Search != "" {
+		if searchable, ok := h.capabilityFlag(metadata.CapSearchRestrictions, "Searchable"); ok && !searchable {
+			return fmt.Errorf("$search is not supported for entity set '%s' (Capabilities.SearchRestrictions/Searchable)", h.metadata.EntitySetName)
+		}
+	}
+
+	if queryOptions.Count {
+		if err := h.validateCountRestriction(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateCountRestriction rejects $count requests when CountRestrictions/Countable is false.
+func (h *EntityHandler) validateCountRestriction() error {
+	if countable, ok := h.capabilityFlag(metadata.CapCountRestrictions, "Countable"); ok && !countable {
+		return fmt.Errorf("$count is not supported for entity set '%s' (Capabilities.CountRestrictions/Countable)", h.metadata.EntitySetName)
+	}
+	return nil
+}
+
+func (h *EntityHandler) validateFilterRestrictions(queryOptions *query.QueryOptions, isCollection bool) error {
+	filters := make([]*query.FilterExpression, 0, 1)
+	if queryOptions.Filter != nil {
+		filters = append(filters, queryOptions.Filter)
+	}
+	for i := range queryOptions.Apply {
+		if queryOptions.Apply[i].Type == query.ApplyTypeFilter && queryOptions.Apply[i].Filter != nil {
+			filters = append(filters, queryOptions.Apply[i].Filter)
+		}
+	}
+
+	if len(filters) > 0 {
+		if filterable, ok := h.capabilityFlag(metadata.CapFilterRestrictions, "Filterable"); ok && !filterable {
+			return fmt.Errorf("$filter is not supported for entity set '%s' (Capabilities.FilterRestrictions/Filterable)", h.metadata.EntitySetName)
+		}
+	}
+
+	nonFilterable := h.capabilityPaths(metadata.CapFilterRestrictions, "NonFilterableProperties")
+	referenced := make(map[string]bool)
+	for _, filter := range filters {
+		for _, path := range query.FilterPropertyPaths(filter) {
+			if restricted, ok := restrictedPath(nonFilterable, path); ok {
+				return fmt.Errorf("property '%s' cannot be used in $filter: '%s' is listed in Capabilities.FilterRestrictions/NonFilterableProperties", path, restricted)
+			}
+			referenced[strings.ToLower(path)] = true
+		}
+	}
+
+	if !isCollection {
+		return nil
+	}
+
+	if requiresFilter, ok := h.capabilityFlag(metadata.CapFilterRestrictions, "RequiresFilter"); ok && requiresFilter && queryOptions.Filter == nil {
+		return fmt.Errorf("entity set '%s' requires a $filter (Capabilities.FilterRestrictions/RequiresFilter)", h.metadata.EntitySetName)
+	}
+
+	for _, required := range h.capabilityPaths(metadata.CapFilterRestrictions, "RequiredProperties") {
+		if !referenced[strings.ToLower(required)] {
+			return fmt.Errorf("$filter must reference property '%s' (Capabilities.FilterRestrictions/RequiredProperties)", required)
+		}
+	}
+
+	return nil
+}
+
+func (h *EntityHandler) validateSortRestrictions(orderBy []query.OrderByItem) error {
+	if len(orderBy) == 0 {
+		return nil
+	}
+
+	if sortable, ok := h.capabilityFlag(metadata.CapSortRestrictions, "Sortable"); ok && !sortable {
+		return fmt.Errorf("$orderby is not supported for entity set '%s' (Capabilities.SortRestrictions/Sortable)", h.metadata.EntitySetName)
+	}
+
+	nonSortable := h.capabilityPaths(metadata.CapSortRestrictions, "NonSortableProperties")
+	ascendingOnly := h.capabilityPaths(metadata.CapSortRestrictions, "AscendingOnlyProperties")
+	descendingOnly := h.capabilityPaths(metadata.CapSortRestrictions, "DescendingOnlyProperties")
+
+	for _, item := range orderBy {
+		if restricted, ok := restrictedPath(nonSortable, item.Property); ok {
+			return fmt.Errorf("property '%s' cannot be used in $orderby: '%s' is listed in Capabilities.SortRestrictions/NonSortableProperties", item.Property, restricted)
+		}
+		if _, ok := restrictedPath(ascendingOnly, item.Property); ok && item.Descending {
+			return fmt.Errorf("property '%s' can only be sorted in ascending order (Capabilities.SortRestrictions/AscendingOnlyProperties)", item.Property)
+		}
+		if _, ok := restrictedPath(descendingOnly, item.Property); ok && !item.Descending {
+			return fmt.Errorf("property '%s' can only be sorted in descending order (Capabilities.SortRestrictions/DescendingOnlyProperties)", item.Property)
+		}
+	}
+
+	return nil
+}
+
+func (h *EntityHandler) validateExpandRestrictions(expand []query.ExpandOption) error {
+	if len(expand) == 0 {
+		return nil
+	}
+
+	if expandable, ok := h.capabilityFlag(metadata.CapExpandRestrictions, "Expandable"); ok && !expandable {
+		return fmt.Errorf("$expand is not supported for entity set '%s' (Capabilities.ExpandRestrictions/Expandable)", h.metadata.EntitySetName)
+	}
+
+	maxLevels, hasMaxLevels := h.capabilityInt(metadata.CapExpandRestrictions, "MaxLevels")
+	if hasMaxLevels && maxLevels < 0 {
+		hasMaxLevels = false
+	}
+
+	return h.validateExpandPaths(expand, "", 1, restrictionLimits{
+		nonExpandable: h.capabilityPaths(metadata.CapExpandRestrictions, "NonExpandableProperties"),
+		nonCountable:  h.capabilityPaths(metadata.CapCountRestrictions, "NonCountableNavigationProperties"),
+		maxLevels:     maxLevels,
+		hasMaxLevels:  hasMaxLevels,
+	})
+}
+
+// restrictionLimits bundles the expand-related restrictions resolved for an entity set.
+type restrictionLimits struct {
+	nonExpandable []string
+	nonCountable  []string
+	maxLevels     int
+	hasMaxLevels  bool
+}
+
+func (h *EntityHandler) validateExpandPaths(expand []query.ExpandOption, prefix string, depth int, limits restrictionLimits) error {
+	for i := range expand {
+		expandOpt := &expand[i]
+		path := expandOpt.NavigationProperty
+		if prefix != "" {
+			path = prefix + "/" + path
+		}
+
+		if restricted, ok := restrictedPath(limits.nonExpandable, path); ok {
+			return fmt.Errorf("navigation property '%s' cannot be expanded: '%s' is listed in Capabilities.ExpandRestrictions/NonExpandableProperties", path, restricted)
+		}
+
+		if limits.hasMaxLevels {
+			levels := depth
+			if expandOpt.Levels != nil {
+				if *expandOpt.Levels < 0 {
+					return fmt.Errorf("$levels=max exceeds the maximum expand depth of %d (Capabilities.ExpandRestrictions/MaxLevels)", limits.maxLevels)
+				}
+				levels = depth + *expandOpt.Levels - 1
+			}
+			if levels > limits.maxLevels {
+				return fmt.Errorf("expanding '%s' exceeds the maximum expand depth of %d (Capabilities.ExpandRestrictions/MaxLevels)", path, limits.maxLevels)
+			}
+		}
+
+		if expandOpt.Count {
+			if restricted, ok := restrictedPath(limits.nonCountable, path); ok {
+				return fmt.Errorf("navigation property '%s' cannot be counted: '%s' is listed in Capabilities.CountRestrictions/NonCountableNavigationProperties", path, restricted)
+			}
+		}
+
+		if len(expandOpt.Expand) > 0 {
+			if err := h.validateExpandPaths(expandOpt.Expand, path, depth+1, limits); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
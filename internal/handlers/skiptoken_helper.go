@@ -8,17 +8,32 @@ import (
 	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/response"
 	"github.com/nlstn/go-odata/internal/skiptoken"
+	"github.com/nlstn/go-odata/internal/tokensign"
 )
 
+const (
+	skipTokenScopeKind  = "skiptoken"
+	deltaTokenScopeKind = "deltatoken"
+)
+
+// continuationTokenScope returns the scope a $skiptoken or $deltatoken is bound to when token
+// signing is enabled: the token kind, the entity set and the query shape ($filter, $orderby).
+func continuationTokenScope(kind, entitySet string, r *http.Request) string {
+	params := query.NormalizeQueryParams(r.URL.Query())
+	return tokensign.Scope(kind, entitySet, params.Get("$filter"), params.Get("$orderby"))
+}
+
 // buildNextLinkWithSkipToken constructs a next link URL using $skiptoken when possible.
 // The caller must ensure the result slice uses a deterministic ordering that matches the
 // provided query options (e.g., explicit $orderby or stable key ordering) so that the
-// generated token can be decoded reliably on subsequent requests.
+// generated token can be decoded reliably on subsequent requests. When signer is non-nil the
+// token is signed and bound to the entity set and query shape of r.
 func buildNextLinkWithSkipToken(
 	meta *metadata.EntityMetadata,
 	queryOptions *query.QueryOptions,
 	sliceValue interface{},
 	r *http.Request,
+	signer *tokensign.Signer,
 ) *string {
 	if queryOptions.Top == nil {
 		return nil
@@ -34,8 +49,18 @@ func buildNextLinkWithSkipToken(
 		return nil
 	}
 
-	lastEntity := v.Index(lastIndex).Interface()
+	return buildNextLinkForLastEntity(meta, queryOptions, v.Index(lastIndex).Interface(), r, signer)
+}
 
+// buildNextLinkForLastEntity constructs a $skiptoken next link that resumes after
+// lastEntity, the last entity of the current page.
+func buildNextLinkForLastEntity(
+	meta *metadata.EntityMetadata,
+	queryOptions *query.QueryOptions,
+	lastEntity interface{},
+	r *http.Request,
+	signer *tokensign.Signer,
+) *string {
 	keyProps := make([]string, len(meta.KeyProperties))
 	for i, kp := range meta.KeyProperties {
 		keyProps[i] = kp.JsonName
@@ -59,6 +84,8 @@ func buildNextLinkWithSkipToken(
 		return nil
 	}
 
+	encoded = signer.Sign(encoded, continuationTokenScope(skipTokenScopeKind, meta.EntitySetName, r))
+
 	nextURL := response.BuildNextLinkWithSkipToken(r, encoded)
 	return &nextURL
 }
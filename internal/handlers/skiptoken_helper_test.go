@@ -47,7 +47,7 @@ func TestBuildNextLinkWithSkipTokenReturnsNil(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := buildNextLinkWithSkipToken(meta, tt.queryOptions, tt.sliceValue, request)
+			result := buildNextLinkWithSkipToken(meta, tt.queryOptions, tt.sliceValue, request, nil)
 			if result != nil {
 				t.Fatalf("expected nil next link, got %q", *result)
 			}
@@ -70,7 +70,7 @@ func TestBuildNextLinkWithSkipTokenSuccess(t *testing.T) {
 	}
 	request := httptest.NewRequest(http.MethodGet, "http://example.test/Entities", nil)
 
-	nextLink := buildNextLinkWithSkipToken(meta, queryOptions, entities, request)
+	nextLink := buildNextLinkWithSkipToken(meta, queryOptions, entities, request, nil)
 	if nextLink == nil {
 		t.Fatal("expected next link, got nil")
 	}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/etag"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/softdelete"
+	"github.com/nlstn/go-odata/internal/trackchanges"
+	"gorm.io/gorm"
+)
+
+// deleteEntity removes an entity inside a write transaction. Entities with a
+// soft delete property keep their row and have the property stamped instead;
+// the softdelete query callbacks hide the row from subsequent reads.
+func (h *EntityHandler) deleteEntity(tx *gorm.DB, entity interface{}) error {
+	prop := h.metadata.SoftDeleteProperty
+	if prop == nil {
+		return tx.Delete(entity).Error
+	}
+	now := time.Now().UTC()
+	if err := tx.Model(entity).UpdateColumn(prop.ColumnName, now).Error; err != nil {
+		return err
+	}
+	h.setSoftDeleteField(entity, &now)
+	return nil
+}
+
+// setSoftDeleteField updates the soft delete field of a fetched entity so that
+// change events and responses reflect the stored state.
+func (h *EntityHandler) setSoftDeleteField(entity interface{}, value *time.Time) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	if field := v.FieldByName(h.metadata.SoftDeleteProperty.FieldName); field.IsValid() && field.CanSet() {
+		field.Set(reflect.ValueOf(value))
+	}
+}
+
+// isSoftDeleted reports whether a fetched entity carries a deletion timestamp.
+func (h *EntityHandler) isSoftDeleted(entity interface{}) bool {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	field := v.FieldByName(h.metadata.SoftDeleteProperty.FieldName)
+	return field.IsValid() && !field.IsNil()
+}
+
+// deletedScope widens a read to the deleted entities selected by $deleted.
+func (h *EntityHandler) deletedScope(mode query.DeletedMode) func(*gorm.DB) *gorm.DB {
+	column := h.metadata.SoftDeleteProperty.ColumnName
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Unscoped()
+		if mode == query.DeletedOnly {
+			db = db.Where(softdelete.Deleted(column))
+		}
+		return db
+	}
+}
+
+// authorizeDeletedAccess checks that the caller may read or restore deleted
+// entities. Unlike other operations, access is denied when no policy is
+// configured: deleted entities are only exposed to callers a policy grants
+// OperationReadDeleted or OperationRestore.
+func (h *EntityHandler) authorizeDeletedAccess(w http.ResponseWriter, r *http.Request, resource auth.ResourceDescriptor, operation auth.Operation) bool {
+	if h.policy == nil {
+		if err := response.WriteError(w, r, http.StatusForbidden, "Forbidden",
+			"access to deleted entities requires an authorization policy"); err != nil {
+			h.logger.Error("Error writing error response", "error", err)
+		}
+		return false
+	}
+	return authorizeRequest(w, r, h.policy, resource, operation, h.logger)
+}
+
+// FetchEntityIncludingDeleted fetches an entity by its key string, including
+// soft-deleted entities. It behaves like FetchEntity for entity sets without a
+// soft delete property.
+func (h *EntityHandler) FetchEntityIncludingDeleted(ctx context.Context, entityKey string) (interface{}, error) {
+	queryOptions := &query.QueryOptions{}
+	if h.metadata.SoftDeleteProperty != nil {
+		queryOptions.Deleted = query.DeletedInclude
+	}
+	return h.fetchEntityByKey(ctx, entityKey, queryOptions, nil)
+}
+
+// RestoreEntity clears the deletion timestamp of a soft-deleted entity and
+// writes the restored entity. It implements the Restore action bound to soft
+// delete entity sets; restoring an entity that is not deleted leaves it
+// unchanged. The entity re-enters delta responses as a change.
+func (h *EntityHandler) RestoreEntity(w http.ResponseWriter, r *http.Request, entity interface{}) error {
+	ctx := r.Context()
+	if h.metadata.SoftDeleteProperty == nil {
+		WriteError(w, r, http.StatusNotImplemented, ErrMsgNotImplemented, "entity set does not support soft delete")
+		return nil
+	}
+	if entity == nil {
+		WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, "Restore must be invoked on a single entity")
+		return nil
+	}
+	if !h.authorizeDeletedAccess(w, r, buildEntityResourceDescriptorWithEntity(h.metadata, "", entity, nil), auth.OperationRestore) {
+		return nil
+	}
+
+	if h.isSoftDeleted(entity) {
+		var changeEvents []changeEvent
+		if err := h.runInTransaction(ctx, r, func(tx *gorm.DB, _ *http.Request) error {
+			if err := tx.Model(entity).UpdateColumn(h.metadata.SoftDeleteProperty.ColumnName, nil).Error; err != nil {
+				return err
+			}
+			h.setSoftDeleteField(entity, nil)
+
+			changeEvents = append(changeEvents, changeEvent{entity: entity, changeType: trackchanges.ChangeTypeAdded})
+			if err := h.appendTemporalHistory(tx, changeEvents); err != nil {
+				return err
+			}
+			return h.appendOutboxEvents(tx, changeEvents)
+		}); err != nil {
+			if isTransactionHandled(err) {
+				return nil
+			}
+			h.writeDatabaseError(w, r, err)
+			return nil
+		}
+
+		h.finalizeChangeEvents(ctx, changeEvents)
+		h.invalidateCache(ctx)
+	}
+
+	var currentETag string
+	if h.metadata.ETagProperty != nil {
+		currentETag = etag.Generate(entity, h.metadata)
+	}
+	h.writeEntityResponseWithETag(w, r, entity, currentETag, http.StatusOK, nil, nil)
+	return nil
+}
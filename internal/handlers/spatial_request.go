@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/nlstn/go-odata/internal/geo"
+)
+
+// decodeSpatialPropertiesInPlace replaces the JSON-decoded values of
+// geography and geometry properties in data with values of the property's Go
+// type.
+//
+// Clients send spatial values as GeoJSON objects or WKT strings. POST and PUT
+// decode the request into the entity struct, whose spatial fields convert
+// themselves, but GORM's Updates(map) used for PATCH writes map values as they
+// are. Converting them here lets the field type's driver.Valuer encode the
+// column and rejects malformed values and shapes of the wrong kind.
+func (h *EntityHandler) decodeSpatialPropertiesInPlace(data map[string]interface{}) error {
+	for i := range h.metadata.Properties {
+		prop := &h.metadata.Properties[i]
+		edmType, ok := geo.EdmTypeOf(prop.Type)
+		if !ok {
+			continue
+		}
+
+		keys := []string{prop.JsonName}
+		if prop.Name != prop.JsonName {
+			keys = append(keys, prop.Name)
+		}
+		for _, key := range keys {
+			raw, exists := data[key]
+			if !exists || raw == nil {
+				continue
+			}
+			encoded, err := json.Marshal(raw)
+			if err != nil {
+				return fmt.Errorf("property '%s': %w", prop.JsonName, err)
+			}
+			target := reflect.New(prop.Type)
+			if err := json.Unmarshal(encoded, target.Interface()); err != nil {
+				return fmt.Errorf("property '%s' expects a %s value: %w", prop.JsonName, edmType, err)
+			}
+			data[key] = target.Elem().Interface()
+		}
+	}
+	return nil
+}
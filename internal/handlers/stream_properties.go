@@ -7,8 +7,10 @@ import (
 	"reflect"
 
 	"github.com/nlstn/go-odata/internal/auth"
+	"github.com/nlstn/go-odata/internal/etag"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/trackchanges"
 	"gorm.io/gorm"
 )
 
@@ -59,7 +61,7 @@ func (h *EntityHandler) handleGetStreamProperty(w http.ResponseWriter, r *http.R
 
 	// Fetch the entity
 	entity := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
@@ -110,16 +112,7 @@ func (h *EntityHandler) handleGetStreamProperty(w http.ResponseWriter, r *http.R
 
 	if isValue {
 		// Return binary content with appropriate Content-Type
-		if contentType == "" {
-			contentType = "application/octet-stream"
-		}
-		w.Header().Set("Content-Type", contentType)
-		w.WriteHeader(http.StatusOK)
-		if r.Method != http.MethodHead {
-			if _, err := w.Write(content); err != nil {
-				h.logger.Error("Error writing stream content", "error", err)
-			}
-		}
+		writeMediaContent(w, r, content, contentType, etag.GenerateStrong(entity, h.metadata))
 	} else {
 		// Return metadata about the stream (not the binary content)
 		// Return stream property information with read/edit links
@@ -176,7 +169,7 @@ func (h *EntityHandler) handlePutStreamProperty(w http.ResponseWriter, r *http.R
 
 	// Fetch the entity
 	entity := reflect.New(h.metadata.EntityType).Interface()
-	db, err := h.buildKeyQuery(h.db, entityKey)
+	db, err := h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
@@ -229,14 +222,27 @@ func (h *EntityHandler) handlePutStreamProperty(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	// Save the entity
-	if err := h.db.Save(entity).Error; err != nil {
+	// Save the entity, together with the version it produces in the history
+	// of a temporal entity set and its outbox event
+	events := []changeEvent{{entity: entity, changeType: trackchanges.ChangeTypeUpdated}}
+	err = h.runInTransaction(r.Context(), r, func(tx *gorm.DB, _ *http.Request) error {
+		if err := tx.Save(entity).Error; err != nil {
+			return err
+		}
+		if err := h.appendTemporalHistory(tx, events); err != nil {
+			return err
+		}
+		return h.appendOutboxEvents(tx, events)
+	})
+	if err != nil {
 		if writeErr := response.WriteError(w, r, http.StatusInternalServerError, ErrMsgInternalError,
 			fmt.Sprintf("Failed to update stream property: %v", err)); writeErr != nil {
 			h.logger.Error("Error writing error response", "error", writeErr)
 		}
 		return
 	}
+	h.finalizeChangeEvents(r.Context(), events)
+	h.invalidateCache(r.Context())
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -272,6 +278,11 @@ func (h *EntityHandler) applyStreamPropertySelect(db *gorm.DB, prop *metadata.Pr
 		selectColumns = append(selectColumns, keyProp.Name)
 	}
 
+	// Add the ETag property, from which the value's ETag is derived
+	if h.metadata.ETagProperty != nil {
+		selectColumns = append(selectColumns, h.metadata.ETagProperty.Name)
+	}
+
 	// If no select columns found, don't apply SELECT (shouldn't happen but safe fallback)
 	if len(selectColumns) == 0 {
 		return db
@@ -372,3 +372,33 @@ func TestHandleStreamProperty_PutNonExistentEntity(t *testing.T) {
 		t.Errorf("Status = %v, want %v", w.Code, http.StatusNotFound)
 	}
 }
+
+func TestHandleStreamProperty_GetValueRange(t *testing.T) {
+	handler, db := setupStreamPropertyHandler(t)
+
+	entity := StreamPropertyTestEntity{
+		ID:               1,
+		Name:             "Test Entity",
+		PhotoContent:     []byte("binary photo data"),
+		PhotoContentType: "image/jpeg",
+	}
+	if err := db.Create(&entity).Error; err != nil {
+		t.Fatalf("Failed to create test data: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/StreamPropertyTestEntities(1)/Photo/$value", nil)
+	req.Header.Set("Range", "bytes=7-11")
+	w := httptest.NewRecorder()
+
+	handler.HandleStreamProperty(w, req, "1", "Photo", true)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("Status = %v, want %v. Body: %s", w.Code, http.StatusPartialContent, w.Body.String())
+	}
+	if w.Body.String() != "photo" {
+		t.Errorf("body = %q, want 'photo'", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 7-11/17" {
+		t.Errorf("Content-Range = %q, want 'bytes 7-11/17'", got)
+	}
+}
@@ -58,6 +58,36 @@ func (h *EntityHandler) handleGetStructuralProperty(w http.ResponseWriter, r *ht
 	}
 }
 
+// handleGetCollectionPropertyCount handles GET requests for the $count of a
+// collection of primitive or complex values (e.g., Products(1)/Tags/$count)
+func (h *EntityHandler) handleGetCollectionPropertyCount(w http.ResponseWriter, r *http.Request, entityKey string, prop *metadata.PropertyMetadata) {
+	fieldValue, err := h.fetchPropertyValue(w, r, entityKey, prop)
+	if err != nil {
+		return // Error already written
+	}
+
+	var count int64
+	for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+		fieldValue = fieldValue.Elem()
+	}
+	if fieldValue.Kind() == reflect.Slice {
+		count = int64(fieldValue.Len())
+	}
+
+	// Write the count as plain text according to OData v4 spec
+	w.Header().Set(HeaderContentType, "text/plain")
+	w.WriteHeader(http.StatusOK)
+
+	// For HEAD requests, don't write the body
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := fmt.Fprintf(w, "%d", count); err != nil {
+		h.logger.Error("Error writing count response", "error", err)
+	}
+}
+
 // handleOptionsStructuralProperty handles OPTIONS requests for structural properties
 func (h *EntityHandler) handleOptionsStructuralProperty(w http.ResponseWriter) {
 	w.Header().Set("Allow", "GET, HEAD, OPTIONS")
@@ -77,7 +107,7 @@ func (h *EntityHandler) fetchPropertyValue(w http.ResponseWriter, r *http.Reques
 		db = h.db
 	} else {
 		// For regular entities, build the key query
-		db, err = h.buildKeyQuery(h.db, entityKey)
+		db, err = h.buildKeyQuery(h.db.WithContext(r.Context()), entityKey)
 		if err != nil {
 			if writeErr := response.WriteError(w, r, http.StatusBadRequest, ErrMsgInvalidKey, err.Error()); writeErr != nil {
 				h.logger.Error("Error writing error response", "error", writeErr)
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/response"
+	"github.com/nlstn/go-odata/internal/trackchanges"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Columns the history table adds to the columns of the entity table. A version
+// is valid in the half-open period [odata_valid_from, odata_valid_to); the open
+// version of an existing entity has no odata_valid_to.
+const (
+	temporalVersionColumn   = "odata_version_id"
+	temporalValidFromColumn = "odata_valid_from"
+	temporalValidToColumn   = "odata_valid_to"
+)
+
+// temporalHistory describes the history table of a temporal entity set.
+type temporalHistory struct {
+	table      string
+	entity     string
+	columns    []string
+	keyColumns []string
+}
+
+// EnableTemporal keeps a history of every version of the entity set's entities
+// in historyTable, creating the table when needed. The history is written in the
+// transaction of each create, update and delete, and makes the entity set
+// answer $at, $from and $to. Entities that exist without an open version are
+// recorded as valid from now.
+func (h *EntityHandler) EnableTemporal(historyTable string) error {
+	if h.metadata == nil {
+		return fmt.Errorf("entity metadata is not initialized")
+	}
+	if h.metadata.IsSingleton {
+		return fmt.Errorf("temporal history is not supported for singleton '%s'", h.metadata.EntitySetName)
+	}
+	if h.metadata.IsVirtual {
+		return fmt.Errorf("temporal history is not supported for virtual entity set '%s'", h.metadata.EntitySetName)
+	}
+	if len(h.metadata.KeyProperties) == 0 {
+		return fmt.Errorf("temporal history requires a key for entity set '%s'", h.metadata.EntitySetName)
+	}
+	if h.db == nil {
+		return fmt.Errorf("temporal history requires a database for entity set '%s'", h.metadata.EntitySetName)
+	}
+
+	stmt := &gorm.Statement{DB: h.db}
+	if err := stmt.Parse(reflect.New(h.metadata.EntityType).Interface()); err != nil {
+		return fmt.Errorf("failed to parse entity '%s': %w", h.metadata.EntityName, err)
+	}
+	entityTable := gormCanonicalTableName(h.db, h.metadata)
+	if historyTable == "" {
+		historyTable = entityTable + "_history"
+	}
+
+	history := &temporalHistory{table: historyTable, entity: entityTable}
+	for _, keyProp := range h.metadata.KeyProperties {
+		history.keyColumns = append(history.keyColumns, keyProp.ColumnName)
+	}
+	model, err := history.model(stmt.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to build history table for '%s': %w", h.metadata.EntitySetName, err)
+	}
+	if err := h.db.Table(historyTable).AutoMigrate(model); err != nil {
+		return fmt.Errorf("failed to migrate history table '%s': %w", historyTable, err)
+	}
+	if err := history.seed(h.db, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to seed history table '%s': %w", historyTable, err)
+	}
+
+	h.temporal = history
+	h.metadata.TemporalHistoryTable = historyTable
+	return nil
+}
+
+// model returns a value whose type maps to the history table: the entity's
+// columns without their key and uniqueness constraints, indexed by key, plus
+// the version id and validity period.
+func (t *temporalHistory) model(sch *schema.Schema) (interface{}, error) {
+	fields := make([]reflect.StructField, 0, len(sch.Fields)+3)
+	keyIndex := "idx_" + t.table + "_key"
+	for _, field := range sch.Fields {
+		if field.DBName == "" || field.IgnoreMigration {
+			continue
+		}
+		switch field.DBName {
+		case temporalVersionColumn, temporalValidFromColumn, temporalValidToColumn:
+			return nil, fmt.Errorf("column '%s' is reserved for the history table", field.DBName)
+		}
+		settings := []string{"column:" + field.DBName}
+		for _, name := range []string{"TYPE", "SIZE", "PRECISION", "SCALE", "SERIALIZER"} {
+			if value, ok := field.TagSettings[name]; ok {
+				settings = append(settings, strings.ToLower(name)+":"+value)
+			}
+		}
+		for _, column := range t.keyColumns {
+			if column == field.DBName {
+				settings = append(settings, "index:"+keyIndex)
+			}
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Column%d", len(fields)),
+			Type: field.FieldType,
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"%s"`, strings.Join(settings, ";"))),
+		})
+		t.columns = append(t.columns, field.DBName)
+	}
+	fields = append(fields,
+		reflect.StructField{
+			Name: "ODataVersionID",
+			Type: reflect.TypeOf(int64(0)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s;primaryKey;autoIncrement"`, temporalVersionColumn)),
+		},
+		reflect.StructField{
+			Name: "ODataValidFrom",
+			Type: reflect.TypeOf(time.Time{}),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s;not null;index:idx_%s_valid_from"`, temporalValidFromColumn, t.table)),
+		},
+		reflect.StructField{
+			Name: "ODataValidTo",
+			Type: reflect.TypeOf((*time.Time)(nil)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`gorm:"column:%s;index:idx_%s_valid_to"`, temporalValidToColumn, t.table)),
+		},
+	)
+	return reflect.New(reflect.StructOf(fields)).Interface(), nil
+}
+
+// seed records an open version, valid from now, for every entity without one.
+func (t *temporalHistory) seed(db *gorm.DB, now time.Time) error {
+	columns := quoteColumns(db, t.columns)
+	conditions := make([]string, len(t.keyColumns))
+	for i, column := range t.keyColumns {
+		conditions[i] = fmt.Sprintf("h.%s = e.%s", db.Statement.Quote(column), db.Statement.Quote(column))
+	}
+	sql := fmt.Sprintf("INSERT INTO %s (%s, %s) SELECT %s, ? FROM %s e WHERE NOT EXISTS (SELECT 1 FROM %s h WHERE %s AND h.%s IS NULL)",
+		db.Statement.Quote(t.table), columns, db.Statement.Quote(temporalValidFromColumn),
+		prefixColumns(db, "e", t.columns), db.Statement.Quote(t.entity),
+		db.Statement.Quote(t.table), strings.Join(conditions, " AND "), db.Statement.Quote(temporalValidToColumn))
+	return db.Exec(sql, now).Error
+}
+
+// appendTemporalHistory records the versions produced by events in the history
+// table, inside the write transaction tx so that the history commits or rolls
+// back together with the change. An added entity opens a version copied from
+// its row, an update closes the open version and opens a new one, and a delete
+// closes the open version.
+func (h *EntityHandler) appendTemporalHistory(tx *gorm.DB, events []changeEvent) error {
+	if h.temporal == nil || len(events) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, event := range events {
+		keyValues, err := h.temporalKeyValues(event.entity)
+		if err != nil {
+			return err
+		}
+		if event.changeType != trackchanges.ChangeTypeAdded {
+			if err := h.temporal.closeVersion(tx, keyValues, now); err != nil {
+				return err
+			}
+		}
+		if event.changeType != trackchanges.ChangeTypeDeleted {
+			if err := h.temporal.openVersion(tx, keyValues, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// temporalKeyValues returns the key column values of entity, in the order of
+// the history table's key columns.
+func (h *EntityHandler) temporalKeyValues(entity interface{}) ([]interface{}, error) {
+	entityValue := reflect.Indirect(reflect.ValueOf(entity))
+	values := make([]interface{}, 0, len(h.metadata.KeyProperties))
+	for _, keyProp := range h.metadata.KeyProperties {
+		field := entityValue.FieldByName(keyProp.FieldName)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("missing key property '%s' for temporal history", keyProp.JsonName)
+		}
+		values = append(values, field.Interface())
+	}
+	return values, nil
+}
+
+func (t *temporalHistory) keyCondition(db *gorm.DB) string {
+	conditions := make([]string, len(t.keyColumns))
+	for i, column := range t.keyColumns {
+		conditions[i] = db.Statement.Quote(column) + " = ?"
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func (t *temporalHistory) openVersion(tx *gorm.DB, keyValues []interface{}, now time.Time) error {
+	sql := fmt.Sprintf("INSERT INTO %s (%s, %s) SELECT %s, ? FROM %s WHERE %s",
+		tx.Statement.Quote(t.table), quoteColumns(tx, t.columns), tx.Statement.Quote(temporalValidFromColumn),
+		quoteColumns(tx, t.columns), tx.Statement.Quote(t.entity), t.keyCondition(tx))
+	return tx.Exec(sql, append([]interface{}{now}, keyValues...)...).Error
+}
+
+func (t *temporalHistory) closeVersion(tx *gorm.DB, keyValues []interface{}, now time.Time) error {
+	sql := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s AND %s IS NULL",
+		tx.Statement.Quote(t.table), tx.Statement.Quote(temporalValidToColumn),
+		t.keyCondition(tx), tx.Statement.Quote(temporalValidToColumn))
+	return tx.Exec(sql, append([]interface{}{now}, keyValues...)...).Error
+}
+
+// temporalScope reads the versions selected by opts from the history table in
+// place of the entity table. The history table is aliased to the entity table's
+// name, so filters, ordering and projections qualified with it apply unchanged.
+func (h *EntityHandler) temporalScope(opts *query.TemporalOptions) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		t := h.temporal
+		if t == nil {
+			return db
+		}
+		validFrom := db.Statement.Quote(t.entity + "." + temporalValidFromColumn)
+		validTo := db.Statement.Quote(t.entity + "." + temporalValidToColumn)
+		db = db.Table(db.Statement.Quote(t.table) + " AS " + db.Statement.Quote(t.entity))
+		if opts.At != nil {
+			return db.Where(fmt.Sprintf("%s <= ? AND (%s IS NULL OR %s > ?)", validFrom, validTo, validTo), *opts.At, *opts.At)
+		}
+		if opts.To != nil {
+			db = db.Where(fmt.Sprintf("%s < ?", validFrom), *opts.To)
+		}
+		if opts.From != nil {
+			db = db.Where(fmt.Sprintf("(%s IS NULL OR %s > ?)", validTo, validTo), *opts.From)
+		}
+		return db
+	}
+}
+
+// temporalVersionOrder orders the versions of each entity returned by a $from/$to
+// query chronologically, after any requested ordering.
+func (h *EntityHandler) temporalVersionOrder(db *gorm.DB) *gorm.DB {
+	return db.Order(db.Statement.Quote(h.temporal.entity + "." + temporalValidFromColumn)).
+		Order(db.Statement.Quote(h.temporal.entity + "." + temporalVersionColumn))
+}
+
+// temporalPeriod is the validity period of one version read from the history table.
+type temporalPeriod struct {
+	From time.Time  `gorm:"column:odata_valid_from"`
+	To   *time.Time `gorm:"column:odata_valid_to"`
+}
+
+// loadTemporalPeriods reads the validity periods of the count versions that a
+// query returned. periodDB must be a copy of the query's final statement taken
+// before the query was executed, so that both return the versions in the same
+// order.
+func (h *EntityHandler) loadTemporalPeriods(periodDB *gorm.DB, count int) ([]temporalPeriod, error) {
+	var periods []temporalPeriod
+	columns := fmt.Sprintf("%s, %s",
+		periodDB.Statement.Quote(h.temporal.entity+"."+temporalValidFromColumn),
+		periodDB.Statement.Quote(h.temporal.entity+"."+temporalValidToColumn))
+	if err := periodDB.Select(columns).Scan(&periods).Error; err != nil {
+		return nil, err
+	}
+	if len(periods) != count {
+		return nil, fmt.Errorf("temporal periods do not match the %d returned versions", count)
+	}
+	return periods, nil
+}
+
+// supplyTemporalPeriods supplies periods, which belong to the versions in
+// results in the same order, to the response writer.
+func supplyTemporalPeriods(ctx context.Context, results reflect.Value, periods []temporalPeriod) {
+	byAddress := make(map[uintptr]temporalPeriod, len(periods))
+	for i, period := range periods {
+		byAddress[results.Index(i).Addr().Pointer()] = period
+	}
+	response.SetTemporalPeriods(ctx, func(entity reflect.Value) (time.Time, *time.Time, bool) {
+		if !entity.CanAddr() {
+			return time.Time{}, nil, false
+		}
+		period, ok := byAddress[entity.Addr().Pointer()]
+		return period.From, period.To, ok
+	})
+}
+
+// withTemporalPeriods prepares r's context to carry the validity periods of the
+// versions returned by a $from/$to query of a temporal entity set.
+func (h *EntityHandler) withTemporalPeriods(r *http.Request) *http.Request {
+	if h.temporal == nil {
+		return r
+	}
+	return r.WithContext(response.WithTemporalPeriods(r.Context()))
+}
+
+// validateTemporalOptions rejects query options that cannot be evaluated
+// against the history of a temporal entity set.
+func validateTemporalOptions(queryOptions *query.QueryOptions) error {
+	if queryOptions.Temporal == nil {
+		return nil
+	}
+	switch {
+	case queryOptions.DeltaToken != nil:
+		return fmt.Errorf("$deltatoken cannot be combined with $at, $from or $to")
+	case queryOptions.Search != "":
+		return fmt.Errorf("$search cannot be combined with $at, $from or $to")
+	case len(queryOptions.Apply) > 0:
+		return fmt.Errorf("$apply cannot be combined with $at, $from or $to")
+	case queryOptions.SkipToken != nil && queryOptions.Temporal.IsPeriod():
+		return fmt.Errorf("$skiptoken cannot be combined with $from or $to")
+	}
+	return nil
+}
+
+func quoteColumns(db *gorm.DB, columns []string) string {
+	return prefixColumns(db, "", columns)
+}
+
+func prefixColumns(db *gorm.DB, prefix string, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = db.Statement.Quote(column)
+		if prefix != "" {
+			quoted[i] = prefix + "." + quoted[i]
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
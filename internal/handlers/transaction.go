@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/nlstn/go-odata/internal/storage"
@@ -13,6 +14,9 @@ import (
 type changeEvent struct {
 	entity     interface{}
 	changeType trackchanges.ChangeType
+	// untracked events reach the temporal history and the outbox but are not
+	// recorded for delta responses.
+	untracked bool
 }
 
 type pendingChangeEvent struct {
@@ -63,16 +67,63 @@ func (h *EntityHandler) runInTransaction(ctx context.Context, r *http.Request, f
 	}
 
 	return h.store.Transaction(ctx, func(tx storage.Tx) error {
-		txDB := tx.DB()
+		txDB, err := gormTransaction(tx)
+		if err != nil {
+			return err
+		}
 		return fn(txDB, requestWithTransaction(r, txDB))
 	})
 }
 
+// beginGORMTransaction begins a transaction of store and returns its GORM
+// transaction.
+func beginGORMTransaction(ctx context.Context, store storage.GORMStore) (*gorm.DB, error) {
+	tx, err := store.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txDB, err := gormTransaction(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return txDB, nil
+}
+
+// gormTransaction returns the GORM transaction of a transaction begun by a
+// storage.GORMStore.
+func gormTransaction(tx storage.Tx) (*gorm.DB, error) {
+	gormTx, ok := tx.(storage.GORMTx)
+	if !ok {
+		return nil, fmt.Errorf("storage transaction %T does not expose a GORM transaction", tx)
+	}
+	return gormTx.DB(), nil
+}
+
 func flushPendingChangeEvents(events []pendingChangeEvent) {
+	invalidated := make(map[string]bool)
+	var searchHandlers []*EntityHandler
+	searchEvents := make(map[*EntityHandler][]changeEvent)
 	for _, evt := range events {
 		if evt.handler == nil {
 			continue
 		}
-		evt.handler.recordChange(evt.event.entity, evt.event.changeType)
+		if !evt.event.untracked {
+			evt.handler.recordChange(evt.event.entity, evt.event.changeType)
+		}
+		evt.handler.notifyOutbox()
+		if _, seen := searchEvents[evt.handler]; !seen {
+			searchHandlers = append(searchHandlers, evt.handler)
+		}
+		searchEvents[evt.handler] = append(searchEvents[evt.handler], evt.event)
+		// The caches were invalidated before the change set committed; drop
+		// any snapshot refreshed since then and tell the other replicas.
+		if entitySet := evt.handler.metadata.EntitySetName; !invalidated[entitySet] {
+			invalidated[entitySet] = true
+			evt.handler.invalidateCache(context.Background())
+		}
+	}
+	for _, handler := range searchHandlers {
+		handler.updateSearch(context.Background(), searchEvents[handler])
 	}
 }
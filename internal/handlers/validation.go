@@ -6,9 +6,12 @@ import (
 	"log/slog"
 	"net/http"
 	"reflect"
+	"sort"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/odataerrors"
 	"github.com/nlstn/go-odata/internal/response"
 	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
@@ -63,6 +66,15 @@ func validateValueType(value interface{}, expectedType reflect.Type, fieldName s
 		}
 	}
 
+	// Spatial values arrive as GeoJSON objects or WKT strings; their content is
+	// checked when they are decoded into the property type
+	if edmType, ok := geo.EdmTypeOf(expectedType); ok {
+		if actualType.Kind() == reflect.String || actualType.Kind() == reflect.Map {
+			return nil
+		}
+		return fmt.Errorf("property '%s' expects type %s but got %s", fieldName, edmType, actualType.Kind())
+	}
+
 	// Special handling for time.Time struct type
 	// JSON datetime values come as strings but need to be assigned to time.Time fields
 	if expectedType.Kind() == reflect.Struct && expectedType.PkgPath() == "time" && expectedType.Name() == "Time" {
@@ -158,6 +170,49 @@ func (h *EntityHandler) validateMaxLength(data map[string]interface{}) error {
 	return nil
 }
 
+// validateVocabularyConstraints checks property values against the Validation vocabulary
+// annotations (Pattern, Minimum, Maximum, AllowedValues, MaxItems, MinItems) declared on
+// the entity's properties. All violations are collected into a single OData error with
+// one detail per offending property.
+func (h *EntityHandler) validateVocabularyConstraints(data map[string]interface{}) error {
+	var details []odataerrors.ErrorDetail
+
+	propNames := make([]string, 0, len(data))
+	for propName := range data {
+		propNames = append(propNames, propName)
+	}
+	sort.Strings(propNames)
+
+	for _, propName := range propNames {
+		propMeta := h.metadata.FindProperty(propName)
+		if propMeta == nil || propMeta.Annotations == nil {
+			continue
+		}
+
+		violations := metadata.ValidationViolations(data[propName], propMeta.Annotations)
+		if len(violations) == 0 {
+			continue
+		}
+
+		details = append(details, odataerrors.ErrorDetail{
+			Code:    ErrMsgValidationFailed,
+			Target:  propMeta.JsonName,
+			Message: strings.Join(violations, "; "),
+		})
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+
+	return &odataerrors.ODataError{
+		StatusCode: http.StatusBadRequest,
+		Code:       odataerrors.ErrorCodeBadRequest,
+		Message:    "One or more property values violate declared validation constraints",
+		Details:    details,
+	}
+}
+
 // validateReferentialConstraints checks that scalar foreign-key values supplied in
 // requestData reference an existing row in the target entity set, for every
 // single-valued navigation property with GORM-derived referential constraints.
@@ -196,8 +251,10 @@ func (h *EntityHandler) validateReferentialConstraints(ctx context.Context, tx *
 				continue
 			}
 
+			// Counting through the model applies the policy's row filter, so
+			// rows the caller cannot read cannot be referenced either.
 			var count int64
-			if err := tx.WithContext(ctx).Table(targetMetadata.TableName).
+			if err := tx.WithContext(ctx).Unscoped().Model(reflect.New(targetMetadata.EntityType).Interface()).
 				Where(fmt.Sprintf("%s = ?", principalMeta.ColumnName), value).
 				Limit(1).Count(&count).Error; err != nil {
 				return fmt.Errorf("failed to validate reference '%s': %w", navProp.Name, err)
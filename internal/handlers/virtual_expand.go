@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/rowsecurity"
+)
+
+// VirtualExpand serves the $expand of navigation properties that target
+// virtual entity sets from their GetCollection overwrites. Expanding such a
+// navigation property calls the overwrite once for all parents, with a filter
+// on the keys of the parents.
+type VirtualExpand struct {
+	handlers map[string]*EntityHandler
+}
+
+// NewVirtualExpand creates a VirtualExpand without virtual entity sets.
+func NewVirtualExpand() *VirtualExpand {
+	return &VirtualExpand{handlers: make(map[string]*EntityHandler)}
+}
+
+// Register adds the handler of a virtual entity set. Like the registration of
+// entity sets, it must happen before requests are served.
+func (v *VirtualExpand) Register(handler *EntityHandler) {
+	if handler != nil && handler.metadata != nil && handler.metadata.IsVirtual {
+		v.handlers[handler.metadata.EntitySetName] = handler
+	}
+}
+
+// WithVirtualExpand returns r with v installed as the source of $expand.
+// Requests without $expand, and all requests when v has no virtual entity
+// sets, are returned unchanged.
+func WithVirtualExpand(r *http.Request, v *VirtualExpand) *http.Request {
+	if r == nil || r.URL == nil || v == nil || len(v.handlers) == 0 || !strings.Contains(r.URL.RawQuery, "expand") {
+		return r
+	}
+	source := func(ctx context.Context, target *metadata.EntityMetadata, options *query.QueryOptions) (interface{}, error) {
+		handler, ok := v.handlers[target.EntitySetName]
+		if !ok || !handler.overwrite.hasGetCollection() {
+			return nil, fmt.Errorf("virtual entity set '%s' has no GetCollection overwrite", target.EntitySetName)
+		}
+		// Under row security the policy filter of expanded entity sets is left
+		// to the database, which does not serve virtual entity sets.
+		rowFilter, err := rowsecurity.FromContext(ctx).Filter(target)
+		if err != nil {
+			return nil, err
+		}
+		options.Filter = query.MergeFilterExpressions(options.Filter, rowFilter)
+		result, err := handler.overwrite.getCollection(&OverwriteContext{
+			QueryOptions: options,
+			Request:      r.WithContext(ctx),
+		})
+		if err != nil || result == nil {
+			return nil, err
+		}
+		return result.Items, nil
+	}
+	return r.WithContext(query.WithVirtualExpandSource(r.Context(), source))
+}
+
+// expandVirtualResults loads the $expand of entities returned by the overwrite
+// handlers of a virtual entity set, from the database or from other virtual
+// entity sets, so that the handlers need not resolve navigation properties.
+// Navigation properties the handlers already populated are left as returned,
+// as are the ones that cannot be loaded: many-to-many relationships and virtual
+// entity sets without a GetCollection overwrite.
+func (h *EntityHandler) expandVirtualResults(r *http.Request, results interface{}, expand []query.ExpandOption) (interface{}, error) {
+	if !h.metadata.IsVirtual || len(expand) == 0 || results == nil || h.db == nil {
+		return results, nil
+	}
+	expand = h.loadableVirtualExpand(h.metadata, expand, results)
+	if len(expand) == 0 {
+		return results, nil
+	}
+	if value := reflect.ValueOf(results); value.Kind() == reflect.Struct {
+		// An entity returned by value cannot be modified; expand a copy.
+		copied := reflect.New(value.Type())
+		copied.Elem().Set(value)
+		results = copied.Interface()
+	}
+	if err := query.ApplyPerParentExpand(h.db.WithContext(r.Context()), results, expand, h.metadata); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// loadableVirtualExpand returns the expand options of entityMetadata that
+// expandVirtualResults loads. results, when not nil, are the entities returned
+// by the overwrite handlers; options for navigation properties populated on any
+// of them are dropped.
+func (h *EntityHandler) loadableVirtualExpand(entityMetadata *metadata.EntityMetadata, expand []query.ExpandOption, results interface{}) []query.ExpandOption {
+	loadable := make([]query.ExpandOption, 0, len(expand))
+	for _, expandOpt := range expand {
+		navProp := entityMetadata.FindNavigationProperty(expandOpt.NavigationProperty)
+		if navProp == nil {
+			continue
+		}
+		targetMetadata, err := h.getTargetMetadata(navProp.NavigationTarget)
+		if err != nil {
+			continue
+		}
+		if navProp.IsManyToMany && (entityMetadata.IsVirtual || targetMetadata.IsVirtual) {
+			continue
+		}
+		if targetMetadata.IsVirtual {
+			target := h.entityHandlers[targetMetadata.EntitySetName]
+			if target == nil || !target.overwrite.hasGetCollection() {
+				continue
+			}
+		}
+		if results != nil && navigationPopulated(results, navProp) {
+			continue
+		}
+		if len(expandOpt.Expand) > 0 {
+			expandOpt.Expand = h.loadableVirtualExpand(targetMetadata, expandOpt.Expand, nil)
+		}
+		loadable = append(loadable, expandOpt)
+	}
+	return loadable
+}
+
+// navigationPopulated reports whether navProp is set on any of the entities in
+// results: a non-nil pointer or slice, or a struct value.
+func navigationPopulated(results interface{}, navProp *metadata.PropertyMetadata) bool {
+	fieldName := navProp.FieldName
+	if fieldName == "" {
+		fieldName = navProp.Name
+	}
+	populated := func(entity reflect.Value) bool {
+		for entity.Kind() == reflect.Interface || entity.Kind() == reflect.Ptr {
+			if entity.IsNil() {
+				return false
+			}
+			entity = entity.Elem()
+		}
+		if entity.Kind() != reflect.Struct {
+			return false
+		}
+		field := entity.FieldByName(fieldName)
+		if !field.IsValid() {
+			return false
+		}
+		switch field.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return !field.IsNil()
+		default:
+			return !field.IsZero()
+		}
+	}
+
+	value := reflect.ValueOf(results)
+	for value.Kind() == reflect.Ptr && !value.IsNil() && value.Elem().Kind() != reflect.Struct {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return populated(value)
+	}
+	for i := 0; i < value.Len(); i++ {
+		if populated(value.Index(i)) {
+			return true
+		}
+	}
+	return false
+}
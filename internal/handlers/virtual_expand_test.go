@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+type virtualExpandTestShipment struct {
+	ID      int `json:"ID" odata:"key"`
+	OrderID int `json:"OrderID"`
+}
+
+func TestWithVirtualExpandOnlyForExpandRequests(t *testing.T) {
+	meta, err := metadata.AnalyzeVirtualEntity(&virtualExpandTestShipment{})
+	if err != nil {
+		t.Fatalf("AnalyzeVirtualEntity() error: %v", err)
+	}
+	virtual := NewVirtualExpand()
+
+	req := httptest.NewRequest(http.MethodGet, "/Orders?$expand=Shipments", nil)
+	if got := WithVirtualExpand(req, virtual); got != req {
+		t.Fatal("expected the request unchanged without virtual entity sets")
+	}
+
+	virtual.Register(NewEntityHandler(nil, meta, nil))
+	if got := WithVirtualExpand(req, virtual); got == req {
+		t.Fatal("expected the expand source to be installed for $expand")
+	}
+	for _, target := range []string{"/Orders", "/Orders?$filter=ID%20eq%201"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if got := WithVirtualExpand(req, virtual); got != req {
+			t.Errorf("%s: expected the request unchanged without $expand", target)
+		}
+	}
+}
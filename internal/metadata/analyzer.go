@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	gormschema "gorm.io/gorm/schema"
 )
@@ -33,6 +34,12 @@ type EntityMetadata struct {
 	// ChangeTrackingEnabled indicates whether $deltatoken and change tracking responses are enabled for this entity set
 	ChangeTrackingEnabled bool
 	StreamProperties      []PropertyMetadata // Named stream properties on this entity
+	// TemporalHistoryTable names the table holding the version history of a temporal
+	// entity set. It is empty unless the entity set supports $at, $from and $to.
+	TemporalHistoryTable string
+	// SoftDeleteProperty is the timestamp property marked odata:"softdelete". When set,
+	// DELETE stamps it instead of removing the row and reads exclude stamped rows.
+	SoftDeleteProperty *PropertyMetadata
 	// DisabledMethods contains HTTP methods that are not allowed for this entity
 	DisabledMethods map[string]bool
 	// DefaultMaxTop is the default maximum number of results to return if no explicit $top is set
@@ -107,10 +114,15 @@ type PropertyMetadata struct {
 	IsComplexType             bool   // True if this property is a complex type (embedded struct)
 	EmbeddedPrefix            string
 	ComplexTypeFields         map[string]*PropertyMetadata
+	// IsCollection is true for a collection of primitive or complex values
+	// (e.g. Tags []string) stored as a JSON array in a single column. For
+	// complex elements, ComplexTypeFields describes the element type.
+	IsCollection bool
 	// Facets
 	MaxLength    int    // Maximum length for string properties
 	Precision    int    // Precision for decimal/numeric properties
 	Scale        int    // Scale for decimal properties
+	SRID         int    // Spatial reference system for geography and geometry properties
 	DefaultValue string // Default value for the property
 	Nullable     *bool  // Explicit nullable override (nil means use default behavior)
 	// Referential constraints for navigation properties
@@ -150,6 +162,8 @@ type PropertyMetadata struct {
 	StreamContentField     string // Name of the field containing the binary content for this stream
 	// Auto properties
 	IsAuto bool // True if this property is automatically set server-side (clients cannot provide/modify it)
+	// Soft delete properties
+	IsSoftDelete bool // True if this property holds the deletion timestamp of soft-deleted entities
 	// Computed properties
 	IsComputed bool // True if this property is computed server-side and has no database column
 	// Untyped properties
@@ -472,6 +486,8 @@ func analyzeField(field reflect.StructField, metadata *EntityMetadata) (Property
 		return PropertyMetadata{}, err
 	}
 
+	analyzeCollectionProperty(&property)
+
 	// Auto-detect nullability based on Go type and GORM tags
 	// This runs after OData tags so explicit odata:"nullable" takes precedence
 	if err := autoDetectNullability(&property); err != nil {
@@ -742,6 +758,10 @@ func analyzeODataTags(property *PropertyMetadata, field reflect.StructField, met
 	return nil
 }
 
+// timePtrType is the only type accepted for a soft delete field: it must be
+// nullable so that live entities can leave it unset.
+var timePtrType = reflect.TypeOf((*time.Time)(nil))
+
 // processODataTagPart processes a single OData tag part
 func processODataTagPart(property *PropertyMetadata, part string, metadata *EntityMetadata, hasSimilarity bool) error {
 	switch {
@@ -758,6 +778,8 @@ func processODataTagPart(property *PropertyMetadata, part string, metadata *Enti
 		processIntFacet(part, "precision=", &property.Precision)
 	case strings.HasPrefix(part, "scale="):
 		processIntFacet(part, "scale=", &property.Scale)
+	case strings.HasPrefix(part, "srid="):
+		processIntFacet(part, "srid=", &property.SRID)
 	case strings.HasPrefix(part, "default="):
 		property.DefaultValue = strings.TrimPrefix(part, "default=")
 	case part == "nullable":
@@ -799,6 +821,16 @@ func processODataTagPart(property *PropertyMetadata, part string, metadata *Enti
 		property.KeyGenerator = strings.TrimSpace(strings.TrimPrefix(part, "generate="))
 	case part == "auto":
 		property.IsAuto = true
+	case part == "softdelete":
+		if property.Type != timePtrType {
+			return fmt.Errorf("soft delete field %s must be of type *time.Time", property.Name)
+		}
+		if metadata.SoftDeleteProperty != nil {
+			return fmt.Errorf("entity %s declares more than one soft delete field", metadata.EntityName)
+		}
+		property.IsSoftDelete = true
+		property.IsAuto = true
+		metadata.SoftDeleteProperty = property
 	case part == "computed":
 		property.IsComputed = true
 	case part == "untyped":
@@ -1422,6 +1454,16 @@ func (metadata *EntityMetadata) FindComplexTypeProperty(name string) *PropertyMe
 	return nil
 }
 
+// FindCollectionProperty returns metadata for collections of primitive or complex values.
+// Returns nil if the property does not exist or is not such a collection.
+func (metadata *EntityMetadata) FindCollectionProperty(name string) *PropertyMetadata {
+	prop := metadata.FindProperty(name)
+	if prop != nil && prop.IsCollection {
+		return prop
+	}
+	return nil
+}
+
 // errMetadataIsNil and errPropertyPathEmpty cover the message-insensitive error cases in
 // ResolvePropertyPath. Callers only ever check err != nil, so sharing sentinels avoids an
 // fmt.Errorf allocation on every call for these edge cases.
@@ -50,6 +50,12 @@ var (
 		Namespace: "Org.OData.Validation.V1",
 		Alias:     "Validation",
 	}
+
+	// TemporalVocabulary is the OData Temporal vocabulary (Org.OData.Temporal.V1)
+	TemporalVocabulary = Vocabulary{
+		Namespace: "Org.OData.Temporal.V1",
+		Alias:     "Temporal",
+	}
 )
 
 // Annotation represents an OData annotation
@@ -60,6 +66,9 @@ type Annotation struct {
 	Value interface{}
 	// Qualifier is an optional qualifier for the annotation
 	Qualifier string
+	// Annotations holds annotations applied to this annotation, such as
+	// Validation.Exclusive on a Validation.Minimum or Validation.Maximum bound.
+	Annotations []Annotation
 }
 
 // QualifiedTerm returns the term combined with its qualifier if present (e.g., "Org.OData.Core.V1.Display#Short")
@@ -242,13 +251,45 @@ const (
 	CapSelectSupport = "Org.OData.Capabilities.V1.SelectSupport"
 )
 
+// Common OData Validation vocabulary term constants
+const (
+	// ValidationPattern restricts string values to a regular expression
+	ValidationPattern = "Org.OData.Validation.V1.Pattern"
+	// ValidationMinimum is the minimum value of a numeric property
+	ValidationMinimum = "Org.OData.Validation.V1.Minimum"
+	// ValidationMaximum is the maximum value of a numeric property
+	ValidationMaximum = "Org.OData.Validation.V1.Maximum"
+	// ValidationExclusive marks a Minimum or Maximum bound as exclusive
+	ValidationExclusive = "Org.OData.Validation.V1.Exclusive"
+	// ValidationAllowedValues lists the values a property may take
+	ValidationAllowedValues = "Org.OData.Validation.V1.AllowedValues"
+	// ValidationMaxItems is the maximum number of items in a collection
+	ValidationMaxItems = "Org.OData.Validation.V1.MaxItems"
+	// ValidationMinItems is the minimum number of items in a collection
+	ValidationMinItems = "Org.OData.Validation.V1.MinItems"
+)
+
+// Common OData Temporal vocabulary term constants
+const (
+	// TemporalApplicationTimeSupport declares that an entity set keeps a history
+	// that can be queried with $at, $from and $to
+	TemporalApplicationTimeSupport = "Org.OData.Temporal.V1.ApplicationTimeSupport"
+	// TemporalFrom is the lower boundary of the period an entity version was valid in
+	TemporalFrom = "Org.OData.Temporal.V1.From"
+	// TemporalTo is the upper boundary of the period an entity version was valid in
+	TemporalTo = "Org.OData.Temporal.V1.To"
+)
+
 // ParseAnnotationTag parses an annotation tag value and returns the term and value.
 // Tag format: "term=value" or just "term" for boolean true.
 // Qualifiers can be specified as "term#Qualifier" or by appending ";qualifier=Qualifier".
+// Annotations of the annotation itself are appended as ";@term" or ";@term=value" segments.
 // Examples:
 //   - "Org.OData.Core.V1.Computed" -> term: "Org.OData.Core.V1.Computed", value: true
 //   - "Core.Computed" -> term: "Org.OData.Core.V1.Computed", value: true (with alias expansion)
 //   - "Org.OData.Core.V1.Description=Product name" -> term: "...", value: "Product name"
+//   - "Validation.Minimum=0;@Validation.Exclusive" -> term: "Org.OData.Validation.V1.Minimum", value: 0,
+//     annotated with Org.OData.Validation.V1.Exclusive
 func ParseAnnotationTag(tag string) (Annotation, error) {
 	tag = strings.TrimSpace(tag)
 	if tag == "" {
@@ -258,12 +299,21 @@ func ParseAnnotationTag(tag string) (Annotation, error) {
 	segments := strings.Split(tag, ";")
 	termValue := strings.TrimSpace(segments[0])
 	var qualifier string
+	var nested []Annotation
 
 	for _, segment := range segments[1:] {
 		segment = strings.TrimSpace(segment)
 		if segment == "" {
 			continue
 		}
+		if strings.HasPrefix(segment, "@") {
+			nestedAnnotation, err := ParseAnnotationTag(segment[1:])
+			if err != nil {
+				return Annotation{}, fmt.Errorf("invalid nested annotation %q: %w", segment, err)
+			}
+			nested = append(nested, nestedAnnotation)
+			continue
+		}
 		if strings.HasPrefix(segment, "qualifier=") {
 			qualifierValue := strings.TrimSpace(strings.TrimPrefix(segment, "qualifier="))
 			if qualifierValue == "" {
@@ -315,9 +365,10 @@ func ParseAnnotationTag(tag string) (Annotation, error) {
 	term = expandAnnotationAlias(term)
 
 	return Annotation{
-		Term:      term,
-		Value:     value,
-		Qualifier: qualifier,
+		Term:        term,
+		Value:       value,
+		Qualifier:   qualifier,
+		Annotations: nested,
 	}, nil
 }
 
@@ -340,6 +391,9 @@ func ParseAnnotationTerm(term string) (string, string, error) {
 	if err != nil {
 		return "", "", err
 	}
+	if len(annotation.Annotations) > 0 {
+		return "", "", fmt.Errorf("annotation term should not contain nested annotations; got %q", term)
+	}
 	return annotation.Term, annotation.Qualifier, nil
 }
 
@@ -509,6 +563,10 @@ func expandAnnotationAlias(term string) string {
 	if strings.HasPrefix(term, "Validation.") {
 		return "Org.OData.Validation.V1." + term[11:]
 	}
+	// Handle Temporal.* -> Org.OData.Temporal.V1.*
+	if strings.HasPrefix(term, "Temporal.") {
+		return "Org.OData.Temporal.V1." + term[9:]
+	}
 	return term
 }
 
@@ -523,5 +581,6 @@ func VocabularyAliasMap() map[string]string {
 		"Org.OData.Core.V1":         "Core",
 		"Org.OData.Capabilities.V1": "Capabilities",
 		"Org.OData.Validation.V1":   "Validation",
+		"Org.OData.Temporal.V1":     "Temporal",
 	}
 }
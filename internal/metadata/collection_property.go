@@ -0,0 +1,82 @@
+package metadata
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// analyzeCollectionProperty marks slices of primitive or complex values as
+// collection properties. GORM cannot store such slices natively, so they are
+// persisted as a JSON array, either through the JSON serializer
+// (gorm:"serializer:json") or a type whose GORM data type is json such as
+// odata.Collection.
+//
+// Slices stored any other way, such as native database arrays, are not
+// collection properties. A slice of structs that is not stored as JSON is a
+// relationship GORM resolves by convention.
+func analyzeCollectionProperty(property *PropertyMetadata) {
+	if property.IsNavigationProp || property.IsComplexType || property.IsUntyped || property.IsStream {
+		return
+	}
+
+	sliceType := property.Type
+	for sliceType.Kind() == reflect.Ptr {
+		sliceType = sliceType.Elem()
+	}
+	if sliceType.Kind() != reflect.Slice || sliceType == rawMessageType || sliceType.Elem().Kind() == reflect.Uint8 {
+		return
+	}
+
+	elemType := sliceType.Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+
+	if !isJSONStoredField(property.GormTag, sliceType) {
+		return
+	}
+
+	if elemType.Kind() == reflect.Struct && elemType != timeType {
+		property.IsCollection = true
+		analyzeComplexTypeFields(property, elemType)
+		return
+	}
+
+	if edmType, err := inferUnderlyingEdmType(elemType); err == nil && edmType != "Edm.Binary" {
+		property.IsCollection = true
+	}
+}
+
+// isJSONStoredField reports whether a field is stored as JSON, through GORM's
+// JSON serializer or a type declaring json as its GORM data type. Other
+// sql.Scanner implementations, such as pq.StringArray, may use storage the
+// JSON collection filters cannot query.
+func isJSONStoredField(gormTag string, fieldType reflect.Type) bool {
+	for _, part := range strings.Split(gormTag, ";") {
+		if strings.EqualFold(strings.TrimSpace(part), "serializer:json") {
+			return true
+		}
+	}
+	typed, ok := reflect.New(fieldType).Interface().(interface{ GormDataType() string })
+	return ok && strings.EqualFold(typed.GormDataType(), "json")
+}
+
+// CollectionElementType returns the element type of a collection property,
+// with pointers removed.
+func (p *PropertyMetadata) CollectionElementType() reflect.Type {
+	t := p.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice {
+		return nil
+	}
+	t = t.Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
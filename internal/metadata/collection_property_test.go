@@ -0,0 +1,94 @@
+package metadata
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type collectionPropertyAddress struct {
+	City string `json:"City"`
+}
+
+type collectionPropertyOrder struct {
+	ID      uint `json:"ID" gorm:"primaryKey"`
+	OwnerID uint `json:"OwnerID"`
+}
+
+// collectionPropertyArray is stored as a native database array, like
+// pq.StringArray.
+type collectionPropertyArray []string
+
+func (a *collectionPropertyArray) Scan(src interface{}) error { return nil }
+
+func (a collectionPropertyArray) Value() (driver.Value, error) { return "{}", nil }
+
+// collectionPropertyJSON declares json as its GORM data type, like
+// odata.Collection.
+type collectionPropertyJSON []int
+
+func (c *collectionPropertyJSON) Scan(src interface{}) error { return nil }
+
+func (c collectionPropertyJSON) Value() (driver.Value, error) { return json.Marshal([]int(c)) }
+
+func (collectionPropertyJSON) GormDataType() string { return "json" }
+
+type collectionPropertyOwner struct {
+	ID        uint                        `json:"ID" gorm:"primaryKey" odata:"key"`
+	Tags      []string                    `json:"Tags" gorm:"serializer:json"`
+	Scores    []*float64                  `json:"Scores" gorm:"serializer:json"`
+	Addresses []collectionPropertyAddress `json:"Addresses" gorm:"serializer:json"`
+	Orders    []collectionPropertyOrder   `json:"Orders" gorm:"foreignKey:OwnerID"`
+	Photo     []byte                      `json:"Photo"`
+	Aliases   collectionPropertyArray     `json:"Aliases" gorm:"type:text[]"`
+	Codes     []string                    `json:"Codes" gorm:"serializer:gob"`
+	Levels    collectionPropertyJSON      `json:"Levels"`
+}
+
+func TestAnalyzeCollectionProperty(t *testing.T) {
+	meta, err := AnalyzeEntity(collectionPropertyOwner{})
+	if err != nil {
+		t.Fatalf("AnalyzeEntity() error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		want     bool
+		elemType reflect.Type
+	}{
+		{name: "Tags", want: true, elemType: reflect.TypeOf("")},
+		{name: "Scores", want: true, elemType: reflect.TypeOf(float64(0))},
+		{name: "Addresses", want: true, elemType: reflect.TypeOf(collectionPropertyAddress{})},
+		{name: "Orders", want: false},
+		{name: "Photo", want: false},
+		{name: "Aliases", want: false},
+		{name: "Codes", want: false},
+		{name: "Levels", want: true, elemType: reflect.TypeOf(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop := meta.FindProperty(tt.name)
+			if prop == nil {
+				t.Fatalf("property %s not found", tt.name)
+			}
+			if prop.IsCollection != tt.want {
+				t.Fatalf("expected IsCollection %v, got %v", tt.want, prop.IsCollection)
+			}
+			if tt.want && prop.CollectionElementType() != tt.elemType {
+				t.Errorf("expected element type %v, got %v", tt.elemType, prop.CollectionElementType())
+			}
+			if (meta.FindCollectionProperty(tt.name) != nil) != tt.want {
+				t.Errorf("FindCollectionProperty(%q) mismatch", tt.name)
+			}
+		})
+	}
+
+	addresses := meta.FindCollectionProperty("Addresses")
+	if _, ok := addresses.ComplexTypeFields["City"]; !ok {
+		t.Errorf("expected complex element fields, got %v", addresses.ComplexTypeFields)
+	}
+	if !meta.FindProperty("Orders").IsNavigationProp {
+		t.Error("expected Orders to remain a navigation property")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+
+	"github.com/nlstn/go-odata/internal/geo"
 )
 
 // TypeDefinitionInfo holds metadata for an OData TypeDefinition element.
@@ -91,6 +93,10 @@ func GetTypeDefinition(goType reflect.Type) (*TypeDefinitionInfo, bool) {
 
 // inferUnderlyingEdmType returns the EDM primitive type name for the given Go type.
 func inferUnderlyingEdmType(t reflect.Type) (string, error) {
+	if edmType, ok := geo.EdmTypeOf(t); ok {
+		return edmType, nil
+	}
+
 	// Check for well-known named types first
 	switch t.String() {
 	case "time.Time":
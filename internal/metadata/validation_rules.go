@@ -0,0 +1,212 @@
+package metadata
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validationPatternCache holds compiled Validation.Pattern expressions keyed by their source.
+var validationPatternCache sync.Map
+
+// ValidationViolations checks a value against the Validation vocabulary annotations
+// (Pattern, Minimum, Maximum, AllowedValues, MaxItems and MinItems) in the collection and
+// returns one message per violated constraint. Null values are not checked; nullability
+// is enforced separately.
+func ValidationViolations(value interface{}, annotations *AnnotationCollection) []string {
+	if value == nil || annotations == nil {
+		return nil
+	}
+
+	var violations []string
+	for _, annotation := range annotations.GetByVocabulary(ValidationVocabulary.Namespace) {
+		if message := checkValidationAnnotation(value, annotation); message != "" {
+			violations = append(violations, message)
+		}
+	}
+	return violations
+}
+
+func checkValidationAnnotation(value interface{}, annotation Annotation) string {
+	switch annotation.Term {
+	case ValidationPattern:
+		return checkValidationPattern(value, annotation.Value)
+	case ValidationMinimum:
+		return checkValidationBound(value, annotation, true)
+	case ValidationMaximum:
+		return checkValidationBound(value, annotation, false)
+	case ValidationAllowedValues:
+		return checkValidationAllowedValues(value, annotation.Value)
+	case ValidationMaxItems:
+		if count, ok := validationItemCount(value); ok {
+			if limit, ok := validationNumber(annotation.Value); ok && float64(count) > limit {
+				return fmt.Sprintf("collection has %d items, which exceeds Validation.MaxItems %v", count, annotation.Value)
+			}
+		}
+	case ValidationMinItems:
+		if count, ok := validationItemCount(value); ok {
+			if limit, ok := validationNumber(annotation.Value); ok && float64(count) < limit {
+				return fmt.Sprintf("collection has %d items, which is below Validation.MinItems %v", count, annotation.Value)
+			}
+		}
+	}
+	return ""
+}
+
+func checkValidationPattern(value interface{}, pattern interface{}) string {
+	source, ok := pattern.(string)
+	if !ok || source == "" {
+		return ""
+	}
+	text, ok := value.(string)
+	if !ok {
+		return ""
+	}
+
+	var compiled *regexp.Regexp
+	if cached, ok := validationPatternCache.Load(source); ok {
+		compiled, _ = cached.(*regexp.Regexp)
+	}
+	if compiled == nil {
+		var err error
+		compiled, err = regexp.Compile(source)
+		if err != nil {
+			return fmt.Sprintf("Validation.Pattern %q is not a valid regular expression", source)
+		}
+		validationPatternCache.Store(source, compiled)
+	}
+
+	if !compiled.MatchString(text) {
+		return fmt.Sprintf("value %q does not match Validation.Pattern %q", text, source)
+	}
+	return ""
+}
+
+func checkValidationBound(value interface{}, annotation Annotation, isMinimum bool) string {
+	number, ok := validationNumber(value)
+	if !ok {
+		return ""
+	}
+	bound, ok := validationNumber(annotation.Value)
+	if !ok {
+		return ""
+	}
+
+	exclusive := false
+	for _, nested := range annotation.Annotations {
+		if nested.Term == ValidationExclusive {
+			exclusive = validationBool(nested.Value)
+		}
+	}
+
+	term := "Validation.Maximum"
+	if isMinimum {
+		term = "Validation.Minimum"
+	}
+
+	switch {
+	case isMinimum && exclusive && number <= bound:
+		return fmt.Sprintf("value %v must be greater than %s %v", value, term, annotation.Value)
+	case isMinimum && !exclusive && number < bound:
+		return fmt.Sprintf("value %v must be greater than or equal to %s %v", value, term, annotation.Value)
+	case !isMinimum && exclusive && number >= bound:
+		return fmt.Sprintf("value %v must be less than %s %v", value, term, annotation.Value)
+	case !isMinimum && !exclusive && number > bound:
+		return fmt.Sprintf("value %v must be less than or equal to %s %v", value, term, annotation.Value)
+	}
+	return ""
+}
+
+func checkValidationAllowedValues(value interface{}, allowed interface{}) string {
+	rv := reflect.ValueOf(allowed)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		allowed = []interface{}{allowed}
+		rv = reflect.ValueOf(allowed)
+	}
+
+	candidates := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		candidate := rv.Index(i).Interface()
+		// The vocabulary models allowed values as records with a Value member.
+		if record, ok := candidate.(map[string]interface{}); ok {
+			candidate = record["Value"]
+		}
+		if validationValuesEqual(value, candidate) {
+			return ""
+		}
+		candidates = append(candidates, fmt.Sprintf("%v", candidate))
+	}
+
+	return fmt.Sprintf("value %v is not one of Validation.AllowedValues [%s]", value, strings.Join(candidates, ", "))
+}
+
+func validationValuesEqual(value, candidate interface{}) bool {
+	if left, ok := validationNumber(value); ok {
+		if right, ok := validationNumber(candidate); ok {
+			return left == right
+		}
+	}
+	return fmt.Sprintf("%v", value) == fmt.Sprintf("%v", candidate)
+}
+
+// validationNumber converts numeric values, including numeric strings sent by
+// IEEE754Compatible clients, to float64.
+func validationNumber(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return 0, false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return 0, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.String:
+		parsed, err := strconv.ParseFloat(rv.String(), 64)
+		if err != nil {
+			return 0, false
+		}
+		return parsed, true
+	}
+
+	if stringer, ok := rv.Interface().(fmt.Stringer); ok {
+		parsed, err := strconv.ParseFloat(stringer.String(), 64)
+		if err == nil {
+			return parsed, true
+		}
+	}
+	return 0, false
+}
+
+func validationItemCount(value interface{}) (int, bool) {
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		return rv.Len(), true
+	}
+	return 0, false
+}
+
+func validationBool(value interface{}) bool {
+	switch typed := value.(type) {
+	case bool:
+		return typed
+	case string:
+		return strings.EqualFold(typed, "true")
+	}
+	return false
+}
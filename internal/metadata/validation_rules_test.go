@@ -0,0 +1,137 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidationViolations(t *testing.T) {
+	mustParse := func(tag string) Annotation {
+		t.Helper()
+		annotation, err := ParseAnnotationTag(tag)
+		if err != nil {
+			t.Fatalf("ParseAnnotationTag(%q) error: %v", tag, err)
+		}
+		return annotation
+	}
+
+	tests := []struct {
+		name        string
+		annotations []Annotation
+		value       interface{}
+		wantMessage string
+	}{
+		{
+			name:        "pattern match",
+			annotations: []Annotation{mustParse(`Validation.Pattern='^[A-Z]{3}$'`)},
+			value:       "ABC",
+		},
+		{
+			name:        "pattern mismatch",
+			annotations: []Annotation{mustParse(`Validation.Pattern='^[A-Z]{3}$'`)},
+			value:       "abcd",
+			wantMessage: "does not match Validation.Pattern",
+		},
+		{
+			name:        "minimum inclusive at bound",
+			annotations: []Annotation{mustParse("Validation.Minimum=0")},
+			value:       float64(0),
+		},
+		{
+			name:        "minimum below bound",
+			annotations: []Annotation{mustParse("Validation.Minimum=0")},
+			value:       float64(-1),
+			wantMessage: "greater than or equal to Validation.Minimum 0",
+		},
+		{
+			name:        "minimum exclusive at bound",
+			annotations: []Annotation{mustParse("Validation.Minimum=0;@Validation.Exclusive")},
+			value:       0,
+			wantMessage: "must be greater than Validation.Minimum 0",
+		},
+		{
+			name:        "maximum exclusive below bound",
+			annotations: []Annotation{mustParse("Validation.Maximum=100;@Validation.Exclusive")},
+			value:       99.5,
+		},
+		{
+			name:        "maximum above bound",
+			annotations: []Annotation{mustParse("Validation.Maximum=100")},
+			value:       int64(101),
+			wantMessage: "less than or equal to Validation.Maximum 100",
+		},
+		{
+			name: "allowed value records",
+			annotations: []Annotation{{Term: ValidationAllowedValues, Value: []interface{}{
+				map[string]interface{}{"Value": "open"},
+				map[string]interface{}{"Value": "closed"},
+			}}},
+			value: "closed",
+		},
+		{
+			name:        "value not allowed",
+			annotations: []Annotation{{Term: ValidationAllowedValues, Value: []interface{}{1, 2, 3}}},
+			value:       float64(4),
+			wantMessage: "is not one of Validation.AllowedValues [1, 2, 3]",
+		},
+		{
+			name:        "too many items",
+			annotations: []Annotation{mustParse("Validation.MaxItems=2")},
+			value:       []interface{}{"a", "b", "c"},
+			wantMessage: "exceeds Validation.MaxItems 2",
+		},
+		{
+			name:        "too few items",
+			annotations: []Annotation{mustParse("Validation.MinItems=1")},
+			value:       []string{},
+			wantMessage: "below Validation.MinItems 1",
+		},
+		{
+			name:        "null values are not checked",
+			annotations: []Annotation{mustParse("Validation.Minimum=10")},
+			value:       nil,
+		},
+		{
+			name:        "other vocabularies are ignored",
+			annotations: []Annotation{mustParse("Core.Description=test")},
+			value:       "anything",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collection := NewAnnotationCollection()
+			for _, annotation := range tt.annotations {
+				collection.Add(annotation)
+			}
+
+			violations := ValidationViolations(tt.value, collection)
+			if tt.wantMessage == "" {
+				if len(violations) != 0 {
+					t.Fatalf("ValidationViolations() = %v, want none", violations)
+				}
+				return
+			}
+			if len(violations) != 1 || !strings.Contains(violations[0], tt.wantMessage) {
+				t.Fatalf("ValidationViolations() = %v, want message containing %q", violations, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestParseAnnotationTagNestedAnnotation(t *testing.T) {
+	annotation, err := ParseAnnotationTag("Validation.Minimum=5;@Validation.Exclusive")
+	if err != nil {
+		t.Fatalf("ParseAnnotationTag() error: %v", err)
+	}
+	if annotation.Term != ValidationMinimum {
+		t.Errorf("Term = %q, want %q", annotation.Term, ValidationMinimum)
+	}
+	if len(annotation.Annotations) != 1 || annotation.Annotations[0].Term != ValidationExclusive || annotation.Annotations[0].Value != true {
+		t.Errorf("nested annotations = %+v, want Validation.Exclusive=true", annotation.Annotations)
+	}
+
+	if _, _, err := ParseAnnotationTerm("Validation.Minimum;@Validation.Exclusive"); err == nil {
+		t.Error("ParseAnnotationTerm() should reject nested annotations")
+	}
+}
@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoSubscribers is returned by ChannelPublisher.Publish when no subscriber
+// received the events. The outbox keeps such events and delivers them once a
+// subscriber is registered.
+var ErrNoSubscribers = errors.New("outbox: no subscribers")
+
+// ChannelPublisher is an in-process Publisher that fans events out to
+// subscriber channels. Publish blocks until every current subscriber has
+// received the batch, so slow subscribers apply backpressure to the delivery
+// loop instead of losing events. Without subscribers Publish fails with
+// ErrNoSubscribers, so the events stay undelivered rather than being dropped.
+type ChannelPublisher struct {
+	mu          sync.RWMutex
+	subscribers map[*subscription]struct{}
+}
+
+type subscription struct {
+	ch     chan Event
+	done   chan struct{}
+	closed sync.Once
+}
+
+// NewChannelPublisher creates a publisher with no subscribers.
+func NewChannelPublisher() *ChannelPublisher {
+	return &ChannelPublisher{subscribers: make(map[*subscription]struct{})}
+}
+
+// Subscribe registers a subscriber and returns its event channel together with
+// a function that cancels the subscription and closes the channel. buffer sets
+// the channel capacity.
+func (p *ChannelPublisher) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	sub := &subscription{ch: make(chan Event, buffer), done: make(chan struct{})}
+
+	p.mu.Lock()
+	p.subscribers[sub] = struct{}{}
+	p.mu.Unlock()
+
+	cancel := func() {
+		sub.closed.Do(func() {
+			close(sub.done)
+			p.mu.Lock()
+			delete(p.subscribers, sub)
+			close(sub.ch)
+			p.mu.Unlock()
+		})
+	}
+	return sub.ch, cancel
+}
+
+// Publish delivers events to every subscriber in order. It returns ctx.Err()
+// if ctx is done before all subscribers received the batch; subscribers that
+// cancel while Publish is blocked on them are skipped. It returns
+// ErrNoSubscribers when no subscriber received the whole batch.
+func (p *ChannelPublisher) Publish(ctx context.Context, events []Event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	received := 0
+	for sub := range p.subscribers {
+		if p.deliver(ctx, sub, events) {
+			received++
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if received == 0 {
+		return ErrNoSubscribers
+	}
+	return nil
+}
+
+// deliver sends events to sub and reports whether it received all of them.
+func (p *ChannelPublisher) deliver(ctx context.Context, sub *subscription, events []Event) bool {
+	for _, event := range events {
+		select {
+		case sub.ch <- event:
+		case <-sub.done:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
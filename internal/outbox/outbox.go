@@ -0,0 +1,533 @@
+// Package outbox implements a transactional outbox for entity change events.
+//
+// Change events are written to an outbox table in the same database transaction
+// as the entity write they describe, so an event exists if and only if the write
+// committed. A delivery loop reads undelivered events in insertion order, hands
+// them to a Publisher and marks them delivered once the publisher accepts them.
+// Delivery is at-least-once: a crash between publishing and marking the batch
+// causes the batch to be published again. Records that cannot be decoded are
+// dead-lettered instead of blocking the events behind them, and delivered
+// records are purged once they are older than the retention period.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/trackchanges"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultPollInterval is how often the delivery loop checks for new events when not notified.
+	DefaultPollInterval = time.Second
+	// DefaultBatchSize is the maximum number of events handed to the publisher at once.
+	DefaultBatchSize = 100
+	// DefaultMinRetryBackoff is the delay before the first retry of a failed delivery.
+	DefaultMinRetryBackoff = 100 * time.Millisecond
+	// DefaultMaxRetryBackoff caps the exponential backoff between delivery retries.
+	DefaultMaxRetryBackoff = 30 * time.Second
+	// DefaultConsumer is the checkpoint name used when Config.Consumer is empty.
+	DefaultConsumer = "default"
+	// DefaultRetention is how long delivered events are kept before they are purged.
+	DefaultRetention = 7 * 24 * time.Hour
+	// maxPurgeInterval caps the time between two retention sweeps.
+	maxPurgeInterval = time.Hour
+)
+
+// Event is a committed change to an entity instance.
+type Event struct {
+	// ID is the outbox sequence number. IDs increase in commit order for a single writer.
+	ID int64
+	// EntitySet is the name of the entity set the entity belongs to.
+	EntitySet string
+	// Type is the kind of change: added, updated or deleted.
+	Type trackchanges.ChangeType
+	// KeyValues maps key property names to the entity's key values.
+	KeyValues map[string]interface{}
+	// Data holds the entity's properties after the change. It is nil for deleted entities.
+	Data map[string]interface{}
+	// OccurredAt is when the change was written.
+	OccurredAt time.Time
+}
+
+// Publisher delivers change events to downstream consumers. Publish must
+// either accept every event in the batch or return an error, in which case the
+// whole batch is retried later.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+// PublisherFunc adapts a function to the Publisher interface.
+type PublisherFunc func(ctx context.Context, events []Event) error
+
+// Publish calls f(ctx, events).
+func (f PublisherFunc) Publish(ctx context.Context, events []Event) error {
+	return f(ctx, events)
+}
+
+// Config controls the delivery loop.
+type Config struct {
+	// PollInterval is how often undelivered events are checked for when no write
+	// notified the loop. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// BatchSize limits the number of events per Publish call. Defaults to DefaultBatchSize.
+	BatchSize int
+	// MinRetryBackoff and MaxRetryBackoff bound the exponential backoff applied
+	// after a failed Publish. They default to DefaultMinRetryBackoff and DefaultMaxRetryBackoff.
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+	// Consumer names the checkpoint record. Defaults to DefaultConsumer.
+	Consumer string
+	// Retention is how long delivered events are kept before the delivery loop
+	// deletes them. Defaults to DefaultRetention; a negative value keeps them forever.
+	Retention time.Duration
+	// Logger receives delivery errors. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Outbox records change events transactionally and delivers them to a Publisher.
+type Outbox struct {
+	db        *gorm.DB
+	publisher Publisher
+	cfg       Config
+	logger    *slog.Logger
+
+	// deliverMu serializes delivery passes so events are published in order.
+	deliverMu sync.Mutex
+
+	mu      sync.Mutex
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+}
+
+// New creates an outbox backed by db and migrates its tables. The delivery loop
+// is not started until Start is called.
+func New(db *gorm.DB, publisher Publisher, cfg Config) (*Outbox, error) {
+	if db == nil {
+		return nil, errors.New("outbox: database handle is required")
+	}
+	if publisher == nil {
+		return nil, errors.New("outbox: publisher is required")
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.MinRetryBackoff <= 0 {
+		cfg.MinRetryBackoff = DefaultMinRetryBackoff
+	}
+	if cfg.MaxRetryBackoff < cfg.MinRetryBackoff {
+		cfg.MaxRetryBackoff = DefaultMaxRetryBackoff
+		if cfg.MaxRetryBackoff < cfg.MinRetryBackoff {
+			cfg.MaxRetryBackoff = cfg.MinRetryBackoff
+		}
+	}
+	if cfg.Consumer == "" {
+		cfg.Consumer = DefaultConsumer
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = DefaultRetention
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if err := db.AutoMigrate(&eventRecord{}, &checkpointRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate outbox tables: %w", err)
+	}
+
+	return &Outbox{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+		logger:    logger,
+		notify:    make(chan struct{}, 1),
+	}, nil
+}
+
+// Append writes events to the outbox using tx. Callers pass the transaction
+// that performs the entity write so the events commit or roll back with it.
+func (o *Outbox) Append(tx *gorm.DB, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	records := make([]eventRecord, 0, len(events))
+	for _, event := range events {
+		record, err := newEventRecord(event)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return fmt.Errorf("failed to write outbox events: %w", err)
+	}
+	return nil
+}
+
+// Notify wakes the delivery loop after a write committed. It never blocks.
+func (o *Outbox) Notify() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start launches the background delivery loop. Calling Start on a running
+// outbox has no effect.
+func (o *Outbox) Start() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.running {
+		return
+	}
+	o.running = true
+	o.stop = make(chan struct{})
+	o.done = make(chan struct{})
+	go o.run(o.stop, o.done)
+}
+
+// Close stops the delivery loop and waits for an in-flight delivery to finish.
+// Undelivered events remain in the outbox and are delivered after the next Start.
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	if !o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = false
+	close(o.stop)
+	done := o.done
+	o.mu.Unlock()
+	<-done
+}
+
+// Flush delivers every undelivered event, retrying failed batches with backoff
+// until the outbox is empty or ctx is done. It returns ErrNoSubscribers at once
+// when the publisher has nobody to deliver to.
+func (o *Outbox) Flush(ctx context.Context) error {
+	backoff := o.cfg.MinRetryBackoff
+	for {
+		delivered, err := o.deliverBatch(ctx)
+		if err == nil {
+			if delivered == 0 {
+				return nil
+			}
+			backoff = o.cfg.MinRetryBackoff
+			continue
+		}
+		if errors.Is(err, ErrNoSubscribers) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = o.nextBackoff(backoff)
+	}
+}
+
+// Checkpoint returns the ID of the most recently delivered event for the
+// configured consumer, or zero if nothing has been delivered yet.
+func (o *Outbox) Checkpoint(ctx context.Context) (int64, error) {
+	var checkpoint checkpointRecord
+	err := o.db.WithContext(ctx).Where("consumer = ?", o.cfg.Consumer).Take(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load outbox checkpoint: %w", err)
+	}
+	return checkpoint.LastEventID, nil
+}
+
+// Pending returns the number of events that have not been delivered yet.
+// Dead-lettered events are not pending.
+func (o *Outbox) Pending(ctx context.Context) (int64, error) {
+	var count int64
+	if err := o.db.WithContext(ctx).Model(&eventRecord{}).
+		Where("delivered_at IS NULL AND failed_at IS NULL").
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count outbox events: %w", err)
+	}
+	return count, nil
+}
+
+// DeadLettered returns the number of events that could not be decoded and were
+// skipped by the delivery loop. They stay in the outbox with failed_at and
+// error set until an operator removes them.
+func (o *Outbox) DeadLettered(ctx context.Context) (int64, error) {
+	var count int64
+	if err := o.db.WithContext(ctx).Model(&eventRecord{}).Where("failed_at IS NOT NULL").Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count dead-lettered outbox events: %w", err)
+	}
+	return count, nil
+}
+
+// Purge deletes delivered events older than the configured retention and
+// returns the number of deleted events. It does nothing when retention is
+// disabled. Undelivered and dead-lettered events are never purged.
+func (o *Outbox) Purge(ctx context.Context) (int64, error) {
+	if o.cfg.Retention < 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().UTC().Add(-o.cfg.Retention)
+	result := o.db.WithContext(ctx).Where("delivered_at IS NOT NULL AND delivered_at < ?", cutoff).Delete(&eventRecord{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge delivered outbox events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// purgeInterval is how often the delivery loop runs Purge.
+func (o *Outbox) purgeInterval() time.Duration {
+	if o.cfg.Retention > 0 && o.cfg.Retention < maxPurgeInterval {
+		return o.cfg.Retention
+	}
+	return maxPurgeInterval
+}
+
+func (o *Outbox) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(o.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var purge <-chan time.Time
+	if o.cfg.Retention >= 0 {
+		purgeTicker := time.NewTicker(o.purgeInterval())
+		defer purgeTicker.Stop()
+		purge = purgeTicker.C
+	}
+
+	backoff := o.cfg.MinRetryBackoff
+	for {
+		delivered, err := o.deliverBatch(ctx)
+		wait := time.Duration(0)
+		switch {
+		case errors.Is(err, ErrNoSubscribers):
+			// Keep the events until a subscriber arrives; Notify or the next
+			// poll retries the delivery.
+			o.logger.Debug("outbox delivery waiting for subscribers", "consumer", o.cfg.Consumer)
+			backoff = o.cfg.MinRetryBackoff
+		case err != nil:
+			if ctx.Err() == nil {
+				o.logger.Error("outbox delivery failed", "consumer", o.cfg.Consumer, "retryIn", backoff, "error", err)
+			}
+			wait = backoff
+			backoff = o.nextBackoff(backoff)
+		case delivered > 0:
+			backoff = o.cfg.MinRetryBackoff
+			// More events may be waiting; continue immediately.
+			select {
+			case <-stop:
+				return
+			default:
+				continue
+			}
+		default:
+			backoff = o.cfg.MinRetryBackoff
+		}
+
+		if wait > 0 {
+			select {
+			case <-stop:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-o.notify:
+		case <-ticker.C:
+		case <-purge:
+			if purged, err := o.Purge(ctx); err != nil {
+				if ctx.Err() == nil {
+					o.logger.Error("outbox purge failed", "consumer", o.cfg.Consumer, "error", err)
+				}
+			} else if purged > 0 {
+				o.logger.Debug("outbox purged delivered events", "consumer", o.cfg.Consumer, "count", purged)
+			}
+		}
+	}
+}
+
+// deliverBatch publishes the oldest undelivered events and marks them
+// delivered. Records that cannot be decoded are dead-lettered and skipped. It
+// returns the number of records delivered or dead-lettered.
+func (o *Outbox) deliverBatch(ctx context.Context) (int, error) {
+	o.deliverMu.Lock()
+	defer o.deliverMu.Unlock()
+
+	var records []eventRecord
+	if err := o.db.WithContext(ctx).
+		Where("delivered_at IS NULL AND failed_at IS NULL").
+		Order("id asc").
+		Limit(o.cfg.BatchSize).
+		Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("failed to load outbox events: %w", err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	events := make([]Event, 0, len(records))
+	ids := make([]int64, 0, len(records))
+	deadLettered := 0
+	for _, record := range records {
+		event, err := record.toEvent()
+		if err != nil {
+			if err := o.deadLetter(ctx, record, err); err != nil {
+				return 0, err
+			}
+			deadLettered++
+			continue
+		}
+		events = append(events, event)
+		ids = append(ids, record.ID)
+	}
+	if len(events) == 0 {
+		return deadLettered, nil
+	}
+
+	if err := o.publisher.Publish(ctx, events); err != nil {
+		return 0, fmt.Errorf("publisher rejected %d events: %w", len(events), err)
+	}
+
+	lastID := ids[len(ids)-1]
+	now := time.Now().UTC()
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&eventRecord{}).Where("id IN ?", ids).Update("delivered_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "consumer"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_event_id", "updated_at"}),
+		}).Create(&checkpointRecord{Consumer: o.cfg.Consumer, LastEventID: lastID, UpdatedAt: now}).Error
+	})
+	if err != nil {
+		// The events were published; they will be published again on the next pass.
+		return 0, fmt.Errorf("failed to record outbox checkpoint: %w", err)
+	}
+	return len(events) + deadLettered, nil
+}
+
+// deadLetter marks a record that cannot be decoded as failed so delivery
+// continues with the events behind it.
+func (o *Outbox) deadLetter(ctx context.Context, record eventRecord, cause error) error {
+	o.logger.Error("outbox event dead-lettered",
+		"consumer", o.cfg.Consumer, "id", record.ID, "entitySet", record.EntitySet, "error", cause)
+	err := o.db.WithContext(ctx).Model(&eventRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"failed_at": time.Now().UTC(),
+		"error":     cause.Error(),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter outbox event %d: %w", record.ID, err)
+	}
+	return nil
+}
+
+func (o *Outbox) nextBackoff(current time.Duration) time.Duration {
+	next := current * 2
+	if next > o.cfg.MaxRetryBackoff {
+		next = o.cfg.MaxRetryBackoff
+	}
+	return next
+}
+
+type eventRecord struct {
+	ID          int64                   `gorm:"primaryKey;autoIncrement"`
+	EntitySet   string                  `gorm:"size:255;not null"`
+	ChangeType  trackchanges.ChangeType `gorm:"size:16;not null"`
+	KeyValues   []byte                  `gorm:"not null"`
+	Data        []byte
+	CreatedAt   time.Time  `gorm:"not null"`
+	DeliveredAt *time.Time `gorm:"index"`
+	// FailedAt and Error are set when the record cannot be decoded; such
+	// records are skipped by delivery and kept for inspection.
+	FailedAt *time.Time `gorm:"index"`
+	Error    string
+}
+
+func (eventRecord) TableName() string {
+	return "_odata_outbox"
+}
+
+type checkpointRecord struct {
+	Consumer    string `gorm:"primaryKey;size:255"`
+	LastEventID int64  `gorm:"not null"`
+	UpdatedAt   time.Time
+}
+
+func (checkpointRecord) TableName() string {
+	return "_odata_outbox_checkpoints"
+}
+
+func newEventRecord(event Event) (eventRecord, error) {
+	keyJSON, err := json.Marshal(event.KeyValues)
+	if err != nil {
+		return eventRecord{}, fmt.Errorf("failed to encode outbox event keys: %w", err)
+	}
+
+	var dataJSON []byte
+	if event.Data != nil {
+		dataJSON, err = json.Marshal(event.Data)
+		if err != nil {
+			return eventRecord{}, fmt.Errorf("failed to encode outbox event data: %w", err)
+		}
+	}
+
+	occurredAt := event.OccurredAt
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+
+	return eventRecord{
+		EntitySet:  event.EntitySet,
+		ChangeType: event.Type,
+		KeyValues:  keyJSON,
+		Data:       dataJSON,
+		CreatedAt:  occurredAt.UTC(),
+	}, nil
+}
+
+func (r eventRecord) toEvent() (Event, error) {
+	event := Event{
+		ID:         r.ID,
+		EntitySet:  r.EntitySet,
+		Type:       r.ChangeType,
+		OccurredAt: r.CreatedAt,
+	}
+	if err := json.Unmarshal(r.KeyValues, &event.KeyValues); err != nil {
+		return Event{}, err
+	}
+	if len(r.Data) > 0 {
+		if err := json.Unmarshal(r.Data, &event.Data); err != nil {
+			return Event{}, err
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,331 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/trackchanges"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type recordingPublisher struct {
+	mu       sync.Mutex
+	events   []Event
+	failures int
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func (p *recordingPublisher) snapshot() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Event(nil), p.events...)
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql.DB: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
+
+func productEvent(id int, changeType trackchanges.ChangeType) Event {
+	event := Event{
+		EntitySet: "Products",
+		Type:      changeType,
+		KeyValues: map[string]interface{}{"ID": id},
+	}
+	if changeType != trackchanges.ChangeTypeDeleted {
+		event.Data = map[string]interface{}{"ID": id, "Name": "Laptop"}
+	}
+	return event
+}
+
+func TestAppendCommitsWithTransaction(t *testing.T) {
+	db := openTestDB(t)
+	publisher := &recordingPublisher{}
+	ob, err := New(db, publisher, Config{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	rollback := errors.New("rollback")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := ob.Append(tx, []Event{productEvent(1, trackchanges.ChangeTypeAdded)}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("transaction error = %v", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return ob.Append(tx, []Event{
+			productEvent(2, trackchanges.ChangeTypeAdded),
+			productEvent(2, trackchanges.ChangeTypeUpdated),
+			productEvent(2, trackchanges.ChangeTypeDeleted),
+		})
+	})
+	if err != nil {
+		t.Fatalf("transaction error = %v", err)
+	}
+
+	if pending, err := ob.Pending(context.Background()); err != nil || pending != 3 {
+		t.Fatalf("Pending() = %d, %v; want 3", pending, err)
+	}
+
+	if err := ob.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	events := publisher.snapshot()
+	if len(events) != 3 {
+		t.Fatalf("published %d events, want 3", len(events))
+	}
+	wantTypes := []trackchanges.ChangeType{trackchanges.ChangeTypeAdded, trackchanges.ChangeTypeUpdated, trackchanges.ChangeTypeDeleted}
+	for i, event := range events {
+		if event.Type != wantTypes[i] || event.EntitySet != "Products" || event.KeyValues["ID"] != float64(2) {
+			t.Fatalf("event %d = %+v", i, event)
+		}
+		if i > 0 && event.ID <= events[i-1].ID {
+			t.Fatalf("events out of order: %d after %d", event.ID, events[i-1].ID)
+		}
+	}
+	if events[2].Data != nil {
+		t.Fatalf("deleted event carries data: %v", events[2].Data)
+	}
+
+	checkpoint, err := ob.Checkpoint(context.Background())
+	if err != nil || checkpoint != events[2].ID {
+		t.Fatalf("Checkpoint() = %d, %v; want %d", checkpoint, err, events[2].ID)
+	}
+	if pending, _ := ob.Pending(context.Background()); pending != 0 {
+		t.Fatalf("Pending() after flush = %d", pending)
+	}
+}
+
+func TestFlushRetriesFailedBatches(t *testing.T) {
+	db := openTestDB(t)
+	publisher := &recordingPublisher{failures: 2}
+	ob, err := New(db, publisher, Config{MinRetryBackoff: time.Millisecond, MaxRetryBackoff: 2 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(1, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ob.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if got := len(publisher.snapshot()); got != 1 {
+		t.Fatalf("published %d events, want 1", got)
+	}
+}
+
+func TestFlushDeadLettersUndecodableEvents(t *testing.T) {
+	db := openTestDB(t)
+	publisher := &recordingPublisher{}
+	ob, err := New(db, publisher, Config{BatchSize: 2, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(1, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+	corrupt := eventRecord{EntitySet: "Products", ChangeType: trackchanges.ChangeTypeAdded, KeyValues: []byte("{"), CreatedAt: time.Now()}
+	if err := db.Create(&corrupt).Error; err != nil {
+		t.Fatalf("create corrupt record: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(2, trackchanges.ChangeTypeAdded), productEvent(3, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ob.Flush(ctx); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+
+	events := publisher.snapshot()
+	if len(events) != 3 {
+		t.Fatalf("published %d events, want 3", len(events))
+	}
+	for i, want := range []float64{1, 2, 3} {
+		if events[i].KeyValues["ID"] != want {
+			t.Fatalf("event %d = %+v, want ID %v", i, events[i], want)
+		}
+	}
+	if dead, err := ob.DeadLettered(context.Background()); err != nil || dead != 1 {
+		t.Fatalf("DeadLettered() = %d, %v; want 1", dead, err)
+	}
+	var stored eventRecord
+	if err := db.First(&stored, corrupt.ID).Error; err != nil {
+		t.Fatalf("load dead-lettered record: %v", err)
+	}
+	if stored.FailedAt == nil || stored.DeliveredAt != nil || stored.Error == "" {
+		t.Fatalf("dead-lettered record = %+v", stored)
+	}
+	if pending, _ := ob.Pending(context.Background()); pending != 0 {
+		t.Fatalf("Pending() after flush = %d", pending)
+	}
+}
+
+func TestPurgeDeletesExpiredDeliveredEvents(t *testing.T) {
+	db := openTestDB(t)
+	publisher := &recordingPublisher{}
+	ob, err := New(db, publisher, Config{Retention: time.Hour})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(1, trackchanges.ChangeTypeAdded), productEvent(2, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+	if err := ob.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(3, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+
+	events := publisher.snapshot()
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	if err := db.Model(&eventRecord{}).Where("id = ?", events[0].ID).Update("delivered_at", old).Error; err != nil {
+		t.Fatalf("age delivered record: %v", err)
+	}
+
+	purged, err := ob.Purge(context.Background())
+	if err != nil || purged != 1 {
+		t.Fatalf("Purge() = %d, %v; want 1", purged, err)
+	}
+	var remaining int64
+	db.Model(&eventRecord{}).Count(&remaining)
+	if remaining != 2 {
+		t.Fatalf("remaining records = %d, want the recent delivered and the pending event", remaining)
+	}
+
+	keep, err := New(db, publisher, Config{Retention: -1})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := db.Model(&eventRecord{}).Where("delivered_at IS NOT NULL").Update("delivered_at", old).Error; err != nil {
+		t.Fatalf("age delivered records: %v", err)
+	}
+	if purged, err := keep.Purge(context.Background()); err != nil || purged != 0 {
+		t.Fatalf("Purge() with retention disabled = %d, %v; want 0", purged, err)
+	}
+}
+
+func TestDeliveryLoop(t *testing.T) {
+	db := openTestDB(t)
+	publisher := NewChannelPublisher()
+	events, cancel := publisher.Subscribe(4)
+	defer cancel()
+
+	ob, err := New(db, publisher, Config{PollInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	ob.Start()
+	defer ob.Close()
+
+	if err := ob.Append(db, []Event{productEvent(7, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+	ob.Notify()
+
+	select {
+	case event := <-events:
+		if event.KeyValues["ID"] != float64(7) || event.Data["Name"] != "Laptop" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestChannelPublisherFanOutAndCancel(t *testing.T) {
+	publisher := NewChannelPublisher()
+	first, cancelFirst := publisher.Subscribe(2)
+	second, cancelSecond := publisher.Subscribe(2)
+	defer cancelSecond()
+
+	batch := []Event{{ID: 1}, {ID: 2}}
+	if err := publisher.Publish(context.Background(), batch); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	for _, ch := range []<-chan Event{first, second} {
+		if a, b := <-ch, <-ch; a.ID != 1 || b.ID != 2 {
+			t.Fatalf("unexpected order: %d, %d", a.ID, b.ID)
+		}
+	}
+
+	cancelFirst()
+	if _, ok := <-first; ok {
+		t.Fatal("expected canceled subscription channel to be closed")
+	}
+
+	// The remaining subscriber's buffer is full after this batch; a further
+	// publish must honour context cancellation instead of blocking forever.
+	if err := publisher.Publish(context.Background(), batch); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := publisher.Publish(ctx, batch); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Publish() on full subscriber = %v, want deadline exceeded", err)
+	}
+}
+
+func TestChannelPublisherKeepsEventsWithoutSubscribers(t *testing.T) {
+	db := openTestDB(t)
+	publisher := NewChannelPublisher()
+	ob, err := New(db, publisher, Config{PollInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if err := ob.Append(db, []Event{productEvent(7, trackchanges.ChangeTypeAdded)}); err != nil {
+		t.Fatalf("Append() error: %v", err)
+	}
+
+	if err := ob.Flush(context.Background()); !errors.Is(err, ErrNoSubscribers) {
+		t.Fatalf("Flush() without subscribers = %v, want ErrNoSubscribers", err)
+	}
+	if pending, err := ob.Pending(context.Background()); err != nil || pending != 1 {
+		t.Fatalf("Pending() = %d, %v; want the event kept", pending, err)
+	}
+
+	events, cancel := publisher.Subscribe(1)
+	defer cancel()
+	if err := ob.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if event := <-events; event.KeyValues["ID"] != float64(7) {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+}
@@ -17,12 +17,14 @@ type Preference struct {
 	IncludeAnnotations    *string // odata.include-annotations preference value (OData v4.0, §8.2.8.4)
 	OmitValues            *string // omit-values / odata.omit-values preference value (OData v4.01, §8.2.8.6)
 	OmitValuesPrefixed    bool
+	StreamingRequested    bool // odata.streaming / streaming preference: stream the collection from a database cursor
 
 	trackChangesApplied       bool
 	respondAsyncApplied       bool
 	allowEntityRefsApplied    bool
 	includeAnnotationsApplied bool
 	omitValuesApplied         bool
+	streamingApplied          bool
 }
 
 // ParsePrefer parses the Prefer header from an HTTP request
@@ -33,6 +35,7 @@ type Preference struct {
 // - odata.allow-entityreferences: allows service to return @odata.id references (OData v4.0, §8.2.8.1)
 // - odata.include-annotations: controls which instance annotations are included (OData v4.0, §8.2.8.4)
 // - odata.omit-values / omit-values: controls omitted default/null values (OData v4.01, §8.2.8.6)
+// - odata.streaming / streaming: requests a collection to be streamed instead of materialized
 func ParsePrefer(r *http.Request) *Preference {
 	pref := &Preference{}
 
@@ -57,6 +60,8 @@ func ParsePrefer(r *http.Request) *Preference {
 			pref.RespondAsyncRequested = true
 		case "odata.allow-entityreferences", "allow-entityreferences":
 			pref.AllowEntityReferences = true
+		case "odata.streaming", "streaming":
+			pref.StreamingRequested = true
 		default:
 			// Check for odata.maxpagesize preference
 			if value, ok := preferenceValue(p, pLower, "odata.maxpagesize"); ok {
@@ -166,6 +171,9 @@ func (p *Preference) GetPreferenceApplied() string {
 	if p.omitValuesApplied && p.OmitValues != nil {
 		applied = append(applied, "omit-values="+*p.OmitValues)
 	}
+	if p.streamingApplied {
+		applied = append(applied, "odata.streaming")
+	}
 	return strings.Join(applied, ", ")
 }
 
@@ -209,6 +217,13 @@ func (p *Preference) ApplyOmitValues(allowUnprefixed bool) {
 	}
 }
 
+// ApplyStreaming marks the odata.streaming preference as applied if it was requested.
+func (p *Preference) ApplyStreaming() {
+	if p.StreamingRequested {
+		p.streamingApplied = true
+	}
+}
+
 // OmitsNulls reports whether the omit-values=nulls preference was applied, meaning
 // properties with a null value should be removed from the response body
 // (OData v4.01 §11.2.8.6).
@@ -3,6 +3,7 @@ package preference
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -660,3 +661,24 @@ func TestMatchesAnnotationFilter_CaseInsensitiveTermMatch(t *testing.T) {
 		t.Error("term matching should be case-insensitive")
 	}
 }
+
+func TestParsePrefer_Streaming(t *testing.T) {
+	for _, header := range []string{"odata.streaming", "streaming", "odata.maxpagesize=10, ODATA.STREAMING"} {
+		t.Run(header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Prefer", header)
+			pref := ParsePrefer(req)
+
+			if !pref.StreamingRequested {
+				t.Fatal("StreamingRequested should be set")
+			}
+			if strings.Contains(pref.GetPreferenceApplied(), "odata.streaming") {
+				t.Fatal("odata.streaming should not be applied before ApplyStreaming")
+			}
+			pref.ApplyStreaming()
+			if !strings.Contains(pref.GetPreferenceApplied(), "odata.streaming") {
+				t.Fatalf("expected odata.streaming to be applied, got %q", pref.GetPreferenceApplied())
+			}
+		})
+	}
+}
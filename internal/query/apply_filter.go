@@ -284,6 +284,15 @@ func addNavigationJoin(db *gorm.DB, parentMetadata *metadata.EntityMetadata, par
 		quotedJoinAlias,
 		quotedPrimaryKey)
 
+	// Related rows outside the request's row filter do not join, so they read
+	// as an absent navigation target.
+	if targetMetadata, err := parentMetadata.ResolveNavigationTarget(navProp.Name); err == nil && targetMetadata != nil {
+		if rowFilter := resolveRowFilter(db, targetMetadata); rowFilter != nil {
+			rowSQL, rowArgs := buildRowFilterCondition(dialect, rowFilter, targetMetadata, joinAlias)
+			return db.Joins(fmt.Sprintf("%s AND (%s)", joinClause, rowSQL), rowArgs...)
+		}
+	}
+
 	return db.Joins(joinClause)
 }
 
@@ -405,6 +414,12 @@ func resolveColumnName(db *gorm.DB, dialect string, propertyName string, entityM
 }
 
 func buildCollectionCountExpression(dialect string, propertyName string, entityMetadata *metadata.EntityMetadata) (string, bool) {
+	if name, ok := strings.CutSuffix(propertyName, "/$count"); ok {
+		if collProp := entityMetadata.FindCollectionProperty(name); collProp != nil {
+			return buildCollectionCountSQL(dialect, entityMetadata, collProp)
+		}
+	}
+
 	ownerMetadata, navProp, err := resolveCollectionCountPath(propertyName, entityMetadata)
 	if err != nil || ownerMetadata == nil || navProp == nil {
 		return "", false
@@ -621,8 +636,8 @@ func buildStandardComparison(dialect string, operator FilterOperator, columnName
 		// Use integer 1 instead of boolean true for database compatibility (PostgreSQL)
 		return fmt.Sprintf("%s = ?", funcSQL), append(funcArgs, 1)
 
-	case OpGeoIntersects:
-		funcSQL, funcArgs := buildFunctionSQL(dialect, OpGeoIntersects, columnName, value)
+	case OpGeoIntersects, OpGeoContains, OpGeoWithin:
+		funcSQL, funcArgs := buildFunctionSQL(dialect, operator, columnName, value)
 		if funcSQL == "" {
 			return "", nil
 		}
@@ -754,7 +769,7 @@ func buildComplexTypeNullComparison(dialect string, op FilterOperator, complexPr
 func buildComparisonConditionWithDB(db *gorm.DB, dialect string, filter *FilterExpression, entityMetadata *metadata.EntityMetadata) (string, []interface{}) {
 	// Handle lambda operators (any, all)
 	if filter.Operator == OpAny || filter.Operator == OpAll {
-		return buildLambdaCondition(dialect, filter, entityMetadata, "")
+		return buildLambdaCondition(dialect, withLambdaRowFilters(db, filter, entityMetadata), entityMetadata, "")
 	}
 
 	// Handle function comparisons (e.g., tolower(Name) eq 'john')
@@ -849,6 +864,10 @@ func buildEntityTypeFilter(dialect string, typeName string, entityMetadata *meta
 // query's table instead of the actual enclosing row. Pass "" at the top level, where
 // entityMetadata.TableName unambiguously refers to the row being filtered.
 func buildLambdaCondition(dialect string, filter *FilterExpression, entityMetadata *metadata.EntityMetadata, parentAlias string) (string, []interface{}) {
+	if collProp := entityMetadata.FindCollectionProperty(filter.Property); collProp != nil {
+		return buildCollectionLambdaCondition(dialect, filter, entityMetadata, collProp, parentAlias)
+	}
+
 	navProp := findNavigationProperty(filter.Property, entityMetadata)
 	if navProp == nil {
 		return "", nil
@@ -940,15 +959,19 @@ func buildLambdaCondition(dialect string, filter *FilterExpression, entityMetada
 
 	// Combine all join conditions with AND
 	joinCondition := strings.Join(joinConditions, " AND ")
+	rowSQL, rowArgs := buildRowFilterCondition(dialect, filter.RowFilter, navTargetMetadata, "")
+	if rowSQL != "" {
+		joinCondition = fmt.Sprintf("%s AND (%s)", joinCondition, rowSQL)
+	}
 
 	if filter.Value == nil || filter.Left == nil {
 		if filter.Operator == OpAny {
 			return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)",
-				quoteIdent(dialect, relatedTableName), joinCondition), []interface{}{}
+				quoteIdent(dialect, relatedTableName), joinCondition), lambdaArgs(rowArgs, nil)
 		}
 		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s) OR EXISTS (SELECT 1 FROM %s WHERE %s)",
 			quoteIdent(dialect, relatedTableName), joinCondition,
-			quoteIdent(dialect, relatedTableName), joinCondition), []interface{}{}
+			quoteIdent(dialect, relatedTableName), joinCondition), lambdaArgs(rowArgs, rowArgs)
 	}
 
 	predicate := filter.Left
@@ -970,7 +993,7 @@ func buildLambdaCondition(dialect string, filter *FilterExpression, entityMetada
 			quoteIdent(dialect, relatedTableName), joinCondition, predicateSQL)
 	}
 
-	return sql, predicateArgs
+	return sql, lambdaArgs(rowArgs, predicateArgs)
 }
 
 // buildManyToManyLambdaCondition builds SQL for a lambda operator (any/all) over a
@@ -1036,13 +1059,17 @@ func buildManyToManyLambdaCondition(dialect string, filter *FilterExpression, en
 	fromClause := fmt.Sprintf("%s JOIN %s AS %s ON %s",
 		quotedJoinTable, quotedRelatedTable, quotedRelatedAlias, strings.Join(joinToRelatedConditions, " AND "))
 	correlateCondition := strings.Join(correlateToParentConditions, " AND ")
+	rowSQL, rowArgs := buildRowFilterCondition(dialect, filter.RowFilter, navTargetMetadata, relatedAlias)
+	if rowSQL != "" {
+		correlateCondition = fmt.Sprintf("%s AND (%s)", correlateCondition, rowSQL)
+	}
 
 	if filter.Value == nil || filter.Left == nil {
 		if filter.Operator == OpAny {
-			return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", fromClause, correlateCondition), []interface{}{}
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", fromClause, correlateCondition), lambdaArgs(rowArgs, nil)
 		}
 		return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s) OR EXISTS (SELECT 1 FROM %s WHERE %s)",
-			fromClause, correlateCondition, fromClause, correlateCondition), []interface{}{}
+			fromClause, correlateCondition, fromClause, correlateCondition), lambdaArgs(rowArgs, rowArgs)
 	}
 
 	predicate := filter.Left
@@ -1062,7 +1089,22 @@ func buildManyToManyLambdaCondition(dialect string, filter *FilterExpression, en
 		sql = fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE %s AND NOT (%s))", fromClause, correlateCondition, predicateSQL)
 	}
 
-	return sql, predicateArgs
+	return sql, lambdaArgs(rowArgs, predicateArgs)
+}
+
+// lambdaArgs returns the arguments of a lambda subquery whose row filter
+// arguments precede the predicate arguments. Without a row filter the predicate
+// arguments are returned unchanged.
+func lambdaArgs(rowArgs, predicateArgs []interface{}) []interface{} {
+	if len(rowArgs) == 0 {
+		if predicateArgs == nil {
+			return []interface{}{}
+		}
+		return predicateArgs
+	}
+	args := make([]interface{}, 0, len(rowArgs)+len(predicateArgs))
+	args = append(args, rowArgs...)
+	return append(args, predicateArgs...)
 }
 
 func getNavigationTargetMetadata(entityMetadata *metadata.EntityMetadata, navProp *metadata.PropertyMetadata) *metadata.EntityMetadata {
@@ -1092,6 +1134,16 @@ func buildFilterConditionForLambda(dialect string, filter *FilterExpression, nav
 		return "", nil
 	}
 
+	if filter.IsNot {
+		positive := *filter
+		positive.IsNot = false
+		query, args := buildFilterConditionForLambda(dialect, &positive, navTargetMetadata, tableQualifier)
+		if query == "" {
+			return "", nil
+		}
+		return fmt.Sprintf("NOT (%s)", query), args
+	}
+
 	if filter.Logical != "" {
 		return buildLogicalConditionForLambda(dialect, filter, navTargetMetadata, tableQualifier)
 	}
@@ -1139,6 +1191,8 @@ func buildFilterConditionForLambda(dialect string, filter *FilterExpression, nav
 		return fmt.Sprintf("%s < ?", columnName), []interface{}{filter.Value}
 	case OpLessThanOrEqual:
 		return fmt.Sprintf("%s <= ?", columnName), []interface{}{filter.Value}
+	case OpIn:
+		return buildStandardComparison(dialect, filter.Operator, columnName, filter.Value, navTargetMetadata)
 	case OpContains:
 		return buildLikeComparison(dialect, columnName, filter.Value, true, true)
 	case OpStartsWith:
@@ -1789,6 +1843,16 @@ func buildFunctionSQL(dialect string, op FilterOperator, columnName string, valu
 			return fmt.Sprintf("ST_Intersects(%s, ST_GeomFromText(?, 4326))", columnName), []interface{}{geoStr}
 		}
 		return "", nil
+	case OpGeoContains:
+		if geoStr, ok := value.(string); ok {
+			return fmt.Sprintf("ST_Contains(%s, ST_GeomFromText(?, 4326))", columnName), []interface{}{geoStr}
+		}
+		return "", nil
+	case OpGeoWithin:
+		if geoStr, ok := value.(string); ok {
+			return fmt.Sprintf("ST_Within(%s, ST_GeomFromText(?, 4326))", columnName), []interface{}{geoStr}
+		}
+		return "", nil
 	default:
 		return "", nil
 	}
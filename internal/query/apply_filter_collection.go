@@ -0,0 +1,215 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+// collectionElementAlias is the alias of the table-valued function that expands
+// a JSON collection column into one row per element.
+const collectionElementAlias = "odata_elem"
+
+// buildCollectionLambdaCondition builds SQL for any/all over a collection of
+// primitive or complex values stored as a JSON array. The array is expanded with
+// json_each on SQLite and jsonb_array_elements on PostgreSQL; other databases
+// are not supported. parentAlias has the same meaning as for
+// buildLambdaCondition.
+func buildCollectionLambdaCondition(dialect string, filter *FilterExpression, entityMetadata *metadata.EntityMetadata, collProp *metadata.PropertyMetadata, parentAlias string) (string, []interface{}) {
+	source := collectionElementsSQL(dialect, collectionColumnSQL(dialect, entityMetadata, collProp, parentAlias))
+	if source == "" {
+		return "", nil
+	}
+
+	if filter.Value == nil || filter.Left == nil {
+		if filter.Operator == OpAny {
+			return fmt.Sprintf("EXISTS (SELECT 1 FROM %s)", source), []interface{}{}
+		}
+		// all() without a predicate is true for every collection.
+		return "1 = 1", []interface{}{}
+	}
+
+	predicateSQL, predicateArgs := buildCollectionPredicate(dialect, filter.Left, collProp)
+	if predicateSQL == "" {
+		return "", nil
+	}
+
+	if filter.Operator == OpAny {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", source, predicateSQL), predicateArgs
+	}
+	return fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s WHERE NOT (%s))", source, predicateSQL), predicateArgs
+}
+
+// buildCollectionCountSQL returns the number of elements of a JSON collection
+// column; a NULL column counts as empty.
+func buildCollectionCountSQL(dialect string, entityMetadata *metadata.EntityMetadata, collProp *metadata.PropertyMetadata) (string, bool) {
+	column := collectionColumnSQL(dialect, entityMetadata, collProp, "")
+	switch dialect {
+	case "sqlite":
+		return fmt.Sprintf("COALESCE(json_array_length(%s), 0)", column), true
+	case "postgres":
+		return fmt.Sprintf("COALESCE(jsonb_array_length(CAST(%s AS jsonb)), 0)", column), true
+	default:
+		return "", false
+	}
+}
+
+func collectionColumnSQL(dialect string, entityMetadata *metadata.EntityMetadata, collProp *metadata.PropertyMetadata, parentAlias string) string {
+	table := entityMetadata.TableName
+	if parentAlias != "" {
+		table = parentAlias
+	}
+	return quoteIdent(dialect, table) + "." + quoteIdent(dialect, collProp.ColumnName)
+}
+
+func collectionElementsSQL(dialect string, column string) string {
+	switch dialect {
+	case "sqlite":
+		return fmt.Sprintf("json_each(%s) AS %s", column, collectionElementAlias)
+	case "postgres":
+		return fmt.Sprintf("jsonb_array_elements(CAST(%s AS jsonb)) AS %s(value)", column, collectionElementAlias)
+	default:
+		return ""
+	}
+}
+
+// collectionElementSQL returns the SQL value of the lambda range variable ($it)
+// or of a property of a complex element.
+func collectionElementSQL(dialect string, property string, collProp *metadata.PropertyMetadata) string {
+	elemType := collProp.CollectionElementType()
+	key := ""
+	if property != "$it" {
+		field, ok := collProp.ComplexTypeFields[property]
+		if !ok || field.IsComplexType || field.IsNavigationProp || !isValidSQLIdentifier(field.JsonName) {
+			return ""
+		}
+		key = field.JsonName
+		elemType = field.Type
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+	} else if len(collProp.ComplexTypeFields) > 0 {
+		return ""
+	}
+
+	value := collectionElementAlias + ".value"
+	switch dialect {
+	case "sqlite":
+		if key == "" {
+			return value
+		}
+		return fmt.Sprintf("json_extract(%s, '$.%s')", value, key)
+	case "postgres":
+		text := fmt.Sprintf("(%s #>> '{}')", value)
+		if key != "" {
+			text = fmt.Sprintf("(%s ->> '%s')", value, key)
+		}
+		switch elemType.Kind() {
+		case reflect.Bool:
+			return fmt.Sprintf("CAST(%s AS boolean)", text)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return fmt.Sprintf("CAST(%s AS numeric)", text)
+		default:
+			return text
+		}
+	default:
+		return ""
+	}
+}
+
+// buildCollectionPredicate builds the WHERE condition of a lambda predicate over
+// the elements of a collection property.
+func buildCollectionPredicate(dialect string, filter *FilterExpression, collProp *metadata.PropertyMetadata) (string, []interface{}) {
+	if filter == nil {
+		return "", nil
+	}
+
+	if filter.Logical != "" {
+		leftSQL, leftArgs := buildCollectionPredicate(dialect, filter.Left, collProp)
+		rightSQL, rightArgs := buildCollectionPredicate(dialect, filter.Right, collProp)
+		if leftSQL == "" || rightSQL == "" {
+			return "", nil
+		}
+		var sql string
+		switch filter.Logical {
+		case LogicalAnd:
+			sql = fmt.Sprintf("(%s) AND (%s)", leftSQL, rightSQL)
+		case LogicalOr:
+			sql = fmt.Sprintf("(%s) OR (%s)", leftSQL, rightSQL)
+		default:
+			return "", nil
+		}
+		if filter.IsNot {
+			sql = fmt.Sprintf("NOT (%s)", sql)
+		}
+		return sql, append(leftArgs, rightArgs...)
+	}
+
+	sql, args := buildCollectionComparison(dialect, filter, collProp)
+	if sql != "" && filter.IsNot {
+		sql = fmt.Sprintf("NOT (%s)", sql)
+	}
+	return sql, args
+}
+
+func buildCollectionComparison(dialect string, filter *FilterExpression, collProp *metadata.PropertyMetadata) (string, []interface{}) {
+	// Nested lambdas over the elements are not supported.
+	if filter.Operator == OpAny || filter.Operator == OpAll {
+		return "", nil
+	}
+
+	var (
+		valueSQL  string
+		valueArgs []interface{}
+	)
+	if filter.Left != nil && filter.Left.Operator != "" {
+		if isArithmeticFilterOperator(filter.Left.Operator) {
+			return "", nil
+		}
+		elemSQL := collectionElementSQL(dialect, strings.TrimPrefix(filter.Left.Property, "$it/"), collProp)
+		if elemSQL == "" {
+			return "", nil
+		}
+		valueSQL, valueArgs = buildFunctionSQL(dialect, filter.Left.Operator, elemSQL, filter.Left.Value)
+	} else {
+		valueSQL = collectionElementSQL(dialect, strings.TrimPrefix(filter.Property, "$it/"), collProp)
+	}
+	if valueSQL == "" {
+		return "", nil
+	}
+
+	var (
+		sql  string
+		args []interface{}
+	)
+	switch filter.Operator {
+	case OpEqual, OpNotEqual:
+		if filter.Value == nil {
+			if filter.Operator == OpEqual {
+				return fmt.Sprintf("%s IS NULL", valueSQL), valueArgs
+			}
+			return fmt.Sprintf("%s IS NOT NULL", valueSQL), valueArgs
+		}
+		sql, args = buildComparisonSQL(filter.Operator, valueSQL), []interface{}{filter.Value}
+	case OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual:
+		sql, args = buildComparisonSQL(filter.Operator, valueSQL), []interface{}{filter.Value}
+	case OpContains:
+		sql, args = buildLikeComparison(dialect, valueSQL, filter.Value, true, true)
+	case OpStartsWith:
+		sql, args = buildLikeComparison(dialect, valueSQL, filter.Value, false, true)
+	case OpEndsWith:
+		sql, args = buildLikeComparison(dialect, valueSQL, filter.Value, true, false)
+	case OpMatchesPattern:
+		sql, args = buildRegexComparison(dialect, valueSQL, filter.Value)
+	default:
+		return "", nil
+	}
+	if sql == "" {
+		return "", nil
+	}
+	return sql, append(valueArgs, args...)
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+type collectionFilterAddress struct {
+	City string `json:"City"`
+}
+
+type collectionFilterProduct struct {
+	ID        int                       `json:"ID" gorm:"primaryKey" odata:"key"`
+	Tags      []string                  `json:"Tags" gorm:"serializer:json"`
+	Ratings   []int                     `json:"Ratings" gorm:"serializer:json"`
+	Addresses []collectionFilterAddress `json:"Addresses" gorm:"serializer:json"`
+}
+
+func TestBuildCollectionPropertyFilter(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&collectionFilterProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		dialect  string
+		filter   string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "sqlite any",
+			dialect:  "sqlite",
+			filter:   "Tags/any(t: t eq 'sale')",
+			wantSQL:  `EXISTS (SELECT 1 FROM json_each("collection_filter_products"."tags") AS odata_elem WHERE odata_elem.value = ?)`,
+			wantArgs: []interface{}{"sale"},
+		},
+		{
+			name:     "sqlite all",
+			dialect:  "sqlite",
+			filter:   "Ratings/all(r: r gt 2)",
+			wantSQL:  `NOT EXISTS (SELECT 1 FROM json_each("collection_filter_products"."ratings") AS odata_elem WHERE NOT (odata_elem.value > ?))`,
+			wantArgs: []interface{}{int64(2)},
+		},
+		{
+			name:     "sqlite complex element",
+			dialect:  "sqlite",
+			filter:   "Addresses/any(a: a/City eq 'Berlin')",
+			wantSQL:  `EXISTS (SELECT 1 FROM json_each("collection_filter_products"."addresses") AS odata_elem WHERE json_extract(odata_elem.value, '$.City') = ?)`,
+			wantArgs: []interface{}{"Berlin"},
+		},
+		{
+			name:     "postgres any",
+			dialect:  "postgres",
+			filter:   "Tags/any(t: t eq 'sale')",
+			wantSQL:  `EXISTS (SELECT 1 FROM jsonb_array_elements(CAST("collection_filter_products"."tags" AS jsonb)) AS odata_elem(value) WHERE (odata_elem.value #>> '{}') = ?)`,
+			wantArgs: []interface{}{"sale"},
+		},
+		{
+			name:     "postgres numeric element",
+			dialect:  "postgres",
+			filter:   "Ratings/any(r: r gt 2)",
+			wantSQL:  `EXISTS (SELECT 1 FROM jsonb_array_elements(CAST("collection_filter_products"."ratings" AS jsonb)) AS odata_elem(value) WHERE CAST((odata_elem.value #>> '{}') AS numeric) > ?)`,
+			wantArgs: []interface{}{int64(2)},
+		},
+		{
+			name:     "postgres complex element",
+			dialect:  "postgres",
+			filter:   "Addresses/any(a: a/City eq 'Berlin')",
+			wantSQL:  `EXISTS (SELECT 1 FROM jsonb_array_elements(CAST("collection_filter_products"."addresses" AS jsonb)) AS odata_elem(value) WHERE (odata_elem.value ->> 'City') = ?)`,
+			wantArgs: []interface{}{"Berlin"},
+		},
+		{
+			name:     "parameterless any",
+			dialect:  "sqlite",
+			filter:   "Tags/any()",
+			wantSQL:  `EXISTS (SELECT 1 FROM json_each("collection_filter_products"."tags") AS odata_elem)`,
+			wantArgs: []interface{}{},
+		},
+		{
+			name:     "sqlite count",
+			dialect:  "sqlite",
+			filter:   "Tags/$count gt 1",
+			wantSQL:  `COALESCE(json_array_length("collection_filter_products"."tags"), 0) > ?`,
+			wantArgs: []interface{}{int64(1)},
+		},
+		{
+			name:     "postgres count",
+			dialect:  "postgres",
+			filter:   "Tags/$count gt 1",
+			wantSQL:  `COALESCE(jsonb_array_length(CAST("collection_filter_products"."tags" AS jsonb)), 0) > ?`,
+			wantArgs: []interface{}{int64(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filterExpr, err := parseFilter(tt.filter, meta, nil, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+			sql, args := buildFilterCondition(tt.dialect, filterExpr, meta)
+			if sql != tt.wantSQL {
+				t.Errorf("expected SQL\n%s\ngot\n%s", tt.wantSQL, sql)
+			}
+			if len(args) != len(tt.wantArgs) {
+				t.Fatalf("expected args %#v, got %#v", tt.wantArgs, args)
+			}
+			for i := range args {
+				if args[i] != tt.wantArgs[i] {
+					t.Errorf("expected args %#v, got %#v", tt.wantArgs, args)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildCollectionPropertyFilter_UnsupportedDialect(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&collectionFilterProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	filterExpr, err := parseFilter("Tags/any(t: t eq 'sale')", meta, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse filter: %v", err)
+	}
+	if sql, _ := buildFilterCondition("mysql", filterExpr, meta); sql != "" {
+		t.Errorf("expected no condition for mysql, got %s", sql)
+	}
+}
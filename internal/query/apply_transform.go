@@ -1150,6 +1150,10 @@ func applyOrderBy(db *gorm.DB, orderBy []OrderByItem, entityMetadata *metadata.E
 	if dialect == "postgres" {
 		var orderExprs []clause.OrderByColumn
 		for _, item := range orderBy {
+			if item.Property == SearchScoreProperty {
+				// Relevance ordering is applied by the handler, not in SQL.
+				continue
+			}
 			var columnName string
 			isNavigationPath := entityMetadata != nil && entityMetadata.IsSingleEntityNavigationPath(item.Property)
 			if propertyExists(item.Property, entityMetadata) {
@@ -1215,6 +1219,9 @@ func applyOrderBy(db *gorm.DB, orderBy []OrderByItem, entityMetadata *metadata.E
 	} else {
 		// For other databases, use the simple approach
 		for _, item := range orderBy {
+			if item.Property == SearchScoreProperty {
+				continue
+			}
 			var columnName string
 			rawColumn := false
 			if propertyExists(item.Property, entityMetadata) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
 )
 
@@ -123,7 +124,8 @@ func isArithmeticFunction(name string) bool {
 
 // isGeospatialFunction checks if a function is a geospatial function
 func isGeospatialFunction(name string) bool {
-	return name == "geo.distance" || name == "geo.length" || name == "geo.intersects"
+	return name == "geo.distance" || name == "geo.length" || name == "geo.intersects" ||
+		name == "geo.contains" || name == "geo.within"
 }
 
 // extractPropertyFromFunctionArgWithContext extracts property from function argument using the provided context
@@ -639,6 +641,9 @@ func convertGeospatialFunctionWithContext(n *FunctionCallExpr, functionName stri
 		// Second argument should be a geography/geometry literal
 		var geoValue interface{}
 		if lit, ok := n.Args[1].(*LiteralExpr); ok {
+			if err := checkSpatialOperands("geo.distance", property, entityMetadata, geo.KindPoint, lit, geo.KindPoint); err != nil {
+				return nil, err
+			}
 			geoValue = lit.Value
 		} else {
 			return nil, errSecondArgOfGeoDistanceMustBeGeoLit
@@ -660,6 +665,9 @@ func convertGeospatialFunctionWithContext(n *FunctionCallExpr, functionName stri
 		if err != nil {
 			return nil, err
 		}
+		if err := checkSpatialOperands("geo.length", property, entityMetadata, geo.KindLineString, nil, 0); err != nil {
+			return nil, err
+		}
 
 		expr := acquireFilterExpression()
 		expr.Property = property
@@ -682,6 +690,9 @@ func convertGeospatialFunctionWithContext(n *FunctionCallExpr, functionName stri
 		// Second argument should be a geography/geometry literal
 		var geoValue interface{}
 		if lit, ok := n.Args[1].(*LiteralExpr); ok {
+			if err := checkSpatialOperands("geo.intersects", property, entityMetadata, geo.KindPoint, lit, geo.KindPolygon); err != nil {
+				return nil, err
+			}
 			geoValue = lit.Value
 		} else {
 			return nil, errSecondArgOfGeoIntersectsMustBeGeoLit
@@ -693,7 +704,70 @@ func convertGeospatialFunctionWithContext(n *FunctionCallExpr, functionName stri
 		expr.Value = geoValue
 		return expr, nil
 
+	case "geo.contains", "geo.within":
+		// geo.contains(geo1, geo2) and geo.within(geo1, geo2) take shapes of
+		// any kind
+		if len(n.Args) != 2 {
+			return nil, fmt.Errorf("function %s requires 2 arguments", functionName)
+		}
+
+		property, err := extractPropertyFromFunctionArgWithContext(n.Args[0], functionName, entityMetadata, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		lit, ok := n.Args[1].(*LiteralExpr)
+		if !ok {
+			return nil, fmt.Errorf("second argument of %s must be a geography or geometry literal", functionName)
+		}
+		if err := checkSpatialOperands(functionName, property, entityMetadata, 0, lit, 0); err != nil {
+			return nil, err
+		}
+
+		expr := acquireFilterExpression()
+		expr.Property = property
+		expr.Operator = FilterOperator(functionName)
+		expr.Value = lit.Value
+		return expr, nil
+
 	default:
 		return nil, fmt.Errorf("unsupported geospatial function: %s", functionName)
 	}
 }
+
+// checkSpatialOperands checks the operands of a geo function against the
+// spatial type of its property: the property must be of the kind the function
+// takes, and the literal, if any, must be of the property's family and the
+// expected kind. A zero kind accepts shapes of any kind. Properties without a
+// spatial Go type, such as WKT strings, are not checked.
+func checkSpatialOperands(functionName, property string, entityMetadata *metadata.EntityMetadata, propertyKind geo.Kind, lit *LiteralExpr, literalKind geo.Kind) error {
+	prop := findProperty(property, entityMetadata)
+	if prop == nil {
+		return nil
+	}
+	edmType, ok := geo.EdmTypeOf(prop.Type)
+	if !ok {
+		return nil
+	}
+	family, kind, _ := geo.ParseEdmType(edmType)
+	if propertyKind != 0 && kind != propertyKind {
+		return fmt.Errorf("%s requires a %s property, but '%s' is %s", functionName, geo.EdmType(family, propertyKind), property, edmType)
+	}
+	if lit == nil {
+		return nil
+	}
+
+	familyName := strings.ToLower(family.String())
+	if lit.Type != familyName {
+		return fmt.Errorf("%s on %s property '%s' requires a %s literal", functionName, edmType, property, familyName)
+	}
+	text, _ := lit.Value.(string)
+	shape, err := geo.Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid %s literal: %w", familyName, err)
+	}
+	if literalKind != 0 && shape.Kind() != literalKind {
+		return fmt.Errorf("%s requires a %s literal, got %s", functionName, geo.EdmType(family, literalKind), geo.EdmType(family, shape.Kind()))
+	}
+	return nil
+}
@@ -157,6 +157,7 @@ func cloneFilterExpression(filter *FilterExpression) *FilterExpression {
 	clone.Value = filter.Value
 	clone.Logical = filter.Logical
 	clone.IsNot = filter.IsNot
+	clone.RowFilter = filter.RowFilter
 	clone.maxInClauseSize = filter.maxInClauseSize
 	if filter.Left != nil {
 		clone.Left = cloneFilterExpression(filter.Left)
@@ -12,6 +12,8 @@ import (
 )
 
 // ApplyPerParentExpand applies $expand options with $top/$skip for collection navigation properties per parent.
+// Children in virtual entity sets are loaded through the VirtualExpandSource
+// carried by the statement context of db.
 func ApplyPerParentExpand(db *gorm.DB, results interface{}, expandOptions []ExpandOption, entityMetadata *metadata.EntityMetadata) error {
 	if db == nil || results == nil || len(expandOptions) == 0 || entityMetadata == nil {
 		return nil
@@ -59,7 +61,12 @@ func ApplyPerParentExpand(db *gorm.DB, results interface{}, expandOptions []Expa
 			continue
 		}
 
-		childResults, err := fetchChildrenByParentKeys(db, expandOpt, targetMetadata, constraints, parentKeys)
+		var childResults reflect.Value
+		if targetMetadata.IsVirtual {
+			childResults, err = fetchVirtualChildren(db, expandOpt, targetMetadata, constraints, parentKeys)
+		} else {
+			childResults, err = fetchChildrenByParentKeys(db, expandOpt, targetMetadata, constraints, parentKeys)
+		}
 		if err != nil {
 			return err
 		}
@@ -74,25 +81,24 @@ func ApplyPerParentExpand(db *gorm.DB, results interface{}, expandOptions []Expa
 			}
 
 			parentChildren = applyPerParentPagination(parentChildren, expandOpt.Skip, expandOpt.Top)
-			childResultsForParent := reflect.New(sliceType)
-			childResultsForParent.Elem().Set(parentChildren)
-
-			if len(expandOpt.Expand) > 0 {
-				if err := ApplyPerParentExpand(db, childResultsForParent.Interface(), expandOpt.Expand, targetMetadata); err != nil {
-					return err
-				}
-			}
-
 			for _, parentVal := range parents {
 				parentStruct := dereferenceValue(parentVal)
 				if !parentStruct.IsValid() || parentStruct.Kind() != reflect.Struct {
 					continue
 				}
-				if err := setNavigationValue(parentStruct, navProp, childResultsForParent.Elem()); err != nil {
+				if err := setNavigationValue(parentStruct, navProp, parentChildren); err != nil {
 					return err
 				}
 			}
 		}
+
+		// Nested expands load the children of all parents at once, so that a
+		// virtual entity set is asked once per level instead of once per parent.
+		if len(expandOpt.Expand) > 0 {
+			if err := applyNestedPerParentExpand(db, parentValues, navProp, expandOpt.Expand, targetMetadata); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -108,6 +114,9 @@ func loadManyToManyExpand(db *gorm.DB, parentValues []reflect.Value, navProp *me
 	if err != nil {
 		return err
 	}
+	if entityMetadata.IsVirtual || targetMetadata.IsVirtual {
+		return fmt.Errorf("cannot expand '%s': many-to-many navigation properties of virtual entity sets are not supported", expandOpt.NavigationProperty)
+	}
 	for _, parentValue := range parentValues {
 		parent := dereferenceValue(parentValue)
 		if !parent.IsValid() || parent.Kind() != reflect.Struct || !parent.CanAddr() {
@@ -0,0 +1,140 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"gorm.io/gorm"
+)
+
+// VirtualExpandSource loads entities of a virtual entity set for $expand. The
+// options carry a filter restricting the entities to the expanded parents,
+// combined with the nested $filter, and the nested $select, $orderby and
+// $compute. $top and $skip apply per parent and are left to the caller. The
+// entities are returned as a slice of the target's entity type or of pointers
+// to it.
+type VirtualExpandSource func(ctx context.Context, target *metadata.EntityMetadata, options *QueryOptions) (interface{}, error)
+
+type virtualExpandSourceKey struct{}
+
+// WithVirtualExpandSource returns a context whose $expand of navigation
+// properties that target virtual entity sets loads the related entities from
+// source rather than the database.
+func WithVirtualExpandSource(ctx context.Context, source VirtualExpandSource) context.Context {
+	return context.WithValue(ctx, virtualExpandSourceKey{}, source)
+}
+
+// virtualExpandSource returns the source carried by the statement context of db.
+func virtualExpandSource(db *gorm.DB) VirtualExpandSource {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return nil
+	}
+	source, _ := db.Statement.Context.Value(virtualExpandSourceKey{}).(VirtualExpandSource)
+	return source
+}
+
+// fetchVirtualChildren loads the children of all parents with one call to the
+// virtual expand source. Children that do not belong to a requested parent are
+// dropped when they are grouped, so the source may return a superset.
+func fetchVirtualChildren(db *gorm.DB, expandOpt ExpandOption, targetMetadata *metadata.EntityMetadata, constraints []parentReferenceConstraint, parentKeys []parentKey) (reflect.Value, error) {
+	sliceType := reflect.SliceOf(targetMetadata.EntityType)
+	if len(parentKeys) == 0 {
+		return reflect.MakeSlice(sliceType, 0, 0), nil
+	}
+
+	source := virtualExpandSource(db)
+	if source == nil {
+		return reflect.Value{}, fmt.Errorf("cannot expand '%s': no source is configured for virtual entity set '%s'",
+			expandOpt.NavigationProperty, targetMetadata.EntitySetName)
+	}
+
+	options := &QueryOptions{
+		Filter:  MergeFilterExpressions(parentKeyFilterExpression(constraints, parentKeys, targetMetadata), expandOpt.Filter),
+		Select:  expandOpt.Select,
+		OrderBy: expandOpt.OrderBy,
+		Compute: expandOpt.Compute,
+	}
+	items, err := source(db.Statement.Context, targetMetadata, options)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return collectVirtualChildren(items, targetMetadata)
+}
+
+// parentKeyFilterExpression builds the filter matching the children of the
+// parent keys: "Prop in (...)" for a single-property relationship, and a
+// disjunction of per-parent conjunctions for a composite one.
+func parentKeyFilterExpression(constraints []parentReferenceConstraint, parentKeys []parentKey, targetMetadata *metadata.EntityMetadata) *FilterExpression {
+	properties := make([]string, len(constraints))
+	for i, constraint := range constraints {
+		properties[i] = constraint.dependentProperty
+		if prop := targetMetadata.FindProperty(constraint.dependentProperty); prop != nil && prop.JsonName != "" {
+			properties[i] = prop.JsonName
+		}
+	}
+
+	if len(constraints) == 1 {
+		values := make([]interface{}, 0, len(parentKeys))
+		for _, key := range parentKeys {
+			values = append(values, key.values[0])
+		}
+		return &FilterExpression{Property: properties[0], Operator: OpIn, Value: values}
+	}
+
+	var disjunction *FilterExpression
+	for _, key := range parentKeys {
+		var conjunction *FilterExpression
+		for i, property := range properties {
+			comparison := &FilterExpression{Property: property, Operator: OpEqual, Value: key.values[i]}
+			if conjunction == nil {
+				conjunction = comparison
+				continue
+			}
+			conjunction = &FilterExpression{Left: conjunction, Right: comparison, Logical: LogicalAnd}
+		}
+		if disjunction == nil {
+			disjunction = conjunction
+			continue
+		}
+		disjunction = &FilterExpression{Left: disjunction, Right: conjunction, Logical: LogicalOr}
+	}
+	return disjunction
+}
+
+// collectVirtualChildren copies the entities returned by a virtual expand
+// source into a slice of the target's entity type.
+func collectVirtualChildren(items interface{}, targetMetadata *metadata.EntityMetadata) (reflect.Value, error) {
+	sliceType := reflect.SliceOf(targetMetadata.EntityType)
+	children := reflect.MakeSlice(sliceType, 0, 0)
+	if items == nil {
+		return children, nil
+	}
+
+	val := dereferenceValue(reflect.ValueOf(items))
+	if !val.IsValid() {
+		return children, nil
+	}
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("virtual entity set '%s' returned %s, expected a slice of %s",
+			targetMetadata.EntitySetName, val.Type(), targetMetadata.EntityType)
+	}
+
+	for i := 0; i < val.Len(); i++ {
+		item := val.Index(i)
+		if item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		item = dereferenceValue(item)
+		if !item.IsValid() {
+			continue
+		}
+		if item.Type() != targetMetadata.EntityType {
+			return reflect.Value{}, fmt.Errorf("virtual entity set '%s' returned %s, expected %s",
+				targetMetadata.EntitySetName, item.Type(), targetMetadata.EntityType)
+		}
+		children = reflect.Append(children, item)
+	}
+	return children, nil
+}
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type virtualExpandLine struct {
+	OrderID uint `odata:"key"`
+	LineNo  int  `odata:"key"`
+	Note    string
+}
+
+func TestParentKeyFilterExpressionSingleProperty(t *testing.T) {
+	target, err := metadata.AnalyzeVirtualEntity(virtualExpandLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []parentReferenceConstraint{{dependentProperty: "OrderID", principalProperty: "ID"}}
+	keys := []parentKey{{values: []interface{}{uint(1)}}, {values: []interface{}{uint(2)}}}
+
+	filter := parentKeyFilterExpression(constraints, keys, target)
+	if filter.Property != "OrderID" || filter.Operator != OpIn {
+		t.Fatalf("expected OrderID in (...), got %+v", filter)
+	}
+	if values, _ := filter.Value.([]interface{}); len(values) != 2 {
+		t.Fatalf("expected two values, got %v", filter.Value)
+	}
+}
+
+func TestParentKeyFilterExpressionComposite(t *testing.T) {
+	target, err := metadata.AnalyzeVirtualEntity(virtualExpandLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []parentReferenceConstraint{
+		{dependentProperty: "OrderID", principalProperty: "ID"},
+		{dependentProperty: "LineNo", principalProperty: "LineNo"},
+	}
+	keys := []parentKey{{values: []interface{}{uint(1), 1}}, {values: []interface{}{uint(1), 2}}}
+
+	filter := parentKeyFilterExpression(constraints, keys, target)
+	if filter.Logical != LogicalOr {
+		t.Fatalf("expected a disjunction of the parents, got %+v", filter)
+	}
+	for _, parent := range []*FilterExpression{filter.Left, filter.Right} {
+		if parent.Logical != LogicalAnd || parent.Left.Property != "OrderID" || parent.Right.Property != "LineNo" {
+			t.Fatalf("expected a conjunction per parent, got %+v", parent)
+		}
+	}
+}
+
+func TestCollectVirtualChildren(t *testing.T) {
+	target, err := metadata.AnalyzeVirtualEntity(virtualExpandLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	children, err := collectVirtualChildren([]interface{}{virtualExpandLine{LineNo: 1}, &virtualExpandLine{LineNo: 2}, nil}, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children.Len() != 2 || children.Index(1).Interface().(virtualExpandLine).LineNo != 2 {
+		t.Fatalf("expected both lines, got %v", children.Interface())
+	}
+
+	if _, err := collectVirtualChildren([]perParentBook{{ID: 1}}, target); err == nil {
+		t.Fatal("expected an error for entities of another type")
+	}
+}
+
+func TestFetchVirtualChildrenUsesContextSource(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := metadata.AnalyzeVirtualEntity(virtualExpandLine{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []parentReferenceConstraint{{dependentProperty: "OrderID", principalProperty: "ID"}}
+	keys := []parentKey{{values: []interface{}{uint(1)}}}
+
+	if _, err := fetchVirtualChildren(db, ExpandOption{NavigationProperty: "Lines"}, target, constraints, keys); err == nil {
+		t.Fatal("expected an error without a virtual expand source")
+	}
+
+	var received *QueryOptions
+	ctx := WithVirtualExpandSource(context.Background(), func(_ context.Context, _ *metadata.EntityMetadata, options *QueryOptions) (interface{}, error) {
+		received = options
+		return []virtualExpandLine{{OrderID: 1, LineNo: 1}}, nil
+	})
+	nested := &FilterExpression{Property: "Note", Operator: OpEqual, Value: "x"}
+	children, err := fetchVirtualChildren(db.WithContext(ctx), ExpandOption{NavigationProperty: "Lines", Filter: nested, Select: []string{"Note"}}, target, constraints, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children.Len() != 1 {
+		t.Fatalf("expected one line, got %v", children.Interface())
+	}
+	if received.Filter.Logical != LogicalAnd || received.Filter.Right != nested || len(received.Select) != 1 {
+		t.Fatalf("expected the key filter combined with the nested options, got %+v", received)
+	}
+}
@@ -45,6 +45,7 @@ func (f *FilterExpression) reset() {
 	f.Right = nil
 	f.Logical = ""
 	f.IsNot = false
+	f.RowFilter = nil
 	f.maxInClauseSize = 0
 }
 
@@ -0,0 +1,40 @@
+package query
+
+import "strings"
+
+// FilterPropertyPaths returns the distinct property paths referenced by a filter expression,
+// in the order they first appear. Function calls and arithmetic operands contribute the
+// property they operate on. For lambda operators (any/all) only the collection path is
+// reported, since the predicate refers to properties of the related entity.
+func FilterPropertyPaths(filter *FilterExpression) []string {
+	if filter == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	collectFilterPropertyPaths(filter, seen, &paths)
+	return paths
+}
+
+func collectFilterPropertyPaths(filter *FilterExpression, seen map[string]bool, paths *[]string) {
+	if filter == nil {
+		return
+	}
+
+	property := strings.TrimPrefix(filter.Property, "$it/")
+	// Internal markers such as "_func_tolower_Name_eq" carry the real operand in Left.
+	if property != "" && !strings.HasPrefix(property, "_") && !strings.HasPrefix(property, "$") {
+		if !seen[property] {
+			seen[property] = true
+			*paths = append(*paths, property)
+		}
+	}
+
+	if filter.Operator == OpAny || filter.Operator == OpAll {
+		return
+	}
+
+	collectFilterPropertyPaths(filter.Left, seen, paths)
+	collectFilterPropertyPaths(filter.Right, seen, paths)
+}
@@ -0,0 +1,72 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+func TestFilterPropertyPaths(t *testing.T) {
+	type TestEntity struct {
+		ID    int     `json:"ID" odata:"key"`
+		Name  string  `json:"Name"`
+		Notes string  `json:"Notes"`
+		Price float64 `json:"Price"`
+	}
+
+	meta, err := metadata.AnalyzeEntity(&TestEntity{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected []string
+	}{
+		{
+			name:     "simple comparison",
+			filter:   "Name eq 'test'",
+			expected: []string{"Name"},
+		},
+		{
+			name:     "logical combination",
+			filter:   "Name eq 'test' and Price gt 10 or Name eq 'other'",
+			expected: []string{"Name", "Price"},
+		},
+		{
+			name:     "function call",
+			filter:   "contains(Notes,'rush')",
+			expected: []string{"Notes"},
+		},
+		{
+			name:     "function comparison",
+			filter:   "tolower(Name) eq 'test'",
+			expected: []string{"Name"},
+		},
+		{
+			name:     "arithmetic comparison",
+			filter:   "Price add 1 gt 10",
+			expected: []string{"Price"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseFilter(tt.filter, meta, nil, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+
+			result := FilterPropertyPaths(filter)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("FilterPropertyPaths() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+
+	if paths := FilterPropertyPaths(nil); paths != nil {
+		t.Errorf("FilterPropertyPaths(nil) = %v, expected nil", paths)
+	}
+}
@@ -3,6 +3,7 @@ package query
 import (
 	"testing"
 
+	"github.com/nlstn/go-odata/internal/geo"
 	"github.com/nlstn/go-odata/internal/metadata"
 )
 
@@ -217,3 +218,78 @@ func TestGeoLiteralParsing(t *testing.T) {
 		})
 	}
 }
+
+// TestTypedGeoEntity declares its geospatial properties with spatial types
+type TestTypedGeoEntity struct {
+	ID       int                     `json:"ID" odata:"key"`
+	Location geo.GeographyPoint      `json:"Location"`
+	Route    geo.GeographyLineString `json:"Route"`
+	Site     geo.GeometryPoint       `json:"Site"`
+}
+
+func TestGeoFunctionsCheckSpatialTypes(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(TestTypedGeoEntity{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		filterStr   string
+		expectError bool
+	}{
+		{
+			name:      "geo.distance between geography points",
+			filterStr: "geo.distance(Location,geography'SRID=4326;Point(0 0)') lt 10",
+		},
+		{
+			name:        "geo.distance with geometry literal on geography property",
+			filterStr:   "geo.distance(Location,geometry'Point(0 0)') lt 10",
+			expectError: true,
+		},
+		{
+			name:        "geo.distance with polygon literal",
+			filterStr:   "geo.distance(Location,geography'Polygon((0 0,1 0,1 1,0 0))') lt 10",
+			expectError: true,
+		},
+		{
+			name:      "geo.distance between geometry points",
+			filterStr: "geo.distance(Site,geometry'Point(3 4)') lt 10",
+		},
+		{
+			name:      "geo.length of line string",
+			filterStr: "geo.length(Route) gt 10",
+		},
+		{
+			name:        "geo.length of point",
+			filterStr:   "geo.length(Location) gt 10",
+			expectError: true,
+		},
+		{
+			name:      "geo.intersects point and polygon",
+			filterStr: "geo.intersects(Location,geography'Polygon((0 0,1 0,1 1,0 0))')",
+		},
+		{
+			name:        "geo.intersects line string",
+			filterStr:   "geo.intersects(Route,geography'Polygon((0 0,1 0,1 1,0 0))')",
+			expectError: true,
+		},
+		{
+			name:        "geo.distance with malformed literal",
+			filterStr:   "geo.distance(Location,geography'Point(0)') lt 10",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFilter(tt.filterStr, meta, nil, 0)
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
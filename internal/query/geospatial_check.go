@@ -8,7 +8,7 @@ func ContainsGeospatialOperations(filter *FilterExpression) bool {
 
 	// Check current operator
 	switch filter.Operator {
-	case OpGeoDistance, OpGeoLength, OpGeoIntersects:
+	case OpGeoDistance, OpGeoLength, OpGeoIntersects, OpGeoContains, OpGeoWithin:
 		return true
 	}
 
@@ -282,6 +282,9 @@ func resolveCollectionCountPath(path string, entityMetadata *metadata.EntityMeta
 }
 
 func isCollectionCountPath(path string, entityMetadata *metadata.EntityMetadata) bool {
+	if name, ok := strings.CutSuffix(path, "/$count"); ok && entityMetadata != nil && entityMetadata.FindCollectionProperty(name) != nil {
+		return true
+	}
 	_, _, err := resolveCollectionCountPath(path, entityMetadata)
 	return err == nil
 }
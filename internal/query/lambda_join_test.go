@@ -1,6 +1,8 @@
 package query
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -67,3 +69,75 @@ func TestBuildLambdaCondition_CompositeKeyUsesColumnNames(t *testing.T) {
 		t.Fatalf("expected join clause %q in SQL: %s", expectedJoin, sql)
 	}
 }
+
+func TestBuildLambdaCondition_RowFilterRestrictsRange(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	parentMeta, err := metadata.AnalyzeEntity(testCompositeParent{})
+	if err != nil {
+		t.Fatalf("Failed to analyze parent entity: %v", err)
+	}
+	childMeta, err := metadata.AnalyzeEntity(testCompositeChild{})
+	if err != nil {
+		t.Fatalf("Failed to analyze child entity: %v", err)
+	}
+	registry := map[string]*metadata.EntityMetadata{
+		parentMeta.EntityName: parentMeta,
+		childMeta.EntityName:  childMeta,
+	}
+	parentMeta.SetEntitiesRegistry(registry)
+	childMeta.SetEntitiesRegistry(registry)
+
+	rowFilter, err := parseFilter("not (Quantity ge 100)", childMeta, nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse row filter: %v", err)
+	}
+
+	for _, tt := range []struct {
+		filter string
+		want   string
+	}{
+		{filter: "Items/any(i: i/Quantity gt 0)", want: `AND (NOT ("qty_value" >= ?)) AND ("qty_value" > ?)`},
+		{filter: "Items/all(i: i/Quantity gt 0)", want: `AND (NOT ("qty_value" >= ?)) AND NOT ("qty_value" > ?)`},
+	} {
+		t.Run(tt.filter, func(t *testing.T) {
+			filterExpr, err := parseFilter(tt.filter, parentMeta, nil, 0)
+			if err != nil {
+				t.Fatalf("Failed to parse filter: %v", err)
+			}
+			ctx := WithRowFilterResolver(context.Background(), func(entity *metadata.EntityMetadata) (*FilterExpression, error) {
+				if entity == childMeta {
+					return rowFilter, nil
+				}
+				return nil, nil
+			})
+
+			query := ApplyFilterOnly(db.WithContext(ctx).Model(&testCompositeParent{}), filterExpr, parentMeta, nil)
+			var parents []testCompositeParent
+			stmt := query.Find(&parents).Statement
+			if sql := stmt.SQL.String(); !strings.Contains(sql, tt.want) {
+				t.Fatalf("expected %q in SQL: %s", tt.want, sql)
+			}
+			if got := fmt.Sprint(stmt.Vars); got != "[100 0]" {
+				t.Fatalf("expected the row filter argument first, got %v", stmt.Vars)
+			}
+			if filterExpr.RowFilter != nil {
+				t.Fatal("expected the parsed filter to be left unmodified")
+			}
+		})
+	}
+}
+
+func TestBuildLambdaCondition_UnsupportedRowFilterMatchesNothing(t *testing.T) {
+	childMeta, err := metadata.AnalyzeEntity(testCompositeChild{})
+	if err != nil {
+		t.Fatalf("Failed to analyze child entity: %v", err)
+	}
+	rowFilter := &FilterExpression{Property: "Quantity", Operator: OpCast}
+	if sql, _ := buildRowFilterCondition("sqlite", rowFilter, childMeta, ""); sql != "1 = 0" {
+		t.Fatalf("expected an unsupported row filter to match nothing, got %q", sql)
+	}
+}
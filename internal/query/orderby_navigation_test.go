@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -86,6 +87,46 @@ func TestApplyOrderByNavigationPropertySQL(t *testing.T) {
 	}
 }
 
+func TestApplyOrderByNavigationPropertySQL_RowFilterRestrictsJoin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	orderMeta, err := metadata.AnalyzeEntity(testOrderNav{})
+	if err != nil {
+		t.Fatalf("Failed to analyze order entity: %v", err)
+	}
+	customerMeta, err := metadata.AnalyzeEntity(testCustomerNav{})
+	if err != nil {
+		t.Fatalf("Failed to analyze customer entity: %v", err)
+	}
+	orderMeta.SetEntitiesRegistry(map[string]*metadata.EntityMetadata{
+		orderMeta.EntityName:    orderMeta,
+		customerMeta.EntityName: customerMeta,
+	})
+
+	ctx := WithRowFilterResolver(context.Background(), func(entity *metadata.EntityMetadata) (*FilterExpression, error) {
+		if entity.EntityName == customerMeta.EntityName {
+			return &FilterExpression{Property: "RegionID", Operator: OpEqual, Value: 7}, nil
+		}
+		return nil, nil
+	})
+
+	query := applyOrderBy(db.WithContext(ctx).Model(&testOrderNav{}), []OrderByItem{{Property: "Customer/Name"}}, orderMeta)
+	var orders []testOrderNav
+	stmt := query.Find(&orders).Statement
+	sql := stmt.SQL.String()
+
+	expectedJoin := `LEFT JOIN "customers" AS "nav_customer" ON "orders"."customer_id" = "nav_customer"."id" AND ("nav_customer"."region_id" = ?)`
+	if !strings.Contains(sql, expectedJoin) {
+		t.Fatalf("expected join clause %q in SQL: %s", expectedJoin, sql)
+	}
+	if len(stmt.Vars) != 1 || stmt.Vars[0] != 7 {
+		t.Fatalf("expected the row filter argument, got %v", stmt.Vars)
+	}
+}
+
 func TestApplyOrderByMultiHopNavigationPropertySQL(t *testing.T) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DryRun: true})
 	if err != nil {
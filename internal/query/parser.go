@@ -24,6 +24,8 @@ type QueryOptions struct {
 	Compute       *ComputeTransformation // Standalone $compute option
 	Index         bool                   // $index query option - adds @odata.index annotations
 	SchemaVersion *string                // $schemaversion query option - for metadata versioning
+	Temporal      *TemporalOptions       // $at, $from and $to query options of a temporal entity set
+	Deleted       DeletedMode            // $deleted query option of a soft delete entity set
 }
 
 // ParserConfig contains configuration options for query parsing
@@ -210,8 +212,9 @@ type FilterExpression struct {
 	Left            *FilterExpression
 	Right           *FilterExpression
 	Logical         LogicalOperator
-	IsNot           bool // Indicates if this is a NOT expression
-	maxInClauseSize int  // Maximum allowed size for IN clauses (internal use only)
+	IsNot           bool              // Indicates if this is a NOT expression
+	RowFilter       *FilterExpression // Restricts the entities a lambda operator ranges over (row-level security)
+	maxInClauseSize int               // Maximum allowed size for IN clauses (internal use only)
 }
 
 // FilterOperator represents filter comparison operators
@@ -271,6 +274,9 @@ const (
 	OpGeoDistance   FilterOperator = "geo.distance"
 	OpGeoLength     FilterOperator = "geo.length"
 	OpGeoIntersects FilterOperator = "geo.intersects"
+	// Geospatial extension functions, not defined by OData
+	OpGeoContains FilterOperator = "geo.contains"
+	OpGeoWithin   FilterOperator = "geo.within"
 	// OData v4.01 string functions
 	OpMatchesPattern FilterOperator = "matchespattern"
 )
@@ -300,6 +306,10 @@ var validQueryOptions = map[string]bool{
 	"$apply":         true,
 	"$deltatoken":    true,
 	"$skiptoken":     true,
+	"$at":            true,
+	"$from":          true,
+	"$to":            true,
+	"$deleted":       true,
 }
 
 // normalizeQueryOptionKey normalizes a query option key to lowercase with $ prefix
@@ -476,6 +486,10 @@ func ParseQueryOptionsWithConfigAndCaseSensitivity(queryParams url.Values, entit
 		return nil, err
 	}
 
+	if options.Search == "" && HasSearchScoreOrder(options.OrderBy) {
+		return nil, fmt.Errorf("invalid $orderby: %w", errSearchScoreRequiresSearch)
+	}
+
 	if err := parseComputeOption(queryParams, entityMetadata, options, config); err != nil {
 		return nil, err
 	}
@@ -488,6 +502,14 @@ func ParseQueryOptionsWithConfigAndCaseSensitivity(queryParams url.Values, entit
 		return nil, err
 	}
 
+	if err := parseTemporalOptions(queryParams, entityMetadata, options); err != nil {
+		return nil, err
+	}
+
+	if err := parseDeletedOption(queryParams, entityMetadata, options); err != nil {
+		return nil, err
+	}
+
 	// Post-process: merge navigation property selections into expand options
 	// This handles cases like $select=Product/Name with $expand=Product
 	mergeNavigationSelects(options)
@@ -896,6 +918,16 @@ func parseExpandOption(queryParams url.Values, entityMetadata *metadata.EntityMe
 // parseOrderByOption parses the $orderby query parameter
 func parseOrderByOption(queryParams url.Values, entityMetadata *metadata.EntityMetadata, options *QueryOptions, computedAliases map[string]bool) error {
 	if orderByStr := queryParams.Get("$orderby"); orderByStr != "" {
+		if strings.Contains(orderByStr, SearchScoreProperty) {
+			// $search.score is accepted at the top level only; it is resolved by
+			// the handler from the ranked search results.
+			aliases := make(map[string]bool, len(computedAliases)+1)
+			for alias := range computedAliases {
+				aliases[alias] = true
+			}
+			aliases[SearchScoreProperty] = true
+			computedAliases = aliases
+		}
 		orderBy, err := parseOrderBy(orderByStr, entityMetadata, computedAliases)
 		if err != nil {
 			return fmt.Errorf("invalid $orderby: %w", err)
@@ -0,0 +1,98 @@
+package query
+
+import (
+	"context"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"gorm.io/gorm"
+)
+
+// RowFilterResolver returns the filter restricting the rows of an entity set a
+// query may reach, or nil when all of its rows are visible.
+type RowFilterResolver func(entity *metadata.EntityMetadata) (*FilterExpression, error)
+
+type rowFilterResolverKey struct{}
+
+// WithRowFilterResolver returns a context whose queries restrict the related
+// entities reached through navigation properties: lambda operators range over
+// the rows resolver permits, and navigation joins in $filter and $orderby only
+// match permitted rows. A nil resolver lifts the restriction.
+func WithRowFilterResolver(ctx context.Context, resolver RowFilterResolver) context.Context {
+	return context.WithValue(ctx, rowFilterResolverKey{}, resolver)
+}
+
+// rowFilterResolver returns the resolver carried by the statement context of db.
+func rowFilterResolver(db *gorm.DB) RowFilterResolver {
+	if db == nil || db.Statement == nil || db.Statement.Context == nil {
+		return nil
+	}
+	resolver, _ := db.Statement.Context.Value(rowFilterResolverKey{}).(RowFilterResolver)
+	return resolver
+}
+
+// resolveRowFilter resolves the row filter of a navigation target. A resolver
+// error is recorded on db so that the query fails rather than widening access.
+func resolveRowFilter(db *gorm.DB, target *metadata.EntityMetadata) *FilterExpression {
+	resolver := rowFilterResolver(db)
+	if resolver == nil || target == nil {
+		return nil
+	}
+	filter, err := resolver(target)
+	if err != nil {
+		_ = db.AddError(err)
+		return nil
+	}
+	return filter
+}
+
+// withLambdaRowFilters returns filter with the RowFilter of every navigation
+// lambda operator resolved from the statement context of db. Nodes are copied
+// rather than modified, because filters may be shared with the policy that
+// supplied them.
+func withLambdaRowFilters(db *gorm.DB, filter *FilterExpression, entityMetadata *metadata.EntityMetadata) *FilterExpression {
+	if filter == nil || entityMetadata == nil || rowFilterResolver(db) == nil {
+		return filter
+	}
+
+	if filter.Operator == OpAny || filter.Operator == OpAll {
+		if entityMetadata.FindCollectionProperty(filter.Property) != nil {
+			return filter
+		}
+		target, err := entityMetadata.ResolveNavigationTarget(filter.Property)
+		if err != nil || target == nil {
+			return filter
+		}
+		clone := *filter
+		clone.RowFilter = resolveRowFilter(db, target)
+		clone.Left = withLambdaRowFilters(db, filter.Left, target)
+		return &clone
+	}
+
+	if filter.Left == nil && filter.Right == nil {
+		return filter
+	}
+	left := withLambdaRowFilters(db, filter.Left, entityMetadata)
+	right := withLambdaRowFilters(db, filter.Right, entityMetadata)
+	if left == filter.Left && right == filter.Right {
+		return filter
+	}
+	clone := *filter
+	clone.Left = left
+	clone.Right = right
+	return &clone
+}
+
+// buildRowFilterCondition builds the condition restricting the rows of a
+// related table to rowFilter. A row filter that cannot be expressed on the
+// related table matches no rows, so the restriction fails closed rather than
+// silently widening access.
+func buildRowFilterCondition(dialect string, rowFilter *FilterExpression, targetMetadata *metadata.EntityMetadata, tableQualifier string) (string, []interface{}) {
+	if rowFilter == nil {
+		return "", nil
+	}
+	sql, args := buildFilterConditionForLambda(dialect, rowFilter, targetMetadata, tableQualifier)
+	if sql == "" {
+		return "1 = 0", nil
+	}
+	return sql, args
+}
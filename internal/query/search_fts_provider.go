@@ -0,0 +1,175 @@
+package query
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FTSSearchProvider is the default SearchProvider. It ranks matches with the
+// database's full-text search: bm25 for SQLite FTS5, term frequency weighted by
+// term rarity (from matchinfo) for FTS3/FTS4, and ts_rank for PostgreSQL.
+type FTSSearchProvider struct {
+	manager *FTSManager
+}
+
+// NewFTSSearchProvider creates a SearchProvider backed by manager.
+func NewFTSSearchProvider(manager *FTSManager) *FTSSearchProvider {
+	return &FTSSearchProvider{manager: manager}
+}
+
+// Search implements SearchProvider. Expressions the database cannot evaluate,
+// such as NOT on SQLite, return ErrSearchNotSupported.
+func (p *FTSSearchProvider) Search(ctx context.Context, req SearchRequest) ([]SearchHit, error) {
+	if p.manager == nil || !p.manager.IsFTSAvailable() || req.metadata == nil {
+		return nil, ErrSearchNotSupported
+	}
+	expr := ParseSearchExpression(req.Query)
+	if expr == nil {
+		return nil, ErrSearchNotSupported
+	}
+	if len(req.KeyColumns) == 0 {
+		return nil, errEntityHasNoKeyProps
+	}
+
+	// Like the unranked FTS path, a table the FTS index cannot be created for is
+	// searched in memory.
+	if err := p.manager.EnsureFTSTable(req.TableName, req.metadata); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSearchNotSupported, err)
+	}
+
+	tableName := req.TableName
+	ftsTableName := p.manager.getFTSTableName(tableName)
+	if !isValidSQLIdentifier(tableName) || !isValidSQLIdentifier(ftsTableName) {
+		return nil, errInvalidSQLIdentifier
+	}
+	keyColumns := make([]string, len(req.KeyColumns))
+	for i, col := range req.KeyColumns {
+		if !isValidSQLIdentifier(col) {
+			return nil, errInvalidSQLIdentifier
+		}
+		keyColumns[i] = tableName + "." + col
+	}
+	// The FTS table is linked to the entity table through the first key column.
+	join := fmt.Sprintf("%s JOIN %s ON %s.%s = %s.%s",
+		ftsTableName, tableName, tableName, req.KeyColumns[0], ftsTableName, req.KeyColumns[0])
+
+	var (
+		sql  string
+		vars []interface{}
+	)
+	switch p.manager.ftsVersion {
+	case "POSTGRES":
+		tsQuery := fmt.Sprintf("websearch_to_tsquery('%s', ?)", p.manager.language)
+		sql = fmt.Sprintf("SELECT %s, ts_rank(%s.search_vector, %s) FROM %s WHERE %s.search_vector @@ %s ORDER BY 2 DESC",
+			strings.Join(keyColumns, ", "), ftsTableName, tsQuery, join, ftsTableName, tsQuery)
+		wsQuery := expr.toWebsearchQuery()
+		vars = []interface{}{wsQuery, wsQuery}
+	case "FTS5":
+		if expr.containsNot() {
+			return nil, ErrSearchNotSupported
+		}
+		// bm25 is lower for better matches.
+		sql = fmt.Sprintf("SELECT %s, -bm25(%s) FROM %s WHERE %s MATCH ? ORDER BY 2 DESC",
+			strings.Join(keyColumns, ", "), ftsTableName, join, ftsTableName)
+		vars = []interface{}{expr.toFTS5Query()}
+	default:
+		ftsQuery := expr.toFTS34Query()
+		if expr.containsNot() || ftsQuery == "" {
+			return nil, ErrSearchNotSupported
+		}
+		sql = fmt.Sprintf("SELECT %s, matchinfo(%s, 'pcx') FROM %s WHERE %s MATCH ?",
+			strings.Join(keyColumns, ", "), ftsTableName, join, ftsTableName)
+		vars = []interface{}{ftsQuery}
+	}
+	// FTS3/FTS4 scores are computed from matchinfo after the rows are read, so
+	// the limit is applied here only where the database ranks the rows.
+	ranked := p.manager.ftsVersion == "POSTGRES" || p.manager.ftsVersion == "FTS5"
+	if ranked && req.Limit > 0 {
+		sql += " LIMIT ?"
+		vars = append(vars, req.Limit)
+	}
+
+	db := req.DB
+	if db == nil {
+		db = p.manager.db
+	}
+	rows, err := db.WithContext(ctx).Raw(sql, vars...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck // read errors are reported by rows.Err
+
+	var hits []SearchHit
+	for rows.Next() {
+		key := make([]interface{}, len(keyColumns))
+		var score interface{}
+		dest := make([]interface{}, len(keyColumns)+1)
+		for i := range key {
+			dest[i] = &key[i]
+		}
+		dest[len(key)] = &score
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, v := range key {
+			if b, ok := v.([]byte); ok {
+				key[i] = string(b)
+			}
+		}
+		hits = append(hits, SearchHit{Key: key, Score: ftsScore(score)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !ranked {
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+		if req.Limit > 0 && len(hits) > req.Limit {
+			hits = hits[:req.Limit]
+		}
+	}
+	return hits, nil
+}
+
+// ftsScore converts the score column of a ranked FTS query to a float. A
+// matchinfo 'pcx' blob is scored as the sum, over every phrase and column, of
+// the phrase's hits in the row divided by the number of rows containing it.
+func ftsScore(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case []byte:
+		return matchinfoScore(v)
+	}
+	return 0
+}
+
+func matchinfoScore(info []byte) float64 {
+	if len(info) < 8 {
+		return 0
+	}
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	phrases, columns := int(values[0]), int(values[1])
+	hitInfo := values[2:]
+	if len(hitInfo) < 3*phrases*columns {
+		return 0
+	}
+	var score float64
+	for i := 0; i < phrases*columns; i++ {
+		hitsInRow, rowsWithHits := hitInfo[3*i], hitInfo[3*i+2]
+		if hitsInRow > 0 && rowsWithHits > 0 {
+			score += float64(hitsInRow) / float64(rowsWithHits)
+		}
+	}
+	return score
+}
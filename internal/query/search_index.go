@@ -0,0 +1,484 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BM25 parameters used by SearchIndex.
+const (
+	searchIndexK1 = 1.2
+	searchIndexB  = 0.75
+)
+
+// SearchIndex is an in-process inverted index implementing SearchProvider for
+// databases without full-text search. On first use for an entity set it reads
+// the key and searchable columns of the table once and tokenizes them into
+// lowercase words; later searches are answered from memory and ranked with
+// BM25. Terms match whole words, and quoted phrases match consecutive words.
+//
+// Writes through the service update the documents of the written entities,
+// which are read again from the table. The index only sees the writes of its
+// own process; other replicas have to call InvalidateSearch when they learn
+// about a write, for example from a cache invalidator.
+type SearchIndex struct {
+	mu          sync.Mutex
+	sets        map[string]*searchIndexSet
+	generations map[string]uint64
+}
+
+// NewSearchIndex creates an empty SearchIndex.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		sets:        make(map[string]*searchIndexSet),
+		generations: make(map[string]uint64),
+	}
+}
+
+// searchIndexSet is the index of one entity set. Documents are numbered in the
+// order they were added; a removed document keeps its number with a nil key.
+type searchIndexSet struct {
+	// updateMu serializes UpdateSearch calls, so that the rows read last are
+	// applied last.
+	updateMu sync.Mutex
+
+	mu          sync.RWMutex
+	keys        [][]interface{}
+	tokens      [][]string
+	lengths     []int
+	docs        map[string]int
+	live        int
+	totalLength int
+	postings    map[string][]searchPosting
+}
+
+// searchPosting lists the word positions of a token in one document. Postings
+// of a token are ordered by document.
+type searchPosting struct {
+	doc       int
+	positions []int
+}
+
+// Search implements SearchProvider.
+func (idx *SearchIndex) Search(ctx context.Context, req SearchRequest) ([]SearchHit, error) {
+	expr := ParseSearchExpression(req.Query)
+	if expr == nil {
+		return nil, ErrSearchNotSupported
+	}
+	set, err := idx.set(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	scores := set.evaluate(expr)
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	// Best matches first; equal scores keep table order.
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i]] != scores[docs[j]] {
+			return scores[docs[i]] > scores[docs[j]]
+		}
+		return docs[i] < docs[j]
+	})
+	if req.Limit > 0 && len(docs) > req.Limit {
+		docs = docs[:req.Limit]
+	}
+	hits := make([]SearchHit, len(docs))
+	for i, doc := range docs {
+		hits[i] = SearchHit{Key: set.keys[doc], Score: scores[doc]}
+	}
+	return hits, nil
+}
+
+// InvalidateSearch implements SearchInvalidator. The entity set's index is
+// rebuilt on the next search.
+func (idx *SearchIndex) InvalidateSearch(entitySet string) {
+	idx.mu.Lock()
+	delete(idx.sets, entitySet)
+	idx.generations[entitySet]++
+	idx.mu.Unlock()
+}
+
+// UpdateSearch implements SearchUpdater. It reads the rows with the given keys
+// again and replaces their documents; keys without a row are removed. An index
+// that has not been built yet is left to the next search. When reading fails,
+// the index is invalidated.
+func (idx *SearchIndex) UpdateSearch(ctx context.Context, req SearchRequest, keys [][]interface{}) error {
+	idx.mu.Lock()
+	set := idx.sets[req.EntitySet]
+	// An index being built may have read the rows before the write.
+	idx.generations[req.EntitySet]++
+	idx.mu.Unlock()
+	if set == nil || len(keys) == 0 {
+		return nil
+	}
+
+	set.updateMu.Lock()
+	defer set.updateMu.Unlock()
+	type row struct {
+		key    []interface{}
+		values []interface{}
+	}
+	var rows []row
+	err := readSearchRows(ctx, req, keys, func(key, values []interface{}) {
+		rows = append(rows, row{key: key, values: append([]interface{}(nil), values...)})
+	})
+	if err != nil {
+		idx.InvalidateSearch(req.EntitySet)
+		return err
+	}
+
+	set.mu.Lock()
+	for _, key := range keys {
+		if doc, ok := set.docs[searchDocKey(key)]; ok {
+			set.remove(doc)
+		}
+	}
+	for _, row := range rows {
+		if doc, ok := set.docs[searchDocKey(row.key)]; ok {
+			set.remove(doc)
+		}
+		set.add(row.key, row.values)
+	}
+	// Removed documents still take space; rebuild once they outnumber the others.
+	compact := len(set.keys)-set.live > set.live
+	set.mu.Unlock()
+
+	if compact {
+		idx.mu.Lock()
+		if idx.sets[req.EntitySet] == set {
+			delete(idx.sets, req.EntitySet)
+		}
+		idx.mu.Unlock()
+	}
+	return nil
+}
+
+// set returns the index of req's entity set, building it when needed. An index
+// built while the entity set was invalidated is used for this search only.
+func (idx *SearchIndex) set(ctx context.Context, req SearchRequest) (*searchIndexSet, error) {
+	idx.mu.Lock()
+	set := idx.sets[req.EntitySet]
+	generation := idx.generations[req.EntitySet]
+	idx.mu.Unlock()
+	if set != nil {
+		return set, nil
+	}
+
+	set, err := buildSearchIndexSet(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	if idx.generations[req.EntitySet] == generation {
+		idx.sets[req.EntitySet] = set
+	}
+	idx.mu.Unlock()
+	return set, nil
+}
+
+// buildSearchIndexSet reads the key and searchable columns of req's table and
+// indexes every row.
+func buildSearchIndexSet(ctx context.Context, req SearchRequest) (*searchIndexSet, error) {
+	set := newSearchIndexSet()
+	if err := readSearchRows(ctx, req, nil, set.add); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// searchKeyBatch bounds the keys read by one statement of UpdateSearch.
+const searchKeyBatch = 500
+
+// readSearchRows reads the key and searchable columns of req's table, limited
+// to keys unless it is nil, and calls fn with the key and searchable values of
+// every row. The values slice is reused between rows.
+func readSearchRows(ctx context.Context, req SearchRequest, keys [][]interface{}, fn func(key, values []interface{})) error {
+	if req.DB == nil {
+		return fmt.Errorf("search index for %s: no database", req.EntitySet)
+	}
+	if len(req.KeyColumns) == 0 {
+		return errEntityHasNoKeyProps
+	}
+	if keys == nil {
+		return scanSearchRows(req.DB.WithContext(ctx).Table(req.TableName), req, fn)
+	}
+	for start := 0; start < len(keys); start += searchKeyBatch {
+		batch := keys[start:min(start+searchKeyBatch, len(keys))]
+		db := req.DB.WithContext(ctx).Table(req.TableName).Where(searchKeyCondition(req.KeyColumns, batch))
+		if err := scanSearchRows(db, req, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchKeyCondition matches the rows with one of keys.
+func searchKeyCondition(columns []string, keys [][]interface{}) clause.Expression {
+	if len(columns) == 1 {
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = key[0]
+		}
+		return clause.IN{Column: clause.Column{Name: columns[0]}, Values: values}
+	}
+	matches := make([]clause.Expression, 0, len(keys))
+	for _, key := range keys {
+		conds := make([]clause.Expression, len(columns))
+		for i, column := range columns {
+			conds[i] = clause.Eq{Column: clause.Column{Name: column}, Value: key[i]}
+		}
+		matches = append(matches, clause.And(conds...))
+	}
+	return clause.Or(matches...)
+}
+
+func scanSearchRows(db *gorm.DB, req SearchRequest, fn func(key, values []interface{})) error {
+	columns := append(append([]string{}, req.KeyColumns...), req.SearchColumns...)
+	rows, err := db.Select(columns).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close() //nolint:errcheck // read errors are reported by rows.Err
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		key := make([]interface{}, len(req.KeyColumns))
+		for i := range key {
+			key[i] = values[i]
+			if b, ok := key[i].([]byte); ok {
+				key[i] = string(b)
+			}
+		}
+		fn(key, values[len(req.KeyColumns):])
+	}
+	return rows.Err()
+}
+
+func newSearchIndexSet() *searchIndexSet {
+	return &searchIndexSet{
+		docs:     make(map[string]int),
+		postings: make(map[string][]searchPosting),
+	}
+}
+
+// searchDocKey identifies a document by its key values, so that keys read from
+// the table and keys of written entities agree although their Go types differ.
+func searchDocKey(key []interface{}) string {
+	parts := make([]string, len(key))
+	for i, v := range key {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// add indexes a row as a new document after all existing ones, which keeps
+// the postings ordered by document.
+func (s *searchIndexSet) add(key []interface{}, values []interface{}) {
+	doc := len(s.keys)
+	s.keys = append(s.keys, key)
+	s.docs[searchDocKey(key)] = doc
+
+	var tokens []string
+	position := 0
+	for _, value := range values {
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case []byte:
+			text = string(v)
+		case nil:
+			continue
+		default:
+			text = fmt.Sprint(v)
+		}
+		for _, token := range searchTokens(text) {
+			postings := s.postings[token]
+			if n := len(postings); n > 0 && postings[n-1].doc == doc {
+				postings[n-1].positions = append(postings[n-1].positions, position)
+			} else {
+				s.postings[token] = append(postings, searchPosting{doc: doc, positions: []int{position}})
+				tokens = append(tokens, token)
+			}
+			position++
+		}
+		// Leave a gap so that phrases do not match across columns.
+		position++
+	}
+	s.tokens = append(s.tokens, tokens)
+	s.lengths = append(s.lengths, position)
+	s.totalLength += position
+	s.live++
+}
+
+// remove drops a document from the postings of its tokens.
+func (s *searchIndexSet) remove(doc int) {
+	if s.keys[doc] == nil {
+		return
+	}
+	for _, token := range s.tokens[doc] {
+		postings := s.postings[token]
+		i := sort.Search(len(postings), func(i int) bool { return postings[i].doc >= doc })
+		if i < len(postings) && postings[i].doc == doc {
+			postings = append(postings[:i], postings[i+1:]...)
+		}
+		if len(postings) == 0 {
+			delete(s.postings, token)
+		} else {
+			s.postings[token] = postings
+		}
+	}
+	delete(s.docs, searchDocKey(s.keys[doc]))
+	s.totalLength -= s.lengths[doc]
+	s.keys[doc], s.tokens[doc], s.lengths[doc] = nil, nil, 0
+	s.live--
+}
+
+// searchTokens splits text into lowercase words.
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// evaluate returns the score of every document matching node.
+func (s *searchIndexSet) evaluate(node *SearchExprNode) map[int]float64 {
+	if node == nil {
+		return nil
+	}
+	switch node.op {
+	case searchOpAnd:
+		left := s.evaluate(node.left)
+		if len(left) == 0 {
+			return nil
+		}
+		right := s.evaluate(node.right)
+		result := make(map[int]float64)
+		for doc, score := range left {
+			if other, ok := right[doc]; ok {
+				result[doc] = score + other
+			}
+		}
+		return result
+	case searchOpOr:
+		result := s.evaluate(node.left)
+		if result == nil {
+			result = make(map[int]float64)
+		}
+		for doc, score := range s.evaluate(node.right) {
+			result[doc] += score
+		}
+		return result
+	case searchOpNot:
+		excluded := s.evaluate(node.left)
+		result := make(map[int]float64)
+		for doc, key := range s.keys {
+			if _, ok := excluded[doc]; !ok && key != nil {
+				result[doc] = 0
+			}
+		}
+		return result
+	case searchOpTerm, searchOpPhrase:
+		return s.phrase(searchTokens(node.term))
+	}
+	return nil
+}
+
+// phrase scores the documents containing tokens as consecutive words. A term
+// that tokenizes into several words, such as "e-mail", is matched as a phrase.
+func (s *searchIndexSet) phrase(tokens []string) map[int]float64 {
+	if len(tokens) == 0 {
+		return nil
+	}
+	lists := make([][]searchPosting, len(tokens))
+	for i, token := range tokens {
+		lists[i] = s.postings[token]
+		if len(lists[i]) == 0 {
+			return nil
+		}
+	}
+
+	result := make(map[int]float64)
+	for _, first := range lists[0] {
+		matches := make([]*searchPosting, len(tokens))
+		matches[0] = &first
+		found := true
+		for i := 1; i < len(tokens) && found; i++ {
+			matches[i] = findPosting(lists[i], first.doc)
+			found = matches[i] != nil
+		}
+		if !found || !consecutive(matches) {
+			continue
+		}
+		var score float64
+		for i, m := range matches {
+			score += s.bm25(len(lists[i]), len(m.positions), first.doc)
+		}
+		result[first.doc] = score
+	}
+	return result
+}
+
+// bm25 is the BM25 weight of a token found in df documents that occurs tf
+// times in doc.
+func (s *searchIndexSet) bm25(df, tf, doc int) float64 {
+	n := float64(s.live)
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	norm := 1.0
+	if s.totalLength > 0 {
+		avgLength := float64(s.totalLength) / n
+		norm = 1 - searchIndexB + searchIndexB*float64(s.lengths[doc])/avgLength
+	}
+	return idf * float64(tf) * (searchIndexK1 + 1) / (float64(tf) + searchIndexK1*norm)
+}
+
+func findPosting(postings []searchPosting, doc int) *searchPosting {
+	i := sort.Search(len(postings), func(i int) bool { return postings[i].doc >= doc })
+	if i < len(postings) && postings[i].doc == doc {
+		return &postings[i]
+	}
+	return nil
+}
+
+// consecutive reports whether the postings have positions p, p+1, p+2, ...
+func consecutive(postings []*searchPosting) bool {
+	if len(postings) == 1 {
+		return true
+	}
+	for _, start := range postings[0].positions {
+		ok := true
+		for i := 1; i < len(postings) && ok; i++ {
+			want := start + i
+			j := sort.SearchInts(postings[i].positions, want)
+			ok = j < len(postings[i].positions) && postings[i].positions[j] == want
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
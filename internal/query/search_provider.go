@@ -0,0 +1,106 @@
+package query
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"gorm.io/gorm"
+)
+
+// SearchScoreProperty is the $orderby pseudo-property that sorts the results of
+// a ranked $search by relevance, e.g. $orderby=$search.score desc.
+const SearchScoreProperty = "$search.score"
+
+// ErrSearchNotSupported is returned by a SearchProvider that cannot evaluate a
+// search expression. The service then evaluates $search in memory instead.
+var ErrSearchNotSupported = errors.New("search expression is not supported by the search provider")
+
+// errSearchScoreRequiresSearch is returned when $orderby references
+// $search.score without a $search.
+var errSearchScoreRequiresSearch = errors.New("$search.score can only be used in $orderby together with $search")
+
+// SearchHit is an entity matched by a SearchProvider.
+type SearchHit struct {
+	// Key holds the entity's key values in the order of SearchRequest.KeyColumns.
+	Key []interface{}
+	// Score is the relevance of the match; higher scores are better matches.
+	Score float64
+}
+
+// SearchRequest describes a $search to be answered by a SearchProvider.
+type SearchRequest struct {
+	// EntitySet is the name of the searched entity set.
+	EntitySet string
+	// Query is the $search expression as sent by the client.
+	Query string
+	// TableName is the database table backing the entity set.
+	TableName string
+	// KeyColumns are the key columns of the table.
+	KeyColumns []string
+	// SearchColumns are the columns of the searchable properties.
+	SearchColumns []string
+	// Limit is the maximum number of hits to return. Providers keep the best
+	// scoring matches. Zero means no limit.
+	Limit int
+	// DB is a session on the service database bound to the request context.
+	DB *gorm.DB
+
+	metadata *metadata.EntityMetadata
+}
+
+// NewSearchRequest builds the SearchRequest for searching entityMetadata's entity
+// set with searchQuery.
+func NewSearchRequest(db *gorm.DB, entityMetadata *metadata.EntityMetadata, searchQuery string, limit int) SearchRequest {
+	req := SearchRequest{
+		EntitySet: entityMetadata.EntitySetName,
+		Query:     searchQuery,
+		TableName: entityMetadata.TableName,
+		Limit:     limit,
+		DB:        db,
+		metadata:  entityMetadata,
+	}
+	for _, kp := range entityMetadata.KeyProperties {
+		req.KeyColumns = append(req.KeyColumns, kp.ColumnName)
+	}
+	for _, prop := range SearchableProperties(entityMetadata) {
+		req.SearchColumns = append(req.SearchColumns, prop.ColumnName)
+	}
+	return req
+}
+
+// SearchProvider answers $search requests with ranked matches. The service
+// restricts the collection to the returned keys, annotates each entity with its
+// score as @search.score and uses the scores for $orderby=$search.score.
+type SearchProvider interface {
+	// Search returns the entities matching req.Query. Hits may be returned in
+	// any order. Returning ErrSearchNotSupported makes the service evaluate the
+	// expression in memory instead.
+	Search(ctx context.Context, req SearchRequest) ([]SearchHit, error)
+}
+
+// SearchInvalidator is implemented by search providers that keep their own
+// copy of the searchable data. InvalidateSearch is called after every write
+// to an entity set the provider answers for.
+type SearchInvalidator interface {
+	InvalidateSearch(entitySet string)
+}
+
+// SearchUpdater is implemented by search providers that can refresh their copy
+// of the searchable data for single entities. After a write, UpdateSearch is
+// called with the keys of the written entities, in the order of
+// req.KeyColumns, instead of InvalidateSearch. A provider that fails to update
+// should discard its copy before returning the error.
+type SearchUpdater interface {
+	UpdateSearch(ctx context.Context, req SearchRequest, keys [][]interface{}) error
+}
+
+// HasSearchScoreOrder reports whether orderBy sorts by $search.score.
+func HasSearchScoreOrder(orderBy []OrderByItem) bool {
+	for _, item := range orderBy {
+		if item.Property == SearchScoreProperty {
+			return true
+		}
+	}
+	return false
+}
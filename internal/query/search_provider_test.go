@@ -0,0 +1,244 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupSearchProviderDB(t *testing.T) (*gorm.DB, *metadata.EntityMetadata) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&FTSTestEntity{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	entities := []FTSTestEntity{
+		{ID: 1, Name: "Laptop", Description: "Portable computer", Category: "Electronics"},
+		{ID: 2, Name: "Laptop Stand", Description: "Stand for a laptop, fits every laptop", Category: "Accessories"},
+		{ID: 3, Name: "Gaming Mouse", Description: "Mouse for gaming laptops", Category: "Electronics"},
+		{ID: 4, Name: "Desk", Description: "Wooden office desk", Category: "Furniture"},
+	}
+	if err := db.Create(&entities).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	meta, err := metadata.AnalyzeEntity(FTSTestEntity{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	return db, meta
+}
+
+func hitIDs(hits []SearchHit) []int64 {
+	ids := make([]int64, len(hits))
+	for i, hit := range hits {
+		ids[i], _ = hit.Key[0].(int64)
+	}
+	return ids
+}
+
+func TestSearchIndex_RanksByRelevance(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	idx := NewSearchIndex()
+
+	hits, err := idx.Search(context.Background(), NewSearchRequest(db, meta, "laptop", 0))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	ids := hitIDs(hits)
+	// "laptops" is a different word, so the mouse does not match.
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Fatalf("expected hits [2 1], got %v", ids)
+	}
+	if hits[0].Score <= hits[1].Score || hits[1].Score <= 0 {
+		t.Errorf("expected descending positive scores, got %v and %v", hits[0].Score, hits[1].Score)
+	}
+}
+
+func TestSearchIndex_Expressions(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	idx := NewSearchIndex()
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{query: `"laptop stand"`, want: []int64{2}},
+		{query: `"stand laptop"`, want: nil},
+		{query: "laptop AND portable", want: []int64{1}},
+		{query: "desk OR mouse", want: []int64{3, 4}},
+		{query: "NOT laptop", want: []int64{3, 4}},
+		{query: "LAPTOP", want: []int64{2, 1}},
+		{query: "electronics", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			hits, err := idx.Search(context.Background(), NewSearchRequest(db, meta, tt.query, 0))
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			ids := hitIDs(hits)
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected hits %v, got %v", tt.want, ids)
+			}
+			seen := make(map[int64]bool)
+			for _, id := range ids {
+				seen[id] = true
+			}
+			for _, id := range tt.want {
+				if !seen[id] {
+					t.Errorf("expected hits %v, got %v", tt.want, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchIndex_LimitAndInvalidation(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	idx := NewSearchIndex()
+
+	hits, err := idx.Search(context.Background(), NewSearchRequest(db, meta, "laptop", 1))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if ids := hitIDs(hits); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("expected the best hit only, got %v", ids)
+	}
+
+	if err := db.Create(&FTSTestEntity{ID: 5, Name: "Laptop Bag", Description: "Bag", Category: "Accessories"}).Error; err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	hits, _ = idx.Search(context.Background(), NewSearchRequest(db, meta, "bag", 0))
+	if len(hits) != 0 {
+		t.Fatalf("expected the index to be unchanged before invalidation, got %v", hitIDs(hits))
+	}
+
+	idx.InvalidateSearch(meta.EntitySetName)
+	hits, err = idx.Search(context.Background(), NewSearchRequest(db, meta, "bag", 0))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if ids := hitIDs(hits); len(ids) != 1 || ids[0] != 5 {
+		t.Fatalf("expected the new entity after invalidation, got %v", ids)
+	}
+}
+
+func TestSearchIndex_UpdateSearch(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	idx := NewSearchIndex()
+	ctx := context.Background()
+
+	if _, err := idx.Search(ctx, NewSearchRequest(db, meta, "laptop", 0)); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+
+	if err := db.Create(&FTSTestEntity{ID: 5, Name: "Laptop Bag", Description: "Bag", Category: "Accessories"}).Error; err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if err := db.Model(&FTSTestEntity{ID: 2}).Update("Name", "Monitor Stand").Error; err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := db.Model(&FTSTestEntity{ID: 2}).Update("Description", "Stand for a monitor").Error; err != nil {
+		t.Fatalf("Failed to update: %v", err)
+	}
+	if err := db.Delete(&FTSTestEntity{ID: 1}).Error; err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	keys := [][]interface{}{{uint(5)}, {uint(2)}, {uint(1)}}
+	if err := idx.UpdateSearch(ctx, NewSearchRequest(db, meta, "", 0), keys); err != nil {
+		t.Fatalf("UpdateSearch failed: %v", err)
+	}
+
+	cases := map[string][]int64{
+		"laptop":         {5},
+		"monitor":        {2},
+		"bag":            {5},
+		"portable":       nil,
+		"\"laptop bag\"": {5},
+		"NOT laptop":     {2, 3, 4},
+	}
+	for search, want := range cases {
+		hits, err := idx.Search(ctx, NewSearchRequest(db, meta, search, 0))
+		if err != nil {
+			t.Fatalf("Search(%q) failed: %v", search, err)
+		}
+		ids := hitIDs(hits)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		if fmt.Sprint(ids) != fmt.Sprint(want) && !(len(ids) == 0 && len(want) == 0) {
+			t.Errorf("Search(%q) = %v, want %v", search, ids, want)
+		}
+		for _, hit := range hits {
+			if search != "NOT laptop" && hit.Score <= 0 {
+				t.Errorf("Search(%q) scored %v for %v", search, hit.Score, hit.Key)
+			}
+		}
+	}
+}
+
+func TestFTSSearchProvider_RanksMatches(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	manager := NewFTSManager(db)
+	if !manager.IsFTSAvailable() {
+		t.Skip("FTS not available, skipping test")
+	}
+	provider := NewFTSSearchProvider(manager)
+
+	hits, err := provider.Search(context.Background(), NewSearchRequest(db, meta, "laptop", 0))
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(hits) == 0 {
+		t.Fatal("expected hits")
+	}
+	if ids := hitIDs(hits); ids[0] != 2 {
+		t.Errorf("expected entity 2 to rank first, got %v", ids)
+	}
+	for i := 1; i < len(hits); i++ {
+		if hits[i].Score > hits[i-1].Score {
+			t.Errorf("hits are not ordered by score: %v", hits)
+		}
+	}
+
+	if manager.GetFTSVersion() != "POSTGRES" {
+		if _, err := provider.Search(context.Background(), NewSearchRequest(db, meta, "NOT laptop", 0)); !errors.Is(err, ErrSearchNotSupported) {
+			t.Errorf("expected ErrSearchNotSupported for NOT on SQLite, got %v", err)
+		}
+	}
+}
+
+func TestFTSSearchProvider_NotAvailable(t *testing.T) {
+	db, meta := setupSearchProviderDB(t)
+	provider := NewFTSSearchProvider(nil)
+	if _, err := provider.Search(context.Background(), NewSearchRequest(db, meta, "laptop", 0)); !errors.Is(err, ErrSearchNotSupported) {
+		t.Errorf("expected ErrSearchNotSupported, got %v", err)
+	}
+}
+
+func TestParseQueryOptions_SearchScoreOrder(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(FTSTestEntity{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+
+	options, err := ParseQueryOptions(url.Values{"$search": {"laptop"}, "$orderby": {"$search.score desc,Name"}}, meta)
+	if err != nil {
+		t.Fatalf("ParseQueryOptions failed: %v", err)
+	}
+	if !HasSearchScoreOrder(options.OrderBy) || !options.OrderBy[0].Descending {
+		t.Errorf("expected $search.score desc, got %+v", options.OrderBy)
+	}
+
+	if _, err := ParseQueryOptions(url.Values{"$orderby": {"$search.score desc"}}, meta); err == nil {
+		t.Error("expected an error for $search.score without $search")
+	}
+}
@@ -0,0 +1,41 @@
+package query
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+// DeletedMode selects how a read of a soft delete entity set treats entities
+// that have been deleted. The zero value excludes them.
+type DeletedMode string
+
+// DeletedMode values accepted by the $deleted query option.
+const (
+	DeletedExclude DeletedMode = ""
+	DeletedInclude DeletedMode = "include"
+	DeletedOnly    DeletedMode = "only"
+)
+
+var (
+	errDeletedInvalidValue = errors.New("invalid $deleted: must be 'include' or 'only'")
+	errDeletedNotSupported = errors.New("entity set does not support the $deleted query option")
+)
+
+// parseDeletedOption parses the $deleted query parameter.
+func parseDeletedOption(queryParams url.Values, entityMetadata *metadata.EntityMetadata, options *QueryOptions) error {
+	if _, exists := queryParams["$deleted"]; !exists {
+		return nil
+	}
+	mode := DeletedMode(strings.ToLower(strings.TrimSpace(queryParams.Get("$deleted"))))
+	if mode != DeletedInclude && mode != DeletedOnly {
+		return errDeletedInvalidValue
+	}
+	if entityMetadata != nil && entityMetadata.SoftDeleteProperty == nil {
+		return errDeletedNotSupported
+	}
+	options.Deleted = mode
+	return nil
+}
@@ -0,0 +1,63 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+type softDeleteTestProduct struct {
+	ID        int        `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name      string     `json:"Name"`
+	DeletedAt *time.Time `json:"DeletedAt" odata:"softdelete"`
+}
+
+func TestParseDeletedOption(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&softDeleteTestProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	plain, err := metadata.AnalyzeEntity(&temporalTestProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		meta    *metadata.EntityMetadata
+		want    DeletedMode
+		wantErr bool
+	}{
+		{name: "absent", query: "", meta: meta, want: DeletedExclude},
+		{name: "include", query: "$deleted=include", meta: meta, want: DeletedInclude},
+		{name: "only", query: "$deleted=Only", meta: meta, want: DeletedOnly},
+		{name: "without prefix", query: "deleted=only", meta: meta, want: DeletedOnly},
+		{name: "invalid value", query: "$deleted=all", meta: meta, wantErr: true},
+		{name: "empty value", query: "$deleted=", meta: meta, wantErr: true},
+		{name: "not soft delete", query: "$deleted=only", meta: plain, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			options, err := ParseQueryOptions(params, tt.meta)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQueryOptions() error: %v", err)
+			}
+			if options.Deleted != tt.want {
+				t.Errorf("expected Deleted %q, got %q", tt.want, options.Deleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+// TemporalOptions holds the $at, $from and $to query options of a request to a
+// temporal entity set. $at selects the state valid at a point in time; $from
+// and $to select every version valid at some point of the half-open period
+// [From, To). A nil bound is unbounded.
+type TemporalOptions struct {
+	At   *time.Time
+	From *time.Time
+	To   *time.Time
+}
+
+// IsPeriod reports whether the options select versions over a period rather
+// than the state at a single point in time.
+func (t *TemporalOptions) IsPeriod() bool {
+	return t != nil && t.At == nil
+}
+
+var (
+	errTemporalAtWithPeriod  = errors.New("invalid $at: must not be combined with $from or $to")
+	errTemporalEmptyPeriod   = errors.New("invalid $to: must be later than $from")
+	errTemporalNotSupported  = errors.New("entity set does not support temporal query options")
+	errTemporalInvalidFormat = "invalid %s: must be an Edm.DateTimeOffset or Edm.Date value"
+)
+
+// parseTemporalOptions parses the $at, $from and $to query parameters.
+func parseTemporalOptions(queryParams url.Values, entityMetadata *metadata.EntityMetadata, options *QueryOptions) error {
+	temporal := &TemporalOptions{}
+	found := false
+	for _, option := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"$at", &temporal.At},
+		{"$from", &temporal.From},
+		{"$to", &temporal.To},
+	} {
+		if _, exists := queryParams[option.name]; !exists {
+			continue
+		}
+		value, err := parseTemporalValue(queryParams.Get(option.name))
+		if err != nil {
+			return fmt.Errorf(errTemporalInvalidFormat, option.name)
+		}
+		*option.target = &value
+		found = true
+	}
+	if !found {
+		return nil
+	}
+
+	if entityMetadata != nil && entityMetadata.TemporalHistoryTable == "" {
+		return errTemporalNotSupported
+	}
+	if temporal.At != nil && (temporal.From != nil || temporal.To != nil) {
+		return errTemporalAtWithPeriod
+	}
+	if temporal.From != nil && temporal.To != nil && !temporal.To.After(*temporal.From) {
+		return errTemporalEmptyPeriod
+	}
+	options.Temporal = temporal
+	return nil
+}
+
+// parseTemporalValue parses a DateTimeOffset or a Date, which denotes the start
+// of that day in UTC. An unencoded '+' of a time zone offset arrives decoded as
+// a space and is restored.
+func parseTemporalValue(value string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), " ", "+")
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+type temporalTestProduct struct {
+	ID   int    `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name string `json:"Name"`
+}
+
+func TestParseTemporalOptions(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&temporalTestProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	meta.TemporalHistoryTable = "temporal_test_products_history"
+
+	may14 := time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		query    string
+		wantAt   *time.Time
+		wantFrom *time.Time
+		wantTo   *time.Time
+		period   bool
+		wantErr  bool
+	}{
+		{name: "none", query: ""},
+		{name: "at date", query: "$at=2024-05-14", wantAt: &may14},
+		{name: "at offset", query: "$at=2024-05-14T02:00:00%2B02:00", wantAt: &may14},
+		{name: "at unencoded offset", query: "$at=2024-05-14T02:00:00+02:00", wantAt: &may14},
+		{name: "from", query: "$from=2024-05-14T00:00:00Z", wantFrom: &may14, period: true},
+		{name: "to", query: "$to=2024-05-14", wantTo: &may14, period: true},
+		{name: "at with from", query: "$at=2024-05-14&$from=2024-05-13", wantErr: true},
+		{name: "empty period", query: "$from=2024-05-14&$to=2024-05-14", wantErr: true},
+		{name: "invalid value", query: "$at=last-tuesday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			options, err := ParseQueryOptions(params, meta)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQueryOptions() error: %v", err)
+			}
+			if tt.query == "" {
+				if options.Temporal != nil {
+					t.Errorf("expected no temporal options, got %+v", options.Temporal)
+				}
+				return
+			}
+			assertTemporalTime(t, "At", options.Temporal.At, tt.wantAt)
+			assertTemporalTime(t, "From", options.Temporal.From, tt.wantFrom)
+			assertTemporalTime(t, "To", options.Temporal.To, tt.wantTo)
+			if options.Temporal.IsPeriod() != tt.period {
+				t.Errorf("expected IsPeriod %v", tt.period)
+			}
+		})
+	}
+}
+
+func TestParseTemporalOptions_NonTemporalEntitySet(t *testing.T) {
+	meta, err := metadata.AnalyzeEntity(&temporalTestProduct{})
+	if err != nil {
+		t.Fatalf("Failed to analyze entity: %v", err)
+	}
+	if _, err := ParseQueryOptions(url.Values{"$at": {"2024-05-14"}}, meta); err == nil {
+		t.Fatal("expected an error for an entity set without history")
+	}
+}
+
+func assertTemporalTime(t *testing.T, name string, got, want *time.Time) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s: expected %v, got %v", name, want, got)
+	case !got.Equal(*want):
+		t.Errorf("%s: expected %v, got %v", name, *want, *got)
+	}
+}
@@ -50,6 +50,10 @@ func WriteODataCollectionWithNavigationAndSelect(w http.ResponseWriter, r *http.
 }
 
 func writeODataCollectionResponse(w http.ResponseWriter, r *http.Request, entitySetName string, data interface{}, count *int64, nextLink, deltaLink *string, selectedProps []string) error {
+	if IsTabularFormat(r) {
+		return WriteTabularCollection(w, r, data, nextLink, selectedProps)
+	}
+
 	if IsAtomFormat(r) {
 		return WriteAtomCollection(w, r, entitySetName, data, count, nextLink, deltaLink, nil)
 	}
@@ -106,6 +110,10 @@ func writeODataCollectionResponse(w http.ResponseWriter, r *http.Request, entity
 }
 
 func writeODataCollectionWithNavigationResponse(w http.ResponseWriter, r *http.Request, entitySetName string, data interface{}, count *int64, nextLink, deltaLink *string, metadata EntityMetadataProvider, expandOptions []query.ExpandOption, selectedNavProps []string, fullMetadata *metadata.EntityMetadata, selectedProps []string, skip int) error {
+	if IsTabularFormat(r) {
+		return writeTabularEntityCollection(w, r, entitySetName, data, nextLink, metadata, expandOptions, selectedNavProps, fullMetadata, selectedProps)
+	}
+
 	if !IsAcceptableFormat(r) {
 		return WriteError(w, r, http.StatusNotAcceptable, "Not Acceptable",
 			"The requested format is not supported. Only application/json and application/atom+xml are supported for data responses.")
@@ -153,6 +161,8 @@ func writeODataCollectionWithNavigationResponse(w http.ResponseWriter, r *http.R
 				annotationFilter: annotationFilter,
 				selectedSet:      buildSelectedSet(selectedProps),
 				keySet:           buildKeySet(metadata),
+				searchScore:      searchScoresFor(r, annotationFilter),
+				temporalPeriod:   temporalPeriodsFor(r, annotationFilter),
 			}
 			return writeFastCollectionToResponse(w, r, fastSlice, ctx, contextURL, count, nextLink, deltaLink)
 		}
@@ -163,6 +173,13 @@ func writeODataCollectionWithNavigationResponse(w http.ResponseWriter, r *http.R
 		transformedData = []interface{}{}
 	}
 
+	if score := searchScoresFor(r, preference.ParsePrefer(r).IncludeAnnotations); score != nil {
+		addSearchScoreAnnotations(transformedData, data, score)
+	}
+	if period := temporalPeriodsFor(r, preference.ParsePrefer(r).IncludeAnnotations); period != nil {
+		addTemporalPeriodAnnotations(transformedData, data, period)
+	}
+
 	// Honor Prefer: omit-values=nulls by removing null-valued properties from each item.
 	if pref := preference.ParsePrefer(r); pref.OmitValues != nil {
 		pref.ApplyOmitValues(true)
@@ -54,6 +54,11 @@ type fastEntityContext struct {
 	// keySet holds the Go names and JSON names of key properties, which are always
 	// emitted even under $select (mirroring query.ApplySelect).
 	keySet map[string]struct{}
+	// searchScore, when non-nil, supplies the @search.score of ranked $search results.
+	searchScore SearchScoreFunc
+	// temporalPeriod, when non-nil, supplies the validity period of entity versions
+	// returned by a $from/$to query.
+	temporalPeriod TemporalPeriodFunc
 }
 
 // canFastWriteCollection reports whether data is a slice of structs the direct
@@ -291,6 +296,24 @@ func writeFastEntity(buf *bytes.Buffer, entity reflect.Value, ctx *fastEntityCon
 		}
 	}
 
+	// @search.score — results of a ranked $search.
+	if ctx.searchScore != nil {
+		if score, ok := ctx.searchScore(entity); ok {
+			writeKey("@" + searchScoreAnnotation)
+			appendSearchScore(buf, score)
+		}
+	}
+
+	// @Org.OData.Temporal.V1.From/To — versions returned by a $from/$to query.
+	if ctx.temporalPeriod != nil {
+		if from, to, ok := ctx.temporalPeriod(entity); ok {
+			writeKey("@" + internalMetadata.TemporalFrom)
+			appendTemporalBoundary(buf, &from)
+			writeKey("@" + internalMetadata.TemporalTo)
+			appendTemporalBoundary(buf, to)
+		}
+	}
+
 	// Structural and navigation properties in declaration order.
 	for j := range infos {
 		e := &plan.entries[j]
@@ -492,6 +515,12 @@ func addressableInterface(entity reflect.Value) interface{} {
 func writeFastEntityFallback(buf *bytes.Buffer, entity reflect.Value, ctx *fastEntityContext) error {
 	om := AcquireOrderedMapWithCapacity(entity.NumField() + 3)
 	processStructEntityOrderedInto(om, entity, ctx.metadata, nil, ctx.selectedNavProps, ctx.baseURL, ctx.entitySetName, ctx.metadataLevel, ctx.fullMetadata, ctx.annotationFilter)
+	if ctx.searchScore != nil {
+		addSearchScoreAnnotations([]interface{}{om}, []reflect.Value{entity}, ctx.searchScore)
+	}
+	if ctx.temporalPeriod != nil {
+		addTemporalPeriodAnnotations([]interface{}{om}, []reflect.Value{entity}, ctx.temporalPeriod)
+	}
 	err := om.marshalTo(buf)
 	om.Release()
 	return err
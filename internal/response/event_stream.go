@@ -0,0 +1,81 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// EventStreamContentType is the media type of Server-Sent Events responses.
+const EventStreamContentType = "text/event-stream"
+
+// AcceptsEventStream reports whether the request's Accept header asks for a
+// Server-Sent Events stream.
+func AcceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != EventStreamContentType {
+				continue
+			}
+			if q, ok := params["q"]; ok && strings.TrimSpace(q) == "0" {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// WriteEventStreamHeaders starts a Server-Sent Events response and flushes the
+// headers so the client sees the stream open before the first event.
+func WriteEventStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flushEventStream(w)
+}
+
+// WriteDeltaEvent writes one Server-Sent Event whose data is a delta payload
+// holding entries for entitySetName. id is sent as the event ID so clients can
+// resume the stream with the Last-Event-ID header.
+func WriteDeltaEvent(w http.ResponseWriter, r *http.Request, entitySetName, id string, entries []map[string]interface{}) error {
+	payload := map[string]interface{}{
+		"value": entries,
+	}
+	if GetODataMetadataLevel(r) != "none" {
+		payload["@odata.context"] = buildDeltaContextURL(r, entitySetName)
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "id: %s\nevent: delta\ndata: %s\n\n", id, bytes.TrimRight(data.Bytes(), "\n")); err != nil {
+		return err
+	}
+	flushEventStream(w)
+	return nil
+}
+
+// WriteEventStreamComment writes a comment line, used as a keep-alive that
+// clients ignore.
+func WriteEventStreamComment(w http.ResponseWriter, comment string) error {
+	if _, err := fmt.Fprintf(w, ": %s\n\n", comment); err != nil {
+		return err
+	}
+	flushEventStream(w)
+	return nil
+}
+
+func flushEventStream(w http.ResponseWriter) {
+	// Writers without Flush support simply buffer the stream.
+	_ = http.NewResponseController(w).Flush()
+}
@@ -26,6 +26,9 @@ type negotiation struct {
 	ieee754       bool
 	hasIndex      bool
 	metadataErr   error
+	// tabular is tabularCSV or tabularNDJSON when the client asked for a
+	// tabular collection format, and empty otherwise.
+	tabular string
 }
 
 // computeNegotiation performs all format/Accept parsing for a request exactly
@@ -43,6 +46,7 @@ func computeNegotiation(r *http.Request) *negotiation {
 		ieee754:       ieee754From(format, accept),
 		hasIndex:      hasIndex,
 		metadataErr:   validateMetadataFrom(format, accept),
+		tabular:       tabularFrom(format, accept),
 	}
 	return n
 }
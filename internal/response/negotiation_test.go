@@ -25,6 +25,8 @@ func TestNegotiationCacheMatchesFallback(t *testing.T) {
 		{"index present", "/Products?$index=true", "application/json"},
 		{"no accept no format", "/Products", ""},
 		{"invalid metadata", "/Products?$format=application/json;odata.metadata=bogus", ""},
+		{"csv via format", "/Products?$format=csv", ""},
+		{"ndjson via accept", "/Products", "application/x-ndjson"},
 	}
 
 	for _, tc := range cases {
@@ -55,6 +57,7 @@ func TestNegotiationCacheMatchesFallback(t *testing.T) {
 			assertSame("ieee754", GetIEEE754Compatible(cached), GetIEEE754Compatible(plain))
 			assertSame("contentType", BuildJSONContentType(cached), BuildJSONContentType(plain))
 			assertSame("index", shouldAddIndexAnnotations(cached), shouldAddIndexAnnotations(plain))
+			assertSame("tabular", getNegotiation(cached).tabular, getNegotiation(plain).tabular)
 
 			cachedErr := ValidateODataMetadata(cached)
 			plainErr := ValidateODataMetadata(plain)
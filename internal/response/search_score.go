@@ -0,0 +1,98 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/preference"
+)
+
+// searchScoreAnnotation is the instance annotation carrying the relevance of
+// an entity returned by a ranked $search.
+const searchScoreAnnotation = "search.score"
+
+// SearchScoreFunc returns the relevance score of an entity returned by a ranked
+// $search, or false when the entity has none.
+type SearchScoreFunc func(entity reflect.Value) (float64, bool)
+
+type searchScoresKey struct{}
+
+type searchScores struct {
+	score SearchScoreFunc
+}
+
+// WithSearchScores returns a copy of ctx whose collection response can be
+// annotated with @search.score. The scores are supplied with SetSearchScores
+// once the search has run.
+func WithSearchScores(ctx context.Context) context.Context {
+	return context.WithValue(ctx, searchScoresKey{}, &searchScores{})
+}
+
+// SetSearchScores supplies the scores for the response written for ctx. It does
+// nothing when ctx was not prepared with WithSearchScores.
+func SetSearchScores(ctx context.Context, score SearchScoreFunc) {
+	if holder, ok := ctx.Value(searchScoresKey{}).(*searchScores); ok {
+		holder.score = score
+	}
+}
+
+// searchScoresFor returns the scores supplied for r, filtered by the
+// odata.include-annotations preference.
+func searchScoresFor(r *http.Request, annotationFilter *string) SearchScoreFunc {
+	holder, ok := r.Context().Value(searchScoresKey{}).(*searchScores)
+	if !ok || holder.score == nil {
+		return nil
+	}
+	if annotationFilter != nil && !preference.MatchesAnnotationFilter(searchScoreAnnotation, *annotationFilter) {
+		return nil
+	}
+	return holder.score
+}
+
+// appendSearchScore appends score as a JSON number.
+func appendSearchScore(buf *bytes.Buffer, score float64) {
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		score = 0
+	}
+	buf.Write(strconv.AppendFloat(buf.AvailableBuffer(), score, 'g', -1, 64))
+}
+
+// addSearchScoreAnnotations adds @search.score to the transformed entities of
+// data, after their leading control information.
+func addSearchScoreAnnotations(transformed []interface{}, data interface{}, score SearchScoreFunc) {
+	dataValue := reflect.ValueOf(data)
+	for i, item := range transformed {
+		if i >= dataValue.Len() {
+			return
+		}
+		entity := dataValue.Index(i)
+		for entity.Kind() == reflect.Ptr || entity.Kind() == reflect.Interface {
+			entity = entity.Elem()
+		}
+		if entity.Kind() != reflect.Struct {
+			continue
+		}
+		value, ok := score(entity)
+		if !ok {
+			continue
+		}
+		switch m := item.(type) {
+		case *OrderedMap:
+			after := ""
+			for _, key := range m.keys {
+				if !strings.HasPrefix(key, "@") {
+					break
+				}
+				after = key
+			}
+			m.InsertAfter(after, "@"+searchScoreAnnotation, value)
+		case map[string]interface{}:
+			m["@"+searchScoreAnnotation] = value
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/preference"
+)
+
+// streamFlushThreshold is the amount of buffered output after which a streamed
+// collection is written to the client.
+const streamFlushThreshold = 32 << 10
+
+// CollectionStream writes an entity collection response one entity at a time,
+// so the whole result never has to be held in memory. Entities are serialized
+// by the direct struct writer. The response has no Content-Length and is sent
+// with chunked transfer encoding.
+//
+// @odata.count is written before the value array. @odata.nextLink is only known
+// once the rows have been read, so it is written after the array; the
+// Content-Type carries odata.streaming=true to announce that ordering.
+type CollectionStream struct {
+	w     http.ResponseWriter
+	ctx   *fastEntityContext
+	buf   *bytes.Buffer
+	enc   *json.Encoder
+	count int
+	err   error
+}
+
+// CanStreamCollection reports whether the negotiated response for r can be
+// written by a CollectionStream: a JSON response without per-item rewriting
+// ($index, Prefer: omit-values).
+func CanStreamCollection(r *http.Request) bool {
+	n := getNegotiation(r)
+	if !n.acceptable || n.isAtom || n.tabular != "" || n.metadataErr != nil || n.hasIndex {
+		return false
+	}
+	return preference.ParsePrefer(r).OmitValues == nil
+}
+
+// NewCollectionStream writes the response headers and the envelope up to the
+// start of the value array. count is written as @odata.count when non-nil.
+func NewCollectionStream(w http.ResponseWriter, r *http.Request, entitySetName string, md EntityMetadataProvider, fullMetadata *metadata.EntityMetadata, selectedNavProps, selectedProps []string, count *int64) (*CollectionStream, error) {
+	metadataLevel := GetODataMetadataLevel(r)
+	annotationFilter := preference.ParsePrefer(r).IncludeAnnotations
+	ctx := &fastEntityContext{
+		baseURL:          buildBaseURL(r),
+		entitySetName:    entitySetName,
+		metadataLevel:    metadataLevel,
+		metadata:         md,
+		fullMetadata:     fullMetadata,
+		selectedNavProps: selectedNavProps,
+		annotationFilter: annotationFilter,
+		selectedSet:      buildSelectedSet(selectedProps),
+		keySet:           buildKeySet(md),
+		searchScore:      searchScoresFor(r, annotationFilter),
+	}
+
+	s := &CollectionStream{
+		w:   w,
+		ctx: ctx,
+		buf: bufferPool.Get().(*bytes.Buffer), //nolint:errcheck // sync.Pool.Get() doesn't return error
+	}
+	s.buf.Reset()
+
+	s.buf.WriteByte('{')
+	first := true
+	if metadataLevel != MetadataNone {
+		writeJSONKey(s.buf, "@odata.context")
+		s.buf.WriteByte(':')
+		if err := writeJSONString(s.buf, buildContextURLWithSelect(r, entitySetName, selectedProps), &s.enc); err != nil {
+			s.release()
+			return nil, err
+		}
+		first = false
+	}
+	if count != nil {
+		if !first {
+			s.buf.WriteByte(',')
+		}
+		writeJSONKey(s.buf, "@odata.count")
+		s.buf.WriteByte(':')
+		writeInt(s.buf, *count)
+		first = false
+	}
+	if !first {
+		s.buf.WriteByte(',')
+	}
+	writeJSONKey(s.buf, "value")
+	s.buf.WriteString(":[")
+
+	SetODataVersionHeaderFromRequest(w, r)
+	w.Header().Set("Content-Type", "application/json;odata.metadata="+metadataLevel+";odata.streaming=true")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	return s, nil
+}
+
+// WriteEntity appends entity, a struct value, to the value array. Buffered
+// output is flushed to the client whenever it exceeds streamFlushThreshold.
+func (s *CollectionStream) WriteEntity(entity reflect.Value) error {
+	if s.err != nil {
+		return s.err
+	}
+	for entity.Kind() == reflect.Ptr {
+		entity = entity.Elem()
+	}
+	if s.count > 0 {
+		s.buf.WriteByte(',')
+	}
+	if err := writeFastEntity(s.buf, entity, s.ctx, &s.enc); err != nil {
+		s.err = err
+		return err
+	}
+	s.count++
+	if s.buf.Len() >= streamFlushThreshold {
+		return s.flush()
+	}
+	return nil
+}
+
+// Count returns the number of entities written so far.
+func (s *CollectionStream) Count() int {
+	return s.count
+}
+
+// Close terminates the value array, writes nextLink when non-nil, and flushes
+// the remaining output.
+func (s *CollectionStream) Close(nextLink *string) error {
+	defer s.release()
+	if s.err != nil {
+		return s.err
+	}
+	s.buf.WriteByte(']')
+	if nextLink != nil && *nextLink != "" {
+		s.buf.WriteByte(',')
+		writeJSONKey(s.buf, "@odata.nextLink")
+		s.buf.WriteByte(':')
+		if err := writeJSONString(s.buf, *nextLink, &s.enc); err != nil {
+			return err
+		}
+	}
+	s.buf.WriteByte('}')
+	return s.flush()
+}
+
+// Abort releases the stream without terminating the JSON document, so clients
+// see a truncated payload instead of a well-formed but incomplete collection.
+// Use it when reading the rows fails after the response has started.
+func (s *CollectionStream) Abort() {
+	if s.err == nil && s.buf != nil {
+		_ = s.flush() //nolint:errcheck // the response is being abandoned
+	}
+	s.release()
+}
+
+func (s *CollectionStream) flush() error {
+	if _, err := s.w.Write(s.buf.Bytes()); err != nil {
+		s.err = err
+		return err
+	}
+	s.buf.Reset()
+	// A writer that cannot flush still receives every byte; it is only sent
+	// once the handler returns.
+	_ = http.NewResponseController(s.w).Flush() //nolint:errcheck // see above
+	return nil
+}
+
+func (s *CollectionStream) release() {
+	if s.buf != nil {
+		releasePooledBuffer(s.buf)
+		s.buf = nil
+	}
+}
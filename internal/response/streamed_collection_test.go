@@ -0,0 +1,136 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	internalMetadata "github.com/nlstn/go-odata/internal/metadata"
+)
+
+// chunkRecorder records each Write separately so tests can see when the
+// stream flushed.
+type chunkRecorder struct {
+	*httptest.ResponseRecorder
+	chunks int
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.chunks++
+	return c.ResponseRecorder.Write(p)
+}
+
+func TestCanStreamCollection(t *testing.T) {
+	cases := []struct {
+		target string
+		accept string
+		prefer string
+		want   bool
+	}{
+		{"/Products", "", "", true},
+		{"/Products", "application/json;odata.metadata=full", "", true},
+		{"/Products?$format=csv", "", "", false},
+		{"/Products", "application/atom+xml", "", false},
+		{"/Products?$index", "", "", false},
+		{"/Products", "", "omit-values=nulls", false},
+		{"/Products", "application/xml", "", false},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.accept != "" {
+			req.Header.Set("Accept", tc.accept)
+		}
+		if tc.prefer != "" {
+			req.Header.Set("Prefer", tc.prefer)
+		}
+		if got := CanStreamCollection(req); got != tc.want {
+			t.Errorf("CanStreamCollection(%s, Accept %q, Prefer %q) = %v, want %v", tc.target, tc.accept, tc.prefer, got, tc.want)
+		}
+	}
+}
+
+func TestCollectionStream_Envelope(t *testing.T) {
+	fullMD, err := internalMetadata.AnalyzeEntity(&ewCat{})
+	if err != nil {
+		t.Fatalf("AnalyzeEntity: %v", err)
+	}
+	provider := newEwProvider(fullMD)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/EwCats?$count=true", nil)
+	w := httptest.NewRecorder()
+	count := int64(7)
+	stream, err := NewCollectionStream(w, req, "EwCats", provider, fullMD, nil, nil, &count)
+	if err != nil {
+		t.Fatalf("NewCollectionStream: %v", err)
+	}
+	for _, cat := range []ewCat{{ID: 1, Name: "A"}, {ID: 2, Name: "B"}} {
+		if err := stream.WriteEntity(reflect.ValueOf(&cat)); err != nil {
+			t.Fatalf("WriteEntity: %v", err)
+		}
+	}
+	if stream.Count() != 2 {
+		t.Errorf("Count() = %d, want 2", stream.Count())
+	}
+	next := "http://example.com/EwCats?$skiptoken=abc"
+	if err := stream.Close(&next); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "application/json;odata.metadata=minimal;odata.streaming=true" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := w.Body.String()
+	want := `{"@odata.context":"http://example.com/$metadata#EwCats","@odata.count":7,"value":[{"@odata.id":"http://example.com/EwCats(1)","ID":1,"Name":"A"},{"@odata.id":"http://example.com/EwCats(2)","ID":2,"Name":"B"}],"@odata.nextLink":"http://example.com/EwCats?$skiptoken=abc"}`
+	if body != want {
+		t.Errorf("body =\n%s\nwant\n%s", body, want)
+	}
+}
+
+func TestCollectionStream_FlushesLargeCollections(t *testing.T) {
+	fullMD, err := internalMetadata.AnalyzeEntity(&ewCat{})
+	if err != nil {
+		t.Fatalf("AnalyzeEntity: %v", err)
+	}
+	provider := newEwProvider(fullMD)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/EwCats", nil)
+	req.Header.Set("Accept", "application/json;odata.metadata=none")
+	w := &chunkRecorder{ResponseRecorder: httptest.NewRecorder()}
+	stream, err := NewCollectionStream(w, req, "EwCats", provider, fullMD, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCollectionStream: %v", err)
+	}
+	const rows = 5000
+	name := strings.Repeat("x", 64)
+	for i := 1; i <= rows; i++ {
+		if err := stream.WriteEntity(reflect.ValueOf(ewCat{ID: uint(i), Name: name})); err != nil {
+			t.Fatalf("WriteEntity: %v", err)
+		}
+	}
+	if err := stream.Close(nil); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if w.chunks < 2 {
+		t.Errorf("stream wrote %d chunks, want it to flush before Close", w.chunks)
+	}
+	if !w.Flushed {
+		t.Error("stream did not flush the response writer")
+	}
+	var payload struct {
+		Value []ewCat `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(payload.Value) != rows || payload.Value[rows-1].ID != rows {
+		t.Errorf("decoded %d entities, want %d", len(payload.Value), rows)
+	}
+	if strings.Contains(w.Body.String(), "nextLink") {
+		t.Error("nextLink written although none was given")
+	}
+}
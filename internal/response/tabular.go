@@ -0,0 +1,407 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+)
+
+// Tabular formats for collection responses, selected via $format or Accept.
+const (
+	tabularCSV    = "csv"
+	tabularNDJSON = "ndjson"
+
+	csvContentType    = "text/csv;charset=utf-8"
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// IsCSVFormat returns true if the request asks for CSV via $format=csv,
+// $format=text/csv or Accept: text/csv.
+func IsCSVFormat(r *http.Request) bool {
+	return getNegotiation(r).tabular == tabularCSV
+}
+
+// IsNDJSONFormat returns true if the request asks for newline-delimited JSON via
+// $format=ndjson, $format=application/x-ndjson or Accept: application/x-ndjson.
+func IsNDJSONFormat(r *http.Request) bool {
+	return getNegotiation(r).tabular == tabularNDJSON
+}
+
+// IsTabularFormat returns true if the request asks for CSV or NDJSON. Only
+// collection responses support these formats.
+func IsTabularFormat(r *http.Request) bool {
+	return getNegotiation(r).tabular != ""
+}
+
+// tabularFrom resolves the tabular format from the already parsed $format value
+// and Accept header. An Accept header selects a tabular format only when it
+// ranks it above JSON and Atom.
+func tabularFrom(format, accept string) string {
+	if format != "" {
+		parts := strings.Split(format, ";")
+		switch strings.ToLower(strings.TrimSpace(parts[0])) {
+		case "csv", "text/csv":
+			return tabularCSV
+		case "ndjson", "application/x-ndjson":
+			return tabularNDJSON
+		}
+		return ""
+	}
+	if accept == "" {
+		return ""
+	}
+
+	var csvQuality, ndjsonQuality, otherQuality float64
+	for _, part := range strings.Split(accept, ",") {
+		subparts := strings.Split(strings.TrimSpace(part), ";")
+		quality := 1.0
+		for _, param := range subparts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var q float64
+				if _, err := fmt.Sscanf(param[2:], "%f", &q); err == nil && q >= 0 && q <= 1 {
+					quality = q
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(subparts[0])) {
+		case "text/csv":
+			csvQuality = max(csvQuality, quality)
+		case "application/x-ndjson":
+			ndjsonQuality = max(ndjsonQuality, quality)
+		case "application/json", "application/atom+xml":
+			otherQuality = max(otherQuality, quality)
+		}
+	}
+
+	switch {
+	case csvQuality > otherQuality && csvQuality >= ndjsonQuality:
+		return tabularCSV
+	case ndjsonQuality > otherQuality:
+		return tabularNDJSON
+	}
+	return ""
+}
+
+// tabularField is a top-level member of a serialized collection item.
+type tabularField struct {
+	name  string
+	value json.RawMessage
+}
+
+// WriteTabularCollection writes the items of data as CSV or NDJSON, whichever
+// the request asked for. selectedProps orders the CSV columns.
+func WriteTabularCollection(w http.ResponseWriter, r *http.Request, data interface{}, nextLink *string, selectedProps []string) error {
+	var items []interface{}
+	dataValue := reflect.ValueOf(data)
+	if dataValue.Kind() == reflect.Ptr {
+		dataValue = dataValue.Elem()
+	}
+	if dataValue.Kind() == reflect.Slice || dataValue.Kind() == reflect.Array {
+		items = make([]interface{}, dataValue.Len())
+		for i := range items {
+			items[i] = dataValue.Index(i).Interface()
+		}
+	}
+
+	rows, err := tabularRows(items)
+	if err != nil {
+		return WriteError(w, r, http.StatusInternalServerError, "Internal Server Error", "Failed to serialize response.")
+	}
+	return writeTabularRows(w, r, rows, nextLink, selectedProps)
+}
+
+// writeTabularEntityCollection writes an entity collection as CSV or NDJSON.
+// Entities are serialized without metadata annotations; expanded navigation
+// properties are kept and flattened like complex values.
+func writeTabularEntityCollection(w http.ResponseWriter, r *http.Request, entitySetName string, data interface{}, nextLink *string, metadata EntityMetadataProvider, expandOptions []query.ExpandOption, selectedNavProps []string, fullMetadata *metadata.EntityMetadata, selectedProps []string) error {
+	transformedData := addNavigationLinks(data, metadata, expandOptions, selectedNavProps, r, entitySetName, MetadataNone, fullMetadata)
+	rows, err := tabularRows(transformedData)
+	releaseOrderedMaps(transformedData)
+	if err != nil {
+		return WriteError(w, r, http.StatusInternalServerError, "Internal Server Error", "Failed to serialize response.")
+	}
+
+	// A $select on entity structs may have been deferred to the serializer, so
+	// the rows can still carry unselected properties.
+	if selected := buildSelectedSet(selectedProps); selected != nil && isStructCollection(data) {
+		projectTabularRows(rows, selected, buildKeySet(metadata))
+	}
+	return writeTabularRows(w, r, rows, nextLink, selectedProps)
+}
+
+// isStructCollection reports whether data is a slice of structs or struct pointers.
+func isStructCollection(data interface{}) bool {
+	t := reflect.TypeOf(data)
+	if t == nil || t.Kind() != reflect.Slice {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct
+}
+
+// tabularRows serializes each item and splits it into its top-level members,
+// keeping their order. Items that are not JSON objects become a single "value" member.
+func tabularRows(items []interface{}) ([][]tabularField, error) {
+	rows := make([][]tabularField, 0, len(items))
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	for _, item := range items {
+		buf.Reset()
+		if err := encoder.Encode(item); err != nil {
+			return nil, err
+		}
+		raw := bytes.TrimSpace(buf.Bytes())
+		fields, isObject, err := decodeTabularObject(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !isObject {
+			fields = []tabularField{{name: "value", value: append(json.RawMessage(nil), raw...)}}
+		}
+		rows = append(rows, fields)
+	}
+	return rows, nil
+}
+
+// decodeTabularObject returns the members of the JSON object raw in order. It
+// reports false when raw is not an object.
+func decodeTabularObject(raw []byte) ([]tabularField, bool, error) {
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, false, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, true, err
+	}
+	var fields []tabularField
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, true, err
+		}
+		name, _ := token.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, true, err
+		}
+		fields = append(fields, tabularField{name: name, value: value})
+	}
+	return fields, true, nil
+}
+
+// projectTabularRows keeps only the selected and key members of each row. It
+// is used for entity structs whose $select projection was deferred to the
+// serializer.
+func projectTabularRows(rows [][]tabularField, selected, keys map[string]struct{}) {
+	for i, row := range rows {
+		kept := row[:0]
+		for _, field := range row {
+			_, isSelected := selected[field.name]
+			_, isKey := keys[field.name]
+			if isSelected || isKey {
+				kept = append(kept, field)
+			}
+		}
+		rows[i] = kept
+	}
+}
+
+func writeTabularRows(w http.ResponseWriter, r *http.Request, rows [][]tabularField, nextLink *string, selectedProps []string) error {
+	format := getNegotiation(r).tabular
+
+	SetODataVersionHeaderFromRequest(w, r)
+	if nextLink != nil && *nextLink != "" {
+		// Tabular formats have no envelope, so the next page is announced as a Link header.
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", *nextLink))
+	}
+	if format == tabularCSV {
+		w.Header().Set("Content-Type", csvContentType)
+	} else {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return nil
+	}
+	if format == tabularCSV {
+		return writeCSVRows(w, rows, selectedProps)
+	}
+	return writeNDJSONRows(w, rows)
+}
+
+// writeNDJSONRows writes one JSON object per line.
+func writeNDJSONRows(w io.Writer, rows [][]tabularField) error {
+	var line bytes.Buffer
+	for _, row := range rows {
+		line.Reset()
+		if len(row) == 1 && row[0].name == "value" && (len(row[0].value) == 0 || row[0].value[0] != '{') {
+			line.Write(row[0].value)
+		} else {
+			line.WriteByte('{')
+			for i, field := range row {
+				if i > 0 {
+					line.WriteByte(',')
+				}
+				writeJSONKey(&line, field.name)
+				line.WriteByte(':')
+				line.Write(field.value)
+			}
+			line.WriteByte('}')
+		}
+		line.WriteByte('\n')
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCSVRows writes a header row followed by one row per item. Complex values
+// are flattened into one column per nested property, named by its path
+// (Address/City); arrays are written as JSON.
+func writeCSVRows(w io.Writer, rows [][]tabularField, selectedProps []string) error {
+	cells := make([]map[string]string, len(rows))
+	var names []string
+	seen := make(map[string]bool)
+	for i, row := range rows {
+		cells[i] = make(map[string]string, len(row))
+		for _, field := range row {
+			if strings.Contains(field.name, "@") {
+				continue
+			}
+			flattenCSVValue(field.name, field.value, cells[i], func(name string) {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			})
+		}
+	}
+
+	// A complex property that is null in some rows also produced a plain column
+	// for itself; its nested columns already cover it.
+	flat := make([]string, 0, len(names))
+	for _, name := range names {
+		nested := false
+		for _, other := range names {
+			if strings.HasPrefix(other, name+"/") {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			flat = append(flat, name)
+		}
+	}
+
+	columns := csvColumns(flat, selectedProps)
+	writer := csv.NewWriter(w)
+	if len(columns) > 0 {
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(columns))
+	for _, row := range cells {
+		for i, column := range columns {
+			record[i] = row[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvColumns orders the flattened column names by $select, or keeps the order
+// in which they first appeared when nothing was selected.
+func csvColumns(names, selectedProps []string) []string {
+	var selected []string
+	for _, prop := range selectedProps {
+		prop = strings.TrimSpace(prop)
+		if prop == "*" {
+			return names
+		}
+		if prop != "" {
+			selected = append(selected, prop)
+		}
+	}
+	if len(selected) == 0 {
+		return names
+	}
+
+	columns := make([]string, 0, len(selected))
+	for _, prop := range selected {
+		matched := false
+		for _, name := range names {
+			if name == prop || strings.HasPrefix(name, prop+"/") {
+				columns = append(columns, name)
+				matched = true
+			}
+		}
+		if !matched {
+			columns = append(columns, prop)
+		}
+	}
+	return columns
+}
+
+// flattenCSVValue stores the cell text of raw under name, descending into JSON
+// objects. column is called for every produced column name.
+func flattenCSVValue(name string, raw json.RawMessage, cells map[string]string, column func(string)) {
+	fields, isObject, err := decodeTabularObject(raw)
+	if isObject && err == nil {
+		for _, field := range fields {
+			if strings.Contains(field.name, "@") {
+				continue
+			}
+			flattenCSVValue(name+"/"+field.name, field.value, cells, column)
+		}
+		return
+	}
+
+	column(name)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		cells[name] = ""
+	case raw[0] == '"':
+		var text string
+		if json.Unmarshal(raw, &text) == nil {
+			cells[name] = csvText(text)
+			return
+		}
+		cells[name] = string(raw)
+	default:
+		cells[name] = string(raw)
+	}
+}
+
+// csvText guards a string cell against formula injection: spreadsheet
+// applications evaluate cells starting with =, +, -, @, a tab or a carriage
+// return as formulas, so such text is prefixed with a single quote. Numbers
+// are written as they are, so negative numbers stay numeric.
+func csvText(text string) string {
+	if text == "" {
+		return text
+	}
+	switch text[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + text
+	}
+	return text
+}
@@ -0,0 +1,173 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTabularFrom(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		accept string
+		want   string
+	}{
+		{"format csv", "csv", "", tabularCSV},
+		{"format text/csv", "text/csv", "application/json", tabularCSV},
+		{"format ndjson", "ndjson", "", tabularNDJSON},
+		{"format x-ndjson", "application/x-ndjson", "", tabularNDJSON},
+		{"format json", "json", "text/csv", ""},
+		{"accept csv", "", "text/csv", tabularCSV},
+		{"accept ndjson", "", "application/x-ndjson", tabularNDJSON},
+		{"accept json preferred", "", "text/csv;q=0.5, application/json", ""},
+		{"accept csv preferred", "", "text/csv, application/json;q=0.8", tabularCSV},
+		{"accept csv refused", "", "text/csv;q=0", ""},
+		{"accept wildcard", "", "*/*", ""},
+		{"no accept", "", "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tabularFrom(tc.format, tc.accept); got != tc.want {
+				t.Errorf("tabularFrom(%q, %q) = %q, want %q", tc.format, tc.accept, got, tc.want)
+			}
+		})
+	}
+}
+
+type tabularAddress struct {
+	City    string `json:"City"`
+	Country string `json:"Country"`
+}
+
+type tabularProduct struct {
+	ID      int             `json:"ID"`
+	Name    string          `json:"Name"`
+	Price   float64         `json:"Price"`
+	Tags    []string        `json:"Tags"`
+	Address *tabularAddress `json:"Address"`
+}
+
+func tabularProducts() []tabularProduct {
+	return []tabularProduct{
+		{ID: 1, Name: "Laptop, 15\"", Price: 999.5, Tags: []string{"a", "b"}, Address: &tabularAddress{City: "Berlin", Country: "DE"}},
+		{ID: 2, Name: "Mouse", Price: 20},
+	}
+}
+
+func TestWriteTabularCollection_CSV(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Products?$format=csv", nil)
+	w := httptest.NewRecorder()
+
+	if err := WriteTabularCollection(w, req, tabularProducts(), nil, nil); err != nil {
+		t.Fatalf("WriteTabularCollection: %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != csvContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, csvContentType)
+	}
+	want := "ID,Name,Price,Tags,Address/City,Address/Country\n" +
+		"1,\"Laptop, 15\"\"\",999.5,\"[\"\"a\"\",\"\"b\"\"]\",Berlin,DE\n" +
+		"2,Mouse,20,,,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteTabularCollection_CSVSelectOrdersColumns(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Products?$format=csv", nil)
+	w := httptest.NewRecorder()
+
+	if err := WriteTabularCollection(w, req, tabularProducts(), nil, []string{"Address", "Name", "Missing"}); err != nil {
+		t.Fatalf("WriteTabularCollection: %v", err)
+	}
+
+	want := "Address/City,Address/Country,Name,Missing\n" +
+		"Berlin,DE,\"Laptop, 15\"\"\",\n" +
+		",,Mouse,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteTabularCollection_CSVEscapesFormulas(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Products?$format=csv", nil)
+	w := httptest.NewRecorder()
+
+	products := []tabularProduct{
+		{ID: 1, Name: "=HYPERLINK(\"http://x\")", Price: -5},
+		{ID: 2, Name: "+1"},
+		{ID: 3, Name: "-2"},
+		{ID: 4, Name: "@SUM(A1)"},
+		{ID: 5, Name: "\tcmd"},
+		{ID: 6, Name: "\rcmd"},
+		{ID: 7, Name: "a=b", Address: &tabularAddress{City: "=1+1"}},
+	}
+	if err := WriteTabularCollection(w, req, products, nil, []string{"ID", "Name", "Price", "Address"}); err != nil {
+		t.Fatalf("WriteTabularCollection: %v", err)
+	}
+
+	want := "ID,Name,Price,Address/City,Address/Country\n" +
+		"1,\"'=HYPERLINK(\"\"http://x\"\")\",-5,,\n" +
+		"2,'+1,0,,\n" +
+		"3,'-2,0,,\n" +
+		"4,'@SUM(A1),0,,\n" +
+		"5,'\tcmd,0,,\n" +
+		"6,\"'\rcmd\",0,,\n" +
+		"7,a=b,0,'=1+1,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWriteTabularCollection_NDJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/Products", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+	nextLink := "http://example.com/Products?$skiptoken=2"
+
+	data := []interface{}{
+		map[string]interface{}{"Name": "<b>"},
+		"plain",
+	}
+	if err := WriteTabularCollection(w, req, data, &nextLink, nil); err != nil {
+		t.Fatalf("WriteTabularCollection: %v", err)
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != ndjsonContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ndjsonContentType)
+	}
+	if link := w.Header().Get("Link"); link != `<`+nextLink+`>; rel="next"` {
+		t.Errorf("Link = %q", link)
+	}
+	want := "{\"Name\":\"<b>\"}\n\"plain\"\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestProjectTabularRows(t *testing.T) {
+	rows, err := tabularRows([]interface{}{tabularProducts()[0]})
+	if err != nil {
+		t.Fatalf("tabularRows: %v", err)
+	}
+
+	projectTabularRows(rows, map[string]struct{}{"Name": {}}, map[string]struct{}{"ID": {}})
+
+	if len(rows[0]) != 2 || rows[0][0].name != "ID" || rows[0][1].name != "Name" {
+		t.Errorf("projected row = %+v, want ID and Name", rows[0])
+	}
+}
+
+func TestWriteTabularCollection_HeadWritesNoBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodHead, "/Products?$format=ndjson", nil)
+	w := httptest.NewRecorder()
+
+	if err := WriteTabularCollection(w, req, tabularProducts(), nil, nil); err != nil {
+		t.Fatalf("WriteTabularCollection: %v", err)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD body = %q, want empty", w.Body.String())
+	}
+}
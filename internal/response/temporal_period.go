@@ -0,0 +1,109 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/preference"
+)
+
+// TemporalPeriodFunc returns the period an entity version returned by a $from/$to
+// query was valid in, or false when the entity has none. A nil to means the
+// version is still current.
+type TemporalPeriodFunc func(entity reflect.Value) (from time.Time, to *time.Time, ok bool)
+
+type temporalPeriodsKey struct{}
+
+type temporalPeriods struct {
+	period TemporalPeriodFunc
+}
+
+// WithTemporalPeriods returns a copy of ctx whose collection response can be
+// annotated with the validity period of each entity version. The periods are
+// supplied with SetTemporalPeriods once the versions have been read.
+func WithTemporalPeriods(ctx context.Context) context.Context {
+	return context.WithValue(ctx, temporalPeriodsKey{}, &temporalPeriods{})
+}
+
+// SetTemporalPeriods supplies the periods for the response written for ctx. It
+// does nothing when ctx was not prepared with WithTemporalPeriods.
+func SetTemporalPeriods(ctx context.Context, period TemporalPeriodFunc) {
+	if holder, ok := ctx.Value(temporalPeriodsKey{}).(*temporalPeriods); ok {
+		holder.period = period
+	}
+}
+
+// temporalPeriodsFor returns the periods supplied for r, filtered by the
+// odata.include-annotations preference.
+func temporalPeriodsFor(r *http.Request, annotationFilter *string) TemporalPeriodFunc {
+	holder, ok := r.Context().Value(temporalPeriodsKey{}).(*temporalPeriods)
+	if !ok || holder.period == nil {
+		return nil
+	}
+	if annotationFilter != nil &&
+		!preference.MatchesAnnotationFilter(metadata.TemporalFrom, *annotationFilter) &&
+		!preference.MatchesAnnotationFilter(metadata.TemporalTo, *annotationFilter) {
+		return nil
+	}
+	return holder.period
+}
+
+// appendTemporalBoundary appends a period boundary as a JSON DateTimeOffset
+// string, or null for the open end of a current version.
+func appendTemporalBoundary(buf *bytes.Buffer, t *time.Time) {
+	if t == nil {
+		buf.WriteString("null")
+		return
+	}
+	buf.WriteByte('"')
+	buf.Write(t.UTC().AppendFormat(buf.AvailableBuffer(), time.RFC3339Nano))
+	buf.WriteByte('"')
+}
+
+// addTemporalPeriodAnnotations adds the @Org.OData.Temporal.V1.From and
+// @Org.OData.Temporal.V1.To annotations to the transformed entities of data,
+// after their leading control information.
+func addTemporalPeriodAnnotations(transformed []interface{}, data interface{}, period TemporalPeriodFunc) {
+	dataValue := reflect.ValueOf(data)
+	for i, item := range transformed {
+		if i >= dataValue.Len() {
+			return
+		}
+		entity := dataValue.Index(i)
+		for entity.Kind() == reflect.Ptr || entity.Kind() == reflect.Interface {
+			entity = entity.Elem()
+		}
+		if entity.Kind() != reflect.Struct {
+			continue
+		}
+		from, to, ok := period(entity)
+		if !ok {
+			continue
+		}
+		var toValue interface{}
+		if to != nil {
+			toValue = to.UTC().Format(time.RFC3339Nano)
+		}
+		fromValue := from.UTC().Format(time.RFC3339Nano)
+		switch m := item.(type) {
+		case *OrderedMap:
+			after := ""
+			for _, key := range m.keys {
+				if !strings.HasPrefix(key, "@") {
+					break
+				}
+				after = key
+			}
+			m.InsertAfter(after, "@"+metadata.TemporalFrom, fromValue)
+			m.InsertAfter("@"+metadata.TemporalFrom, "@"+metadata.TemporalTo, toValue)
+		case map[string]interface{}:
+			m["@"+metadata.TemporalFrom] = fromValue
+			m["@"+metadata.TemporalTo] = toValue
+		}
+	}
+}
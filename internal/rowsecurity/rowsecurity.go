@@ -0,0 +1,198 @@
+// Package rowsecurity restricts GORM reads to the rows an authorization policy
+// permits. A request carries a Scope in its context; the callbacks registered
+// by Register AND the scope's row filter for the statement's entity type into
+// every query and row scan run with that context, so $expand, navigation,
+// $count, $apply, $ref and hook-initiated reads are filtered just like
+// top-level collection reads.
+package rowsecurity
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	callbackName = "odata:row_security"
+	// appliedClause marks statements that already carry the row filter, so a
+	// statement executed twice (e.g. Count followed by Find) gets it once.
+	appliedClause = "odata:row_security_applied"
+)
+
+// FilterFunc returns the row filter for an entity set, or nil when all of its
+// rows are visible.
+type FilterFunc func(entity *metadata.EntityMetadata) (*query.FilterExpression, error)
+
+type resolvedFilter struct {
+	filter *query.FilterExpression
+	err    error
+}
+
+// Scope resolves the row filters of one request. Filters are resolved at most
+// once per entity set.
+type Scope struct {
+	entities []*metadata.EntityMetadata
+	filterFn FilterFunc
+
+	mu       sync.Mutex
+	resolved map[*metadata.EntityMetadata]resolvedFilter
+}
+
+type contextKey struct{}
+
+// NewScope creates a scope over the given entity sets.
+func NewScope(entities []*metadata.EntityMetadata, filter FilterFunc) *Scope {
+	return &Scope{
+		entities: entities,
+		filterFn: filter,
+		resolved: make(map[*metadata.EntityMetadata]resolvedFilter),
+	}
+}
+
+// WithScope returns a context whose reads are restricted by scope. Related
+// entities reached inside a query, through lambda operators and navigation
+// joins, are restricted as well.
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	ctx = query.WithRowFilterResolver(ctx, scope.Filter)
+	return context.WithValue(ctx, contextKey{}, scope)
+}
+
+// FromContext returns the scope carried by ctx, or nil.
+func FromContext(ctx context.Context) *Scope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(contextKey{}).(*Scope)
+	return scope
+}
+
+// Unrestricted returns a context that reads all rows. It is meant for loads
+// shared between callers, such as the in-memory entity cache, whose consumers
+// apply the row filter themselves.
+func Unrestricted(ctx context.Context) context.Context {
+	ctx = query.WithRowFilterResolver(ctx, nil)
+	return context.WithValue(ctx, contextKey{}, (*Scope)(nil))
+}
+
+// Filter returns the row filter of an entity set. A nil scope permits all rows.
+func (s *Scope) Filter(entity *metadata.EntityMetadata) (*query.FilterExpression, error) {
+	if s == nil || entity == nil || s.filterFn == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	cached, ok := s.resolved[entity]
+	s.mu.Unlock()
+	if ok {
+		return cached.filter, cached.err
+	}
+
+	filter, err := s.filterFn(entity)
+	s.mu.Lock()
+	s.resolved[entity] = resolvedFilter{filter: filter, err: err}
+	s.mu.Unlock()
+	return filter, err
+}
+
+// restriction returns the row filter for reads of a Go model type. When
+// several entity sets share the type, a row must satisfy all of their filters.
+func (s *Scope) restriction(modelType reflect.Type) (*metadata.EntityMetadata, *query.FilterExpression, error) {
+	var (
+		target   *metadata.EntityMetadata
+		combined *query.FilterExpression
+	)
+	for _, entity := range s.entities {
+		if entity == nil || entity.IsSingleton || entity.EntityType != modelType {
+			continue
+		}
+		filter, err := s.Filter(entity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("row filter for %s: %w", entity.EntitySetName, err)
+		}
+		if filter == nil {
+			continue
+		}
+		if target == nil {
+			target = entity
+		}
+		combined = query.MergeFilterExpressions(combined, filter)
+	}
+	return target, combined, nil
+}
+
+// Register installs the row security query and row callbacks on db. It is safe
+// to call more than once; later calls are no-ops.
+func Register(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	if db.Callback().Query().Get(callbackName) == nil {
+		if err := db.Callback().Query().Before("gorm:query").Register(callbackName, restrictRows); err != nil {
+			return err
+		}
+	}
+	if db.Callback().Row().Get(callbackName) == nil {
+		if err := db.Callback().Row().Before("gorm:row").Register(callbackName, restrictRows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func restrictRows(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 {
+		return
+	}
+	if _, ok := stmt.Clauses[appliedClause]; ok {
+		return
+	}
+	scope := FromContext(stmt.Context)
+	if scope == nil {
+		return
+	}
+	entity, filter, err := scope.restriction(stmt.Schema.ModelType)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	if filter == nil {
+		return
+	}
+	stmt.Clauses[appliedClause] = clause.Clause{}
+
+	if stmt.TableExpr != nil {
+		// Reads from a table expression, such as temporal history versions
+		// aliased to the entity table, expose the entity's columns directly.
+		query.ApplyFilterOnly(db, filter, entity, nil)
+		return
+	}
+
+	// Matching keys through a subquery keeps the filter's navigation joins
+	// and unqualified columns away from joins the statement already has.
+	keys := make([]interface{}, 0, len(entity.KeyProperties))
+	selects := make([]string, 0, len(entity.KeyProperties))
+	for _, key := range entity.KeyProperties {
+		keys = append(keys, clause.Column{Table: clause.CurrentTable, Name: key.ColumnName})
+		selects = append(selects, stmt.Quote(entity.TableName+"."+key.ColumnName))
+	}
+	if len(keys) == 0 {
+		_ = db.AddError(fmt.Errorf("row filter for %s: entity set has no key", entity.EntitySetName))
+		return
+	}
+	sub := db.Session(&gorm.Session{NewDB: true, Context: Unrestricted(stmt.Context)}).
+		Unscoped().
+		Model(reflect.New(stmt.Schema.ModelType).Interface())
+	sub = query.ApplyFilterOnly(sub, filter, entity, nil).Select(strings.Join(selects, ", "))
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Expr{SQL: "(" + placeholders + ") IN (?)", Vars: append(keys, sub)},
+	}})
+}
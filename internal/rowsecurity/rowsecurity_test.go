@@ -0,0 +1,163 @@
+package rowsecurity
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type document struct {
+	ID      uint     `json:"ID" gorm:"primaryKey" odata:"key"`
+	Owner   string   `json:"Owner"`
+	Title   string   `json:"Title"`
+	Section *section `json:"Section,omitempty" gorm:"foreignKey:DocumentID"`
+}
+
+type section struct {
+	ID         uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	DocumentID uint   `json:"DocumentID"`
+	Name       string `json:"Name"`
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&document{}, &section{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatalf("second Register() error: %v", err)
+	}
+	docs := []document{{ID: 1, Owner: "alice", Title: "a"}, {ID: 2, Owner: "bob", Title: "b"}, {ID: 3, Owner: "alice", Title: "c"}}
+	if err := db.Create(&docs).Error; err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	sections := []section{{ID: 1, DocumentID: 1, Name: "intro"}, {ID: 2, DocumentID: 2, Name: "intro"}}
+	if err := db.Create(&sections).Error; err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	return db
+}
+
+func ownerScope(t *testing.T, owner string) *Scope {
+	t.Helper()
+	meta, err := metadata.AnalyzeEntity(&document{})
+	if err != nil {
+		t.Fatalf("failed to analyze entity: %v", err)
+	}
+	calls := 0
+	scope := NewScope([]*metadata.EntityMetadata{meta}, func(entity *metadata.EntityMetadata) (*query.FilterExpression, error) {
+		calls++
+		if calls > 1 {
+			t.Errorf("filter resolved %d times", calls)
+		}
+		return &query.FilterExpression{Property: "Owner", Operator: query.OpEqual, Value: owner}, nil
+	})
+	return scope
+}
+
+func TestRestrictRows(t *testing.T) {
+	db := openTestDB(t)
+	scope := ownerScope(t, "alice")
+	ctx := WithScope(context.Background(), scope)
+
+	var found []document
+	if err := db.WithContext(ctx).Order("id").Find(&found).Error; err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != 1 || found[1].ID != 3 {
+		t.Errorf("expected alice's documents, got %+v", found)
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(&document{}).Where("title <> ?", "c").Count(&count).Error; err != nil {
+		t.Fatalf("Count() error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected count 1, got %d", count)
+	}
+
+	var doc document
+	if err := db.WithContext(ctx).First(&doc, 2).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected bob's document to be hidden, got %v", err)
+	}
+
+	rows, err := db.WithContext(ctx).Model(&document{}).Rows()
+	if err != nil {
+		t.Fatalf("Rows() error: %v", err)
+	}
+	scanned := 0
+	for rows.Next() {
+		scanned++
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if scanned != 2 {
+		t.Errorf("expected 2 rows from Rows(), got %d", scanned)
+	}
+
+	found = nil
+	if err := db.WithContext(Unrestricted(ctx)).Find(&found).Error; err != nil {
+		t.Fatalf("unrestricted Find() error: %v", err)
+	}
+	if len(found) != 3 {
+		t.Errorf("expected all documents when unrestricted, got %d", len(found))
+	}
+}
+
+func TestRestrictRows_JoinedStatement(t *testing.T) {
+	db := openTestDB(t)
+	scope := ownerScope(t, "alice")
+	ctx := WithScope(context.Background(), scope)
+
+	var found []document
+	err := db.WithContext(ctx).
+		Joins("JOIN sections ON sections.document_id = documents.id").
+		Where("sections.name = ?", "intro").
+		Find(&found).Error
+	if err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != 1 {
+		t.Errorf("expected only alice's document, got %+v", found)
+	}
+}
+
+func TestRestrictRows_FilterError(t *testing.T) {
+	db := openTestDB(t)
+	meta, err := metadata.AnalyzeEntity(&document{})
+	if err != nil {
+		t.Fatalf("failed to analyze entity: %v", err)
+	}
+	scope := NewScope([]*metadata.EntityMetadata{meta}, func(*metadata.EntityMetadata) (*query.FilterExpression, error) {
+		return nil, errors.New("denied")
+	})
+
+	var found []document
+	err = db.WithContext(WithScope(context.Background(), scope)).Find(&found).Error
+	if err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("expected the filter error, got %v", err)
+	}
+}
+
+func TestRestrictRows_IgnoresOtherModels(t *testing.T) {
+	db := openTestDB(t)
+	scope := ownerScope(t, "alice")
+	stmt := db.Session(&gorm.Session{DryRun: true}).WithContext(WithScope(context.Background(), scope)).Find(&[]section{}).Statement
+	if sql := stmt.SQL.String(); sql != "SELECT * FROM `sections`" {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+}
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -115,6 +116,10 @@ func (h *Handler) HandleActionOrFunction(w http.ResponseWriter, r *http.Request,
 			h.writeError(w, r, invErr)
 			return
 		}
+		if err := actions.ValidateParameterConstraints(actionDef.Parameters, params); err != nil {
+			h.writeHandlerError(w, r, err, "Invalid parameters")
+			return
+		}
 
 		// Check authorization before executing action
 		resource := h.buildResourceDescriptor(entitySet, name, isBound)
@@ -125,7 +130,7 @@ func (h *Handler) HandleActionOrFunction(w http.ResponseWriter, r *http.Request,
 		var ctx interface{}
 		if isBound {
 			var ctxErr *invocationError
-			ctx, ctxErr = h.loadBoundContext(entitySet, key)
+			ctx, ctxErr = h.loadBoundContext(r.Context(), entitySet, key, actionDef.IncludeDeleted)
 			if ctxErr != nil {
 				h.writeError(w, r, ctxErr)
 				return
@@ -142,6 +147,10 @@ func (h *Handler) HandleActionOrFunction(w http.ResponseWriter, r *http.Request,
 			h.writeError(w, r, invErr)
 			return
 		}
+		if err := actions.ValidateParameterConstraints(functionDef.Parameters, params); err != nil {
+			h.writeHandlerError(w, r, err, "Invalid parameters")
+			return
+		}
 
 		countRequested := actions.CountRequested(r)
 		if countRequested && !isCollectionReturnType(functionDef.ReturnType) {
@@ -162,7 +171,7 @@ func (h *Handler) HandleActionOrFunction(w http.ResponseWriter, r *http.Request,
 		var ctx interface{}
 		if isBound {
 			var ctxErr *invocationError
-			ctx, ctxErr = h.loadBoundContext(entitySet, key)
+			ctx, ctxErr = h.loadBoundContext(r.Context(), entitySet, key, false)
 			if ctxErr != nil {
 				h.writeError(w, r, ctxErr)
 				return
@@ -184,52 +193,65 @@ func (h *Handler) HandleActionOrFunction(w http.ResponseWriter, r *http.Request,
 			return
 		}
 
-		if !response.IsAcceptableFormat(r) {
-			if writeErr := response.WriteError(w, r, http.StatusNotAcceptable, "Not Acceptable",
-				"The requested format is not supported. Only application/json is supported for data responses."); writeErr != nil {
-				h.logError("Error writing error response", writeErr)
-			}
-			return
+		h.WriteResult(w, r, functionDef.ReturnType, result)
+	default:
+		if writeErr := response.WriteMethodNotAllowed(w, r, "GET, HEAD, POST, OPTIONS", "Method not allowed",
+			fmt.Sprintf("Method %s is not allowed for actions or functions", r.Method)); writeErr != nil {
+			h.logError("Error writing error response", writeErr)
 		}
+	}
+}
 
-		metadataLevel := response.GetODataMetadataLevel(r)
-		w.Header().Set("Content-Type", fmt.Sprintf("application/json;odata.metadata=%s", metadataLevel))
-
-		contextFragment := metadata.FunctionContextFragment(functionDef.ReturnType, h.entities, h.namespace)
-		if contextFragment == "" {
-			contextFragment = "Edm.String"
+// WriteResult serializes the return value of an action or function as an OData
+// response with the @odata.context derived from returnType.
+func (h *Handler) WriteResult(w http.ResponseWriter, r *http.Request, returnType reflect.Type, result interface{}) {
+	if response.IsTabularFormat(r) && isCollectionReturnType(returnType) {
+		if err := response.WriteTabularCollection(w, r, result, nil, nil); err != nil {
+			h.logError("Error writing tabular response", err)
 		}
+		return
+	}
 
-		contextURL := ""
-		if metadataLevel != "none" && contextFragment != "" {
-			contextURL = fmt.Sprintf("%s/$metadata#%s", response.BuildBaseURL(r), contextFragment)
+	if !response.IsAcceptableFormat(r) {
+		if writeErr := response.WriteError(w, r, http.StatusNotAcceptable, "Not Acceptable",
+			"The requested format is not supported. Only application/json is supported for data responses."); writeErr != nil {
+			h.logError("Error writing error response", writeErr)
 		}
+		return
+	}
 
-		odataResponse := response.ODataResponse{
-			Context: contextURL,
-			Value:   result,
-		}
+	metadataLevel := response.GetODataMetadataLevel(r)
+	w.Header().Set("Content-Type", fmt.Sprintf("application/json;odata.metadata=%s", metadataLevel))
 
-		if metadataLevel == "none" {
-			odataResponse.Context = ""
-		}
+	contextFragment := metadata.FunctionContextFragment(returnType, h.entities, h.namespace)
+	if contextFragment == "" {
+		contextFragment = "Edm.String"
+	}
 
-		w.WriteHeader(http.StatusOK)
+	contextURL := ""
+	if metadataLevel != "none" && contextFragment != "" {
+		contextURL = fmt.Sprintf("%s/$metadata#%s", response.BuildBaseURL(r), contextFragment)
+	}
 
-		if r.Method == http.MethodHead {
-			return
-		}
+	odataResponse := response.ODataResponse{
+		Context: contextURL,
+		Value:   result,
+	}
 
-		encoder := json.NewEncoder(w)
-		encoder.SetEscapeHTML(false)
-		if err := encoder.Encode(odataResponse); err != nil {
-			h.logError("Error encoding response", err)
-		}
-	default:
-		if writeErr := response.WriteMethodNotAllowed(w, r, "GET, HEAD, POST, OPTIONS", "Method not allowed",
-			fmt.Sprintf("Method %s is not allowed for actions or functions", r.Method)); writeErr != nil {
-			h.logError("Error writing error response", writeErr)
-		}
+	if metadataLevel == "none" {
+		odataResponse.Context = ""
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(odataResponse); err != nil {
+		h.logError("Error encoding response", err)
 	}
 }
 
@@ -367,7 +389,7 @@ func (h *Handler) buildResourceDescriptor(entitySet string, name string, isBound
 	return resource
 }
 
-func (h *Handler) loadBoundContext(entitySet, key string) (interface{}, *invocationError) {
+func (h *Handler) loadBoundContext(ctx context.Context, entitySet, key string, includeDeleted bool) (interface{}, *invocationError) {
 	if key == "" {
 		return nil, nil
 	}
@@ -380,7 +402,11 @@ func (h *Handler) loadBoundContext(entitySet, key string) (interface{}, *invocat
 		}
 	}
 
-	entity, err := handler.FetchEntity(key)
+	fetch := handler.FetchEntity
+	if includeDeleted {
+		fetch = handler.FetchEntityIncludingDeleted
+	}
+	entity, err := fetch(ctx, key)
 	if err != nil {
 		if handlers.IsNotFoundError(err) {
 			return nil, &invocationError{
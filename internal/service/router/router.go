@@ -1,6 +1,7 @@
 package router
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -38,8 +39,8 @@ type EntityHandler interface {
 	IsStructuralProperty(string) bool
 	IsComplexTypeProperty(string) bool
 	NavigationTargetSet(string) (string, bool)
-	FetchEntity(string) (interface{}, error)
-	FetchNavEntityKey(entityKey, navPropName string) (string, error)
+	FetchEntity(context.Context, string) (interface{}, error)
+	FetchNavEntityKey(ctx context.Context, entityKey, navPropName string) (string, error)
 }
 
 // HandlerResolver resolves an entity handler for the given entity set.
@@ -419,13 +420,17 @@ func (r *Router) tryServeAsyncMonitor(w http.ResponseWriter, req *http.Request)
 	}
 
 	path := req.URL.Path
+	if path+"/" == prefix {
+		// The job listing is also served without the trailing slash.
+		path = prefix
+	}
 	if !strings.HasPrefix(path, prefix) {
 		return false
 	}
 
 	suffix := strings.TrimPrefix(path, prefix)
 	if suffix == "" {
-		http.NotFound(w, req)
+		manager.ServeJobList(w, req)
 		return true
 	}
 	if strings.Contains(suffix, "/") {
@@ -668,7 +673,7 @@ func (r *Router) handlePropertyRequest(w http.ResponseWriter, req *http.Request,
 			targetEntitySet := r.getNavigationTargetEntitySet(handler, firstSegment)
 			targetHandler, targetExists := r.resolveHandler(targetEntitySet)
 			if targetExists {
-				intermediateKey, err := handler.FetchNavEntityKey(keyString, firstSegment)
+				intermediateKey, err := handler.FetchNavEntityKey(req.Context(), keyString, firstSegment)
 				if err != nil {
 					statusCode := http.StatusInternalServerError
 					if handlers.IsNotFoundError(err) {
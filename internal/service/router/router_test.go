@@ -14,7 +14,7 @@ import (
 	"gorm.io/gorm"
 )
 
-func newTestAsyncManager(t *testing.T) *async.Manager {
+func newTestAsyncManager(t *testing.T, opts ...async.ManagerOption) *async.Manager {
 	t.Helper()
 	// Use file-based database for better concurrency support
 	dbPath := filepath.Join(t.TempDir(), "test.db")
@@ -22,7 +22,7 @@ func newTestAsyncManager(t *testing.T) *async.Manager {
 	if err != nil {
 		t.Fatalf("failed to open database: %v", err)
 	}
-	mgr, err := async.NewManager(db, 0)
+	mgr, err := async.NewManager(db, 0, opts...)
 	if err != nil {
 		t.Fatalf("failed to create async manager: %v", err)
 	}
@@ -127,9 +127,11 @@ func (h *stubEntityHandler) IsComplexTypeProperty(name string) bool {
 	return h.complexProps[name]
 }
 
-func (h *stubEntityHandler) FetchEntity(string) (interface{}, error) { return nil, nil }
+func (h *stubEntityHandler) FetchEntity(context.Context, string) (interface{}, error) {
+	return nil, nil
+}
 
-func (h *stubEntityHandler) FetchNavEntityKey(entityKey, navPropName string) (string, error) {
+func (h *stubEntityHandler) FetchNavEntityKey(_ context.Context, entityKey, navPropName string) (string, error) {
 	if h.fetchNavEntityKeyFn != nil {
 		return h.fetchNavEntityKeyFn(entityKey, navPropName)
 	}
@@ -329,6 +331,23 @@ func TestRouter_AsyncMonitorInvalidPaths(t *testing.T) {
 	}
 }
 
+func TestRouter_AsyncJobList(t *testing.T) {
+	mgr := newTestAsyncManager(t, async.WithJobListAuthorizer(func(*http.Request) bool { return true }))
+
+	router := newTestRouter(nil, nil, nil, func(http.ResponseWriter, *http.Request, string, string, bool, string) {})
+	router.SetAsyncMonitor("/$async/jobs", mgr)
+
+	for _, target := range []string{"/$async/jobs/", "/$async/jobs"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK || rec.Body.String() != `{"value":[]}` {
+			t.Errorf("GET %s = %d %q, want an empty job list", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
 func TestRouter_ServiceDocument(t *testing.T) {
 	called := false
 	r := NewRouter(
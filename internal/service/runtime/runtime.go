@@ -32,6 +32,9 @@ type Runtime struct {
 	asyncQueue           chan struct{}
 	asyncMonitorPrefix   string
 	defaultRetryInterval time.Duration
+	asyncRecoveryPolicy  func(*http.Request) async.RecoveryPolicy
+	asyncReplayIdentity  func(*http.Request) string
+	asyncRestoreIdentity func(*http.Request, string) (context.Context, error)
 
 	// observability holds the OpenTelemetry configuration
 	observability *observability.Config
@@ -78,6 +81,58 @@ func (rt *Runtime) ConfigureAsync(manager *async.Manager, queue chan struct{}, m
 	rt.defaultRetryInterval = defaultRetryInterval
 }
 
+// SetAsyncRecoveryPolicy configures how jobs started for a request are handled
+// when the service restarts before they finish. Requests for which policy
+// returns async.RecoverRequeue are stored with their job so they can be replayed.
+func (rt *Runtime) SetAsyncRecoveryPolicy(policy func(*http.Request) async.RecoveryPolicy) {
+	rt.asyncRecoveryPolicy = policy
+}
+
+// SetAsyncReplayIdentity configures how replayed requests act on behalf of
+// their caller. capture names the caller of a request whose job may be
+// replayed, in a form that is stored with the job; restore returns the context
+// under which the replayed request runs. Credential headers are never stored,
+// so without restore replayed requests carry no identity.
+func (rt *Runtime) SetAsyncReplayIdentity(capture func(*http.Request) string, restore func(*http.Request, string) (context.Context, error)) {
+	rt.asyncReplayIdentity = capture
+	rt.asyncRestoreIdentity = restore
+}
+
+// AsyncReplayHandler returns the factory that re-runs stored async requests
+// after a restart. serve handles each rebuilt request like a new synchronous
+// request, including the pre-request hook, under the identity restored by the
+// hook set with SetAsyncReplayIdentity. Replayed jobs wait for a free queue
+// slot instead of falling back to synchronous execution.
+func (rt *Runtime) AsyncReplayHandler(serve http.HandlerFunc) async.HandlerFactory {
+	return func(stored *async.StoredRequest) async.Handler {
+		return func(ctx context.Context) (*async.StoredResponse, error) {
+			token, err := rt.waitAsyncSlot(ctx)
+			if err != nil {
+				return nil, err
+			}
+			defer token.release()
+
+			req, err := stored.NewRequest(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if rt.asyncRestoreIdentity != nil {
+				identityCtx, err := rt.asyncRestoreIdentity(req, stored.Identity)
+				if err != nil {
+					return nil, err
+				}
+				if identityCtx != nil {
+					req = req.WithContext(identityCtx)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			serve(recorder, req)
+			return recordedResponse(recorder), nil
+		}
+	}
+}
+
 // ServeHTTP dispatches the request via the configured router.
 func (rt *Runtime) ServeHTTP(w http.ResponseWriter, r *http.Request, allowAsync bool) {
 	if rt.router == nil {
@@ -210,17 +265,30 @@ func (rt *Runtime) tryHandleAsync(w http.ResponseWriter, r *http.Request) bool {
 		recorder := httptest.NewRecorder()
 		rt.router.ServeHTTP(recorder, cloned)
 
-		return &async.StoredResponse{
-			StatusCode: recorder.Code,
-			Header:     cloneHeader(recorder.Header()),
-			Body:       append([]byte(nil), recorder.Body.Bytes()...),
-		}, nil
+		return recordedResponse(recorder), nil
 	}
 
 	jobOpts := []async.JobOption{}
 	if rt.defaultRetryInterval > 0 {
 		jobOpts = append(jobOpts, async.WithRetryAfter(rt.defaultRetryInterval))
 	}
+	if rt.asyncRecoveryPolicy != nil {
+		if policy := rt.asyncRecoveryPolicy(r); policy != "" {
+			var stored *async.StoredRequest
+			if policy == async.RecoverRequeue {
+				stored = async.StoredRequestFrom(r, body)
+				if sanitizedPrefer == "" {
+					stored.Header.Del("Prefer")
+				} else {
+					stored.Header.Set("Prefer", sanitizedPrefer)
+				}
+				if rt.asyncReplayIdentity != nil {
+					stored.Identity = rt.asyncReplayIdentity(r)
+				}
+			}
+			jobOpts = append(jobOpts, async.WithRecovery(policy, stored))
+		}
+	}
 
 	job, err := rt.asyncManager.StartJob(context.WithoutCancel(r.Context()), handler, jobOpts...)
 	if err != nil {
@@ -268,6 +336,29 @@ func (rt *Runtime) acquireAsyncSlot() *queueToken {
 	}
 }
 
+// waitAsyncSlot blocks until a queue slot is free or ctx is done.
+func (rt *Runtime) waitAsyncSlot(ctx context.Context) (*queueToken, error) {
+	queue := rt.asyncQueue
+	if queue == nil {
+		return &queueToken{}, nil
+	}
+
+	select {
+	case queue <- struct{}{}:
+		return &queueToken{ch: queue}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func recordedResponse(recorder *httptest.ResponseRecorder) *async.StoredResponse {
+	return &async.StoredResponse{
+		StatusCode: recorder.Code,
+		Header:     cloneHeader(recorder.Header()),
+		Body:       append([]byte(nil), recorder.Body.Bytes()...),
+	}
+}
+
 func bufferRequestBody(r *http.Request) ([]byte, error) {
 	if r.Body == nil {
 		return nil, nil
@@ -334,6 +425,23 @@ func (r *statusRecorder) Write(b []byte) (int, error) {
 	return r.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying ResponseWriter so http.ResponseController can
+// reach optional interfaces of the wrapped writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Flush implements http.Flusher so streaming responses are not held back by
+// the recorder.
+func (r *statusRecorder) Flush() {
+	if !r.written {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // serverTimingResponseWriter is a non-buffering ResponseWriter wrapper that injects the
 // Server-Timing header just before the status line is committed to the client.
 // Writes are passed straight through, preserving streaming and chunked-transfer behaviour.
@@ -0,0 +1,103 @@
+// Package softdelete hides soft-deleted rows from GORM reads. An entity opts in
+// by tagging a nullable timestamp field with `odata:"softdelete"`; a row whose
+// column is set counts as deleted. The callbacks registered by Register add a
+// "column IS NULL" predicate to every query and row scan of such a model unless
+// the statement is Unscoped, so navigation, $expand and hook-initiated reads
+// exclude deleted rows just like top-level collection reads.
+package softdelete
+
+import (
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	// TagValue is the odata struct tag option marking the soft delete field.
+	TagValue = "softdelete"
+
+	callbackName = "odata:soft_delete"
+	// appliedClause marks statements that already carry the predicate, so a
+	// statement executed twice (e.g. Count followed by Find) gets it once.
+	appliedClause = "odata:soft_delete_applied"
+)
+
+// fields caches the soft delete field per parsed schema; schemas without one
+// map to a nil field.
+var fields sync.Map // map[*schema.Schema]*schema.Field
+
+// Register installs the soft delete query and row callbacks on db. It is safe
+// to call more than once; later calls are no-ops.
+func Register(db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	if db.Callback().Query().Get(callbackName) == nil {
+		if err := db.Callback().Query().Before("gorm:query").Register(callbackName, excludeDeleted); err != nil {
+			return err
+		}
+	}
+	if db.Callback().Row().Get(callbackName) == nil {
+		if err := db.Callback().Row().Before("gorm:row").Register(callbackName, excludeDeleted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasTag reports whether an odata struct tag marks its field as the soft
+// delete field.
+func HasTag(tag string) bool {
+	for _, part := range strings.Split(tag, ",") {
+		if strings.TrimSpace(part) == TagValue {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the soft delete field of a parsed schema, or nil.
+func Field(sch *schema.Schema) *schema.Field {
+	if sch == nil {
+		return nil
+	}
+	if cached, ok := fields.Load(sch); ok {
+		return cached.(*schema.Field)
+	}
+	var found *schema.Field
+	for _, field := range sch.Fields {
+		if field.DBName != "" && HasTag(field.Tag.Get("odata")) {
+			found = field
+			break
+		}
+	}
+	fields.Store(sch, found)
+	return found
+}
+
+// Deleted returns a condition matching the soft-deleted rows of the current
+// table.
+func Deleted(column string) clause.Expression {
+	return clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: nil}
+}
+
+func excludeDeleted(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Unscoped || stmt.SQL.Len() > 0 {
+		return
+	}
+	if _, ok := stmt.Clauses[appliedClause]; ok {
+		return
+	}
+	field := Field(stmt.Schema)
+	if field == nil {
+		return
+	}
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil},
+	}})
+	stmt.Clauses[appliedClause] = clause.Clause{}
+}
@@ -0,0 +1,116 @@
+package softdelete
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID        uint `gorm:"primaryKey"`
+	Text      string
+	DeletedAt *time.Time `odata:"nullable,softdelete"`
+}
+
+type plainNote struct {
+	ID   uint `gorm:"primaryKey"`
+	Text string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&note{}, &plainNote{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatalf("Register() error: %v", err)
+	}
+	if err := Register(db); err != nil {
+		t.Fatalf("second Register() error: %v", err)
+	}
+	return db
+}
+
+func TestHasTag(t *testing.T) {
+	for tag, want := range map[string]bool{
+		"softdelete":           true,
+		"nullable, softdelete": true,
+		"key":                  false,
+		"":                     false,
+		"softdeleted":          false,
+	} {
+		if got := HasTag(tag); got != want {
+			t.Errorf("HasTag(%q) = %v, want %v", tag, got, want)
+		}
+	}
+}
+
+func TestExcludeDeleted(t *testing.T) {
+	db := openTestDB(t)
+	deletedAt := time.Now()
+	notes := []note{{ID: 1, Text: "live"}, {ID: 2, Text: "gone", DeletedAt: &deletedAt}}
+	if err := db.Create(&notes).Error; err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+
+	var found []note
+	if err := db.Find(&found).Error; err != nil {
+		t.Fatalf("Find() error: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != 1 {
+		t.Errorf("expected only the live note, got %+v", found)
+	}
+
+	var count int64
+	if err := db.Model(&note{}).Count(&count).Error; err != nil {
+		t.Fatalf("Count() error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected count 1, got %d", count)
+	}
+
+	rows, err := db.Model(&note{}).Rows()
+	if err != nil {
+		t.Fatalf("Rows() error: %v", err)
+	}
+	scanned := 0
+	for rows.Next() {
+		scanned++
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if scanned != 1 {
+		t.Errorf("expected 1 row from Rows(), got %d", scanned)
+	}
+
+	found = nil
+	if err := db.Unscoped().Find(&found).Error; err != nil {
+		t.Fatalf("Unscoped Find() error: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected both notes when unscoped, got %d", len(found))
+	}
+
+	found = nil
+	if err := db.Unscoped().Where(Deleted("deleted_at")).Find(&found).Error; err != nil {
+		t.Fatalf("Find() deleted error: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != 2 {
+		t.Errorf("expected only the deleted note, got %+v", found)
+	}
+}
+
+func TestExcludeDeleted_IgnoresOtherModels(t *testing.T) {
+	db := openTestDB(t)
+	stmt := db.Session(&gorm.Session{DryRun: true}).Find(&[]plainNote{}).Statement
+	if sql := stmt.SQL.String(); sql != "SELECT * FROM `plain_notes`" {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+}
@@ -33,7 +33,7 @@ func TestStoreTransactionCommitAndRollback(t *testing.T) {
 	t.Run("commit", func(t *testing.T) {
 		s := newTestStore(t)
 		if err := s.Transaction(ctx, func(tx storage.Tx) error {
-			return tx.DB().Create(&txTestEntity{ID: 1, Name: "ok"}).Error
+			return tx.(storage.GORMTx).DB().Create(&txTestEntity{ID: 1, Name: "ok"}).Error
 		}); err != nil {
 			t.Fatalf("transaction: %v", err)
 		}
@@ -49,7 +49,7 @@ func TestStoreTransactionCommitAndRollback(t *testing.T) {
 	t.Run("rollback", func(t *testing.T) {
 		s := newTestStore(t)
 		_ = s.Transaction(ctx, func(tx storage.Tx) error {
-			if err := tx.DB().Create(&txTestEntity{ID: 2, Name: "rollback"}).Error; err != nil {
+			if err := tx.(storage.GORMTx).DB().Create(&txTestEntity{ID: 2, Name: "rollback"}).Error; err != nil {
 				return err
 			}
 			return errors.New("force rollback")
@@ -0,0 +1,447 @@
+package memstore
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/storage"
+)
+
+// evaluate reports whether record satisfies the parsed $filter expression.
+// Constructs the evaluator does not implement yield storage.ErrUnsupportedQuery
+// rather than a silently wrong result.
+func (s *Store) evaluate(record reflect.Value, expr *query.FilterExpression) (bool, error) {
+	if expr == nil {
+		return true, nil
+	}
+	result, err := s.evaluateNode(record, expr)
+	if err != nil {
+		return false, err
+	}
+	if expr.IsNot {
+		return !result, nil
+	}
+	return result, nil
+}
+
+func (s *Store) evaluateNode(record reflect.Value, expr *query.FilterExpression) (bool, error) {
+	if expr.Left != nil && expr.Right != nil {
+		left, err := s.evaluate(record, expr.Left)
+		if err != nil {
+			return false, err
+		}
+		switch expr.Logical {
+		case query.LogicalAnd:
+			if !left {
+				return false, nil
+			}
+			return s.evaluate(record, expr.Right)
+		case query.LogicalOr:
+			if left {
+				return true, nil
+			}
+			return s.evaluate(record, expr.Right)
+		default:
+			return false, unsupported("logical operator %q", expr.Logical)
+		}
+	}
+
+	switch expr.Operator {
+	case query.OpEqual, query.OpNotEqual,
+		query.OpGreaterThan, query.OpGreaterThanOrEqual,
+		query.OpLessThan, query.OpLessThanOrEqual:
+		left, err := s.leftOperand(record, expr)
+		if err != nil {
+			return false, err
+		}
+		return compare(left, expr.Operator, expr.Value), nil
+
+	case query.OpIn:
+		left, err := s.leftOperand(record, expr)
+		if err != nil {
+			return false, err
+		}
+		values, ok := expr.Value.([]interface{})
+		if !ok {
+			return false, unsupported("in operand %T", expr.Value)
+		}
+		for _, v := range values {
+			if compare(left, query.OpEqual, v) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case query.OpContains, query.OpStartsWith, query.OpEndsWith:
+		left, err := s.leftOperand(record, expr)
+		if err != nil {
+			return false, err
+		}
+		needle, ok := expr.Value.(string)
+		if !ok {
+			return false, unsupported("%s argument %T", expr.Operator, expr.Value)
+		}
+		subject, ok := left.(string)
+		if !ok {
+			return false, nil
+		}
+		switch expr.Operator {
+		case query.OpContains:
+			return strings.Contains(subject, needle), nil
+		case query.OpStartsWith:
+			return strings.HasPrefix(subject, needle), nil
+		default:
+			return strings.HasSuffix(subject, needle), nil
+		}
+
+	default:
+		return false, unsupported("filter operator %q", expr.Operator)
+	}
+}
+
+// leftOperand resolves the left-hand side of a predicate: either the value
+// computed by the function or arithmetic node in Left, or a property path.
+func (s *Store) leftOperand(record reflect.Value, expr *query.FilterExpression) (interface{}, error) {
+	if expr.Left != nil {
+		return s.operandValue(record, expr.Left)
+	}
+	return s.propertyValue(record, expr.Property)
+}
+
+// operandValue computes the value of a function or arithmetic node.
+func (s *Store) operandValue(record reflect.Value, node *query.FilterExpression) (interface{}, error) {
+	switch node.Operator {
+	case query.OpAdd, query.OpSub, query.OpMul, query.OpDiv, query.OpDivBy, query.OpMod:
+		return s.arithmeticValue(record, node)
+	}
+
+	var arg interface{}
+	var err error
+	if node.Left != nil {
+		arg, err = s.operandValue(record, node.Left)
+	} else {
+		arg, err = s.propertyValue(record, node.Property)
+	}
+	if err != nil || arg == nil {
+		return nil, err
+	}
+
+	switch node.Operator {
+	case query.OpToLower:
+		return stringFunc(arg, strings.ToLower)
+	case query.OpToUpper:
+		return stringFunc(arg, strings.ToUpper)
+	case query.OpTrim:
+		return stringFunc(arg, strings.TrimSpace)
+	case query.OpLength:
+		str, ok := arg.(string)
+		if !ok {
+			return nil, unsupported("length of %T", arg)
+		}
+		return float64(utf8.RuneCountInString(str)), nil
+	case query.OpCeiling, query.OpFloor, query.OpRound:
+		f, ok := toFloat(arg)
+		if !ok {
+			return nil, unsupported("%s of %T", node.Operator, arg)
+		}
+		switch node.Operator {
+		case query.OpCeiling:
+			return math.Ceil(f), nil
+		case query.OpFloor:
+			return math.Floor(f), nil
+		default:
+			return math.Round(f), nil
+		}
+	case query.OpYear, query.OpMonth, query.OpDay, query.OpHour, query.OpMinute, query.OpSecond:
+		t, ok := arg.(time.Time)
+		if !ok {
+			return nil, unsupported("%s of %T", node.Operator, arg)
+		}
+		switch node.Operator {
+		case query.OpYear:
+			return float64(t.Year()), nil
+		case query.OpMonth:
+			return float64(t.Month()), nil
+		case query.OpDay:
+			return float64(t.Day()), nil
+		case query.OpHour:
+			return float64(t.Hour()), nil
+		case query.OpMinute:
+			return float64(t.Minute()), nil
+		default:
+			return float64(t.Second()), nil
+		}
+	default:
+		return nil, unsupported("function %q", node.Operator)
+	}
+}
+
+func (s *Store) arithmeticValue(record reflect.Value, node *query.FilterExpression) (interface{}, error) {
+	var left, right interface{}
+	var err error
+	if node.Left != nil {
+		left, err = s.operandValue(record, node.Left)
+	} else {
+		left, err = s.propertyValue(record, node.Property)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case node.Right != nil:
+		right, err = s.operandValue(record, node.Right)
+	case isString(node.Value):
+		// Arithmetic is never applied to string literals, so a string operand names a property.
+		right, err = s.propertyValue(record, node.Value.(string))
+	default:
+		right = node.Value
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l, lok := toFloat(left)
+	r, rok := toFloat(right)
+	if !lok || !rok {
+		return nil, nil
+	}
+	switch node.Operator {
+	case query.OpAdd:
+		return l + r, nil
+	case query.OpSub:
+		return l - r, nil
+	case query.OpMul:
+		return l * r, nil
+	case query.OpMod:
+		if r == 0 {
+			return nil, nil
+		}
+		return math.Mod(l, r), nil
+	case query.OpDiv:
+		if r == 0 {
+			return nil, nil
+		}
+		if isIntegral(left) && isIntegral(right) {
+			return math.Trunc(l / r), nil
+		}
+		return l / r, nil
+	default:
+		if r == 0 {
+			return nil, nil
+		}
+		return l / r, nil
+	}
+}
+
+// propertyValue returns the value at a property path ("Name" or
+// "Address/City"), dereferencing pointers. A nil pointer yields nil.
+func (s *Store) propertyValue(record reflect.Value, path string) (interface{}, error) {
+	segments := strings.Split(path, "/")
+	prop := s.meta.FindProperty(segments[0])
+	if prop == nil || prop.IsNavigationProp {
+		return nil, unsupported("property %q", path)
+	}
+
+	current := record.FieldByName(prop.FieldName)
+	for _, segment := range segments[1:] {
+		current = indirect(current)
+		if !current.IsValid() {
+			return nil, nil
+		}
+		if current.Kind() != reflect.Struct {
+			return nil, unsupported("property %q", path)
+		}
+		field, ok := fieldByJSONName(current, segment)
+		if !ok {
+			return nil, unsupported("property %q", path)
+		}
+		current = field
+	}
+
+	current = indirect(current)
+	if !current.IsValid() || !current.CanInterface() {
+		return nil, nil
+	}
+	return current.Interface(), nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func fieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(jsonName, name) || strings.EqualFold(field.Name, name) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func stringFunc(arg interface{}, fn func(string) string) (interface{}, error) {
+	str, ok := arg.(string)
+	if !ok {
+		return nil, unsupported("string function on %T", arg)
+	}
+	return fn(str), nil
+}
+
+func unsupported(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", storage.ErrUnsupportedQuery, fmt.Sprintf(format, args...))
+}
+
+// compare applies a comparison operator. Null only equals null and is not
+// ordered against anything, matching OData's three-valued comparison rules.
+func compare(left interface{}, op query.FilterOperator, right interface{}) bool {
+	if left == nil || right == nil {
+		switch op {
+		case query.OpEqual:
+			return left == nil && right == nil
+		case query.OpNotEqual:
+			return (left == nil) != (right == nil)
+		default:
+			return false
+		}
+	}
+
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return op == query.OpNotEqual
+	}
+	switch op {
+	case query.OpEqual:
+		return cmp == 0
+	case query.OpNotEqual:
+		return cmp != 0
+	case query.OpGreaterThan:
+		return cmp > 0
+	case query.OpGreaterThanOrEqual:
+		return cmp >= 0
+	case query.OpLessThan:
+		return cmp < 0
+	case query.OpLessThanOrEqual:
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// compareForSort orders values for $orderby; nulls sort first.
+func compareForSort(left, right interface{}) int {
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil:
+		return -1
+	case right == nil:
+		return 1
+	}
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return strings.Compare(fmt.Sprint(left), fmt.Sprint(right))
+	}
+	return cmp
+}
+
+// compareValues compares two non-nil values of compatible kinds. Numbers of
+// any Go type compare numerically; time.Time compares against time.Time or an
+// ISO 8601 literal.
+func compareValues(left, right interface{}) (int, bool) {
+	if lt, ok := left.(time.Time); ok {
+		rt, ok := toTime(right)
+		if !ok {
+			return 0, false
+		}
+		return lt.Compare(rt), true
+	}
+	if lf, ok := toFloat(left); ok {
+		rf, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case lf < rf:
+			return -1, true
+		case lf > rf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	lv, rv := reflect.ValueOf(left), reflect.ValueOf(right)
+	switch {
+	case lv.Kind() == reflect.Bool && rv.Kind() == reflect.Bool:
+		lb, rb := lv.Bool(), rv.Bool()
+		switch {
+		case lb == rb:
+			return 0, true
+		case !lb:
+			return -1, true
+		default:
+			return 1, true
+		}
+	case lv.Kind() == reflect.String && rv.Kind() == reflect.String:
+		return strings.Compare(lv.String(), rv.String()), true
+	}
+	return strings.Compare(fmt.Sprint(left), fmt.Sprint(right)), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func isIntegral(v interface{}) bool {
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
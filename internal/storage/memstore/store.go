@@ -0,0 +1,412 @@
+// Package memstore provides an in-memory storage.EntityStore. It evaluates the
+// parsed OData query options ($filter, $orderby, $top, $skip, $search, $count)
+// directly against Go values and serves as the reference implementation for
+// services backed by non-SQL sources.
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/storage"
+)
+
+// Store keeps the records of one entity set in memory. It is safe for
+// concurrent use. Records are copied on the way in and out, so callers never
+// share memory with the stored values (copies are shallow: slices, maps and
+// pointers inside a record are shared).
+type Store struct {
+	meta       *metadata.EntityMetadata
+	entityType reflect.Type
+
+	mu      sync.RWMutex
+	records map[string]reflect.Value
+	lastID  int64
+}
+
+// New creates an empty store for the entity type of entity (a struct or a
+// pointer to a struct, tagged like any other OData entity).
+func New(entity interface{}) (*Store, error) {
+	meta, err := metadata.AnalyzeVirtualEntity(entity)
+	if err != nil {
+		return nil, fmt.Errorf("memstore: %w", err)
+	}
+	if len(meta.KeyProperties) == 0 {
+		return nil, fmt.Errorf("memstore: entity %s has no key properties", meta.EntityName)
+	}
+	return &Store{
+		meta:       meta,
+		entityType: meta.EntityType,
+		records:    make(map[string]reflect.Value),
+	}, nil
+}
+
+// Query returns the page of records selected by q.
+func (s *Store) Query(ctx context.Context, q storage.Query) (*storage.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	opts := optionsOf(q)
+	if len(opts.Apply) > 0 || opts.Compute != nil {
+		return nil, fmt.Errorf("%w: $apply and $compute are not supported", storage.ErrUnsupportedQuery)
+	}
+
+	matched, err := s.match(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sortRecords(matched, opts.OrderBy); err != nil {
+		return nil, err
+	}
+
+	result := &storage.Result{}
+	if opts.Count {
+		total := int64(len(matched))
+		result.Count = &total
+	}
+
+	if opts.Skip != nil && *opts.Skip > 0 {
+		if *opts.Skip >= len(matched) {
+			matched = nil
+		} else {
+			matched = matched[*opts.Skip:]
+		}
+	}
+	if opts.Top != nil && *opts.Top >= 0 && *opts.Top < len(matched) {
+		matched = matched[:*opts.Top]
+	}
+
+	items := reflect.MakeSlice(reflect.SliceOf(s.entityType), 0, len(matched))
+	for _, record := range matched {
+		items = reflect.Append(items, record)
+	}
+	result.Items = items.Interface()
+	return result, nil
+}
+
+// Count returns the number of records matching the filter and search of q.
+func (s *Store) Count(ctx context.Context, q storage.Query) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	matched, err := s.match(optionsOf(q))
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(matched)), nil
+}
+
+// Get returns a copy of the record identified by key.
+func (s *Store) Get(ctx context.Context, key map[string]interface{}, _ storage.Query) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	id, err := s.keyFromMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	record, ok := s.records[id]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return s.copyOut(record), nil
+}
+
+// Create stores a copy of entity. A single integer key left at its zero value
+// is assigned the next free value, mirroring an auto-increment column.
+func (s *Store) Create(ctx context.Context, entity interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	record, err := s.copyIn(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.assignKey(record)
+	id := s.keyFromRecord(record)
+	if _, exists := s.records[id]; exists {
+		return nil, fmt.Errorf("%w: %s with key %s already exists", storage.ErrConflict, s.meta.EntityName, id)
+	}
+	s.records[id] = record
+	s.trackKey(record)
+	return s.copyOut(record), nil
+}
+
+// Update applies changes to the record identified by key. Key properties
+// cannot be changed.
+func (s *Store) Update(ctx context.Context, key map[string]interface{}, changes map[string]interface{}, replace bool) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	id, err := s.keyFromMap(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.records[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+
+	updated, err := s.applyChanges(current, changes, replace)
+	if err != nil {
+		return nil, err
+	}
+	s.records[id] = updated
+	return s.copyOut(updated), nil
+}
+
+// Delete removes the record identified by key.
+func (s *Store) Delete(ctx context.Context, key map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	id, err := s.keyFromMap(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[id]; !ok {
+		return storage.ErrNotFound
+	}
+	delete(s.records, id)
+	return nil
+}
+
+// Len returns the number of stored records.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+func optionsOf(q storage.Query) *query.QueryOptions {
+	if q.Options == nil {
+		return &query.QueryOptions{}
+	}
+	return q.Options
+}
+
+// match returns the records satisfying the filter and search options, in no
+// particular order.
+func (s *Store) match(opts *query.QueryOptions) ([]reflect.Value, error) {
+	s.mu.RLock()
+	snapshot := make([]reflect.Value, 0, len(s.records))
+	for _, record := range s.records {
+		snapshot = append(snapshot, record)
+	}
+	s.mu.RUnlock()
+
+	matched := snapshot[:0]
+	for _, record := range snapshot {
+		ok, err := s.evaluate(record, opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, record)
+		}
+	}
+
+	if opts.Search != "" && len(matched) > 0 {
+		items := reflect.MakeSlice(reflect.SliceOf(s.entityType), 0, len(matched))
+		for _, record := range matched {
+			items = reflect.Append(items, record)
+		}
+		found := reflect.ValueOf(query.ApplySearch(items.Interface(), opts.Search, s.meta))
+		matched = matched[:0]
+		for i := 0; i < found.Len(); i++ {
+			matched = append(matched, found.Index(i))
+		}
+	}
+	return matched, nil
+}
+
+// sortRecords orders records by the $orderby items, breaking ties (and
+// ordering unsorted queries) by key so paging is deterministic.
+func (s *Store) sortRecords(records []reflect.Value, orderBy []query.OrderByItem) error {
+	paths := make([]string, 0, len(orderBy)+len(s.meta.KeyProperties))
+	descending := make([]bool, 0, cap(paths))
+	for _, item := range orderBy {
+		if s.meta.FindProperty(strings.Split(item.Property, "/")[0]) == nil {
+			return fmt.Errorf("%w: cannot order by %q", storage.ErrUnsupportedQuery, item.Property)
+		}
+		paths = append(paths, item.Property)
+		descending = append(descending, item.Descending)
+	}
+	for _, key := range s.meta.KeyProperties {
+		paths = append(paths, key.JsonName)
+		descending = append(descending, false)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		for n, path := range paths {
+			left, _ := s.propertyValue(records[i], path)
+			right, _ := s.propertyValue(records[j], path)
+			cmp := compareForSort(left, right)
+			if cmp == 0 {
+				continue
+			}
+			if descending[n] {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	return nil
+}
+
+// copyIn converts entity into a freshly allocated struct value of the store's type.
+func (s *Store) copyIn(entity interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("memstore: nil %s", s.meta.EntityName)
+		}
+		v = v.Elem()
+	}
+	if v.Type() != s.entityType {
+		return reflect.Value{}, fmt.Errorf("memstore: expected %s, got %s", s.entityType, v.Type())
+	}
+	record := reflect.New(s.entityType).Elem()
+	record.Set(v)
+	return record, nil
+}
+
+// copyOut returns a pointer to a copy of record.
+func (s *Store) copyOut(record reflect.Value) interface{} {
+	out := reflect.New(s.entityType)
+	out.Elem().Set(record)
+	return out.Interface()
+}
+
+// assignKey fills a zero-valued single integer key with the next free value.
+func (s *Store) assignKey(record reflect.Value) {
+	if len(s.meta.KeyProperties) != 1 {
+		return
+	}
+	field := record.FieldByName(s.meta.KeyProperties[0].FieldName)
+	if !field.IsValid() || !field.CanSet() || !field.IsZero() {
+		return
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(s.lastID + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		field.SetUint(uint64(s.lastID + 1))
+	}
+}
+
+// trackKey remembers the highest integer key seen so generated keys never collide.
+func (s *Store) trackKey(record reflect.Value) {
+	if len(s.meta.KeyProperties) != 1 {
+		return
+	}
+	field := record.FieldByName(s.meta.KeyProperties[0].FieldName)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if field.Int() > s.lastID {
+			s.lastID = field.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if int64(field.Uint()) > s.lastID {
+			s.lastID = int64(field.Uint())
+		}
+	}
+}
+
+func (s *Store) keyFromRecord(record reflect.Value) string {
+	parts := make([]string, len(s.meta.KeyProperties))
+	for i, key := range s.meta.KeyProperties {
+		parts[i] = fmt.Sprint(record.FieldByName(key.FieldName).Interface())
+	}
+	return strings.Join(parts, ",")
+}
+
+// keyFromMap builds the record identifier from a key map whose entries may be
+// named by JSON or Go field name. Values are compared by their textual form, so
+// 42, int64(42) and "42" address the same record.
+func (s *Store) keyFromMap(key map[string]interface{}) (string, error) {
+	parts := make([]string, len(s.meta.KeyProperties))
+	for i, prop := range s.meta.KeyProperties {
+		value, ok := lookupKey(key, prop)
+		if !ok {
+			return "", fmt.Errorf("memstore: missing key property %s", prop.JsonName)
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func lookupKey(key map[string]interface{}, prop metadata.PropertyMetadata) (interface{}, bool) {
+	if v, ok := key[prop.JsonName]; ok {
+		return v, true
+	}
+	if v, ok := key[prop.Name]; ok {
+		return v, true
+	}
+	for name, v := range key {
+		if strings.EqualFold(name, prop.JsonName) || strings.EqualFold(name, prop.Name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// applyChanges merges changes into a copy of current through the entity's JSON
+// representation, so property names and value conversions follow the same
+// rules as request bodies. Key properties always keep their current values.
+func (s *Store) applyChanges(current reflect.Value, changes map[string]interface{}, replace bool) (reflect.Value, error) {
+	var merged map[string]interface{}
+	if replace {
+		merged = make(map[string]interface{}, len(changes))
+	} else {
+		data, err := json.Marshal(current.Interface())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("memstore: %w", err)
+		}
+		if err := json.Unmarshal(data, &merged); err != nil {
+			return reflect.Value{}, fmt.Errorf("memstore: %w", err)
+		}
+	}
+	for name, value := range changes {
+		if strings.Contains(name, "@") {
+			continue
+		}
+		merged[name] = value
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("memstore: %w", err)
+	}
+	updated := reflect.New(s.entityType)
+	if err := json.Unmarshal(data, updated.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("memstore: %w", err)
+	}
+	for _, key := range s.meta.KeyProperties {
+		updated.Elem().FieldByName(key.FieldName).Set(current.FieldByName(key.FieldName))
+	}
+	return updated.Elem(), nil
+}
@@ -0,0 +1,288 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/query"
+	"github.com/nlstn/go-odata/internal/storage"
+)
+
+type memProduct struct {
+	ID        int       `json:"id" odata:"key"`
+	Name      string    `json:"name"`
+	Price     float64   `json:"price"`
+	Stock     *int      `json:"stock"`
+	Released  time.Time `json:"released"`
+	Available bool      `json:"available"`
+}
+
+type memLine struct {
+	OrderID   int    `json:"orderID" odata:"key"`
+	ProductID int    `json:"productID" odata:"key"`
+	Note      string `json:"note"`
+}
+
+func intPtr(v int) *int { return &v }
+
+func newProductStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(&memProduct{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	products := []memProduct{
+		{ID: 1, Name: "Laptop", Price: 999.5, Stock: intPtr(3), Released: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC), Available: true},
+		{ID: 2, Name: "Mouse", Price: 19.99, Released: time.Date(2022, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{ID: 3, Name: "Keyboard", Price: 49, Stock: intPtr(10), Released: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Available: true},
+		{ID: 4, Name: "Monitor", Price: 199, Stock: intPtr(0), Released: time.Date(2023, 11, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	for i := range products {
+		if _, err := s.Create(context.Background(), &products[i]); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+	return s
+}
+
+func queryWith(t *testing.T, s *Store, rawFilter string, opts *query.QueryOptions) []memProduct {
+	t.Helper()
+	if opts == nil {
+		opts = &query.QueryOptions{}
+	}
+	if rawFilter != "" {
+		filter, err := query.ParseFilterExpression(rawFilter, s.meta)
+		if err != nil {
+			t.Fatalf("parse %q: %v", rawFilter, err)
+		}
+		opts.Filter = filter
+	}
+	result, err := s.Query(context.Background(), storage.Query{Options: opts})
+	if err != nil {
+		t.Fatalf("Query(%q) error: %v", rawFilter, err)
+	}
+	return result.Items.([]memProduct)
+}
+
+func ids(items []memProduct) []int {
+	out := make([]int, len(items))
+	for i, item := range items {
+		out[i] = item.ID
+	}
+	return out
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueryFilter(t *testing.T) {
+	s := newProductStore(t)
+
+	tests := []struct {
+		filter string
+		want   []int
+	}{
+		{"price gt 100", []int{1, 4}},
+		{"price le 49", []int{2, 3}},
+		{"name eq 'Mouse'", []int{2}},
+		{"name ne 'Mouse'", []int{1, 3, 4}},
+		{"contains(name,'o')", []int{1, 2, 3, 4}},
+		{"startswith(name,'M') and price lt 100", []int{2}},
+		{"endswith(name,'top') or id eq 3", []int{1, 3}},
+		{"not (price gt 100)", []int{2, 3}},
+		{"id in (2,4)", []int{2, 4}},
+		{"stock eq null", []int{2}},
+		{"stock ne null and stock gt 2", []int{1, 3}},
+		{"tolower(name) eq 'keyboard'", []int{3}},
+		{"length(name) eq 5", []int{2}},
+		{"year(released) eq 2023", []int{1, 4}},
+		{"released gt 2023-06-01T00:00:00Z", []int{3, 4}},
+		{"available eq true", []int{1, 3}},
+		{"price mul 2 gt 300", []int{1, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got := ids(queryWith(t, s, tt.filter, nil))
+			if !equalIDs(got, tt.want) {
+				t.Fatalf("filter %q: got %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryOrderPagingAndCount(t *testing.T) {
+	s := newProductStore(t)
+	top, skip := 2, 1
+	opts := &query.QueryOptions{
+		OrderBy: []query.OrderByItem{{Property: "price", Descending: true}},
+		Top:     &top,
+		Skip:    &skip,
+		Count:   true,
+	}
+	filter, err := query.ParseFilterExpression("price gt 20", s.meta)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	opts.Filter = filter
+
+	result, err := s.Query(context.Background(), storage.Query{Options: opts})
+	if err != nil {
+		t.Fatalf("Query() error: %v", err)
+	}
+	if got := ids(result.Items.([]memProduct)); !equalIDs(got, []int{4, 3}) {
+		t.Fatalf("got %v, want [4 3]", got)
+	}
+	if result.Count == nil || *result.Count != 3 {
+		t.Fatalf("count = %v, want 3", result.Count)
+	}
+
+	count, err := s.Count(context.Background(), storage.Query{Options: &query.QueryOptions{Filter: filter}})
+	if err != nil || count != 3 {
+		t.Fatalf("Count() = %d, %v; want 3", count, err)
+	}
+}
+
+func TestQuerySearch(t *testing.T) {
+	s := newProductStore(t)
+	got := ids(queryWith(t, s, "", &query.QueryOptions{Search: "Keyboard"}))
+	if !equalIDs(got, []int{3}) {
+		t.Fatalf("got %v, want [3]", got)
+	}
+}
+
+func TestQueryUnsupported(t *testing.T) {
+	s := newProductStore(t)
+	filter, err := query.ParseFilterExpression("indexof(name,'o') eq 1", s.meta)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	_, err = s.Query(context.Background(), storage.Query{Options: &query.QueryOptions{Filter: filter}})
+	if !errors.Is(err, storage.ErrUnsupportedQuery) {
+		t.Fatalf("expected ErrUnsupportedQuery, got %v", err)
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	s := newProductStore(t)
+
+	created, err := s.Create(ctx, &memProduct{Name: "Webcam", Price: 59})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if got := created.(*memProduct).ID; got != 5 {
+		t.Fatalf("generated id = %d, want 5", got)
+	}
+	if _, err := s.Create(ctx, &memProduct{ID: 5}); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	entity, err := s.Get(ctx, map[string]interface{}{"id": "5"}, storage.Query{})
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	entity.(*memProduct).Name = "mutated"
+
+	updated, err := s.Update(ctx, map[string]interface{}{"ID": 5}, map[string]interface{}{"price": 65.0, "id": 99}, false)
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	product := updated.(*memProduct)
+	if product.ID != 5 || product.Name != "Webcam" || product.Price != 65 {
+		t.Fatalf("unexpected patched entity: %+v", product)
+	}
+
+	replaced, err := s.Update(ctx, map[string]interface{}{"id": 5}, map[string]interface{}{"name": "Cam"}, true)
+	if err != nil {
+		t.Fatalf("Update(replace) error: %v", err)
+	}
+	if product := replaced.(*memProduct); product.Name != "Cam" || product.Price != 0 {
+		t.Fatalf("unexpected replaced entity: %+v", product)
+	}
+
+	if err := s.Delete(ctx, map[string]interface{}{"id": 5}); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := s.Get(ctx, map[string]interface{}{"id": 5}, storage.Query{}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := s.Delete(ctx, map[string]interface{}{"id": 5}); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
+
+func TestCompositeKey(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(memLine{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if _, err := s.Create(ctx, memLine{OrderID: 1, ProductID: 2, Note: "a"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if _, err := s.Create(ctx, memLine{OrderID: 1, ProductID: 3, Note: "b"}); err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	line, err := s.Get(ctx, map[string]interface{}{"OrderID": 1, "productID": 3}, storage.Query{})
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if line.(*memLine).Note != "b" {
+		t.Fatalf("unexpected line: %+v", line)
+	}
+	if _, err := s.Get(ctx, map[string]interface{}{"orderID": 1}, storage.Query{}); err == nil {
+		t.Fatal("expected error for incomplete key")
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(&memProduct{})
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if _, err := s.Create(ctx, &memProduct{Name: "p"}); err != nil {
+					t.Errorf("Create() error: %v", err)
+					return
+				}
+				if _, err := s.Query(ctx, storage.Query{}); err != nil {
+					t.Errorf("Query() error: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.Len() != 200 {
+		t.Fatalf("Len() = %d, want 200", s.Len())
+	}
+}
+
+func TestNewRequiresKey(t *testing.T) {
+	type keyless struct {
+		Name string `json:"name"`
+	}
+	if _, err := New(&keyless{}); err == nil {
+		t.Fatal("expected error for entity without key")
+	}
+}
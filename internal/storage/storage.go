@@ -4,33 +4,90 @@ import (
 	"context"
 	"errors"
 
+	"github.com/nlstn/go-odata/internal/query"
 	"gorm.io/gorm"
 )
 
 var (
 	// ErrNotFound indicates a requested record does not exist.
 	ErrNotFound = errors.New("storage: not found")
+
+	// ErrConflict indicates a record with the same key already exists.
+	ErrConflict = errors.New("storage: conflict")
+
+	// ErrUnsupportedQuery indicates the backend cannot evaluate part of the
+	// requested query (for example a $filter function it does not implement).
+	ErrUnsupportedQuery = errors.New("storage: unsupported query")
 )
 
 // Query describes parsed OData query intent passed to a storage backend.
-// This is intentionally minimal in the first decoupling phase.
+// Options holds the validated query options of the request, including the
+// $filter AST, $orderby, $top/$skip, $select, $expand, $search and $count.
 type Query struct {
-	Options interface{}
+	Options *query.QueryOptions
+}
+
+// Result is the outcome of an EntityStore collection query.
+type Result struct {
+	// Items is a slice of entities ([]T or []*T) for the requested page.
+	Items interface{}
+	// Count is the total number of matching records before $top/$skip.
+	// It only needs to be set when the query requested $count=true.
+	Count *int64
 }
 
 // Tx represents an active storage transaction.
 type Tx interface {
-	DB() *gorm.DB
 	Commit() error
 	Rollback() error
 }
 
-// Store provides the storage contract consumed by runtime handlers.
+// Store provides the transactional storage contract consumed by runtime
+// handlers. Entity sets served by other sources use EntityStore.
 type Store interface {
-	DB(ctx context.Context) *gorm.DB
 	Transaction(ctx context.Context, fn func(tx Tx) error) error
 	Begin(ctx context.Context) (Tx, error)
 	IsNotFound(err error) bool
 	MapError(err error) error
+}
+
+// GORMTx is a transaction of a GORMStore that exposes the GORM transaction.
+type GORMTx interface {
+	Tx
+	DB() *gorm.DB
+}
+
+// GORMStore is a Store backed by GORM. The runtime handlers of GORM-backed
+// entity sets query it directly. The transactions it begins implement GORMTx.
+type GORMStore interface {
+	Store
+	DB(ctx context.Context) *gorm.DB
 	SupportsGORM() bool
 }
+
+// EntityStore is the record-level storage contract for a single entity set.
+// It receives parsed query options instead of SQL, so any backend (key-value
+// stores, REST APIs, in-memory data) can serve an entity set with full query
+// option support.
+//
+// Keys are passed as maps from key property name to typed key value. Get,
+// Update and Delete report a missing record with ErrNotFound; Create reports a
+// duplicate key with ErrConflict. Query and Count may return ErrUnsupportedQuery
+// for query constructs the backend cannot evaluate.
+type EntityStore interface {
+	// Query returns the records matching q.Options.Filter and q.Options.Search,
+	// ordered by q.Options.OrderBy and paged by q.Options.Skip and q.Options.Top.
+	Query(ctx context.Context, q Query) (*Result, error)
+	// Get returns the record identified by key.
+	Get(ctx context.Context, key map[string]interface{}, q Query) (interface{}, error)
+	// Create stores a new record and returns it as persisted.
+	Create(ctx context.Context, entity interface{}) (interface{}, error)
+	// Update applies changes (keyed by JSON property name) to the record
+	// identified by key. When replace is true, properties absent from changes
+	// are reset to their zero values.
+	Update(ctx context.Context, key map[string]interface{}, changes map[string]interface{}, replace bool) (interface{}, error)
+	// Delete removes the record identified by key.
+	Delete(ctx context.Context, key map[string]interface{}) error
+	// Count returns the number of records matching q.Options.Filter and q.Options.Search.
+	Count(ctx context.Context, q Query) (int64, error)
+}
@@ -0,0 +1,81 @@
+package tokensign
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signer, err := New([]byte("current-key"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	scope := Scope("skiptoken", "Products", "Price gt 5", "Name")
+
+	token := signer.Sign("eyJrIjp7IklEIjo1fX0=", scope)
+	payload, err := signer.Verify(token, scope)
+	if err != nil {
+		t.Fatalf("Verify() error: %v", err)
+	}
+	if payload != "eyJrIjp7IklEIjo1fX0=" {
+		t.Errorf("Verify() payload = %q", payload)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		scope string
+	}{
+		{name: "unsigned", token: "eyJrIjp7IklEIjo1fX0=", scope: scope},
+		{name: "tampered payload", token: "eyJrIjp7IklEIjo5fX0=" + token[len("eyJrIjp7IklEIjo1fX0="):], scope: scope},
+		{name: "other entity set", token: token, scope: Scope("skiptoken", "Orders", "Price gt 5", "Name")},
+		{name: "other filter", token: token, scope: Scope("skiptoken", "Products", "Price gt 0", "Name")},
+		{name: "malformed signature", token: "eyJrIjp7IklEIjo1fX0=.%%%", scope: scope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := signer.Verify(tt.token, tt.scope); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Verify() error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldSigner, err := New([]byte("old-key"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	rotated, err := New([]byte("new-key"), []byte("old-key"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	retired, err := New([]byte("new-key"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+
+	token := oldSigner.Sign("payload", "scope")
+	if _, err := rotated.Verify(token, "scope"); err != nil {
+		t.Errorf("rotated signer should accept tokens signed with a verification key: %v", err)
+	}
+	if _, err := retired.Verify(token, "scope"); err == nil {
+		t.Error("signer without the old key should reject its tokens")
+	}
+}
+
+func TestNilSignerPassesThrough(t *testing.T) {
+	signer, err := New(nil)
+	if err != nil || signer != nil {
+		t.Fatalf("New(nil) = %v, %v; want nil signer", signer, err)
+	}
+	if got := signer.Sign("payload", "scope"); got != "payload" {
+		t.Errorf("Sign() = %q, want payload unchanged", got)
+	}
+	if got, err := signer.Verify("payload", "scope"); err != nil || got != "payload" {
+		t.Errorf("Verify() = %q, %v; want payload unchanged", got, err)
+	}
+	if _, err := New(nil, []byte("key")); err == nil {
+		t.Error("verification keys without a signing key should be rejected")
+	}
+}
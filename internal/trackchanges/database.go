@@ -0,0 +1,363 @@
+package trackchanges
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// compactionDeleteBatch limits the number of versions deleted per statement
+// to stay below database parameter limits.
+const compactionDeleteBatch = 500
+
+// databaseHistory stores the change history in the database. Versions are
+// allocated from _odata_change_versions inside the transaction that writes
+// the change, so processes sharing a database issue one consistent sequence
+// of versions per entity set.
+type databaseHistory struct {
+	db *gorm.DB
+}
+
+func newDatabaseHistory(db *gorm.DB) (*databaseHistory, error) {
+	if err := db.AutoMigrate(&changeRecord{}, &versionRecord{}); err != nil {
+		return nil, fmt.Errorf("failed to migrate change tracking table: %w", err)
+	}
+	return &databaseHistory{db: db}, nil
+}
+
+func (d *databaseHistory) append(event ChangeEvent) (int64, error) {
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		version, err := allocateVersion(tx, event.EntitySet)
+		if err != nil {
+			return err
+		}
+		event.Version = version
+
+		record, err := newChangeRecord(event)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("failed to persist change event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return event.Version, nil
+}
+
+// allocateVersion increments the version counter of entitySet. The update
+// locks the counter row until tx ends, so versions become visible to readers
+// in the order they were allocated.
+func allocateVersion(tx *gorm.DB, entitySet string) (int64, error) {
+	if err := ensureCounter(tx, entitySet); err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&versionRecord{}).
+		Where("entity_set = ?", entitySet).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return 0, fmt.Errorf("failed to allocate change version: %w", err)
+	}
+	var counter versionRecord
+	if err := tx.Where("entity_set = ?", entitySet).Take(&counter).Error; err != nil {
+		return 0, fmt.Errorf("failed to read change version: %w", err)
+	}
+	return counter.Version, nil
+}
+
+// ensureCounter creates the version counter of entitySet if it does not exist,
+// seeding it from history written before the counter table existed.
+func ensureCounter(tx *gorm.DB, entitySet string) error {
+	var count int64
+	if err := tx.Model(&versionRecord{}).Where("entity_set = ?", entitySet).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to read change version: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	seed, err := latestRecordedVersion(tx, entitySet)
+	if err != nil {
+		return err
+	}
+	// Another process may create the counter concurrently; keep whichever came first.
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&versionRecord{EntitySet: entitySet, Version: seed}).Error; err != nil {
+		return fmt.Errorf("failed to create change version counter: %w", err)
+	}
+	return nil
+}
+
+func latestRecordedVersion(db *gorm.DB, entitySet string) (int64, error) {
+	var latest *int64
+	if err := db.Model(&changeRecord{}).
+		Where("entity_set = ?", entitySet).
+		Select("MAX(version)").
+		Scan(&latest).Error; err != nil {
+		return 0, fmt.Errorf("failed to read change history: %w", err)
+	}
+	if latest == nil {
+		return 0, nil
+	}
+	return *latest, nil
+}
+
+// counter returns the version counter of entitySet, falling back to the
+// change log for entity sets whose counter has not been created yet.
+func (d *databaseHistory) counter(entitySet string) (versionRecord, error) {
+	var counter versionRecord
+	err := d.db.Where("entity_set = ?", entitySet).Take(&counter).Error
+	if err == nil {
+		return counter, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return versionRecord{}, fmt.Errorf("failed to read change version: %w", err)
+	}
+	latest, err := latestRecordedVersion(d.db, entitySet)
+	if err != nil {
+		return versionRecord{}, err
+	}
+	return versionRecord{EntitySet: entitySet, Version: latest}, nil
+}
+
+func (d *databaseHistory) current(entitySet string) (int64, error) {
+	counter, err := d.counter(entitySet)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Version, nil
+}
+
+func (d *databaseHistory) checkVersion(entitySet string, version int64) error {
+	counter, err := d.counter(entitySet)
+	if err != nil {
+		return err
+	}
+	if version < counter.MinVersion {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+func (d *databaseHistory) since(entitySet string, version int64) ([]ChangeEvent, int64, error) {
+	// Read the counter first: every version up to it has committed, so the
+	// returned token never skips a change that commits after the query.
+	counter, err := d.counter(entitySet)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version < counter.MinVersion {
+		return nil, 0, ErrTokenExpired
+	}
+
+	var records []changeRecord
+	if err := d.db.
+		Where("entity_set = ? AND version > ? AND version <= ?", entitySet, version, counter.Version).
+		Order("version asc").
+		Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load change history: %w", err)
+	}
+
+	events := make([]ChangeEvent, 0, len(records))
+	for _, record := range records {
+		event, err := record.toEvent()
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, counter.Version, nil
+}
+
+func (d *databaseHistory) compact(entitySet string, cfg Config, now time.Time) error {
+	var floor int64
+	if cfg.MaxAge > 0 {
+		var expired *int64
+		if err := d.db.Model(&changeRecord{}).
+			Where("entity_set = ? AND recorded_at < ?", entitySet, now.Add(-cfg.MaxAge)).
+			Select("MAX(version)").
+			Scan(&expired).Error; err != nil {
+			return err
+		}
+		if expired != nil {
+			floor = *expired
+		}
+	}
+	if cfg.MaxEvents > 0 {
+		var overflow []int64
+		if err := d.db.Model(&changeRecord{}).
+			Where("entity_set = ?", entitySet).
+			Order("version desc").
+			Offset(cfg.MaxEvents).
+			Limit(1).
+			Pluck("version", &overflow).Error; err != nil {
+			return err
+		}
+		if len(overflow) > 0 && overflow[0] > floor {
+			floor = overflow[0]
+		}
+	}
+
+	if floor > 0 {
+		err := d.db.Transaction(func(tx *gorm.DB) error {
+			if err := ensureCounter(tx, entitySet); err != nil {
+				return err
+			}
+			if err := tx.Where("entity_set = ? AND version <= ?", entitySet, floor).Delete(&changeRecord{}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&versionRecord{}).
+				Where("entity_set = ? AND min_version < ?", entitySet, floor).
+				UpdateColumn("min_version", floor).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !cfg.CompactUpdates {
+		return nil
+	}
+
+	var rows []struct {
+		Version    int64
+		KeyValues  []byte
+		ChangeType ChangeType
+	}
+	if err := d.db.Model(&changeRecord{}).
+		Select("version, key_values, change_type").
+		Where("entity_set = ?", entitySet).
+		Order("version asc").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	candidates := make([]compactionCandidate, 0, len(rows))
+	for _, row := range rows {
+		candidates = append(candidates, compactionCandidate{version: row.Version, key: string(row.KeyValues), changeType: row.ChangeType})
+	}
+	superseded := supersededUpdates(candidates)
+	if len(superseded) == 0 {
+		return nil
+	}
+
+	versions := make([]int64, 0, len(superseded))
+	for _, candidate := range candidates {
+		if superseded[candidate.version] {
+			versions = append(versions, candidate.version)
+		}
+	}
+	for start := 0; start < len(versions); start += compactionDeleteBatch {
+		end := start + compactionDeleteBatch
+		if end > len(versions) {
+			end = len(versions)
+		}
+		if err := d.db.Where("entity_set = ? AND version IN ?", entitySet, versions[start:end]).Delete(&changeRecord{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type changeRecord struct {
+	ID         uint       `gorm:"primaryKey"`
+	EntitySet  string     `gorm:"size:255;not null;index:idx_entity_version,priority:1"`
+	Version    int64      `gorm:"not null;index:idx_entity_version,priority:2"`
+	ChangeType ChangeType `gorm:"size:16;not null"`
+	KeyValues  []byte     `gorm:"not null"`
+	Data       []byte
+	// RecordedAt is nil for changes written by versions without retention support.
+	RecordedAt *time.Time `gorm:"index"`
+}
+
+func (changeRecord) TableName() string {
+	return "_odata_change_log"
+}
+
+// versionRecord holds the latest allocated version of an entity set and the
+// oldest version a delta token may still hold after retention.
+type versionRecord struct {
+	EntitySet  string `gorm:"primaryKey;size:255"`
+	Version    int64  `gorm:"not null"`
+	MinVersion int64  `gorm:"not null;default:0"`
+}
+
+func (versionRecord) TableName() string {
+	return "_odata_change_versions"
+}
+
+func newChangeRecord(event ChangeEvent) (changeRecord, error) {
+	keyJSON, err := json.Marshal(event.KeyValues)
+	if err != nil {
+		return changeRecord{}, fmt.Errorf("failed to encode change keys: %w", err)
+	}
+
+	var dataJSON []byte
+	if event.Data != nil {
+		dataJSON, err = json.Marshal(event.Data)
+		if err != nil {
+			return changeRecord{}, fmt.Errorf("failed to encode change data: %w", err)
+		}
+	}
+
+	record := changeRecord{
+		EntitySet:  event.EntitySet,
+		Version:    event.Version,
+		ChangeType: event.Type,
+		KeyValues:  keyJSON,
+		Data:       dataJSON,
+	}
+	if !event.RecordedAt.IsZero() {
+		recordedAt := event.RecordedAt
+		record.RecordedAt = &recordedAt
+	}
+	return record, nil
+}
+
+func (r changeRecord) toEvent() (ChangeEvent, error) {
+	keyValues, err := r.decodeKeyValues()
+	if err != nil {
+		return ChangeEvent{}, fmt.Errorf("failed to decode change record keys: %w", err)
+	}
+
+	data, err := r.decodeData()
+	if err != nil {
+		return ChangeEvent{}, fmt.Errorf("failed to decode change record data: %w", err)
+	}
+
+	event := ChangeEvent{
+		EntitySet: r.EntitySet,
+		KeyValues: keyValues,
+		Data:      data,
+		Type:      r.ChangeType,
+		Version:   r.Version,
+	}
+	if r.RecordedAt != nil {
+		event.RecordedAt = *r.RecordedAt
+	}
+	return event, nil
+}
+
+func (r changeRecord) decodeKeyValues() (map[string]interface{}, error) {
+	var keyValues map[string]interface{}
+	if err := json.Unmarshal(r.KeyValues, &keyValues); err != nil {
+		return nil, err
+	}
+	return keyValues, nil
+}
+
+func (r changeRecord) decodeData() (map[string]interface{}, error) {
+	if len(r.Data) == 0 {
+		return nil, nil
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(r.Data, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
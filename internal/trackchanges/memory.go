@@ -0,0 +1,144 @@
+package trackchanges
+
+import (
+	"sync"
+	"time"
+)
+
+type entityHistory struct {
+	Version int64
+	// MinVersion is the oldest version a delta token may hold. Retention
+	// raises it when it removes history.
+	MinVersion int64
+	Events     []ChangeEvent
+}
+
+// memoryHistory keeps the change history of the current process in memory.
+type memoryHistory struct {
+	mu       sync.RWMutex
+	entities map[string]*entityHistory
+}
+
+func newMemoryHistory() *memoryHistory {
+	return &memoryHistory{entities: make(map[string]*entityHistory)}
+}
+
+func (m *memoryHistory) entity(entitySet string) *entityHistory {
+	history, exists := m.entities[entitySet]
+	if !exists {
+		history = &entityHistory{}
+		m.entities[entitySet] = history
+	}
+	return history
+}
+
+func (m *memoryHistory) append(event ChangeEvent) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := m.entity(event.EntitySet)
+	history.Version++
+	event.Version = history.Version
+	history.Events = append(history.Events, event)
+	return event.Version, nil
+}
+
+func (m *memoryHistory) current(entitySet string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if history, exists := m.entities[entitySet]; exists {
+		return history.Version, nil
+	}
+	return 0, nil
+}
+
+func (m *memoryHistory) checkVersion(entitySet string, version int64) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if history, exists := m.entities[entitySet]; exists && version < history.MinVersion {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+func (m *memoryHistory) since(entitySet string, version int64) ([]ChangeEvent, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history, exists := m.entities[entitySet]
+	if !exists {
+		return nil, 0, nil
+	}
+	if version < history.MinVersion {
+		return nil, 0, ErrTokenExpired
+	}
+
+	var events []ChangeEvent
+	for _, event := range history.Events {
+		if event.Version > version {
+			// Copy to avoid exposing internal state
+			copied := event
+			copied.KeyValues = copyMap(event.KeyValues)
+			copied.Data = copyMap(event.Data)
+			events = append(events, copied)
+		}
+	}
+	return events, history.Version, nil
+}
+
+func (m *memoryHistory) compact(entitySet string, cfg Config, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history, exists := m.entities[entitySet]
+	if !exists {
+		return nil
+	}
+
+	var floor int64
+	if cfg.MaxAge > 0 {
+		cutoff := now.Add(-cfg.MaxAge)
+		for _, event := range history.Events {
+			if !event.RecordedAt.Before(cutoff) {
+				break
+			}
+			floor = event.Version
+		}
+	}
+	if cfg.MaxEvents > 0 && len(history.Events) > cfg.MaxEvents {
+		if version := history.Events[len(history.Events)-cfg.MaxEvents-1].Version; version > floor {
+			floor = version
+		}
+	}
+
+	var superseded map[int64]bool
+	if cfg.CompactUpdates {
+		candidates := make([]compactionCandidate, 0, len(history.Events))
+		for _, event := range history.Events {
+			key, err := keyIdentity(event.KeyValues)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, compactionCandidate{version: event.Version, key: key, changeType: event.Type})
+		}
+		superseded = supersededUpdates(candidates)
+	}
+
+	kept := history.Events[:0]
+	for _, event := range history.Events {
+		if event.Version <= floor || superseded[event.Version] {
+			continue
+		}
+		kept = append(kept, event)
+	}
+	for i := len(kept); i < len(history.Events); i++ {
+		history.Events[i] = ChangeEvent{}
+	}
+	history.Events = kept
+	if floor > history.MinVersion {
+		history.MinVersion = floor
+	}
+	return nil
+}
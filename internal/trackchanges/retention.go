@@ -0,0 +1,142 @@
+package trackchanges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultCompactionInterval is how often retention and compaction run when
+// Config enables them without setting CompactionInterval.
+const DefaultCompactionInterval = time.Minute
+
+// Config controls how much change history a Tracker retains.
+type Config struct {
+	// MaxAge removes changes older than the given duration. Zero keeps changes regardless of age.
+	MaxAge time.Duration
+	// MaxEvents keeps at most this many changes per entity set. Zero keeps all changes.
+	MaxEvents int
+	// CompactUpdates removes update events that are followed by a later change
+	// to the same entity. Delta responses only carry the latest state, so
+	// clients observe the same result with fewer stored events.
+	CompactUpdates bool
+	// CompactionInterval is how often retention and compaction run in the
+	// background. Defaults to DefaultCompactionInterval.
+	CompactionInterval time.Duration
+	// Logger receives compaction errors. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+func (c Config) enabled() bool {
+	return c.MaxAge > 0 || c.MaxEvents > 0 || c.CompactUpdates
+}
+
+func (c Config) withDefaults() Config {
+	if c.CompactionInterval <= 0 {
+		c.CompactionInterval = DefaultCompactionInterval
+	}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	return c
+}
+
+// Compact applies the configured retention and update compaction to the
+// history of every registered entity set. Tokens that point before the
+// removed history are answered with ErrTokenExpired afterwards.
+func (t *Tracker) Compact(ctx context.Context) error {
+	if !t.cfg.enabled() {
+		return nil
+	}
+	now := time.Now()
+	for _, entitySet := range t.registeredEntitySets() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.history.compact(entitySet, t.cfg, now); err != nil {
+			return fmt.Errorf("failed to compact change history for '%s': %w", entitySet, err)
+		}
+	}
+	return nil
+}
+
+// Close stops the background compaction loop. It is safe to call multiple times.
+func (t *Tracker) Close() {
+	t.loopMu.Lock()
+	stop, done := t.stop, t.done
+	t.stop, t.done = nil, nil
+	t.loopMu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (t *Tracker) startCompaction() {
+	t.loopMu.Lock()
+	defer t.loopMu.Unlock()
+
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.runCompaction(t.stop, t.done)
+}
+
+func (t *Tracker) runCompaction(stop, done chan struct{}) {
+	defer close(done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(t.cfg.CompactionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := t.Compact(ctx); err != nil && ctx.Err() == nil {
+				t.cfg.Logger.Error("change tracking compaction failed", "error", err)
+			}
+		}
+	}
+}
+
+type compactionCandidate struct {
+	version    int64
+	key        string
+	changeType ChangeType
+}
+
+// supersededUpdates returns the versions of update events that are followed by
+// a later change to the same key. candidates must be ordered by version.
+func supersededUpdates(candidates []compactionCandidate) map[int64]bool {
+	superseded := make(map[int64]bool)
+	seen := make(map[string]struct{}, len(candidates))
+	for i := len(candidates) - 1; i >= 0; i-- {
+		candidate := candidates[i]
+		if _, later := seen[candidate.key]; later && candidate.changeType == ChangeTypeUpdated {
+			superseded[candidate.version] = true
+		}
+		seen[candidate.key] = struct{}{}
+	}
+	return superseded
+}
+
+// keyIdentity returns a stable string for an entity's key values. It matches
+// the encoding stored in the change log, since encoding/json sorts map keys.
+func keyIdentity(keyValues map[string]interface{}) (string, error) {
+	encoded, err := json.Marshal(keyValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode change keys: %w", err)
+	}
+	return string(encoded), nil
+}
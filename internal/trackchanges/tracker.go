@@ -3,8 +3,11 @@ package trackchanges
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -28,28 +31,46 @@ type ChangeEvent struct {
 	Data      map[string]interface{}
 	Type      ChangeType
 	Version   int64
+	// RecordedAt is when the change was recorded. It drives age-based retention.
+	RecordedAt time.Time
 }
 
+// ErrTokenExpired is returned for delta tokens that point before the retained
+// change history. Clients holding such a token must re-read the entity set.
+var ErrTokenExpired = errors.New("delta token has expired")
+
 type deltaToken struct {
 	EntitySet string `json:"entitySet"`
 	Version   int64  `json:"version"`
 }
 
-type entityHistory struct {
-	Version int64
-	Events  []ChangeEvent
+// historyStore holds the change history of all entity sets and allocates versions.
+type historyStore interface {
+	append(event ChangeEvent) (int64, error)
+	current(entitySet string) (int64, error)
+	checkVersion(entitySet string, version int64) error
+	since(entitySet string, version int64) ([]ChangeEvent, int64, error)
+	compact(entitySet string, cfg Config, now time.Time) error
 }
 
 // Tracker tracks entity changes and issues delta tokens that follow the OData change tracking semantics.
 type Tracker struct {
-	mu       sync.RWMutex
-	entities map[string]*entityHistory
-	db       *gorm.DB
+	mu         sync.RWMutex
+	registered map[string]struct{}
+	history    historyStore
+	cfg        Config
+
+	subMu       sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+
+	loopMu sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
 }
 
 // NewTracker creates a new change tracker.
 func NewTracker() (*Tracker, error) {
-	return newTracker(nil)
+	return NewTrackerWithConfig(nil, Config{})
 }
 
 // NewTrackerWithDB creates a tracker backed by persistent storage.
@@ -57,23 +78,35 @@ func NewTrackerWithDB(db *gorm.DB) (*Tracker, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database handle is required for persistent change tracking")
 	}
-	return newTracker(db)
+	return NewTrackerWithConfig(db, Config{})
 }
 
-func newTracker(db *gorm.DB) (*Tracker, error) {
-	tracker := &Tracker{entities: make(map[string]*entityHistory), db: db}
-	if db == nil {
-		return tracker, nil
+// NewTrackerWithConfig creates a tracker that applies cfg to its history. When
+// db is nil the history is kept in memory; otherwise it is stored in db and
+// versions are allocated by the database, so several processes sharing db
+// issue consistent delta tokens. When cfg enables retention or compaction, a
+// background loop applies it every cfg.CompactionInterval until Close is called.
+func NewTrackerWithConfig(db *gorm.DB, cfg Config) (*Tracker, error) {
+	cfg = cfg.withDefaults()
+
+	tracker := &Tracker{
+		registered:  make(map[string]struct{}),
+		cfg:         cfg,
+		subscribers: make(map[string]map[chan struct{}]struct{}),
 	}
-
-	if err := db.AutoMigrate(&changeRecord{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate change tracking table: %w", err)
+	if db == nil {
+		tracker.history = newMemoryHistory()
+	} else {
+		history, err := newDatabaseHistory(db)
+		if err != nil {
+			return nil, err
+		}
+		tracker.history = history
 	}
 
-	if err := tracker.loadFromDatabase(); err != nil {
-		return nil, err
+	if cfg.enabled() {
+		tracker.startCompaction()
 	}
-
 	return tracker, nil
 }
 
@@ -82,101 +115,144 @@ func newTracker(db *gorm.DB) (*Tracker, error) {
 func (t *Tracker) RegisterEntity(entitySet string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.registered[entitySet] = struct{}{}
+}
 
-	if _, exists := t.entities[entitySet]; !exists {
-		t.entities[entitySet] = &entityHistory{}
+func (t *Tracker) isRegistered(entitySet string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.registered[entitySet]
+	return exists
+}
+
+func (t *Tracker) registeredEntitySets() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	sets := make([]string, 0, len(t.registered))
+	for entitySet := range t.registered {
+		sets = append(sets, entitySet)
 	}
+	sort.Strings(sets)
+	return sets
 }
 
 // RecordChange stores a change for the specified entity set and returns the new version number.
 func (t *Tracker) RecordChange(entitySet string, keyValues, data map[string]interface{}, changeType ChangeType) (int64, error) {
-	t.mu.Lock()
-
-	history, exists := t.entities[entitySet]
-	if !exists {
-		history = &entityHistory{}
-		t.entities[entitySet] = history
-	}
-
-	history.Version++
+	t.RegisterEntity(entitySet)
 
-	copiedKeyValues := copyMap(keyValues)
 	var copiedData map[string]interface{}
 	if data != nil {
 		copiedData = copyMap(data)
 	}
+	version, err := t.history.append(ChangeEvent{
+		EntitySet:  entitySet,
+		KeyValues:  copyMap(keyValues),
+		Data:       copiedData,
+		Type:       changeType,
+		RecordedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
 
-	event := ChangeEvent{
-		EntitySet: entitySet,
-		KeyValues: copiedKeyValues,
-		Data:      copiedData,
-		Type:      changeType,
-		Version:   history.Version,
+	t.notifySubscribers(entitySet)
+	return version, nil
+}
+
+// Subscribe returns a channel that is signalled whenever a change is recorded
+// for entitySet, together with a function that ends the subscription. Signals
+// are coalesced: a subscriber that has not drained the channel receives one
+// pending signal rather than one per change, so callers should read all changes
+// since their last token after each signal. Changes recorded by other
+// processes sharing the database are not signalled.
+func (t *Tracker) Subscribe(entitySet string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	t.subMu.Lock()
+	subs, ok := t.subscribers[entitySet]
+	if !ok {
+		subs = make(map[chan struct{}]struct{})
+		t.subscribers[entitySet] = subs
+	}
+	subs[ch] = struct{}{}
+	t.subMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			t.subMu.Lock()
+			delete(t.subscribers[entitySet], ch)
+			t.subMu.Unlock()
+		})
 	}
-	history.Events = append(history.Events, event)
-	version := history.Version
-	t.mu.Unlock()
-
-	if t.db != nil {
-		if err := t.persistEvent(event); err != nil {
-			t.mu.Lock()
-			history.Version--
-			if len(history.Events) > 0 {
-				history.Events = history.Events[:len(history.Events)-1]
-			}
-			t.mu.Unlock()
-			return 0, err
+}
+
+func (t *Tracker) notifySubscribers(entitySet string) {
+	t.subMu.Lock()
+	defer t.subMu.Unlock()
+	for ch := range t.subscribers[entitySet] {
+		select {
+		case ch <- struct{}{}:
+		default:
 		}
 	}
+}
 
-	return version, nil
+// TokenForVersion returns the delta token that resumes change tracking for
+// entitySet after the change with the given version.
+func (t *Tracker) TokenForVersion(entitySet string, version int64) (string, error) {
+	if !t.isRegistered(entitySet) {
+		return "", fmt.Errorf("entity set '%s' is not registered", entitySet)
+	}
+	return encodeToken(entitySet, version)
 }
 
 // CurrentToken returns a delta token that represents the current state of the entity set.
 func (t *Tracker) CurrentToken(entitySet string) (string, error) {
-	t.mu.RLock()
-	history, exists := t.entities[entitySet]
-	t.mu.RUnlock()
-	if !exists {
+	if !t.isRegistered(entitySet) {
 		return "", fmt.Errorf("entity set '%s' is not registered", entitySet)
 	}
-	return encodeToken(entitySet, history.Version)
+	version, err := t.history.current(entitySet)
+	if err != nil {
+		return "", err
+	}
+	return encodeToken(entitySet, version)
+}
+
+// CheckToken validates token without reading the changes it refers to. It
+// returns ErrTokenExpired when the history the token depends on was removed
+// by retention.
+func (t *Tracker) CheckToken(token string) error {
+	entitySet, version, err := decodeToken(token)
+	if err != nil {
+		return err
+	}
+	if !t.isRegistered(entitySet) {
+		return fmt.Errorf("entity set '%s' is not registered", entitySet)
+	}
+	return t.history.checkVersion(entitySet, version)
 }
 
 // ChangesSince returns the change events that happened after the supplied delta token and a new token for subsequent requests.
+// It returns ErrTokenExpired when the history the token depends on was removed by retention.
 func (t *Tracker) ChangesSince(token string) ([]ChangeEvent, string, error) {
 	entitySet, version, err := decodeToken(token)
 	if err != nil {
 		return nil, "", err
 	}
-
-	t.mu.RLock()
-	history, exists := t.entities[entitySet]
-	if !exists {
-		t.mu.RUnlock()
+	if !t.isRegistered(entitySet) {
 		return nil, "", fmt.Errorf("entity set '%s' is not registered", entitySet)
 	}
 
-	var events []ChangeEvent
-	for _, event := range history.Events {
-		if event.Version > version {
-			// Copy to avoid exposing internal state
-			events = append(events, ChangeEvent{
-				EntitySet: event.EntitySet,
-				KeyValues: copyMap(event.KeyValues),
-				Data:      copyMap(event.Data),
-				Type:      event.Type,
-				Version:   event.Version,
-			})
-		}
+	events, current, err := t.history.since(entitySet, version)
+	if err != nil {
+		return nil, "", err
 	}
 
-	newToken, encodeErr := encodeToken(entitySet, history.Version)
-	t.mu.RUnlock()
-	if encodeErr != nil {
-		return nil, "", encodeErr
+	newToken, err := encodeToken(entitySet, current)
+	if err != nil {
+		return nil, "", err
 	}
-
 	return events, newToken, nil
 }
 
@@ -186,56 +262,6 @@ func (t *Tracker) EntitySetFromToken(token string) (string, error) {
 	return entitySet, err
 }
 
-func (t *Tracker) loadFromDatabase() error {
-	var records []changeRecord
-	if err := t.db.Order("entity_set asc, version asc").Find(&records).Error; err != nil {
-		return fmt.Errorf("failed to load change history: %w", err)
-	}
-
-	for _, record := range records {
-		keyValues, err := record.decodeKeyValues()
-		if err != nil {
-			return fmt.Errorf("failed to decode change record keys: %w", err)
-		}
-
-		data, err := record.decodeData()
-		if err != nil {
-			return fmt.Errorf("failed to decode change record data: %w", err)
-		}
-
-		history, exists := t.entities[record.EntitySet]
-		if !exists {
-			history = &entityHistory{}
-			t.entities[record.EntitySet] = history
-		}
-
-		event := ChangeEvent{
-			EntitySet: record.EntitySet,
-			KeyValues: keyValues,
-			Data:      data,
-			Type:      record.ChangeType,
-			Version:   record.Version,
-		}
-		history.Events = append(history.Events, event)
-		if record.Version > history.Version {
-			history.Version = record.Version
-		}
-	}
-
-	return nil
-}
-
-func (t *Tracker) persistEvent(event ChangeEvent) error {
-	record, err := newChangeRecord(event)
-	if err != nil {
-		return err
-	}
-	if err := t.db.Create(&record).Error; err != nil {
-		return fmt.Errorf("failed to persist change event: %w", err)
-	}
-	return nil
-}
-
 func encodeToken(entitySet string, version int64) (string, error) {
 	payload := deltaToken{
 		EntitySet: entitySet,
@@ -277,59 +303,3 @@ func copyMap(source map[string]interface{}) map[string]interface{} {
 	}
 	return clone
 }
-
-type changeRecord struct {
-	ID         uint       `gorm:"primaryKey"`
-	EntitySet  string     `gorm:"size:255;not null;index:idx_entity_version,priority:1"`
-	Version    int64      `gorm:"not null;index:idx_entity_version,priority:2"`
-	ChangeType ChangeType `gorm:"size:16;not null"`
-	KeyValues  []byte     `gorm:"not null"`
-	Data       []byte
-}
-
-func (changeRecord) TableName() string {
-	return "_odata_change_log"
-}
-
-func newChangeRecord(event ChangeEvent) (changeRecord, error) {
-	keyJSON, err := json.Marshal(event.KeyValues)
-	if err != nil {
-		return changeRecord{}, fmt.Errorf("failed to encode change keys: %w", err)
-	}
-
-	var dataJSON []byte
-	if event.Data != nil {
-		dataJSON, err = json.Marshal(event.Data)
-		if err != nil {
-			return changeRecord{}, fmt.Errorf("failed to encode change data: %w", err)
-		}
-	}
-
-	return changeRecord{
-		EntitySet:  event.EntitySet,
-		Version:    event.Version,
-		ChangeType: event.Type,
-		KeyValues:  keyJSON,
-		Data:       dataJSON,
-	}, nil
-}
-
-func (r changeRecord) decodeKeyValues() (map[string]interface{}, error) {
-	var keyValues map[string]interface{}
-	if err := json.Unmarshal(r.KeyValues, &keyValues); err != nil {
-		return nil, err
-	}
-	return keyValues, nil
-}
-
-func (r changeRecord) decodeData() (map[string]interface{}, error) {
-	if len(r.Data) == 0 {
-		return nil, nil
-	}
-
-	var data map[string]interface{}
-	if err := json.Unmarshal(r.Data, &data); err != nil {
-		return nil, err
-	}
-	return data, nil
-}
@@ -1,8 +1,11 @@
 package trackchanges
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -119,3 +122,217 @@ func TestPersistentTrackerLoadsHistory(t *testing.T) {
 		t.Fatalf("expected version 1, got %d", events[0].Version)
 	}
 }
+
+func recordProductChanges(t *testing.T, tracker *Tracker, changes ...ChangeType) {
+	t.Helper()
+	for i, changeType := range changes {
+		id := i + 1
+		if changeType == ChangeTypeUpdated {
+			id = 1
+		}
+		var data map[string]interface{}
+		if changeType != ChangeTypeDeleted {
+			data = map[string]interface{}{"ID": id}
+		}
+		if _, err := tracker.RecordChange("Products", map[string]interface{}{"ID": id}, data, changeType); err != nil {
+			t.Fatalf("RecordChange failed: %v", err)
+		}
+	}
+}
+
+func TestTrackerRetentionExpiresOldTokens(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T, cfg Config) (*Tracker, error){
+		"memory": func(t *testing.T, cfg Config) (*Tracker, error) {
+			return NewTrackerWithConfig(nil, cfg)
+		},
+		"database": func(t *testing.T, cfg Config) (*Tracker, error) {
+			db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracker.db")), &gorm.Config{})
+			if err != nil {
+				t.Fatalf("open database: %v", err)
+			}
+			return NewTrackerWithConfig(db, cfg)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tracker, err := open(t, Config{MaxEvents: 2, CompactionInterval: time.Hour})
+			if err != nil {
+				t.Fatalf("create tracker: %v", err)
+			}
+			defer tracker.Close()
+			tracker.RegisterEntity("Products")
+
+			oldToken, err := tracker.CurrentToken("Products")
+			if err != nil {
+				t.Fatalf("CurrentToken failed: %v", err)
+			}
+			recordProductChanges(t, tracker, ChangeTypeAdded, ChangeTypeAdded)
+			recentToken, err := tracker.CurrentToken("Products")
+			if err != nil {
+				t.Fatalf("CurrentToken failed: %v", err)
+			}
+			recordProductChanges(t, tracker, ChangeTypeAdded, ChangeTypeAdded)
+
+			if err := tracker.Compact(context.Background()); err != nil {
+				t.Fatalf("Compact failed: %v", err)
+			}
+
+			if _, _, err := tracker.ChangesSince(oldToken); !errors.Is(err, ErrTokenExpired) {
+				t.Fatalf("expected ErrTokenExpired for token before retained history, got %v", err)
+			}
+			if err := tracker.CheckToken(oldToken); !errors.Is(err, ErrTokenExpired) {
+				t.Fatalf("expected CheckToken to report ErrTokenExpired, got %v", err)
+			}
+			events, _, err := tracker.ChangesSince(recentToken)
+			if err != nil {
+				t.Fatalf("ChangesSince for retained token failed: %v", err)
+			}
+			if len(events) != 2 || events[0].Version != 3 || events[1].Version != 4 {
+				t.Fatalf("expected versions 3 and 4, got %+v", events)
+			}
+		})
+	}
+}
+
+func TestTrackerRetentionByAge(t *testing.T) {
+	tracker, err := NewTrackerWithConfig(nil, Config{MaxAge: time.Millisecond, CompactionInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("create tracker: %v", err)
+	}
+	defer tracker.Close()
+	tracker.RegisterEntity("Products")
+
+	token, err := tracker.CurrentToken("Products")
+	if err != nil {
+		t.Fatalf("CurrentToken failed: %v", err)
+	}
+	recordProductChanges(t, tracker, ChangeTypeAdded)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := tracker.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if _, _, err := tracker.ChangesSince(token); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expected ErrTokenExpired, got %v", err)
+	}
+	current, err := tracker.CurrentToken("Products")
+	if err != nil {
+		t.Fatalf("CurrentToken failed: %v", err)
+	}
+	if events, _, err := tracker.ChangesSince(current); err != nil || len(events) != 0 {
+		t.Fatalf("expected current token to stay valid, got %d events, err %v", len(events), err)
+	}
+}
+
+func TestTrackerCompactsSupersededUpdates(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracker.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	tracker, err := NewTrackerWithConfig(db, Config{CompactUpdates: true, CompactionInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("create tracker: %v", err)
+	}
+	defer tracker.Close()
+	tracker.RegisterEntity("Products")
+
+	token, err := tracker.CurrentToken("Products")
+	if err != nil {
+		t.Fatalf("CurrentToken failed: %v", err)
+	}
+	// Versions: 1 add ID 1, 2 update ID 1, 3 add ID 3, 4 update ID 1.
+	recordProductChanges(t, tracker, ChangeTypeAdded, ChangeTypeUpdated, ChangeTypeAdded, ChangeTypeUpdated)
+
+	if err := tracker.Compact(context.Background()); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	events, _, err := tracker.ChangesSince(token)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	var versions []int64
+	for _, event := range events {
+		versions = append(versions, event.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 3 || versions[2] != 4 {
+		t.Fatalf("expected versions [1 3 4] after compaction, got %v", versions)
+	}
+}
+
+func TestPersistentTrackersShareVersions(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "tracker.db")
+	openTracker := func() *Tracker {
+		db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		tracker, err := NewTrackerWithDB(db)
+		if err != nil {
+			t.Fatalf("create tracker: %v", err)
+		}
+		tracker.RegisterEntity("Products")
+		return tracker
+	}
+	first, second := openTracker(), openTracker()
+
+	token, err := first.CurrentToken("Products")
+	if err != nil {
+		t.Fatalf("CurrentToken failed: %v", err)
+	}
+
+	for i, tracker := range []*Tracker{first, second, first} {
+		version, err := tracker.RecordChange("Products", map[string]interface{}{"ID": i}, map[string]interface{}{"ID": i}, ChangeTypeAdded)
+		if err != nil {
+			t.Fatalf("RecordChange failed: %v", err)
+		}
+		if version != int64(i+1) {
+			t.Fatalf("expected version %d, got %d", i+1, version)
+		}
+	}
+
+	firstEvents, firstToken, err := first.ChangesSince(token)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	secondEvents, secondToken, err := second.ChangesSince(token)
+	if err != nil {
+		t.Fatalf("ChangesSince failed: %v", err)
+	}
+	if len(firstEvents) != 3 || len(secondEvents) != 3 {
+		t.Fatalf("expected both trackers to see 3 events, got %d and %d", len(firstEvents), len(secondEvents))
+	}
+	if firstToken != secondToken {
+		t.Fatalf("expected identical delta tokens, got %q and %q", firstToken, secondToken)
+	}
+}
+
+func TestPersistentTrackerContinuesLegacyHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracker.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&changeRecord{}); err != nil {
+		t.Fatalf("migrate change log: %v", err)
+	}
+	legacy, err := newChangeRecord(ChangeEvent{EntitySet: "Products", KeyValues: map[string]interface{}{"ID": 1}, Type: ChangeTypeAdded, Version: 7})
+	if err != nil {
+		t.Fatalf("build legacy record: %v", err)
+	}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatalf("insert legacy record: %v", err)
+	}
+
+	tracker, err := NewTrackerWithDB(db)
+	if err != nil {
+		t.Fatalf("create tracker: %v", err)
+	}
+	tracker.RegisterEntity("Products")
+
+	version, err := tracker.RecordChange("Products", map[string]interface{}{"ID": 2}, map[string]interface{}{"ID": 2}, ChangeTypeAdded)
+	if err != nil {
+		t.Fatalf("RecordChange failed: %v", err)
+	}
+	if version != 8 {
+		t.Fatalf("expected version to continue after legacy history at 8, got %d", version)
+	}
+}
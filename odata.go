@@ -63,12 +63,14 @@ import (
 	"github.com/nlstn/go-odata/internal/handlers"
 	"github.com/nlstn/go-odata/internal/metadata"
 	"github.com/nlstn/go-odata/internal/observability"
+	"github.com/nlstn/go-odata/internal/outbox"
 	"github.com/nlstn/go-odata/internal/query"
 	"github.com/nlstn/go-odata/internal/service/operations"
 	servrouter "github.com/nlstn/go-odata/internal/service/router"
 	servruntime "github.com/nlstn/go-odata/internal/service/runtime"
 	"github.com/nlstn/go-odata/internal/storage"
 	"github.com/nlstn/go-odata/internal/storage/gormstore"
+	"github.com/nlstn/go-odata/internal/tokensign"
 	"github.com/nlstn/go-odata/internal/trackchanges"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
@@ -352,13 +354,62 @@ type EntityCacheConfig struct {
 	// must be refreshed from the primary database. A value of 0 defaults to
 	// 5 minutes. Only used when Level is CacheLevelFull.
 	TTL time.Duration
+
+	// Invalidator shares invalidations with the other replicas of the service.
+	// Writes to the entity set are published through it, and invalidations
+	// published by other replicas drop the local snapshot and the cached
+	// responses that depend on the entity set before their TTL runs out. When
+	// nil, each replica only sees its own writes. Only used when Level is
+	// CacheLevelFull.
+	Invalidator CacheInvalidator
+}
+
+// EntityOption configures an entity set when it is registered with
+// RegisterEntityWithOptions. EntityCacheConfig, ResponseCacheConfig and
+// TemporalConfig are entity options.
+type EntityOption interface {
+	applyEntityOption(*entityOptions)
+}
+
+// entityOptions collects the options passed to RegisterEntityWithOptions.
+type entityOptions struct {
+	cache         []EntityCacheConfig
+	temporal      []TemporalConfig
+	responseCache []ResponseCacheConfig
+}
+
+func (c EntityCacheConfig) applyEntityOption(o *entityOptions) {
+	o.cache = append(o.cache, c)
+}
+
+// ChangeTrackingRetention bounds the change history kept for delta links.
+// Delta tokens that point before the retained history are answered with
+// 410 Gone, and clients must re-read the entity set to obtain a new delta link.
+type ChangeTrackingRetention struct {
+	// MaxAge removes changes older than the given duration. Zero keeps changes regardless of age.
+	MaxAge time.Duration
+	// MaxEvents keeps at most this many changes per entity set. Zero keeps all changes.
+	MaxEvents int
+	// CompactUpdates removes update events that are followed by a later change
+	// to the same entity. Delta responses only carry the latest state, so
+	// clients receive the same entries from a smaller history.
+	CompactUpdates bool
+	// CompactionInterval is how often retention and compaction run in the
+	// background. Defaults to one minute.
+	CompactionInterval time.Duration
 }
 
 // ServiceConfig controls optional service behaviours.
 type ServiceConfig struct {
 	// PersistentChangeTracking enables database-backed change tracking history.
+	// Versions are then allocated by the database, so several service instances
+	// sharing one database issue consistent delta links.
 	PersistentChangeTracking bool
 
+	// ChangeTrackingRetention bounds the change tracking history. The zero
+	// value keeps all history.
+	ChangeTrackingRetention ChangeTrackingRetention
+
 	// MaxInClauseSize limits the maximum number of values in an IN clause to prevent DoS attacks.
 	// Default: 1000. If set to 0 or left unset, DefaultMaxInClauseSize is used. This limit is always enforced.
 	MaxInClauseSize int
@@ -371,11 +422,35 @@ type ServiceConfig struct {
 	// Default: 100. If set to 0 or left unset, DefaultMaxBatchSize is used. This limit is always enforced.
 	MaxBatchSize int
 
+	// MaxBatchParallelism sets how many independent GET sub-requests of a $batch request may run
+	// concurrently. Reads outside change sets and atomicity groups run in parallel; writes, change
+	// sets, atomicity groups and requests whose dependsOn names a running read wait until earlier
+	// reads have finished. Responses are always returned in request order. Default: 0, which
+	// processes sub-requests sequentially. Parallel reads need a connection pool that shares
+	// data across connections, so in-memory SQLite databases should use a shared cache.
+	MaxBatchParallelism int
+
+	// MaxBatchBodySize limits the size of a $batch request body in bytes. Larger bodies are
+	// rejected with 413 Request Entity Too Large before any sub-request runs. Default: 100 MiB.
+	// If set to 0 or left unset, DefaultMaxBatchBodySize is used; a negative value disables the limit.
+	MaxBatchBodySize int64
+
 	// FTSLanguage sets the PostgreSQL text-search configuration used when building tsvector indexes
 	// and when querying with websearch_to_tsquery.  Defaults to "english" when empty.
 	// Common values: "english", "french", "german", "simple" (disables stemming and stop-words).
 	// This setting has no effect for SQLite, which uses its own built-in tokenizer.
 	FTSLanguage string
+
+	// TokenSigningKey enables HMAC-SHA256 signing of $skiptoken and $deltatoken values. Signed
+	// tokens are bound to the entity set and to the $filter and $orderby of the request that
+	// produced them; tokens that fail verification are rejected with 400 Bad Request.
+	// When empty, tokens are issued and accepted unsigned.
+	TokenSigningKey []byte
+
+	// TokenVerificationKeys lists additional keys that are accepted when verifying tokens, such
+	// as the previous signing key during key rotation. New tokens are always signed with
+	// TokenSigningKey, which must be set when verification keys are configured.
+	TokenVerificationKeys [][]byte
 }
 
 // DefaultNamespace is used when no explicit namespace is configured for the service.
@@ -394,6 +469,9 @@ const (
 	// DefaultMaxBatchSize is the default maximum number of sub-requests allowed in a batch request.
 	// This prevents DoS attacks via large batch payloads while supporting most legitimate use cases.
 	DefaultMaxBatchSize = 100
+
+	// DefaultMaxBatchBodySize is the default maximum size in bytes of a batch request body.
+	DefaultMaxBatchBodySize = 100 << 20
 )
 
 // Service represents an OData service that can handle multiple entities.
@@ -401,7 +479,7 @@ type Service struct {
 	// db holds the GORM database connection
 	db *gorm.DB
 	// store holds the internal storage backend abstraction
-	store storage.Store
+	store storage.GORMStore
 	// entities holds registered entity metadata keyed by entity set name
 	entities map[string]*metadata.EntityMetadata
 	// entityContainerAnnotations holds annotations applied to the entity container
@@ -424,6 +502,16 @@ type Service struct {
 	deltaTracker *trackchanges.Tracker
 	// changeTrackingPersistent indicates whether tracker state is backed by the database
 	changeTrackingPersistent bool
+	// outbox records change events transactionally and delivers them when enabled
+	outbox *outbox.Outbox
+	// changePublisher is the in-process publisher backing SubscribeChanges
+	changePublisher *outbox.ChannelPublisher
+	// responseStore holds the cached HTTP responses of all entity sets
+	responseStore *cache.ResponseStore
+	// virtualExpand serves the $expand of virtual entity sets from their overwrites
+	virtualExpand *handlers.VirtualExpand
+	// cacheSubscriptions cancels the subscriptions of entity caches to their invalidators
+	cacheSubscriptions []func()
 	// router handles HTTP routing for the service
 	router *servrouter.Router
 	// operationsHandler orchestrates action and function execution
@@ -470,6 +558,8 @@ type Service struct {
 	// basePath is the configured base path for mounting the service at a custom path
 	basePath   string
 	basePathMu sync.RWMutex
+	// tokenSigner signs $skiptoken and $deltatoken values when a signing key is configured
+	tokenSigner *tokensign.Signer
 }
 
 // NewService creates a new OData service instance with database connection.
@@ -491,18 +581,30 @@ func NewServiceWithConfig(db *gorm.DB, cfg ServiceConfig) (*Service, error) {
 		tracker *trackchanges.Tracker
 		err     error
 	)
+	trackerConfig := trackchanges.Config{
+		MaxAge:             cfg.ChangeTrackingRetention.MaxAge,
+		MaxEvents:          cfg.ChangeTrackingRetention.MaxEvents,
+		CompactUpdates:     cfg.ChangeTrackingRetention.CompactUpdates,
+		CompactionInterval: cfg.ChangeTrackingRetention.CompactionInterval,
+		Logger:             logger,
+	}
 	if cfg.PersistentChangeTracking {
-		tracker, err = trackchanges.NewTrackerWithDB(db)
+		tracker, err = trackchanges.NewTrackerWithConfig(db, trackerConfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize persistent change tracker: %w", err)
 		}
 	} else {
-		tracker, err = trackchanges.NewTracker()
+		tracker, err = trackchanges.NewTrackerWithConfig(nil, trackerConfig)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize change tracker: %w", err)
 		}
 	}
 
+	tokenSigner, err := tokensign.New(cfg.TokenSigningKey, cfg.TokenVerificationKeys...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signing configuration: %w", err)
+	}
+
 	// Initialize FTS manager for full-text search (SQLite and PostgreSQL)
 	ftsManager := query.NewFTSManagerWithOptions(db, query.FTSOptions{Language: cfg.FTSLanguage})
 
@@ -542,6 +644,9 @@ func NewServiceWithConfig(db *gorm.DB, cfg ServiceConfig) (*Service, error) {
 		maxInClauseSize:            maxInClauseSize,
 		maxExpandDepth:             maxExpandDepth,
 		maxBatchSize:               maxBatchSize,
+		tokenSigner:                tokenSigner,
+		responseStore:              cache.NewResponseStore(),
+		virtualExpand:              handlers.NewVirtualExpand(),
 	}
 	s.metadataHandler.SetNamespace(DefaultNamespace)
 	s.metadataHandler.SetPolicy(s.policy)
@@ -552,6 +657,13 @@ func NewServiceWithConfig(db *gorm.DB, cfg ServiceConfig) (*Service, error) {
 	s.batchHandler = handlers.NewBatchHandlerWithStore(s.store, handlersMap, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.serveHTTP(w, r, false)
 	}), maxBatchSize)
+	s.batchHandler.SetMaxParallelism(cfg.MaxBatchParallelism)
+	s.batchHandler.SetVirtualExpand(s.virtualExpand)
+	maxBatchBodySize := cfg.MaxBatchBodySize
+	if maxBatchBodySize == 0 {
+		maxBatchBodySize = DefaultMaxBatchBodySize
+	}
+	s.batchHandler.SetMaxBodySize(maxBatchBodySize)
 	s.router = servrouter.NewRouter(
 		func(name string) (servrouter.EntityHandler, bool) {
 			handler, ok := s.handlers[name]
@@ -831,9 +943,61 @@ type AsyncConfig struct {
 	JobRetention time.Duration
 	// DisableRetention disables automatic removal of completed jobs from the backing store.
 	DisableRetention bool
+	// RecoveryPolicy decides, per request, what happens to its job when the service
+	// restarts before the job finishes. For AsyncRecoveryRequeue the request method,
+	// URL, body and the headers that shape the response are stored with the job and
+	// replayed on startup. Credential headers such as Authorization and Cookie are
+	// not stored; see ReplayIdentity. Nil, or an empty result, marks interrupted jobs
+	// as failed.
+	RecoveryPolicy func(r *http.Request) AsyncRecoveryPolicy
+	// ReplayIdentity names the caller of a request whose job is requeued on
+	// recovery, in a form that is safe to store with the job, such as a user ID.
+	ReplayIdentity func(r *http.Request) string
+	// RestoreReplayIdentity re-establishes the identity recorded by ReplayIdentity
+	// on a replayed request. The returned context, derived from r.Context(), is
+	// used for the replay; an error fails the job. The pre-request hook runs
+	// afterwards and sees the replayed request without its credential headers.
+	RestoreReplayIdentity func(r *http.Request, identity string) (context.Context, error)
+	// InstanceID identifies this instance among the instances sharing the jobs
+	// table. Each instance only recovers its own interrupted jobs and the jobs
+	// whose lease expired. Use a stable ID per instance, such as the pod name, so
+	// that a restarted instance recovers its jobs at startup; when empty, a random
+	// ID is used and the jobs of a stopped instance are recovered once their lease
+	// expires.
+	InstanceID string
+	// JobLease is how long an unfinished job stays owned by its instance without
+	// a heartbeat. Zero applies async.DefaultJobLease.
+	JobLease time.Duration
+	// AuthorizeJobList enables the job listing at MonitorPathPrefix and decides which
+	// requests may read it. The listing is disabled when nil.
+	AuthorizeJobList func(r *http.Request) bool
+}
+
+// AsyncRecoveryPolicy decides what happens to an async job that is still pending or
+// running when the service restarts.
+type AsyncRecoveryPolicy = async.RecoveryPolicy
+
+const (
+	// AsyncRecoveryFail marks interrupted jobs as failed.
+	AsyncRecoveryFail = async.RecoverFail
+	// AsyncRecoveryRequeue runs interrupted jobs again from their stored request.
+	AsyncRecoveryRequeue = async.RecoverRequeue
+)
+
+// ReportAsyncProgress records the progress of the async job that is executing the
+// request with ctx. Monitor responses for the unfinished job include the latest
+// percent and message. It does nothing when the request is not processed
+// asynchronously.
+func ReportAsyncProgress(ctx context.Context, percent int, message string) error {
+	return async.ReportProgress(ctx, percent, message)
 }
 
 // EnableAsyncProcessing configures asynchronous request handling for the service.
+//
+// Jobs of this instance that were still pending or running when it last
+// stopped, and jobs of other instances whose lease expired, are resolved
+// according to their recovery policy, so call it after registering the entities
+// and operations that re-enqueued jobs use.
 func (s *Service) EnableAsyncProcessing(cfg AsyncConfig) error {
 	normalized := cfg
 	if normalized.MonitorPathPrefix == "" {
@@ -855,10 +1019,19 @@ func (s *Service) EnableAsyncProcessing(cfg AsyncConfig) error {
 		s.runtime.ConfigureAsync(nil, nil, "", 0)
 	}
 
-	managerOptions := make([]async.ManagerOption, 0, 1)
+	managerOptions := make([]async.ManagerOption, 0, 4)
 	if normalized.DisableRetention {
 		managerOptions = append(managerOptions, async.WithRetentionDisabled())
 	}
+	if normalized.AuthorizeJobList != nil {
+		managerOptions = append(managerOptions, async.WithJobListAuthorizer(normalized.AuthorizeJobList))
+	}
+	if normalized.InstanceID != "" {
+		managerOptions = append(managerOptions, async.WithInstanceID(normalized.InstanceID))
+	}
+	if normalized.JobLease > 0 {
+		managerOptions = append(managerOptions, async.WithJobLease(normalized.JobLease))
+	}
 
 	mgr, err := async.NewManager(s.db, normalized.JobRetention, managerOptions...)
 	if err != nil {
@@ -880,8 +1053,18 @@ func (s *Service) EnableAsyncProcessing(cfg AsyncConfig) error {
 		s.asyncQueue = nil
 	}
 
+	var replay async.HandlerFactory
 	if s.runtime != nil {
 		s.runtime.ConfigureAsync(s.asyncManager, s.asyncQueue, s.asyncMonitorPrefix, s.asyncConfig.DefaultRetryInterval)
+		s.runtime.SetAsyncRecoveryPolicy(normalized.RecoveryPolicy)
+		s.runtime.SetAsyncReplayIdentity(normalized.ReplayIdentity, normalized.RestoreReplayIdentity)
+		replay = s.runtime.AsyncReplayHandler(func(w http.ResponseWriter, r *http.Request) {
+			s.serveHTTP(w, r, false)
+		})
+	}
+
+	if _, _, err := mgr.Recover(context.Background(), replay); err != nil {
+		return fmt.Errorf("failed to recover async jobs: %w", err)
 	}
 
 	return nil
@@ -960,6 +1143,19 @@ func (s *Service) Close() error {
 		s.asyncManager.Close()
 	}
 
+	if s.outbox != nil {
+		s.outbox.Close()
+	}
+
+	if s.deltaTracker != nil {
+		s.deltaTracker.Close()
+	}
+
+	for _, cancel := range s.cacheSubscriptions {
+		cancel()
+	}
+	s.cacheSubscriptions = nil
+
 	if s.router != nil {
 		s.router.SetAsyncMonitor("", nil)
 	}
@@ -1005,15 +1201,41 @@ func (s *Service) resolveKeyGenerator(name string) (KeyGenerator, bool) {
 // RegisterEntity registers an entity type with the OData service.
 //
 // Optionally pass an EntityCacheConfig to enable per-entity caching at
-// registration time. When omitted, caching defaults to CacheLevelNone.
+// registration time. When omitted, caching defaults to CacheLevelNone. Use
+// RegisterEntityWithOptions to configure response caching or temporal history.
 func (s *Service) RegisterEntity(entity interface{}, cacheConfigs ...EntityCacheConfig) error {
-	if len(cacheConfigs) > 1 {
-		return fmt.Errorf("expected at most one cache configuration, got %d", len(cacheConfigs))
+	options := make([]EntityOption, len(cacheConfigs))
+	for i, cacheConfig := range cacheConfigs {
+		options[i] = cacheConfig
+	}
+	return s.RegisterEntityWithOptions(entity, options...)
+}
+
+// RegisterEntityWithOptions registers an entity type with the OData service,
+// configured by options. Pass an EntityCacheConfig to enable per-entity
+// caching, a ResponseCacheConfig to cache HTTP responses of GET requests, and
+// a TemporalConfig to keep a queryable history of the entity set. Each option
+// may be passed at most once.
+func (s *Service) RegisterEntityWithOptions(entity interface{}, options ...EntityOption) error {
+	var opts entityOptions
+	for _, option := range options {
+		if option != nil {
+			option.applyEntityOption(&opts)
+		}
+	}
+	if len(opts.cache) > 1 {
+		return fmt.Errorf("expected at most one cache configuration, got %d", len(opts.cache))
+	}
+	if len(opts.temporal) > 1 {
+		return fmt.Errorf("expected at most one temporal configuration, got %d", len(opts.temporal))
+	}
+	if len(opts.responseCache) > 1 {
+		return fmt.Errorf("expected at most one response cache configuration, got %d", len(opts.responseCache))
 	}
 
 	cacheCfg := EntityCacheConfig{Level: CacheLevelNone}
-	if len(cacheConfigs) == 1 {
-		cacheCfg = cacheConfigs[0]
+	if len(opts.cache) == 1 {
+		cacheCfg = opts.cache[0]
 	}
 
 	// Analyze the entity structure
@@ -1044,9 +1266,12 @@ func (s *Service) RegisterEntity(entity interface{}, cacheConfigs ...EntityCache
 	handler := handlers.NewEntityHandlerWithStore(s.store, entityMetadata, s.logger)
 	handler.SetNamespace(s.namespace)
 	handler.SetEntitiesMetadata(s.entities)
+	handler.SetEntityHandlers(s.handlers)
 	handler.SetDeltaTracker(s.deltaTracker)
+	handler.SetOutbox(s.outbox)
 	handler.SetFTSManager(s.ftsManager)
 	handler.SetPolicy(s.policy)
+	handler.SetResponseStore(s.responseStore)
 	handler.SetKeyGeneratorResolver(func(name string) (func(context.Context) (interface{}, error), bool) {
 		generator, ok := s.resolveKeyGenerator(name)
 		if !ok {
@@ -1067,6 +1292,7 @@ func (s *Service) RegisterEntity(entity interface{}, cacheConfigs ...EntityCache
 	// Set security limits
 	handler.SetMaxInClauseSize(s.maxInClauseSize)
 	handler.SetMaxExpandDepth(s.maxExpandDepth)
+	handler.SetTokenSigner(s.tokenSigner)
 	// Propagate schema version if already configured
 	if s.schemaVersion != "" {
 		handler.SetSchemaVersion(s.schemaVersion)
@@ -1076,6 +1302,25 @@ func (s *Service) RegisterEntity(entity interface{}, cacheConfigs ...EntityCache
 	if err := s.configureEntityCache(entityMetadata, handler, cacheCfg); err != nil {
 		return err
 	}
+	if len(opts.responseCache) == 1 {
+		var entityCacheInvalidator CacheInvalidator
+		if cacheCfg.Level == CacheLevelFull {
+			entityCacheInvalidator = cacheCfg.Invalidator
+		}
+		if err := s.configureResponseCache(entityMetadata, handler, opts.responseCache[0], entityCacheInvalidator); err != nil {
+			return err
+		}
+	}
+	if len(opts.temporal) == 1 {
+		if err := s.configureTemporal(entityMetadata, handler, opts.temporal[0]); err != nil {
+			return err
+		}
+	}
+	if entityMetadata.SoftDeleteProperty != nil {
+		if err := s.configureSoftDelete(entityMetadata, handler); err != nil {
+			return err
+		}
+	}
 
 	// The set of exposed entity sets changed; drop the cached service document.
 	s.serviceDocumentHandler.ClearCache()
@@ -1105,6 +1350,63 @@ func (s *Service) EnableChangeTracking(entitySetName string) error {
 	return nil
 }
 
+// EnableChangeStream lets clients subscribe to live changes of an entity set over
+// Server-Sent Events. Change tracking is enabled for the entity set if it is not
+// already. A GET of the collection with "Accept: text/event-stream" then streams
+// one delta entry per change, optionally restricted by $filter and $select. Each
+// event ID is a resumable delta token; clients reconnecting with Last-Event-ID
+// (or $deltatoken) receive every change they missed. heartbeat sets the interval
+// of keep-alive comments on idle streams; zero uses 15 seconds.
+//
+// Example:
+//
+//	if err := service.EnableChangeStream("Products", 0); err != nil {
+//	    log.Fatal(err)
+//	}
+//	// GET /Products?$filter=Price gt 100
+//	// Accept: text/event-stream
+func (s *Service) EnableChangeStream(entitySetName string, heartbeat time.Duration) error {
+	if err := s.EnableChangeTracking(entitySetName); err != nil {
+		return err
+	}
+	return s.handlers[entitySetName].EnableChangeStream(heartbeat)
+}
+
+// EnableCollectionStreaming makes collection reads of the entity set stream
+// their results from a database cursor. Each entity is written to the response
+// as soon as it is scanned, so exports that opt out of server paging no longer
+// hold the whole result in memory. Clients can request the same behavior for a
+// single request with Prefer: odata.streaming.
+//
+// Streaming applies to reads of plain entity rows. Requests using $expand,
+// $apply, $compute, $index, Prefer: omit-values, change tracking, non-JSON
+// formats, or entity sets with an after-read collection hook are answered as
+// usual.
+//
+// Example:
+//
+//	if err := service.EnableCollectionStreaming("AuditLogs"); err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *Service) EnableCollectionStreaming(entitySetName string) error {
+	handler, exists := s.handlers[entitySetName]
+	if !exists {
+		return fmt.Errorf("entity set '%s' is not registered", entitySetName)
+	}
+	handler.EnableCollectionStreaming()
+	return nil
+}
+
+// CompactChangeHistory applies ServiceConfig.ChangeTrackingRetention to the
+// change history of every tracked entity set immediately instead of waiting for
+// the background compaction loop. It does nothing when no retention is configured.
+func (s *Service) CompactChangeHistory(ctx context.Context) error {
+	if s.deltaTracker == nil {
+		return nil
+	}
+	return s.deltaTracker.Compact(ctx)
+}
+
 func (s *Service) configureEntityCache(entityMeta *metadata.EntityMetadata, handler *handlers.EntityHandler, cfg EntityCacheConfig) error {
 	if entityMeta == nil {
 		return fmt.Errorf("entity metadata is nil")
@@ -1133,6 +1435,17 @@ func (s *Service) configureEntityCache(entityMeta *metadata.EntityMetadata, hand
 		return fmt.Errorf("failed to create entity cache for '%s': %w", entityMeta.EntitySetName, err)
 	}
 
+	if cfg.Invalidator != nil {
+		cancel, err := entityCache.Distribute(entityMeta.EntitySetName, cfg.Invalidator)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe entity cache for '%s' to invalidations: %w", entityMeta.EntitySetName, err)
+		}
+		s.cacheSubscriptions = append(s.cacheSubscriptions, cancel)
+		if err := s.subscribeResponseStore(entityMeta.EntitySetName, cfg.Invalidator); err != nil {
+			return err
+		}
+	}
+
 	handler.SetEntityCache(entityCache)
 	s.logger.Debug("Enabled entity caching",
 		"entitySet", entityMeta.EntitySetName,
@@ -1173,8 +1486,10 @@ func (s *Service) RegisterSingleton(entity interface{}, singletonName string) er
 	handler := handlers.NewEntityHandlerWithStore(s.store, singletonMetadata, s.logger)
 	handler.SetNamespace(s.namespace)
 	handler.SetEntitiesMetadata(s.entities)
+	handler.SetEntityHandlers(s.handlers)
 	handler.SetFTSManager(s.ftsManager)
 	handler.SetPolicy(s.policy)
+	handler.SetResponseStore(s.responseStore)
 	handler.SetKeyGeneratorResolver(func(name string) (func(context.Context) (interface{}, error), bool) {
 		generator, ok := s.resolveKeyGenerator(name)
 		if !ok {
@@ -1195,6 +1510,7 @@ func (s *Service) RegisterSingleton(entity interface{}, singletonName string) er
 	// Set security limits
 	handler.SetMaxInClauseSize(s.maxInClauseSize)
 	handler.SetMaxExpandDepth(s.maxExpandDepth)
+	handler.SetTokenSigner(s.tokenSigner)
 	// Propagate schema version if already configured
 	if s.schemaVersion != "" {
 		handler.SetSchemaVersion(s.schemaVersion)
@@ -1267,8 +1583,10 @@ func (s *Service) RegisterVirtualEntity(entity interface{}) error {
 	handler := handlers.NewEntityHandlerWithStore(s.store, entityMetadata, s.logger)
 	handler.SetNamespace(s.namespace)
 	handler.SetEntitiesMetadata(s.entities)
+	handler.SetEntityHandlers(s.handlers)
 	handler.SetFTSManager(s.ftsManager)
 	handler.SetPolicy(s.policy)
+	handler.SetResponseStore(s.responseStore)
 	handler.SetKeyGeneratorResolver(func(name string) (func(context.Context) (interface{}, error), bool) {
 		generator, ok := s.resolveKeyGenerator(name)
 		if !ok {
@@ -1289,11 +1607,13 @@ func (s *Service) RegisterVirtualEntity(entity interface{}) error {
 	// Set security limits
 	handler.SetMaxInClauseSize(s.maxInClauseSize)
 	handler.SetMaxExpandDepth(s.maxExpandDepth)
+	handler.SetTokenSigner(s.tokenSigner)
 	// Propagate schema version if already configured
 	if s.schemaVersion != "" {
 		handler.SetSchemaVersion(s.schemaVersion)
 	}
 	s.handlers[entityMetadata.EntitySetName] = handler
+	s.virtualExpand.Register(handler)
 
 	// The set of exposed entity sets changed; drop the cached service document.
 	s.serviceDocumentHandler.ClearCache()
@@ -0,0 +1,158 @@
+package odata
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/outbox"
+	"github.com/nlstn/go-odata/internal/trackchanges"
+)
+
+// ChangeType identifies the kind of change described by a ChangeEvent.
+type ChangeType = trackchanges.ChangeType
+
+const (
+	// ChangeTypeAdded indicates that an entity was created.
+	ChangeTypeAdded = trackchanges.ChangeTypeAdded
+	// ChangeTypeUpdated indicates that an entity was updated or replaced.
+	ChangeTypeUpdated = trackchanges.ChangeTypeUpdated
+	// ChangeTypeDeleted indicates that an entity was deleted.
+	ChangeTypeDeleted = trackchanges.ChangeTypeDeleted
+)
+
+// ChangeEvent is a committed create, update or delete of an entity. KeyValues
+// and Data are keyed by JSON property name and hold JSON-decoded values
+// (numbers are float64). Data is nil for deleted entities.
+type ChangeEvent = outbox.Event
+
+// ChangePublisher delivers change events from the outbox to downstream
+// consumers such as message brokers, search indexers or cache invalidators.
+// Returning an error causes the whole batch to be retried with backoff, so
+// implementations should be idempotent with respect to ChangeEvent.ID.
+type ChangePublisher = outbox.Publisher
+
+// ChangePublisherFunc adapts a function to the ChangePublisher interface.
+type ChangePublisherFunc = outbox.PublisherFunc
+
+// ChannelPublisher is the in-process ChangePublisher. Each subscriber receives
+// every event on its own channel; slow subscribers apply backpressure to the
+// delivery loop rather than dropping events. Events committed while nobody is
+// subscribed stay in the outbox and are delivered to the next subscriber.
+type ChannelPublisher = outbox.ChannelPublisher
+
+// ErrNoSubscribers is returned by a ChannelPublisher, and by FlushOutbox, when
+// no subscriber received the pending events. The events stay in the outbox.
+var ErrNoSubscribers = outbox.ErrNoSubscribers
+
+// NewChannelPublisher creates an in-process ChangePublisher with no subscribers.
+func NewChannelPublisher() *ChannelPublisher {
+	return outbox.NewChannelPublisher()
+}
+
+// OutboxConfig configures the transactional change-event outbox.
+type OutboxConfig struct {
+	// Publisher receives committed change events. When nil, an in-process
+	// ChannelPublisher is used and events are available through SubscribeChanges.
+	Publisher ChangePublisher
+	// PollInterval is how often the delivery loop looks for undelivered events
+	// when no write has signalled it. Defaults to one second.
+	PollInterval time.Duration
+	// BatchSize limits the number of events passed to a single Publish call. Defaults to 100.
+	BatchSize int
+	// MinRetryBackoff and MaxRetryBackoff bound the exponential backoff between
+	// failed deliveries. They default to 100ms and 30s.
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+	// Consumer names the delivery checkpoint. Defaults to "default".
+	Consumer string
+	// Retention is how long delivered events stay in _odata_outbox before the
+	// delivery loop deletes them. Defaults to seven days; a negative value keeps
+	// them forever.
+	Retention time.Duration
+}
+
+// EnableOutbox records a ChangeEvent for every create, update and delete of a
+// database-backed entity set and delivers the events to cfg.Publisher.
+//
+// Events are written to the _odata_outbox table inside the same transaction as
+// the entity write, including $batch change sets, so an event is published if
+// and only if its write committed. A background loop delivers undelivered
+// events in order, retries failed batches with exponential backoff and records
+// a checkpoint in _odata_outbox_checkpoints. Delivery is at-least-once: after a
+// crash, events that were published but not yet checkpointed are published again.
+// Records that cannot be decoded are dead-lettered: they are logged, marked with
+// failed_at and error, and skipped so the events behind them are still delivered.
+// Entity sets served by overwrite handlers or a Store do not produce events.
+//
+// Calling EnableOutbox again replaces the previous configuration. Close stops
+// the delivery loop.
+//
+// Example:
+//
+//	if err := service.EnableOutbox(odata.OutboxConfig{}); err != nil {
+//	    log.Fatal(err)
+//	}
+//	events, cancel, _ := service.SubscribeChanges(64)
+//	defer cancel()
+//	go func() {
+//	    for event := range events {
+//	        indexer.Apply(event.EntitySet, event.Type, event.KeyValues, event.Data)
+//	    }
+//	}()
+func (s *Service) EnableOutbox(cfg OutboxConfig) error {
+	publisher := cfg.Publisher
+	channelPublisher, _ := publisher.(*ChannelPublisher)
+	if publisher == nil {
+		channelPublisher = outbox.NewChannelPublisher()
+		publisher = channelPublisher
+	}
+
+	ob, err := outbox.New(s.db, publisher, outbox.Config{
+		PollInterval:    cfg.PollInterval,
+		BatchSize:       cfg.BatchSize,
+		MinRetryBackoff: cfg.MinRetryBackoff,
+		MaxRetryBackoff: cfg.MaxRetryBackoff,
+		Consumer:        cfg.Consumer,
+		Retention:       cfg.Retention,
+		Logger:          s.logger,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to configure outbox: %w", err)
+	}
+
+	if s.outbox != nil {
+		s.outbox.Close()
+	}
+	s.outbox = ob
+	s.changePublisher = channelPublisher
+	for _, handler := range s.handlers {
+		handler.SetOutbox(ob)
+	}
+	ob.Start()
+	return nil
+}
+
+// SubscribeChanges returns a channel that receives every committed change event
+// together with a function that ends the subscription. It requires EnableOutbox
+// to have been called without a Publisher or with a *ChannelPublisher.
+func (s *Service) SubscribeChanges(buffer int) (<-chan ChangeEvent, func(), error) {
+	if s.changePublisher == nil {
+		return nil, nil, fmt.Errorf("change subscriptions require EnableOutbox with an in-process publisher")
+	}
+	events, cancel := s.changePublisher.Subscribe(buffer)
+	// Deliver the events that waited for a subscriber right away.
+	if s.outbox != nil {
+		s.outbox.Notify()
+	}
+	return events, cancel, nil
+}
+
+// FlushOutbox delivers all pending change events synchronously, retrying until
+// the outbox is empty or ctx is done. It is useful before shutdown and in tests.
+func (s *Service) FlushOutbox(ctx context.Context) error {
+	if s.outbox == nil {
+		return fmt.Errorf("outbox is not enabled")
+	}
+	return s.outbox.Flush(ctx)
+}
@@ -0,0 +1,137 @@
+package odata
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/nlstn/go-odata/internal/handlers"
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+// ResponseCacheConfig caches the HTTP responses of GET requests for an entity
+// set: its collection, single entities and $count. It is an entity option of
+// RegisterEntityWithOptions.
+//
+// Responses are keyed on the request URL with its query options in canonical
+// order, the Vary headers and a partition key. A response without an ETag of
+// its own gets a weak ETag computed from its body, and requests whose
+// If-None-Match names it are answered with 304 Not Modified. Writes through the
+// service drop the cached responses of the written entity set and of every
+// entity set that can reach it through navigation properties.
+type ResponseCacheConfig struct {
+	// TTL is how long a response is served from the cache. A value of 0
+	// defaults to 1 minute.
+	TTL time.Duration
+
+	// MaxEntries bounds the number of cached responses of the entity set. A
+	// value of 0 defaults to 1000; a negative value removes the bound.
+	MaxEntries int
+
+	// MaxBodySize bounds the size in bytes of a cached response body. A
+	// response that grows beyond it, such as a large streamed collection or a
+	// CSV export, is sent as it is produced and not cached. A value of 0
+	// defaults to 1 MiB; a negative value removes the bound.
+	MaxBodySize int
+
+	// CacheControl is sent as the Cache-Control header of cached responses,
+	// for example "private, max-age=30". Empty sends none.
+	CacheControl string
+
+	// Vary lists request headers that select different responses, such as
+	// Accept-Language. Accept, Prefer and OData-MaxVersion are always
+	// included. The headers are part of the cache key and sent in Vary.
+	Vary []string
+
+	// PartitionKey separates the responses of different principals or
+	// tenants. It defaults to the principal, roles, claims and scopes stored in
+	// the request context (see AuthContext). Set it when responses depend on
+	// anything else about the caller, such as a tenant header read by hooks.
+	PartitionKey func(r *http.Request) string
+
+	// Invalidator shares invalidations with the other replicas of the service.
+	// Writes to the entity set are published through it, and invalidations
+	// published by other replicas drop the cached responses that depend on the
+	// entity set. Writes to other entity sets reach the other replicas when
+	// those entity sets publish through an invalidator as well, through their
+	// ResponseCacheConfig or EntityCacheConfig. When nil, each replica only
+	// sees its own writes.
+	Invalidator CacheInvalidator
+}
+
+func (c ResponseCacheConfig) applyEntityOption(o *entityOptions) {
+	o.responseCache = append(o.responseCache, c)
+}
+
+// configureResponseCache enables the response cache of an entity set.
+// entityCacheInvalidator is the invalidator the entity set's cache already
+// publishes through and subscribes to, if any.
+func (s *Service) configureResponseCache(entityMeta *metadata.EntityMetadata, handler *handlers.EntityHandler, cfg ResponseCacheConfig, entityCacheInvalidator CacheInvalidator) error {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	maxEntries := cfg.MaxEntries
+	switch {
+	case maxEntries == 0:
+		maxEntries = 1000
+	case maxEntries < 0:
+		maxEntries = 0
+	}
+	maxBodySize := cfg.MaxBodySize
+	switch {
+	case maxBodySize == 0:
+		maxBodySize = 1 << 20
+	case maxBodySize < 0:
+		maxBodySize = 0
+	}
+
+	var invalidator CacheInvalidator
+	if cfg.Invalidator != nil && !sameInvalidator(cfg.Invalidator, entityCacheInvalidator) {
+		invalidator = cfg.Invalidator
+	}
+	if err := handler.EnableResponseCache(handlers.ResponseCachePolicy{
+		TTL:          ttl,
+		MaxEntries:   maxEntries,
+		MaxBodySize:  maxBodySize,
+		CacheControl: cfg.CacheControl,
+		Vary:         cfg.Vary,
+		PartitionKey: cfg.PartitionKey,
+		Invalidator:  invalidator,
+	}); err != nil {
+		return fmt.Errorf("failed to enable response caching for '%s': %w", entityMeta.EntitySetName, err)
+	}
+	if invalidator != nil {
+		if err := s.subscribeResponseStore(entityMeta.EntitySetName, invalidator); err != nil {
+			return err
+		}
+	}
+	s.logger.Debug("Enabled response caching",
+		"entitySet", entityMeta.EntitySetName,
+		"ttl", ttl,
+		"maxEntries", maxEntries,
+		"maxBodySize", maxBodySize)
+	return nil
+}
+
+// subscribeResponseStore drops the cached responses that depend on the entity
+// set whenever inv announces a write to it, on this or any other replica.
+func (s *Service) subscribeResponseStore(entitySet string, inv CacheInvalidator) error {
+	cancel, err := inv.Subscribe(entitySet, func() { s.responseStore.Invalidate(entitySet) })
+	if err != nil {
+		return fmt.Errorf("failed to subscribe response cache for '%s' to invalidations: %w", entitySet, err)
+	}
+	s.cacheSubscriptions = append(s.cacheSubscriptions, cancel)
+	return nil
+}
+
+// sameInvalidator reports whether a and b are the same invalidator, so that
+// writes are not published twice.
+func sameInvalidator(a, b CacheInvalidator) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	typ := reflect.TypeOf(a)
+	return typ == reflect.TypeOf(b) && typ.Comparable() && a == b
+}
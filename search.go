@@ -0,0 +1,64 @@
+package odata
+
+import (
+	"fmt"
+
+	"github.com/nlstn/go-odata/internal/query"
+)
+
+// SearchProvider answers $search for an entity set with ranked matches. The
+// service restricts the collection to the returned keys, annotates every entity
+// with its score as @search.score and sorts by it for $orderby=$search.score.
+// Returning ErrSearchNotSupported from Search makes the service evaluate the
+// expression in memory instead.
+//
+// Providers that keep their own copy of the searchable data can implement
+// InvalidateSearch(entitySet string); it is called after every write to an
+// entity set the provider is registered for. Providers that can refresh single
+// entities implement UpdateSearch(ctx, req, keys) instead, which is called with
+// the keys of the written entities.
+type SearchProvider = query.SearchProvider
+
+// SearchRequest describes a $search passed to a SearchProvider.
+type SearchRequest = query.SearchRequest
+
+// SearchHit is an entity matched by a SearchProvider, identified by its key
+// values and scored by relevance.
+type SearchHit = query.SearchHit
+
+// SearchIndex is an in-process inverted index implementing SearchProvider for
+// databases without full-text search. It indexes the searchable properties of
+// an entity set on first use, ranks matches with BM25 and updates the written
+// entities after writes through the service. It does not see the writes of
+// other replicas; subscribe InvalidateSearch to a CacheInvalidator for that.
+type SearchIndex = query.SearchIndex
+
+// ErrSearchNotSupported is returned by a SearchProvider that cannot evaluate a
+// search expression.
+var ErrSearchNotSupported = query.ErrSearchNotSupported
+
+// NewSearchIndex creates an empty in-process SearchIndex. One index can be
+// registered for several entity sets.
+func NewSearchIndex() *SearchIndex {
+	return query.NewSearchIndex()
+}
+
+// RegisterSearchProvider sets the provider answering $search for an entity set.
+// Without a registered provider, matches are ranked by the database's full-text
+// search when available (SQLite FTS, PostgreSQL tsvector) and $search is
+// otherwise evaluated in memory without scores.
+//
+// Example:
+//
+//	index := odata.NewSearchIndex()
+//	if err := service.RegisterSearchProvider("Products", index); err != nil {
+//	    log.Fatal(err)
+//	}
+func (s *Service) RegisterSearchProvider(entitySetName string, provider SearchProvider) error {
+	handler, exists := s.handlers[entitySetName]
+	if !exists {
+		return fmt.Errorf("entity set '%s' is not registered", entitySetName)
+	}
+	handler.SetSearchProvider(provider)
+	return nil
+}
@@ -0,0 +1,61 @@
+package odata
+
+import (
+	"net/http"
+
+	"github.com/nlstn/go-odata/internal/actions"
+	"github.com/nlstn/go-odata/internal/handlers"
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/softdelete"
+)
+
+// RestoreActionName is the name of the action bound to every soft delete entity
+// set. An entity set becomes a soft delete entity set when its entity declares a
+// *time.Time field tagged odata:"softdelete":
+//
+//	type Product struct {
+//	    ID        int        `json:"ID" gorm:"primaryKey" odata:"key"`
+//	    Name      string     `json:"Name"`
+//	    DeletedAt *time.Time `json:"DeletedAt,omitempty" odata:"softdelete"`
+//	}
+//
+// DELETE then stamps DeletedAt instead of removing the row, and every read —
+// collections, single entities, $count, navigation and $expand — excludes
+// deleted entities. Deletions still reach delta responses as @removed entries,
+// the outbox and temporal history.
+//
+// Callers a policy grants OperationReadDeleted may list deleted entities with the
+// $deleted query option (include or only), and callers granted OperationRestore
+// may bring one back by invoking the bound action:
+//
+//	GET  /Products?$deleted=only
+//	POST /Products(1)/Restore
+//
+// Both are forbidden when the service has no authorization policy.
+const RestoreActionName = "Restore"
+
+// configureSoftDelete hides the deleted rows of a soft delete entity set from
+// database reads and binds the Restore action to it.
+func (s *Service) configureSoftDelete(entityMeta *metadata.EntityMetadata, handler *handlers.EntityHandler) error {
+	if err := softdelete.Register(s.db); err != nil {
+		return err
+	}
+
+	if err := s.RegisterAction(actions.ActionDefinition{
+		Name:           RestoreActionName,
+		IsBound:        true,
+		EntitySet:      entityMeta.EntitySetName,
+		ReturnType:     entityMeta.EntityType,
+		IncludeDeleted: true,
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, _ map[string]interface{}) error {
+			return handler.RestoreEntity(w, r, ctx)
+		},
+	}); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Enabled soft delete",
+		"entitySet", entityMeta.EntitySetName,
+		"property", entityMeta.SoftDeleteProperty.Name)
+	return nil
+}
@@ -5,6 +5,7 @@ package odata
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"regexp"
 
 	"github.com/mattn/go-sqlite3"
@@ -98,3 +99,43 @@ func ensureSQLiteRegexp(db *gorm.DB) error {
 		}, true)
 	})
 }
+
+// registerSQLiteGeospatialFunctions registers the pure-Go spatial SQL
+// functions (see geospatialSQLFunctions) on the connection the service uses.
+// Like REGEXP they are per-connection, so this relies on the single pinned
+// connection set up by ensureSQLiteRegexp.
+func registerSQLiteGeospatialFunctions(db *gorm.DB) error {
+	if db == nil || db.Name() != "sqlite" {
+		return fmt.Errorf("the pure-Go geospatial functions require SQLite")
+	}
+	if err := ensureSQLiteRegexp(db); err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	return conn.Raw(func(driverConn interface{}) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unsupported SQLite driver connection %T", driverConn)
+		}
+		for name, impl := range geospatialSQLFunctions() {
+			if err := sqliteConn.RegisterFunc(name, impl, true); err != nil {
+				return fmt.Errorf("register %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package odata
+
+import (
+	"fmt"
+
+	"github.com/nlstn/go-odata/internal/metadata"
+	"github.com/nlstn/go-odata/internal/storage"
+	"github.com/nlstn/go-odata/internal/storage/memstore"
+)
+
+// Store is the record-level storage contract for an entity set that is not
+// backed by the service's GORM database. The service parses and validates the
+// request, then hands the store the resulting QueryOptions ($filter AST,
+// $orderby, $top/$skip, $select, $expand, $search, $count) instead of SQL, so
+// key-value stores, REST backends and in-memory data can serve an entity set
+// with full query option support.
+//
+// Keys are maps from key property name to typed key value, for example
+// {"id": 42} or {"orderID": 1, "productID": 5}. Implementations report a
+// missing record with ErrStoreNotFound, a duplicate key on Create with
+// ErrStoreConflict (409), and query constructs they cannot evaluate with
+// ErrStoreUnsupportedQuery (501). $select is applied by the service after Query
+// returns; stores may use it as a projection hint.
+type Store = storage.EntityStore
+
+// StoreQuery carries the parsed query options passed to a Store.
+type StoreQuery = storage.Query
+
+// StoreResult is the page of entities (and optional total count) returned by Store.Query.
+type StoreResult = storage.Result
+
+// MemoryStore is the in-memory reference implementation of Store. It evaluates
+// $filter, $orderby, $top, $skip, $search and $count against Go values and is
+// safe for concurrent use.
+type MemoryStore = memstore.Store
+
+// Sentinel errors returned by Store implementations.
+var (
+	// ErrStoreNotFound indicates the requested record does not exist. Maps to HTTP 404.
+	ErrStoreNotFound = storage.ErrNotFound
+
+	// ErrStoreConflict indicates a record with the same key already exists. Maps to HTTP 409.
+	ErrStoreConflict = storage.ErrConflict
+
+	// ErrStoreUnsupportedQuery indicates the store cannot evaluate part of the query. Maps to HTTP 501.
+	ErrStoreUnsupportedQuery = storage.ErrUnsupportedQuery
+)
+
+// NewMemoryStore creates an empty in-memory store for the given entity type.
+//
+// Example:
+//
+//	store, err := odata.NewMemoryStore(&Product{})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	_, _ = store.Create(ctx, &Product{ID: 1, Name: "Laptop"})
+//	service.RegisterEntityWithStore(&Product{}, store)
+func NewMemoryStore(entity interface{}) (*MemoryStore, error) {
+	return memstore.New(entity)
+}
+
+// RegisterEntityWithStore registers an entity set whose data lives in store
+// rather than in the service's database. The entity is registered like a
+// virtual entity (no table is migrated) and every operation — collection reads,
+// $count, single-entity reads, create, update and delete — is delegated to the
+// store with the parsed query options. Query option validation, capability
+// restrictions, authorization policy filters, $select projection and response
+// serialization behave exactly as for database-backed entity sets.
+//
+// Example:
+//
+//	store, _ := odata.NewMemoryStore(&Product{})
+//	if err := service.RegisterEntityWithStore(&Product{}, store); err != nil {
+//	    log.Fatal(err)
+//	}
+//	// GET /Products?$filter=contains(name,'Pro')&$orderby=price desc&$top=5&$count=true
+func (s *Service) RegisterEntityWithStore(entity interface{}, store Store) error {
+	if store == nil {
+		return fmt.Errorf("store must not be nil")
+	}
+	entityMetadata, err := metadata.AnalyzeVirtualEntity(entity)
+	if err != nil {
+		return fmt.Errorf("failed to analyze virtual entity: %w", err)
+	}
+	if err := s.RegisterVirtualEntity(entity); err != nil {
+		return err
+	}
+	s.handlers[entityMetadata.EntitySetName].SetEntityStore(store)
+
+	s.logger.Debug("Registered store-backed entity",
+		"entity", entityMetadata.EntityName,
+		"entitySet", entityMetadata.EntitySetName)
+	return nil
+}
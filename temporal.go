@@ -0,0 +1,61 @@
+package odata
+
+import (
+	"github.com/nlstn/go-odata/internal/handlers"
+	"github.com/nlstn/go-odata/internal/metadata"
+)
+
+// TemporalConfig makes an entity set temporal (system-versioned). Pass it to
+// RegisterEntityWithOptions to keep every version of the entity set's entities
+// in a history table. The history is written inside the transaction of each create,
+// update and delete, including the transaction exposed to hooks through
+// TransactionFromContext, so it commits or rolls back together with the change.
+//
+// A temporal entity set advertises the Org.OData.Temporal.V1 vocabulary and
+// answers the $at, $from and $to query options:
+//
+//	GET /Products?$at=2024-05-14T09:00:00Z
+//	GET /Products(1)?$at=2024-05-14
+//	GET /Products?$from=2024-05-01&$to=2024-06-01
+//
+// $at returns the entities as they were at the given point in time. $from and
+// $to return every version valid at some point of the period, annotated with
+// @Org.OData.Temporal.V1.From and @Org.OData.Temporal.V1.To.
+type TemporalConfig struct {
+	// HistoryTable names the table holding the versions. It defaults to the
+	// entity table's name followed by "_history". The table is created or
+	// migrated when the entity is registered.
+	HistoryTable string
+}
+
+func (c TemporalConfig) applyEntityOption(o *entityOptions) {
+	o.temporal = append(o.temporal, c)
+}
+
+// configureTemporal creates the history table of a temporal entity set and
+// advertises its temporal support in the service metadata.
+func (s *Service) configureTemporal(entityMeta *metadata.EntityMetadata, handler *handlers.EntityHandler, cfg TemporalConfig) error {
+	if err := handler.EnableTemporal(cfg.HistoryTable); err != nil {
+		return err
+	}
+
+	if entityMeta.EntitySetAnnotations == nil {
+		entityMeta.EntitySetAnnotations = metadata.NewAnnotationCollection()
+	}
+	entityMeta.EntitySetAnnotations.AddTerm(metadata.TemporalApplicationTimeSupport, map[string]interface{}{
+		"UnitOfTime": map[string]interface{}{
+			"@type": "Org.OData.Temporal.V1.UnitOfTimeDateTimeOffset",
+		},
+		"Timeline": map[string]interface{}{
+			"@type": "Org.OData.Temporal.V1.TimelineSnapshot",
+		},
+	})
+	if s.metadataHandler != nil {
+		s.metadataHandler.ClearCache()
+	}
+
+	s.logger.Debug("Enabled temporal history",
+		"entitySet", entityMeta.EntitySetName,
+		"historyTable", entityMeta.TemporalHistoryTable)
+	return nil
+}
@@ -0,0 +1,309 @@
+package odata_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type asyncExportTask struct {
+	calls   atomic.Int32
+	block   chan struct{}
+	started chan struct{}
+}
+
+// newAsyncExportService registers an unbound Export action that reports
+// progress and then waits for task.block, or for the request to be canceled.
+func newAsyncExportService(t *testing.T, db *gorm.DB, task *asyncExportTask, cfg odata.AsyncConfig) *odata.Service {
+	t.Helper()
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(PreferTestProduct{}); err != nil {
+		t.Fatalf("Failed to register entity: %v", err)
+	}
+	if err := service.RegisterAction(odata.ActionDefinition{
+		Name:       "Export",
+		ReturnType: nil,
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) error {
+			task.calls.Add(1)
+			if err := odata.ReportAsyncProgress(r.Context(), 25, "exporting"); err != nil {
+				return err
+			}
+			if task.started != nil {
+				select {
+				case task.started <- struct{}{}:
+				default:
+				}
+			}
+			if task.block != nil {
+				select {
+				case <-task.block:
+				case <-r.Context().Done():
+					return r.Context().Err()
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+	}); err != nil {
+		t.Fatalf("Failed to register action: %v", err)
+	}
+	if cfg.MonitorPathPrefix == "" {
+		cfg.MonitorPathPrefix = "/$async/jobs/"
+	}
+	if err := service.EnableAsyncProcessing(cfg); err != nil {
+		t.Fatalf("failed to enable async processing: %v", err)
+	}
+	t.Cleanup(service.AsyncManager().Close)
+	return service
+}
+
+func openAsyncRecoveryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "async_recovery.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&PreferTestProduct{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func startAsyncExport(t *testing.T, service *odata.Service) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/Export", nil)
+	req.Header.Set("Prefer", "respond-async")
+	rec := httptest.NewRecorder()
+	service.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected async acknowledgement, got %d: %s", rec.Code, rec.Body.String())
+	}
+	return rec.Header().Get("Location")
+}
+
+func TestAsyncRecovery_RequeuesOrFailsAfterRestart(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		policy     odata.AsyncRecoveryPolicy
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"requeue", odata.AsyncRecoveryRequeue, http.StatusNoContent, 1},
+		{"fail", odata.AsyncRecoveryFail, http.StatusInternalServerError, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := openAsyncRecoveryDB(t)
+			cfg := odata.AsyncConfig{
+				// The restarted instance keeps its ID, like a pod that is restarted.
+				InstanceID: "node-1",
+				RecoveryPolicy: func(r *http.Request) odata.AsyncRecoveryPolicy {
+					if strings.HasSuffix(r.URL.Path, "/Export") {
+						return tc.policy
+					}
+					return ""
+				},
+			}
+
+			// The first instance never finishes the export, like a process that stopped.
+			stopped := &asyncExportTask{block: make(chan struct{}), started: make(chan struct{}, 1)}
+			t.Cleanup(func() { close(stopped.block) })
+			first := newAsyncExportService(t, db, stopped, cfg)
+			location := startAsyncExport(t, first)
+			<-stopped.started
+
+			restarted := &asyncExportTask{}
+			second := newAsyncExportService(t, db, restarted, cfg)
+
+			rec := waitForMonitorCompletion(t, second, location)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("monitor status = %d, want %d; body %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if got := restarted.calls.Load(); got != tc.wantCalls {
+				t.Errorf("restarted instance ran the action %d times, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+type asyncReplayUserKey struct{}
+
+func TestAsyncRecovery_ReplaysUnderRestoredIdentity(t *testing.T) {
+	db := openAsyncRecoveryDB(t)
+	restored := make(chan string, 1)
+	cfg := odata.AsyncConfig{
+		InstanceID: "node-1",
+		RecoveryPolicy: func(*http.Request) odata.AsyncRecoveryPolicy {
+			return odata.AsyncRecoveryRequeue
+		},
+		ReplayIdentity: func(r *http.Request) string {
+			return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		},
+		RestoreReplayIdentity: func(r *http.Request, identity string) (context.Context, error) {
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("replayed request carries credentials: %v", r.Header)
+			}
+			restored <- identity
+			return context.WithValue(r.Context(), asyncReplayUserKey{}, identity), nil
+		},
+	}
+
+	stopped := &asyncExportTask{block: make(chan struct{}), started: make(chan struct{}, 1)}
+	t.Cleanup(func() { close(stopped.block) })
+	first := newAsyncExportService(t, db, stopped, cfg)
+	req := httptest.NewRequest(http.MethodPost, "/Export", nil)
+	req.Header.Set("Prefer", "respond-async")
+	req.Header.Set("Authorization", "Bearer alice")
+	rec := httptest.NewRecorder()
+	first.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected async acknowledgement, got %d: %s", rec.Code, rec.Body.String())
+	}
+	<-stopped.started
+
+	var headers [][]byte
+	if err := db.Table("_odata_async_jobs").Pluck("request_headers", &headers).Error; err != nil {
+		t.Fatalf("failed to read stored request: %v", err)
+	}
+	if len(headers) != 1 || strings.Contains(string(headers[0]), "alice") {
+		t.Fatalf("stored headers = %v, want them without the bearer token", headers)
+	}
+
+	second := newAsyncExportService(t, db, &asyncExportTask{}, cfg)
+	waitForMonitorCompletion(t, second, rec.Header().Get("Location"))
+	if identity := <-restored; identity != "alice" {
+		t.Errorf("restored identity = %q, want alice", identity)
+	}
+}
+
+func TestAsyncRecovery_LeavesJobsOfOtherInstances(t *testing.T) {
+	db := openAsyncRecoveryDB(t)
+	cfg := odata.AsyncConfig{
+		InstanceID: "node-1",
+		RecoveryPolicy: func(*http.Request) odata.AsyncRecoveryPolicy {
+			return odata.AsyncRecoveryRequeue
+		},
+	}
+
+	running := &asyncExportTask{block: make(chan struct{}), started: make(chan struct{}, 1)}
+	first := newAsyncExportService(t, db, running, cfg)
+	location := startAsyncExport(t, first)
+	<-running.started
+
+	// Another replica starts while the first one keeps running the export.
+	cfg.InstanceID = "node-2"
+	other := &asyncExportTask{}
+	newAsyncExportService(t, db, other, cfg)
+
+	close(running.block)
+	rec := waitForMonitorCompletion(t, first, location)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("monitor status = %d, want 204; body %s", rec.Code, rec.Body.String())
+	}
+	if got := other.calls.Load(); got != 0 {
+		t.Errorf("starting replica ran the live job %d times, want 0", got)
+	}
+}
+
+func TestAsyncMonitor_DeleteCancelsRunningJob(t *testing.T) {
+	db := openAsyncRecoveryDB(t)
+	task := &asyncExportTask{block: make(chan struct{}), started: make(chan struct{}, 1)}
+	t.Cleanup(func() { close(task.block) })
+	service := newAsyncExportService(t, db, task, odata.AsyncConfig{})
+
+	location := startAsyncExport(t, service)
+	<-task.started
+
+	pending := issueMonitorRequest(t, service, http.MethodGet, location)
+	if pending.Code != http.StatusAccepted {
+		t.Fatalf("monitor status = %d, want 202", pending.Code)
+	}
+	var info struct {
+		Status          string `json:"status"`
+		PercentComplete int    `json:"percentComplete"`
+		ProgressMessage string `json:"progressMessage"`
+	}
+	if err := json.Unmarshal(pending.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid monitor body %q: %v", pending.Body.String(), err)
+	}
+	if info.PercentComplete != 25 || info.ProgressMessage != "exporting" {
+		t.Errorf("monitor body = %+v, want progress 25 exporting", info)
+	}
+
+	if rec := issueMonitorRequest(t, service, http.MethodDelete, location); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want 204", rec.Code)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		var statuses []string
+		if err := db.Table("_odata_async_jobs").Pluck("status", &statuses).Error; err != nil {
+			t.Fatalf("failed to read job status: %v", err)
+		}
+		if len(statuses) == 1 && statuses[0] == "canceled" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job statuses = %v, want canceled", statuses)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := issueMonitorRequest(t, service, http.MethodGet, location); rec.Code != http.StatusNoContent {
+		t.Errorf("monitor after cancel = %d, want 204", rec.Code)
+	}
+}
+
+func TestAsyncJobList(t *testing.T) {
+	db := openAsyncRecoveryDB(t)
+	service := newAsyncExportService(t, db, &asyncExportTask{}, odata.AsyncConfig{
+		AuthorizeJobList: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer admin"
+		},
+	})
+
+	location := startAsyncExport(t, service)
+	waitForMonitorCompletion(t, service, location)
+
+	if rec := issueMonitorRequest(t, service, http.MethodGet, "/$async/jobs"); rec.Code != http.StatusForbidden {
+		t.Errorf("unauthorized listing status = %d, want 403", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/$async/jobs/", nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rec := httptest.NewRecorder()
+	service.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("listing status = %d, want 200", rec.Code)
+	}
+
+	var listing struct {
+		Value []struct {
+			ID         string `json:"id"`
+			Status     string `json:"status"`
+			MonitorURL string `json:"monitorUrl"`
+			AgeSeconds *int64 `json:"ageSeconds"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("invalid listing %q: %v", rec.Body.String(), err)
+	}
+	if len(listing.Value) != 1 {
+		t.Fatalf("listing = %+v, want one job", listing.Value)
+	}
+	job := listing.Value[0]
+	if job.MonitorURL != location || job.Status != "completed" || job.AgeSeconds == nil {
+		t.Errorf("listed job = %+v, want completed job at %s with an age", job, location)
+	}
+}
@@ -1136,6 +1136,65 @@ Accept: application/json
 	}
 }
 
+func TestBatchIntegration_MaxBatchBodySizeEnforcement(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	if err := db.AutoMigrate(&BatchIntegrationProduct{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+
+	service, err := odata.NewServiceWithConfig(db, odata.ServiceConfig{
+		MaxBatchBodySize: 512,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+
+	if err := service.RegisterEntity(&BatchIntegrationProduct{}); err != nil {
+		t.Fatalf("Failed to register entity: %v", err)
+	}
+
+	// The changeset fits the sub-request limit but not the body limit.
+	boundary := "batch_boundary"
+	changeset := "changeset_boundary"
+	description := strings.Repeat("x", 600)
+	body := fmt.Sprintf(`--%s
+Content-Type: multipart/mixed; boundary=%s
+
+--%s
+Content-Type: application/http
+Content-Transfer-Encoding: binary
+
+POST /BatchIntegrationProducts HTTP/1.1
+Content-Type: application/json
+
+{"Name":"Large","Price":1,"Category":"Bulk","Description":"%s"}
+
+--%s--
+
+--%s--
+`, boundary, changeset, changeset, description, changeset, boundary)
+
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", fmt.Sprintf("multipart/mixed; boundary=%s", boundary))
+	w := httptest.NewRecorder()
+
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Status = %v, want %v. Body: %s", w.Code, http.StatusRequestEntityTooLarge, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&BatchIntegrationProduct{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no products to be created, got %d", count)
+	}
+}
+
 func TestBatchIntegration_MaxBatchSizeWithinLimit(t *testing.T) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
@@ -0,0 +1,189 @@
+package odata_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newCacheReplica starts a service on the shared database file, as a further
+// replica of the same deployment would.
+func newCacheReplica(t *testing.T, path string, invalidator func(*odata.Service) odata.CacheInvalidator) *odata.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&CachedCategory{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	t.Cleanup(func() { _ = service.Close() })
+	if err := service.RegisterEntity(&CachedCategory{}, odata.EntityCacheConfig{
+		Level:       odata.CacheLevelFull,
+		TTL:         time.Hour,
+		Invalidator: invalidator(service),
+	}); err != nil {
+		t.Fatalf("failed to register entity: %v", err)
+	}
+	return service
+}
+
+func cachedCategoryNames(t *testing.T, service *odata.Service) []string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/CachedCategories?$orderby=ID", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var names []string
+	for _, v := range decodeValues(t, w) {
+		name, _ := v.(map[string]interface{})["Name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func createCachedCategory(t *testing.T, service *odata.Service, body string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/CachedCategories", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCacheInvalidation_DatabaseInvalidatorAcrossReplicas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicas.db")
+	invalidator := func(service *odata.Service) odata.CacheInvalidator {
+		inv, err := service.NewDatabaseCacheInvalidator(10 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("NewDatabaseCacheInvalidator() error: %v", err)
+		}
+		return inv
+	}
+	replicaA := newCacheReplica(t, path, invalidator)
+	replicaB := newCacheReplica(t, path, invalidator)
+
+	createCachedCategory(t, replicaA, `{"ID": 1, "Name": "Books"}`)
+	if got := cachedCategoryNames(t, replicaB); len(got) != 1 {
+		t.Fatalf("expected replica B to load 1 category, got %v", got)
+	}
+
+	// The TTL is an hour, so only the invalidation can make replica B see
+	// the write.
+	createCachedCategory(t, replicaA, `{"ID": 2, "Name": "Music"}`)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := cachedCategoryNames(t, replicaB)
+		if len(got) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replica B still serves %v after the write on replica A", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The writing replica never serves its own stale snapshot.
+	createCachedCategory(t, replicaB, `{"ID": 3, "Name": "Games"}`)
+	if got := cachedCategoryNames(t, replicaB); len(got) != 3 {
+		t.Fatalf("expected replica B to see its own write, got %v", got)
+	}
+}
+
+func TestCacheInvalidation_PubSubInvalidator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicas.db")
+
+	// An in-process stand-in for a message bus that delivers synchronously.
+	var replicas []*odata.PubSubCacheInvalidator
+	var messages []string
+	invalidator := func(*odata.Service) odata.CacheInvalidator {
+		inv := odata.NewPubSubCacheInvalidator(func(_ context.Context, entitySet string) error {
+			messages = append(messages, entitySet)
+			for _, replica := range replicas {
+				replica.Receive(entitySet)
+			}
+			return nil
+		})
+		replicas = append(replicas, inv)
+		return inv
+	}
+	replicaA := newCacheReplica(t, path, invalidator)
+	replicaB := newCacheReplica(t, path, invalidator)
+
+	createCachedCategory(t, replicaA, `{"ID": 1, "Name": "Books"}`)
+	if got := cachedCategoryNames(t, replicaB); len(got) != 1 {
+		t.Fatalf("expected replica B to load 1 category, got %v", got)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/CachedCategories(1)", strings.NewReader(`{"Name": "Novels"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	replicaA.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+		t.Fatalf("expected PATCH to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := cachedCategoryNames(t, replicaB); len(got) != 1 || got[0] != "Novels" {
+		t.Fatalf("expected replica B to serve the update, got %v", got)
+	}
+	if len(messages) != 2 || messages[0] != "CachedCategories" {
+		t.Errorf("expected one message per write, got %v", messages)
+	}
+}
+
+func TestCacheInvalidation_BatchChangeSetPublishesAfterCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replicas.db")
+	var replicas []*odata.PubSubCacheInvalidator
+	invalidator := func(*odata.Service) odata.CacheInvalidator {
+		inv := odata.NewPubSubCacheInvalidator(func(_ context.Context, entitySet string) error {
+			for _, replica := range replicas {
+				replica.Receive(entitySet)
+			}
+			return nil
+		})
+		replicas = append(replicas, inv)
+		return inv
+	}
+	replicaA := newCacheReplica(t, path, invalidator)
+	replicaB := newCacheReplica(t, path, invalidator)
+	if got := cachedCategoryNames(t, replicaB); len(got) != 0 {
+		t.Fatalf("expected no categories, got %v", got)
+	}
+
+	body := "--batch_1\r\n" +
+		"Content-Type: multipart/mixed; boundary=changeset_1\r\n\r\n" +
+		"--changeset_1\r\n" +
+		"Content-Type: application/http\r\n" +
+		"Content-Transfer-Encoding: binary\r\n" +
+		"Content-ID: 1\r\n\r\n" +
+		"POST CachedCategories HTTP/1.1\r\n" +
+		"Content-Type: application/json\r\n\r\n" +
+		`{"ID": 1, "Name": "Books"}` + "\r\n" +
+		"--changeset_1--\r\n" +
+		"--batch_1--\r\n"
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=batch_1")
+	w := httptest.NewRecorder()
+	replicaA.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "201 Created") {
+		t.Fatalf("expected the change set to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if got := cachedCategoryNames(t, replicaB); len(got) != 1 {
+		t.Fatalf("expected replica B to see the committed change set, got %v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package odata_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type RestrictedOrder struct {
+	ID         uint               `json:"ID" gorm:"primaryKey" odata:"key"`
+	CustomerID uint               `json:"CustomerID"`
+	Notes      string             `json:"Notes"`
+	Total      float64            `json:"Total"`
+	Lines      []RestrictedLine   `json:"Lines" gorm:"foreignKey:OrderID"`
+	Customer   RestrictedCustomer `json:"Customer" gorm:"foreignKey:CustomerID"`
+}
+
+type RestrictedLine struct {
+	ID      uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	OrderID uint   `json:"OrderID"`
+	Product string `json:"Product"`
+}
+
+type RestrictedCustomer struct {
+	ID   uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name string `json:"Name"`
+}
+
+func setupCapabilityRestrictionsService(t *testing.T) *odata.Service {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&RestrictedCustomer{}, &RestrictedOrder{}, &RestrictedLine{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Create(&RestrictedCustomer{ID: 1, Name: "Alice"})
+	db.Create(&RestrictedOrder{ID: 1, CustomerID: 1, Notes: "rush", Total: 10})
+	db.Create(&RestrictedLine{ID: 1, OrderID: 1, Product: "Widget"})
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	for _, entity := range []interface{}{&RestrictedCustomer{}, &RestrictedOrder{}, &RestrictedLine{}} {
+		if err := service.RegisterEntity(entity); err != nil {
+			t.Fatalf("RegisterEntity() error: %v", err)
+		}
+	}
+
+	annotations := map[string]interface{}{
+		"Capabilities.FilterRestrictions": map[string]interface{}{
+			"RequiresFilter":          true,
+			"RequiredProperties":      []string{"CustomerID"},
+			"NonFilterableProperties": []string{"Notes"},
+		},
+		"Capabilities.SortRestrictions": map[string]interface{}{
+			"NonSortableProperties":    []interface{}{"Notes"},
+			"AscendingOnlyProperties":  []string{"CustomerID"},
+			"DescendingOnlyProperties": []string{"Total"},
+		},
+		"Capabilities.ExpandRestrictions": map[string]interface{}{
+			"NonExpandableProperties": []string{"Lines"},
+		},
+		"Capabilities.SearchRestrictions": map[string]interface{}{"Searchable": false},
+		"Capabilities.CountRestrictions":  map[string]interface{}{"Countable": false},
+	}
+	for term, value := range annotations {
+		if err := service.RegisterEntitySetAnnotation("RestrictedOrders", term, value); err != nil {
+			t.Fatalf("RegisterEntitySetAnnotation(%s) error: %v", term, err)
+		}
+	}
+
+	return service
+}
+
+func TestCapabilityRestrictionsEnforced(t *testing.T) {
+	service := setupCapabilityRestrictionsService(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedText   string
+	}{
+		{
+			name:           "filter satisfying restrictions",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing required filter",
+			path:           "/RestrictedOrders",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "RequiresFilter",
+		},
+		{
+			name:           "missing required property",
+			path:           "/RestrictedOrders?$filter=Total%20gt%205",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "RequiredProperties",
+		},
+		{
+			name:           "non-filterable property",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201%20and%20contains(Notes,'rush')",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "NonFilterableProperties",
+		},
+		{
+			name:           "nested expand filter uses target restrictions",
+			path:           "/RestrictedOrders(1)?$expand=Customer($filter=Name%20eq%20'x')",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "non-sortable property",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$orderby=Notes",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "NonSortableProperties",
+		},
+		{
+			name:           "ascending only property sorted descending",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$orderby=CustomerID%20desc",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "AscendingOnlyProperties",
+		},
+		{
+			name:           "descending only property sorted ascending",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$orderby=Total",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "DescendingOnlyProperties",
+		},
+		{
+			name:           "allowed sort directions",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$orderby=CustomerID,Total%20desc",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "non-expandable navigation property",
+			path:           "/RestrictedOrders(1)?$expand=Lines",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "NonExpandableProperties",
+		},
+		{
+			name:           "expandable navigation property",
+			path:           "/RestrictedOrders(1)?$expand=Customer",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "search not supported",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$search=rush",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "Searchable",
+		},
+		{
+			name:           "inline count not supported",
+			path:           "/RestrictedOrders?$filter=CustomerID%20eq%201&$count=true",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "Countable",
+		},
+		{
+			name:           "count segment not supported",
+			path:           "/RestrictedOrders/$count",
+			expectedStatus: http.StatusBadRequest,
+			expectedText:   "Countable",
+		},
+		{
+			name:           "unrestricted entity set",
+			path:           "/RestrictedLines?$orderby=Product&$count=true",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedText != "" && !strings.Contains(w.Body.String(), tt.expectedText) {
+				t.Errorf("expected error to mention %q, got %s", tt.expectedText, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package odata_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type StreamProduct struct {
+	ID    uint    `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string  `json:"Name"`
+	Price float64 `json:"Price"`
+}
+
+type sseEvent struct {
+	id    string
+	event string
+	data  map[string]interface{}
+}
+
+type sseReader struct {
+	t      *testing.T
+	events chan sseEvent
+	body   interface{ Close() error }
+}
+
+func setupChangeStreamServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "stream.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&StreamProduct{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&StreamProduct{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.EnableChangeStream("StreamProducts", 0); err != nil {
+		t.Fatalf("EnableChangeStream() error: %v", err)
+	}
+	server := httptest.NewServer(service)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func openChangeStream(t *testing.T, server *httptest.Server, query, lastEventID string) *sseReader {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/StreamProducts"+query, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("subscribe status = %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		resp.Body.Close()
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	reader := &sseReader{t: t, events: make(chan sseEvent, 16), body: resp.Body}
+	t.Cleanup(func() { resp.Body.Close() })
+	go func() {
+		defer close(reader.events)
+		scanner := bufio.NewScanner(resp.Body)
+		var current sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if current.data != nil {
+					reader.events <- current
+				}
+				current = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				current.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				current.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.data)
+			}
+		}
+	}()
+	return reader
+}
+
+func (r *sseReader) next() (sseEvent, map[string]interface{}) {
+	r.t.Helper()
+	select {
+	case event, ok := <-r.events:
+		if !ok {
+			r.t.Fatal("stream closed unexpectedly")
+		}
+		values, _ := event.data["value"].([]interface{})
+		if len(values) != 1 {
+			r.t.Fatalf("expected one delta entry per event, got %v", event.data)
+		}
+		return event, values[0].(map[string]interface{})
+	case <-time.After(5 * time.Second):
+		r.t.Fatal("timed out waiting for change event")
+	}
+	return sseEvent{}, nil
+}
+
+func streamWrite(t *testing.T, server *httptest.Server, method, path, body string) {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		t.Fatalf("%s %s status = %d", method, path, resp.StatusCode)
+	}
+}
+
+func TestChangeStream_FilteredDeltaEntries(t *testing.T) {
+	server := setupChangeStreamServer(t)
+	stream := openChangeStream(t, server, "?$filter=Price%20gt%20100", "")
+
+	streamWrite(t, server, http.MethodPost, "/StreamProducts", `{"Name":"Mouse","Price":25}`)
+	streamWrite(t, server, http.MethodPost, "/StreamProducts", `{"Name":"Laptop","Price":1200}`)
+
+	event, entry := stream.next()
+	if event.event != "delta" || event.id == "" {
+		t.Fatalf("unexpected event framing: %+v", event)
+	}
+	if ctx, _ := event.data["@odata.context"].(string); !strings.HasSuffix(ctx, "$metadata#StreamProducts/$delta") {
+		t.Fatalf("@odata.context = %q", ctx)
+	}
+	if entry["Name"] != "Laptop" || entry["ID"] != float64(2) {
+		t.Fatalf("expected added Laptop entry, got %v", entry)
+	}
+
+	streamWrite(t, server, http.MethodPatch, "/StreamProducts(2)", `{"Price":99}`)
+	_, entry = stream.next()
+	removed, _ := entry["@odata.removed"].(map[string]interface{})
+	if removed["reason"] != "changed" || entry["ID"] != float64(2) {
+		t.Fatalf("expected changed removal for ID 2, got %v", entry)
+	}
+
+	streamWrite(t, server, http.MethodDelete, "/StreamProducts(1)", "")
+	_, entry = stream.next()
+	removed, _ = entry["@odata.removed"].(map[string]interface{})
+	if removed["reason"] != "deleted" || entry["ID"] != float64(1) {
+		t.Fatalf("expected deleted entry for ID 1, got %v", entry)
+	}
+}
+
+func TestChangeStream_ResumeWithLastEventID(t *testing.T) {
+	server := setupChangeStreamServer(t)
+	stream := openChangeStream(t, server, "?$select=Name", "")
+
+	streamWrite(t, server, http.MethodPost, "/StreamProducts", `{"Name":"Keyboard","Price":75}`)
+	first, entry := stream.next()
+	if _, hasPrice := entry["Price"]; hasPrice || entry["Name"] != "Keyboard" || entry["ID"] != float64(1) {
+		t.Fatalf("$select not applied to entry: %v", entry)
+	}
+	if err := stream.body.Close(); err != nil {
+		t.Fatalf("close stream: %v", err)
+	}
+
+	// Changes made while disconnected are replayed after reconnecting.
+	streamWrite(t, server, http.MethodPost, "/StreamProducts", `{"Name":"Monitor","Price":300}`)
+	streamWrite(t, server, http.MethodPatch, "/StreamProducts(1)", `{"Name":"Keyboard Pro"}`)
+
+	resumed := openChangeStream(t, server, "?$select=Name", first.id)
+	_, entry = resumed.next()
+	if entry["Name"] != "Monitor" {
+		t.Fatalf("first replayed entry = %v, want Monitor", entry)
+	}
+	_, entry = resumed.next()
+	if entry["Name"] != "Keyboard Pro" {
+		t.Fatalf("second replayed entry = %v, want Keyboard Pro", entry)
+	}
+}
+
+func TestChangeStream_RejectsUnsupportedRequests(t *testing.T) {
+	server := setupChangeStreamServer(t)
+
+	for name, tc := range map[string]struct {
+		query       string
+		lastEventID string
+	}{
+		"paging option":      {query: "?$top=5"},
+		"unsupported filter": {query: "?$filter=length(Name)%20gt%203"},
+		"invalid resume id":  {lastEventID: "not-a-token"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL+"/StreamProducts"+tc.query, nil)
+			req.Header.Set("Accept", "text/event-stream")
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", resp.StatusCode)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package odata_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -124,6 +125,75 @@ func TestChangeTrackingPersistsAcrossRestart(t *testing.T) {
 	}
 }
 
+func TestChangeTrackingRetentionReturnsGone(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tracker.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&PersistentProduct{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	service, err := odata.NewServiceWithConfig(db, odata.ServiceConfig{
+		PersistentChangeTracking: true,
+		ChangeTrackingRetention:  odata.ChangeTrackingRetention{MaxEvents: 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer service.Close()
+	if err := service.RegisterEntity(&PersistentProduct{}); err != nil {
+		t.Fatalf("register entity: %v", err)
+	}
+	if err := service.EnableChangeTracking("PersistentProducts"); err != nil {
+		t.Fatalf("enable change tracking: %v", err)
+	}
+
+	initialReq := httptest.NewRequest(http.MethodGet, "/PersistentProducts", nil)
+	initialReq.Header.Set("Prefer", "odata.track-changes")
+	initialRes := httptest.NewRecorder()
+	service.ServeHTTP(initialRes, initialReq)
+	if initialRes.Code != http.StatusOK {
+		t.Fatalf("initial response status: %d", initialRes.Code)
+	}
+	initialToken := extractDeltaToken(t, initialRes.Body.Bytes())
+
+	for _, body := range []string{`{"id":1,"name":"First"}`, `{"id":2,"name":"Second"}`} {
+		createReq := httptest.NewRequest(http.MethodPost, "/PersistentProducts", strings.NewReader(body))
+		createReq.Header.Set("Content-Type", "application/json")
+		createRes := httptest.NewRecorder()
+		service.ServeHTTP(createRes, createReq)
+		if createRes.Code != http.StatusCreated {
+			t.Fatalf("create status: %d", createRes.Code)
+		}
+	}
+
+	if err := service.CompactChangeHistory(context.Background()); err != nil {
+		t.Fatalf("compact change history: %v", err)
+	}
+
+	deltaReq := httptest.NewRequest(http.MethodGet, "/PersistentProducts?$deltatoken="+url.QueryEscape(initialToken), nil)
+	deltaRes := httptest.NewRecorder()
+	service.ServeHTTP(deltaRes, deltaReq)
+	if deltaRes.Code != http.StatusGone {
+		t.Fatalf("expected 410 Gone for expired delta token, got %d: %s", deltaRes.Code, deltaRes.Body.String())
+	}
+
+	// A fresh delta link works again.
+	refreshReq := httptest.NewRequest(http.MethodGet, "/PersistentProducts", nil)
+	refreshReq.Header.Set("Prefer", "odata.track-changes")
+	refreshRes := httptest.NewRecorder()
+	service.ServeHTTP(refreshRes, refreshReq)
+	refreshedToken := extractDeltaToken(t, refreshRes.Body.Bytes())
+
+	followReq := httptest.NewRequest(http.MethodGet, "/PersistentProducts?$deltatoken="+url.QueryEscape(refreshedToken), nil)
+	followRes := httptest.NewRecorder()
+	service.ServeHTTP(followRes, followReq)
+	if followRes.Code != http.StatusOK {
+		t.Fatalf("delta with refreshed token status: %d", followRes.Code)
+	}
+}
+
 func extractDeltaToken(t *testing.T, body []byte) string {
 	t.Helper()
 
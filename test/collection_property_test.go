@@ -0,0 +1,238 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type CollectionPropertyAddress struct {
+	City string `json:"City"`
+	Zip  string `json:"Zip"`
+}
+
+type CollectionPropertyProduct struct {
+	ID        uint                        `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name      string                      `json:"Name"`
+	Tags      odata.Collection[string]    `json:"Tags"`
+	Ratings   []int                       `json:"Ratings" gorm:"serializer:json"`
+	Addresses []CollectionPropertyAddress `json:"Addresses" gorm:"serializer:json"`
+}
+
+func setupCollectionPropertyService(t *testing.T) *odata.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&CollectionPropertyProduct{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	products := []CollectionPropertyProduct{
+		{ID: 1, Name: "Lamp", Tags: odata.Collection[string]{"red", "sale"}, Ratings: []int{1, 5},
+			Addresses: []CollectionPropertyAddress{{City: "Berlin", Zip: "10115"}}},
+		{ID: 2, Name: "Chair", Tags: odata.Collection[string]{"blue"}, Ratings: []int{3}},
+		{ID: 3, Name: "Desk", Tags: odata.Collection[string]{}, Ratings: []int{}},
+	}
+	if err := db.Create(&products).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&CollectionPropertyProduct{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	return service
+}
+
+func collectionPropertyProductIDs(t *testing.T, w *httptest.ResponseRecorder) []int {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Value []struct {
+			ID int `json:"ID"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+	}
+	ids := make([]int, len(body.Value))
+	for i, v := range body.Value {
+		ids[i] = v.ID
+	}
+	return ids
+}
+
+func TestCollectionProperty_Read(t *testing.T) {
+	service := setupCollectionPropertyService(t)
+
+	w := serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(1)", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var entity CollectionPropertyProduct
+	if err := json.Unmarshal(w.Body.Bytes(), &entity); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(entity.Tags) != 2 || entity.Tags[1] != "sale" || len(entity.Ratings) != 2 || entity.Addresses[0].City != "Berlin" {
+		t.Errorf("unexpected entity: %+v", entity)
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(1)/Tags", "", nil)
+	var tags struct {
+		Value []string `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tags); err != nil || len(tags.Value) != 2 {
+		t.Errorf("expected the tags wrapped in value, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(1)/Tags/$count", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "2" {
+		t.Errorf("expected count 2, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(3)/Ratings/$count", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0" {
+		t.Errorf("expected count 0, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCollectionProperty_Filter(t *testing.T) {
+	service := setupCollectionPropertyService(t)
+
+	tests := []struct {
+		filter string
+		want   []int
+	}{
+		{filter: "Tags/any(t: t eq 'sale')", want: []int{1}},
+		{filter: "Tags/any(t: startswith(t, 'b') or t eq 'red')", want: []int{1, 2}},
+		{filter: "Tags/all(t: startswith(t, 'b'))", want: []int{2, 3}},
+		{filter: "Tags/any()", want: []int{1, 2}},
+		{filter: "not Tags/any()", want: []int{3}},
+		{filter: "Ratings/any(r: r gt 4)", want: []int{1}},
+		{filter: "Ratings/all(r: r ge 3)", want: []int{2, 3}},
+		{filter: "Addresses/any(a: a/City eq 'Berlin')", want: []int{1}},
+		{filter: "Tags/$count gt 1", want: []int{1}},
+		{filter: "Ratings/$count eq 0", want: []int{3}},
+		{filter: "Name eq 'Chair' and Tags/any(t: t eq 'blue')", want: []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			path := "/CollectionPropertyProducts?$filter=" + strings.ReplaceAll(tt.filter, " ", "%20")
+			ids := collectionPropertyProductIDs(t, serveRequest(t, service, http.MethodGet, path, "", nil))
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("expected %v, got %v", tt.want, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectionProperty_InvalidQueries(t *testing.T) {
+	service := setupCollectionPropertyService(t)
+
+	for _, path := range []string{
+		"/CollectionPropertyProducts?$filter=Tags%20eq%20'red'",
+		"/CollectionPropertyProducts?$orderby=Tags",
+		"/CollectionPropertyProducts?$filter=Addresses/any(a:%20a/Country%20eq%20'DE')",
+	} {
+		if w := serveRequest(t, service, http.MethodGet, path, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCollectionProperty_Write(t *testing.T) {
+	service := setupCollectionPropertyService(t)
+
+	w := serveRequest(t, service, http.MethodPost, "/CollectionPropertyProducts",
+		`{"ID": 4, "Name": "Sofa", "Tags": ["green", "sale"], "Ratings": [4], "Addresses": [{"City": "Rome", "Zip": "00100"}]}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	ids := collectionPropertyProductIDs(t, serveRequest(t, service, http.MethodGet,
+		"/CollectionPropertyProducts?$filter=Tags/any(t:%20t%20eq%20'sale')", "", nil))
+	if len(ids) != 2 || ids[1] != 4 {
+		t.Errorf("expected the new entity to match, got %v", ids)
+	}
+
+	w = serveRequest(t, service, http.MethodPatch, "/CollectionPropertyProducts(4)",
+		`{"Tags": ["clearance"], "Addresses": [{"City": "Paris", "Zip": "75001"}]}`, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(4)", "", nil)
+	var entity CollectionPropertyProduct
+	if err := json.Unmarshal(w.Body.Bytes(), &entity); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+	}
+	if len(entity.Tags) != 1 || entity.Tags[0] != "clearance" || entity.Addresses[0].City != "Paris" || len(entity.Ratings) != 1 {
+		t.Errorf("unexpected entity after PATCH: %+v", entity)
+	}
+
+	w = serveRequest(t, service, http.MethodPut, "/CollectionPropertyProducts(4)",
+		`{"ID": 4, "Name": "Sofa", "Tags": ["a", "b", "c"], "Ratings": [], "Addresses": []}`, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveRequest(t, service, http.MethodGet, "/CollectionPropertyProducts(4)/Tags/$count", "", nil)
+	if w.Body.String() != "3" {
+		t.Errorf("expected 3 tags after PUT, got %s", w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodPatch, "/CollectionPropertyProducts(4)", `{"Ratings": ["high"]}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a mistyped element, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCollectionProperty_Metadata(t *testing.T) {
+	service := setupCollectionPropertyService(t)
+
+	xml := serveRequest(t, service, http.MethodGet, "/$metadata", "", nil).Body.String()
+	for _, want := range []string{
+		`<Property Name="Tags" Type="Collection(Edm.String)"`,
+		`<Property Name="Ratings" Type="Collection(Edm.Int32)"`,
+		`<Property Name="Addresses" Type="Collection(ODataService.CollectionPropertyAddress)"`,
+		`<ComplexType Name="CollectionPropertyAddress">`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("expected $metadata to contain %s", want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/$metadata?$format=json", nil)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to parse JSON metadata: %v", err)
+	}
+	schema, _ := doc["ODataService"].(map[string]interface{})
+	entityType, _ := schema["CollectionPropertyProduct"].(map[string]interface{})
+	tags, _ := entityType["Tags"].(map[string]interface{})
+	if tags["$Collection"] != true || tags["$Type"] != "Edm.String" {
+		t.Errorf("expected Tags to be a string collection in JSON metadata, got %v", tags)
+	}
+	addresses, _ := entityType["Addresses"].(map[string]interface{})
+	if addresses["$Collection"] != true || addresses["$Type"] != "ODataService.CollectionPropertyAddress" {
+		t.Errorf("expected Addresses to be a complex collection in JSON metadata, got %v", addresses)
+	}
+	if _, ok := schema["CollectionPropertyAddress"]; !ok {
+		t.Error("expected the complex element type in JSON metadata")
+	}
+}
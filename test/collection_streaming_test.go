@@ -0,0 +1,270 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type StreamedReading struct {
+	ID       int     `json:"ID" gorm:"primaryKey" odata:"key"`
+	Sensor   string  `json:"Sensor"`
+	Value    float64 `json:"Value"`
+	Comment  *string `json:"Comment"`
+	DeviceID int     `json:"DeviceID"`
+}
+
+type StreamedDevice struct {
+	ID       int               `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name     string            `json:"Name"`
+	Readings []StreamedReading `json:"Readings" gorm:"foreignKey:DeviceID;references:ID"`
+}
+
+func setupStreamingService(t *testing.T, rows int) (*odata.Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to get database handle: %v", err)
+	}
+	// Every connection to ":memory:" opens a separate database.
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&StreamedDevice{}, &StreamedReading{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	if err := db.Create(&StreamedDevice{ID: 1, Name: "Probe"}).Error; err != nil {
+		t.Fatalf("Failed to seed device: %v", err)
+	}
+	if err := db.Exec(`WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < ?)
+		INSERT INTO streamed_readings (id, sensor, value, comment, device_id)
+		SELECT n, 'sensor-' || (n % 10), n * 0.5, CASE WHEN n % 2 = 0 THEN 'even' END, 1 FROM seq`, rows).Error; err != nil {
+		t.Fatalf("Failed to seed readings: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&StreamedReading{}); err != nil {
+		t.Fatalf("Failed to register StreamedReading: %v", err)
+	}
+	if err := service.RegisterEntity(&StreamedDevice{}); err != nil {
+		t.Fatalf("Failed to register StreamedDevice: %v", err)
+	}
+	return service, db
+}
+
+func getCollection(t *testing.T, service *odata.Service, target string, prefer string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status = %d, body: %s", target, w.Code, w.Body.String())
+	}
+	return w
+}
+
+func isStreamed(w *httptest.ResponseRecorder) bool {
+	return strings.Contains(w.Header().Get("Content-Type"), "odata.streaming=true")
+}
+
+func TestCollectionStreaming_MatchesMaterializedResponse(t *testing.T) {
+	service, _ := setupStreamingService(t, 25)
+
+	for _, target := range []string{
+		"/StreamedReadings",
+		"/StreamedReadings?$filter=Value gt 5&$orderby=Sensor desc,ID",
+		"/StreamedReadings?$select=Sensor&$skip=3",
+		"/StreamedReadings?$format=application/json;odata.metadata=full&$top=4",
+	} {
+		target = strings.ReplaceAll(target, " ", "%20")
+		t.Run(target, func(t *testing.T) {
+			plain := getCollection(t, service, target, "")
+			streamed := getCollection(t, service, target, "odata.streaming")
+
+			if isStreamed(plain) {
+				t.Fatalf("response was streamed without opting in")
+			}
+			if !isStreamed(streamed) {
+				t.Fatalf("Content-Type = %q, want odata.streaming=true", streamed.Header().Get("Content-Type"))
+			}
+			if streamed.Header().Get("Content-Length") != "" {
+				t.Errorf("streamed response has Content-Length %s", streamed.Header().Get("Content-Length"))
+			}
+			if applied := streamed.Header().Get("Preference-Applied"); !strings.Contains(applied, "odata.streaming") {
+				t.Errorf("Preference-Applied = %q, want odata.streaming", applied)
+			}
+
+			var want, got map[string]interface{}
+			if err := json.Unmarshal(plain.Body.Bytes(), &want); err != nil {
+				t.Fatalf("invalid materialized JSON: %v", err)
+			}
+			if err := json.Unmarshal(streamed.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid streamed JSON: %v; body: %s", err, streamed.Body.String())
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("streamed response differs:\nstreamed:     %v\nmaterialized: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCollectionStreaming_CountAndNextLinkPlacement(t *testing.T) {
+	service, _ := setupStreamingService(t, 10)
+
+	w := getCollection(t, service, "/StreamedReadings?$count=true&$top=4", "odata.streaming")
+	body := w.Body.String()
+
+	countAt := strings.Index(body, `"@odata.count":10`)
+	valueAt := strings.Index(body, `"value":[`)
+	nextAt := strings.Index(body, `"@odata.nextLink"`)
+	if countAt < 0 || valueAt < 0 || nextAt < 0 {
+		t.Fatalf("missing count, value or nextLink: %s", body)
+	}
+	if countAt > valueAt || nextAt < valueAt {
+		t.Errorf("want @odata.count before value and @odata.nextLink after it: %s", body)
+	}
+
+	var page struct {
+		Value    []StreamedReading `json:"value"`
+		NextLink string            `json:"@odata.nextLink"`
+	}
+	var ids []int
+	for target := "/StreamedReadings?$top=4"; target != ""; {
+		w := getCollection(t, service, target, "odata.streaming")
+		page.NextLink = ""
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		for _, reading := range page.Value {
+			ids = append(ids, reading.ID)
+		}
+		target = ""
+		if page.NextLink != "" {
+			next, err := url.Parse(page.NextLink)
+			if err != nil {
+				t.Fatalf("invalid nextLink %q: %v", page.NextLink, err)
+			}
+			target = next.RequestURI()
+		}
+		if len(ids) > 20 {
+			t.Fatalf("next links do not terminate: %v", ids)
+		}
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("paged IDs = %v, want %v", ids, want)
+	}
+}
+
+func TestCollectionStreaming_EnabledPerEntitySet(t *testing.T) {
+	service, _ := setupStreamingService(t, 3)
+	if err := service.EnableCollectionStreaming("StreamedReadings"); err != nil {
+		t.Fatalf("EnableCollectionStreaming: %v", err)
+	}
+	if err := service.EnableCollectionStreaming("Missing"); err == nil {
+		t.Error("EnableCollectionStreaming accepted an unknown entity set")
+	}
+
+	if w := getCollection(t, service, "/StreamedReadings", ""); !isStreamed(w) {
+		t.Error("collection of an opted-in entity set was not streamed")
+	}
+	if w := getCollection(t, service, "/StreamedDevices", ""); isStreamed(w) {
+		t.Error("collection of another entity set was streamed")
+	}
+}
+
+func TestCollectionStreaming_FallsBackForUnsupportedQueries(t *testing.T) {
+	service, _ := setupStreamingService(t, 3)
+
+	for _, target := range []string{
+		"/StreamedDevices?$expand=Readings",
+		"/StreamedReadings?$apply=groupby((Sensor))",
+		"/StreamedReadings?$index",
+		"/StreamedReadings?$format=csv",
+	} {
+		t.Run(target, func(t *testing.T) {
+			w := getCollection(t, service, target, "odata.streaming")
+			if isStreamed(w) {
+				t.Errorf("%s was streamed", target)
+			}
+		})
+	}
+}
+
+// countingWriter discards the response body and samples the heap while it is written.
+type countingWriter struct {
+	header  http.Header
+	written int64
+	writes  int
+	maxHeap uint64
+}
+
+func (c *countingWriter) Header() http.Header { return c.header }
+
+func (c *countingWriter) WriteHeader(int) {}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.written += int64(len(p))
+	c.writes++
+	if c.writes%32 == 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > c.maxHeap {
+			c.maxHeap = stats.HeapAlloc
+		}
+	}
+	return len(p), nil
+}
+
+func (c *countingWriter) Flush() {}
+
+func TestCollectionStreaming_MemoryAtOneMillionRows(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 1M row streaming test in short mode")
+	}
+	const rows = 1_000_000
+	service, _ := setupStreamingService(t, rows)
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	w := &countingWriter{header: http.Header{}}
+	req := httptest.NewRequest(http.MethodGet, "/StreamedReadings?$format=application/json;odata.metadata=none", nil)
+	req.Header.Set("Prefer", "odata.streaming")
+	service.ServeHTTP(w, req)
+
+	if !strings.Contains(w.header.Get("Content-Type"), "odata.streaming=true") {
+		t.Fatalf("response was not streamed: Content-Type %q", w.header.Get("Content-Type"))
+	}
+	// Every entity is at least {"ID":n,...} - far more than 40 bytes.
+	if w.written < rows*40 {
+		t.Fatalf("wrote %d bytes, want the full collection of %d rows", w.written, rows)
+	}
+
+	// The body alone is over 50 MB; materializing the rows would need well
+	// over 100 MB. Streaming keeps only a row and a flush buffer alive.
+	const limit = 32 << 20
+	if w.maxHeap > before.HeapAlloc && w.maxHeap-before.HeapAlloc > limit {
+		t.Errorf("heap grew by %d MB while streaming %d MB, want less than %d MB",
+			(w.maxHeap-before.HeapAlloc)>>20, w.written>>20, limit>>20)
+	}
+}
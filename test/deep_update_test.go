@@ -2,7 +2,9 @@ package odata_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -23,10 +25,10 @@ type DeepUpdateSupplier struct {
 
 // DeepUpdateProduct has a BelongsTo relationship to DeepUpdateSupplier.
 type DeepUpdateProduct struct {
-	ID         int                  `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
-	Name       string               `json:"Name"`
-	SupplierID *int                 `json:"SupplierID,omitempty"`
-	Supplier   *DeepUpdateSupplier  `json:"Supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	ID         int                 `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
+	Name       string              `json:"Name"`
+	SupplierID *int                `json:"SupplierID,omitempty"`
+	Supplier   *DeepUpdateSupplier `json:"Supplier,omitempty" gorm:"foreignKey:SupplierID"`
 }
 
 // DeepUpdateAddress has a HasOne relationship from DeepUpdateOrder (FK is on Address).
@@ -44,6 +46,50 @@ type DeepUpdateOrder struct {
 	Address *DeepUpdateAddress `json:"Address,omitempty" gorm:"foreignKey:OrderID"`
 }
 
+// DeepUpdateInvoiceLine is the dependent entity of the collection-valued DeepUpdateInvoice.Lines.
+type DeepUpdateInvoiceLine struct {
+	ID        int    `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
+	InvoiceID *int   `json:"InvoiceID,omitempty"`
+	Product   string `json:"Product"`
+}
+
+// DeepUpdateInvoice has many DeepUpdateInvoiceLines (FK is on DeepUpdateInvoiceLine).
+type DeepUpdateInvoice struct {
+	ID    int                     `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
+	Total float64                 `json:"Total"`
+	Lines []DeepUpdateInvoiceLine `json:"Lines,omitempty" gorm:"foreignKey:InvoiceID"`
+}
+
+// DeepUpdateChecklistItem is the dependent entity of DeepUpdateChecklist.Items. Its hooks refuse
+// to change locked items.
+type DeepUpdateChecklistItem struct {
+	ID          int    `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
+	ChecklistID *int   `json:"ChecklistID,omitempty"`
+	Title       string `json:"Title"`
+	Locked      bool   `json:"Locked"`
+}
+
+func (i DeepUpdateChecklistItem) ODataBeforeUpdate(ctx context.Context, r *http.Request) error {
+	if i.Locked {
+		return fmt.Errorf("item %d is locked", i.ID)
+	}
+	return nil
+}
+
+func (i DeepUpdateChecklistItem) ODataBeforeDelete(ctx context.Context, r *http.Request) error {
+	if i.Locked {
+		return fmt.Errorf("item %d is locked", i.ID)
+	}
+	return nil
+}
+
+// DeepUpdateChecklist has many DeepUpdateChecklistItems (FK is on DeepUpdateChecklistItem).
+type DeepUpdateChecklist struct {
+	ID    int                       `json:"ID" gorm:"primaryKey;autoIncrement" odata:"key"`
+	Name  string                    `json:"Name"`
+	Items []DeepUpdateChecklistItem `json:"Items,omitempty" gorm:"foreignKey:ChecklistID"`
+}
+
 // ---- Setup helper ----
 
 func setupDeepUpdateService(t *testing.T) (*odata.Service, *gorm.DB) {
@@ -53,7 +99,9 @@ func setupDeepUpdateService(t *testing.T) (*odata.Service, *gorm.DB) {
 		t.Fatalf("Failed to open DB: %v", err)
 	}
 	if err := db.AutoMigrate(&DeepUpdateSupplier{}, &DeepUpdateProduct{},
-		&DeepUpdateAddress{}, &DeepUpdateOrder{}); err != nil {
+		&DeepUpdateAddress{}, &DeepUpdateOrder{},
+		&DeepUpdateInvoiceLine{}, &DeepUpdateInvoice{},
+		&DeepUpdateChecklistItem{}, &DeepUpdateChecklist{}); err != nil {
 		t.Fatalf("Failed to migrate: %v", err)
 	}
 
@@ -66,6 +114,10 @@ func setupDeepUpdateService(t *testing.T) (*odata.Service, *gorm.DB) {
 		&DeepUpdateProduct{},
 		&DeepUpdateAddress{},
 		&DeepUpdateOrder{},
+		&DeepUpdateInvoiceLine{},
+		&DeepUpdateInvoice{},
+		&DeepUpdateChecklistItem{},
+		&DeepUpdateChecklist{},
 	} {
 		if err := service.RegisterEntity(e); err != nil {
 			t.Fatalf("RegisterEntity error: %v", err)
@@ -299,3 +351,270 @@ func TestDeepUpdate_CollectionNavProp_Ignored(t *testing.T) {
 		t.Errorf("Order.Total = %v, want 99.0", updatedOrder.Total)
 	}
 }
+
+// ---- Tests: collection-valued deep update ----
+
+func seedDeepUpdateInvoice(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	invoiceID := 1
+	db.Create(&DeepUpdateInvoice{ID: invoiceID, Total: 10})
+	for id, product := range map[int]string{1: "Widget", 2: "Gadget", 3: "Gizmo"} {
+		db.Create(&DeepUpdateInvoiceLine{ID: id, InvoiceID: &invoiceID, Product: product})
+	}
+	db.Create(&DeepUpdateInvoiceLine{ID: 4, Product: "Unassigned"})
+}
+
+func patchDeepUpdateInvoice(t *testing.T, service *odata.Service, payload map[string]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPatch, "/DeepUpdateInvoices(1)", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	return w
+}
+
+func deepUpdateInvoiceLines(t *testing.T, db *gorm.DB) map[int]DeepUpdateInvoiceLine {
+	t.Helper()
+	var lines []DeepUpdateInvoiceLine
+	if err := db.Order("id").Find(&lines).Error; err != nil {
+		t.Fatalf("Failed to load lines: %v", err)
+	}
+	result := make(map[int]DeepUpdateInvoiceLine, len(lines))
+	for _, line := range lines {
+		result[line.ID] = line
+	}
+	return result
+}
+
+func lineInvoiceID(line DeepUpdateInvoiceLine) int {
+	if line.InvoiceID == nil {
+		return 0
+	}
+	return *line.InvoiceID
+}
+
+// TestDeepUpdate_Collection_FullSet verifies that a plain array replaces the related collection:
+// keyed entries are updated, new entries are created, references are linked and unlisted
+// related entities are unlinked.
+func TestDeepUpdate_Collection_FullSet(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	seedDeepUpdateInvoice(t, db)
+
+	w := patchDeepUpdateInvoice(t, service, map[string]interface{}{
+		"Total": 25.0,
+		"Lines": []interface{}{
+			map[string]interface{}{"ID": 1, "Product": "Widget v2"},
+			map[string]interface{}{"Product": "Brand new"},
+			map[string]interface{}{"@id": "DeepUpdateInvoiceLines(4)"},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %v, want 204. Body: %s", w.Code, w.Body.String())
+	}
+
+	lines := deepUpdateInvoiceLines(t, db)
+	if lines[1].Product != "Widget v2" || lineInvoiceID(lines[1]) != 1 {
+		t.Errorf("line 1 = %+v, want updated product and still linked", lines[1])
+	}
+	for _, id := range []int{2, 3} {
+		if lineInvoiceID(lines[id]) != 0 {
+			t.Errorf("line %d should have been unlinked, got InvoiceID %v", id, lineInvoiceID(lines[id]))
+		}
+	}
+	if lineInvoiceID(lines[4]) != 1 {
+		t.Errorf("line 4 should have been linked, got InvoiceID %v", lineInvoiceID(lines[4]))
+	}
+	if len(lines) != 5 || lines[5].Product != "Brand new" || lineInvoiceID(lines[5]) != 1 {
+		t.Errorf("expected new line 5 linked to invoice 1, got %+v", lines)
+	}
+
+	var invoice DeepUpdateInvoice
+	db.First(&invoice, 1)
+	if invoice.Total != 25.0 {
+		t.Errorf("Invoice.Total = %v, want 25", invoice.Total)
+	}
+}
+
+// TestDeepUpdate_Collection_Delta verifies that a delta array only applies the listed changes,
+// including @removed entries that unlink or delete related entities.
+func TestDeepUpdate_Collection_Delta(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	seedDeepUpdateInvoice(t, db)
+
+	w := patchDeepUpdateInvoice(t, service, map[string]interface{}{
+		"Lines@delta": []interface{}{
+			map[string]interface{}{"@id": "DeepUpdateInvoiceLines(2)", "@removed": map[string]interface{}{"reason": "deleted"}},
+			map[string]interface{}{"ID": 3, "@removed": map[string]interface{}{"reason": "changed"}},
+			map[string]interface{}{"Product": "Added"},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %v, want 204. Body: %s", w.Code, w.Body.String())
+	}
+
+	lines := deepUpdateInvoiceLines(t, db)
+	if lineInvoiceID(lines[1]) != 1 || lines[1].Product != "Widget" {
+		t.Errorf("line 1 should be untouched, got %+v", lines[1])
+	}
+	if _, exists := lines[2]; exists {
+		t.Errorf("line 2 should have been deleted")
+	}
+	if line, exists := lines[3]; !exists || lineInvoiceID(line) != 0 {
+		t.Errorf("line 3 should have been unlinked but kept, got %+v (exists=%v)", line, exists)
+	}
+	if lines[5].Product != "Added" || lineInvoiceID(lines[5]) != 1 {
+		t.Errorf("expected added line linked to invoice 1, got %+v", lines[5])
+	}
+}
+
+// TestDeepUpdate_Collection_BindAndInline verifies that @odata.bind references for a collection
+// are linked alongside inline entries instead of replacing them.
+func TestDeepUpdate_Collection_BindAndInline(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	seedDeepUpdateInvoice(t, db)
+
+	w := patchDeepUpdateInvoice(t, service, map[string]interface{}{
+		"Lines@odata.bind": []interface{}{"DeepUpdateInvoiceLines(4)"},
+		"Lines@delta": []interface{}{
+			map[string]interface{}{"Product": "Inline"},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %v, want 204. Body: %s", w.Code, w.Body.String())
+	}
+
+	lines := deepUpdateInvoiceLines(t, db)
+	for _, id := range []int{1, 2, 3, 4, 5} {
+		if lineInvoiceID(lines[id]) != 1 {
+			t.Errorf("line %d should be linked to invoice 1, got %+v", id, lines[id])
+		}
+	}
+}
+
+// TestDeepUpdate_Collection_RollsBackOnError verifies that a failing nested entry leaves the
+// parent and all related entities unchanged.
+func TestDeepUpdate_Collection_RollsBackOnError(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	seedDeepUpdateInvoice(t, db)
+
+	w := patchDeepUpdateInvoice(t, service, map[string]interface{}{
+		"Total": 99.0,
+		"Lines": []interface{}{
+			map[string]interface{}{"ID": 1, "Product": "Changed"},
+			map[string]interface{}{"@id": "DeepUpdateInvoiceLines(42)"},
+		},
+	})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH status = %v, want 400. Body: %s", w.Code, w.Body.String())
+	}
+
+	lines := deepUpdateInvoiceLines(t, db)
+	if lines[1].Product != "Widget" {
+		t.Errorf("line 1 change should have been rolled back, got %+v", lines[1])
+	}
+	for _, id := range []int{1, 2, 3} {
+		if lineInvoiceID(lines[id]) != 1 {
+			t.Errorf("line %d should still be linked, got %+v", id, lines[id])
+		}
+	}
+	var invoice DeepUpdateInvoice
+	db.First(&invoice, 1)
+	if invoice.Total != 10 {
+		t.Errorf("Invoice.Total = %v, want 10", invoice.Total)
+	}
+}
+
+// TestDeepUpdate_Collection_RejectsEntitiesOfOtherParents verifies that keyed and removed entries
+// can only address entities related to the patched entity.
+func TestDeepUpdate_Collection_RejectsEntitiesOfOtherParents(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	seedDeepUpdateInvoice(t, db)
+	otherInvoiceID := 2
+	db.Create(&DeepUpdateInvoice{ID: otherInvoiceID, Total: 20})
+	db.Create(&DeepUpdateInvoiceLine{ID: 6, InvoiceID: &otherInvoiceID, Product: "Foreign"})
+
+	for name, entry := range map[string]map[string]interface{}{
+		"delete":           {"ID": 6, "@removed": map[string]interface{}{"reason": "deleted"}},
+		"delete reference": {"@id": "DeepUpdateInvoiceLines(6)", "@removed": map[string]interface{}{"reason": "deleted"}},
+		"unlink":           {"ID": 6, "@removed": map[string]interface{}{"reason": "changed"}},
+		"update":           {"ID": 6, "Product": "Hijacked"},
+		"update unrelated": {"ID": 4, "Product": "Hijacked"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := patchDeepUpdateInvoice(t, service, map[string]interface{}{
+				"Lines@delta": []interface{}{entry},
+			})
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("PATCH status = %v, want 400. Body: %s", w.Code, w.Body.String())
+			}
+
+			lines := deepUpdateInvoiceLines(t, db)
+			if line, exists := lines[6]; !exists || line.Product != "Foreign" || lineInvoiceID(line) != otherInvoiceID {
+				t.Errorf("line 6 of another invoice should be untouched, got %+v (exists=%v)", line, exists)
+			}
+			if line := lines[4]; line.Product != "Unassigned" || lineInvoiceID(line) != 0 {
+				t.Errorf("unassigned line 4 should be untouched, got %+v", line)
+			}
+		})
+	}
+}
+
+// TestDeepUpdate_Collection_RunsRelatedEntityHooks verifies that nested writes go through the
+// hooks of the related entity set and that a refusal rolls back the whole update.
+func TestDeepUpdate_Collection_RunsRelatedEntityHooks(t *testing.T) {
+	service, db := setupDeepUpdateService(t)
+	checklistID := 1
+	db.Create(&DeepUpdateChecklist{ID: checklistID, Name: "Release"})
+	db.Create(&DeepUpdateChecklistItem{ID: 1, ChecklistID: &checklistID, Title: "Tag", Locked: true})
+	db.Create(&DeepUpdateChecklistItem{ID: 2, ChecklistID: &checklistID, Title: "Announce"})
+
+	patch := func(payload map[string]interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPatch, "/DeepUpdateChecklists(1)", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		service.ServeHTTP(w, req)
+		return w
+	}
+
+	for name, entry := range map[string]map[string]interface{}{
+		"update": {"ID": 1, "Title": "Retag"},
+		"delete": {"ID": 1, "@removed": map[string]interface{}{"reason": "deleted"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := patch(map[string]interface{}{
+				"Name":        "Release 2",
+				"Items@delta": []interface{}{map[string]interface{}{"ID": 2, "Title": "Announce widely"}, entry},
+			})
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("PATCH status = %v, want 403. Body: %s", w.Code, w.Body.String())
+			}
+
+			var items []DeepUpdateChecklistItem
+			db.Order("id").Find(&items)
+			if len(items) != 2 || items[0].Title != "Tag" || items[1].Title != "Announce" {
+				t.Errorf("items should be unchanged, got %+v", items)
+			}
+			var checklist DeepUpdateChecklist
+			db.First(&checklist, 1)
+			if checklist.Name != "Release" {
+				t.Errorf("Checklist.Name = %q, want the update rolled back", checklist.Name)
+			}
+		})
+	}
+
+	w := patch(map[string]interface{}{
+		"Items@delta": []interface{}{
+			map[string]interface{}{"ID": 2, "@removed": map[string]interface{}{"reason": "deleted"}},
+		},
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %v, want 204. Body: %s", w.Code, w.Body.String())
+	}
+	var remaining int64
+	db.Model(&DeepUpdateChecklistItem{}).Count(&remaining)
+	if remaining != 1 {
+		t.Errorf("expected the unlocked item to be deleted, %d items remain", remaining)
+	}
+}
@@ -0,0 +1,178 @@
+package odata_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type StoreProduct struct {
+	ID       int     `json:"ID" odata:"key"`
+	Name     string  `json:"Name"`
+	Category string  `json:"Category"`
+	Price    float64 `json:"Price"`
+}
+
+func setupEntityStoreService(t *testing.T) (*odata.Service, *odata.MemoryStore) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+
+	store, err := odata.NewMemoryStore(&StoreProduct{})
+	if err != nil {
+		t.Fatalf("NewMemoryStore() error: %v", err)
+	}
+	for _, p := range []StoreProduct{
+		{ID: 1, Name: "Laptop Pro", Category: "Computers", Price: 1999},
+		{ID: 2, Name: "Mouse", Category: "Accessories", Price: 25},
+		{ID: 3, Name: "Keyboard Pro", Category: "Accessories", Price: 120},
+		{ID: 4, Name: "Monitor", Category: "Displays", Price: 340},
+	} {
+		p := p
+		if _, err := store.Create(context.Background(), &p); err != nil {
+			t.Fatalf("Create() error: %v", err)
+		}
+	}
+
+	if err := service.RegisterEntityWithStore(&StoreProduct{}, store); err != nil {
+		t.Fatalf("RegisterEntityWithStore() error: %v", err)
+	}
+	return service, store
+}
+
+func decodeStoreCollection(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return payload
+}
+
+func TestEntityStore_CollectionQueryOptions(t *testing.T) {
+	service, _ := setupEntityStoreService(t)
+
+	w := serveRequest(t, service, http.MethodGet,
+		"/StoreProducts?$filter=contains(Name,'Pro')%20or%20Price%20lt%2050&$orderby=Price%20desc&$top=2&$count=true&$select=Name", "", nil)
+	payload := decodeStoreCollection(t, w)
+
+	if count, ok := payload["@odata.count"].(float64); !ok || count != 3 {
+		t.Fatalf("@odata.count = %v, want 3", payload["@odata.count"])
+	}
+	values := payload["value"].([]interface{})
+	if len(values) != 2 {
+		t.Fatalf("expected 2 entities, got %d: %s", len(values), w.Body.String())
+	}
+	first := values[0].(map[string]interface{})
+	if first["Name"] != "Laptop Pro" || values[1].(map[string]interface{})["Name"] != "Keyboard Pro" {
+		t.Fatalf("unexpected order: %s", w.Body.String())
+	}
+	if _, hasPrice := first["Price"]; hasPrice {
+		t.Fatalf("$select not applied: %s", w.Body.String())
+	}
+}
+
+func TestEntityStore_CountAndSkip(t *testing.T) {
+	service, _ := setupEntityStoreService(t)
+
+	w := serveRequest(t, service, http.MethodGet, "/StoreProducts/$count?$filter=Category%20eq%20'Accessories'", "", nil)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "2" {
+		t.Fatalf("$count: status %d body %q", w.Code, w.Body.String())
+	}
+
+	payload := decodeStoreCollection(t, serveRequest(t, service, http.MethodGet, "/StoreProducts?$skip=3", "", nil))
+	values := payload["value"].([]interface{})
+	if len(values) != 1 || values[0].(map[string]interface{})["ID"] != float64(4) {
+		t.Fatalf("unexpected $skip page: %v", values)
+	}
+}
+
+func TestEntityStore_CRUD(t *testing.T) {
+	service, store := setupEntityStoreService(t)
+
+	w := serveRequest(t, service, http.MethodPost, "/StoreProducts", `{"Name":"Webcam","Category":"Accessories","Price":59}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if loc := w.Header().Get("Location"); !strings.HasSuffix(loc, "/StoreProducts(5)") {
+		t.Fatalf("Location = %q", loc)
+	}
+
+	w = serveRequest(t, service, http.MethodPost, "/StoreProducts", `{"ID":1,"Name":"Duplicate"}`, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("duplicate POST status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodPatch, "/StoreProducts(5)", `{"Price":65}`, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/StoreProducts(5)?$select=Price", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var entity map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &entity); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if entity["Price"] != float64(65) {
+		t.Fatalf("unexpected entity: %s", w.Body.String())
+	}
+	if _, hasName := entity["Name"]; hasName {
+		t.Fatalf("$select not applied to entity: %s", w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodDelete, "/StoreProducts(5)", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if store.Len() != 4 {
+		t.Fatalf("store has %d records after delete, want 4", store.Len())
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/StoreProducts(5)", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GET deleted status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestEntityStore_UnsupportedFilter(t *testing.T) {
+	service, _ := setupEntityStoreService(t)
+
+	w := serveRequest(t, service, http.MethodGet, "/StoreProducts?$filter=indexof(Name,'o')%20eq%201", "", nil)
+	if w.Code != http.StatusNotImplemented {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestEntityStore_RegisterValidation(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntityWithStore(&StoreProduct{}, nil); err == nil {
+		t.Fatal("expected error for nil store")
+	}
+}
@@ -0,0 +1,127 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type FallbackPlace struct {
+	ID        uint                       `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name      string                     `json:"Name"`
+	Location  odata.GeographyPoint       `json:"Location"`
+	Route     *odata.GeographyLineString `json:"Route"`
+	Footprint *odata.GeometryPolygon     `json:"Footprint"`
+	// Legacy WKT column, interpreted in SRID 4326
+	Position string `json:"Position"`
+}
+
+func setupGeospatialFallbackService(t *testing.T, enable func(*odata.Service) error) *odata.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&FallbackPlace{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	line := func(points ...odata.Position) *odata.GeographyLineString {
+		return &odata.GeographyLineString{LineString: odata.LineString{Points: points}}
+	}
+	square := func(x, y, size float64) *odata.GeometryPolygon {
+		return &odata.GeometryPolygon{Polygon: odata.Polygon{Rings: [][]odata.Position{{
+			{X: x, Y: y}, {X: x + size, Y: y}, {X: x + size, Y: y + size}, {X: x, Y: y + size}, {X: x, Y: y},
+		}}}}
+	}
+	places := []FallbackPlace{
+		{ID: 1, Name: "Berlin", Location: odata.NewGeographyPoint(13.405, 52.52),
+			Route:     line(odata.Position{X: 13.405, Y: 52.52}, odata.Position{X: 13.405, Y: 53.52}),
+			Footprint: square(0, 0, 10), Position: "POINT(13.405 52.52)"},
+		{ID: 2, Name: "Potsdam", Location: odata.NewGeographyPoint(13.064, 52.391),
+			Route:     line(odata.Position{X: 13.064, Y: 52.391}, odata.Position{X: 13.1, Y: 52.4}),
+			Footprint: square(20, 20, 5), Position: "POINT(13.064 52.391)"},
+		{ID: 3, Name: "Paris", Location: odata.NewGeographyPoint(2.3522, 48.8566),
+			Position: "POINT(2.3522 48.8566)"},
+	}
+	if err := db.Create(&places).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := enable(service); err != nil {
+		t.Fatalf("enabling geospatial features: %v", err)
+	}
+	if err := service.RegisterEntity(&FallbackPlace{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	return service
+}
+
+func fallbackPlaceIDs(t *testing.T, service *odata.Service, filter string) []int {
+	t.Helper()
+	w := serveRequest(t, service, http.MethodGet, "/FallbackPlaces?$select=ID&$filter="+url.QueryEscape(filter), "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("$filter=%s: expected 200, got %d: %s", filter, w.Code, w.Body.String())
+	}
+	var body struct {
+		Value []struct {
+			ID int `json:"ID"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	ids := make([]int, 0, len(body.Value))
+	for _, v := range body.Value {
+		ids = append(ids, v.ID)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestGeospatialFallbackFilters(t *testing.T) {
+	service := setupGeospatialFallbackService(t, (*odata.Service).EnableGeospatial)
+
+	tests := []struct {
+		name   string
+		filter string
+		want   []int
+	}{
+		{"distance on typed property", "geo.distance(Location,geography'SRID=4326;Point(13.4 52.5)') lt 50000", []int{1, 2}},
+		{"distance on WKT column", "geo.distance(Position,geography'SRID=4326;Point(2.35 48.85)') lt 10000", []int{3}},
+		{"distance in meters", "geo.distance(Location,geography'Point(13.405 52.52)') gt 870000", []int{3}},
+		{"length in meters", "geo.length(Route) gt 100000", []int{1}},
+		{"intersects", "geo.intersects(Location,geography'Polygon((13 52,14 52,14 53,13 53,13 52))')", []int{1, 2}},
+		{"not intersects", "not geo.intersects(Location,geography'Polygon((13 52,14 52,14 53,13 53,13 52))')", []int{3}},
+		{"contains", "geo.contains(Footprint,geometry'Point(22 22)')", []int{2}},
+		{"within", "geo.within(Footprint,geometry'Polygon((-1 -1,11 -1,11 11,-1 11,-1 -1))')", []int{1}},
+		{"combined with other filters", "Name ne 'Berlin' and geo.distance(Location,geography'Point(13.4 52.5)') lt 50000", []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fallbackPlaceIDs(t, service, tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("$filter=%s returned %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeospatialFallbackExplicitOptIn(t *testing.T) {
+	service := setupGeospatialFallbackService(t, (*odata.Service).EnableGeospatialFallback)
+	if !service.IsGeospatialEnabled() {
+		t.Fatal("expected geospatial features to be enabled")
+	}
+	if got := fallbackPlaceIDs(t, service, "geo.length(Route) lt 10000"); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("geo.length returned %v, want [2]", got)
+	}
+}
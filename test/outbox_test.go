@@ -0,0 +1,254 @@
+package odata_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type OutboxProduct struct {
+	ID    uint    `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string  `json:"Name" odata:"required"`
+	Price float64 `json:"Price"`
+}
+
+type OutboxSupplier struct {
+	ID    uint         `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string       `json:"Name"`
+	Parts []OutboxPart `json:"Parts,omitempty" gorm:"foreignKey:SupplierID"`
+}
+
+type OutboxPart struct {
+	ID               uint            `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name             string          `json:"Name"`
+	SupplierID       *uint           `json:"SupplierID"`
+	Supplier         *OutboxSupplier `json:"Supplier,omitempty" gorm:"foreignKey:SupplierID"`
+	Photo            []byte          `json:"-" odata:"stream"`
+	PhotoContent     []byte          `json:"-" gorm:"type:blob"`
+	PhotoContentType string          `json:"-"`
+}
+
+type collectingPublisher struct {
+	mu     sync.Mutex
+	events []odata.ChangeEvent
+}
+
+func (p *collectingPublisher) Publish(_ context.Context, events []odata.ChangeEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func (p *collectingPublisher) snapshot() []odata.ChangeEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]odata.ChangeEvent(nil), p.events...)
+}
+
+// setupOutboxService registers OutboxProduct and entities with an outbox.
+func setupOutboxService(t *testing.T, cfg odata.OutboxConfig, entities ...interface{}) *odata.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(append([]interface{}{&OutboxProduct{}}, entities...)...); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	t.Cleanup(func() { _ = service.Close() })
+	for _, entity := range append([]interface{}{&OutboxProduct{}}, entities...) {
+		if err := service.RegisterEntity(entity); err != nil {
+			t.Fatalf("RegisterEntity() error: %v", err)
+		}
+	}
+	if err := service.EnableOutbox(cfg); err != nil {
+		t.Fatalf("EnableOutbox() error: %v", err)
+	}
+	return service
+}
+
+func TestOutbox_PublishesCommittedWrites(t *testing.T) {
+	publisher := &collectingPublisher{}
+	service := setupOutboxService(t, odata.OutboxConfig{Publisher: publisher, PollInterval: time.Hour})
+
+	if w := serveRequest(t, service, http.MethodPost, "/OutboxProducts", `{"Name":"Laptop","Price":999}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPatch, "/OutboxProducts(1)", `{"Price":899}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPost, "/OutboxProducts", `{"Price":5}`, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid POST status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodDelete, "/OutboxProducts(1)", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	if err := service.FlushOutbox(context.Background()); err != nil {
+		t.Fatalf("FlushOutbox() error: %v", err)
+	}
+
+	events := publisher.snapshot()
+	if len(events) != 3 {
+		t.Fatalf("published %d events, want 3: %+v", len(events), events)
+	}
+	want := []odata.ChangeType{odata.ChangeTypeAdded, odata.ChangeTypeUpdated, odata.ChangeTypeDeleted}
+	for i, event := range events {
+		if event.Type != want[i] || event.EntitySet != "OutboxProducts" || event.KeyValues["ID"] != float64(1) {
+			t.Fatalf("event %d = %+v", i, event)
+		}
+	}
+	if events[1].Data["Price"] != float64(899) || events[1].Data["Name"] != "Laptop" {
+		t.Fatalf("updated event payload = %v", events[1].Data)
+	}
+	if events[2].Data != nil {
+		t.Fatalf("deleted event payload = %v, want nil", events[2].Data)
+	}
+}
+
+func TestOutbox_PublishesStreamAndReferenceWrites(t *testing.T) {
+	publisher := &collectingPublisher{}
+	service := setupOutboxService(t, odata.OutboxConfig{Publisher: publisher, PollInterval: time.Hour}, &OutboxSupplier{}, &OutboxPart{})
+	for _, write := range []struct{ target, body string }{
+		{"/OutboxSuppliers", `{"ID":1,"Name":"Acme"}`},
+		{"/OutboxParts", `{"ID":1,"Name":"Bolt"}`},
+		{"/OutboxParts", `{"ID":2,"Name":"Nut"}`},
+	} {
+		if w := serveRequest(t, service, http.MethodPost, write.target, write.body, nil); w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d, body: %s", write.target, w.Code, w.Body.String())
+		}
+	}
+	if err := service.FlushOutbox(context.Background()); err != nil {
+		t.Fatalf("FlushOutbox() error: %v", err)
+	}
+	seeded := len(publisher.snapshot())
+
+	req := httptest.NewRequest(http.MethodPut, "/OutboxParts(1)/Photo/$value", strings.NewReader("png"))
+	req.Header.Set("Content-Type", "image/png")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PUT stream status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPut, "/OutboxParts(1)/Supplier/$ref",
+		`{"@odata.id": "http://localhost/OutboxSuppliers(1)"}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT $ref status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPost, "/OutboxSuppliers(1)/Parts/$ref",
+		`{"@odata.id": "http://localhost/OutboxParts(2)"}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("POST $ref status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if err := service.FlushOutbox(context.Background()); err != nil {
+		t.Fatalf("FlushOutbox() error: %v", err)
+	}
+
+	events := publisher.snapshot()[seeded:]
+	if len(events) != 3 {
+		t.Fatalf("published %d events, want 3: %+v", len(events), events)
+	}
+	// References are recorded for the entity whose row holds the foreign key.
+	for i, wantKey := range []float64{1, 1, 2} {
+		event := events[i]
+		if event.Type != odata.ChangeTypeUpdated || event.EntitySet != "OutboxParts" || event.KeyValues["ID"] != wantKey {
+			t.Fatalf("event %d = %+v", i, event)
+		}
+	}
+	if events[2].Data["SupplierID"] != float64(1) {
+		t.Fatalf("reference event payload = %v", events[2].Data)
+	}
+}
+
+func TestOutbox_ChangesetRollbackPublishesNothing(t *testing.T) {
+	publisher := &collectingPublisher{}
+	service := setupOutboxService(t, odata.OutboxConfig{Publisher: publisher, PollInterval: time.Hour})
+
+	batchBoundary := "batch_outbox"
+	changesetBoundary := "changeset_outbox"
+	body := fmt.Sprintf(`--%s
+Content-Type: multipart/mixed; boundary=%s
+
+--%s
+Content-Type: application/http
+Content-Transfer-Encoding: binary
+
+POST /OutboxProducts HTTP/1.1
+Host: localhost
+Content-Type: application/json
+
+{"Name":"Rolled back","Price":1}
+
+--%s
+Content-Type: application/http
+Content-Transfer-Encoding: binary
+
+PATCH /OutboxProducts(42) HTTP/1.1
+Host: localhost
+Content-Type: application/json
+
+{"Price":2}
+
+--%s--
+
+--%s--
+`, batchBoundary, changesetBoundary, changesetBoundary, changesetBoundary, changesetBoundary, batchBoundary)
+
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+batchBoundary)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	if err := service.FlushOutbox(context.Background()); err != nil {
+		t.Fatalf("FlushOutbox() error: %v", err)
+	}
+	if events := publisher.snapshot(); len(events) != 0 {
+		t.Fatalf("rolled back changeset published %d events: %+v", len(events), events)
+	}
+}
+
+func TestOutbox_SubscribeChanges(t *testing.T) {
+	service := setupOutboxService(t, odata.OutboxConfig{PollInterval: time.Hour})
+
+	events, cancel, err := service.SubscribeChanges(8)
+	if err != nil {
+		t.Fatalf("SubscribeChanges() error: %v", err)
+	}
+	defer cancel()
+
+	if w := serveRequest(t, service, http.MethodPost, "/OutboxProducts", `{"Name":"Mouse","Price":25}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != odata.ChangeTypeAdded || event.Data["Name"] != "Mouse" {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change event")
+	}
+}
+
+func TestOutbox_SubscribeRequiresChannelPublisher(t *testing.T) {
+	service := setupOutboxService(t, odata.OutboxConfig{Publisher: &collectingPublisher{}})
+	if _, _, err := service.SubscribeChanges(1); err == nil {
+		t.Fatal("expected error when a custom publisher is configured")
+	}
+}
@@ -0,0 +1,319 @@
+package odata_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type ResponseCacheAuthor struct {
+	ID    uint                `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string              `json:"Name"`
+	Books []ResponseCacheBook `json:"Books,omitempty" gorm:"foreignKey:AuthorID"`
+}
+
+type ResponseCacheBook struct {
+	ID       uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	Title    string `json:"Title"`
+	AuthorID uint   `json:"AuthorID"`
+}
+
+func setupResponseCacheService(t *testing.T, cfg odata.ResponseCacheConfig) (*gorm.DB, *odata.Service) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&ResponseCacheAuthor{}, &ResponseCacheBook{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	if err := db.Create(&ResponseCacheAuthor{ID: 1, Name: "Ursula"}).Error; err != nil {
+		t.Fatalf("failed to seed data: %v", err)
+	}
+	if err := db.Create(&ResponseCacheBook{ID: 1, Title: "Earthsea", AuthorID: 1}).Error; err != nil {
+		t.Fatalf("failed to seed data: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.RegisterEntityWithOptions(&ResponseCacheAuthor{}, cfg); err != nil {
+		t.Fatalf("failed to register entity: %v", err)
+	}
+	if err := service.RegisterEntity(&ResponseCacheBook{}); err != nil {
+		t.Fatalf("failed to register entity: %v", err)
+	}
+	return db, service
+}
+
+func TestResponseCache_ServesCachedCollection(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+
+	first := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$top=5&$select=Name", "", nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak collection ETag, got %q", etag)
+	}
+
+	// A change that bypasses the service is not seen until the entry is
+	// invalidated, which proves the response came from the cache.
+	if err := db.Model(&ResponseCacheAuthor{}).Where("id = ?", 1).Update("name", "Le Guin").Error; err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	// The same query options in another order and encoding share the entry.
+	second := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?%24select=Name&$top=5", "", nil)
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the cached body, got %s", second.Body.String())
+	}
+	if second.Header().Get("ETag") != etag {
+		t.Errorf("expected ETag %s, got %s", etag, second.Header().Get("ETag"))
+	}
+
+	other := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$top=6&$select=Name", "", nil)
+	if !strings.Contains(other.Body.String(), "Le Guin") {
+		t.Errorf("expected different query options to miss the cache, got %s", other.Body.String())
+	}
+}
+
+func TestResponseCache_IfNoneMatch(t *testing.T) {
+	_, service := setupResponseCacheService(t, odata.ResponseCacheConfig{
+		TTL:          time.Hour,
+		CacheControl: "private, max-age=30",
+		Vary:         []string{"accept-language"},
+	})
+
+	first := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil)
+	etag := first.Header().Get("ETag")
+	if got := first.Header().Get("Cache-Control"); got != "private, max-age=30" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+	if got := first.Header().Get("Vary"); got != "Accept, Prefer, OData-MaxVersion, Accept-Language" {
+		t.Errorf("Vary = %q", got)
+	}
+
+	notModified := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", http.Header{"If-None-Match": {etag}})
+	if notModified.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Errorf("expected an empty 304 body, got %s", notModified.Body.String())
+	}
+	if notModified.Header().Get("ETag") != etag || notModified.Header().Get("Cache-Control") == "" {
+		t.Errorf("expected the 304 to carry the validators, got %v", notModified.Header())
+	}
+
+	stale := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", http.Header{"If-None-Match": {`W/"0"`}})
+	if stale.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a stale ETag, got %d", stale.Code)
+	}
+
+	// A write changes the representation and therefore the ETag.
+	created := serveRequest(t, service, http.MethodPost, "/ResponseCacheAuthors", `{"ID": 2, "Name": "Octavia"}`, nil)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", created.Code, created.Body.String())
+	}
+	changed := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", http.Header{"If-None-Match": {etag}})
+	if changed.Code != http.StatusOK || !strings.Contains(changed.Body.String(), "Octavia") {
+		t.Fatalf("expected the new collection after a write, got %d: %s", changed.Code, changed.Body.String())
+	}
+	if changed.Header().Get("ETag") == etag {
+		t.Error("expected a new ETag after a write")
+	}
+}
+
+func TestResponseCache_EntityAndCount(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+
+	entity := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors(1)", "", nil)
+	count := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors/$count", "", nil)
+	if entity.Code != http.StatusOK || count.Body.String() != "1" {
+		t.Fatalf("unexpected responses: %d %s / %s", entity.Code, entity.Body.String(), count.Body.String())
+	}
+
+	if err := db.Create(&ResponseCacheAuthor{ID: 2, Name: "Octavia"}).Error; err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors/$count", "", nil).Body.String(); got != "1" {
+		t.Errorf("expected the cached count, got %s", got)
+	}
+
+	head := serveRequest(t, service, http.MethodHead, "/ResponseCacheAuthors(1)", "", nil)
+	if head.Code != http.StatusOK || head.Body.Len() != 0 || head.Header().Get("ETag") != entity.Header().Get("ETag") {
+		t.Errorf("expected HEAD to be answered from the cache without a body, got %d %q", head.Code, head.Body.String())
+	}
+
+	patched := serveRequest(t, service, http.MethodPatch, "/ResponseCacheAuthors(1)", `{"Name": "Le Guin"}`, nil)
+	if patched.Code != http.StatusNoContent && patched.Code != http.StatusOK {
+		t.Fatalf("expected PATCH to succeed, got %d: %s", patched.Code, patched.Body.String())
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors(1)", "", nil).Body.String(); !strings.Contains(got, "Le Guin") {
+		t.Errorf("expected the entity to be refreshed after PATCH, got %s", got)
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors/$count", "", nil).Body.String(); got != "2" {
+		t.Errorf("expected the count to be refreshed after PATCH, got %s", got)
+	}
+}
+
+func TestResponseCache_WritesToExpandedEntitySetInvalidate(t *testing.T) {
+	_, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+
+	first := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$expand=Books", "", nil)
+	if !strings.Contains(first.Body.String(), "Earthsea") {
+		t.Fatalf("expected the expanded book, got %s", first.Body.String())
+	}
+
+	created := serveRequest(t, service, http.MethodPost, "/ResponseCacheBooks", `{"ID": 2, "Title": "Tehanu", "AuthorID": 1}`, nil)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", created.Code, created.Body.String())
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$expand=Books", "", nil).Body.String(); !strings.Contains(got, "Tehanu") {
+		t.Errorf("expected a write to Books to invalidate the expanded response, got %s", got)
+	}
+}
+
+func TestResponseCache_PartitionsByPrincipal(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+	if err := service.SetPreRequestHook(func(r *http.Request) (context.Context, error) {
+		return context.WithValue(r.Context(), odata.PrincipalContextKey, r.Header.Get("X-User")), nil
+	}); err != nil {
+		t.Fatalf("SetPreRequestHook() error: %v", err)
+	}
+
+	alice := http.Header{"X-User": {"alice"}}
+	serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", alice)
+	if err := db.Model(&ResponseCacheAuthor{}).Where("id = ?", 1).Update("name", "Le Guin").Error; err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", alice).Body.String(); strings.Contains(got, "Le Guin") {
+		t.Errorf("expected alice to be served from the cache, got %s", got)
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", http.Header{"X-User": {"bob"}}).Body.String(); !strings.Contains(got, "Le Guin") {
+		t.Errorf("expected bob not to share alice's entry, got %s", got)
+	}
+}
+
+func TestResponseCache_NotConfigured(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+
+	serveRequest(t, service, http.MethodGet, "/ResponseCacheBooks", "", nil)
+	if err := db.Model(&ResponseCacheBook{}).Where("id = ?", 1).Update("title", "A Wizard of Earthsea").Error; err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	w := serveRequest(t, service, http.MethodGet, "/ResponseCacheBooks", "", nil)
+	if !strings.Contains(w.Body.String(), "A Wizard of Earthsea") {
+		t.Errorf("expected entity sets without a response cache to query the database, got %s", w.Body.String())
+	}
+	if w.Header().Get("ETag") != "" {
+		t.Errorf("expected no collection ETag without a response cache, got %s", w.Header().Get("ETag"))
+	}
+}
+
+func TestResponseCache_LargeResponsesNotCached(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour, MaxBodySize: 64})
+	for id := uint(2); id <= 20; id++ {
+		if err := db.Create(&ResponseCacheAuthor{ID: id, Name: fmt.Sprintf("Author %d", id)}).Error; err != nil {
+			t.Fatalf("failed to seed data: %v", err)
+		}
+	}
+
+	first := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", first.Code, first.Body.String())
+	}
+	if !strings.Contains(first.Body.String(), "Author 20") {
+		t.Fatalf("expected the complete collection, got %s", first.Body.String())
+	}
+	if first.Header().Get("ETag") != "" {
+		t.Errorf("expected no cache ETag for a response over the limit, got %s", first.Header().Get("ETag"))
+	}
+
+	if err := db.Model(&ResponseCacheAuthor{}).Where("id = ?", 1).Update("name", "Le Guin").Error; err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	second := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil)
+	if !strings.Contains(second.Body.String(), "Le Guin") {
+		t.Errorf("expected responses over the limit to query the database, got %s", second.Body.String())
+	}
+
+	// Responses within the limit are still cached.
+	small := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors/$count", "", nil)
+	if small.Header().Get("ETag") == "" {
+		t.Errorf("expected a cache ETag for a response within the limit")
+	}
+}
+
+func TestResponseCache_DoesNotCacheMiddlewareHeaders(t *testing.T) {
+	_, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+
+	serveWithRequestID := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/ResponseCacheAuthors", nil)
+		w := httptest.NewRecorder()
+		w.Header().Set("X-Request-Id", id)
+		service.ServeHTTP(w, req)
+		return w
+	}
+
+	first := serveWithRequestID("first")
+	second := serveWithRequestID("second")
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the cached body, got %s", second.Body.String())
+	}
+	if got := second.Header().Get("X-Request-Id"); got != "second" {
+		t.Errorf("expected the header of the current request, got %q", got)
+	}
+	if len(second.Header()["OData-Version"]) == 0 || second.Header().Get("Content-Type") == "" {
+		t.Errorf("expected the headers of the handler to be cached, got %v", second.Header())
+	}
+}
+
+func TestResponseCache_ReferenceWritesInvalidate(t *testing.T) {
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour})
+	if err := db.Create(&ResponseCacheBook{ID: 2, Title: "Tehanu"}).Error; err != nil {
+		t.Fatalf("failed to seed data: %v", err)
+	}
+
+	first := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$expand=Books", "", nil)
+	if strings.Contains(first.Body.String(), "Tehanu") {
+		t.Fatalf("expected the unassigned book to be missing, got %s", first.Body.String())
+	}
+
+	added := serveRequest(t, service, http.MethodPost, "/ResponseCacheAuthors(1)/Books/$ref",
+		`{"@odata.id": "http://localhost/ResponseCacheBooks(2)"}`, nil)
+	if added.Code != http.StatusNoContent {
+		t.Fatalf("POST $ref: expected 204, got %d: %s", added.Code, added.Body.String())
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors?$expand=Books", "", nil).Body.String(); !strings.Contains(got, "Tehanu") {
+		t.Errorf("expected a $ref write to invalidate the expanded response, got %s", got)
+	}
+}
+
+func TestResponseCache_RemoteInvalidation(t *testing.T) {
+	invalidator := odata.NewPubSubCacheInvalidator(func(context.Context, string) error { return nil })
+	db, service := setupResponseCacheService(t, odata.ResponseCacheConfig{TTL: time.Hour, Invalidator: invalidator})
+	t.Cleanup(func() { _ = service.Close() })
+
+	serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil)
+	// Another replica writes to the shared database and announces it.
+	if err := db.Model(&ResponseCacheAuthor{}).Where("id = ?", 1).Update("name", "Le Guin").Error; err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil).Body.String(); strings.Contains(got, "Le Guin") {
+		t.Fatalf("expected the cached response before the invalidation, got %s", got)
+	}
+	invalidator.Receive("ResponseCacheAuthors")
+	if got := serveRequest(t, service, http.MethodGet, "/ResponseCacheAuthors", "", nil).Body.String(); !strings.Contains(got, "Le Guin") {
+		t.Errorf("expected a remote invalidation to drop the cached response, got %s", got)
+	}
+}
@@ -0,0 +1,428 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type RLSCustomer struct {
+	ID     uint       `json:"ID" gorm:"primaryKey" odata:"key"`
+	Tenant string     `json:"Tenant"`
+	Name   string     `json:"Name"`
+	Orders []RLSOrder `json:"Orders,omitempty" gorm:"foreignKey:CustomerID"`
+}
+
+type RLSOrder struct {
+	ID         uint         `json:"ID" gorm:"primaryKey" odata:"key"`
+	Tenant     string       `json:"Tenant"`
+	Amount     float64      `json:"Amount"`
+	CustomerID uint         `json:"CustomerID"`
+	Customer   *RLSCustomer `json:"Customer,omitempty" gorm:"foreignKey:CustomerID"`
+}
+
+// tenantRowPolicy restricts every entity set to the rows of the tenant named
+// in the X-Tenant header.
+type tenantRowPolicy struct{}
+
+func (tenantRowPolicy) Authorize(odata.AuthContext, odata.ResourceDescriptor, odata.Operation) odata.Decision {
+	return odata.Allow()
+}
+
+func (tenantRowPolicy) QueryFilter(ctx odata.AuthContext, _ odata.ResourceDescriptor, _ odata.Operation) (*odata.FilterExpression, error) {
+	return &odata.FilterExpression{
+		Property: "Tenant",
+		Operator: odata.FilterOperator("eq"),
+		Value:    ctx.Request.Headers.Get("X-Tenant"),
+	}, nil
+}
+
+// setupRowSecurityService seeds two tenants whose data cross-reference each
+// other: tenant a's customer 1 owns tenant b's order 2, and tenant a's order 4
+// belongs to tenant b's customer 2.
+func setupRowSecurityService(t *testing.T, orderOptions ...odata.EntityCacheConfig) (*gorm.DB, *odata.Service) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&RLSCustomer{}, &RLSOrder{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	customers := []RLSCustomer{{ID: 1, Tenant: "a", Name: "Alice"}, {ID: 2, Tenant: "b", Name: "Bob"}}
+	if err := db.Create(&customers).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	orders := []RLSOrder{
+		{ID: 1, Tenant: "a", Amount: 10, CustomerID: 1},
+		{ID: 2, Tenant: "b", Amount: 20, CustomerID: 1},
+		{ID: 3, Tenant: "b", Amount: 30, CustomerID: 2},
+		{ID: 4, Tenant: "a", Amount: 40, CustomerID: 2},
+	}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&RLSCustomer{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.RegisterEntity(&RLSOrder{}, orderOptions...); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.SetPolicy(tenantRowPolicy{}); err != nil {
+		t.Fatalf("SetPolicy() error: %v", err)
+	}
+	return db, service
+}
+
+func serveAsTenant(t *testing.T, service *odata.Service, tenant, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveRequest(t, service, method, path, body, http.Header{"X-Tenant": {tenant}})
+}
+
+// rowIDs returns the sorted IDs of the entities in a collection response.
+func rowIDs(t *testing.T, w *httptest.ResponseRecorder) []int {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return entityIDs(body.Value)
+}
+
+func entityIDs(entities []map[string]interface{}) []int {
+	ids := make([]int, 0, len(entities))
+	for _, entity := range entities {
+		if id, ok := entity["ID"].(float64); ok {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func nestedIDs(value interface{}) []int {
+	items, _ := value.([]interface{})
+	entities := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if entity, ok := item.(map[string]interface{}); ok {
+			entities = append(entities, entity)
+		}
+	}
+	return entityIDs(entities)
+}
+
+func TestRowSecurity_Reads(t *testing.T) {
+	modes := map[string][]odata.EntityCacheConfig{
+		"database": nil,
+		"full cache": {odata.EntityCacheConfig{
+			Level: odata.CacheLevelFull,
+			TTL:   time.Minute,
+		}},
+	}
+	for mode, options := range modes {
+		t.Run(mode, func(t *testing.T) {
+			_, service := setupRowSecurityService(t, options...)
+
+			collections := []struct {
+				name string
+				path string
+				want []int
+			}{
+				{"collection", "/RLSOrders", []int{1, 4}},
+				{"filtered collection", "/RLSOrders?$filter=Amount%20gt%205", []int{1, 4}},
+				{"navigation collection", "/RLSCustomers(1)/Orders", []int{1}},
+				{"navigation of hidden parent", "/RLSCustomers(2)/Orders", nil},
+				{"references", "/RLSCustomers(1)/Orders/$ref", nil},
+				{"lambda any", "/RLSCustomers?$filter=Orders/any(o:o/Amount%20ge%2020)", []int{}},
+				{"lambda all", "/RLSCustomers?$filter=Orders/all(o:o/Tenant%20eq%20'a')", []int{1}},
+				{"navigation filter", "/RLSOrders?$filter=Customer/Name%20eq%20'Bob'", []int{}},
+				{"navigation orderby", "/RLSOrders?$orderby=Customer/Name%20desc", []int{1, 4}},
+			}
+			for _, tc := range collections {
+				t.Run(tc.name, func(t *testing.T) {
+					w := serveAsTenant(t, service, "a", http.MethodGet, tc.path, "")
+					if tc.want == nil {
+						switch {
+						case strings.Contains(tc.path, "$ref"):
+							if w.Code != http.StatusOK {
+								t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+							}
+							if body := w.Body.String(); strings.Contains(body, "RLSOrders(2)") || !strings.Contains(body, "RLSOrders(1)") {
+								t.Fatalf("Expected only the visible reference, got %s", body)
+							}
+						case w.Code != http.StatusNotFound:
+							t.Fatalf("Expected status 404, got %d: %s", w.Code, w.Body.String())
+						}
+						return
+					}
+					if got := rowIDs(t, w); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+						t.Fatalf("Expected IDs %v, got %v", tc.want, got)
+					}
+				})
+			}
+
+			t.Run("entity", func(t *testing.T) {
+				if w := serveAsTenant(t, service, "a", http.MethodGet, "/RLSOrders(1)", ""); w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				for _, path := range []string{"/RLSOrders(2)", "/RLSOrders(2)/Amount", "/RLSOrders(2)/Amount/$value", "/RLSOrders(4)/Customer", "/RLSOrders(4)/Customer/Name"} {
+					if w := serveAsTenant(t, service, "a", http.MethodGet, path, ""); w.Code == http.StatusOK {
+						t.Errorf("%s: expected the hidden entity to be unreachable, got %s", path, w.Body.String())
+					}
+				}
+			})
+
+			t.Run("count", func(t *testing.T) {
+				for path, want := range map[string]string{
+					"/RLSOrders/$count":                          "2",
+					"/RLSCustomers(1)/Orders/$count":             "1",
+					"/RLSOrders/$count?$filter=Amount%20gt%2015": "1",
+				} {
+					w := serveAsTenant(t, service, "a", http.MethodGet, path, "")
+					if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != want {
+						t.Errorf("%s: expected %s, got %d: %s", path, want, w.Code, w.Body.String())
+					}
+				}
+
+				w := serveAsTenant(t, service, "a", http.MethodGet, "/RLSOrders?$count=true&$top=1", "")
+				var body map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if count, _ := body["@odata.count"].(float64); count != 2 {
+					t.Errorf("Expected @odata.count 2, got %v", body["@odata.count"])
+				}
+			})
+
+			t.Run("apply", func(t *testing.T) {
+				w := serveAsTenant(t, service, "a", http.MethodGet, "/RLSOrders?$apply=aggregate(Amount%20with%20sum%20as%20Total)", "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				var body struct {
+					Value []map[string]interface{} `json:"value"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if len(body.Value) != 1 || body.Value[0]["Total"] != float64(50) {
+					t.Fatalf("Expected a total of 50, got %v", body.Value)
+				}
+			})
+
+			t.Run("expand", func(t *testing.T) {
+				w := serveAsTenant(t, service, "a", http.MethodGet, "/RLSOrders?$expand=Customer($expand=Orders)", "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				var body struct {
+					Value []map[string]interface{} `json:"value"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				for _, order := range body.Value {
+					customer, _ := order["Customer"].(map[string]interface{})
+					switch order["ID"] {
+					case float64(1):
+						if customer == nil || fmt.Sprint(nestedIDs(customer["Orders"])) != "[1]" {
+							t.Errorf("Expected customer 1 with only order 1, got %v", order["Customer"])
+						}
+					case float64(4):
+						if customer != nil {
+							t.Errorf("Expected the hidden customer to be omitted, got %v", customer)
+						}
+					default:
+						t.Errorf("Unexpected order %v", order["ID"])
+					}
+				}
+
+				w = serveAsTenant(t, service, "a", http.MethodGet, "/RLSCustomers(1)?$expand=Orders($count=true)", "")
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				var customer map[string]interface{}
+				if err := json.Unmarshal(w.Body.Bytes(), &customer); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if got := nestedIDs(customer["Orders"]); fmt.Sprint(got) != "[1]" {
+					t.Errorf("Expected expanded orders [1], got %v", got)
+				}
+				if count := customer["Orders@odata.count"]; count != nil && count != float64(1) {
+					t.Errorf("Expected expanded count 1, got %v", count)
+				}
+			})
+
+			t.Run("batch", func(t *testing.T) {
+				body := "--b\r\nContent-Type: application/http\r\nContent-Transfer-Encoding: binary\r\n\r\n" +
+					"GET /RLSOrders HTTP/1.1\r\nHost: localhost\r\nX-Tenant: a\r\n\r\n\r\n--b--\r\n"
+				req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+				req.Header.Set("Content-Type", "multipart/mixed; boundary=b")
+				req.Header.Set("X-Tenant", "a")
+				w := httptest.NewRecorder()
+				service.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				if response := w.Body.String(); !strings.Contains(response, `"Amount":40`) || strings.Contains(response, `"Amount":20`) || strings.Contains(response, `"Amount":30`) {
+					t.Fatalf("Expected only tenant a's orders, got %s", response)
+				}
+			})
+
+			t.Run("other tenant", func(t *testing.T) {
+				if got := rowIDs(t, serveAsTenant(t, service, "b", http.MethodGet, "/RLSOrders", "")); fmt.Sprint(got) != "[2 3]" {
+					t.Fatalf("Expected IDs [2 3], got %v", got)
+				}
+			})
+		})
+	}
+}
+
+func TestRowSecurity_Writes(t *testing.T) {
+	db, service := setupRowSecurityService(t)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"patch hidden", http.MethodPatch, "/RLSOrders(2)", `{"Amount":99}`, http.StatusNotFound},
+		{"put hidden", http.MethodPut, "/RLSOrders(2)", `{"Tenant":"a","Amount":99,"CustomerID":1}`, http.StatusNotFound},
+		{"delete hidden", http.MethodDelete, "/RLSOrders(2)", "", http.StatusNotFound},
+		{"move out", http.MethodPatch, "/RLSOrders(1)", `{"Tenant":"b"}`, http.StatusForbidden},
+		{"replace out", http.MethodPut, "/RLSOrders(1)", `{"Tenant":"b","Amount":10,"CustomerID":1}`, http.StatusForbidden},
+		{"create outside", http.MethodPost, "/RLSOrders", `{"ID":5,"Tenant":"b","Amount":50,"CustomerID":1}`, http.StatusForbidden},
+		{"bind hidden reference", http.MethodPut, "/RLSOrders(1)/Customer/$ref", `{"@odata.id":"/RLSCustomers(2)"}`, http.StatusNotFound},
+		{"add hidden reference", http.MethodPost, "/RLSCustomers(1)/Orders/$ref", `{"@odata.id":"/RLSOrders(3)"}`, http.StatusNotFound},
+		{"remove hidden reference", http.MethodDelete, "/RLSCustomers(1)/Orders(2)/$ref", "", http.StatusNotFound},
+		{"deep update hidden", http.MethodPatch, "/RLSCustomers(1)", `{"Orders@delta":[{"ID":2,"Amount":99}]}`, http.StatusNotFound},
+		{"deep update move out", http.MethodPatch, "/RLSCustomers(1)", `{"Orders@delta":[{"ID":1,"Tenant":"b"}]}`, http.StatusForbidden},
+		{"deep create outside", http.MethodPatch, "/RLSCustomers(1)", `{"Orders@delta":[{"ID":6,"Tenant":"b","Amount":60}]}`, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAsTenant(t, service, "a", tc.method, tc.path, tc.body)
+			if w.Code != tc.want {
+				t.Fatalf("Expected status %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	var orders []RLSOrder
+	if err := db.Order("id").Find(&orders).Error; err != nil {
+		t.Fatalf("Failed to load orders: %v", err)
+	}
+	want := []RLSOrder{
+		{ID: 1, Tenant: "a", Amount: 10, CustomerID: 1},
+		{ID: 2, Tenant: "b", Amount: 20, CustomerID: 1},
+		{ID: 3, Tenant: "b", Amount: 30, CustomerID: 2},
+		{ID: 4, Tenant: "a", Amount: 40, CustomerID: 2},
+	}
+	if fmt.Sprint(orders) != fmt.Sprint(want) {
+		t.Fatalf("Expected refused writes to leave the data unchanged, got %+v", orders)
+	}
+
+	if w := serveAsTenant(t, service, "a", http.MethodPost, "/RLSOrders", `{"ID":5,"Tenant":"a","Amount":50,"CustomerID":1}`); w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 for a visible entity, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveAsTenant(t, service, "a", http.MethodPatch, "/RLSOrders(5)", `{"Amount":55}`); w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+		t.Fatalf("Expected a visible entity to be updatable, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRowSecurity_BatchChangeset(t *testing.T) {
+	db, service := setupRowSecurityService(t)
+
+	body := "--b\r\nContent-Type: multipart/mixed; boundary=cs\r\n\r\n" +
+		"--cs\r\nContent-Type: application/http\r\nContent-Transfer-Encoding: binary\r\nContent-ID: 1\r\n\r\n" +
+		"PATCH /RLSOrders(2) HTTP/1.1\r\nHost: localhost\r\nX-Tenant: a\r\nContent-Type: application/json\r\n\r\n" +
+		`{"Amount":99}` + "\r\n--cs--\r\n--b--\r\n"
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary=b")
+	req.Header.Set("X-Tenant", "a")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "HTTP/1.1 404") {
+		t.Fatalf("Expected the changeset to be refused with 404, got %s", w.Body.String())
+	}
+
+	var order RLSOrder
+	if err := db.First(&order, 2).Error; err != nil {
+		t.Fatalf("Failed to load order: %v", err)
+	}
+	if order.Amount != 20 {
+		t.Fatalf("Expected the hidden order to be unchanged, got %v", order.Amount)
+	}
+}
+
+func TestRowSecurity_Delta(t *testing.T) {
+	_, service := setupRowSecurityService(t)
+	if err := service.EnableChangeTracking("RLSOrders"); err != nil {
+		t.Fatalf("EnableChangeTracking() error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/RLSOrders", nil)
+	req.Header.Set("Prefer", "odata.track-changes")
+	req.Header.Set("X-Tenant", "a")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	token := extractDeltaToken(t, w.Body.Bytes())
+
+	for _, change := range []struct{ tenant, method, path, body string }{
+		{"b", http.MethodPost, "/RLSOrders", `{"ID":6,"Tenant":"b","Amount":60,"CustomerID":2}`},
+		{"b", http.MethodPatch, "/RLSOrders(3)", `{"Amount":33}`},
+		{"b", http.MethodDelete, "/RLSOrders(2)", ""},
+		{"a", http.MethodPatch, "/RLSOrders(1)", `{"Amount":11}`},
+		{"a", http.MethodPatch, "/RLSOrders(4)", `{"Amount":44}`},
+	} {
+		if w := serveAsTenant(t, service, change.tenant, change.method, change.path, change.body); w.Code >= 300 {
+			t.Fatalf("%s %s: unexpected status %d: %s", change.method, change.path, w.Code, w.Body.String())
+		}
+	}
+
+	w = serveAsTenant(t, service, "a", http.MethodGet, "/RLSOrders?$deltatoken="+url.QueryEscape(token), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, entry := range body.Value {
+		if _, removed := entry["@odata.removed"]; removed {
+			t.Errorf("Unexpected removal in tenant a's delta: %v", entry)
+		}
+	}
+	if got := entityIDs(body.Value); fmt.Sprint(got) != "[1 4]" {
+		t.Fatalf("Expected only tenant a's changes, got %v in %s", got, w.Body.String())
+	}
+}
@@ -0,0 +1,321 @@
+package odata_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SearchRankingArticle struct {
+	ID    uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	Title string `json:"Title" odata:"searchable"`
+	Body  string `json:"Body" odata:"searchable"`
+	Views int    `json:"Views"`
+}
+
+func setupSearchRankingService(t *testing.T, register func(*odata.Service)) (*odata.Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&SearchRankingArticle{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	articles := []SearchRankingArticle{
+		{ID: 1, Title: "Cooking basics", Body: "A short note on go routines", Views: 10},
+		{ID: 2, Title: "Go concurrency", Body: "Go channels and go routines explained with go examples", Views: 30},
+		{ID: 3, Title: "Gardening", Body: "Planting tomatoes", Views: 20},
+		{ID: 4, Title: "Go testing", Body: "Table driven tests", Views: 40},
+	}
+	if err := db.Create(&articles).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&SearchRankingArticle{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if register != nil {
+		register(service)
+	}
+	return service, db
+}
+
+func getSearchRanking(t *testing.T, service *odata.Service, path string, headers map[string]string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, body
+}
+
+func searchRankingValues(t *testing.T, body map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	raw, ok := body["value"].([]interface{})
+	if !ok {
+		t.Fatalf("response has no value array: %v", body)
+	}
+	values := make([]map[string]interface{}, len(raw))
+	for i, v := range raw {
+		values[i] = v.(map[string]interface{})
+	}
+	return values
+}
+
+func searchRankingIDs(values []map[string]interface{}) []int {
+	ids := make([]int, len(values))
+	for i, v := range values {
+		ids[i] = int(v["ID"].(float64))
+	}
+	return ids
+}
+
+func TestSearchRanking_Providers(t *testing.T) {
+	providers := map[string]func(*odata.Service){
+		"FTS": nil,
+		"SearchIndex": func(service *odata.Service) {
+			if err := service.RegisterSearchProvider("SearchRankingArticles", odata.NewSearchIndex()); err != nil {
+				t.Fatalf("RegisterSearchProvider() error: %v", err)
+			}
+		},
+	}
+	for name, register := range providers {
+		t.Run(name, func(t *testing.T) {
+			service, _ := setupSearchRankingService(t, register)
+
+			status, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$orderby=$search.score%20desc&$count=true", nil)
+			if status != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %v", status, body)
+			}
+			values := searchRankingValues(t, body)
+			if ids := searchRankingIDs(values); len(ids) != 3 || ids[0] != 2 {
+				t.Fatalf("expected three matches with 2 first, got %v", ids)
+			}
+			if count, _ := body["@odata.count"].(float64); count != 3 {
+				t.Errorf("expected @odata.count 3, got %v", body["@odata.count"])
+			}
+			previous := -1.0
+			for i, v := range values {
+				score, ok := v["@search.score"].(float64)
+				if !ok {
+					t.Fatalf("entity %d has no @search.score: %v", i, v)
+				}
+				if i > 0 && score > previous {
+					t.Errorf("scores are not descending: %v", values)
+				}
+				previous = score
+			}
+
+			// Without $orderby the usual key order applies.
+			_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=go", nil)
+			if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 4 {
+				t.Errorf("expected key order [1 2 4], got %v", ids)
+			}
+		})
+	}
+}
+
+func TestSearchRanking_PagingAndSecondaryOrder(t *testing.T) {
+	service, _ := setupSearchRankingService(t, func(service *odata.Service) {
+		if err := service.RegisterSearchProvider("SearchRankingArticles", odata.NewSearchIndex()); err != nil {
+			t.Fatalf("RegisterSearchProvider() error: %v", err)
+		}
+	})
+
+	_, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$orderby=$search.score%20desc&$top=1", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("expected [2], got %v", ids)
+	}
+	nextLink, _ := body["@odata.nextLink"].(string)
+	if nextLink == "" {
+		t.Fatalf("expected a next link, got %v", body)
+	}
+	_, body = getSearchRanking(t, service, nextLink[strings.Index(nextLink, "/SearchRankingArticles"):], nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 1 || ids[0] == 2 {
+		t.Errorf("expected the second best match on the next page, got %v", ids)
+	}
+
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$filter=Views%20gt%2015&$orderby=$search.score%20desc,Views", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Errorf("expected [2 4], got %v", ids)
+	}
+}
+
+func TestSearchRanking_Annotations(t *testing.T) {
+	service, _ := setupSearchRankingService(t, nil)
+
+	_, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=go", map[string]string{
+		"Prefer": "odata.include-annotations=\"-search.score\"",
+	})
+	for _, v := range searchRankingValues(t, body) {
+		if _, ok := v["@search.score"]; ok {
+			t.Errorf("expected @search.score to be excluded, got %v", v)
+		}
+	}
+
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$select=Title", nil)
+	for _, v := range searchRankingValues(t, body) {
+		if _, ok := v["@search.score"]; !ok {
+			t.Errorf("expected @search.score with $select, got %v", v)
+		}
+	}
+
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$filter=Views%20gt%2015", nil)
+	for _, v := range searchRankingValues(t, body) {
+		if _, ok := v["@search.score"]; ok {
+			t.Errorf("expected no @search.score without $search, got %v", v)
+		}
+	}
+}
+
+// floodingSearchProvider matches more entities than one key restriction can
+// carry. Articles 2, 4 and 1 are ranked before, between and after thousands of
+// keys without an entity, so they end up on different pages of keys.
+type floodingSearchProvider struct {
+	limits []int
+}
+
+func (p *floodingSearchProvider) Search(_ context.Context, req odata.SearchRequest) ([]odata.SearchHit, error) {
+	p.limits = append(p.limits, req.Limit)
+	hits := []odata.SearchHit{{Key: []interface{}{uint(2)}, Score: 9}}
+	for i := 0; i < 6000; i++ {
+		hits = append(hits, odata.SearchHit{Key: []interface{}{uint(i + 1000)}, Score: 5})
+	}
+	hits = append(hits,
+		odata.SearchHit{Key: []interface{}{uint(4)}, Score: 2},
+		odata.SearchHit{Key: []interface{}{uint(1)}, Score: 1})
+	return hits, nil
+}
+
+func TestSearchRanking_ManyMatchesKeepRanking(t *testing.T) {
+	provider := &floodingSearchProvider{}
+	service, _ := setupSearchRankingService(t, func(service *odata.Service) {
+		if err := service.RegisterSearchProvider("SearchRankingArticles", provider); err != nil {
+			t.Fatalf("RegisterSearchProvider() error: %v", err)
+		}
+	})
+
+	status, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$count=true", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, body)
+	}
+	if len(provider.limits) == 0 || provider.limits[0] != 0 {
+		t.Fatalf("expected an unbounded provider request, got limits %v", provider.limits)
+	}
+	values := searchRankingValues(t, body)
+	if ids := searchRankingIDs(values); fmt.Sprint(ids) != "[1 2 4]" {
+		t.Fatalf("expected the provider's matches in key order, got %v", ids)
+	}
+	if count, _ := body["@odata.count"].(float64); count != 3 {
+		t.Errorf("expected @odata.count 3, got %v", body["@odata.count"])
+	}
+	for _, v := range values {
+		if _, ok := v["@search.score"]; !ok {
+			t.Errorf("expected @search.score on every match, got %v", v)
+		}
+	}
+
+	cases := []struct {
+		query string
+		want  []int
+	}{
+		{"$orderby=$search.score%20desc&$top=2", []int{2, 4}},
+		{"$orderby=$search.score%20desc&$skip=1&$top=1", []int{4}},
+		{"$orderby=$search.score", []int{1, 4, 2}},
+		{"$orderby=Views%20desc", []int{4, 2, 1}},
+		{"$filter=Views%20gt%2015&$orderby=$search.score%20desc", []int{2, 4}},
+	}
+	for _, tc := range cases {
+		status, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=go&"+tc.query, nil)
+		if status != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", tc.query, status, body)
+		}
+		if ids := searchRankingIDs(searchRankingValues(t, body)); fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.query, tc.want, ids)
+		}
+	}
+}
+
+func TestSearchRanking_ScoreRequiresSearch(t *testing.T) {
+	service, _ := setupSearchRankingService(t, nil)
+
+	status, _ := getSearchRanking(t, service, "/SearchRankingArticles?$orderby=$search.score%20desc", nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", status)
+	}
+}
+
+func TestSearchRanking_IndexUpdatedOnWrite(t *testing.T) {
+	service, _ := setupSearchRankingService(t, func(service *odata.Service) {
+		if err := service.RegisterSearchProvider("SearchRankingArticles", odata.NewSearchIndex()); err != nil {
+			t.Fatalf("RegisterSearchProvider() error: %v", err)
+		}
+	})
+
+	_, body := getSearchRanking(t, service, "/SearchRankingArticles?$search=rust", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 0 {
+		t.Fatalf("expected no matches, got %v", ids)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/SearchRankingArticles",
+		strings.NewReader(`{"ID": 5, "Title": "Rust ownership", "Body": "Borrowing", "Views": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=rust", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 1 || ids[0] != 5 {
+		t.Errorf("expected [5] after the write, got %v", ids)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/SearchRankingArticles(2)", strings.NewReader(`{"Title": "Rust concurrency"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/SearchRankingArticles(5)", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=rust", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("expected [2] after the update and delete, got %v", ids)
+	}
+	_, body = getSearchRanking(t, service, "/SearchRankingArticles?$search=go&$orderby=ID", nil)
+	if ids := searchRankingIDs(searchRankingValues(t, body)); fmt.Sprint(ids) != "[1 2 4]" {
+		t.Errorf("expected [1 2 4] for the unchanged body text, got %v", ids)
+	}
+}
+
+func TestSearchRanking_RegisterUnknownEntitySet(t *testing.T) {
+	service, _ := setupSearchRankingService(t, nil)
+	if err := service.RegisterSearchProvider("Missing", odata.NewSearchIndex()); err == nil {
+		t.Error("expected an error for an unknown entity set")
+	}
+}
@@ -0,0 +1,316 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SoftCategory struct {
+	ID       uint          `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name     string        `json:"Name"`
+	Products []SoftProduct `json:"Products,omitempty" gorm:"foreignKey:CategoryID"`
+}
+
+type SoftProduct struct {
+	ID         uint       `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name       string     `json:"Name"`
+	CategoryID uint       `json:"CategoryID"`
+	DeletedAt  *time.Time `json:"DeletedAt,omitempty" odata:"softdelete"`
+}
+
+// softDeletePolicy allows every operation but reserves deleted entities for
+// requests carrying the X-Role: admin header.
+type softDeletePolicy struct{}
+
+func (softDeletePolicy) Authorize(ctx odata.AuthContext, _ odata.ResourceDescriptor, operation odata.Operation) odata.Decision {
+	switch operation {
+	case odata.OperationReadDeleted, odata.OperationRestore:
+		if ctx.Request.Headers.Get("X-Role") != "admin" {
+			return odata.Deny("deleted entities are reserved for administrators")
+		}
+	}
+	return odata.Allow()
+}
+
+func setupSoftDeleteService(t *testing.T, withPolicy bool) (*gorm.DB, *odata.Service) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&SoftCategory{}, &SoftProduct{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := db.Create(&SoftCategory{ID: 1, Name: "Lighting"}).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	for _, p := range []SoftProduct{{ID: 1, Name: "Lamp", CategoryID: 1}, {ID: 2, Name: "Bulb", CategoryID: 1}} {
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatalf("Failed to seed: %v", err)
+		}
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&SoftCategory{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.RegisterEntity(&SoftProduct{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if withPolicy {
+		if err := service.SetPolicy(softDeletePolicy{}); err != nil {
+			t.Fatalf("SetPolicy() error: %v", err)
+		}
+	}
+	return db, service
+}
+
+func serveSoftDelete(t *testing.T, service *odata.Service, method, path, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body string
+	if method == http.MethodPatch {
+		body = `{"Name":"Renamed"}`
+	}
+	header := http.Header{"Authorization": {"Bearer test"}}
+	if role != "" {
+		header.Set("X-Role", role)
+	}
+	return serveRequest(t, service, method, path, body, header)
+}
+
+func softDeleteNames(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	names := make([]string, 0, len(body.Value))
+	for _, entity := range body.Value {
+		names = append(names, entity["Name"].(string))
+	}
+	return names
+}
+
+func TestSoftDelete_DeleteHidesEntity(t *testing.T) {
+	db, service := setupSoftDeleteService(t, false)
+
+	if w := serveSoftDelete(t, service, http.MethodDelete, "/SoftProducts(1)", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var stored SoftProduct
+	if err := db.Unscoped().First(&stored, 1).Error; err != nil {
+		t.Fatalf("Expected the row to be kept: %v", err)
+	}
+	if stored.DeletedAt == nil {
+		t.Fatal("Expected DeletedAt to be stamped")
+	}
+
+	if names := softDeleteNames(t, serveSoftDelete(t, service, http.MethodGet, "/SoftProducts", "")); strings.Join(names, ",") != "Bulb" {
+		t.Errorf("Expected only Bulb, got %v", names)
+	}
+	if w := serveSoftDelete(t, service, http.MethodGet, "/SoftProducts/$count", ""); w.Body.String() != "1" {
+		t.Errorf("Expected count 1, got %q", w.Body.String())
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		if w := serveSoftDelete(t, service, method, "/SoftProducts(1)", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s deleted entity: expected status 404, got %d", method, w.Code)
+		}
+	}
+
+	w := serveSoftDelete(t, service, http.MethodGet, "/SoftCategories(1)?$expand=Products", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expand: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var category SoftCategory
+	if err := json.Unmarshal(w.Body.Bytes(), &category); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(category.Products) != 1 || category.Products[0].Name != "Bulb" {
+		t.Errorf("Expected the expanded products to exclude Lamp, got %+v", category.Products)
+	}
+	if names := softDeleteNames(t, serveSoftDelete(t, service, http.MethodGet, "/SoftCategories(1)/Products", "")); strings.Join(names, ",") != "Bulb" {
+		t.Errorf("Navigation: expected only Bulb, got %v", names)
+	}
+}
+
+func TestSoftDelete_DeltaReportsRemoval(t *testing.T) {
+	_, service := setupSoftDeleteService(t, true)
+	if err := service.EnableChangeTracking("SoftProducts"); err != nil {
+		t.Fatalf("EnableChangeTracking() error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/SoftProducts", nil)
+	req.Header.Set("Prefer", "odata.track-changes")
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	token := extractDeltaToken(t, w.Body.Bytes())
+
+	if w := serveSoftDelete(t, service, http.MethodDelete, "/SoftProducts(1)", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveSoftDelete(t, service, http.MethodGet, "/SoftProducts?$deltatoken="+url.QueryEscape(token), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Delta: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	changes := valueEntries(t, decodeJSON(t, w.Body.Bytes()))
+	if len(changes) != 1 {
+		t.Fatalf("Expected one change, got %d: %s", len(changes), w.Body.String())
+	}
+	if _, ok := changes[0]["@odata.removed"]; !ok {
+		t.Errorf("Expected a removed entry, got %v", changes[0])
+	}
+	token = extractDeltaToken(t, w.Body.Bytes())
+
+	if w := serveSoftDelete(t, service, http.MethodPost, "/SoftProducts(1)/Restore", "admin"); w.Code != http.StatusOK {
+		t.Fatalf("Restore: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = serveSoftDelete(t, service, http.MethodGet, "/SoftProducts?$deltatoken="+url.QueryEscape(token), "")
+	changes = valueEntries(t, decodeJSON(t, w.Body.Bytes()))
+	if len(changes) != 1 || changes[0]["Name"] != "Lamp" {
+		t.Errorf("Expected the restored entity in the delta, got %s", w.Body.String())
+	}
+}
+
+func TestSoftDelete_ListDeleted(t *testing.T) {
+	_, service := setupSoftDeleteService(t, true)
+	if w := serveSoftDelete(t, service, http.MethodDelete, "/SoftProducts(1)", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if names := softDeleteNames(t, serveSoftDelete(t, service, http.MethodGet, "/SoftProducts?$deleted=only", "admin")); strings.Join(names, ",") != "Lamp" {
+		t.Errorf("$deleted=only: expected Lamp, got %v", names)
+	}
+	if names := softDeleteNames(t, serveSoftDelete(t, service, http.MethodGet, "/SoftProducts?$deleted=include&$orderby=ID", "admin")); strings.Join(names, ",") != "Lamp,Bulb" {
+		t.Errorf("$deleted=include: expected Lamp,Bulb, got %v", names)
+	}
+	if w := serveSoftDelete(t, service, http.MethodGet, "/SoftProducts/$count?$deleted=include", "admin"); w.Body.String() != "2" {
+		t.Errorf("Expected count 2, got %q", w.Body.String())
+	}
+
+	w := serveSoftDelete(t, service, http.MethodGet, "/SoftProducts(1)?$deleted=include", "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Single entity: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var product SoftProduct
+	if err := json.Unmarshal(w.Body.Bytes(), &product); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if product.DeletedAt == nil {
+		t.Error("Expected DeletedAt in the response")
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		role   string
+		status int
+	}{
+		{name: "not privileged", path: "/SoftProducts?$deleted=only", status: http.StatusForbidden},
+		{name: "not privileged single entity", path: "/SoftProducts(1)?$deleted=include", status: http.StatusForbidden},
+		{name: "not privileged count", path: "/SoftProducts/$count?$deleted=include", status: http.StatusForbidden},
+		{name: "invalid value", path: "/SoftProducts?$deleted=all", role: "admin", status: http.StatusBadRequest},
+		{name: "unsupported entity set", path: "/SoftCategories?$deleted=only", role: "admin", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveSoftDelete(t, service, http.MethodGet, tt.path, tt.role); w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSoftDelete_RequiresPolicy(t *testing.T) {
+	_, service := setupSoftDeleteService(t, false)
+	if w := serveSoftDelete(t, service, http.MethodGet, "/SoftProducts?$deleted=only", "admin"); w.Code != http.StatusForbidden {
+		t.Errorf("List: expected status 403, got %d", w.Code)
+	}
+	if w := serveSoftDelete(t, service, http.MethodPost, "/SoftProducts(1)/Restore", "admin"); w.Code != http.StatusForbidden {
+		t.Errorf("Restore: expected status 403, got %d", w.Code)
+	}
+}
+
+func TestSoftDelete_Restore(t *testing.T) {
+	db, service := setupSoftDeleteService(t, true)
+	if w := serveSoftDelete(t, service, http.MethodDelete, "/SoftProducts(1)", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := serveSoftDelete(t, service, http.MethodPost, "/SoftProducts(1)/Restore", ""); w.Code != http.StatusForbidden {
+		t.Errorf("Not privileged: expected status 403, got %d", w.Code)
+	}
+	if w := serveSoftDelete(t, service, http.MethodPost, "/SoftProducts(9)/Restore", "admin"); w.Code != http.StatusNotFound {
+		t.Errorf("Missing entity: expected status 404, got %d", w.Code)
+	}
+
+	w := serveSoftDelete(t, service, http.MethodPost, "/SoftProducts(1)/Restore", "admin")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Restore: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var restored SoftProduct
+	if err := json.Unmarshal(w.Body.Bytes(), &restored); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if restored.Name != "Lamp" || restored.DeletedAt != nil {
+		t.Errorf("Expected the restored Lamp, got %+v", restored)
+	}
+
+	var stored SoftProduct
+	if err := db.First(&stored, 1).Error; err != nil {
+		t.Fatalf("Failed to load row: %v", err)
+	}
+	if stored.DeletedAt != nil {
+		t.Error("Expected DeletedAt to be cleared")
+	}
+	if w := serveSoftDelete(t, service, http.MethodGet, "/SoftProducts(1)", ""); w.Code != http.StatusOK {
+		t.Errorf("GET restored entity: expected status 200, got %d", w.Code)
+	}
+}
+
+func TestSoftDelete_Metadata(t *testing.T) {
+	_, service := setupSoftDeleteService(t, false)
+	w := serveSoftDelete(t, service, http.MethodGet, "/$metadata", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `<Action Name="Restore" IsBound="true">`) {
+		t.Errorf("Expected the bound Restore action in metadata:\n%s", w.Body.String())
+	}
+}
+
+func TestSoftDelete_RejectsNonTimestampField(t *testing.T) {
+	type InvalidSoftDelete struct {
+		ID      uint `json:"ID" gorm:"primaryKey" odata:"key"`
+		Deleted bool `json:"Deleted" odata:"softdelete"`
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&InvalidSoftDelete{}); err == nil {
+		t.Fatal("Expected an error for a non-timestamp soft delete field")
+	}
+}
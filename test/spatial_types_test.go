@@ -0,0 +1,221 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SpatialStore struct {
+	ID       uint                       `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name     string                     `json:"Name"`
+	Location odata.GeographyPoint       `json:"Location"`
+	Route    *odata.GeographyLineString `json:"Route"`
+	Area     *odata.GeometryPolygon     `json:"Area" odata:"srid=3857"`
+}
+
+func setupSpatialStoreService(t *testing.T) (*odata.Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&SpatialStore{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	store := SpatialStore{
+		ID:       1,
+		Name:     "Berlin",
+		Location: odata.NewGeographyPoint(13.4, 52.5),
+		Route: &odata.GeographyLineString{LineString: odata.LineString{
+			Points: []odata.Position{{X: 13.4, Y: 52.5}, {X: 13.5, Y: 52.6}},
+		}},
+	}
+	if err := db.Create(&store).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&SpatialStore{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	return service, db
+}
+
+func TestSpatialTypesMetadata(t *testing.T) {
+	service, _ := setupSpatialStoreService(t)
+
+	w := serveRequest(t, service, http.MethodGet, "/$metadata", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	xml := w.Body.String()
+	for _, want := range []string{
+		`Name="Location" Type="Edm.GeographyPoint" Nullable="false"`,
+		`Name="Route" Type="Edm.GeographyLineString" Nullable="true"`,
+		`Name="Area" Type="Edm.GeometryPolygon" Nullable="true" SRID="3857"`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("expected $metadata to contain %s, got:\n%s", want, xml)
+		}
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/$metadata?$format=json", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON metadata: %v", err)
+	}
+	var entityType map[string]interface{}
+	for _, schema := range doc {
+		if schemaMap, ok := schema.(map[string]interface{}); ok {
+			if et, ok := schemaMap["SpatialStore"].(map[string]interface{}); ok {
+				entityType = et
+			}
+		}
+	}
+	if entityType == nil {
+		t.Fatalf("SpatialStore entity type not found in JSON metadata: %s", w.Body.String())
+	}
+	area, _ := entityType["Area"].(map[string]interface{})
+	if area["$Type"] != "Edm.GeometryPolygon" || area["$SRID"] != float64(3857) {
+		t.Errorf("Area = %v, want $Type Edm.GeometryPolygon and $SRID 3857", area)
+	}
+	location, _ := entityType["Location"].(map[string]interface{})
+	if location["$Type"] != "Edm.GeographyPoint" {
+		t.Errorf("Location $Type = %v, want Edm.GeographyPoint", location["$Type"])
+	}
+}
+
+func TestSpatialTypesSerializeAsGeoJSON(t *testing.T) {
+	service, _ := setupSpatialStoreService(t)
+
+	w := serveRequest(t, service, http.MethodGet, "/SpatialStores(1)", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if got, want := string(body["Location"]), `{"type":"Point","coordinates":[13.4,52.5]}`; got != want {
+		t.Errorf("Location = %s, want %s", got, want)
+	}
+	if got, want := string(body["Route"]), `{"type":"LineString","coordinates":[[13.4,52.5],[13.5,52.6]]}`; got != want {
+		t.Errorf("Route = %s, want %s", got, want)
+	}
+	if got := string(body["Area"]); got != "null" {
+		t.Errorf("Area = %s, want null", got)
+	}
+
+	w = serveRequest(t, service, http.MethodGet, "/SpatialStores?$select=Location", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"Location":{"type":"Point","coordinates":[13.4,52.5]}`) {
+		t.Errorf("expected GeoJSON Location in collection, got %s", w.Body.String())
+	}
+}
+
+func TestSpatialTypesWrite(t *testing.T) {
+	service, db := setupSpatialStoreService(t)
+
+	t.Run("POST with GeoJSON", func(t *testing.T) {
+		w := serveRequest(t, service, http.MethodPost, "/SpatialStores",
+			`{"ID":2,"Name":"Paris","Location":{"type":"Point","coordinates":[2.35,48.85]},`+
+				`"Area":{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,0]]],"crs":{"type":"name","properties":{"name":"EPSG:3857"}}}}`, nil)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var stored SpatialStore
+		if err := db.First(&stored, 2).Error; err != nil {
+			t.Fatalf("failed to load stored entity: %v", err)
+		}
+		if stored.Location.X != 2.35 || stored.Location.Y != 48.85 || stored.Location.SRID != 4326 {
+			t.Errorf("stored Location = %+v", stored.Location.Point)
+		}
+		if stored.Area == nil || stored.Area.SRID != 3857 || len(stored.Area.Rings) != 1 {
+			t.Errorf("stored Area = %+v", stored.Area)
+		}
+	})
+
+	t.Run("POST with WKT", func(t *testing.T) {
+		w := serveRequest(t, service, http.MethodPost, "/SpatialStores",
+			`{"ID":3,"Name":"Rome","Location":"SRID=4326;POINT(12.5 41.9)","Route":"LINESTRING(12.5 41.9, 12.6 42)"}`, nil)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `"Location":{"type":"Point","coordinates":[12.5,41.9]}`) {
+			t.Errorf("expected GeoJSON Location in response, got %s", w.Body.String())
+		}
+		var stored SpatialStore
+		if err := db.First(&stored, 3).Error; err != nil {
+			t.Fatalf("failed to load stored entity: %v", err)
+		}
+		if stored.Route == nil || len(stored.Route.Points) != 2 {
+			t.Errorf("stored Route = %+v", stored.Route)
+		}
+	})
+
+	t.Run("PATCH with WKT and GeoJSON", func(t *testing.T) {
+		w := serveRequest(t, service, http.MethodPatch, "/SpatialStores(1)",
+			`{"Location":"POINT(13.41 52.52)","Area":{"type":"Polygon","coordinates":[[[1,1],[2,1],[2,2],[1,1]]]}}`, nil)
+		if w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+			t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+		}
+		var stored SpatialStore
+		if err := db.First(&stored, 1).Error; err != nil {
+			t.Fatalf("failed to load stored entity: %v", err)
+		}
+		if stored.Location.X != 13.41 || stored.Location.Y != 52.52 {
+			t.Errorf("stored Location = %+v", stored.Location.Point)
+		}
+		if stored.Area == nil || len(stored.Area.Rings) != 1 || stored.Area.Rings[0][1].X != 2 {
+			t.Errorf("stored Area = %+v", stored.Area)
+		}
+		if stored.Route == nil || len(stored.Route.Points) != 2 {
+			t.Errorf("PATCH must leave Route unchanged, got %+v", stored.Route)
+		}
+	})
+
+	t.Run("PATCH with null", func(t *testing.T) {
+		w := serveRequest(t, service, http.MethodPatch, "/SpatialStores(1)", `{"Route":null}`, nil)
+		if w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+			t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+		}
+		var stored SpatialStore
+		if err := db.First(&stored, 1).Error; err != nil {
+			t.Fatalf("failed to load stored entity: %v", err)
+		}
+		if stored.Route != nil {
+			t.Errorf("expected Route to be cleared, got %+v", stored.Route)
+		}
+	})
+
+	t.Run("rejects values of the wrong shape", func(t *testing.T) {
+		cases := []struct {
+			method, path, body string
+		}{
+			{http.MethodPost, "/SpatialStores", `{"ID":4,"Name":"Bad","Location":{"type":"LineString","coordinates":[[0,0],[1,1]]}}`},
+			{http.MethodPost, "/SpatialStores", `{"ID":5,"Name":"Bad","Location":"POINT(1)"}`},
+			{http.MethodPatch, "/SpatialStores(1)", `{"Location":"LINESTRING(0 0, 1 1)"}`},
+			{http.MethodPatch, "/SpatialStores(1)", `{"Location":42}`},
+		}
+		for _, c := range cases {
+			w := serveRequest(t, service, c.method, c.path, c.body, nil)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s %s: expected 400, got %d: %s", c.method, c.path, c.body, w.Code, w.Body.String())
+			}
+		}
+	})
+}
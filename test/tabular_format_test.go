@@ -0,0 +1,205 @@
+package odata_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type TabularAddress struct {
+	Street string `json:"Street"`
+	City   string `json:"City"`
+}
+
+type TabularProduct struct {
+	ID         int             `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name       string          `json:"Name"`
+	Category   string          `json:"Category"`
+	Price      float64         `json:"Price"`
+	SupplierID int             `json:"SupplierID"`
+	Address    *TabularAddress `json:"Address,omitempty" gorm:"embedded;embeddedPrefix:addr_" odata:"nullable"`
+}
+
+type TabularSupplier struct {
+	ID       int              `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name     string           `json:"Name"`
+	Products []TabularProduct `json:"Products" gorm:"foreignKey:SupplierID;references:ID"`
+}
+
+func setupTabularService(t *testing.T) *odata.Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	if err := db.AutoMigrate(&TabularSupplier{}, &TabularProduct{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	db.Create(&TabularSupplier{ID: 1, Name: "Acme"})
+	db.Create(&[]TabularProduct{
+		{ID: 1, Name: "Laptop", Category: "Electronics", Price: 1000, SupplierID: 1, Address: &TabularAddress{Street: "Main St 1", City: "Berlin"}},
+		{ID: 2, Name: "Mouse", Category: "Electronics", Price: 20, SupplierID: 1},
+		{ID: 3, Name: "Desk", Category: "Furniture", Price: 300, SupplierID: 1},
+	})
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&TabularProduct{}); err != nil {
+		t.Fatalf("Failed to register TabularProduct: %v", err)
+	}
+	if err := service.RegisterEntity(&TabularSupplier{}); err != nil {
+		t.Fatalf("Failed to register TabularSupplier: %v", err)
+	}
+	if err := service.RegisterFunction(odata.FunctionDefinition{
+		Name:       "TopProductNames",
+		ReturnType: reflect.TypeOf([]string{}),
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) (interface{}, error) {
+			return []string{"Laptop", "Desk"}, nil
+		},
+	}); err != nil {
+		t.Fatalf("Failed to register function: %v", err)
+	}
+	return service
+}
+
+func getTabular(t *testing.T, service *odata.Service, target, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status = %d, body: %s", target, w.Code, w.Body.String())
+	}
+	return w
+}
+
+func readCSV(t *testing.T, w *httptest.ResponseRecorder) [][]string {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Content-Type = %q, want text/csv", ct)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v; body: %s", err, w.Body.String())
+	}
+	return records
+}
+
+func TestTabularFormat_CSVSelectOrdersAndFlattens(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TabularProducts?$format=csv&$select=Address,Name&$orderby=ID", "")
+	records := readCSV(t, w)
+
+	want := [][]string{
+		{"Address/Street", "Address/City", "Name"},
+		{"Main St 1", "Berlin", "Laptop"},
+		{"", "", "Mouse"},
+		{"", "", "Desk"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %v, want %v", records, want)
+	}
+}
+
+func TestTabularFormat_CSVViaAcceptWithNextLink(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TabularProducts?$top=2&$orderby=ID", "text/csv")
+	records := readCSV(t, w)
+
+	if len(records) != 3 || records[0][0] != "ID" || records[1][0] != "1" || records[2][0] != "2" {
+		t.Errorf("CSV = %v, want header and products 1 and 2", records)
+	}
+	link := w.Header().Get("Link")
+	if !strings.HasPrefix(link, "<") || !strings.HasSuffix(link, `>; rel="next"`) {
+		t.Errorf("Link = %q, want a next link", link)
+	}
+}
+
+func TestTabularFormat_NDJSON(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TabularProducts?$select=Name&$orderby=ID", "application/x-ndjson")
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), w.Body.String())
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if first["Name"] != "Laptop" || first["ID"] != float64(1) {
+		t.Errorf("first line = %v, want ID 1 and Name Laptop", first)
+	}
+	if _, ok := first["Price"]; ok {
+		t.Errorf("first line = %v, want unselected Price omitted", first)
+	}
+}
+
+func TestTabularFormat_ApplyGroupBy(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TabularProducts?$format=csv&$apply=groupby((Category),aggregate(Price%20with%20sum%20as%20Total))&$orderby=Category", "")
+	records := readCSV(t, w)
+
+	want := [][]string{
+		{"Category", "Total"},
+		{"Electronics", "1020"},
+		{"Furniture", "300"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %v, want %v", records, want)
+	}
+}
+
+func TestTabularFormat_NavigationCollection(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TabularSuppliers(1)/Products?$format=ndjson", "")
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3: %q", len(lines), w.Body.String())
+	}
+}
+
+func TestTabularFormat_FunctionResult(t *testing.T) {
+	service := setupTabularService(t)
+
+	w := getTabular(t, service, "/TopProductNames()?$format=csv", "")
+	records := readCSV(t, w)
+
+	want := [][]string{{"value"}, {"Laptop"}, {"Desk"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %v, want %v", records, want)
+	}
+}
+
+func TestTabularFormat_SingleEntityNotAcceptable(t *testing.T) {
+	service := setupTabularService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/TabularProducts(1)?$format=csv", nil)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotAcceptable)
+	}
+}
@@ -0,0 +1,353 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type TemporalProduct struct {
+	ID    uint    `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string  `json:"Name"`
+	Price float64 `json:"Price"`
+}
+
+type TemporalPlainProduct struct {
+	ID   uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name string `json:"Name"`
+}
+
+func setupTemporalService(t *testing.T) (*gorm.DB, *odata.Service) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&TemporalProduct{}, &TemporalPlainProduct{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := db.Create(&TemporalProduct{ID: 1, Name: "Lamp", Price: 10}).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntityWithOptions(&TemporalProduct{}, odata.TemporalConfig{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.RegisterEntity(&TemporalPlainProduct{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	return db, service
+}
+
+// temporalInstant returns a point in time strictly between the writes before
+// and after the call.
+func temporalInstant(t *testing.T) string {
+	t.Helper()
+	time.Sleep(5 * time.Millisecond)
+	instant := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(5 * time.Millisecond)
+	return url.QueryEscape(instant)
+}
+
+type temporalCollection struct {
+	Count *int                     `json:"@odata.count"`
+	Next  string                   `json:"@odata.nextLink"`
+	Value []map[string]interface{} `json:"value"`
+}
+
+func readTemporalCollection(t *testing.T, service *odata.Service, path string) temporalCollection {
+	t.Helper()
+	w := serveRequest(t, service, http.MethodGet, path, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d: %s", path, w.Code, w.Body.String())
+	}
+	var body temporalCollection
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to parse response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func temporalSummary(values []map[string]interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%v:%v", v["ID"], v["Price"])
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestTemporal_AsOf(t *testing.T) {
+	_, service := setupTemporalService(t)
+
+	beforeRegistration := url.QueryEscape(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	t0 := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodPost, "/TemporalProducts", `{"ID": 2, "Name": "Desk", "Price": 100}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("POST: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	t1 := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodPatch, "/TemporalProducts(1)", `{"Price": 12}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	t2 := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodPut, "/TemporalProducts(2)", `{"ID": 2, "Name": "Desk", "Price": 90}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	t3 := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodDelete, "/TemporalProducts(1)", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "$at=" + beforeRegistration, want: ""},
+		{query: "$at=" + t0, want: "1:10"},
+		{query: "$at=" + t1, want: "1:10,2:100"},
+		{query: "$at=" + t2, want: "1:12,2:100"},
+		{query: "$at=" + t3, want: "1:12,2:90"},
+		{query: "", want: "2:90"},
+		{query: "$at=" + t2 + "&$filter=Price%20gt%2050", want: "2:100"},
+		{query: "$at=" + t2 + "&$orderby=Price%20desc", want: "2:100,1:12"},
+		{query: "$at=" + t3 + "&$top=1&$skip=1", want: "2:90"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			body := readTemporalCollection(t, service, "/TemporalProducts?"+tt.query)
+			if got := temporalSummary(body.Value); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	body := readTemporalCollection(t, service, "/TemporalProducts?$at="+t2+"&$select=Name&$count=true")
+	if body.Count == nil || *body.Count != 2 || len(body.Value) != 2 || body.Value[0]["Name"] != "Lamp" {
+		t.Errorf("unexpected $select/$count result: %+v", body)
+	}
+	if _, ok := body.Value[0]["Price"]; ok {
+		t.Errorf("expected $select to apply to the historical state, got %v", body.Value[0])
+	}
+
+	w := serveRequest(t, service, http.MethodGet, "/TemporalProducts(1)?$at="+t1, "", nil)
+	var entity TemporalProduct
+	if err := json.Unmarshal(w.Body.Bytes(), &entity); err != nil || w.Code != http.StatusOK || entity.Price != 10 {
+		t.Errorf("expected the deleted entity's original state, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodGet, "/TemporalProducts(2)?$at="+t0, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before the entity existed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodGet, "/TemporalProducts(1)", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the deleted entity, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTemporal_Period(t *testing.T) {
+	_, service := setupTemporalService(t)
+
+	from := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodPatch, "/TemporalProducts(1)", `{"Price": 12}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPost, "/TemporalProducts", `{"ID": 2, "Name": "Desk", "Price": 100}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("POST: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodDelete, "/TemporalProducts(2)", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	to := temporalInstant(t)
+
+	body := readTemporalCollection(t, service, "/TemporalProducts?$from="+from+"&$to="+to+"&$count=true")
+	if got := temporalSummary(body.Value); got != "1:10,1:12,2:100" {
+		t.Fatalf("expected every version, got %q", got)
+	}
+	if body.Count == nil || *body.Count != 3 {
+		t.Errorf("expected $count to count versions, got %v", body.Count)
+	}
+	const fromAnnotation, toAnnotation = "@Org.OData.Temporal.V1.From", "@Org.OData.Temporal.V1.To"
+	first, second, third := body.Value[0], body.Value[1], body.Value[2]
+	if first[toAnnotation] == nil || first[toAnnotation] != second[fromAnnotation] {
+		t.Errorf("expected the first version to end where the second begins, got %v and %v", first, second)
+	}
+	if v, ok := second[toAnnotation]; !ok || v != nil {
+		t.Errorf("expected the current version to be open, got %v", second)
+	}
+	if third[fromAnnotation] == nil || third[toAnnotation] == nil {
+		t.Errorf("expected the deleted entity's version to be closed, got %v", third)
+	}
+
+	body = readTemporalCollection(t, service, "/TemporalProducts?$from="+to)
+	if got := temporalSummary(body.Value); got != "1:12" {
+		t.Errorf("expected only the version valid after the period, got %q", got)
+	}
+
+	body = readTemporalCollection(t, service, "/TemporalProducts?$from="+from+"&$to="+to+"&$top=2")
+	if len(body.Value) != 2 || !strings.Contains(body.Next, "skip=2") {
+		t.Errorf("expected a $skip next link for version pages, got %+v", body)
+	}
+}
+
+func TestTemporal_InvalidQueries(t *testing.T) {
+	_, service := setupTemporalService(t)
+
+	now := url.QueryEscape(time.Now().UTC().Format(time.RFC3339))
+	for _, path := range []string{
+		"/TemporalPlainProducts?$at=" + now,
+		"/TemporalProducts?$at=" + now + "&$from=" + now,
+		"/TemporalProducts?$from=2024-02-01&$to=2024-01-01",
+		"/TemporalProducts?$at=yesterday",
+		"/TemporalProducts?$at=" + now + "&$search=Lamp",
+		"/TemporalProducts?$at=" + now + "&$apply=aggregate(Price%20with%20sum%20as%20Total)",
+		"/TemporalProducts(1)?$from=" + now,
+	} {
+		if w := serveRequest(t, service, http.MethodGet, path, "", nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTemporal_Metadata(t *testing.T) {
+	_, service := setupTemporalService(t)
+
+	xml := serveRequest(t, service, http.MethodGet, "/$metadata", "", nil).Body.String()
+	for _, want := range []string{
+		`Org.OData.Temporal.V1.xml`,
+		`Term="Org.OData.Temporal.V1.ApplicationTimeSupport"`,
+		`<Record Type="Org.OData.Temporal.V1.TimelineSnapshot">`,
+		`<Record Type="Org.OData.Temporal.V1.UnitOfTimeDateTimeOffset">`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("expected $metadata to contain %s:\n%s", want, xml)
+		}
+	}
+	if strings.Count(xml, "ApplicationTimeSupport") != 1 {
+		t.Errorf("expected only the temporal entity set to be annotated")
+	}
+}
+
+func TestTemporal_HistoryRollsBackWithChangeset(t *testing.T) {
+	db, service := setupTemporalService(t)
+
+	batchBoundary := "batch_temporal"
+	changesetBoundary := "changeset_temporal"
+	body := fmt.Sprintf(`--%s
+Content-Type: multipart/mixed; boundary=%s
+
+--%s
+Content-Type: application/http
+Content-Transfer-Encoding: binary
+
+PATCH /TemporalProducts(1) HTTP/1.1
+Host: localhost
+Content-Type: application/json
+
+{"Price":99}
+
+--%s
+Content-Type: application/http
+Content-Transfer-Encoding: binary
+
+PATCH /TemporalProducts(42) HTTP/1.1
+Host: localhost
+Content-Type: application/json
+
+{"Price":2}
+
+--%s--
+
+--%s--
+`, batchBoundary, changesetBoundary, changesetBoundary, changesetBoundary, changesetBoundary, batchBoundary)
+
+	req := httptest.NewRequest(http.MethodPost, "/$batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "multipart/mixed; boundary="+batchBoundary)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	var versions int64
+	if err := db.Table("temporal_products_history").Count(&versions).Error; err != nil {
+		t.Fatalf("Failed to count versions: %v", err)
+	}
+	if versions != 1 {
+		t.Errorf("expected the rolled back changeset to leave only the seeded version, got %d", versions)
+	}
+}
+
+type TemporalSupplier struct {
+	ID    uint           `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string         `json:"Name"`
+	Parts []TemporalPart `json:"Parts,omitempty" gorm:"foreignKey:SupplierID"`
+}
+
+type TemporalPart struct {
+	ID         uint              `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name       string            `json:"Name"`
+	SupplierID *uint             `json:"SupplierID"`
+	Supplier   *TemporalSupplier `json:"Supplier,omitempty" gorm:"foreignKey:SupplierID"`
+}
+
+func TestTemporal_ReferenceChangesRecordVersions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&TemporalSupplier{}, &TemporalPart{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := db.Create(&TemporalSupplier{ID: 1, Name: "Acme"}).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	if err := db.Create(&[]TemporalPart{{ID: 1, Name: "Bolt"}, {ID: 2, Name: "Nut"}}).Error; err != nil {
+		t.Fatalf("Failed to seed: %v", err)
+	}
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&TemporalSupplier{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+	if err := service.RegisterEntityWithOptions(&TemporalPart{}, odata.TemporalConfig{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+
+	t0 := temporalInstant(t)
+	if w := serveRequest(t, service, http.MethodPut, "/TemporalParts(1)/Supplier/$ref",
+		`{"@odata.id": "http://localhost/TemporalSuppliers(1)"}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT $ref: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveRequest(t, service, http.MethodPost, "/TemporalSuppliers(1)/Parts/$ref",
+		`{"@odata.id": "http://localhost/TemporalParts(2)"}`, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("POST $ref: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	t1 := temporalInstant(t)
+
+	for instant, want := range map[string]float64{t0: 0, t1: 2} {
+		body := readTemporalCollection(t, service, "/TemporalParts?$at="+instant+"&$filter=SupplierID%20eq%201&$count=true")
+		if body.Count == nil || float64(*body.Count) != want {
+			t.Errorf("$at=%s: expected %v parts of the supplier, got %+v", instant, want, body)
+		}
+	}
+
+	var versions int64
+	if err := db.Table("temporal_parts_history").Count(&versions).Error; err != nil {
+		t.Fatalf("Failed to count versions: %v", err)
+	}
+	if versions != 4 {
+		t.Errorf("expected a new version of each part, got %d versions", versions)
+	}
+}
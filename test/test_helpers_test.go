@@ -1,9 +1,14 @@
 package odata_test
 
 import (
-	odata "github.com/nlstn/go-odata"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	odata "github.com/nlstn/go-odata"
+
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -36,3 +41,23 @@ func setupTestService(t *testing.T) (*odata.Service, *gorm.DB) {
 
 	return service, db
 }
+
+// serveRequest sends a request to service and returns the recorded response. A
+// non-empty body is sent as JSON; header adds further request headers.
+func serveRequest(t *testing.T, service http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	return w
+}
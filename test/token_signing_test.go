@@ -0,0 +1,187 @@
+package odata_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type SignedTokenProduct struct {
+	ID    int     `json:"ID" gorm:"primaryKey" odata:"key"`
+	Name  string  `json:"Name"`
+	Price float64 `json:"Price"`
+}
+
+type SignedTokenOrder struct {
+	ID    int     `json:"ID" gorm:"primaryKey" odata:"key"`
+	Price float64 `json:"Price"`
+}
+
+func setupTokenSigningService(t *testing.T, db *gorm.DB, cfg odata.ServiceConfig) *odata.Service {
+	t.Helper()
+
+	service, err := odata.NewServiceWithConfig(db, cfg)
+	if err != nil {
+		t.Fatalf("NewServiceWithConfig() error: %v", err)
+	}
+	for _, entity := range []interface{}{&SignedTokenProduct{}, &SignedTokenOrder{}} {
+		if err := service.RegisterEntity(entity); err != nil {
+			t.Fatalf("RegisterEntity() error: %v", err)
+		}
+	}
+	for _, entitySet := range []string{"SignedTokenProducts", "SignedTokenOrders"} {
+		if err := service.EnableChangeTracking(entitySet); err != nil {
+			t.Fatalf("EnableChangeTracking() error: %v", err)
+		}
+	}
+	return service
+}
+
+func openTokenSigningDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&SignedTokenProduct{}, &SignedTokenOrder{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		db.Create(&SignedTokenProduct{ID: i, Name: "Product", Price: float64(i * 10)})
+		db.Create(&SignedTokenOrder{ID: i, Price: float64(i)})
+	}
+	return db
+}
+
+func serveTokenSigningRequest(service *odata.Service, target string, prefer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	return w
+}
+
+func extractSkipToken(t *testing.T, body []byte) string {
+	t.Helper()
+
+	payload := decodeJSON(t, body)
+	link, ok := payload["@odata.nextLink"].(string)
+	if !ok || link == "" {
+		t.Fatalf("next link missing: %v", payload["@odata.nextLink"])
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse next link: %v", err)
+	}
+	return parsed.Query().Get("$skiptoken")
+}
+
+func TestSignedSkipToken(t *testing.T) {
+	service := setupTokenSigningService(t, openTokenSigningDB(t), odata.ServiceConfig{
+		TokenSigningKey: []byte("signing-key"),
+	})
+
+	first := serveTokenSigningRequest(service, "/SignedTokenProducts?$filter=Price%20gt%2010&$orderby=Price&$top=2", "")
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", first.Code, first.Body.String())
+	}
+	token := extractSkipToken(t, first.Body.Bytes())
+
+	next := serveTokenSigningRequest(service, "/SignedTokenProducts?$filter=Price%20gt%2010&$orderby=Price&$top=2&$skiptoken="+url.QueryEscape(token), "")
+	if next.Code != http.StatusOK {
+		t.Fatalf("expected signed token to be accepted, got %d: %s", next.Code, next.Body.String())
+	}
+	if !strings.Contains(next.Body.String(), `"Price":40`) || strings.Contains(next.Body.String(), `"Price":30`) {
+		t.Errorf("expected second page to start after the first page, got %s", next.Body.String())
+	}
+
+	payload, _, _ := strings.Cut(token, ".")
+	tamperedJSON, _ := base64.URLEncoding.DecodeString(payload)
+	tampered := base64.URLEncoding.EncodeToString([]byte(strings.Replace(string(tamperedJSON), "30", "0", 1))) + token[len(payload):]
+
+	tests := []struct {
+		name   string
+		target string
+	}{
+		{name: "unsigned token", target: "/SignedTokenProducts?$filter=Price%20gt%2010&$orderby=Price&$top=2&$skiptoken=" + url.QueryEscape(payload)},
+		{name: "tampered token", target: "/SignedTokenProducts?$filter=Price%20gt%2010&$orderby=Price&$top=2&$skiptoken=" + url.QueryEscape(tampered)},
+		{name: "different filter", target: "/SignedTokenProducts?$filter=Price%20gt%200&$orderby=Price&$top=2&$skiptoken=" + url.QueryEscape(token)},
+		{name: "different entity set", target: "/SignedTokenOrders?$filter=Price%20gt%2010&$orderby=Price&$top=2&$skiptoken=" + url.QueryEscape(token)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveTokenSigningRequest(service, tt.target, "")
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), "skiptoken") {
+				t.Errorf("expected error to mention the skiptoken, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignedDeltaToken(t *testing.T) {
+	service := setupTokenSigningService(t, openTokenSigningDB(t), odata.ServiceConfig{
+		TokenSigningKey: []byte("signing-key"),
+	})
+
+	initial := serveTokenSigningRequest(service, "/SignedTokenProducts", "odata.track-changes")
+	if initial.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", initial.Code, initial.Body.String())
+	}
+	token := extractDeltaToken(t, initial.Body.Bytes())
+
+	delta := serveTokenSigningRequest(service, "/SignedTokenProducts?$deltatoken="+url.QueryEscape(token), "")
+	if delta.Code != http.StatusOK {
+		t.Fatalf("expected signed delta token to be accepted, got %d: %s", delta.Code, delta.Body.String())
+	}
+	extractDeltaToken(t, delta.Body.Bytes())
+
+	replayed := serveTokenSigningRequest(service, "/SignedTokenOrders?$deltatoken="+url.QueryEscape(token), "")
+	if replayed.Code != http.StatusBadRequest {
+		t.Fatalf("expected delta token replay against another entity set to fail, got %d: %s", replayed.Code, replayed.Body.String())
+	}
+
+	payload, _, _ := strings.Cut(token, ".")
+	unsigned := serveTokenSigningRequest(service, "/SignedTokenProducts?$deltatoken="+url.QueryEscape(payload), "")
+	if unsigned.Code != http.StatusBadRequest {
+		t.Fatalf("expected unsigned delta token to fail, got %d: %s", unsigned.Code, unsigned.Body.String())
+	}
+}
+
+func TestSignedTokenKeyRotation(t *testing.T) {
+	db := openTokenSigningDB(t)
+	oldService := setupTokenSigningService(t, db, odata.ServiceConfig{TokenSigningKey: []byte("old-key")})
+
+	first := serveTokenSigningRequest(oldService, "/SignedTokenProducts?$top=2", "")
+	token := extractSkipToken(t, first.Body.Bytes())
+	target := "/SignedTokenProducts?$top=2&$skiptoken=" + url.QueryEscape(token)
+
+	rotated := setupTokenSigningService(t, db, odata.ServiceConfig{
+		TokenSigningKey:       []byte("new-key"),
+		TokenVerificationKeys: [][]byte{[]byte("old-key")},
+	})
+	if w := serveTokenSigningRequest(rotated, target, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected token signed with a verification key to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+
+	retired := setupTokenSigningService(t, db, odata.ServiceConfig{TokenSigningKey: []byte("new-key")})
+	if w := serveTokenSigningRequest(retired, target, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected token signed with a retired key to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, err := odata.NewServiceWithConfig(db, odata.ServiceConfig{TokenVerificationKeys: [][]byte{[]byte("old-key")}}); err == nil {
+		t.Error("expected verification keys without a signing key to be rejected")
+	}
+}
@@ -0,0 +1,239 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/gorm"
+)
+
+type typedRestockParams struct {
+	ProductID uint `json:"productID"`
+	Quantity  int  `json:"quantity" odata:"annotation:Validation.Minimum=1"`
+}
+
+type typedPriceParams struct {
+	TaxRate float64 `json:"taxRate"`
+}
+
+type typedPriceResult struct {
+	Net   float64 `json:"net"`
+	Gross float64 `json:"gross"`
+}
+
+func decodeTypedResponse(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return payload
+}
+
+func TestTypedAction_Unbound(t *testing.T) {
+	service, db := setupActionFunctionTestService(t)
+
+	err := odata.RegisterTypedAction(service, "Restock",
+		func(r *http.Request, p typedRestockParams) (ActionTestProduct, error) {
+			var product ActionTestProduct
+			if err := db.First(&product, p.ProductID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return product, &odata.ODataError{StatusCode: http.StatusNotFound, Code: "NotFound", Message: "product not found"}
+				}
+				return product, err
+			}
+			product.Price += float64(p.Quantity)
+			return product, db.Save(&product).Error
+		})
+	if err != nil {
+		t.Fatalf("RegisterTypedAction() error: %v", err)
+	}
+
+	payload := decodeTypedResponse(t, serveRequest(t, service, http.MethodPost, "/Restock", `{"productID":2,"quantity":5}`, nil))
+	if ctx, _ := payload["@odata.context"].(string); !strings.HasSuffix(ctx, "$metadata#ActionTestProducts/$entity") {
+		t.Fatalf("@odata.context = %q", ctx)
+	}
+	value, ok := payload["value"].(map[string]interface{})
+	if !ok || value["Price"] != float64(30) || value["Name"] != "Mouse" {
+		t.Fatalf("unexpected result: %v", payload)
+	}
+
+	w := serveRequest(t, service, http.MethodPost, "/Restock", `{"productID":99,"quantity":1}`, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing product status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTypedAction_InvalidParameters(t *testing.T) {
+	service, _ := setupActionFunctionTestService(t)
+
+	err := odata.RegisterTypedAction(service, "Restock",
+		func(r *http.Request, p typedRestockParams) (odata.NoResult, error) {
+			return odata.NoResult{}, nil
+		})
+	if err != nil {
+		t.Fatalf("RegisterTypedAction() error: %v", err)
+	}
+
+	w := serveRequest(t, service, http.MethodPost, "/Restock", `{"productID":"abc","quantity":1}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("wrong type status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	w = serveRequest(t, service, http.MethodPost, "/Restock", `{"productID":1,"quantity":0}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Validation.Minimum status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTypedAction_NoResult(t *testing.T) {
+	service, _ := setupActionFunctionTestService(t)
+
+	called := false
+	err := odata.RegisterTypedAction(service, "Reset",
+		func(r *http.Request, _ odata.NoParams) (odata.NoResult, error) {
+			called = true
+			return odata.NoResult{}, nil
+		})
+	if err != nil {
+		t.Fatalf("RegisterTypedAction() error: %v", err)
+	}
+
+	w := serveRequest(t, service, http.MethodPost, "/Reset", `{}`, nil)
+	if w.Code != http.StatusNoContent || !called {
+		t.Fatalf("status = %d (called=%v), body: %s", w.Code, called, w.Body.String())
+	}
+}
+
+func TestTypedAction_Bound(t *testing.T) {
+	service, db := setupActionFunctionTestService(t)
+
+	err := odata.RegisterBoundTypedAction(service, "ActionTestProducts", "ApplyDiscount",
+		func(r *http.Request, p *ActionTestProduct, params struct {
+			Percentage float64 `json:"percentage" odata:"annotation:Validation.Maximum=50"`
+		}) (*ActionTestProduct, error) {
+			p.Price *= 1 - params.Percentage/100
+			return p, db.Save(p).Error
+		})
+	if err != nil {
+		t.Fatalf("RegisterBoundTypedAction() error: %v", err)
+	}
+
+	payload := decodeTypedResponse(t, serveRequest(t, service, http.MethodPost, "/ActionTestProducts(1)/ApplyDiscount", `{"percentage":10}`, nil))
+	if value := payload["value"].(map[string]interface{}); value["Price"] != float64(900) {
+		t.Fatalf("unexpected result: %v", payload)
+	}
+
+	var stored ActionTestProduct
+	if err := db.First(&stored, 1).Error; err != nil || stored.Price != 900 {
+		t.Fatalf("stored price = %v (%v), want 900", stored.Price, err)
+	}
+
+	w := serveRequest(t, service, http.MethodPost, "/ActionTestProducts(1)/ApplyDiscount", `{"percentage":80}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Validation.Maximum status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTypedFunction_Unbound(t *testing.T) {
+	service, db := setupActionFunctionTestService(t)
+
+	err := odata.RegisterTypedFunction(service, "GetTopProducts",
+		func(r *http.Request, p struct {
+			Count int64 `json:"count"`
+		}) ([]ActionTestProduct, error) {
+			var products []ActionTestProduct
+			err := db.Order("price DESC").Limit(int(p.Count)).Find(&products).Error
+			return products, err
+		})
+	if err != nil {
+		t.Fatalf("RegisterTypedFunction() error: %v", err)
+	}
+
+	payload := decodeTypedResponse(t, serveRequest(t, service, http.MethodGet, "/GetTopProducts(count=2)", "", nil))
+	if ctx, _ := payload["@odata.context"].(string); !strings.HasSuffix(ctx, "$metadata#ActionTestProducts") {
+		t.Fatalf("@odata.context = %q", ctx)
+	}
+	values, ok := payload["value"].([]interface{})
+	if !ok || len(values) != 2 || values[0].(map[string]interface{})["Name"] != "Laptop" {
+		t.Fatalf("unexpected result: %v", payload)
+	}
+
+	w := serveRequest(t, service, http.MethodGet, "/GetTopProducts(count='many')", "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid parameter status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTypedFunction_Bound(t *testing.T) {
+	service, _ := setupActionFunctionTestService(t)
+
+	err := odata.RegisterBoundTypedFunction(service, "ActionTestProducts", "GetPrice",
+		func(r *http.Request, p *ActionTestProduct, params typedPriceParams) (typedPriceResult, error) {
+			return typedPriceResult{Net: p.Price, Gross: p.Price * (1 + params.TaxRate)}, nil
+		})
+	if err != nil {
+		t.Fatalf("RegisterBoundTypedFunction() error: %v", err)
+	}
+
+	payload := decodeTypedResponse(t, serveRequest(t, service, http.MethodGet, "/ActionTestProducts(3)/GetPrice(taxRate=0.2)", "", nil))
+	if _, ok := payload["@odata.context"].(string); !ok {
+		t.Fatalf("missing @odata.context: %v", payload)
+	}
+	value, ok := payload["value"].(map[string]interface{})
+	if !ok || value["net"] != float64(75) || value["gross"] != float64(90) {
+		t.Fatalf("unexpected result: %v", payload)
+	}
+
+	metadataReq := serveRequest(t, service, http.MethodGet, "/$metadata", "", nil)
+	if body := metadataReq.Body.String(); !strings.Contains(body, `Name="GetPrice"`) || !strings.Contains(body, `Name="taxRate"`) {
+		t.Fatalf("function not advertised in metadata: %s", body)
+	}
+}
+
+func TestTypedOperations_RegistrationErrors(t *testing.T) {
+	service, _ := setupActionFunctionTestService(t)
+
+	type otherEntity struct {
+		ID int `json:"ID" odata:"key"`
+	}
+	err := odata.RegisterBoundTypedAction(service, "ActionTestProducts", "Mismatch",
+		func(r *http.Request, e *otherEntity, _ odata.NoParams) (odata.NoResult, error) {
+			return odata.NoResult{}, nil
+		})
+	if err == nil {
+		t.Fatal("expected error for mismatched bound entity type")
+	}
+
+	err = odata.RegisterBoundTypedFunction(service, "Missing", "Lookup",
+		func(r *http.Request, e *ActionTestProduct, _ odata.NoParams) (int, error) {
+			return 0, nil
+		})
+	if err == nil {
+		t.Fatal("expected error for unknown entity set")
+	}
+
+	err = odata.RegisterTypedAction(service, "ScalarParams",
+		func(r *http.Request, p int) (odata.NoResult, error) {
+			return odata.NoResult{}, nil
+		})
+	if err == nil {
+		t.Fatal("expected error for non-struct parameter type")
+	}
+
+	err = odata.RegisterTypedFunction(service, "NoValue",
+		func(r *http.Request, _ odata.NoParams) (odata.NoResult, error) {
+			return odata.NoResult{}, nil
+		})
+	if err == nil {
+		t.Fatal("expected error for function without a return type")
+	}
+}
@@ -0,0 +1,182 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type ValidatedProduct struct {
+	ID       uint    `json:"ID" gorm:"primaryKey" odata:"key"`
+	SKU      string  `json:"SKU" odata:"annotation:Validation.Pattern=^[A-Z]+$"`
+	Price    float64 `json:"Price" odata:"annotation:Validation.Minimum=0;@Validation.Exclusive"`
+	Quantity int     `json:"Quantity" odata:"annotation:Validation.Maximum=100"`
+}
+
+type validatedDiscountParams struct {
+	Percentage float64 `json:"percentage" odata:"annotation:Validation.Maximum=50"`
+}
+
+func setupValidationVocabularyService(t *testing.T) *odata.Service {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&ValidatedProduct{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Create(&ValidatedProduct{ID: 1, SKU: "ABC", Price: 10, Quantity: 5})
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("NewService() error: %v", err)
+	}
+	if err := service.RegisterEntity(&ValidatedProduct{}); err != nil {
+		t.Fatalf("RegisterEntity() error: %v", err)
+	}
+
+	err = service.RegisterAction(odata.ActionDefinition{
+		Name:                "ApplyValidatedDiscount",
+		ParameterStructType: reflect.TypeOf(validatedDiscountParams{}),
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) error {
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterAction() error: %v", err)
+	}
+
+	return service
+}
+
+func TestValidationVocabularyEnforcedOnWrites(t *testing.T) {
+	service := setupValidationVocabularyService(t)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedTarget []string
+	}{
+		{
+			name:           "valid create",
+			method:         http.MethodPost,
+			path:           "/ValidatedProducts",
+			body:           `{"ID":2,"SKU":"XYZ","Price":1.5,"Quantity":100}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "create violating several constraints",
+			method:         http.MethodPost,
+			path:           "/ValidatedProducts",
+			body:           `{"ID":3,"SKU":"abc","Price":0,"Quantity":101}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedTarget: []string{"Price", "Quantity", "SKU"},
+		},
+		{
+			name:           "patch violating exclusive minimum",
+			method:         http.MethodPatch,
+			path:           "/ValidatedProducts(1)",
+			body:           `{"Price":0}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedTarget: []string{"Price"},
+		},
+		{
+			name:           "patch leaving constrained properties untouched",
+			method:         http.MethodPatch,
+			path:           "/ValidatedProducts(1)",
+			body:           `{"Quantity":7}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "put violating pattern",
+			method:         http.MethodPut,
+			path:           "/ValidatedProducts(1)",
+			body:           `{"ID":1,"SKU":"a1","Price":3,"Quantity":1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedTarget: []string{"SKU"},
+		},
+		{
+			name:           "action parameter above maximum",
+			method:         http.MethodPost,
+			path:           "/ApplyValidatedDiscount",
+			body:           `{"percentage":75}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedTarget: []string{"percentage"},
+		},
+		{
+			name:           "action parameter within maximum",
+			method:         http.MethodPost,
+			path:           "/ApplyValidatedDiscount",
+			body:           `{"percentage":50}`,
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			service.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if len(tt.expectedTarget) == 0 {
+				return
+			}
+
+			var response struct {
+				Error struct {
+					Details []struct {
+						Target  string `json:"target"`
+						Message string `json:"message"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+
+			targets := make([]string, 0, len(response.Error.Details))
+			for _, detail := range response.Error.Details {
+				targets = append(targets, detail.Target)
+			}
+			if !reflect.DeepEqual(targets, tt.expectedTarget) {
+				t.Errorf("expected detail targets %v, got %v (%s)", tt.expectedTarget, targets, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidationExclusiveAnnotationInMetadata(t *testing.T) {
+	service := setupValidationVocabularyService(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/$metadata", nil)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `<Annotation Term="Org.OData.Validation.V1.Exclusive" Bool="true"`) {
+		t.Errorf("expected nested Validation.Exclusive annotation in XML metadata, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/$metadata?$format=json", nil)
+	w = httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"@Org.OData.Validation.V1.Minimum@Org.OData.Validation.V1.Exclusive": true`) {
+		t.Errorf("expected nested Validation.Exclusive annotation in JSON metadata, got %s", w.Body.String())
+	}
+}
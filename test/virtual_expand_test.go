@@ -0,0 +1,365 @@
+package odata_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	odata "github.com/nlstn/go-odata"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type VirtualExpandOrder struct {
+	ID        uint                    `json:"ID" gorm:"primaryKey" odata:"key"`
+	Customer  string                  `json:"Customer"`
+	Shipments []VirtualExpandShipment `json:"Shipments,omitempty" gorm:"foreignKey:OrderID"`
+	Status    *VirtualExpandStatus    `json:"Status,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// VirtualExpandShipment is served by a carrier API rather than the database.
+type VirtualExpandShipment struct {
+	ID      uint                `json:"ID" odata:"key"`
+	OrderID uint                `json:"OrderID"`
+	Carrier string              `json:"Carrier"`
+	State   string              `json:"State"`
+	Order   *VirtualExpandOrder `json:"Order,omitempty" gorm:"foreignKey:OrderID"`
+	// Events is served by a virtual entity set without a GetCollection overwrite.
+	Events []VirtualExpandEvent `json:"Events,omitempty" gorm:"foreignKey:ShipmentID"`
+	Labels []VirtualExpandLabel `json:"Labels,omitempty" gorm:"many2many:virtual_expand_shipment_labels"`
+}
+
+type VirtualExpandEvent struct {
+	ID         uint   `json:"ID" odata:"key"`
+	ShipmentID uint   `json:"ShipmentID"`
+	Message    string `json:"Message"`
+}
+
+type VirtualExpandLabel struct {
+	ID   uint   `json:"ID" gorm:"primaryKey" odata:"key"`
+	Text string `json:"Text"`
+}
+
+type VirtualExpandStatus struct {
+	OrderID uint   `json:"OrderID" odata:"key"`
+	State   string `json:"State"`
+}
+
+var virtualExpandShipments = []VirtualExpandShipment{
+	{ID: 1, OrderID: 1, Carrier: "DHL", State: "Delivered"},
+	{ID: 2, OrderID: 1, Carrier: "UPS", State: "Delivered"},
+	{ID: 3, OrderID: 1, Carrier: "FedEx", State: "InTransit"},
+	{ID: 4, OrderID: 2, Carrier: "DHL", State: "InTransit"},
+	{ID: 5, OrderID: 9, Carrier: "DHL", State: "Delivered"},
+}
+
+var virtualExpandStatuses = []VirtualExpandStatus{
+	{OrderID: 1, State: "Closed"},
+	{OrderID: 2, State: "Open"},
+}
+
+// matchesVirtualExpandFilter evaluates the subset of filters the tests send to
+// the carrier API: comparisons and "in" combined with and/or.
+func matchesVirtualExpandFilter(filter *odata.FilterExpression, fields map[string]interface{}) bool {
+	if filter == nil {
+		return true
+	}
+	switch filter.Logical {
+	case "and":
+		return matchesVirtualExpandFilter(filter.Left, fields) && matchesVirtualExpandFilter(filter.Right, fields)
+	case "or":
+		return matchesVirtualExpandFilter(filter.Left, fields) || matchesVirtualExpandFilter(filter.Right, fields)
+	}
+	actual := fmt.Sprint(fields[filter.Property])
+	switch filter.Operator {
+	case "eq":
+		return actual == fmt.Sprint(filter.Value)
+	case "in":
+		values, _ := filter.Value.([]interface{})
+		for _, value := range values {
+			if actual == fmt.Sprint(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type virtualExpandCalls struct {
+	shipments []*odata.OverwriteContext
+	statuses  int
+	// resolveOrders makes the shipment overwrite populate Order itself.
+	resolveOrders bool
+}
+
+func setupVirtualExpandService(t *testing.T) (*odata.Service, *virtualExpandCalls) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect database: %v", err)
+	}
+	if err := db.AutoMigrate(&VirtualExpandOrder{}, &VirtualExpandLabel{}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	orders := []VirtualExpandOrder{{ID: 1, Customer: "Ada"}, {ID: 2, Customer: "Grace"}, {ID: 3, Customer: "Linus"}}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatalf("failed to seed data: %v", err)
+	}
+
+	service, err := odata.NewService(db)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	if err := service.RegisterEntity(&VirtualExpandOrder{}); err != nil {
+		t.Fatalf("failed to register entity: %v", err)
+	}
+	if err := service.RegisterVirtualEntity(&VirtualExpandShipment{}); err != nil {
+		t.Fatalf("failed to register virtual entity: %v", err)
+	}
+	if err := service.RegisterVirtualEntity(&VirtualExpandStatus{}); err != nil {
+		t.Fatalf("failed to register virtual entity: %v", err)
+	}
+	if err := service.RegisterVirtualEntity(&VirtualExpandEvent{}); err != nil {
+		t.Fatalf("failed to register virtual entity: %v", err)
+	}
+	if err := service.RegisterEntity(&VirtualExpandLabel{}); err != nil {
+		t.Fatalf("failed to register entity: %v", err)
+	}
+
+	calls := &virtualExpandCalls{}
+	err = service.SetEntityOverwrite("VirtualExpandShipments", &odata.EntityOverwrite{
+		GetCollection: func(ctx *odata.OverwriteContext) (*odata.CollectionResult, error) {
+			calls.shipments = append(calls.shipments, ctx)
+			var items []VirtualExpandShipment
+			for _, shipment := range virtualExpandShipments {
+				fields := map[string]interface{}{"ID": shipment.ID, "OrderID": shipment.OrderID, "State": shipment.State}
+				if matchesVirtualExpandFilter(ctx.QueryOptions.Filter, fields) {
+					items = append(items, shipment)
+				}
+			}
+			if calls.resolveOrders {
+				for i := range items {
+					items[i].Order = &VirtualExpandOrder{ID: items[i].OrderID, Customer: "resolved by the carrier API"}
+				}
+			}
+			if len(ctx.QueryOptions.OrderBy) > 0 && ctx.QueryOptions.OrderBy[0].Descending {
+				sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+			}
+			return &odata.CollectionResult{Items: items}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to set overwrite: %v", err)
+	}
+	err = service.SetEntityOverwrite("VirtualExpandStatuses", &odata.EntityOverwrite{
+		GetCollection: func(ctx *odata.OverwriteContext) (*odata.CollectionResult, error) {
+			calls.statuses++
+			var items []*VirtualExpandStatus
+			for i := range virtualExpandStatuses {
+				fields := map[string]interface{}{"OrderID": virtualExpandStatuses[i].OrderID}
+				if matchesVirtualExpandFilter(ctx.QueryOptions.Filter, fields) {
+					items = append(items, &virtualExpandStatuses[i])
+				}
+			}
+			return &odata.CollectionResult{Items: items}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to set overwrite: %v", err)
+	}
+	return service, calls
+}
+
+func getVirtualExpand(t *testing.T, service *odata.Service, target string) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	w := httptest.NewRecorder()
+	service.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected 200, got %d: %s", target, w.Code, w.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return body
+}
+
+func virtualExpandOrderByID(t *testing.T, body map[string]interface{}, id float64) map[string]interface{} {
+	t.Helper()
+	values, _ := body["value"].([]interface{})
+	for _, value := range values {
+		entity, _ := value.(map[string]interface{})
+		if entity["ID"] == id {
+			return entity
+		}
+	}
+	t.Fatalf("entity %v not found in %v", id, body)
+	return nil
+}
+
+func TestVirtualExpand_BatchesParentKeysIntoOneCall(t *testing.T) {
+	service, calls := setupVirtualExpandService(t)
+
+	body := getVirtualExpand(t, service, "/VirtualExpandOrders?$expand=Shipments")
+
+	if len(calls.shipments) != 1 {
+		t.Fatalf("expected one call to the virtual entity set, got %d", len(calls.shipments))
+	}
+	filter := calls.shipments[0].QueryOptions.Filter
+	if filter == nil || filter.Property != "OrderID" || filter.Operator != "in" {
+		t.Fatalf("expected an OrderID in (...) filter, got %+v", filter)
+	}
+	if values, _ := filter.Value.([]interface{}); len(values) != 3 {
+		t.Fatalf("expected the keys of the three orders, got %v", filter.Value)
+	}
+
+	for id, expected := range map[float64]int{1: 3, 2: 1, 3: 0} {
+		order := virtualExpandOrderByID(t, body, id)
+		shipments, _ := order["Shipments"].([]interface{})
+		if len(shipments) != expected {
+			t.Fatalf("order %v: expected %d shipments, got %v", id, expected, order["Shipments"])
+		}
+		for _, shipment := range shipments {
+			if shipment.(map[string]interface{})["OrderID"] != id {
+				t.Fatalf("order %v: shipment of another order stitched: %v", id, shipment)
+			}
+		}
+	}
+}
+
+func TestVirtualExpand_NestedQueryOptions(t *testing.T) {
+	service, calls := setupVirtualExpandService(t)
+
+	body := getVirtualExpand(t, service,
+		"/VirtualExpandOrders?$expand=Shipments($filter=State%20eq%20'Delivered';$select=Carrier;$orderby=ID%20desc;$top=1)")
+
+	if len(calls.shipments) != 1 {
+		t.Fatalf("expected one call to the virtual entity set, got %d", len(calls.shipments))
+	}
+	options := calls.shipments[0].QueryOptions
+	if options.Filter == nil || options.Filter.Logical != "and" {
+		t.Fatalf("expected the key filter combined with the nested $filter, got %+v", options.Filter)
+	}
+	if len(options.Select) != 1 || options.Select[0] != "Carrier" {
+		t.Fatalf("expected the nested $select, got %v", options.Select)
+	}
+	if options.Top != nil {
+		t.Fatalf("expected $top to be applied per parent, got %v", *options.Top)
+	}
+
+	shipments, _ := virtualExpandOrderByID(t, body, 1)["Shipments"].([]interface{})
+	if len(shipments) != 1 {
+		t.Fatalf("expected $top=1 per parent, got %v", shipments)
+	}
+	shipment := shipments[0].(map[string]interface{})
+	if shipment["Carrier"] != "UPS" {
+		t.Fatalf("expected the latest delivered shipment, got %v", shipment)
+	}
+	if _, ok := shipment["State"]; ok {
+		t.Fatalf("expected State to be omitted by $select, got %v", shipment)
+	}
+	if shipments, _ := virtualExpandOrderByID(t, body, 2)["Shipments"].([]interface{}); len(shipments) != 0 {
+		t.Fatalf("expected the nested $filter to exclude order 2's shipment, got %v", shipments)
+	}
+}
+
+func TestVirtualExpand_SingleValuedNavigationOfEntity(t *testing.T) {
+	service, calls := setupVirtualExpandService(t)
+
+	body := getVirtualExpand(t, service, "/VirtualExpandOrders(2)?$expand=Status")
+
+	if calls.statuses != 1 {
+		t.Fatalf("expected one call to the virtual entity set, got %d", calls.statuses)
+	}
+	status, _ := body["Status"].(map[string]interface{})
+	if status == nil || status["State"] != "Open" {
+		t.Fatalf("expected the status of order 2, got %v", body["Status"])
+	}
+
+	body = getVirtualExpand(t, service, "/VirtualExpandOrders(3)?$expand=Status")
+	if status, ok := body["Status"]; ok && status != nil {
+		t.Fatalf("expected no status for order 3, got %v", status)
+	}
+}
+
+func TestVirtualExpand_FromVirtualEntitySetToDatabase(t *testing.T) {
+	service, _ := setupVirtualExpandService(t)
+
+	body := getVirtualExpand(t, service, "/VirtualExpandShipments?$expand=Order")
+
+	values, _ := body["value"].([]interface{})
+	if len(values) != len(virtualExpandShipments) {
+		t.Fatalf("expected %d shipments, got %v", len(virtualExpandShipments), body)
+	}
+	for _, value := range values {
+		shipment := value.(map[string]interface{})
+		order, _ := shipment["Order"].(map[string]interface{})
+		switch shipment["OrderID"] {
+		case float64(1):
+			if order == nil || order["Customer"] != "Ada" {
+				t.Fatalf("expected order 1 of Ada, got %v", shipment)
+			}
+		case float64(9):
+			if order != nil {
+				t.Fatalf("expected no order for a dangling shipment, got %v", shipment)
+			}
+		}
+	}
+}
+
+func TestVirtualExpand_KeepsNavigationPopulatedByOverwrite(t *testing.T) {
+	service, calls := setupVirtualExpandService(t)
+	calls.resolveOrders = true
+
+	body := getVirtualExpand(t, service, "/VirtualExpandShipments?$expand=Order")
+
+	values, _ := body["value"].([]interface{})
+	for _, value := range values {
+		order, _ := value.(map[string]interface{})["Order"].(map[string]interface{})
+		if order == nil || order["Customer"] != "resolved by the carrier API" {
+			t.Fatalf("expected the order returned by the overwrite, got %v", value)
+		}
+	}
+}
+
+func TestVirtualExpand_SkipsNavigationThatCannotBeLoaded(t *testing.T) {
+	service, _ := setupVirtualExpandService(t)
+
+	for _, target := range []string{
+		"/VirtualExpandShipments?$expand=Events",
+		"/VirtualExpandShipments?$expand=Labels",
+		"/VirtualExpandShipments?$expand=Order($expand=Shipments($expand=Events))",
+	} {
+		getVirtualExpand(t, service, target)
+	}
+}
+
+func TestVirtualExpand_NestedExpandBatchesAcrossParents(t *testing.T) {
+	service, calls := setupVirtualExpandService(t)
+
+	body := getVirtualExpand(t, service, "/VirtualExpandOrders?$expand=Shipments($expand=Order($expand=Status))")
+
+	if len(calls.shipments) != 1 {
+		t.Fatalf("expected one call to the shipments, got %d", len(calls.shipments))
+	}
+	if calls.statuses != 1 {
+		t.Fatalf("expected one call to the statuses for all orders, got %d", calls.statuses)
+	}
+	for id, state := range map[float64]string{1: "Closed", 2: "Open"} {
+		shipments, _ := virtualExpandOrderByID(t, body, id)["Shipments"].([]interface{})
+		if len(shipments) == 0 {
+			t.Fatalf("order %v: expected shipments, got none", id)
+		}
+		for _, shipment := range shipments {
+			order, _ := shipment.(map[string]interface{})["Order"].(map[string]interface{})
+			status, _ := order["Status"].(map[string]interface{})
+			if status == nil || status["State"] != state {
+				t.Fatalf("order %v: expected status %s on every shipment's order, got %v", id, state, shipment)
+			}
+		}
+	}
+}
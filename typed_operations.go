@@ -0,0 +1,284 @@
+package odata
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	publicactions "github.com/nlstn/go-odata/actions"
+)
+
+// NoParams is the TParams type for typed operations that take no parameters.
+type NoParams struct{}
+
+// NoResult is the TResult type for typed actions that return no value.
+// Such actions respond with 204 No Content.
+type NoResult struct{}
+
+// TypedHandler implements an unbound typed action or function. params is
+// decoded from the request body (actions) or URL (functions) into TParams,
+// and the returned TResult is serialized with the matching @odata.context.
+type TypedHandler[TParams, TResult any] func(r *http.Request, params TParams) (TResult, error)
+
+// BoundTypedHandler implements a typed action or function bound to an entity
+// set. entity is the bound entity loaded by key, or nil when the operation is
+// invoked on the entity set itself.
+type BoundTypedHandler[TEntity, TParams, TResult any] func(r *http.Request, entity *TEntity, params TParams) (TResult, error)
+
+// RegisterTypedAction registers an unbound action whose parameters and return
+// type are derived from TParams and TResult. TParams must be a struct (or
+// pointer to struct) whose fields follow the ParameterStructType conventions;
+// use NoParams for actions without parameters and NoResult for actions
+// without a return value.
+//
+// Example:
+//
+//	type RestockParams struct {
+//	    ProductID int `json:"productID"`
+//	    Quantity  int `json:"quantity"`
+//	}
+//
+//	err := odata.RegisterTypedAction(service, "Restock",
+//	    func(r *http.Request, p RestockParams) (Product, error) {
+//	        return inventory.Restock(r.Context(), p.ProductID, p.Quantity)
+//	    })
+//
+// Invoke: POST /Restock with body {"productID": 1, "quantity": 5}
+func RegisterTypedAction[TParams, TResult any](s *Service, name string, handler TypedHandler[TParams, TResult]) error {
+	if handler == nil {
+		return fmt.Errorf("action handler cannot be nil")
+	}
+	paramType, err := typedParamType[TParams]()
+	if err != nil {
+		return fmt.Errorf("invalid parameter type for action '%s': %w", name, err)
+	}
+	returnType := typedReturnType[TResult]()
+
+	return s.RegisterAction(ActionDefinition{
+		Name:                name,
+		ParameterStructType: paramType,
+		ReturnType:          returnType,
+		Handler: func(w http.ResponseWriter, r *http.Request, _ interface{}, params map[string]interface{}) error {
+			bound, err := publicactions.BindParams[TParams](params)
+			if err != nil {
+				return typedParamError(err)
+			}
+			result, err := handler(r, bound)
+			if err != nil {
+				return err
+			}
+			s.writeTypedActionResult(w, r, returnType, result)
+			return nil
+		},
+	})
+}
+
+// RegisterBoundTypedAction registers an action bound to entitySet. The bound
+// entity is passed to the handler as *TEntity; TEntity must be the entity
+// type registered for entitySet.
+//
+// Example:
+//
+//	type DiscountParams struct {
+//	    Percentage float64 `json:"percentage" odata:"annotation:Validation.Maximum=50"`
+//	}
+//
+//	err := odata.RegisterBoundTypedAction(service, "Products", "ApplyDiscount",
+//	    func(r *http.Request, p *Product, params DiscountParams) (*Product, error) {
+//	        p.Price *= 1 - params.Percentage/100
+//	        return p, db.Save(p).Error
+//	    })
+//
+// Invoke: POST /Products(1)/ApplyDiscount with body {"percentage": 10}
+func RegisterBoundTypedAction[TEntity, TParams, TResult any](s *Service, entitySet, name string, handler BoundTypedHandler[TEntity, TParams, TResult]) error {
+	if handler == nil {
+		return fmt.Errorf("action handler cannot be nil")
+	}
+	if err := s.checkBoundEntityType(entitySet, reflect.TypeOf((*TEntity)(nil)).Elem()); err != nil {
+		return fmt.Errorf("invalid binding for action '%s': %w", name, err)
+	}
+	paramType, err := typedParamType[TParams]()
+	if err != nil {
+		return fmt.Errorf("invalid parameter type for action '%s': %w", name, err)
+	}
+	returnType := typedReturnType[TResult]()
+
+	return s.RegisterAction(ActionDefinition{
+		Name:                name,
+		IsBound:             true,
+		EntitySet:           entitySet,
+		ParameterStructType: paramType,
+		ReturnType:          returnType,
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) error {
+			entity, err := typedBoundEntity[TEntity](ctx)
+			if err != nil {
+				return err
+			}
+			bound, err := publicactions.BindParams[TParams](params)
+			if err != nil {
+				return typedParamError(err)
+			}
+			result, err := handler(r, entity, bound)
+			if err != nil {
+				return err
+			}
+			s.writeTypedActionResult(w, r, returnType, result)
+			return nil
+		},
+	})
+}
+
+// RegisterTypedFunction registers an unbound function whose parameters and
+// return type are derived from TParams and TResult. Functions must return a
+// value, so TResult cannot be NoResult.
+//
+// Example:
+//
+//	type TopProductsParams struct {
+//	    Count int64 `json:"count"`
+//	}
+//
+//	err := odata.RegisterTypedFunction(service, "GetTopProducts",
+//	    func(r *http.Request, p TopProductsParams) ([]Product, error) {
+//	        var products []Product
+//	        err := db.Order("price DESC").Limit(int(p.Count)).Find(&products).Error
+//	        return products, err
+//	    })
+//
+// Invoke: GET /GetTopProducts(count=3)
+func RegisterTypedFunction[TParams, TResult any](s *Service, name string, handler TypedHandler[TParams, TResult]) error {
+	if handler == nil {
+		return fmt.Errorf("function handler cannot be nil")
+	}
+	paramType, err := typedParamType[TParams]()
+	if err != nil {
+		return fmt.Errorf("invalid parameter type for function '%s': %w", name, err)
+	}
+
+	return s.RegisterFunction(FunctionDefinition{
+		Name:                name,
+		ParameterStructType: paramType,
+		ReturnType:          typedReturnType[TResult](),
+		Handler: func(w http.ResponseWriter, r *http.Request, _ interface{}, params map[string]interface{}) (interface{}, error) {
+			bound, err := publicactions.BindParams[TParams](params)
+			if err != nil {
+				return nil, typedParamError(err)
+			}
+			return handler(r, bound)
+		},
+	})
+}
+
+// RegisterBoundTypedFunction registers a function bound to entitySet. The
+// bound entity is passed to the handler as *TEntity; TEntity must be the
+// entity type registered for entitySet.
+//
+// Example:
+//
+//	type TotalPriceParams struct {
+//	    TaxRate float64 `json:"taxRate"`
+//	}
+//
+//	err := odata.RegisterBoundTypedFunction(service, "Products", "GetTotalPrice",
+//	    func(r *http.Request, p *Product, params TotalPriceParams) (float64, error) {
+//	        return p.Price * (1 + params.TaxRate), nil
+//	    })
+//
+// Invoke: GET /Products(1)/GetTotalPrice(taxRate=0.08)
+func RegisterBoundTypedFunction[TEntity, TParams, TResult any](s *Service, entitySet, name string, handler BoundTypedHandler[TEntity, TParams, TResult]) error {
+	if handler == nil {
+		return fmt.Errorf("function handler cannot be nil")
+	}
+	if err := s.checkBoundEntityType(entitySet, reflect.TypeOf((*TEntity)(nil)).Elem()); err != nil {
+		return fmt.Errorf("invalid binding for function '%s': %w", name, err)
+	}
+	paramType, err := typedParamType[TParams]()
+	if err != nil {
+		return fmt.Errorf("invalid parameter type for function '%s': %w", name, err)
+	}
+
+	return s.RegisterFunction(FunctionDefinition{
+		Name:                name,
+		IsBound:             true,
+		EntitySet:           entitySet,
+		ParameterStructType: paramType,
+		ReturnType:          typedReturnType[TResult](),
+		Handler: func(w http.ResponseWriter, r *http.Request, ctx interface{}, params map[string]interface{}) (interface{}, error) {
+			entity, err := typedBoundEntity[TEntity](ctx)
+			if err != nil {
+				return nil, err
+			}
+			bound, err := publicactions.BindParams[TParams](params)
+			if err != nil {
+				return nil, typedParamError(err)
+			}
+			return handler(r, entity, bound)
+		},
+	})
+}
+
+// typedParamType returns the ParameterStructType for TParams, rejecting types
+// the parameter binder cannot populate.
+func typedParamType[TParams any]() (reflect.Type, error) {
+	t := reflect.TypeOf((*TParams)(nil)).Elem()
+	if _, err := publicactions.NormalizeStructType(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// typedReturnType returns the ReturnType for TResult; NoResult maps to nil.
+func typedReturnType[TResult any]() reflect.Type {
+	t := reflect.TypeOf((*TResult)(nil)).Elem()
+	if t == reflect.TypeOf(NoResult{}) {
+		return nil
+	}
+	return t
+}
+
+// typedBoundEntity converts the bound entity loaded by the operations handler
+// into *TEntity. A nil ctx (operation invoked on the entity set) yields nil.
+func typedBoundEntity[TEntity any](ctx interface{}) (*TEntity, error) {
+	switch entity := ctx.(type) {
+	case nil:
+		return nil, nil
+	case *TEntity:
+		return entity, nil
+	case TEntity:
+		return &entity, nil
+	default:
+		return nil, fmt.Errorf("bound entity has type %T, expected *%s", ctx, reflect.TypeOf((*TEntity)(nil)).Elem())
+	}
+}
+
+// typedParamError reports a parameter binding failure as a 400 Bad Request.
+func typedParamError(err error) error {
+	return &ODataError{
+		StatusCode: http.StatusBadRequest,
+		Code:       ErrorCodeBadRequest,
+		Message:    "Invalid parameters",
+		Details:    []ErrorDetail{{Message: err.Error()}},
+	}
+}
+
+// checkBoundEntityType verifies that entitySet is registered with entity type t.
+func (s *Service) checkBoundEntityType(entitySet string, t reflect.Type) error {
+	entityMetadata, ok := s.entities[entitySet]
+	if !ok {
+		return fmt.Errorf("entity set '%s' not found", entitySet)
+	}
+	if entityMetadata.EntityType != t {
+		return fmt.Errorf("entity set '%s' holds %s, not %s", entitySet, entityMetadata.EntityType, t)
+	}
+	return nil
+}
+
+// writeTypedActionResult writes the response of a typed action: 204 No Content
+// for NoResult actions, otherwise the result with its @odata.context.
+func (s *Service) writeTypedActionResult(w http.ResponseWriter, r *http.Request, returnType reflect.Type, result interface{}) {
+	if returnType == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.operationsHandler.WriteResult(w, r, returnType, result)
+}